@@ -0,0 +1,102 @@
+package trace
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// ErrTruncated is returned from Capture.Err when the trace stream ended on an
+// event boundary before the runtime wrote the trace footer. Events emitted
+// after the stream ended are missing.
+var ErrTruncated = errors.New(`trace: stream ended before the trace footer`)
+
+// Capture streams events decoded from the Go execution tracer of the current
+// program. Events are delivered over the channel returned by Events, which is
+// closed once the stream ends.
+type Capture struct {
+	events chan *event.Event
+	done   chan struct{}
+	stop   func()
+	err    error
+}
+
+// StartCapture enables tracing for the current program and begins decoding the
+// events produced by the runtime. Only a single capture or call to Start may
+// be active at once.
+func StartCapture() (*Capture, error) {
+	r, w := io.Pipe()
+	if err := Start(w); err != nil {
+		return nil, err
+	}
+
+	c := newCapture(r)
+	c.stop = func() {
+		// trace.Stop blocks until all trace data has been written to w, which
+		// requires the decoding goroutine to keep consuming.
+		go func() {
+			defer w.Close()
+			Stop()
+		}()
+	}
+	return c, nil
+}
+
+func newCapture(r io.Reader) *Capture {
+	c := &Capture{
+		events: make(chan *event.Event, 64),
+		done:   make(chan struct{}),
+		stop:   func() {},
+	}
+	go c.decode(r)
+	return c
+}
+
+func (c *Capture) decode(r io.Reader) {
+	dec := encoding.NewDecoder(r)
+	for dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			break
+		}
+		c.events <- evt
+	}
+	if c.err = dec.Err(); c.err == nil && !dec.Stopped() {
+		c.err = ErrTruncated
+	}
+	close(c.events)
+	close(c.done)
+
+	if c.err != nil {
+		// The runtime blocks in trace.Stop until all trace data is written, so
+		// the remainder of a broken stream is consumed to let it return. This
+		// happens after the channels are closed since it does not end before
+		// the capture is stopped.
+		io.Copy(ioutil.Discard, r)
+	}
+}
+
+// Events returns the channel events are delivered on. Callers must continue
+// receiving until the channel is closed, which happens once the producer
+// stopped or the stream was broken.
+func (c *Capture) Events() <-chan *event.Event {
+	return c.events
+}
+
+// Stop stops tracing without blocking. The channel returned by Events is closed
+// once the remaining trace data has been decoded.
+func (c *Capture) Stop() {
+	c.stop()
+}
+
+// Err blocks until the channel returned by Events is closed and then reports
+// how the stream ended. It returns nil if the producer stopped cleanly,
+// ErrTruncated if the stream ended before the trace footer was written, or
+// the decoding error if the stream was broken, such as io.ErrUnexpectedEOF.
+func (c *Capture) Err() error {
+	<-c.done
+	return c.err
+}
@@ -0,0 +1,104 @@
+package trace
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/internal/tracefile"
+)
+
+func TestCapture(t *testing.T) {
+	traceList, err := tracefile.Load(`internal/tracefile`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+
+	drain := func(c *Capture) (n int) {
+		for range c.Events() {
+			n++
+		}
+		return
+	}
+	t.Run(`Stopped`, func(t *testing.T) {
+		c := newCapture(bytes.NewReader(data))
+		if n := drain(c); n == 0 {
+			t.Fatal(`exp at least one event`)
+		}
+		if err := c.Err(); err != nil {
+			t.Fatalf(`exp nil err; got %v`, err)
+		}
+	})
+	t.Run(`Truncated`, func(t *testing.T) {
+		// The header and first batch event of the trace, ending on a boundary.
+		c := newCapture(bytes.NewReader(data[:16+8]))
+		if n := drain(c); n != 1 {
+			t.Fatalf(`exp 1 event; got %v`, n)
+		}
+		if err := c.Err(); err != ErrTruncated {
+			t.Fatalf(`exp ErrTruncated; got %v`, err)
+		}
+	})
+	t.Run(`Broken`, func(t *testing.T) {
+		c := newCapture(bytes.NewReader(data[:16+7]))
+		drain(c)
+		if err := c.Err(); err != io.ErrUnexpectedEOF {
+			t.Fatalf(`exp io.ErrUnexpectedEOF; got %v`, err)
+		}
+	})
+	t.Run(`Drained`, func(t *testing.T) {
+		r, w := io.Pipe()
+		c := newCapture(r)
+		wrote := make(chan error, 1)
+		go func() {
+			// An invalid event type breaks the stream, the writes which follow
+			// block until the reader is drained.
+			w.Write(data[:16])
+			w.Write([]byte{0xff})
+			_, err := w.Write(data[16:])
+			w.Close()
+			wrote <- err
+		}()
+		drain(c)
+		if err := c.Err(); err == nil {
+			t.Fatal(`exp non-nil err`)
+		}
+		select {
+		case err := <-wrote:
+			if err != nil {
+				t.Fatalf(`exp nil err from writer; got %v`, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal(`exp writer to be unblocked once the stream broke`)
+		}
+	})
+	t.Run(`NotStopped`, func(t *testing.T) {
+		r, w := io.Pipe()
+		defer w.Close()
+
+		c := newCapture(r)
+		go func() {
+			// The writer is not closed until the test returns, like a runtime
+			// which keeps tracing until the capture is stopped.
+			w.Write(data[:16])
+			w.Write([]byte{0xff})
+			w.Write(data[16:])
+		}()
+		errc := make(chan error, 1)
+		go func() {
+			drain(c)
+			errc <- c.Err()
+		}()
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Fatal(`exp non-nil err`)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal(`exp Err to return before the capture was stopped`)
+		}
+	})
+}
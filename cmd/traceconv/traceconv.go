@@ -0,0 +1,92 @@
+// Command traceconv converts Go execution traces to formats understood by other
+// tools.
+//
+// Usage:
+//
+//	traceconv [flags] <trace file>
+//
+// Example:
+//
+//	traceconv -to mermaid -start 10ms -end 12ms -g 1,7 app.trace > app.mmd
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cstockton/go-trace/convert"
+)
+
+var (
+	flagTo    = flag.String(`to`, `mermaid`, `output format: mermaid, mermaid-gantt`)
+	flagOut   = flag.String(`o`, ``, `output file, defaults to stdout`)
+	flagStart = flag.Duration(`start`, 0, `start of the window relative to the first event`)
+	flagEnd   = flag.Duration(`end`, 0, `end of the window relative to the first event`)
+	flagG     = flag.String(`g`, ``, `comma separated goroutine ids to include`)
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: traceconv [flags] <trace file>\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "traceconv: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(path string) error {
+	win, err := window()
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var out io.Writer = os.Stdout
+	if *flagOut != `` {
+		f, err := os.Create(*flagOut)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch *flagTo {
+	case `mermaid`:
+		return convert.Mermaid(out, in, convert.Sequence, win)
+	case `mermaid-gantt`:
+		return convert.Mermaid(out, in, convert.Gantt, win)
+	}
+	return fmt.Errorf(`unknown output format %q`, *flagTo)
+}
+
+func window() (convert.Window, error) {
+	win := convert.Window{Start: *flagStart, End: *flagEnd}
+	if *flagG == `` {
+		return win, nil
+	}
+	for _, s := range strings.Split(*flagG, `,`) {
+		g, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return win, fmt.Errorf(`invalid goroutine id %q`, s)
+		}
+		win.Goroutines = append(win.Goroutines, g)
+	}
+	return win, nil
+}
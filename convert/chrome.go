@@ -0,0 +1,333 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// chromeEvent is an event of the Chrome Trace Event Format, timestamps and
+// durations are in microseconds.
+type chromeEvent struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat"`
+	Ph   string                 `json:"ph"`
+	Ts   float64                `json:"ts"`
+	Dur  float64                `json:"dur"`
+	Pid  interface{}            `json:"pid"`
+	Tid  interface{}            `json:"tid"`
+	Args map[string]interface{} `json:"args"`
+}
+
+// chromeThread holds the slices and instant events of a thread, which becomes
+// a goroutine of the synthesized trace.
+type chromeThread struct {
+	label    string
+	slices   []chromeSpan
+	instants []chromeSpan
+	open     []chromeSpan
+}
+
+// chromeSpan is a named span of time in nanoseconds, instants have a zero
+// length.
+type chromeSpan struct {
+	name, cat  string
+	start, end int64
+}
+
+// FromChrome reads a timeline in the Chrome Trace Event Format from r and
+// writes a Go trace synthesized from it to w, for generating fixtures and for
+// testing trace viewers with externally authored timelines. Both the JSON
+// object format holding a "traceEvents" array and the bare JSON array format
+// are accepted.
+//
+// Each thread, identified by its pid and tid, becomes a goroutine running on
+// a P of its own. The complete ("X") and begin and end ("B", "E") events of a
+// thread become user regions, and the goroutine runs from the start of each
+// run of overlapping regions until its end, when it yields. Instant events
+// ("i", "I") become user logs keyed by their category and thread names given
+// by "M" events label the starts of their goroutine. Other events are ignored.
+//
+// Timestamps are written in nanoseconds with a frequency of 1e9 ticks per
+// second, shifted when needed so every timestamp is positive.
+func FromChrome(w io.Writer, r io.Reader) error {
+	evts, err := readChrome(r)
+	if err != nil {
+		return err
+	}
+
+	var (
+		threads []*chromeThread
+		ids     = make(map[string]int)
+		min     = int64(math.MaxInt64)
+		max     = int64(math.MinInt64)
+	)
+	thread := func(ce *chromeEvent) *chromeThread {
+		key := fmt.Sprintf(`%v/%v`, ce.Pid, ce.Tid)
+		if i, ok := ids[key]; ok {
+			return threads[i]
+		}
+		ids[key] = len(threads)
+		threads = append(threads, new(chromeThread))
+		return threads[len(threads)-1]
+	}
+	for i := range evts {
+		ce := &evts[i]
+		ts := int64(math.Round(ce.Ts * 1e3))
+		end := ts + int64(math.Round(ce.Dur*1e3))
+		switch ce.Ph {
+		case `X`:
+			if end < ts {
+				return fmt.Errorf(`convert: event %q has negative duration`, ce.Name)
+			}
+			th := thread(ce)
+			th.slices = append(th.slices, chromeSpan{ce.Name, ce.Cat, ts, end})
+		case `B`:
+			th := thread(ce)
+			th.open = append(th.open, chromeSpan{ce.Name, ce.Cat, ts, ts})
+		case `E`:
+			th := thread(ce)
+			n := len(th.open)
+			if n == 0 {
+				return fmt.Errorf(`convert: end event at %v has no begin event`, ce.Ts)
+			}
+			sp := th.open[n-1]
+			th.open = th.open[:n-1]
+			if sp.end = ts; sp.end < sp.start {
+				return fmt.Errorf(`convert: event %q ends before it begins`, sp.name)
+			}
+			th.slices = append(th.slices, sp)
+		case `i`, `I`:
+			th := thread(ce)
+			th.instants = append(th.instants, chromeSpan{ce.Name, ce.Cat, ts, ts})
+		case `M`:
+			if ce.Name == `thread_name` {
+				if name, ok := ce.Args[`name`].(string); ok {
+					thread(ce).label = name
+				}
+			}
+			continue
+		default:
+			continue
+		}
+		if ts < min {
+			min = ts
+		}
+		if end > max {
+			max = end
+		}
+	}
+	if min > max {
+		return errors.New(`convert: timeline contains no events`)
+	}
+
+	// Slices which were begun but never ended last until the end of the trace.
+	for _, th := range threads {
+		for _, sp := range th.open {
+			sp.end = max
+			th.slices = append(th.slices, sp)
+		}
+	}
+
+	s := &chromeSynth{strs: make(map[string]uint64)}
+	if min <= 0 {
+		s.base = min - 1
+	}
+	for i, th := range threads {
+		s.thread(int64(i), th, min, max)
+	}
+	return s.write(w)
+}
+
+// readChrome decodes the events of a trace in either the JSON object or JSON
+// array format.
+func readChrome(r io.Reader) ([]chromeEvent, error) {
+	br := bufio.NewReader(r)
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		br.UnreadByte()
+		var evts []chromeEvent
+		if c == '[' {
+			err = json.NewDecoder(br).Decode(&evts)
+		} else {
+			var obj struct {
+				TraceEvents []chromeEvent `json:"traceEvents"`
+			}
+			err = json.NewDecoder(br).Decode(&obj)
+			evts = obj.TraceEvents
+		}
+		if err != nil {
+			return nil, fmt.Errorf(`convert: malformed trace event json: %v`, err)
+		}
+		return evts, nil
+	}
+}
+
+// chromeSynth accumulates the events synthesized from the threads of a Chrome
+// trace, with timestamps in nanoseconds after base.
+type chromeSynth struct {
+	base int64
+	strs map[string]uint64
+	decl []*event.Event
+	evts []*event.Event
+}
+
+// str returns the id of the string v, declaring it when it has not been seen
+// before.
+func (s *chromeSynth) str(v string) uint64 {
+	if id, ok := s.strs[v]; ok {
+		return id
+	}
+	id := uint64(len(s.strs) + 1)
+	s.strs[v] = id
+	evt := event.MustNew(event.EvString, id)
+	evt.Data = []byte(v)
+	s.decl = append(s.decl, evt)
+	return id
+}
+
+// emit adds an event of the given type at ts to the P and G of a thread.
+func (s *chromeSynth) emit(p int64, ts int64, typ event.Type, args ...uint64) *event.Event {
+	evt := event.MustNew(typ, append([]uint64{0}, args...)...)
+	evt.Ts, evt.P, evt.G = ts-s.base, p, p+1
+	s.evts = append(s.evts, evt)
+	return evt
+}
+
+// chromeMark is a point within a run of a goroutine at which a region begins
+// or ends or a log is written.
+type chromeMark struct {
+	ts    int64
+	phase int
+	sp    *chromeSpan
+}
+
+// Phases of marks sharing a timestamp, so the regions ending at a time are
+// ended before those beginning at it.
+const (
+	markEnd = iota
+	markLog
+	markBegin
+)
+
+// thread synthesizes the events of the goroutine of th running on P p from
+// the start of the trace at min until its end at max.
+func (s *chromeSynth) thread(p int64, th *chromeThread, min, max int64) {
+	g := uint64(p + 1)
+	s.emit(p, min, event.EvProcStart, uint64(p))
+	s.emit(p, min, event.EvGoCreate, g, 0, 0)
+
+	var marks []chromeMark
+	for i := range th.slices {
+		sp := &th.slices[i]
+		marks = append(marks, chromeMark{sp.start, markBegin, sp})
+		if sp.end > sp.start {
+			marks = append(marks, chromeMark{sp.end, markEnd, sp})
+		}
+	}
+	for i := range th.instants {
+		marks = append(marks, chromeMark{th.instants[i].start, markLog, &th.instants[i]})
+	}
+	sort.SliceStable(marks, func(i, j int) bool {
+		a, b := marks[i], marks[j]
+		switch {
+		case a.ts != b.ts:
+			return a.ts < b.ts
+		case a.phase != b.phase:
+			return a.phase < b.phase
+		case a.phase == markBegin:
+			// Outer regions begin before the regions nested within them.
+			return a.sp.end > b.sp.end
+		case a.phase == markEnd:
+			return a.sp.start > b.sp.start
+		}
+		return false
+	})
+
+	var (
+		seq     uint64
+		depth   int
+		running bool
+		label   uint64
+	)
+	if th.label != `` {
+		label = s.str(th.label)
+	}
+	for i, m := range marks {
+		if !running {
+			seq++
+			if label != 0 {
+				s.emit(p, m.ts, event.EvGoStartLabel, g, seq, label)
+			} else {
+				s.emit(p, m.ts, event.EvGoStart, g, seq)
+			}
+			running = true
+		}
+
+		switch m.phase {
+		case markBegin:
+			name := s.str(m.sp.name)
+			s.emit(p, m.ts, event.EvUserRegion, 0, 0, name, 0)
+			if m.sp.end == m.sp.start {
+				s.emit(p, m.ts, event.EvUserRegion, 0, 1, name, 0)
+			} else {
+				depth++
+			}
+		case markEnd:
+			s.emit(p, m.ts, event.EvUserRegion, 0, 1, s.str(m.sp.name), 0)
+			depth--
+		case markLog:
+			key := m.sp.cat
+			if key == `` {
+				key = `chrome`
+			}
+			evt := s.emit(p, m.ts, event.EvUserLog, 0, s.str(key), 0)
+			evt.Data = []byte(m.sp.name)
+		}
+
+		// Yield once no region remains open and the next mark is later.
+		if depth == 0 && (i+1 == len(marks) || marks[i+1].ts > m.ts) {
+			s.emit(p, m.ts, event.EvGoSched, 0)
+			running = false
+		}
+	}
+	s.emit(p, max, event.EvProcStop)
+}
+
+// write encodes the synthesized events to w ordered by time. The strings they
+// refer to are declared first, the SortWindow holds them until it begins the
+// first batch.
+func (s *chromeSynth) write(w io.Writer) error {
+	sort.SliceStable(s.evts, func(i, j int) bool {
+		return s.evts[i].Ts < s.evts[j].Ts
+	})
+
+	enc := encoding.NewEncoder(w, encoding.SortWindow(1))
+	for _, evt := range s.decl {
+		if err := enc.Emit(evt); err != nil {
+			return err
+		}
+	}
+	for _, evt := range s.evts {
+		if err := enc.Emit(evt); err != nil {
+			return err
+		}
+	}
+	if err := enc.Emit(event.MustNew(event.EvFrequency, 1e9)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,97 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+const chromeTimeline = `{"traceEvents": [
+	{"name": "thread_name", "ph": "M", "pid": 1, "tid": 1, "args": {"name": "main"}},
+	{"name": "outer", "ph": "X", "ts": 10, "dur": 10, "pid": 1, "tid": 1},
+	{"name": "inner", "ph": "X", "ts": 10, "dur": 5, "pid": 1, "tid": 1},
+	{"name": "next", "ph": "X", "ts": 20, "dur": 2, "pid": 1, "tid": 1},
+	{"name": "mark", "cat": "app", "ph": "i", "ts": 30, "pid": 1, "tid": 1},
+	{"name": "work", "ph": "B", "ts": 12, "pid": 1, "tid": "worker"},
+	{"name": "work", "ph": "E", "ts": 18, "pid": 1, "tid": "worker"},
+	{"name": "ignored", "ph": "C", "ts": 1, "pid": 2, "tid": 1}
+]}`
+
+func TestFromChrome(t *testing.T) {
+	for _, in := range []string{chromeTimeline, chromeTimeline[len(`{"traceEvents": `) : len(chromeTimeline)-1]} {
+		var buf bytes.Buffer
+		if err := FromChrome(&buf, strings.NewReader(in)); err != nil {
+			t.Fatal(err)
+		}
+		tr, evts, err := readOrdered(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tr.Frequency != 1e9 {
+			t.Fatalf(`exp frequency of 1e9; got %v`, tr.Frequency)
+		}
+
+		v, err := event.NewValidator(tr.Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var starts, labels int
+		for _, evt := range evts {
+			if err := v.Visit(evt); err != nil {
+				t.Fatal(err)
+			}
+			switch evt.Type {
+			case event.EvGoStart:
+				starts++
+			case event.EvGoStartLabel:
+				labels++
+			}
+		}
+		// Regions which touch share a run, so main runs until 22us and again
+		// at 30us.
+		if starts != 1 || labels != 2 {
+			t.Fatalf(`exp 1 start and 2 labeled starts; got %v and %v`, starts, labels)
+		}
+
+		tt, err := tr.Tasks(evts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(tt.Regions); n != 3 {
+			t.Fatalf(`exp 3 outermost regions; got %v`, n)
+		}
+		outer := tt.Regions[0]
+		if outer.Name != `outer` || outer.Start != 10e3 || outer.End != 20e3 {
+			t.Fatalf(`exp outer region from 10us to 20us; got %+v`, outer)
+		}
+		if len(outer.Children) != 1 || outer.Children[0].Name != `inner` {
+			t.Fatalf(`exp inner region nested in outer; got %+v`, outer.Children)
+		}
+		if work := tt.Regions[1]; work.Name != `work` || work.Duration() != 6e3 {
+			t.Fatalf(`exp work region of 6us; got %+v`, work)
+		}
+		if len(tt.Logs) != 1 || tt.Logs[0].Key != `app` || tt.Logs[0].Value != `mark` {
+			t.Fatalf(`exp mark log keyed by app; got %+v`, tt.Logs)
+		}
+	}
+}
+
+func TestFromChromeErrors(t *testing.T) {
+	tests := []string{
+		``,
+		`{"traceEvents": [`,
+		`{"traceEvents": []}`,
+		`[{"name": "a", "ph": "E", "ts": 1, "pid": 1, "tid": 1}]`,
+		`[{"name": "a", "ph": "X", "ts": 1, "dur": -2, "pid": 1, "tid": 1}]`,
+		`[{"name": "a", "ph": "B", "ts": 5, "pid": 1, "tid": 1},
+		  {"name": "a", "ph": "E", "ts": 1, "pid": 1, "tid": 1}]`,
+	}
+	for _, in := range tests {
+		var buf bytes.Buffer
+		if err := FromChrome(&buf, strings.NewReader(in)); err == nil {
+			t.Fatalf(`exp non-nil err for %q`, in)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package convert implements conversions from the Go trace format to formats
+// understood by other tools, and from the formats of other tools back into Go
+// traces.
+package convert
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// Window selects the portion of a trace to convert.
+type Window struct {
+
+	// Start and End are durations relative to the first event of the trace. A
+	// zero End selects all events after Start.
+	Start, End time.Duration
+
+	// Goroutines limits conversion to the given goroutine ids, all goroutines
+	// are selected when empty.
+	Goroutines []uint64
+}
+
+func (w Window) contains(d time.Duration) bool {
+	return d >= w.Start && (w.End == 0 || d <= w.End)
+}
+
+func (w Window) overlaps(start, end time.Duration) bool {
+	return end >= w.Start && (w.End == 0 || start <= w.End)
+}
+
+func (w Window) selects(g uint64) bool {
+	if len(w.Goroutines) == 0 {
+		return true
+	}
+	for _, v := range w.Goroutines {
+		if v == g {
+			return true
+		}
+	}
+	return false
+}
+
+// segment is a span of time a goroutine was running on a P.
+type segment struct {
+	g          uint64
+	p          int64
+	start, end time.Duration
+}
+
+// edge is an interaction from one goroutine to another, such as GoCreate.
+type edge struct {
+	typ      event.Type
+	from, to uint64
+	ts       time.Duration
+}
+
+// timeline holds the run segments and edges of each goroutine in a trace.
+type timeline struct {
+	segments []segment
+	edges    []edge
+}
+
+// run tracks the goroutine running on a P while building a timeline.
+type run struct {
+	g       uint64
+	running bool
+	start   int64
+	last    int64
+}
+
+type tickSegment struct {
+	g          uint64
+	p          int64
+	start, end int64
+}
+
+type tickEdge struct {
+	typ      event.Type
+	from, to uint64
+	ts       int64
+}
+
+// readTimeline decodes all events from r into a timeline.
+func readTimeline(r io.Reader) (*timeline, error) {
+	var (
+		evt   event.Event
+		freq  uint64
+		min   int64 = -1
+		segs  []tickSegment
+		edges []tickEdge
+		runs  = make(map[int64]*run)
+		dec   = encoding.NewDecoder(r)
+	)
+	ver, err := dec.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	// Version1 events are prefixed with a sequence before the timestamp.
+	var argoff int
+	if ver == event.Version1 {
+		argoff = 1
+	}
+	arg := func(name string) uint64 {
+		idx, ok := evt.Type.Arg(name)
+		if !ok || idx+argoff >= len(evt.Args) {
+			return 0
+		}
+		return evt.Args[idx+argoff]
+	}
+	stop := func(p int64, pr *run) {
+		if pr.running {
+			segs = append(segs, tickSegment{pr.g, p, pr.start, pr.last})
+		}
+		pr.running, pr.g = false, 0
+	}
+
+	for dec.More() {
+		evt.Reset()
+		if err := dec.Decode(&evt); err != nil {
+			break
+		}
+		if evt.Type == event.EvFrequency {
+			freq = evt.Args[0]
+			continue
+		}
+		if evt.Ts == 0 {
+			continue
+		}
+
+		pr := runs[evt.P]
+		if pr == nil {
+			pr = new(run)
+			runs[evt.P] = pr
+		}
+		pr.last = evt.Ts
+		if min == -1 || evt.Ts < min {
+			min = evt.Ts
+		}
+
+		switch evt.Type {
+		case event.EvGoStart, event.EvGoStartLocal, event.EvGoStartLabel:
+			stop(evt.P, pr)
+			pr.g, pr.running, pr.start = uint64(evt.G), true, evt.Ts
+		case event.EvGoEnd, event.EvGoStop, event.EvGoSched, event.EvGoPreempt,
+			event.EvGoSleep, event.EvGoBlock, event.EvGoBlockSend,
+			event.EvGoBlockRecv, event.EvGoBlockSelect, event.EvGoBlockSync,
+			event.EvGoBlockCond, event.EvGoBlockNet, event.EvGoBlockGC,
+			event.EvGoSysBlock, event.EvProcStop:
+			stop(evt.P, pr)
+		case event.EvGoCreate:
+			edges = append(edges, tickEdge{
+				evt.Type, uint64(evt.G), arg(event.ArgNewGoroutineID), evt.Ts})
+		case event.EvGoUnblock, event.EvGoUnblockLocal:
+			edges = append(edges, tickEdge{
+				evt.Type, uint64(evt.G), arg(event.ArgGoroutineID), evt.Ts})
+		}
+	}
+	if err := dec.Err(); err != nil {
+		return nil, err
+	}
+	if freq == 0 {
+		return nil, errors.New(`convert: trace contains no frequency event`)
+	}
+
+	// Runs still in progress end with the last event on their P.
+	for p, pr := range runs {
+		stop(p, pr)
+	}
+
+	dur := func(ticks int64) time.Duration {
+		return time.Duration(float64(ticks-min) * 1e9 / float64(freq))
+	}
+	tl := new(timeline)
+	for _, s := range segs {
+		tl.segments = append(tl.segments, segment{s.g, s.p, dur(s.start), dur(s.end)})
+	}
+	for _, e := range edges {
+		tl.edges = append(tl.edges, edge{e.typ, e.from, e.to, dur(e.ts)})
+	}
+	sort.SliceStable(tl.segments, func(i, j int) bool {
+		return tl.segments[i].start < tl.segments[j].start
+	})
+	sort.SliceStable(tl.edges, func(i, j int) bool {
+		return tl.edges[i].ts < tl.edges[j].ts
+	})
+	return tl, nil
+}
+
+// window returns a copy of this timeline containing only the segments and edges
+// selected by w, with segments clipped to the bounds of w.
+func (tl *timeline) window(w Window) *timeline {
+	out := new(timeline)
+	for _, s := range tl.segments {
+		if !w.selects(s.g) || !w.overlaps(s.start, s.end) {
+			continue
+		}
+		if s.start < w.Start {
+			s.start = w.Start
+		}
+		if w.End != 0 && s.end > w.End {
+			s.end = w.End
+		}
+		out.segments = append(out.segments, s)
+	}
+	for _, e := range tl.edges {
+		if !w.contains(e.ts) || !(w.selects(e.from) || w.selects(e.to)) {
+			continue
+		}
+		out.edges = append(out.edges, e)
+	}
+	return out
+}
+
+// goroutines returns the sorted ids of all goroutines in this timeline.
+func (tl *timeline) goroutines() []uint64 {
+	seen := make(map[uint64]bool)
+	for _, s := range tl.segments {
+		seen[s.g] = true
+	}
+	for _, e := range tl.edges {
+		seen[e.from], seen[e.to] = true, true
+	}
+
+	out := make([]uint64, 0, len(seen))
+	for g := range seen {
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
@@ -0,0 +1,110 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Diagram is the kind of diagram to generate.
+type Diagram int
+
+// Diagram kinds supported by Mermaid.
+const (
+
+	// Sequence diagrams show goroutines as participants with create and unblock
+	// interactions between them, noting each span of time they were running.
+	Sequence Diagram = iota
+
+	// Gantt diagrams show a section per goroutine containing a task for each
+	// span of time it was running. Times are in microseconds from Window.Start.
+	Gantt
+)
+
+// Mermaid reads a trace from r and writes a Mermaid diagram of the goroutine
+// interactions within win to w. It is intended for short windows of time and
+// small sets of goroutines to embed in design docs or postmortems, larger
+// selections will produce diagrams too dense to be useful.
+func Mermaid(w io.Writer, r io.Reader, d Diagram, win Window) error {
+	tl, err := readTimeline(r)
+	if err != nil {
+		return err
+	}
+	tl = tl.window(win)
+
+	bw := bufio.NewWriter(w)
+	switch d {
+	case Sequence:
+		writeSequence(bw, tl)
+	case Gantt:
+		writeGantt(bw, tl, win)
+	default:
+		return fmt.Errorf(`convert: unknown diagram kind %d`, d)
+	}
+	return bw.Flush()
+}
+
+func writeSequence(w *bufio.Writer, tl *timeline) {
+	fmt.Fprintln(w, `sequenceDiagram`)
+	for _, g := range tl.goroutines() {
+		fmt.Fprintf(w, "    participant %v\n", goName(g))
+	}
+
+	// Merge the segments and edges in order of time.
+	var i, j int
+	for i < len(tl.segments) || j < len(tl.edges) {
+		if j == len(tl.edges) ||
+			(i < len(tl.segments) && tl.segments[i].start <= tl.edges[j].ts) {
+			s := tl.segments[i]
+			fmt.Fprintf(w, "    Note over %v: run on P%v %v - %v\n",
+				goName(s.g), s.p, fmtDuration(s.start), fmtDuration(s.end))
+			i++
+			continue
+		}
+		e := tl.edges[j]
+		fmt.Fprintf(w, "    %v->>%v: %v at %v\n",
+			goName(e.from), goName(e.to), e.typ.Name(), fmtDuration(e.ts))
+		j++
+	}
+}
+
+func writeGantt(w *bufio.Writer, tl *timeline, win Window) {
+	fmt.Fprintln(w, `gantt`)
+	fmt.Fprintln(w, `    dateFormat x`)
+	fmt.Fprintln(w, `    axisFormat %L`)
+
+	us := func(d time.Duration) int64 {
+		return int64((d - win.Start) / time.Microsecond)
+	}
+	for _, g := range tl.goroutines() {
+		var section bool
+		for _, s := range tl.segments {
+			if s.g != g {
+				continue
+			}
+			if !section {
+				fmt.Fprintf(w, "    section %v\n", goName(g))
+				section = true
+			}
+			start, end := us(s.start), us(s.end)
+			if end <= start {
+				end = start + 1
+			}
+			fmt.Fprintf(w, "    P%v :%v, %v\n", s.p, start, end)
+		}
+	}
+}
+
+// goName returns the participant name for a goroutine, goroutine 0 represents
+// events that were emitted while no goroutine was running.
+func goName(g uint64) string {
+	if g == 0 {
+		return `runtime`
+	}
+	return fmt.Sprintf(`G%v`, g)
+}
+
+func fmtDuration(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
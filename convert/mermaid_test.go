@@ -0,0 +1,89 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cstockton/go-trace/internal/tracefile"
+)
+
+var traceList tracefile.TraceList
+
+func init() {
+	var err error
+	traceList, err = tracefile.Load(`../internal/tracefile`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Mermaid(&buf, bytes.NewReader(tf.Bytes()), Sequence, Window{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := buf.String()
+			if !strings.HasPrefix(out, "sequenceDiagram\n") {
+				t.Fatalf(`exp sequence diagram; got %q`, out)
+			}
+			for _, exp := range []string{`participant G1`, `GoCreate at`, `Note over G1: run`} {
+				if !strings.Contains(out, exp) {
+					t.Fatalf(`exp diagram to contain %q; got:\n%v`, exp, out)
+				}
+			}
+
+			buf.Reset()
+			win := Window{End: time.Second, Goroutines: []uint64{1}}
+			err = Mermaid(&buf, bytes.NewReader(tf.Bytes()), Gantt, win)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = buf.String()
+			if !strings.HasPrefix(out, "gantt\n") {
+				t.Fatalf(`exp gantt diagram; got %q`, out)
+			}
+			if got := strings.Count(out, `section`); got != 1 {
+				t.Fatalf(`exp 1 section for goroutine 1; got %v in:\n%v`, got, out)
+			}
+		})
+	}
+	t.Run(`Errors`, func(t *testing.T) {
+		data := traceList.ByName(`log.trace`)[0].Bytes()
+		var buf bytes.Buffer
+		if err := Mermaid(&buf, bytes.NewReader(data), Diagram(-1), Window{}); err == nil {
+			t.Fatal(`exp non-nil err for unknown diagram`)
+		}
+		if err := Mermaid(&buf, bytes.NewReader(data[:64]), Sequence, Window{}); err == nil {
+			t.Fatal(`exp non-nil err for trace without frequency`)
+		}
+	})
+}
+
+func TestWindow(t *testing.T) {
+	tl := &timeline{
+		segments: []segment{
+			{g: 1, start: 0, end: 10},
+			{g: 2, start: 5, end: 20},
+			{g: 1, start: 30, end: 40},
+		},
+		edges: []edge{{from: 1, to: 2, ts: 5}, {from: 2, to: 3, ts: 35}},
+	}
+	out := tl.window(Window{Start: 8, End: 30, Goroutines: []uint64{2}})
+	if exp, got := 1, len(out.segments); exp != got {
+		t.Fatalf(`exp %v segments; got %v`, exp, got)
+	}
+	if s := out.segments[0]; s.start != 8 || s.end != 20 {
+		t.Fatalf(`exp segment clipped to [8, 20]; got [%v, %v]`, s.start, s.end)
+	}
+	if exp, got := 0, len(out.edges); exp != got {
+		t.Fatalf(`exp %v edges; got %v`, exp, got)
+	}
+	if exp, got := []uint64{2}, out.goroutines(); len(got) != 1 || got[0] != exp[0] {
+		t.Fatalf(`exp goroutines %v; got %v`, exp, got)
+	}
+}
@@ -0,0 +1,415 @@
+package convert
+
+import (
+	"encoding/binary"
+	"io"
+	"math/bits"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// parquetGroupRows is the number of events written to each row group of the
+// files written by Parquet, which bounds the events buffered while converting.
+var parquetGroupRows int64 = 1 << 16
+
+// Parquet reads a trace from r and writes a Parquet file of its events to w,
+// for querying large traces with columnar engines such as Spark, BigQuery or
+// DuckDB. Each row is an event other than the EvString and EvStack events
+// declaring the strings and stacks of a trace, in the order they appear, with
+// the columns:
+//
+//	off       int64   offset of the event within the trace
+//	type      string  name of the event type, dictionary encoded
+//	ts        int64   timestamp in ticks
+//	p         int64   P of the event, dictionary encoded
+//	g         int64   G of the event, dictionary encoded
+//	stack_id  int64   stack id of the event or 0 when it has none
+//
+// Events are written uncompressed in row groups of 65536 rows, which bounds
+// the events buffered while converting. See SQLite for exporting strings and
+// stacks.
+func Parquet(w io.Writer, r io.Reader) error {
+	pw := newParquetWriter(w, []*parquetColumn{
+		{name: `off`, typ: parquetInt64},
+		{name: `type`, typ: parquetByteArray, utf8: true, dict: true},
+		{name: `ts`, typ: parquetInt64},
+		{name: `p`, typ: parquetInt64, dict: true},
+		{name: `g`, typ: parquetInt64, dict: true},
+		{name: `stack_id`, typ: parquetInt64},
+	})
+	err := streamEvents(r, func(tr *event.Trace, argoff int, evt *event.Event) error {
+		if evt.Type == event.EvString || evt.Type == event.EvStack {
+			return nil
+		}
+		var stk uint64
+		if i, ok := evt.Type.Arg(event.ArgStackID); ok && i+argoff < len(evt.Args) {
+			stk = evt.Args[i+argoff]
+		}
+		c := pw.cols
+		c[0].int64(int64(evt.Off))
+		c[1].bytes(evt.Type.Name())
+		c[2].int64(evt.Ts)
+		c[3].int64(evt.P)
+		c[4].int64(evt.G)
+		c[5].int64(int64(stk))
+		return pw.row()
+	})
+	if err != nil {
+		return err
+	}
+	return pw.close()
+}
+
+// Parquet physical types, encodings and page types from parquet.thrift.
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetPlain           = 0
+	parquetPlainDictionary = 2
+	parquetRLE             = 3
+
+	parquetDataPage       = 0
+	parquetDictionaryPage = 2
+)
+
+// parquetMagic begins and ends every Parquet file.
+const parquetMagic = `PAR1`
+
+// parquetColumn buffers the values of a column for the current row group, in
+// their plain encoding or as dictionary indices when dict is true.
+type parquetColumn struct {
+	name string
+	typ  int32
+	utf8 bool
+	dict bool
+
+	plain   []byte
+	index   map[string]uint64
+	indices []uint64
+	entries []byte
+}
+
+func (c *parquetColumn) int64(v int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(v))
+	c.add(b[:])
+}
+
+func (c *parquetColumn) bytes(s string) {
+	b := make([]byte, 4+len(s))
+	binary.LittleEndian.PutUint32(b, uint32(len(s)))
+	copy(b[4:], s)
+	c.add(b)
+}
+
+// add adds the plain encoded value b to the column.
+func (c *parquetColumn) add(b []byte) {
+	if !c.dict {
+		c.plain = append(c.plain, b...)
+		return
+	}
+	if c.index == nil {
+		c.index = make(map[string]uint64)
+	}
+	idx, ok := c.index[string(b)]
+	if !ok {
+		idx = uint64(len(c.index))
+		c.index[string(b)] = idx
+		c.entries = append(c.entries, b...)
+	}
+	c.indices = append(c.indices, idx)
+}
+
+func (c *parquetColumn) reset() {
+	c.plain, c.index, c.indices, c.entries = c.plain[:0], nil, c.indices[:0], c.entries[:0]
+}
+
+// rle returns the indices of the column in the RLE / bit-packing hybrid
+// encoding prefixed by their bit width, written entirely as RLE runs.
+func (c *parquetColumn) rle() []byte {
+	width := bits.Len64(uint64(len(c.index) - 1))
+	if width == 0 {
+		width = 1
+	}
+	size := (width + 7) / 8
+
+	var (
+		b   = []byte{byte(width)}
+		buf [binary.MaxVarintLen64]byte
+	)
+	for i := 0; i < len(c.indices); {
+		j := i + 1
+		for j < len(c.indices) && c.indices[j] == c.indices[i] {
+			j++
+		}
+		b = append(b, buf[:binary.PutUvarint(buf[:], uint64(j-i)<<1)]...)
+		for k, v := 0, c.indices[i]; k < size; k, v = k+1, v>>8 {
+			b = append(b, byte(v))
+		}
+		i = j
+	}
+	return b
+}
+
+// parquetChunk locates the pages of a column within a row group.
+type parquetChunk struct {
+	dictOff, dataOff int64
+	size             int64
+}
+
+type parquetRowGroup struct {
+	chunks     []parquetChunk
+	rows, size int64
+}
+
+// parquetWriter writes the rows of its columns to w in row groups, followed by
+// the file metadata once closed.
+type parquetWriter struct {
+	w      io.Writer
+	off    int64
+	err    error
+	cols   []*parquetColumn
+	rows   int64
+	total  int64
+	groups []parquetRowGroup
+}
+
+func newParquetWriter(w io.Writer, cols []*parquetColumn) *parquetWriter {
+	pw := &parquetWriter{w: w, cols: cols}
+	pw.write([]byte(parquetMagic))
+	return pw
+}
+
+func (pw *parquetWriter) write(b []byte) {
+	if pw.err != nil {
+		return
+	}
+	var n int
+	n, pw.err = pw.w.Write(b)
+	pw.off += int64(n)
+}
+
+// row completes the row added to each column, writing a row group when it
+// holds parquetGroupRows rows.
+func (pw *parquetWriter) row() error {
+	if pw.rows++; pw.rows >= parquetGroupRows {
+		pw.flush()
+	}
+	return pw.err
+}
+
+// flush writes the buffered rows as a row group.
+func (pw *parquetWriter) flush() {
+	if pw.rows == 0 {
+		return
+	}
+	rg := parquetRowGroup{rows: pw.rows}
+	for _, c := range pw.cols {
+		ch := parquetChunk{dictOff: -1, dataOff: pw.off}
+		data := c.plain
+		if c.dict {
+			ch.dictOff = pw.off
+			pw.page(parquetDictionaryPage, c.entries, len(c.index), parquetPlainDictionary)
+			ch.dataOff = pw.off
+			data = c.rle()
+			pw.page(parquetDataPage, data, int(pw.rows), parquetPlainDictionary)
+		} else {
+			pw.page(parquetDataPage, data, int(pw.rows), parquetPlain)
+		}
+		ch.size = pw.off - ch.dataOff
+		if ch.dictOff >= 0 {
+			ch.size = pw.off - ch.dictOff
+		}
+		rg.size += ch.size
+		rg.chunks = append(rg.chunks, ch)
+		c.reset()
+	}
+	pw.groups = append(pw.groups, rg)
+	pw.total += pw.rows
+	pw.rows = 0
+}
+
+// page writes a page of the given type holding n values encoded as data.
+func (pw *parquetWriter) page(typ int32, data []byte, n int, enc int32) {
+	var t thrift
+	t.i32(1, typ)
+	t.i32(2, int32(len(data)))
+	t.i32(3, int32(len(data)))
+	if typ == parquetDictionaryPage {
+		t.begin(7)
+		t.i32(1, int32(n))
+		t.i32(2, enc)
+		t.end()
+	} else {
+		t.begin(5)
+		t.i32(1, int32(n))
+		t.i32(2, enc)
+		t.i32(3, parquetRLE)
+		t.i32(4, parquetRLE)
+		t.end()
+	}
+	t.stop()
+	pw.write(t.b)
+	pw.write(data)
+}
+
+// close writes the remaining rows and the file metadata.
+func (pw *parquetWriter) close() error {
+	pw.flush()
+
+	var t thrift
+	t.i32(1, 1)
+	t.list(2, thriftStruct, len(pw.cols)+1)
+	t.push()
+	t.binary(4, `schema`)
+	t.i32(5, int32(len(pw.cols)))
+	t.end()
+	for _, c := range pw.cols {
+		t.push()
+		t.i32(1, c.typ)
+		t.i32(3, 0) // REQUIRED
+		t.binary(4, c.name)
+		if c.utf8 {
+			t.i32(6, 0) // UTF8
+		}
+		t.end()
+	}
+	t.i64(3, pw.total)
+
+	t.list(4, thriftStruct, len(pw.groups))
+	for _, rg := range pw.groups {
+		t.push()
+		t.list(1, thriftStruct, len(rg.chunks))
+		for i, ch := range rg.chunks {
+			c := pw.cols[i]
+			start, enc := ch.dataOff, int32(parquetPlain)
+			if c.dict {
+				start, enc = ch.dictOff, parquetPlainDictionary
+			}
+
+			t.push()
+			t.i64(2, start)
+			t.begin(3)
+			t.i32(1, c.typ)
+			t.list(2, thriftI32, 2)
+			t.zigzag(int64(enc))
+			t.zigzag(parquetRLE)
+			t.list(3, thriftBinary, 1)
+			t.str(c.name)
+			t.i32(4, 0) // UNCOMPRESSED
+			t.i64(5, rg.rows)
+			t.i64(6, ch.size)
+			t.i64(7, ch.size)
+			t.i64(9, ch.dataOff)
+			if c.dict {
+				t.i64(11, ch.dictOff)
+			}
+			t.end()
+			t.end()
+		}
+		t.i64(2, rg.size)
+		t.i64(3, rg.rows)
+		t.end()
+	}
+	t.binary(6, `github.com/cstockton/go-trace`)
+	t.stop()
+
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(t.b)))
+	pw.write(t.b)
+	pw.write(size[:])
+	pw.write([]byte(parquetMagic))
+	return pw.err
+}
+
+// Types of the Thrift compact protocol.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thrift encodes a struct in the Thrift compact protocol, as used by the
+// metadata of Parquet files.
+type thrift struct {
+	b     []byte
+	last  int16
+	stack []int16
+}
+
+func (t *thrift) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	t.b = append(t.b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func (t *thrift) zigzag(v int64) {
+	t.uvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+func (t *thrift) str(s string) {
+	t.uvarint(uint64(len(s)))
+	t.b = append(t.b, s...)
+}
+
+// field writes the header of the field id of the given type.
+func (t *thrift) field(id int16, typ byte) {
+	if d := id - t.last; d > 0 && d <= 15 {
+		t.b = append(t.b, byte(d)<<4|typ)
+	} else {
+		t.b = append(t.b, typ)
+		t.zigzag(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thrift) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thrift) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thrift) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.str(s)
+}
+
+// list writes the header of the list field id holding n elements of the given
+// type, which are written next. Struct elements are written between push and
+// end.
+func (t *thrift) list(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.b = append(t.b, byte(n)<<4|elem)
+		return
+	}
+	t.b = append(t.b, 0xf0|elem)
+	t.uvarint(uint64(n))
+}
+
+// begin writes the header of the struct field id, which ends with end.
+func (t *thrift) begin(id int16) {
+	t.field(id, thriftStruct)
+	t.push()
+}
+
+func (t *thrift) push() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thrift) end() {
+	t.stop()
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thrift) stop() {
+	t.b = append(t.b, 0)
+}
@@ -0,0 +1,192 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// readThrift decodes the Thrift compact protocol struct at the start of b into
+// a map of field ids to values, returning the bytes which follow it. Structs
+// are decoded as maps, lists as slices and integers as int64.
+func readThrift(t *testing.T, b []byte) (map[int16]interface{}, []byte) {
+	uvarint := func() uint64 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatal(`malformed varint in thrift struct`)
+		}
+		b = b[n:]
+		return v
+	}
+	zigzag := func() int64 {
+		v := uvarint()
+		return int64(v>>1) ^ -int64(v&1)
+	}
+
+	var value func(typ byte) interface{}
+	value = func(typ byte) interface{} {
+		switch typ {
+		case 1, 2:
+			return typ == 1
+		case thriftI32, thriftI64:
+			return zigzag()
+		case thriftBinary:
+			n := uvarint()
+			s := string(b[:n])
+			b = b[n:]
+			return s
+		case thriftList:
+			head := b[0]
+			b = b[1:]
+			n := int(head >> 4)
+			if n == 15 {
+				n = int(uvarint())
+			}
+			var out []interface{}
+			for i := 0; i < n; i++ {
+				out = append(out, value(head&0x0f))
+			}
+			return out
+		case thriftStruct:
+			var m map[int16]interface{}
+			m, b = readThrift(t, b)
+			return m
+		}
+		t.Fatalf(`unexpected thrift type %v`, typ)
+		return nil
+	}
+
+	m := make(map[int16]interface{})
+	var last int16
+	for {
+		head := b[0]
+		b = b[1:]
+		if head == 0 {
+			return m, b
+		}
+		id, typ := last+int16(head>>4), head&0x0f
+		if head>>4 == 0 {
+			id = int16(zigzag())
+		}
+		m[id], last = value(typ), id
+	}
+}
+
+func TestParquet(t *testing.T) {
+	defer func(n int64) { parquetGroupRows = n }(parquetGroupRows)
+	parquetGroupRows = 100
+
+	for _, tf := range traceList.ByName(`log.trace`) {
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Parquet(&buf, bytes.NewReader(tf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			b := buf.Bytes()
+			if !bytes.HasPrefix(b, []byte(parquetMagic)) || !bytes.HasSuffix(b, []byte(parquetMagic)) {
+				t.Fatal(`exp file to begin and end with magic`)
+			}
+			size := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+			meta, rest := readThrift(t, b[len(b)-8-size:len(b)-8])
+			if len(rest) != 0 {
+				t.Fatalf(`exp metadata to span footer; %v bytes remain`, len(rest))
+			}
+
+			var exp []event.Type
+			for _, typ := range decodeTypes(t, tf.Bytes()) {
+				if typ != event.EvString && typ != event.EvStack {
+					exp = append(exp, typ)
+				}
+			}
+			if got := meta[3].(int64); int64(len(exp)) != got {
+				t.Fatalf(`exp %v rows; got %v`, len(exp), got)
+			}
+			if got := len(meta[2].([]interface{})); got != 7 {
+				t.Fatalf(`exp 7 schema elements; got %v`, got)
+			}
+
+			// Decode the dictionary encoded type column of every row group.
+			var got []string
+			for _, rg := range meta[4].([]interface{}) {
+				col := rg.(map[int16]interface{})[1].([]interface{})[1]
+				md := col.(map[int16]interface{})[3].(map[int16]interface{})
+
+				hdr, data := readThrift(t, b[md[11].(int64):])
+				page := hdr[7].(map[int16]interface{})
+				var names []string
+				for i := int64(0); i < page[1].(int64); i++ {
+					n := binary.LittleEndian.Uint32(data)
+					names = append(names, string(data[4:4+n]))
+					data = data[4+n:]
+				}
+
+				hdr, data = readThrift(t, b[md[9].(int64):])
+				data = data[:hdr[2].(int64)]
+				width := int(data[0])
+				for data = data[1:]; len(data) > 0; {
+					run, n := binary.Uvarint(data)
+					if run&1 != 0 {
+						t.Fatal(`exp only RLE runs`)
+					}
+					data = data[n:]
+					var idx uint64
+					for k := 0; k < (width+7)/8; k++ {
+						idx |= uint64(data[k]) << (8 * uint(k))
+					}
+					data = data[(width+7)/8:]
+					for i := uint64(0); i < run>>1; i++ {
+						got = append(got, names[idx])
+					}
+				}
+			}
+			if len(exp) != len(got) {
+				t.Fatalf(`exp %v types; got %v`, len(exp), len(got))
+			}
+			for i := range exp {
+				if exp[i].Name() != got[i] {
+					t.Fatalf(`exp row %v to have type %v; got %v`, i, exp[i].Name(), got[i])
+				}
+			}
+		})
+	}
+	t.Run(`Errors`, func(t *testing.T) {
+		data := traceList.ByName(`log.trace`)[0].Bytes()
+		var buf bytes.Buffer
+		if err := Parquet(&buf, bytes.NewReader(data[:8])); err == nil {
+			t.Fatal(`exp non-nil err for truncated trace`)
+		}
+	})
+}
+
+func TestThrift(t *testing.T) {
+	var w thrift
+	w.i32(1, -3)
+	w.i64(20, 1<<40)
+	w.list(21, thriftBinary, 16)
+	for i := 0; i < 16; i++ {
+		w.str(`s`)
+	}
+	w.begin(22)
+	w.binary(1, `name`)
+	w.end()
+	w.stop()
+
+	m, rest := readThrift(t, w.b)
+	if len(rest) != 0 {
+		t.Fatalf(`exp struct to span buffer; %v bytes remain`, len(rest))
+	}
+	if got := m[1].(int64); got != -3 {
+		t.Fatalf(`exp field 1 to be -3; got %v`, got)
+	}
+	if got := m[20].(int64); got != 1<<40 {
+		t.Fatalf(`exp field 20 to be %v; got %v`, int64(1<<40), got)
+	}
+	if got := len(m[21].([]interface{})); got != 16 {
+		t.Fatalf(`exp 16 list elements; got %v`, got)
+	}
+	if got := m[22].(map[int16]interface{})[1]; got != `name` {
+		t.Fatalf(`exp nested field to be "name"; got %v`, got)
+	}
+}
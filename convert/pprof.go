@@ -0,0 +1,294 @@
+package convert
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// BlockProfile reads a trace from r and writes the time goroutines spent
+// blocked to w as a gzipped profile.proto, which may be viewed with go tool
+// pprof. Each sample is a stack goroutines blocked with, labeled with the type
+// of the blocking event and holding the number of times they blocked and the
+// cumulative delay in nanoseconds.
+func BlockProfile(w io.Writer, r io.Reader) error {
+	return writeProfile(w, r, (*event.Trace).BlockProfile)
+}
+
+// SyscallProfile reads a trace from r and writes the time goroutines spent
+// blocked in system calls to w as a gzipped profile.proto, in the form of the
+// samples written by BlockProfile. Each sample is a stack goroutines entered
+// a blocking system call from.
+func SyscallProfile(w io.Writer, r io.Reader) error {
+	return writeProfile(w, r, (*event.Trace).SyscallProfile)
+}
+
+// SchedProfile reads a trace from r and writes the scheduler latency of
+// goroutines to w as a gzipped profile.proto, in the form of the samples
+// written by BlockProfile. Each sample is a stack goroutines were created or
+// unblocked from, holding the delay until they began running.
+func SchedProfile(w io.Writer, r io.Reader) error {
+	return writeProfile(w, r, (*event.Trace).SchedProfile)
+}
+
+// writeProfile reads a trace from r and writes the profile returned by fn for
+// its ordered events to w.
+func writeProfile(w io.Writer, r io.Reader,
+	fn func(tr *event.Trace, evts []*event.Event) *event.BlockProfile) error {
+	tr, evts, err := readOrdered(r)
+	if err != nil {
+		return err
+	}
+	if tr.Frequency == 0 {
+		return errors.New(`convert: trace contains no frequency event`)
+	}
+	p := fn(tr, evts)
+
+	pb := newProfileBuilder(tr)
+	pb.sampleType(`contentions`, `count`)
+	pb.sampleType(`delay`, `nanoseconds`)
+	for _, rec := range p.Records {
+		ns := int64(float64(rec.Time) * 1e9 / float64(tr.Frequency))
+		pb.sample(rec.StackID, []int64{int64(rec.Count), ns},
+			`type`, rec.Type.Name())
+	}
+	pb.periodType(`contentions`, `count`, 1)
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(pb.build()); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// readOrdered decodes all events from r, visiting each with a Trace and
+// returning the timestamped events in the order given by an event.Orderer.
+func readOrdered(r io.Reader) (*event.Trace, []*event.Event, error) {
+	dec := encoding.NewDecoder(r)
+	ver, err := dec.Version()
+	if err != nil {
+		return nil, nil, err
+	}
+	tr, err := event.NewTrace(ver)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	o, evt := event.NewOrderer(ver), new(event.Event)
+	for dec.More() {
+		evt.Reset()
+		if err := dec.Decode(evt); err != nil {
+			return nil, nil, err
+		}
+		if err := tr.Visit(evt); err != nil {
+			return nil, nil, err
+		}
+		o.Push(evt)
+	}
+	if err := dec.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	evts := make([]*event.Event, 0, o.Len())
+	for {
+		evt, err := o.Next()
+		if err == io.EOF {
+			return tr, evts, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		evts = append(evts, evt)
+	}
+}
+
+// Field numbers of the messages within profile.proto.
+const (
+	profileSampleType  = 1
+	profileSample      = 2
+	profileLocation    = 4
+	profileFunction    = 5
+	profileStringTable = 6
+	profilePeriodType  = 11
+	profilePeriod      = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+	sampleLabel      = 3
+
+	labelKey = 1
+	labelStr = 2
+
+	locationID      = 1
+	locationAddress = 3
+	locationLine    = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+)
+
+// profileBuilder assembles a profile.proto message, resolving the stacks of
+// samples into locations and functions from a Trace.
+type profileBuilder struct {
+	tr      *event.Trace
+	msg     protoBuf
+	strs    map[string]int64
+	strList []string
+	locs    map[uint64]uint64 // pc to location id
+	funcs   map[[2]string]uint64
+	stks    map[uint64][]uint64 // stack id to location ids
+}
+
+func newProfileBuilder(tr *event.Trace) *profileBuilder {
+	pb := &profileBuilder{
+		tr:    tr,
+		strs:  make(map[string]int64),
+		locs:  make(map[uint64]uint64),
+		funcs: make(map[[2]string]uint64),
+		stks:  make(map[uint64][]uint64),
+	}
+	pb.str(``)
+	return pb
+}
+
+// str returns the index of s in the string table.
+func (pb *profileBuilder) str(s string) int64 {
+	if idx, ok := pb.strs[s]; ok {
+		return idx
+	}
+	idx := int64(len(pb.strList))
+	pb.strs[s] = idx
+	pb.strList = append(pb.strList, s)
+	return idx
+}
+
+func (pb *profileBuilder) valueType(typ, unit string) []byte {
+	var vt protoBuf
+	vt.int(valueTypeType, pb.str(typ))
+	vt.int(valueTypeUnit, pb.str(unit))
+	return vt
+}
+
+func (pb *profileBuilder) sampleType(typ, unit string) {
+	pb.msg.bytes(profileSampleType, pb.valueType(typ, unit))
+}
+
+func (pb *profileBuilder) periodType(typ, unit string, period int64) {
+	pb.msg.bytes(profilePeriodType, pb.valueType(typ, unit))
+	pb.msg.int(profilePeriod, period)
+}
+
+func (pb *profileBuilder) sample(stk uint64, values []int64, key, val string) {
+	var s, label protoBuf
+	locs := pb.stack(stk)
+	s.packed(sampleLocationID, len(locs), func(i int) uint64 { return locs[i] })
+	s.packed(sampleValue, len(values), func(i int) uint64 { return uint64(values[i]) })
+	label.int(labelKey, pb.str(key))
+	label.int(labelStr, pb.str(val))
+	s.bytes(sampleLabel, label)
+	pb.msg.bytes(profileSample, s)
+}
+
+// stack returns the location ids of the given stack id, adding a location for
+// each frame not yet seen.
+func (pb *profileBuilder) stack(id uint64) []uint64 {
+	if locs, ok := pb.stks[id]; ok {
+		return locs
+	}
+	stk := pb.tr.Stacks[id]
+	locs := make([]uint64, 0, len(stk))
+	for _, f := range stk {
+		locs = append(locs, pb.location(f))
+	}
+	pb.stks[id] = locs
+	return locs
+}
+
+func (pb *profileBuilder) location(f event.Frame) uint64 {
+	if id, ok := pb.locs[f.PC()]; ok {
+		return id
+	}
+	id := uint64(len(pb.locs) + 1)
+	pb.locs[f.PC()] = id
+
+	var loc, line protoBuf
+	loc.int(locationID, int64(id))
+	loc.int(locationAddress, int64(f.PC()))
+	line.int(lineFunctionID, int64(pb.function(f.Func(), f.File())))
+	line.int(lineLine, int64(f.Line()))
+	loc.bytes(locationLine, line)
+	pb.msg.bytes(profileLocation, loc)
+	return id
+}
+
+func (pb *profileBuilder) function(name, file string) uint64 {
+	k := [2]string{name, file}
+	if id, ok := pb.funcs[k]; ok {
+		return id
+	}
+	id := uint64(len(pb.funcs) + 1)
+	pb.funcs[k] = id
+
+	var fn protoBuf
+	fn.int(functionID, int64(id))
+	fn.int(functionName, pb.str(name))
+	fn.int(functionSystemName, pb.str(name))
+	fn.int(functionFilename, pb.str(file))
+	pb.msg.bytes(profileFunction, fn)
+	return id
+}
+
+// build returns the encoded profile, it must be called once all samples have
+// been added.
+func (pb *profileBuilder) build() []byte {
+	msg := pb.msg
+	for _, s := range pb.strList {
+		msg.bytes(profileStringTable, []byte(s))
+	}
+	return msg
+}
+
+// protoBuf is a protocol buffer message being encoded. Only the varint and
+// length delimited wire types are needed by profile.proto.
+type protoBuf []byte
+
+func (b *protoBuf) uvarint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+func (b *protoBuf) int(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	b.uvarint(uint64(field) << 3)
+	b.uvarint(uint64(v))
+}
+
+func (b *protoBuf) bytes(field int, v []byte) {
+	b.uvarint(uint64(field)<<3 | 2)
+	b.uvarint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *protoBuf) packed(field, n int, fn func(i int) uint64) {
+	var p protoBuf
+	for i := 0; i < n; i++ {
+		p.uvarint(fn(i))
+	}
+	b.bytes(field, p)
+}
@@ -0,0 +1,103 @@
+package convert
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// readFields returns the values of each field of a profile.proto message by
+// field number, only the varint and length delimited wire types are supported.
+func readFields(t *testing.T, b []byte) map[int][][]byte {
+	fields := make(map[int][][]byte)
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatal(`bad tag in profile`)
+		}
+		b = b[n:]
+		switch tag & 7 {
+		case 0:
+			_, n = binary.Uvarint(b)
+			fields[int(tag>>3)] = append(fields[int(tag>>3)], b[:n])
+			b = b[n:]
+		case 2:
+			size, n := binary.Uvarint(b)
+			b = b[n:]
+			fields[int(tag>>3)] = append(fields[int(tag>>3)], b[:size])
+			b = b[size:]
+		default:
+			t.Fatalf(`unexpected wire type %v in profile`, tag&7)
+		}
+	}
+	return fields
+}
+
+func TestBlockProfile(t *testing.T) {
+	testProfile(t, BlockProfile, true)
+}
+
+func TestSyscallProfile(t *testing.T) {
+	testProfile(t, SyscallProfile, false)
+}
+
+func TestSchedProfile(t *testing.T) {
+	testProfile(t, SchedProfile, true)
+}
+
+// testProfile checks the profile written by fn for each trace, which must
+// contain samples when samples is true.
+func testProfile(t *testing.T, fn func(w io.Writer, r io.Reader) error, samples bool) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := fn(&buf, bytes.NewReader(tf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			gz, err := gzip.NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ioutil.ReadAll(gz)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fields := readFields(t, b)
+			strs := fields[profileStringTable]
+			if len(strs) == 0 || len(strs[0]) != 0 {
+				t.Fatal(`exp string table beginning with the empty string`)
+			}
+			has := make(map[string]bool)
+			for _, s := range strs {
+				has[string(s)] = true
+			}
+			for _, exp := range []string{`contentions`, `delay`, `nanoseconds`, `type`} {
+				if !has[exp] && (samples || exp != `type`) {
+					t.Fatalf(`exp string table to contain %q`, exp)
+				}
+			}
+			if n := len(fields[profileSampleType]); n != 2 {
+				t.Fatalf(`exp 2 sample types; got %v`, n)
+			}
+			if samples && (len(fields[profileSample]) == 0 || len(fields[profileLocation]) == 0) {
+				t.Fatal(`exp samples with locations`)
+			}
+			for _, s := range fields[profileSample] {
+				if sf := readFields(t, s); len(sf[sampleValue]) != 1 {
+					t.Fatalf(`exp packed sample values; got %v`, sf)
+				}
+			}
+		})
+	}
+	t.Run(`Errors`, func(t *testing.T) {
+		data := traceList.ByName(`log.trace`)[0].Bytes()
+		var buf bytes.Buffer
+		if err := fn(&buf, bytes.NewReader(data[:8])); err == nil {
+			t.Fatal(`exp non-nil err for truncated trace`)
+		}
+	})
+}
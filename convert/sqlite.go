@@ -0,0 +1,162 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// sqliteSchema creates the tables written by SQLite, the indexes are created
+// after the rows are inserted by sqliteIndexes.
+const sqliteSchema = `CREATE TABLE strings (
+	id    INTEGER PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE stacks (
+	id    INTEGER PRIMARY KEY,
+	depth INTEGER NOT NULL
+);
+CREATE TABLE frames (
+	stack_id INTEGER NOT NULL REFERENCES stacks (id),
+	pos      INTEGER NOT NULL,
+	pc       INTEGER NOT NULL,
+	func_id  INTEGER REFERENCES strings (id),
+	file_id  INTEGER REFERENCES strings (id),
+	line     INTEGER,
+	PRIMARY KEY (stack_id, pos)
+);
+CREATE TABLE events (
+	id       INTEGER PRIMARY KEY,
+	off      INTEGER NOT NULL,
+	type     TEXT NOT NULL,
+	ts       INTEGER NOT NULL,
+	p        INTEGER NOT NULL,
+	g        INTEGER NOT NULL,
+	stack_id INTEGER REFERENCES stacks (id),
+	value    TEXT
+);
+CREATE TABLE args (
+	event_id INTEGER NOT NULL REFERENCES events (id),
+	pos      INTEGER NOT NULL,
+	name     TEXT,
+	kind     TEXT,
+	value    INTEGER NOT NULL,
+	PRIMARY KEY (event_id, pos)
+);
+`
+
+const sqliteIndexes = `CREATE INDEX events_ts ON events (ts);
+CREATE INDEX events_g ON events (g);
+CREATE INDEX events_type ON events (type);
+CREATE INDEX args_name ON args (name, value);
+`
+
+// SQLite reads a trace from r and writes a SQL script to w which creates and
+// populates normalized SQLite tables of its events, for ad-hoc analysis of
+// traces too large for in-memory tooling. The script is written as events are
+// decoded and may be loaded with the sqlite3 shell:
+//
+//	sqlite3 trace.db < trace.sql
+//
+// The tables written are:
+//
+//	strings  the id and value of each EvString event
+//	stacks   the id and depth of each EvStack event
+//	frames   the pc, func and file string ids and line of each stack frame
+//	events   every other event numbered in the order they appear, with the
+//	         offset, type, timestamp in ticks, P, G, stack id and the value
+//	         of user log events
+//	args     the position, name, kind and value of each event argument
+//
+// Events are indexed by ts, g and type and arguments by name and value. The
+// frames of Version1 traces hold only a pc, their other columns are NULL.
+func SQLite(w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("BEGIN TRANSACTION;\n")
+	bw.WriteString(sqliteSchema)
+
+	var id int
+	err := streamEvents(r, func(tr *event.Trace, argoff int, evt *event.Event) error {
+		switch evt.Type {
+		case event.EvString:
+			if len(evt.Args) > 0 {
+				fmt.Fprintf(bw, "INSERT INTO strings VALUES (%v, %v);\n",
+					evt.Args[0], sqlText(evt.Data))
+			}
+			return nil
+		case event.EvStack:
+			return writeSQLStack(bw, evt, argoff == 0)
+		}
+
+		id++
+		stk := `NULL`
+		names, kinds := evt.Type.Args(), evt.Type.ArgKinds()
+		for i := argoff; i < len(evt.Args); i++ {
+			name, kind, pos := `NULL`, `NULL`, i-argoff
+			if pos < len(names) {
+				name, kind = sqlText([]byte(names[pos])), sqlText([]byte(kinds[pos].String()))
+				if names[pos] == event.ArgStackID && evt.Args[i] != 0 {
+					stk = strconv.FormatUint(evt.Args[i], 10)
+				}
+			}
+			fmt.Fprintf(bw, "INSERT INTO args VALUES (%v, %v, %v, %v, %v);\n",
+				id, pos, name, kind, evt.Args[i])
+		}
+
+		val := `NULL`
+		if evt.Type == event.EvUserLog {
+			val = sqlText(evt.Data)
+		}
+		_, err := fmt.Fprintf(bw, "INSERT INTO events VALUES (%v, %v, %v, %v, %v, %v, %v, %v);\n",
+			id, evt.Off, sqlText([]byte(evt.Type.Name())), evt.Ts, evt.P, evt.G, stk, val)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	bw.WriteString(sqliteIndexes)
+	bw.WriteString("COMMIT;\n")
+	return bw.Flush()
+}
+
+// writeSQLStack writes the rows of the stack evt, which has frames of a pc, func
+// and file string ids and line when full is true or of only a pc otherwise.
+func writeSQLStack(w io.Writer, evt *event.Event, full bool) error {
+	frameSize := 1
+	if full {
+		frameSize = 4
+	}
+	if len(evt.Args) < 2 || len(evt.Args)-2 != int(evt.Args[1])*frameSize {
+		return fmt.Errorf(`stack %v has malformed frames`, evt.Args[0])
+	}
+
+	id := evt.Args[0]
+	fmt.Fprintf(w, "INSERT INTO stacks VALUES (%v, %v);\n", id, evt.Args[1])
+	for pos, i := 0, 2; i < len(evt.Args); pos, i = pos+1, i+frameSize {
+		if !full {
+			fmt.Fprintf(w, "INSERT INTO frames VALUES (%v, %v, %v, NULL, NULL, NULL);\n",
+				id, pos, evt.Args[i])
+			continue
+		}
+		fmt.Fprintf(w, "INSERT INTO frames VALUES (%v, %v, %v, %v, %v, %v);\n",
+			id, pos, evt.Args[i], evt.Args[i+1], evt.Args[i+2], evt.Args[i+3])
+	}
+	return nil
+}
+
+// sqlText returns b as a SQL string literal, or as a blob literal cast to text
+// when it is not valid UTF-8 or contains a NUL byte the sqlite3 shell would
+// truncate it at.
+func sqlText(b []byte) string {
+	if !utf8.Valid(b) || strings.IndexByte(string(b), 0) >= 0 {
+		return `CAST(X'` + hex.EncodeToString(b) + `' AS TEXT)`
+	}
+	return `'` + strings.Replace(string(b), `'`, `''`, -1) + `'`
+}
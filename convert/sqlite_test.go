@@ -0,0 +1,89 @@
+package convert
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+func TestSQLite(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := SQLite(&buf, bytes.NewReader(tf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			out := buf.String()
+			if !strings.HasPrefix(out, "BEGIN TRANSACTION;\n") || !strings.HasSuffix(out, "COMMIT;\n") {
+				t.Fatal(`exp script to be within a transaction`)
+			}
+
+			var evts, strs, stks int
+			for _, typ := range decodeTypes(t, tf.Bytes()) {
+				switch typ {
+				case event.EvString:
+					strs++
+				case event.EvStack:
+					stks++
+				default:
+					evts++
+				}
+			}
+			tables := map[string]int{`events`: evts, `strings`: strs, `stacks`: stks}
+			for table, exp := range tables {
+				if got := strings.Count(out, "INSERT INTO "+table+" VALUES"); exp != got {
+					t.Fatalf(`exp %v rows in %v; got %v`, exp, table, got)
+				}
+			}
+
+			// Load the script when the sqlite3 shell is available.
+			path, err := exec.LookPath(`sqlite3`)
+			if err != nil {
+				return
+			}
+			db := filepath.Join(t.TempDir(), `trace.db`)
+			cmd := exec.Command(path, db)
+			cmd.Stdin = &buf
+			if res, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf(`sqlite3: %v: %s`, err, res)
+			}
+			res, err := exec.Command(path, db,
+				`SELECT count(*) FROM events WHERE type = 'GoCreate';`).CombinedOutput()
+			if err != nil {
+				t.Fatalf(`sqlite3: %v: %s`, err, res)
+			}
+			if strings.TrimSpace(string(res)) == `0` {
+				t.Fatal(`exp GoCreate events to be queryable`)
+			}
+		})
+	}
+	t.Run(`Errors`, func(t *testing.T) {
+		data := traceList.ByName(`log.trace`)[0].Bytes()
+		var buf bytes.Buffer
+		if err := SQLite(&buf, bytes.NewReader(data[:8])); err == nil {
+			t.Fatal(`exp non-nil err for truncated trace`)
+		}
+	})
+}
+
+func TestSQLText(t *testing.T) {
+	tests := []struct {
+		from string
+		exp  string
+	}{
+		{``, `''`},
+		{`main.main`, `'main.main'`},
+		{`it's`, `'it''s'`},
+		{"a\x00b", `CAST(X'610062' AS TEXT)`},
+		{"\xff", `CAST(X'ff' AS TEXT)`},
+	}
+	for _, test := range tests {
+		if got := sqlText([]byte(test.from)); test.exp != got {
+			t.Fatalf(`exp sqlText(%q) to be %v; got %v`, test.from, test.exp, got)
+		}
+	}
+}
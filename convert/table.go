@@ -0,0 +1,169 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// tableColumns are the fixed leading columns written by CSV.
+var tableColumns = []string{`off`, `type`, `ts`, `p`, `g`}
+
+// argColumns are the names of every argument of every event type in the order
+// they first appear, so each argument has a single column across event types.
+// stringColumns are the subset naming string ids.
+var argColumns, stringColumns = func() (args, strs []string) {
+	seen := make(map[string]bool)
+	for typ := event.EvNone; typ < event.EvCount; typ++ {
+		kinds := typ.ArgKinds()
+		for i, name := range typ.Args() {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			args = append(args, name)
+			if i < len(kinds) && kinds[i] == event.KindString && typ != event.EvString {
+				strs = append(strs, name)
+			}
+		}
+	}
+	return
+}()
+
+// CSV reads a trace from r and writes one row per event to w as it is decoded,
+// for loading a trace into tools such as pandas, DuckDB or a spreadsheet. The
+// header row names the offset, type, timestamp in ticks, P and G of each event
+// followed by a column for each argument name of every event type, which is
+// empty for events without that argument. Arguments beyond their names, such
+// as the frames of a stack, are joined by spaces in an "extra" column.
+//
+// The strings an event refers to are written to a column for each string id
+// argument with the "ID" suffix removed, such as "LabelString", and the payload
+// of string and user log events to the "Value" column. Strings are resolved
+// from the string events decoded before the event referring to them, which
+// the runtime writes ahead of their first use.
+func CSV(w io.Writer, r io.Reader) error {
+	cw := csv.NewWriter(w)
+	header := append(append([]string(nil), tableColumns...), argColumns...)
+	header = append(header, `extra`)
+	for _, name := range stringColumns {
+		header = append(header, strings.TrimSuffix(name, `ID`))
+	}
+	header = append(header, `Value`)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	pos := make(map[string]int, len(argColumns))
+	for i, name := range argColumns {
+		pos[name] = len(tableColumns) + i
+	}
+	extra := len(tableColumns) + len(argColumns)
+	for i, name := range stringColumns {
+		pos[name+`.str`] = extra + 1 + i
+	}
+
+	row := make([]string, len(header))
+	err := streamEvents(r, func(tr *event.Trace, argoff int, evt *event.Event) error {
+		for i := range row {
+			row[i] = ``
+		}
+		row[0] = strconv.Itoa(evt.Off)
+		row[1] = evt.Type.Name()
+		row[2] = strconv.FormatInt(evt.Ts, 10)
+		row[3] = strconv.FormatInt(evt.P, 10)
+		row[4] = strconv.FormatInt(evt.G, 10)
+
+		names := evt.Type.Args()
+		args := evt.Args
+		if argoff <= len(args) {
+			args = args[argoff:]
+		}
+		for i, arg := range args {
+			if i >= len(names) {
+				vals := make([]string, len(args)-i)
+				for j, v := range args[i:] {
+					vals[j] = strconv.FormatUint(v, 10)
+				}
+				row[extra] = strings.Join(vals, ` `)
+				break
+			}
+			row[pos[names[i]]] = strconv.FormatUint(arg, 10)
+		}
+
+		re := tr.Resolve(evt)
+		for name, s := range re.Strings {
+			if i, ok := pos[name+`.str`]; ok {
+				row[i] = s
+			}
+		}
+		row[len(row)-1] = re.Value
+		return cw.Write(row)
+	})
+	cw.Flush()
+	if err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// JSONLines reads a trace from r and writes one JSON object per line to w for
+// each event as it is decoded, in the representation given by the MarshalEvent
+// method of event.Trace. Strings are resolved as they are for CSV, stacks are
+// declared at the end of a trace so are not included.
+func JSONLines(w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriter(w)
+	err := streamEvents(r, func(tr *event.Trace, argoff int, evt *event.Event) error {
+		b, err := tr.MarshalEvent(evt)
+		if err != nil {
+			return err
+		}
+		bw.Write(b)
+		return bw.WriteByte('\n')
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// streamEvents decodes each event from r in the order they appear, visiting
+// them with a Trace before calling fn with the argument offset of the trace
+// version. The event given to fn is reused.
+func streamEvents(r io.Reader, fn func(tr *event.Trace, argoff int, evt *event.Event) error) error {
+	dec := encoding.NewDecoder(r)
+	ver, err := dec.Version()
+	if err != nil {
+		return err
+	}
+	tr, err := event.NewTrace(ver)
+	if err != nil {
+		return err
+	}
+
+	// Version1 events are prefixed with a sequence before the timestamp.
+	var argoff int
+	if ver == event.Version1 {
+		argoff = 1
+	}
+
+	var evt event.Event
+	for dec.More() {
+		evt.Reset()
+		if err := dec.Decode(&evt); err != nil {
+			return err
+		}
+		if err := tr.Visit(&evt); err != nil {
+			return err
+		}
+		if err := fn(tr, argoff, &evt); err != nil {
+			return err
+		}
+	}
+	return dec.Err()
+}
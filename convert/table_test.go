@@ -0,0 +1,120 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+func decodeTypes(t *testing.T, data []byte) []event.Type {
+	var types []event.Type
+	dec := encoding.NewDecoder(bytes.NewReader(data))
+	err := dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+		types = append(types, evt.Type)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types
+}
+
+func TestCSV(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := CSV(&buf, bytes.NewReader(tf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			rows, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			types := decodeTypes(t, tf.Bytes())
+			if exp, got := len(types)+1, len(rows); exp != got {
+				t.Fatalf(`exp %v rows; got %v`, exp, got)
+			}
+			col := make(map[string]int)
+			for i, name := range rows[0] {
+				col[name] = i
+			}
+			for _, name := range []string{`type`, `ts`, event.ArgStackID, `extra`, `LabelString`, `Value`} {
+				if _, ok := col[name]; !ok {
+					t.Fatalf(`exp header to contain %q; got %v`, name, rows[0])
+				}
+			}
+
+			var strs, values int
+			for i, row := range rows[1:] {
+				if exp, got := types[i].Name(), row[col[`type`]]; exp != got {
+					t.Fatalf(`exp row %v to have type %v; got %v`, i, exp, got)
+				}
+				if types[i] == event.EvString {
+					strs++
+					if row[col[`Value`]] != `` {
+						values++
+					}
+				}
+				if types[i] == event.EvStack && row[col[`extra`]] == `` {
+					t.Fatalf(`exp frames of stack in row %v`, i)
+				}
+			}
+			if values != strs {
+				t.Fatalf(`exp %v string events to have values; got %v`, strs, values)
+			}
+		})
+	}
+	t.Run(`Errors`, func(t *testing.T) {
+		data := traceList.ByName(`log.trace`)[0].Bytes()
+		var buf bytes.Buffer
+		if err := CSV(&buf, bytes.NewReader(data[:8])); err == nil {
+			t.Fatal(`exp non-nil err for truncated trace`)
+		}
+	})
+}
+
+func TestJSONLines(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := JSONLines(&buf, bytes.NewReader(tf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+
+			types := decodeTypes(t, tf.Bytes())
+			sc := bufio.NewScanner(&buf)
+			sc.Buffer(nil, 1<<20)
+			var n int
+			for ; sc.Scan(); n++ {
+				var evt event.Event
+				if err := evt.UnmarshalJSON(sc.Bytes()); err != nil {
+					t.Fatalf(`line %v: %v`, n, err)
+				}
+				if n >= len(types) {
+					continue
+				}
+				if exp, got := types[n], evt.Type; exp != got {
+					t.Fatalf(`exp line %v to have type %v; got %v`, n, exp, got)
+				}
+			}
+			if err := sc.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if exp, got := len(types), n; exp != got {
+				t.Fatalf(`exp %v lines; got %v`, exp, got)
+			}
+		})
+	}
+	t.Run(`Errors`, func(t *testing.T) {
+		data := traceList.ByName(`log.trace`)[0].Bytes()
+		var buf bytes.Buffer
+		if err := JSONLines(&buf, bytes.NewReader(data[:8])); err == nil {
+			t.Fatal(`exp non-nil err for truncated trace`)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package encoding
+
+import (
+	"github.com/cstockton/go-trace/event"
+)
+
+// AppendEvent appends the encoding of evt in the latest version of the Go trace
+// format to dst and returns the extended buffer, allowing events to be batched
+// into memory owned by the caller without allocating. The trace header is not
+// written, see Encoder. When an error is returned dst is returned unmodified.
+func AppendEvent(dst []byte, evt *event.Event) ([]byte, error) {
+	return evt.AppendBinary(dst)
+}
+
+// EncodedSize returns the number of bytes AppendEvent or an Encoder targeting
+// the latest version would write for evt, or -1 if evt can not be encoded. It
+// allows writers with a fixed capacity to check that an event fits before it is
+// written.
+func EncodedSize(evt *event.Event) int {
+	if !evt.Type.Valid() || len(evt.Args) == 0 {
+		return -1
+	}
+
+	switch {
+	case evt.Type == event.EvString:
+		return 1 + ulebSize(evt.Args[0]) + dataSize(evt)
+	case evt.Type == event.EvUserLog:
+		return sizedSize(evt) + dataSize(evt)
+	case len(evt.Args) < 4:
+		return 1 + argsSize(evt)
+	default:
+		return sizedSize(evt)
+	}
+}
+
+// ulebSize returns the number of bytes used to encode v as a uleb128 value.
+func ulebSize(v uint64) int {
+	n := 1
+	for ; v >= 0x80; v >>= 7 {
+		n++
+	}
+	return n
+}
+
+func argsSize(evt *event.Event) (n int) {
+	for _, arg := range evt.Args {
+		n += ulebSize(arg)
+	}
+	return n
+}
+
+func sizedSize(evt *event.Event) int {
+	n := argsSize(evt)
+	return 1 + ulebSize(uint64(n)) + n
+}
+
+func dataSize(evt *event.Event) int {
+	return ulebSize(uint64(len(evt.Data))) + len(evt.Data)
+}
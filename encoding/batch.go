@@ -0,0 +1,90 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// BeginBatch emits an EvBatch event for the P with the given id and begins
+// grouping the events which follow it into the batch. The runtime uses a P id
+// of -1 for events that are not associated with any P.
+//
+// Until EndBatch is called or another batch begins, the timestamp argument of
+// each event given to Emit is replaced with the number of ticks from the prior
+// event of the batch, computed from the absolute tick count in the Ts field of
+// the event as populated by the Decoder. The first event is relative to ts, the
+// base timestamp of the batch. The events given to Emit are not modified.
+func (e *Encoder) BeginBatch(p int, ts int64) error {
+	if ts < 0 {
+		return fmt.Errorf(`batch timestamp %v may not be negative`, ts)
+	}
+	e.lock()
+	defer e.unlock()
+	if e.order != nil {
+		return nil
+	}
+	_, err := e.emit(&event.Event{
+		Type: event.EvBatch, Args: []uint64{uint64(int64(p)), uint64(ts)}})
+	if err != nil {
+		return err
+	}
+	e.batch.open, e.batch.p, e.batch.ts = true, int64(p), ts
+	return nil
+}
+
+// EndBatch ends the batch started by BeginBatch, the timestamp arguments of
+// events given to Emit are written as given until the next batch begins.
+func (e *Encoder) EndBatch() error {
+	e.lock()
+	defer e.unlock()
+	if e.order != nil {
+		return nil
+	}
+	if !e.batch.open {
+		return errors.New(`EndBatch called without a batch in progress`)
+	}
+	e.batch.open = false
+	return nil
+}
+
+// batchState tracks the batch in progress for an Encoder.
+type batchState struct {
+	open bool
+	p    int64
+	ts   int64
+	evt  event.Event
+}
+
+// visit returns evt with a timestamp relative to the prior event of the batch in
+// progress, if any.
+func (b *batchState) visit(evt *event.Event) (*event.Event, error) {
+	if evt.Type == event.EvBatch {
+		// A batch emitted directly ends the batch in progress.
+		b.open = false
+		return evt, nil
+	}
+	if !b.open {
+		return evt, nil
+	}
+	if names := evt.Type.Args(); len(names) == 0 || names[0] != event.ArgTimestamp {
+		return evt, nil
+	}
+	if len(evt.Args) == 0 {
+		return nil, fmt.Errorf(`expected a timestamp argument for event %v`, evt.Type)
+	}
+	if evt.Ts < b.ts {
+		return nil, fmt.Errorf(
+			`event %v at tick %v precedes the prior event of its batch at tick %v`,
+			evt.Type, evt.Ts, b.ts)
+	}
+
+	out := &b.evt
+	args := append(out.Args[:0], evt.Args...)
+	*out = *evt
+	out.Args = args
+	out.Args[0] = uint64(evt.Ts - b.ts)
+	b.ts = evt.Ts
+	return out, nil
+}
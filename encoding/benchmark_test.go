@@ -9,12 +9,12 @@ import (
 )
 
 func BenchmarkDecoding(b *testing.B) {
-	tfs := traceList.ByVersion(event.Latest).ByName(`log.trace`)
+	tfs := traceList.ByVersion(event.Version4).ByName(`log.trace`)
 	if len(tfs) != 1 {
 		b.Fatal(`couldn't find log.trace in traceList`)
 	}
 	data := tfs[0].Bytes()
-	expCount := 331
+	expCount := 354
 
 	r := bytes.NewReader(data)
 	buf := bufio.NewReaderSize(r, len(data))
@@ -0,0 +1,72 @@
+package encoding
+
+import "github.com/cstockton/go-trace/event"
+
+// Carry moves the timestamp deltas of events dropped from a trace to the next
+// event retained from the same batch, so the timestamps of retained events are
+// unchanged. Within a batch the timestamp argument of each event is the number
+// of ticks since the prior event of the batch, see Batch. Every event must be
+// given to either Drop or Keep in the order it was decoded.
+//
+// The zero value is ready to use for events in the layout of Version2 and
+// later, see NewCarry for events decoded from Version1 traces.
+type Carry struct {
+	argoff  int
+	seq, ts uint64
+}
+
+// NewCarry returns a Carry for events in the layout of version v. The events of
+// Version1 are preceded by a sequence delta which is carried along with the
+// timestamp delta.
+func NewCarry(v event.Version) *Carry {
+	c := new(Carry)
+	if v == event.Version1 {
+		c.argoff = 1
+	}
+	return c
+}
+
+// Drop records the deltas of evt, which will not be retained.
+func (c *Carry) Drop(evt *event.Event) {
+	if evt.Type == event.EvBatch {
+		c.seq, c.ts = 0, 0
+		return
+	}
+	if !c.timed(evt) {
+		return
+	}
+	if c.argoff > 0 {
+		c.seq += evt.Args[0]
+	}
+	c.ts += evt.Args[c.argoff]
+}
+
+// Keep adds the deltas of the events dropped since the prior retained event of
+// the batch to the arguments of evt. An EvBatch event begins a new batch and
+// discards any deltas carried from the prior one.
+func (c *Carry) Keep(evt *event.Event) {
+	if evt.Type == event.EvBatch {
+		c.seq, c.ts = 0, 0
+		return
+	}
+	if !c.timed(evt) {
+		return
+	}
+	if c.argoff > 0 {
+		evt.Args[0] += c.seq
+	}
+	evt.Args[c.argoff] += c.ts
+	c.seq, c.ts = 0, 0
+}
+
+// Pending reports if deltas have been dropped since the prior retained event.
+func (c *Carry) Pending() bool {
+	return c.seq != 0 || c.ts != 0
+}
+
+// timed reports if evt carries a timestamp delta.
+func (c *Carry) timed(evt *event.Event) bool {
+	names := evt.Type.Args()
+	return len(names) > 0 && names[0] == event.ArgTimestamp &&
+		c.argoff < len(evt.Args)
+}
@@ -0,0 +1,123 @@
+package encoding
+
+import (
+	"fmt"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// Clock shifts and scales the timestamps of events, see the ShiftTimestamps
+// and ScaleTimestamps options. The base timestamp of each EvBatch and the real
+// timestamp of syscall exits are rebased, while the absolute tick count of the
+// batch in progress is tracked so the timestamp deltas of its events are
+// computed from their scaled absolute position, preventing rounding errors from
+// accumulating across the batch. Every event must be given to Rebase in the
+// order it was decoded, in the layout of Version2 and later.
+type Clock struct {
+	shift     int64
+	from, to  uint64
+	cur, last uint64
+}
+
+// NewClock returns a Clock adding shift ticks to the absolute timestamps of
+// events after rescaling them from a frequency of from ticks per second to a
+// frequency of to, so shift is in the ticks of the new frequency. The argument
+// of EvFrequency events is replaced with to. Scaling is disabled when either
+// frequency is zero.
+func NewClock(shift int64, from, to uint64) *Clock {
+	c := &Clock{shift: shift}
+	if from != 0 && to != 0 {
+		c.from, c.to = from, to
+	}
+	return c
+}
+
+// Rebase rewrites the timestamp arguments of evt in place. The Ts field of evt
+// is not changed.
+func (c *Clock) Rebase(evt *event.Event) error {
+	var err error
+	switch evt.Type {
+	case event.EvBatch:
+		if len(evt.Args) != 2 {
+			return fmt.Errorf(`expected 2 arguments for event %v`, evt.Type)
+		}
+		c.cur = evt.Args[1]
+		if c.last, err = c.rebase(c.cur); err != nil {
+			return err
+		}
+		evt.Args[1] = c.last
+		return nil
+	case event.EvFrequency:
+		if c.to != 0 && len(evt.Args) > 0 {
+			evt.Args[0] = c.to
+		}
+		return nil
+	}
+
+	for i, name := range evt.Type.Args() {
+		if i >= len(evt.Args) {
+			break
+		}
+		switch name {
+		case event.ArgTimestamp:
+			if i != 0 {
+				continue
+			}
+			c.cur += evt.Args[i]
+			ts, err := c.rebase(c.cur)
+			if err != nil {
+				return err
+			}
+			evt.Args[i], c.last = ts-c.last, ts
+		case event.ArgRealTimestamp:
+			// A zero value indicates the timestamp was not recorded.
+			if evt.Args[i] == 0 {
+				continue
+			}
+			if evt.Args[i], err = c.rebase(evt.Args[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rebase returns the absolute tick count ts scaled and shifted.
+func (c *Clock) rebase(ts uint64) (uint64, error) {
+	if c.from != 0 {
+		// Split the multiplication to avoid overflow for large tick counts.
+		q, r := ts/c.from, ts%c.from
+		ts = q*c.to + r*c.to/c.from
+	}
+	out := int64(ts) + c.shift
+	if out < 0 {
+		return 0, fmt.Errorf(`shifted timestamp %v may not be negative`, out)
+	}
+	return uint64(out), nil
+}
+
+// clock rewrites the timestamps of copies of the events emitted by an Encoder,
+// so the events given to Emit are not modified.
+type clock struct {
+	Clock
+	evt event.Event
+}
+
+func newClock(o *options) *clock {
+	if o == nil || (o.shift == 0 && (o.from == 0 || o.to == 0)) {
+		return nil
+	}
+	return &clock{Clock: *NewClock(o.shift, o.from, o.to)}
+}
+
+// visit returns a copy of evt with its timestamps rewritten.
+func (c *clock) visit(evt *event.Event) (*event.Event, error) {
+	out := &c.evt
+	args := append(out.Args[:0], evt.Args...)
+	*out = *evt
+	out.Args = args
+	if err := c.Rebase(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
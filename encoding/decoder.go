@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 
 	"github.com/cstockton/go-trace/event"
 )
@@ -29,9 +31,11 @@ type Decoder struct {
 
 // NewDecoder returns a new decoder that reads from r. If the given r is a
 // bufio.Reader then the decoder will use it for buffering, otherwise creating
-// a new bufio.Reader.
-func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{state: newState(r)}
+// a new bufio.Reader. Options are retained across calls to Reset.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	s := newState(r)
+	s.opts = newOptions(opts)
+	return &Decoder{state: s}
 }
 
 // Reset the Decoder to read from r, if r is a bufio.Reader it will use it for
@@ -72,6 +76,19 @@ func (d *Decoder) Version() (event.Version, error) {
 // first time More returns false, all future calls will return false until Reset
 // is called.
 func (d *Decoder) More() bool {
+	opts := d.state.opts
+	if d.state.ver == 0 && d.err == nil && (opts.sample > 0 || opts.stacks > 0) {
+		d.init()
+	}
+	if d.state.sampler != nil {
+		return d.ahead()
+	}
+	return d.input()
+}
+
+// input reports if bytes remain in the input stream, halting with the error
+// which ended it otherwise.
+func (d *Decoder) input() bool {
 	if d.err != nil {
 		return false
 	}
@@ -81,6 +98,10 @@ func (d *Decoder) More() bool {
 			return false
 		}
 	}
+	if d.state.footer && !d.state.more() {
+		d.halt(d.state.trailing(d.state.off))
+		return false
+	}
 	return true
 }
 
@@ -113,19 +134,186 @@ func (d *Decoder) Decode(evt *event.Event) error {
 	if d.state.ver == 0 {
 		d.init()
 	}
+	if s := d.state.sampler; s != nil {
+		// Sampled events are decoded ahead, the event given is swapped with
+		// the next one so its backing slices are reused.
+		if !d.ahead() {
+			return d.err
+		}
+		*evt, s.next = s.next, *evt
+		s.ok = false
+		return nil
+	}
 	if d.err != nil {
 		// Once an error occurs the decoder may no longer be used.
 		return d.err
 	}
+	return d.decode(evt)
+}
+
+// decode the next event from the input stream into evt, see Decode.
+func (d *Decoder) decode(evt *event.Event) error {
+	if d.state.footer && !d.state.more() {
+		return d.halt(d.state.trailing(d.state.off))
+	}
+
+	start := d.state.off
 	if err := decodeEvent(d.state, evt); err != nil {
+		// A stream broken mid-event is not assumed to be trailing bytes.
+		if d.state.footer && err != io.EOF && err != io.ErrUnexpectedEOF {
+			evt.Reset()
+			return d.halt(d.state.trailing(start))
+		}
 		return d.halt(err)
 	}
+	if d.state.opts.strict {
+		if d.state.strict == nil {
+			d.state.strict = newValidator(d.state.ver, d.state.argoff, true)
+		}
+		if err := d.state.strict.validate(evt); err != nil {
+			return d.halt(err)
+		}
+	}
+	d.state.visit(evt)
 	return nil
 }
 
+// VisitAll decodes all remaining events from the input stream, calling v.Visit
+// for each of them. The event given to Visit is reused across calls and must
+// be copied to be retained. The first error returned from Visit is returned
+// immediately, leaving the Decoder positioned at the next event. Otherwise the
+// value of Err is returned once decoding stops.
+func (d *Decoder) VisitAll(v event.Visitor) error {
+	var evt event.Event
+	for d.More() {
+		evt.Reset()
+		if err := d.Decode(&evt); err != nil {
+			break
+		}
+		if err := v.Visit(&evt); err != nil {
+			return err
+		}
+	}
+	return d.Err()
+}
+
+// TrailingBytes returns the number of bytes that followed the final event of the
+// trace, such as when a trace is concatenated with other output. The trailing
+// bytes are consumed without being decoded, they are not an error so Err
+// returns nil and Stopped true once they have been reached.
+func (d *Decoder) TrailingBytes() int {
+	return d.state.trail
+}
+
+// Clone returns a new Decoder positioned at the next event of this Decoder. The
+// clone may be used from another goroutine to scan the remainder of the trace
+// independently, such as building the string and stack tables while another
+// goroutine filters events.
+//
+// Clone requires the input stream to implement io.ReaderAt, which must be safe
+// for concurrent use as is the case for *os.File and *bytes.Reader. Options
+// are copied to the clone, but any spill writer given to LazyStrings is shared
+// and written to by one of them at a time, so the spans of both refer to it.
+func (d *Decoder) Clone() (*Decoder, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	ra, ok := d.state.src.(io.ReaderAt)
+	if !ok {
+		return nil, errors.New(`Clone requires an input stream implementing io.ReaderAt`)
+	}
+
+	off := d.state.base + int64(d.state.off)
+	s, opts := *d.state, *d.state.opts
+	s.Reader = bufio.NewReader(io.NewSectionReader(ra, off, math.MaxInt64-off))
+	s.opts = &opts
+	if s.strict != nil {
+		s.strict = s.strict.clone()
+	}
+	if s.sampler != nil {
+		s.sampler = s.sampler.clone()
+	}
+	if s.procs != nil {
+		procs := make(map[int64]*proc, len(s.procs))
+		for id, p := range s.procs {
+			cp := *p
+			procs[id] = &cp
+		}
+		s.procs, s.cur = procs, procs[s.batch.P]
+	}
+	return &Decoder{state: &s}, nil
+}
+
+// NewDecoderAt returns a new Decoder reading the trace within ra from the event
+// beginning at off, which must be the offset of an EvBatch event such as those
+// recorded by an index of the trace. The trace header is read from the start of
+// ra, and the Off field of decoded events remains relative to it.
+//
+// Events before off are not decoded, so any EvString events they contain are
+// not seen by the Decoder. The ReaderAt must be safe for concurrent use when
+// the Decoder is cloned.
+func NewDecoderAt(ra io.ReaderAt, off int64, opts ...Option) (*Decoder, error) {
+	var b [16]byte
+	if _, err := ra.ReadAt(b[:], 0); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	ver, err := parseHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	if off < int64(len(b)) {
+		return nil, fmt.Errorf(`offset %v precedes the first event`, off)
+	}
+
+	src := io.NewSectionReader(ra, 0, math.MaxInt64)
+	s := newState(io.NewSectionReader(ra, off, math.MaxInt64-off))
+	s.opts, s.src, s.base = newOptions(opts), src, 0
+	s.ver, s.off = ver, int(off)
+	d := &Decoder{state: s}
+	d.setup()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if b, err := s.Peek(1); err == nil && event.Type(b[0]<<2>>2) != event.EvBatch {
+		return nil, fmt.Errorf(`offset %v is not the beginning of a batch`, off)
+	}
+	return d, nil
+}
+
+// Batch returns the per-P batch the most recently decoded event belongs to and
+// a boolean true, or the zero value and false if no EvBatch has been decoded.
+// Events are decoded ahead of those returned when configured with Sample or
+// SampleStacks, so the batch may be that of a later event.
+func (d *Decoder) Batch() (Batch, bool) {
+	return d.state.batch, d.state.batched
+}
+
+// Stopped reports whether the producer of the input stream stopped tracing
+// cleanly. It returns true only after the input has been fully consumed without
+// error and the trace footer the runtime writes from trace.Stop was decoded.
+//
+// A stream that has bytes following the trace footer is considered stopped,
+// with TrailingBytes returning their number. A stream that is broken in the
+// middle of an event results in Err returning io.ErrUnexpectedEOF. A stream that ends on an event boundary before the
+// footer was written, such as a pipe closed before trace.Stop returns, will
+// have a nil Err while Stopped returns false.
+func (d *Decoder) Stopped() bool {
+	return d.err == io.EOF && d.state.footer
+}
+
 // halt is called anytime an error occurs, setting permanent error state for
 // this Decoder.
 func (d *Decoder) halt(err error) error {
+	// Stacks are declared in a table following the trace footer, so references
+	// to them may only be checked once a trace that stopped cleanly ends.
+	if err == io.EOF && d.state.strict != nil && d.state.footer {
+		if serr := d.state.strict.unresolved(); serr != nil {
+			err = serr
+		}
+	}
 	d.err = err
 	return d.err
 }
@@ -135,32 +323,242 @@ func (d *Decoder) init() {
 		d.halt(err)
 		return
 	}
+	d.setup()
+}
 
+// setup configures the Decoder for the version of the trace header.
+func (d *Decoder) setup() {
 	// Set the argoffset for v1 only since the latest versions have no offset.
 	if d.state.ver == event.Version1 {
 		d.state.argoff = 1
 	}
+	d.state.sampler = newSampler(d.state.opts, d.state.ver)
+	if d.state.opts.nanos {
+		freq, err := d.frequency()
+		if err != nil {
+			d.halt(err)
+			return
+		}
+		d.state.freq = freq
+	}
+}
+
+// frequency scans ahead to the trace footer for the number of ticks per second,
+// leaving the position of this Decoder unchanged.
+func (d *Decoder) frequency() (uint64, error) {
+	ra, ok := d.state.src.(io.ReaderAt)
+	if !ok {
+		return 0, errors.New(
+			`Nanoseconds requires an input stream implementing io.ReaderAt`)
+	}
+
+	off := d.state.base + int64(d.state.off)
+	s := &state{
+		Reader: bufio.NewReader(io.NewSectionReader(ra, off, math.MaxInt64-off)),
+		ver:    d.state.ver,
+		argoff: d.state.argoff,
+		opts:   &options{lazy: true},
+	}
+	var evt event.Event
+	for {
+		evt.Reset()
+		if err := decodeEvent(s, &evt); err != nil {
+			if err == io.EOF {
+				err = errors.New(`trace contains no frequency event`)
+			}
+			return 0, err
+		}
+		if evt.Type == event.EvFrequency && len(evt.Args) > 0 && evt.Args[0] > 0 {
+			return evt.Args[0], nil
+		}
+	}
+}
+
+// Batch is the context of the per-P batch of events currently being decoded.
+// Events written by the runtime are grouped in batches, each beginning with an
+// EvBatch event declaring the P the events occurred on and the base timestamp
+// future timestamp deltas within the batch are relative to.
+type Batch struct {
+
+	// P is the id of the P the events in this batch were emitted from. Events
+	// that are not associated with any P will have an id of -1.
+	P int64
+
+	// Ts is the base timestamp of this batch in CPU ticks.
+	Ts int64
 }
 
 type state struct {
 	*bufio.Reader
-	ver    event.Version
-	off    int
-	argoff int
+	ver     event.Version
+	off     int
+	trail   int
+	argoff  int
+	footer  bool
+	begun   bool
+	batched bool
+	batch   Batch
+	opts    *options
+	strict  *validator
+
+	// procs holds the clock and running goroutine of each P and cur that of
+	// the P of the current batch, freq is non-zero when timestamps are
+	// converted to nanoseconds.
+	procs map[int64]*proc
+	cur   *proc
+	freq  uint64
+
+	// src is the input stream and base its position when it was given to the
+	// Decoder, used by Clone.
+	src  io.Reader
+	base int64
+
+	// sampler is non-nil for Decoders configured with the Sample or
+	// SampleStacks options.
+	sampler *sampler
+}
+
+// visit updates the state from a successfully decoded event, setting any event
+// fields derived from prior events.
+func (s *state) visit(evt *event.Event) {
+	switch evt.Type {
+	case event.EvBatch:
+		// Version1 batches include a sequence before the timestamp.
+		if n := 1 + s.argoff; n < len(evt.Args) {
+			s.batch = Batch{P: int64(evt.Args[0]), Ts: int64(evt.Args[n])}
+			s.batched = true
+		}
+	case event.EvFrequency:
+		// A frequency preceding all other events was written by an Encoder
+		// configured with the Frequency option rather than the runtime, which
+		// declares it in the trace footer following the batches of events.
+		s.footer = s.begun
+	}
+	s.begun = true
+	if s.batched {
+		evt.P = s.batch.P
+		s.clock(evt)
+	}
+}
+
+// proc is the state of a P derived from the events in its batches.
+type proc struct {
+	ts, g int64
+}
+
+// clock sets the Ts and G fields of a timestamped event from the state of the P
+// it belongs to. Timestamps within a batch are tick deltas from the prior event
+// of the same P, beginning with the base timestamp of the batch.
+func (s *state) clock(evt *event.Event) {
+	if evt.Type != event.EvBatch {
+		if names := evt.Type.Args(); len(names) == 0 || names[0] != event.ArgTimestamp {
+			return
+		}
+	}
+	if evt.Type == event.EvBatch || s.cur == nil {
+		if s.procs == nil {
+			s.procs = make(map[int64]*proc)
+		}
+		if s.cur = s.procs[s.batch.P]; s.cur == nil {
+			s.cur = new(proc)
+			s.procs[s.batch.P] = s.cur
+		}
+	}
+	p := s.cur
+
+	if evt.Type == event.EvBatch {
+		p.ts = s.batch.Ts
+	} else if s.argoff < len(evt.Args) {
+		p.ts += int64(evt.Args[s.argoff])
+	}
+	evt.Ts, evt.G = p.ts, p.g
+	if s.freq > 0 {
+		evt.Ts = int64(float64(p.ts) * 1e9 / float64(s.freq))
+	}
+
+	switch evt.Type {
+	case event.EvGoStart, event.EvGoStartLocal, event.EvGoStartLabel:
+		p.g = s.arg(evt, event.ArgGoroutineID)
+		evt.G = p.g
+	case event.EvGoSysExit, event.EvGoSysExitLocal, event.EvGoWaiting,
+		event.EvGoInSyscall:
+		evt.G = s.arg(evt, event.ArgGoroutineID)
+	case event.EvCPUSample:
+		// Samples are batched when the profiler is read, so they carry the
+		// time, P and goroutine they were taken on.
+		evt.Ts, evt.P = s.arg(evt, event.ArgRealTimestamp), s.arg(evt, event.ArgProcessorID)
+		evt.G = s.arg(evt, event.ArgGoroutineID)
+		if s.freq > 0 {
+			evt.Ts = int64(float64(evt.Ts) * 1e9 / float64(s.freq))
+		}
+	case event.EvGoEnd, event.EvGoStop, event.EvGoSched, event.EvGoPreempt,
+		event.EvGoSleep, event.EvGoBlock, event.EvGoBlockSend,
+		event.EvGoBlockRecv, event.EvGoBlockSelect, event.EvGoBlockSync,
+		event.EvGoBlockCond, event.EvGoBlockNet, event.EvGoBlockGC,
+		event.EvGoSysBlock:
+		p.g = 0
+	}
+}
+
+// arg returns the named argument of evt accounting for the argument offset of
+// the current version, or zero if it does not exist.
+func (s *state) arg(evt *event.Event, name string) int64 {
+	idx, ok := evt.Type.Arg(name)
+	if !ok || idx+s.argoff >= len(evt.Args) {
+		return 0
+	}
+	return int64(evt.Args[idx+s.argoff])
+}
+
+// more reports if the next event in the input stream may belong to the trace.
+// Only the stack table and a few other events may follow the trace footer.
+func (s *state) more() bool {
+	b, err := s.Peek(1)
+	if err != nil {
+		return true // let decoding report the error
+	}
+	switch event.Type(b[0] << 2 >> 2) {
+	case event.EvStack, event.EvString, event.EvTimerGoroutine:
+		return true
+	}
+	return false
+}
+
+// trailing consumes the remainder of the input stream, recording all bytes from
+// the given offset as trailing the trace and returning io.EOF.
+func (s *state) trailing(off int) error {
+	n, _ := io.Copy(ioutil.Discard, s.Reader)
+	s.off += int(n)
+	s.trail = s.off - off
+	return io.EOF
 }
 
 func newState(r io.Reader) *state {
-	return &state{Reader: bufio.NewReader(r)}
+	return &state{
+		Reader: bufio.NewReader(r), opts: new(options), src: r, base: seekBase(r)}
 }
 
 func (s *state) Reset(r io.Reader) {
-	buf := s.Reader
+	buf, opts := s.Reader, s.opts
 	if buf == nil {
 		buf = bufio.NewReader(r)
 	} else {
 		buf.Reset(r)
 	}
-	*s = state{Reader: buf}
+	if opts == nil {
+		opts = new(options)
+	}
+	*s = state{Reader: buf, opts: opts, src: r, base: seekBase(r)}
+}
+
+// seekBase returns the current position of r if it implements io.Seeker.
+func seekBase(r io.Reader) int64 {
+	if sk, ok := r.(io.Seeker); ok {
+		if pos, err := sk.Seek(0, io.SeekCurrent); err == nil {
+			return pos
+		}
+	}
+	return 0
 }
 
 func (s *state) Read(p []byte) (n int, err error) {
@@ -170,59 +568,122 @@ func (s *state) Read(p []byte) (n int, err error) {
 }
 
 func (s *state) ReadByte() (b byte, err error) {
-	b, err = s.Reader.ReadByte()
-	s.off++
+	if b, err = s.Reader.ReadByte(); err == nil {
+		s.off++
+	}
 	return
 }
 
 var headerLut = [9]byte{'t', 'r', 'a', 'c', 'e', 0, 0, 0, 0}
 
+// ErrUnsupportedVersion is returned when the trace header is well formed but
+// declares a version of the trace format this package does not support, such
+// as the format introduced in Go 1.22.
+var ErrUnsupportedVersion = errors.New(`trace header version is not supported`)
+
+// SniffVersion reads the trace header from r and returns the version it
+// declares along with a reader that replays the header followed by the
+// remainder of r. This allows dispatching to a processing path before creating
+// a Decoder. When the header is well formed but declares a version that is not
+// supported ErrUnsupportedVersion is returned, the returned reader is always
+// valid even when err is non-nil.
+func SniffVersion(r io.Reader) (event.Version, io.Reader, error) {
+	var b [16]byte
+	n, err := io.ReadFull(r, b[:])
+	replay := io.MultiReader(bytes.NewReader(b[:n]), r)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, replay, err
+	}
+
+	ver, err := parseHeader(b)
+	return ver, replay, err
+}
+
 // decodeHeader will read a valid trace header consisting of exactly 16 bytes
 // from r, updating state or returning an error on failure.
-func decodeHeader(s *state) error {
+func decodeHeader(s *state) (err error) {
 	var b [16]byte
-	if _, err := io.ReadFull(s, b[:]); err != nil {
+	if _, err = io.ReadFull(s, b[:]); err != nil {
 		if err == io.EOF {
 			return io.ErrUnexpectedEOF
 		}
 		return err
 	}
+	s.ver, err = parseHeader(b)
+	return
+}
 
+// parseHeader returns the version declared by the given trace header.
+func parseHeader(b [16]byte) (event.Version, error) {
 	// "go 1.8 trace\x00\x00\x00\x00"
 	//  +++|-----------------------
 	if b[0] != 'g' || b[1] != 'o' || b[2] != ' ' {
-		return errors.New(`trace header prefix was malformed`)
+		return 0, errors.New(`trace header prefix was malformed`)
 	}
 
 	// Small lookahead here for more intuitive error reporting.
 	// "go 1.8 trace\x00\x00\x00\x00"
-	//  xxx++-+|-----------------------
-	if b[3] != '1' || b[4] != '.' || b[6] != ' ' {
-		return errors.New(`trace header version was malformed`)
-	}
-
-	// "go 1.8 trace\x00\x00\x00\x00"
-	//  xxxxx+x|----------------------
-	switch b[5] {
-	case '5':
-		s.ver = event.Version1
-	case '7':
-		s.ver = event.Version2
-	case '8':
-		s.ver = event.Version3
-	case '9':
-		s.ver = event.Version4
+	//  xxx++|-------------------------
+	if b[3] != '1' || b[4] != '.' {
+		return 0, malformedHeader(b, `trace header version was malformed`)
+	}
+
+	// "go 1.11 trace\x00\x00\x00"
+	//  xxxxx++-|-------------------
+	var minor, i int
+	for i = 5; i < 8 && '0' <= b[i] && b[i] <= '9'; i++ {
+		minor = minor*10 + int(b[i]-'0')
+	}
+	if i == 5 || b[i] != ' ' {
+		return 0, malformedHeader(b, `trace header version was malformed`)
+	}
+
+	var ver event.Version
+	switch minor {
+	case 5:
+		ver = event.Version1
+	case 7:
+		ver = event.Version2
+	case 8:
+		ver = event.Version3
+	case 9:
+		ver = event.Version4
+	case 11:
+		ver = event.Version5
+	case 19:
+		ver = event.Version6
 	default:
-		return errors.New(`trace header version was malformed`)
+		return 0, malformedHeader(b, `trace header version was malformed`)
 	}
 
 	// "go 1.8 trace\x00\x00\x00\x00"
 	//  xxxxxx++++++++++++++++++++++|
-	if !bytes.Equal(headerLut[:], b[7:]) {
-		s.ver = 0
-		return errors.New(`trace header suffix was malformed`)
+	if !bytes.Equal(headerLut[:len(b)-i-1], b[i+1:]) {
+		return 0, errors.New(`trace header suffix was malformed`)
 	}
-	return nil
+	return ver, nil
+}
+
+// malformedHeader returns ErrUnsupportedVersion if the header is well formed
+// for a version that is not supported, otherwise an error with msg.
+func malformedHeader(b [16]byte, msg string) error {
+	// "go 1.22 trace\x00\x00\x00"
+	//  xxxxx++-|----------------
+	i := 5
+	for ; i < len(b) && '0' <= b[i] && b[i] <= '9'; i++ {
+	}
+	if i == 5 || !bytes.HasPrefix(b[i:], []byte(" trace")) {
+		return errors.New(msg)
+	}
+	for _, c := range b[i+6:] {
+		if c != 0 {
+			return errors.New(msg)
+		}
+	}
+	return ErrUnsupportedVersion
 }
 
 // decodeEvent is the top level entry function for decoding events. It will
@@ -233,7 +694,7 @@ func decodeEvent(s *state, evt *event.Event) error {
 	if err != nil {
 		return err
 	}
-	if evt.Type.Since() > s.ver {
+	if !s.ver.Supports(evt.Type) {
 		return fmt.Errorf(`version %v does not support event %v`, s.ver, evt.Type)
 	}
 
@@ -258,6 +719,13 @@ func decodeEventData(s *state, evt *event.Event, args int) error {
 			return err
 		}
 		return decodeEventString(s, evt)
+	case evt.Type == event.EvUserLog:
+		// User logs are followed by the logged value in the same form as the
+		// payload of a string.
+		if err := decodeEventArgs(s, evt); err != nil {
+			return err
+		}
+		return decodeEventString(s, evt)
 	case args < 4:
 		// Arguments are inline if they do not exceed this boundary.
 		return decodeEventInline(s, args+s.argoff, evt)
@@ -323,6 +791,12 @@ func decodeEventString(s *state, evt *event.Event) error {
 		}
 		return err
 	}
+	if s.opts.lazy && !s.opts.fidelity {
+		return decodeEventSpan(s, evt, int(size))
+	}
+	if s.opts.large > 0 && size > uint64(s.opts.large) && !s.opts.fidelity {
+		return decodeEventLarge(s, evt, int(size))
+	}
 	if maxMakeSize < size {
 		return fmt.Errorf(
 			"size %v exceeds allocation limit(%v)", size, maxMakeSize)
@@ -334,17 +808,82 @@ func decodeEventString(s *state, evt *event.Event) error {
 	}
 
 	if _, err = io.ReadFull(s, evt.Data); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
 		return err
 	}
 	return nil
 }
 
+// decodeEventSpan will record the location of the message payload in evt.Span
+// instead of copying it into evt.Data, see LazyStrings.
+func decodeEventSpan(s *state, evt *event.Event, size int) (err error) {
+	evt.Data = evt.Data[0:0]
+	if s.opts.spill == nil {
+		var n int
+		evt.Span = event.Span{Off: s.off, Len: size}
+		n, err = s.Reader.Discard(size)
+		s.off += n
+	} else {
+		evt.Span, err = s.opts.spill.copy(s, size)
+	}
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// decodeEventLarge will stream a message payload exceeding the configured size
+// to the callback given to LargeStrings, or cap it when none was given.
+func decodeEventLarge(s *state, evt *event.Event, size int) error {
+	fn, max := s.opts.largeFn, s.opts.large
+	lr := &io.LimitedReader{R: s, N: int64(size)}
+	if fn == nil {
+		if max > cap(evt.Data) {
+			evt.Data = make([]byte, max)
+		} else {
+			evt.Data = evt.Data[0:max]
+		}
+		if _, err := io.ReadFull(lr, evt.Data); err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	} else {
+		evt.Data = evt.Data[0:0]
+		evt.Span = event.Span{Off: s.off, Len: size}
+
+		// The callback is given a copy, so the event given to Decode does not
+		// escape to the heap when payloads are not streamed.
+		cp := new(event.Event)
+		*cp = *evt
+		err := fn(cp, lr)
+		*evt = *cp
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.Copy(ioutil.Discard, lr); err != nil {
+		return err
+	}
+	if lr.N > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
 // decodeEventArgs is used when the args packed in the event byte exceed the
 // available bits, instead specifying to decode uleb values until exceeding the
 // given message length received from the first uleb value.
 func decodeEventArgs(s *state, evt *event.Event) error {
 	v, err := decodeUleb(s)
 	if err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
 		return err
 	}
 	if maxMakeSize < v {
@@ -356,6 +895,9 @@ func decodeEventArgs(s *state, evt *event.Event) error {
 	until := s.off + int(v)
 	for s.off < until {
 		if v, err = decodeUleb(s); err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
 			return err
 		}
 		evt.Args = append(evt.Args, v)
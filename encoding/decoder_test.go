@@ -5,6 +5,7 @@ import (
 	"errors"
 	"flag"
 	"io"
+	"io/ioutil"
 	"reflect"
 	"strings"
 	"testing"
@@ -153,6 +154,916 @@ func TestDecoder(t *testing.T) {
 	})
 }
 
+func TestDecoderStopped(t *testing.T) {
+	decodeAll := func(b []byte) *Decoder {
+		dec, evt := NewDecoder(bytes.NewReader(b)), new(event.Event)
+		for dec.More() {
+			evt.Reset()
+			if err := dec.Decode(evt); err != nil {
+				break
+			}
+		}
+		return dec
+	}
+	t.Run(`Footer`, func(t *testing.T) {
+		for _, tf := range traceList.ByName(`log.trace`) {
+			dec := decodeAll(tf.Bytes())
+			if err := dec.Err(); err != nil {
+				t.Fatalf(`exp nil err for %v; got %v`, tf.Path, err)
+			}
+			if !dec.Stopped() {
+				t.Fatalf(`exp Stopped() to be true for %v`, tf.Path)
+			}
+		}
+	})
+	t.Run(`Boundary`, func(t *testing.T) {
+		dec := decodeAll(makeBuffer(t, event.Latest, 1).Bytes())
+		if err := dec.Err(); err != nil {
+			t.Fatalf(`exp nil err; got %v`, err)
+		}
+		if dec.Stopped() {
+			t.Fatal(`exp Stopped() to be false without a footer`)
+		}
+	})
+	t.Run(`Broken`, func(t *testing.T) {
+		buf := makeBuffer(t, event.Latest, 3)
+		dec := decodeAll(buf.Bytes()[:buf.Len()-1])
+		if err := dec.Err(); err != io.ErrUnexpectedEOF {
+			t.Fatalf(`exp io.ErrUnexpectedEOF; got %v`, err)
+		}
+		if dec.Stopped() {
+			t.Fatal(`exp Stopped() to be false for a broken stream`)
+		}
+	})
+	t.Run(`Reset`, func(t *testing.T) {
+		tf := traceList.ByName(`log.trace`)[0]
+		dec := decodeAll(tf.Bytes())
+		dec.Reset(bytes.NewReader(tf.Bytes()))
+		if dec.Stopped() {
+			t.Fatal(`exp Stopped() to be false after Reset`)
+		}
+	})
+}
+
+func TestDecoderStrict(t *testing.T) {
+	decodeAll := func(r io.Reader) *Decoder {
+		dec, evt := NewDecoder(r, Strict()), new(event.Event)
+		for dec.More() {
+			evt.Reset()
+			if err := dec.Decode(evt); err != nil {
+				break
+			}
+		}
+		return dec
+	}
+	t.Run(`Valid`, func(t *testing.T) {
+		for _, tf := range traceList {
+			dec := decodeAll(bytes.NewReader(tf.Bytes()))
+			if err := dec.Err(); err != nil {
+				t.Fatalf(`exp nil err for %v; got %v`, tf.Path, err)
+			}
+		}
+	})
+
+	str := func(id uint64, s string) *event.Event {
+		return &event.Event{Type: event.EvString, Args: []uint64{id}, Data: []byte(s)}
+	}
+	evt := func(typ event.Type, args ...uint64) *event.Event {
+		return &event.Event{Type: typ, Args: args}
+	}
+	tests := []struct {
+		name string
+		evts []*event.Event
+		exp  string
+		idx  int
+	}{
+		{`ArgCount`, []*event.Event{
+			evt(event.EvGoStart, 1)},
+			`expected 3 arguments; got 1`, 0},
+		{`DuplicateString`, []*event.Event{
+			str(1, `a`), str(1, `b`)},
+			`string id 1 was already declared`, 1},
+		{`StackSize`, []*event.Event{
+			evt(event.EvStack, 1, 2, 0x1, 1, 1, 10)},
+			`stack size 2 does not match 4 frame arguments`, 0},
+		{`FrameString`, []*event.Event{
+			str(1, `main.main`), evt(event.EvStack, 1, 1, 0x1, 1, 2, 10)},
+			`frame string id 2 was not declared`, 1},
+		{`LabelString`, []*event.Event{
+			evt(event.EvGoStartLabel, 1, 2, 3, 4)},
+			`label string id 4 was not declared`, 0},
+		{`UndeclaredStack`, []*event.Event{
+			evt(event.EvGoSched, 1, 5), evt(event.EvFrequency, 1)},
+			`stack id 5 was never declared`, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			for _, e := range test.evts {
+				if err := enc.Emit(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Decode once without validation to obtain the expected offset.
+			exp := new(event.Event)
+			dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+			for i := 0; i <= test.idx; i++ {
+				if err := dec.Decode(exp); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := decodeAll(bytes.NewReader(buf.Bytes())).Err()
+			serr, ok := err.(*SchemaError)
+			if !ok {
+				t.Fatalf(`exp *SchemaError; got %v`, err)
+			}
+			if serr.Msg != test.exp {
+				t.Fatalf(`exp msg %q; got %q`, test.exp, serr.Msg)
+			}
+			if serr.Off != exp.Off || serr.Type != exp.Type {
+				t.Fatalf(`exp %v at 0x%x; got %v`, exp.Type, exp.Off, serr)
+			}
+		})
+	}
+	t.Run(`Truncated`, func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Emit(evt(event.EvGoSched, 1, 5)); err != nil {
+			t.Fatal(err)
+		}
+		if err := decodeAll(&buf).Err(); err != nil {
+			t.Fatalf(`exp nil err without a footer; got %v`, err)
+		}
+	})
+}
+
+func TestDecoderTrailingBytes(t *testing.T) {
+	tests := []struct {
+		name  string
+		extra []byte
+	}{
+		{`Text`, []byte("PASS\nok  \tlog\t0.004s\n")},
+		{`Concatenated`, makeBuffer(t, event.Latest, 3).Bytes()},
+		{`InvalidStack`, []byte{0xc3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+			0xff, 0xff, 0xff, 0x1}},
+		{`Zeros`, make([]byte, 12)},
+	}
+	for _, tf := range traceList.ByName(`log.trace`) {
+		for _, test := range tests {
+			t.Run(tf.Version.Go()+`/`+test.name, func(t *testing.T) {
+				count := func(data []byte) (dec *Decoder, n int) {
+					dec, evt := NewDecoder(bytes.NewReader(data)), new(event.Event)
+					for dec.More() {
+						evt.Reset()
+						if err := dec.Decode(evt); err != nil {
+							break
+						}
+						n++
+					}
+					return dec, n
+				}
+
+				_, exp := count(tf.Bytes())
+				dec, got := count(append(tf.Bytes(), test.extra...))
+				if exp != got {
+					t.Fatalf(`exp %v events decoded; got %v`, exp, got)
+				}
+
+				if err := dec.Err(); err != nil {
+					t.Fatalf(`exp nil err with trailing bytes; got %v`, err)
+				}
+				if exp, got := len(test.extra), dec.TrailingBytes(); exp != got {
+					t.Fatalf(`exp %v trailing bytes; got %v`, exp, got)
+				}
+				if !dec.Stopped() {
+					t.Fatal(`exp Stopped() to be true with trailing bytes`)
+				}
+			})
+		}
+	}
+	t.Run(`NoFooter`, func(t *testing.T) {
+		buf := makeBuffer(t, event.Latest, 1)
+		buf.Write([]byte("garbage"))
+		dec, evt := NewDecoder(buf), new(event.Event)
+		for dec.More() {
+			if err := dec.Decode(evt); err != nil {
+				break
+			}
+		}
+		if dec.Err() == nil {
+			t.Fatalf(`exp decoding error without a footer; got %v`, dec.Err())
+		}
+		if dec.TrailingBytes() != 0 {
+			t.Fatal(`exp zero trailing bytes`)
+		}
+	})
+}
+
+func TestDecoderClone(t *testing.T) {
+	decodeAll := func(dec *Decoder) (out []*event.Event, err error) {
+		for dec.More() {
+			evt := new(event.Event)
+			if err = dec.Decode(evt); err != nil {
+				break
+			}
+			out = append(out, evt)
+		}
+		return out, dec.Err()
+	}
+	for _, tf := range traceList.ByName(`log.trace`) {
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			data := tf.Bytes()
+			exp, err := decodeAll(NewDecoder(bytes.NewReader(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Start the reader at a non-zero position to check the seek base.
+			r := bytes.NewReader(append([]byte(`prefix`), data...))
+			r.Seek(6, io.SeekStart)
+			dec, half := NewDecoder(r), len(exp)/2
+			for i := 0; i < half; i++ {
+				if err := dec.Decode(new(event.Event)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			clone, err := dec.Clone()
+			if err != nil {
+				t.Fatal(err)
+			}
+			type result struct {
+				evts []*event.Event
+				err  error
+			}
+			ch := make(chan result, 2)
+			for _, d := range []*Decoder{dec, clone} {
+				go func(d *Decoder) {
+					evts, err := decodeAll(d)
+					ch <- result{evts, err}
+				}(d)
+			}
+			for i := 0; i < 2; i++ {
+				res := <-ch
+				if res.err != nil {
+					t.Fatal(res.err)
+				}
+				if !reflect.DeepEqual(exp[half:], res.evts) {
+					t.Fatal(`exp clone to decode the same events as the original`)
+				}
+			}
+		})
+	}
+	t.Run(`LazyStrings`, func(t *testing.T) {
+		data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+		exp, err := decodeAll(NewDecoder(bytes.NewReader(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var spill bytes.Buffer
+		dec := NewDecoder(bytes.NewReader(data), LazyStrings(&spill))
+		if _, err := dec.Version(); err != nil {
+			t.Fatal(err)
+		}
+		clone, err := dec.Clone()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Decode through each in turn so their payloads interleave in the spill.
+		var all [2][]*event.Event
+		for dec.More() || clone.More() {
+			for i, d := range []*Decoder{dec, clone} {
+				if !d.More() {
+					continue
+				}
+				evt := new(event.Event)
+				if err := d.Decode(evt); err != nil {
+					t.Fatal(err)
+				}
+				all[i] = append(all[i], evt)
+			}
+		}
+		for _, evts := range all {
+			var n int
+			for i, evt := range evts {
+				if evt.Type != event.EvString {
+					continue
+				}
+				n++
+				got := spill.Bytes()[evt.Span.Off : evt.Span.Off+evt.Span.Len]
+				if !bytes.Equal(exp[i].Data, got) {
+					t.Fatalf(`exp string %q at span %v; got %q`, exp[i].Data, evt.Span, got)
+				}
+			}
+			if n == 0 {
+				t.Fatal(`exp strings to be decoded`)
+			}
+		}
+	})
+	t.Run(`Errors`, func(t *testing.T) {
+		dec := NewDecoder(struct{ io.Reader }{makeBuffer(t, event.Latest, 1)})
+		if _, err := dec.Clone(); err == nil {
+			t.Fatal(`exp non-nil err for input without io.ReaderAt`)
+		}
+		sentinel := errors.New(`sentinel`)
+		dec.halt(sentinel)
+		if _, err := dec.Clone(); err != sentinel {
+			t.Fatalf(`exp err %v; got %v`, sentinel, err)
+		}
+	})
+}
+
+type countVisitor struct {
+	n, limit int
+	err      error
+}
+
+func (v *countVisitor) Visit(evt *event.Event) error {
+	if v.n++; v.limit > 0 && v.n >= v.limit {
+		return v.err
+	}
+	return nil
+}
+
+func TestDecoderVisitAll(t *testing.T) {
+	tf := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0]
+	t.Run(`All`, func(t *testing.T) {
+		v := new(countVisitor)
+		if err := NewDecoder(bytes.NewReader(tf.Bytes())).VisitAll(v); err != nil {
+			t.Fatal(err)
+		}
+		if exp := 354; v.n != exp {
+			t.Fatalf(`exp %v events; got %v`, exp, v.n)
+		}
+	})
+	t.Run(`VisitError`, func(t *testing.T) {
+		sentinel := errors.New(`sentinel`)
+		v := &countVisitor{limit: 10, err: sentinel}
+		dec := NewDecoder(bytes.NewReader(tf.Bytes()))
+		if err := dec.VisitAll(v); err != sentinel {
+			t.Fatalf(`exp err %v; got %v`, sentinel, err)
+		}
+		if dec.Err() != nil {
+			t.Fatalf(`exp visit errors to not halt the decoder; got %v`, dec.Err())
+		}
+
+		// the decoder may continue with the next event
+		v.limit = 0
+		if err := dec.VisitAll(v); err != nil {
+			t.Fatal(err)
+		}
+		if exp := 354; v.n != exp {
+			t.Fatalf(`exp %v events; got %v`, exp, v.n)
+		}
+	})
+	t.Run(`DecodeError`, func(t *testing.T) {
+		b := tf.Bytes()
+		dec := NewDecoder(bytes.NewReader(b[:len(b)-1]))
+		if err := dec.VisitAll(new(countVisitor)); err != io.ErrUnexpectedEOF {
+			t.Fatalf(`exp io.ErrUnexpectedEOF; got %v`, err)
+		}
+	})
+}
+
+func TestDecoderLargeStrings(t *testing.T) {
+	large := bytes.Repeat([]byte(`0123456789`), 1e5+1) // exceeds maxMakeSize
+	makeTrace := func(t *testing.T) []byte {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		evts := []*event.Event{
+			{Type: event.EvString, Args: []uint64{1}, Data: []byte(`small`)},
+			{Type: event.EvString, Args: []uint64{2}, Data: large},
+			{Type: event.EvGoEnd, Args: []uint64{1}},
+		}
+		for _, evt := range evts {
+			if err := enc.Emit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return buf.Bytes()
+	}
+	decodeAll := func(t *testing.T, dec *Decoder) (out []*event.Event) {
+		for dec.More() {
+			evt := new(event.Event)
+			if err := dec.Decode(evt); err != nil {
+				break
+			}
+			out = append(out, evt)
+		}
+		if err := dec.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if exp, got := 3, len(out); exp != got {
+			t.Fatalf(`exp %v events; got %v`, exp, got)
+		}
+		return
+	}
+	t.Run(`Callback`, func(t *testing.T) {
+		data := makeTrace(t)
+		var got []byte
+		dec := NewDecoder(bytes.NewReader(data), LargeStrings(8,
+			func(evt *event.Event, r io.Reader) (err error) {
+				if evt.Type != event.EvString || evt.Args[0] != 2 {
+					t.Fatalf(`exp large string event; got %v`, evt)
+				}
+				got, err = ioutil.ReadAll(r)
+				return
+			}))
+		evts := decodeAll(t, dec)
+		if string(evts[0].Data) != `small` {
+			t.Fatalf(`exp small string to be materialized; got %q`, evts[0].Data)
+		}
+		if !bytes.Equal(large, got) {
+			t.Fatal(`exp callback to receive the entire payload`)
+		}
+		sp := evts[1].Span
+		if len(evts[1].Data) != 0 || sp.Len != len(large) {
+			t.Fatalf(`exp empty Data and span length %v; got %v`, len(large), sp)
+		}
+		if !bytes.Equal(large, data[sp.Off:sp.Off+sp.Len]) {
+			t.Fatal(`exp span to locate the payload in the input`)
+		}
+	})
+	t.Run(`PartialRead`, func(t *testing.T) {
+		dec := NewDecoder(bytes.NewReader(makeTrace(t)), LargeStrings(8,
+			func(evt *event.Event, r io.Reader) error {
+				_, err := r.Read(make([]byte, 4))
+				return err
+			}))
+		evts := decodeAll(t, dec)
+		if evts[2].Type != event.EvGoEnd {
+			t.Fatalf(`exp unread payload to be discarded; got %v`, evts[2])
+		}
+	})
+	t.Run(`Cap`, func(t *testing.T) {
+		dec := NewDecoder(bytes.NewReader(makeTrace(t)), LargeStrings(8, nil))
+		evts := decodeAll(t, dec)
+		if exp, got := `01234567`, string(evts[1].Data); exp != got {
+			t.Fatalf(`exp capped data %q; got %q`, exp, got)
+		}
+	})
+	t.Run(`Errors`, func(t *testing.T) {
+		data := makeTrace(t)
+		sentinel := errors.New(`sentinel`)
+		dec := NewDecoder(bytes.NewReader(data), LargeStrings(8,
+			func(evt *event.Event, r io.Reader) error { return sentinel }))
+		if err := dec.VisitAll(new(countVisitor)); err != sentinel {
+			t.Fatalf(`exp err %v; got %v`, sentinel, err)
+		}
+
+		data = data[:len(data)-100]
+		for _, fn := range []func(*event.Event, io.Reader) error{nil,
+			func(evt *event.Event, r io.Reader) error { return nil }} {
+			dec = NewDecoder(bytes.NewReader(data), LargeStrings(8, fn))
+			if err := dec.VisitAll(new(countVisitor)); err != io.ErrUnexpectedEOF {
+				t.Fatalf(`exp io.ErrUnexpectedEOF; got %v`, err)
+			}
+		}
+	})
+}
+
+func TestDecoderBatch(t *testing.T) {
+	t.Run(`Traces`, func(t *testing.T) {
+		for _, tf := range traceList.ByName(`log.trace`) {
+			dec, evt := NewDecoder(bytes.NewReader(tf.Bytes())), new(event.Event)
+			if _, ok := dec.Batch(); ok {
+				t.Fatal(`exp no batch before decoding`)
+			}
+
+			var batches int
+			for dec.More() {
+				evt.Reset()
+				if err := dec.Decode(evt); err != nil {
+					t.Fatal(err)
+				}
+				b, ok := dec.Batch()
+				if !ok {
+					continue
+				}
+				if evt.Type == event.EvBatch {
+					batches++
+					if exp := int64(evt.Args[0]); b.P != exp {
+						t.Fatalf(`exp batch P %v; got %v`, exp, b.P)
+					}
+				}
+				if evt.P != b.P {
+					t.Fatalf(`exp event %v to have P %v; got %v`, evt, b.P, evt.P)
+				}
+			}
+			if err := dec.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if batches == 0 {
+				t.Fatalf(`exp at least one batch in %v`, tf.Path)
+			}
+		}
+	})
+	t.Run(`Timestamp`, func(t *testing.T) {
+		for _, v := range []event.Version{event.Version1, event.Latest} {
+			test := testEvents[v][0]
+			dec := NewDecoder(bytes.NewReader(append(makeHeader(t, v), test.from...)))
+			if err := dec.Decode(new(event.Event)); err != nil {
+				t.Fatal(err)
+			}
+			b, ok := dec.Batch()
+			if !ok {
+				t.Fatal(`exp batch after decoding EvBatch`)
+			}
+			if exp := int64(test.exp[len(test.exp)-1]); b.Ts != exp {
+				t.Fatalf(`exp batch Ts %v; got %v`, exp, b.Ts)
+			}
+		}
+	})
+}
+
+func TestDecoderClock(t *testing.T) {
+	t.Run(`Ticks`, func(t *testing.T) {
+		for _, tf := range traceList.ByName(`log.trace`) {
+			dec, evt := NewDecoder(bytes.NewReader(tf.Bytes())), new(event.Event)
+
+			var gs int
+			for dec.More() {
+				evt.Reset()
+				if err := dec.Decode(evt); err != nil {
+					t.Fatal(err)
+				}
+				b, ok := dec.Batch()
+				if !ok || evt.Type == event.EvBatch {
+					continue
+				}
+				if args := evt.Type.Args(); len(args) == 0 || args[0] != event.ArgTimestamp {
+					if evt.Ts != 0 || evt.G != 0 {
+						t.Fatalf(`exp zero Ts and G for %v; got %v, %v`, evt, evt.Ts, evt.G)
+					}
+					continue
+				}
+				if evt.Ts < b.Ts {
+					t.Fatalf(`exp Ts of %v to be >= batch Ts %v; got %v`, evt, b.Ts, evt.Ts)
+				}
+				if evt.G != 0 {
+					gs++
+				}
+			}
+			if err := dec.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if gs == 0 {
+				t.Fatalf(`exp events with a G in %v`, tf.Path)
+			}
+		}
+	})
+	t.Run(`Goroutine`, func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		for _, e := range []*event.Event{
+			{Type: event.EvBatch, Args: []uint64{1, 100}},
+			{Type: event.EvGoStart, Args: []uint64{5, 7, 0}},
+			{Type: event.EvGoSched, Args: []uint64{10, 0}},
+			{Type: event.EvGoBlock, Args: []uint64{1, 0}},
+			{Type: event.EvBatch, Args: []uint64{2, 90}},
+			{Type: event.EvGoWaiting, Args: []uint64{3, 9}},
+		} {
+			if err := enc.Emit(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		exp := []struct{ p, g, ts int64 }{
+			{1, 0, 100}, {1, 7, 105}, {1, 7, 115}, {1, 0, 116}, {2, 0, 90}, {2, 9, 93}}
+		dec, evt := NewDecoder(&buf), new(event.Event)
+		for i := 0; dec.More(); i++ {
+			evt.Reset()
+			if err := dec.Decode(evt); err != nil {
+				t.Fatal(err)
+			}
+			if got := exp[i]; evt.P != got.p || evt.G != got.g || evt.Ts != got.ts {
+				t.Fatalf(`exp P %v G %v Ts %v for %v; got P %v G %v Ts %v`,
+					got.p, got.g, got.ts, evt, evt.P, evt.G, evt.Ts)
+			}
+		}
+	})
+	t.Run(`Nanoseconds`, func(t *testing.T) {
+		for _, tf := range traceList.ByName(`log.trace`) {
+			ticks := NewDecoder(bytes.NewReader(tf.Bytes()))
+			nanos := NewDecoder(bytes.NewReader(tf.Bytes()), Nanoseconds())
+
+			var freq uint64
+			var a, b []int64
+			for _, dec := range []*Decoder{ticks, nanos} {
+				evt := new(event.Event)
+				for dec.More() {
+					evt.Reset()
+					if err := dec.Decode(evt); err != nil {
+						t.Fatal(err)
+					}
+					if evt.Type == event.EvFrequency {
+						freq = evt.Args[0]
+					}
+					if dec == ticks {
+						a = append(a, evt.Ts)
+					} else {
+						b = append(b, evt.Ts)
+					}
+				}
+			}
+			for i := range a {
+				if exp := int64(float64(a[i]) * 1e9 / float64(freq)); exp != b[i] {
+					t.Fatalf(`exp Ts %v at event %v of %v; got %v`, exp, i, tf.Path, b[i])
+				}
+			}
+		}
+
+		data := traceList.ByName(`log.trace`)[0].Bytes()
+		dec := NewDecoder(ioutil.NopCloser(bytes.NewReader(data)), Nanoseconds())
+		if err := dec.Decode(new(event.Event)); err == nil {
+			t.Fatal(`exp non-nil err for input without io.ReaderAt`)
+		}
+		dec = NewDecoder(bytes.NewReader(data[:64]), Nanoseconds())
+		if err := dec.Decode(new(event.Event)); err == nil {
+			t.Fatal(`exp non-nil err for input without a frequency`)
+		}
+	})
+}
+
+func TestDecoderSample(t *testing.T) {
+	const n = 3
+	for _, tf := range traceList.ByName(`log.trace`) {
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			decode := func(r io.Reader, opts ...Option) (ts map[event.Type][]int64) {
+				ts = make(map[event.Type][]int64)
+				dec, evt := NewDecoder(r, opts...), new(event.Event)
+				for dec.More() {
+					evt.Reset()
+					if err := dec.Decode(evt); err != nil {
+						t.Fatal(err)
+					}
+					ts[evt.Type] = append(ts[evt.Type], evt.Ts)
+				}
+				if err := dec.Err(); err != nil {
+					t.Fatal(err)
+				}
+				return ts
+			}
+
+			all := decode(bytes.NewReader(tf.Bytes()))
+			check := func(got map[event.Type][]int64) {
+				for typ, exp := range all {
+					switch typ {
+					case event.EvBatch, event.EvFrequency, event.EvString, event.EvStack:
+						if !reflect.DeepEqual(exp, got[typ]) {
+							t.Fatalf(`exp all %v %v events; got %v`, len(exp), typ, len(got[typ]))
+						}
+						continue
+					}
+					if len(got[typ]) != (len(exp)+n-1)/n {
+						t.Fatalf(`exp every %vth of %v %v events; got %v`,
+							n, len(exp), typ, len(got[typ]))
+					}
+					for i, ts := range got[typ] {
+						if ts != exp[i*n] {
+							t.Fatalf(`exp Ts %v for %v event %v; got %v`, exp[i*n], typ, i*n, ts)
+						}
+					}
+				}
+			}
+			check(decode(bytes.NewReader(tf.Bytes()), Sample(n)))
+			if got := decode(bytes.NewReader(tf.Bytes()), Sample(1)); !reflect.DeepEqual(all, got) {
+				t.Fatal(`exp Sample(1) to keep every event`)
+			}
+			if tf.Version == event.Version1 {
+				return
+			}
+
+			// Deltas of skipped events are carried, so the sampled events may be
+			// encoded without changing their timestamps.
+			var buf bytes.Buffer
+			dec := NewDecoder(bytes.NewReader(tf.Bytes()), Sample(n))
+			enc := NewEncoder(&buf, TargetVersion(tf.Version))
+			for dec.More() {
+				evt := new(event.Event)
+				if err := dec.Decode(evt); err != nil {
+					t.Fatal(err)
+				}
+				if err := enc.Emit(evt); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+			check(decode(&buf))
+		})
+	}
+
+	// Events are decoded ahead, so More reports false rather than Decode
+	// returning io.EOF when the remaining events are skipped.
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.BeginBatch(0, 10); err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 4; i++ {
+		if err := enc.Emit(&event.Event{Type: event.EvGoSched, Args: []uint64{0, 0}, Ts: 10 + i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dec := NewDecoder(&buf, Sample(2))
+	var types []event.Type
+	for dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			t.Fatalf(`exp nil err after More returned true; got %v`, err)
+		}
+		types = append(types, evt.Type)
+	}
+	if err := dec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []event.Type{event.EvBatch, event.EvGoSched, event.EvGoSched}; !reflect.DeepEqual(exp, types) {
+		t.Fatalf(`exp events %v; got %v`, exp, types)
+	}
+}
+
+func TestDecoderSampleStacks(t *testing.T) {
+	const k = 2
+	for _, tf := range traceList.ByName(`sync_atomic.trace`) {
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			pos := make(map[event.Type]int)
+			for _, sch := range event.Schemas(tf.Version) {
+				for i, name := range sch.Args {
+					if name == event.ArgStackID {
+						pos[sch.Type] = i
+					}
+				}
+			}
+			type key struct {
+				typ event.Type
+				stk uint64
+			}
+			decode := func(r io.Reader, opts ...Option) (evts []*event.Event, stks map[key]int) {
+				stks = make(map[key]int)
+				dec := NewDecoder(r, opts...)
+				for dec.More() {
+					evt := new(event.Event)
+					if err := dec.Decode(evt); err != nil {
+						t.Fatal(err)
+					}
+					if i, ok := pos[evt.Type]; ok {
+						stks[key{evt.Type, evt.Args[i]}]++
+					}
+					evts = append(evts, evt)
+				}
+				if err := dec.Err(); err != nil {
+					t.Fatal(err)
+				}
+				return evts, stks
+			}
+
+			all, exp := decode(bytes.NewReader(tf.Bytes()))
+			got, stks := decode(bytes.NewReader(tf.Bytes()), SampleStacks(k), Sample(3))
+			for stk, n := range exp {
+				if n > k {
+					n = k
+				}
+				if stks[stk] != n {
+					t.Fatalf(`exp %v events of %v; got %v`, n, stk, stks[stk])
+				}
+			}
+
+			// The events returned are those of the trace in the same order,
+			// including every event without a stack.
+			var i int
+			for _, evt := range all {
+				if i < len(got) && got[i].Off == evt.Off {
+					if got[i].Ts != evt.Ts || got[i].Type != evt.Type {
+						t.Fatalf(`exp %v at %v; got %v at %v`, evt.Type, evt.Ts, got[i].Type, got[i].Ts)
+					}
+					i++
+					continue
+				}
+				if _, ok := pos[evt.Type]; !ok {
+					t.Fatalf(`exp event without a stack to be returned; got %v skipped`, evt)
+				}
+			}
+			if i != len(got) {
+				t.Fatalf(`exp %v events in the order of the trace; got %v`, len(got), i)
+			}
+			again, _ := decode(bytes.NewReader(tf.Bytes()), SampleStacks(k))
+			if !reflect.DeepEqual(got, again) {
+				t.Fatal(`exp the same events to be sampled from the same trace`)
+			}
+			if tf.Version == event.Version1 {
+				return
+			}
+
+			// Deltas of skipped events are carried, so the sampled events may be
+			// encoded without changing their timestamps.
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf, TargetVersion(tf.Version))
+			for _, evt := range got {
+				if err := enc.Emit(evt); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+			out, _ := decode(&buf)
+			if len(out) != len(got) {
+				t.Fatalf(`exp %v events; got %v`, len(got), len(out))
+			}
+			for i := range out {
+				if out[i].Ts != got[i].Ts {
+					t.Fatalf(`exp Ts %v for %v; got %v`, got[i].Ts, got[i], out[i].Ts)
+				}
+			}
+		})
+	}
+}
+
+func TestDecoderLazyStrings(t *testing.T) {
+	visitAll := func(t *testing.T, tr *event.Trace, dec *Decoder, lazy bool) {
+		evt := new(event.Event)
+		for dec.More() {
+			evt.Reset()
+			if err := dec.Decode(evt); err != nil {
+				t.Fatal(err)
+			}
+			if evt.Type != event.EvString {
+				continue
+			}
+			if lazy && len(evt.Data) > 0 {
+				t.Fatal(`exp lazily decoded strings to have empty Data`)
+			}
+			if err := tr.Visit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := dec.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(t *testing.T, exp map[uint64]string, tr *event.Trace) {
+		if len(exp) == 0 {
+			t.Fatal(`exp at least one string`)
+		}
+		if len(tr.Strings) != 0 {
+			t.Fatalf(`exp no eager strings; got %v`, len(tr.Strings))
+		}
+		for id, str := range exp {
+			got, err := tr.GetString(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != str {
+				t.Fatalf(`exp string %v to be %q; got %q`, id, str, got)
+			}
+		}
+	}
+	for _, tf := range traceList.ByVersion(event.Version4) {
+		data := tf.Bytes()
+		eager, err := event.NewTrace(event.Latest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		visitAll(t, eager, NewDecoder(bytes.NewReader(data)), false)
+
+		t.Run(`Source/`+tf.Name, func(t *testing.T) {
+			tr, _ := event.NewTrace(event.Latest)
+			visitAll(t, tr, NewDecoder(bytes.NewReader(data), LazyStrings(nil)), true)
+			if _, err := tr.GetString(1); err == nil {
+				t.Fatal(`exp non-nil err without a Source`)
+			}
+			tr.Source = bytes.NewReader(data)
+			check(t, eager.Strings, tr)
+		})
+		t.Run(`Spill/`+tf.Name, func(t *testing.T) {
+			var spill bytes.Buffer
+			tr, _ := event.NewTrace(event.Latest)
+			visitAll(t, tr, NewDecoder(bytes.NewReader(data), LazyStrings(&spill)), true)
+			tr.Source = bytes.NewReader(spill.Bytes())
+			check(t, eager.Strings, tr)
+		})
+	}
+	t.Run(`UnexpectedEOF`, func(t *testing.T) {
+		test := testEventStrings[0]
+		for _, spill := range []io.Writer{nil, new(bytes.Buffer)} {
+			s := testDecodeSetup(t, event.Latest, test.from[:len(test.from)-1])
+			s.opts = newOptions([]Option{LazyStrings(spill)})
+			if err := decodeEvent(s, new(event.Event)); err != io.ErrUnexpectedEOF {
+				t.Fatalf(`exp io.ErrUnexpectedEOF; got %v`, err)
+			}
+		}
+	})
+}
+
 func TestState(t *testing.T) {
 	t.Run(`Reset`, func(t *testing.T) {
 		// nil state Reader should get a new Reader
@@ -359,7 +1270,7 @@ func TestDecodeHeader(t *testing.T) {
 	})
 	t.Run(`Invalid`, func(t *testing.T) {
 		buf, header := new(bytes.Buffer), makeHeader(t, event.Latest)
-		header[5] = '0' // set invalid version
+		header[6] = '3' // set invalid version
 		buf.Write(header)
 
 		dec := NewDecoder(buf)
@@ -370,6 +1281,69 @@ func TestDecodeHeader(t *testing.T) {
 	})
 }
 
+func TestSniffVersion(t *testing.T) {
+	t.Run(`Versions`, func(t *testing.T) {
+		for _, tf := range traceList.ByName(`log.trace`) {
+			data := tf.Bytes()
+			ver, r, err := SniffVersion(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ver != tf.Version {
+				t.Fatalf(`exp version %v; got %v`, tf.Version, ver)
+			}
+
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, got) {
+				t.Fatal(`exp replayed reader to yield the entire input`)
+			}
+		}
+	})
+	t.Run(`Unsupported`, func(t *testing.T) {
+		for _, from := range []string{
+			"go 1.22 trace\x00\x00\x00",
+			"go 1.4 trace\x00\x00\x00\x00",
+			"go 1.10 trace\x00\x00\x00",
+		} {
+			_, r, err := SniffVersion(strings.NewReader(from + `rest`))
+			if err != ErrUnsupportedVersion {
+				t.Fatalf(`exp ErrUnsupportedVersion for %q; got %v`, from, err)
+			}
+			got, _ := ioutil.ReadAll(r)
+			if exp := from + `rest`; exp != string(got) {
+				t.Fatalf(`exp replayed reader to yield %q; got %q`, exp, got)
+			}
+		}
+	})
+	t.Run(`Malformed`, func(t *testing.T) {
+		for _, from := range []string{
+			"go 1.22 traces\x00\x00",
+			"go 1. trace\x00\x00\x00\x00\x00",
+			"no 1.9 trace\x00\x00\x00\x00",
+		} {
+			_, _, err := SniffVersion(strings.NewReader(from))
+			if err == nil || err == ErrUnsupportedVersion {
+				t.Fatalf(`exp malformed header err for %q; got %v`, from, err)
+			}
+		}
+	})
+	t.Run(`Short`, func(t *testing.T) {
+		_, r, err := SniffVersion(strings.NewReader(`go 1.9`))
+		if err != io.ErrUnexpectedEOF {
+			t.Fatalf(`exp io.ErrUnexpectedEOF; got %v`, err)
+		}
+		if got, _ := ioutil.ReadAll(r); string(got) != `go 1.9` {
+			t.Fatalf(`exp replayed reader to yield partial header; got %q`, got)
+		}
+		if _, _, err = SniffVersion(strings.NewReader(``)); err != io.ErrUnexpectedEOF {
+			t.Fatalf(`exp io.ErrUnexpectedEOF; got %v`, err)
+		}
+	})
+}
+
 func TestDecodeUleb(t *testing.T) {
 	// Generated with:
 	//
@@ -440,6 +1414,12 @@ func TestDecodeUleb(t *testing.T) {
 			if v != test.exp {
 				t.Errorf(`exp %v; got %v`, test.exp, v)
 			}
+			if v, err = ReadUleb(bytes.NewReader(test.from)); err != nil || v != test.exp {
+				t.Errorf(`exp %v from ReadUleb; got %v (err %v)`, test.exp, v, err)
+			}
+			if b := AppendUleb([]byte{0xa}, test.exp); !bytes.Equal(b[1:], test.from) || b[0] != 0xa {
+				t.Errorf(`exp AppendUleb to append %#v; got %#v`, test.from, b)
+			}
 		}
 	})
 	t.Run(`Overflow`, func(t *testing.T) {
@@ -454,6 +1434,9 @@ func TestDecodeUleb(t *testing.T) {
 			if err == nil {
 				t.Fatalf(`exp non-nil err; got err %v and value %v`, err, v)
 			}
+			if _, err := ReadUleb(bytes.NewReader(test.from)); err == nil {
+				t.Fatal(`exp non-nil err from ReadUleb`)
+			}
 			if v != test.exp {
 				t.Errorf(`exp %v; got %v`, test.exp, v)
 			}
@@ -497,7 +1480,7 @@ func runDecodeEventTest(t *testing.T, v event.Version, tests []testDecodeEvent)
 			t.Run(`EventType`, func(t *testing.T) {
 				from := make([]byte, len(test.from))
 				copy(from, test.from)
-				from[0] = '0'
+				from[0] = byte(event.EvCount)
 				neg(t, from)
 			})
 			t.Run(`ArgsInvalidUleb`, func(t *testing.T) {
@@ -547,6 +1530,65 @@ func TestDecodeEvents(t *testing.T) {
 	})
 	t.Run(event.Version4.Go(), func(t *testing.T) {
 		runDecodeEventTest(t, event.Version4, testEventsV4)
+		t.Run(`Unsupported`, func(t *testing.T) {
+			test := testEventsV5[len(testEventsV5)-1]
+			s := testDecodeSetup(t, event.Version4, test.from)
+			evt := new(event.Event)
+			if err := decodeEvent(s, evt); err == nil {
+				t.Fatal(`exp non-nil err for event newer than version`)
+			}
+		})
+	})
+	t.Run(event.Version5.Go(), func(t *testing.T) {
+		runDecodeEventTest(t, event.Version5, testEventsV5)
+		t.Run(`UserLog`, func(t *testing.T) {
+			test := testEventsV5[len(testEventsV5)-1]
+			s := testDecodeSetup(t, event.Version5, test.from)
+			evt := new(event.Event)
+			if err := decodeEvent(s, evt); err != nil {
+				t.Fatal(err)
+			}
+			if exp, got := `v`, string(evt.Data); exp != got {
+				t.Fatalf(`exp logged value %q; got %q`, exp, got)
+			}
+
+			var buf bytes.Buffer
+			if err := encodeEvent(&offsetWriter{w: &buf}, evt); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(test.from, buf.Bytes()) {
+				t.Fatalf(`exp encoded bytes %v; got %v`, test.from, buf.Bytes())
+			}
+		})
+		t.Run(`Unsupported`, func(t *testing.T) {
+			test := testEventsV6[len(testEventsV6)-1]
+			s := testDecodeSetup(t, event.Version5, test.from)
+			evt := new(event.Event)
+			if err := decodeEvent(s, evt); err == nil {
+				t.Fatal(`exp non-nil err for event newer than version`)
+			}
+		})
+	})
+	t.Run(event.Version6.Go(), func(t *testing.T) {
+		runDecodeEventTest(t, event.Version6, testEventsV6)
+		t.Run(`CPUSample`, func(t *testing.T) {
+			// Samples are timed by their arguments rather than their batch.
+			from := append(makeHeader(t, event.Version6), 0x41, 0x1, 0xe8, 0x7)
+			from = append(from, testEventsV6[len(testEventsV6)-1].from...)
+			dec, evt := NewDecoder(bytes.NewReader(from)), new(event.Event)
+			for i := 0; i < 2; i++ {
+				if err := dec.Decode(evt); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if evt.Type != event.EvCPUSample {
+				t.Fatalf(`exp CPUSample; got %v`, evt.Type)
+			}
+			if evt.Ts != 0x2000 || evt.P != 1 || evt.G != 5 {
+				t.Fatalf(`exp sample at 0x2000 on P 1 in g 5; got %v on P %v in g %v`,
+					evt.Ts, evt.P, evt.G)
+			}
+		})
 	})
 }
 
@@ -593,3 +1635,328 @@ func TestDecodeEventStack(t *testing.T) {
 		}
 	})
 }
+
+func TestDecoderPool(t *testing.T) {
+	data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+	pool := NewDecoderPool(Strict())
+
+	var exp int
+	for i := 0; i < 3; i++ {
+		var n int
+		dec := pool.Get(bytes.NewReader(data))
+		err := dec.VisitAll(visitFunc(func(evt *event.Event) error {
+			n++
+			return nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !dec.state.opts.strict {
+			t.Fatal(`exp pooled decoder to retain its options`)
+		}
+		pool.Put(dec)
+		if dec.state.src != nil || dec.state.procs != nil {
+			t.Fatal(`exp pooled decoder to be reset by Put`)
+		}
+
+		if i == 0 {
+			exp = n
+		} else if n != exp {
+			t.Fatalf(`exp %v events from pooled decoder; got %v`, exp, n)
+		}
+	}
+}
+
+func TestNewDecoderAt(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			data := tf.Bytes()
+			var (
+				evts    []*event.Event
+				batches []int
+			)
+			dec := NewDecoder(bytes.NewReader(data))
+			err := dec.VisitAll(visitFunc(func(evt *event.Event) error {
+				if evt.Type == event.EvBatch {
+					batches = append(batches, len(evts))
+				}
+				evts = append(evts, evt.Copy())
+				return nil
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(batches) < 2 {
+				t.Fatalf(`exp several batches; got %v`, len(batches))
+			}
+
+			from := batches[len(batches)/2]
+			dec, err = NewDecoderAt(bytes.NewReader(data), int64(evts[from].Off))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ver, err := dec.Version(); err != nil || ver != tf.Version {
+				t.Fatalf(`exp version %v; got %v (err %v)`, tf.Version, ver, err)
+			}
+			i := from
+			err = dec.VisitAll(visitFunc(func(evt *event.Event) error {
+				if i >= len(evts) {
+					t.Fatalf(`exp %v events; got more`, len(evts)-from)
+				}
+				exp := evts[i]
+				if evt.Type != exp.Type || evt.Off != exp.Off || evt.Ts != exp.Ts ||
+					evt.P != exp.P || !reflect.DeepEqual(evt.Args, exp.Args) {
+					t.Fatalf(`exp event #%v to be %v; got %v`, i, exp, evt)
+				}
+				i++
+				return nil
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i != len(evts) {
+				t.Fatalf(`exp %v events; got %v`, len(evts)-from, i-from)
+			}
+
+			if _, err := NewDecoderAt(bytes.NewReader(data), 8); err == nil {
+				t.Fatal(`exp error for offset within the header`)
+			}
+			off := int64(evts[from+1].Off)
+			if evts[from+1].Type == event.EvBatch {
+				t.Fatal(`exp event following a batch not to be a batch`)
+			}
+			if _, err := NewDecoderAt(bytes.NewReader(data), off); err == nil {
+				t.Fatal(`exp error for offset not beginning a batch`)
+			}
+			if _, err := NewDecoderAt(bytes.NewReader(data[1:]), 16); err == nil {
+				t.Fatal(`exp error for malformed header`)
+			}
+		})
+	}
+}
+
+func TestDecoderOrderer(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		tf := tf
+		t.Run(tf.Path, func(t *testing.T) {
+			dec, evt := NewDecoder(bytes.NewReader(tf.Bytes())), new(event.Event)
+			o := event.NewOrderer(tf.Version)
+			tr, err := event.NewTrace(tf.Version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr.Lenient = true
+
+			var pushed int
+			for dec.More() {
+				evt.Reset()
+				if err := dec.Decode(evt); err != nil {
+					t.Fatal(err)
+				}
+				if err := tr.Visit(evt); err != nil {
+					t.Fatal(err)
+				}
+				if o.Push(evt) {
+					pushed++
+				}
+			}
+			if err := dec.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			var evts []*event.Event
+			for {
+				evt, err := o.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf(`%v after %d of %d events`, err, len(evts), pushed)
+				}
+				evts = append(evts, evt)
+			}
+			if n := len(evts); n == 0 || n != pushed {
+				t.Fatalf(`exp %d ordered events; got %d`, pushed, n)
+			}
+
+			// Stacks of Version1 only record program counters.
+			var named int
+			for _, g := range tr.Goroutines(evts) {
+				if g.TotalTime < 0 || g.ExecTime < 0 || g.ExecTime > g.TotalTime {
+					t.Fatalf(`exp consistent times for goroutine %v; got %+v`, g.ID, g)
+				}
+				if g.Name != `` || g.PC != 0 && tf.Version == event.Version1 {
+					named++
+				}
+			}
+			if named == 0 {
+				t.Fatal(`exp goroutines with a resolved name`)
+			}
+
+			if w := tr.Warnings(); len(w) != 0 {
+				t.Fatalf(`exp no warnings; got %v`, w)
+			}
+			if r := tr.Integrity(); !r.OK() {
+				t.Fatalf(`exp no integrity issues; got %+v`, r)
+			}
+
+			// Goroutines accumulated while visiting agree with the analysis.
+			descs := tr.Goroutines(evts)
+			ids := tr.GoroutineIDs()
+			if len(ids) == 0 {
+				t.Fatal(`exp goroutines accumulated by the trace`)
+			}
+			for _, id := range ids {
+				g, ok := tr.Goroutine(id)
+				desc := descs[uint64(id)]
+				if !ok || desc == nil || g.Name != desc.Name || g.EndTime != desc.EndTime {
+					t.Fatalf(`exp goroutine %v to match %+v; got %+v`, id, desc, g)
+				}
+			}
+
+			// Goroutines alive when tracing started include the one starting it.
+			initial := tr.InitialGoroutines()
+			if len(initial) == 0 || initial[0].ID != 1 || initial[0].Initial != event.GRunnable {
+				t.Fatalf(`exp initial goroutines beginning with g 1; got %+v`, initial)
+			}
+			for _, g := range initial[1:] {
+				if g.Initial != event.GWaiting && g.Initial != event.GRunnable {
+					t.Fatalf(`exp initial goroutine to be waiting or runnable; got %+v`, g)
+				}
+			}
+
+			sum := tr.GC(evts)
+			if sum.PauseTime < 0 || sum.AssistTime < 0 || sum.SweepTime < 0 {
+				t.Fatalf(`exp positive GC totals; got %+v`, sum)
+			}
+			for _, c := range sum.Cycles {
+				if c.Duration() < 0 || c.PauseTime > c.Duration() && c.End != 0 {
+					t.Fatalf(`exp consistent GC cycle; got %+v`, c)
+				}
+			}
+
+			v, err := event.NewValidator(tf.Version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, evt := range evts {
+				if err := v.Visit(evt); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecoderValidator(t *testing.T) {
+	for _, tf := range traceList {
+		tf := tf
+		if tf.Version == event.Version3 && tf.Name == `net_http.trace` &&
+			strings.Contains(tf.Path, `godev`) {
+			// Lost the events creating some goroutines, so it can't be ordered.
+			continue
+		}
+		t.Run(tf.Path, func(t *testing.T) {
+			dec, evt := NewDecoder(bytes.NewReader(tf.Bytes())), new(event.Event)
+			o := event.NewOrderer(tf.Version)
+			for dec.More() {
+				evt.Reset()
+				if err := dec.Decode(evt); err != nil {
+					t.Fatal(err)
+				}
+				o.Push(evt)
+			}
+
+			v, err := event.NewValidator(tf.Version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for {
+				evt, err := o.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := v.Visit(evt); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecoderAllocs(t *testing.T) {
+	if testing.Short() {
+		t.Skip(`skipping allocation guard in short mode`)
+	}
+	data := traceList.ByVersion(event.Version1).ByName(`net_http.trace`)[0].Bytes()
+
+	var events, args, stacks float64
+	dec := NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var evt event.Event
+		if err := dec.Decode(&evt); err != nil {
+			t.Fatal(err)
+		}
+		events++
+		if len(evt.Args) > 0 {
+			args++
+		}
+		if evt.Type == event.EvStack {
+			stacks++
+		}
+	}
+
+	// Events given to Decode do not escape, so new events allocate only their
+	// arguments, which grow while decoding the frames of stacks, and reused
+	// events only while decoding the header.
+	fresh := testing.AllocsPerRun(1, func() {
+		dec := NewDecoder(bytes.NewReader(data))
+		for dec.More() {
+			evt := new(event.Event)
+			if err := dec.Decode(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	if max := args + 16*stacks; fresh > max {
+		t.Fatalf(`exp at most %v allocations decoding %v events; got %v`,
+			max, events, fresh)
+	}
+	reuse := testing.AllocsPerRun(1, func() {
+		dec, evt := NewDecoder(bytes.NewReader(data)), new(event.Event)
+		for dec.More() {
+			evt.Reset()
+			if err := dec.Decode(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	if reuse > 64 {
+		t.Fatalf(`exp at most 64 allocations decoding into a reused event; got %v`, reuse)
+	}
+
+	// Visiting allocates each Stack and the growth of the tables of the Trace.
+	traced := testing.AllocsPerRun(1, func() {
+		tr, err := event.NewTrace(event.Version1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, evt := NewDecoder(bytes.NewReader(data)), new(event.Event)
+		for dec.More() {
+			evt.Reset()
+			if err := dec.Decode(evt); err != nil {
+				t.Fatal(err)
+			}
+			if err := tr.Visit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	if max := stacks + 1024; traced > max {
+		t.Fatalf(`exp at most %v allocations visiting %v stacks; got %v`,
+			max, stacks, traced)
+	}
+}
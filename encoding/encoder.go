@@ -2,9 +2,11 @@ package encoding
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/cstockton/go-trace/event"
 )
@@ -14,44 +16,509 @@ import (
 // Events produced by the Encoder are always lexically correct, logical
 // consistency with runtime produced events is the responsibility of the
 // caller. It is included for testing systems that consume or parse trace
-// events.
+// events. An Encoder may only be used by multiple goroutines when created with
+// the Concurrent option.
 type Encoder struct {
 	w      *offsetWriter
+	dst    io.Writer
+	gz     *gzip.Writer
 	err    error
 	encode encodeFn
+	opts   *options
+	ver    event.Version
+	tab    tables
+	batch  batchState
+	strict *validator
+	stats  Stats
+	clock  *clock
+	order  *orderState
+	carry  *Carry
+	kept   event.Event
+	buf    bytes.Buffer
+	ends   []int
+	mu     sync.Mutex
 }
 
 // NewEncoder returns a new encoder that emits events to w in the latest version
-// of the Go trace format.
-func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w: &offsetWriter{w: w}}
+// of the Go trace format, unless another version is given with TargetVersion.
+// Options are retained across calls to Reset.
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	e := &Encoder{w: new(offsetWriter), opts: newOptions(opts)}
+	e.output(w)
+	return e
+}
+
+// output sets the stream events are written to, compressing it when configured
+// with the Gzip option.
+func (e *Encoder) output(w io.Writer) {
+	e.dst, e.w.w = w, w
+	if e.opts == nil || !e.opts.gzip {
+		return
+	}
+	if e.gz == nil {
+		gz, err := gzip.NewWriterLevel(w, e.opts.level)
+		if err != nil {
+			e.err = err
+			return
+		}
+		e.gz = gz
+	} else {
+		e.gz.Reset(w)
+	}
+	e.w.w = e.gz
 }
 
 // Err returns the first error that occurred during encoding, once an error
 // occurs all future calls to Err() will return the same value.
 func (e *Encoder) Err() error {
+	e.lock()
+	defer e.unlock()
 	return e.err
 }
 
-// Reset the Encoder for writing to w.
+// Reset the Encoder for writing to w, a new trace header is written before the
+// next event.
 func (e *Encoder) Reset(w io.Writer) {
-	e.err, e.w.off, e.w.w = nil, 0, w
+	e.lock()
+	defer e.unlock()
+	e.reset(w)
+}
+
+func (e *Encoder) reset(w io.Writer) {
+	e.err, e.w.off, e.w.err, e.encode = nil, 0, nil, nil
+	e.tab, e.batch, e.strict = tables{}, batchState{}, nil
+	e.stats = Stats{}
+	e.output(w)
+}
+
+// ResetVersion is like Reset but the trace written to w is in version v of the
+// Go trace format, replacing any version given with TargetVersion.
+func (e *Encoder) ResetVersion(w io.Writer, v event.Version) {
+	e.lock()
+	defer e.unlock()
+	if e.opts == nil {
+		e.opts = new(options)
+	}
+	e.opts.target = v
+	e.reset(w)
+}
+
+// ResetKeepState directs the output of the Encoder to w while continuing the
+// trace in progress, for services rotating output files. No header is written
+// and the string and stack tables, batch in progress and offsets carry over, so
+// the concatenated output of each writer forms a single trace. When configured
+// with Gzip the compressed stream of the prior writer is closed first, leaving
+// each writer with a complete gzip member.
+func (e *Encoder) ResetKeepState(w io.Writer) error {
+	e.lock()
+	defer e.unlock()
+	if err := e.drain(); err != nil {
+		return err
+	}
+	if err := e.resolve(); err != nil {
+		return err
+	}
+	if e.gz != nil {
+		if err := e.gz.Close(); err != nil {
+			e.err = err
+			return err
+		}
+	}
+	if err := e.flush(); err != nil {
+		return err
+	}
+	e.output(w)
+	return e.err
+}
+
+// Flush writes any output buffered by the Encoder to the underlying writer.
+// When configured with Gzip the pending compressed data is flushed, allowing a
+// reader to decompress every event emitted so far. If the underlying writer has
+// a Flush method, such as a *bufio.Writer, it is called last. A non-nil error
+// is permanent as with Emit.
+func (e *Encoder) Flush() error {
+	e.lock()
+	defer e.unlock()
+	return e.flush()
+}
+
+func (e *Encoder) flush() error {
+	if err := e.drain(); err != nil {
+		return err
+	}
+	if err := e.resolve(); err != nil {
+		return err
+	}
+	if e.gz != nil {
+		if err := e.gz.Flush(); err != nil {
+			e.err = err
+			return err
+		}
+	}
+	if f, ok := e.dst.(interface {
+		Flush() error
+	}); ok {
+		if err := f.Flush(); err != nil {
+			e.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes the Encoder and, when configured with Gzip, writes the gzip
+// footer. The underlying writer is not closed. Once closed all future calls to
+// Emit return an error until the Encoder is Reset.
+func (e *Encoder) Close() error {
+	e.lock()
+	defer e.unlock()
+	if e.err == errClosed {
+		return nil
+	}
+	if err := e.drain(); err != nil {
+		return err
+	}
+	if err := e.resolve(); err != nil {
+		return err
+	}
+	if e.gz != nil {
+		if err := e.gz.Close(); err != nil {
+			e.err = err
+			return err
+		}
+	}
+	if err := e.flush(); err != nil {
+		return err
+	}
+	e.err = errClosed
+	return nil
 }
 
+var errClosed = errors.New(`encoder is closed`)
+
+// ErrInvalidEvent is matched by errors.Is for errors caused by an event given
+// to an Encoder rather than the underlying writer, such as an event with too
+// few arguments or a *SchemaError when configured with Strict.
+var ErrInvalidEvent = errors.New(`invalid event`)
+
+// EncodeError is returned from an Encoder when an event could not be written.
+type EncodeError struct {
+
+	// Off is the offset in the output stream the event would have begun at.
+	Off int
+
+	// Type is the type of the event, it is EvNone for the trace header.
+	Type event.Type
+
+	// Err is the cause of the failure. It is the error returned from the
+	// underlying writer, such as io.ErrShortWrite, or an error matching
+	// ErrInvalidEvent when the event could not be encoded.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf(`%v at 0x%x`, e.Err, e.Off)
+}
+
+// Unwrap returns the cause of the error.
+func (e *EncodeError) Unwrap() error {
+	return e.Err
+}
+
+// invalidEventError wraps an error from encoding an event so it matches
+// ErrInvalidEvent without altering its message.
+type invalidEventError struct {
+	err error
+}
+
+func (e *invalidEventError) Error() string        { return e.err.Error() }
+func (e *invalidEventError) Unwrap() error        { return e.err }
+func (e *invalidEventError) Is(target error) bool { return target == ErrInvalidEvent }
+
 // Emit writes a single event to the the output stream. If Emit returns a
 // non-nil error then failure is permanent and all future calls will immediately
 // return the same error.
 func (e *Encoder) Emit(evt *event.Event) error {
+	e.lock()
+	defer e.unlock()
+	_, err := e.emit(evt)
+	return err
+}
+
+// EmitOffset is like Emit but also returns the offset of the first byte of the
+// event within the output stream, allowing an index of the trace to be built
+// while it is written. An event dropped by a Hook has the offset of the next
+// event written, which carries its timestamp delta.
+//
+// The offset is -1 for events buffered by the SortWindow option, including the
+// EvBatch events it drops, as their position is not known until the window is
+// written. Offsets of the events written by Flush or Close are not reported, an
+// index of a sorted trace should be built by decoding its output instead.
+func (e *Encoder) EmitOffset(evt *event.Event) (int64, error) {
+	e.lock()
+	defer e.unlock()
+	off, err := e.emit(evt)
+	return int64(off), err
+}
+
+// Offset returns the number of bytes written to the output stream, which is the
+// offset the next event will begin at once the trace header has been written.
+func (e *Encoder) Offset() int64 {
+	e.lock()
+	defer e.unlock()
+	return int64(e.w.Off())
+}
+
+func (e *Encoder) emit(evt *event.Event) (int, error) {
 	if e.encode == nil {
 		e.init()
 	}
 
 	// Once an error occurs the encoder may no longer be used.
 	if e.err != nil {
-		return e.err
+		return 0, e.err
 	}
-	if err := e.encode(e.w, evt); err != nil {
-		e.err = fmt.Errorf(`%v at 0x%x`, err, e.w.Off())
+	if e.opts != nil {
+		for _, fn := range e.opts.hooks {
+			out, err := fn(evt)
+			if err != nil {
+				e.err = &EncodeError{Off: e.w.Off(), Type: evt.Type, Err: err}
+				return 0, e.err
+			}
+			if out == nil {
+				return e.drop(evt), nil
+			}
+			evt = out
+		}
+		if e.opts.freq != 0 && evt.Type == event.EvFrequency {
+			// The frequency was declared by the preamble.
+			return e.drop(evt), nil
+		}
+	}
+	if e.carry.Pending() {
+		evt = e.keep(evt)
+	}
+	if e.order != nil && ordered(evt) {
+		if evt.Type == event.EvBatch {
+			// Batches are written as needed by writeOrdered.
+			return -1, nil
+		}
+		e.order.push(evt)
+		for e.order.Len() > e.order.window {
+			if err := e.writeOrdered(e.order.pop()); err != nil {
+				return 0, err
+			}
+		}
+		return -1, nil
+	}
+	if e.order != nil && evt.Type == event.EvFrequency {
+		// The frequency begins the trace footer, which follows all events.
+		if err := e.drain(); err != nil {
+			return 0, err
+		}
+	} else if e.order != nil && !e.batch.open {
+		// Parsers require every event to follow a batch, so declarations are
+		// held until writeOrdered begins the first one.
+		e.order.hold(evt)
+		return -1, nil
+	}
+	return e.write(evt)
+}
+
+// drop records the deltas of evt which will not be written, returning the offset
+// of the next event. The timestamps of a batch begun by BeginBatch or formed by
+// SortWindow are computed from the Ts field of each event, so nothing is
+// carried.
+func (e *Encoder) drop(evt *event.Event) int {
+	if e.order != nil {
+		return -1
+	}
+	if !e.batch.open {
+		e.carry.Drop(evt)
+	}
+	return e.w.Off()
+}
+
+// keep returns evt with the deltas of the events dropped before it, copying it
+// so the events given to Emit are not modified.
+func (e *Encoder) keep(evt *event.Event) *event.Event {
+	if evt.Type == event.EvBatch {
+		e.carry.Keep(evt)
+		return evt
+	}
+	out := &e.kept
+	args := append(out.Args[:0], evt.Args...)
+	*out = *evt
+	out.Args = args
+	e.carry.Keep(out)
+	return out
+}
+
+// drain writes all events buffered by SortWindow, along with any declarations
+// held when no timestamped event was given to begin a batch.
+func (e *Encoder) drain() error {
+	for e.order != nil && e.order.Len() > 0 && e.err == nil {
+		e.writeOrdered(e.order.pop())
+	}
+	if e.order != nil && e.err == nil {
+		return e.release()
+	}
+	return e.err
+}
+
+// release writes the declarations held by the SortWindow.
+func (e *Encoder) release() error {
+	decl := e.order.decl
+	e.order.decl = nil
+	for i := range decl {
+		if _, err := e.write(&decl[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeOrdered writes an event from the SortWindow, beginning a new batch when
+// it belongs to another P or precedes the prior event of the batch.
+func (e *Encoder) writeOrdered(evt *event.Event) error {
+	if b := &e.batch; !b.open || b.p != evt.P || evt.Ts < b.ts {
+		_, err := e.write(&event.Event{
+			Type: event.EvBatch, Args: []uint64{uint64(evt.P), uint64(evt.Ts)}})
+		if err != nil {
+			return err
+		}
+		b.open, b.p, b.ts = true, evt.P, evt.Ts
+		if err := e.release(); err != nil {
+			return err
+		}
+	}
+	_, err := e.write(evt)
+	return err
+}
+
+// write encodes evt once it has passed through any hooks and the SortWindow.
+func (e *Encoder) write(evt *event.Event) (int, error) {
+	off := e.w.Off()
+	if e.opts != nil && e.opts.strict {
+		if err := e.validate(evt); err != nil {
+			e.err = &EncodeError{Off: off, Type: evt.Type, Err: err}
+			return 0, e.err
+		}
+	}
+	out, err := e.batch.visit(evt)
+	if err == nil && e.clock != nil {
+		out, err = e.clock.visit(out)
+	}
+	if err == nil {
+		err = e.encode(e.w, out)
+	}
+	if err != nil {
+		if e.w.err == nil {
+			err = &invalidEventError{err}
+		}
+		e.err = &EncodeError{Off: off, Type: evt.Type, Err: err}
+		return 0, e.err
+	}
+	e.tab.visit(evt)
+	e.stats.visit(evt, e.w.Off()-off)
+	return off, nil
+}
+
+// EmitAll writes evts to the output stream with a single call to the
+// underlying writer, returning the number of events written before any error.
+// Like Emit a non-nil error is permanent, events preceding the failed event
+// are still written.
+func (e *Encoder) EmitAll(evts []*event.Event) (n int, err error) {
+	e.lock()
+	defer e.unlock()
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	// Events are encoded into buf while recording where each one ends, so the
+	// number of events written is known should the write fall short.
+	w, off := e.w.w, e.w.off
+	e.buf.Reset()
+	e.ends = e.ends[:0]
+	e.w.w = &e.buf
+	for _, evt := range evts {
+		if _, err = e.emit(evt); err != nil {
+			break
+		}
+		e.ends = append(e.ends, e.w.off-off)
+	}
+	e.w.w, e.w.off = w, off
+	if len(e.ends) > 0 {
+		// Drop any partial encoding of a failed event.
+		e.buf.Truncate(e.ends[len(e.ends)-1])
+	} else {
+		e.buf.Reset()
+	}
+
+	wrote, werr := e.w.Write(e.buf.Bytes())
+	for n < len(e.ends) && e.ends[n] <= wrote {
+		n++
+	}
+	if werr != nil {
+		werr := &EncodeError{Off: off, Err: werr}
+		if n > 0 {
+			werr.Off += e.ends[n-1]
+		}
+		if n < len(evts) {
+			werr.Type = evts[n].Type
+		}
+		e.err = werr
+		return n, e.err
+	}
+	return n, err
+}
+
+// lock acquires the Encoder for the duration of a call when it was created with
+// the Concurrent option.
+func (e *Encoder) lock() {
+	if e.opts != nil && e.opts.concurrent {
+		e.mu.Lock()
+	}
+}
+
+func (e *Encoder) unlock() {
+	if e.opts != nil && e.opts.concurrent {
+		e.mu.Unlock()
+	}
+}
+
+// validate checks evt against its schema, see Strict.
+func (e *Encoder) validate(evt *event.Event) error {
+	if e.strict == nil {
+		// Events are given in the layout of the latest version, which is shared
+		// by all versions after Version1, unless written with Fidelity. Stacks
+		// may be declared after they are referenced as they are by the runtime.
+		ver, argoff := e.ver, 0
+		switch {
+		case ver == event.Version1 && e.opts.fidelity:
+			argoff = 1
+		case ver < event.Version2:
+			ver = event.Version2
+		}
+		e.strict = newValidator(ver, argoff, true)
+	}
+
+	// Errors report the offset of the event in the output stream.
+	at := *evt
+	at.Off = e.w.Off()
+	return e.strict.validate(&at)
+}
+
+// resolve returns an error for the first event written which referred to a
+// stack that was never declared, see Strict.
+func (e *Encoder) resolve() error {
+	if e.strict == nil {
+		return nil
+	}
+	if err := e.strict.unresolved(); err != nil {
+		serr := err.(*SchemaError)
+		e.err = &EncodeError{Off: serr.Off, Type: serr.Type, Err: serr}
 		return e.err
 	}
 	return nil
@@ -67,7 +534,41 @@ func (e *Encoder) init() {
 		e.err = errors.New(`possible unsafe usage from multiple goroutines`)
 		return
 	}
-	e.encode, e.err = encodeInit(e.w, event.Latest)
+	e.ver = event.Latest
+	if e.opts != nil && e.opts.target != 0 {
+		e.ver = e.opts.target
+	}
+	e.clock, e.order = newClock(e.opts), newOrderState(e.opts)
+	e.carry = new(Carry)
+	encode, err := encodeInit(e.w, e.ver)
+	if err == nil && e.ver == event.Version1 && e.opts != nil && e.opts.fidelity {
+		// Events are given in the Version1 layout they were decoded in.
+		encode, e.carry = encodeVersion1, NewCarry(event.Version1)
+	}
+	if err != nil {
+		if e.w.err == nil {
+			err = &invalidEventError{err}
+		}
+		e.encode, e.err = encode, &EncodeError{Err: err}
+		return
+	}
+	e.encode = encode
+	e.err = e.preamble()
+}
+
+// preamble writes the events configured to follow the trace header.
+func (e *Encoder) preamble() error {
+	if e.opts == nil || e.opts.freq == 0 {
+		return nil
+	}
+
+	off := e.w.Off()
+	evt := &event.Event{Type: event.EvFrequency, Args: []uint64{e.opts.freq}}
+	if err := e.encode(e.w, evt); err != nil {
+		return &EncodeError{Off: off, Type: evt.Type, Err: err}
+	}
+	e.stats.visit(evt, e.w.Off()-off)
+	return nil
 }
 
 type writer interface {
@@ -75,9 +576,12 @@ type writer interface {
 	io.ByteWriter
 }
 
+// offsetWriter counts the bytes written to w, err holds the first error from w
+// to distinguish failed writes from invalid events.
 type offsetWriter struct {
 	w   io.Writer
 	off int
+	err error
 	buf [1]byte
 }
 
@@ -88,26 +592,132 @@ func (r *offsetWriter) Off() int {
 func (r *offsetWriter) Write(p []byte) (n int, err error) {
 	n, err = r.w.Write(p)
 	r.off += n
+	if err == nil && n != len(p) {
+		err = io.ErrShortWrite
+	}
+	if err != nil && r.err == nil {
+		r.err = err
+	}
 	return
 }
 
 func (r *offsetWriter) WriteByte(b byte) (err error) {
 	r.buf[0] = b
-	n, err := r.w.Write(r.buf[:])
-	r.off += n
+	_, err = r.Write(r.buf[:])
 	return err
 }
 
 type encodeFn func(w writer, evt *event.Event) error
 
-// encodeInit will simply send the header and return the Latest event fn.
+// encodeInit will send the header and return the event fn for the given version.
 func encodeInit(w writer, v event.Version) (encodeFn, error) {
 	if err := encodeHeader(w, v); err != nil {
 		return nil, err
 	}
+	if v == event.Version1 {
+		return new(downgrader).encodeVersion1, nil
+	}
+	if v != event.Latest {
+		return encodeSince(v), nil
+	}
 	return encodeEvent, nil
 }
 
+// encodeSince returns an encodeFn rejecting events newer than v.
+func encodeSince(v event.Version) encodeFn {
+	return func(w writer, evt *event.Event) error {
+		if evt.Type.Valid() && !v.Supports(evt.Type) {
+			return fmt.Errorf(`version %v does not support event %v`, v, evt.Type)
+		}
+		return encodeEvent(w, evt)
+	}
+}
+
+// downgrader translates events from the latest layout to the Version1 layout,
+// where events carry a sequence delta before their timestamp and sequence
+// arguments of later versions do not exist.
+type downgrader struct {
+	seq, last uint64
+	evt       event.Event
+}
+
+func (d *downgrader) encodeVersion1(w writer, evt *event.Event) error {
+	if !evt.Type.Valid() {
+		return errors.New(`invalid trace event type`)
+	}
+	if evt.Type.Since() > event.Version1 {
+		return fmt.Errorf(
+			`version %v does not support event %v`, event.Version1, evt.Type)
+	}
+
+	out := &d.evt
+	out.Type, out.Args = evt.Type, out.Args[:0]
+	switch evt.Type {
+	case event.EvBatch:
+		// The batch sequence seeds the deltas of the events which follow it.
+		if len(evt.Args) != 2 {
+			return errors.New(`expected 2 arguments for batch event`)
+		}
+		d.seq++
+		d.last = d.seq
+		out.Args = append(out.Args, evt.Args[0], d.seq, evt.Args[1])
+	case event.EvFrequency, event.EvTimerGoroutine:
+		// Followed by an argument which is unused.
+		out.Args = append(append(out.Args, evt.Args...), 0)
+	case event.EvStack:
+		// Frames contain only the PC.
+		if len(evt.Args) < 2 || uint64(len(evt.Args)-2) != evt.Args[1]*4 {
+			return encodeEventSized(w, evt)
+		}
+		out.Args = append(out.Args, evt.Args[0], evt.Args[1])
+		for i := 2; i < len(evt.Args); i += 4 {
+			out.Args = append(out.Args, evt.Args[i])
+		}
+		return encodeEventSized(w, out)
+	default:
+		d.seq++
+		out.Args = append(out.Args, d.seq-d.last)
+		d.last = d.seq
+		names := evt.Type.Args()
+		for i, arg := range evt.Args {
+			if i < len(names) && (names[i] == event.ArgSequence ||
+				names[i] == event.ArgSequenceGC) {
+				continue
+			}
+			out.Args = append(out.Args, arg)
+		}
+	}
+
+	return encodeVersion1Args(w, out)
+}
+
+// encodeVersion1 writes an event already in the Version1 layout as decoded from
+// a Version1 trace, see Fidelity.
+func encodeVersion1(w writer, evt *event.Event) error {
+	if !evt.Type.Valid() {
+		return errors.New(`invalid trace event type`)
+	}
+	if evt.Type.Since() > event.Version1 {
+		return fmt.Errorf(
+			`version %v does not support event %v`, event.Version1, evt.Type)
+	}
+	if evt.Type == event.EvStack {
+		return encodeEventSized(w, evt)
+	}
+	return encodeVersion1Args(w, evt)
+}
+
+// encodeVersion1Args writes evt to w with the Version1 arg count.
+func encodeVersion1Args(w writer, evt *event.Event) error {
+	// The inline arg count of Version1 excludes the leading argument.
+	if n := len(evt.Args); n > 4 {
+		return encodeEventSized(w, evt)
+	} else if n < 2 {
+		return fmt.Errorf(`expected at least 2 arguments for event %v`, evt.Type)
+	}
+	return encodeEventCount(w, evt, len(evt.Args)-2)
+}
+
 // encodeHeader will encode a valid trace version object into a well formed
 // trace header.
 func encodeHeader(w io.Writer, v event.Version) (err error) {
@@ -121,6 +731,10 @@ func encodeHeader(w io.Writer, v event.Version) (err error) {
 		n, err = w.Write([]byte("go 1.8 trace\x00\x00\x00\x00"))
 	case event.Version4:
 		n, err = w.Write([]byte("go 1.9 trace\x00\x00\x00\x00"))
+	case event.Version5:
+		n, err = w.Write([]byte("go 1.11 trace\x00\x00\x00"))
+	case event.Version6:
+		n, err = w.Write([]byte("go 1.19 trace\x00\x00\x00"))
 	default:
 		err = errors.New(`trace header version was invalid`)
 	}
@@ -148,6 +762,8 @@ func encodeEvent(w writer, evt *event.Event) error {
 	switch {
 	case evt.Type == event.EvString:
 		return encodeEventString(w, evt)
+	case evt.Type == event.EvUserLog:
+		return encodeEventLog(w, evt)
 	case len(evt.Args) < 4:
 		return encodeEventInline(w, evt)
 	default:
@@ -161,8 +777,13 @@ func encodeEventInline(w writer, evt *event.Event) error {
 		return errors.New(`expected at least 1 argument for event`)
 	}
 
-	typ, nargs := byte(evt.Type), byte(len(evt.Args)-1)
-	if err := w.WriteByte(typ | nargs<<traceArgCountShift); err != nil {
+	return encodeEventCount(w, evt, len(evt.Args)-1)
+}
+
+// encodeEventCount will write evt to w with the given inline arg count.
+func encodeEventCount(w writer, evt *event.Event, nargs int) error {
+	typ := byte(evt.Type)
+	if err := w.WriteByte(typ | byte(nargs)<<traceArgCountShift); err != nil {
 		return err
 	}
 	for _, arg := range evt.Args {
@@ -178,7 +799,11 @@ func encodeEventArgs(w writer, evt *event.Event) error {
 	if len(evt.Args) < 4 {
 		return errors.New(`expected 4 or more arguments arguments for event`)
 	}
+	return encodeEventSized(w, evt)
+}
 
+// encodeEventSized will write evt to w with args prefixed by their byte length.
+func encodeEventSized(w writer, evt *event.Event) error {
 	var buf bytes.Buffer
 	for _, arg := range evt.Args {
 		encodeUleb(&buf, arg)
@@ -215,7 +840,20 @@ func encodeEventString(w writer, evt *event.Event) error {
 	if err := encodeUleb(w, evt.Args[0]); err != nil {
 		return err
 	}
+	return encodeEventData(w, evt)
+}
+
+// encodeEventLog will write a user log event to w, the args are prefixed with
+// their byte length and followed by the logged value.
+func encodeEventLog(w writer, evt *event.Event) error {
+	if err := encodeEventSized(w, evt); err != nil {
+		return err
+	}
+	return encodeEventData(w, evt)
+}
 
+// encodeEventData will write the length of evt.Data followed by its bytes to w.
+func encodeEventData(w writer, evt *event.Event) error {
 	size := len(evt.Data)
 	if err := encodeUleb(w, uint64(size)); err != nil {
 		return err
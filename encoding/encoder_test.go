@@ -1,10 +1,15 @@
 package encoding
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
+	"reflect"
 	"testing"
 
 	"github.com/cstockton/go-trace/event"
@@ -89,7 +94,9 @@ func TestEncodeHeader(t *testing.T) {
 		t.Fatal(err)
 	}
 	t.Run(`Propagation`, func(t *testing.T) {
-		for _, v := range []event.Version{event.Version1, event.Version2, event.Version3, 0} {
+		for _, v := range []event.Version{
+			event.Version1, event.Version2, event.Version3, event.Version4, event.Version5,
+			event.Version6, 0} {
 			w := &rwLimiter{w: ioutil.Discard, n: 0}
 			if err := encodeHeader(w, v); err == nil {
 				t.Fatal(`exp non-nil err for writer error`)
@@ -98,6 +105,496 @@ func TestEncodeHeader(t *testing.T) {
 	})
 }
 
+func TestEncoderTargetVersion(t *testing.T) {
+	evts := []*event.Event{
+		{Type: event.EvBatch, Args: []uint64{1, 100}},
+		{Type: event.EvGoCreate, Args: []uint64{5, 2, 3, 1}},
+		{Type: event.EvGoStart, Args: []uint64{10, 2, 1}},
+		{Type: event.EvGoUnblock, Args: []uint64{1, 3, 1, 1}},
+		{Type: event.EvGoEnd, Args: []uint64{4}},
+		{Type: event.EvFrequency, Args: []uint64{1000}},
+		{Type: event.EvStack, Args: []uint64{1, 1, 0x10, 0, 0, 10}},
+	}
+	exp := map[event.Version][][]uint64{
+		event.Version1: {
+			{1, 1, 100},
+			{1, 5, 2, 3, 1},
+			{1, 10, 2},
+			{1, 1, 3, 1},
+			{1, 4},
+			{1000, 0},
+			{1, 1, 0x10},
+		},
+	}
+	for v := event.Version1; v <= event.Latest; v++ {
+		t.Run(v.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf, TargetVersion(v))
+			for _, evt := range evts {
+				if err := enc.Emit(evt); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			dec, evt := NewDecoder(&buf), new(event.Event)
+			if got, err := dec.Version(); err != nil || got != v {
+				t.Fatalf(`exp version %v; got %v (err %v)`, v, got, err)
+			}
+			var ts []int64
+			for i := 0; dec.More(); i++ {
+				evt.Reset()
+				if err := dec.Decode(evt); err != nil {
+					t.Fatal(err)
+				}
+				want := evts[i].Args
+				if args, ok := exp[v]; ok {
+					want = args[i]
+				}
+				if !reflect.DeepEqual(want, evt.Args) {
+					t.Fatalf(`exp args %v for %v; got %v`, want, evt.Type, evt.Args)
+				}
+				ts = append(ts, evt.Ts)
+			}
+			if !reflect.DeepEqual(ts, []int64{100, 105, 115, 116, 120, 0, 0}) {
+				t.Fatalf(`exp timestamps to be preserved; got %v`, ts)
+			}
+		})
+	}
+	t.Run(`Unsupported`, func(t *testing.T) {
+		for _, test := range []struct {
+			v   event.Version
+			typ event.Type
+		}{
+			{event.Version1, event.EvString},
+			{event.Version2, event.EvGoStartLabel},
+			{event.Version3, event.EvGCMarkAssistStart},
+		} {
+			enc := NewEncoder(ioutil.Discard, TargetVersion(test.v))
+			err := enc.Emit(&event.Event{Type: test.typ, Args: []uint64{1, 1}})
+			if err == nil {
+				t.Fatalf(`exp non-nil err for %v in %v`, test.typ, test.v)
+			}
+		}
+		enc := NewEncoder(ioutil.Discard, TargetVersion(event.Version(99)))
+		if err := enc.Emit(&event.Event{Type: event.EvGoEnd, Args: []uint64{1}}); err == nil {
+			t.Fatal(`exp non-nil err for invalid version`)
+		}
+	})
+}
+
+func TestEncoderInternString(t *testing.T) {
+	decodeStrings := func(t *testing.T, r io.Reader) map[uint64]string {
+		tr, err := event.NewTrace(event.Latest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := NewDecoder(r)
+		if err := dec.VisitAll(tr); err != nil {
+			t.Fatal(err)
+		}
+		return tr.Strings
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, test := range []struct {
+		s   string
+		exp uint64
+	}{
+		{`a`, 1}, {`b`, 2}, {`a`, 1}, {``, 0}, {`b`, 2},
+	} {
+		id, err := enc.InternString(test.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != test.exp {
+			t.Fatalf(`exp id %v for %q; got %v`, test.exp, test.s, id)
+		}
+	}
+	if exp, got := map[uint64]string{1: `a`, 2: `b`}, decodeStrings(t, &buf); !reflect.DeepEqual(exp, got) {
+		t.Fatalf(`exp strings %v; got %v`, exp, got)
+	}
+
+	t.Run(`Emit`, func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		err := enc.Emit(&event.Event{
+			Type: event.EvString, Args: []uint64{5}, Data: []byte(`main.go`)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id, err := enc.InternString(`main.go`); err != nil || id != 5 {
+			t.Fatalf(`exp id 5 for emitted string; got %v (err %v)`, id, err)
+		}
+		if id, err := enc.InternString(`main.main`); err != nil || id != 6 {
+			t.Fatalf(`exp id 6 after emitted string; got %v (err %v)`, id, err)
+		}
+		if got := decodeStrings(t, &buf); len(got) != 2 {
+			t.Fatalf(`exp 2 strings; got %v`, got)
+		}
+	})
+	t.Run(`Reset`, func(t *testing.T) {
+		var buf bytes.Buffer
+		enc.Reset(&buf)
+		if id, err := enc.InternString(`b`); err != nil || id != 1 {
+			t.Fatalf(`exp id 1 after Reset; got %v (err %v)`, id, err)
+		}
+		if got := decodeStrings(t, &buf); got[1] != `b` {
+			t.Fatalf(`exp string to be emitted after Reset; got %v`, got)
+		}
+	})
+	t.Run(`Unsupported`, func(t *testing.T) {
+		enc := NewEncoder(ioutil.Discard, TargetVersion(event.Version1))
+		if _, err := enc.InternString(`a`); err == nil {
+			t.Fatal(`exp non-nil err for version without strings`)
+		}
+		if err := enc.Err(); err != nil {
+			t.Fatalf(`exp encoder to remain usable; got %v`, err)
+		}
+	})
+}
+
+func TestEncoderEmitStack(t *testing.T) {
+	main := event.Stack{
+		event.NewFrame(0x10, `main.main`, `main.go`, 10),
+		event.NewFrame(0x20, `runtime.main`, `proc.go`, 20),
+	}
+	other := event.Stack{
+		event.NewFrame(0x30, `main.work`, `main.go`, 30),
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, test := range []struct {
+		stk event.Stack
+		exp uint64
+	}{
+		{main, 1}, {other, 2}, {main, 1}, {nil, 0}, {main[:1], 3},
+	} {
+		id, err := enc.EmitStack(test.stk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != test.exp {
+			t.Fatalf(`exp id %v for %v; got %v`, test.exp, test.stk, id)
+		}
+	}
+
+	tr, err := event.NewTrace(event.Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewDecoder(&buf).VisitAll(tr); err != nil {
+		t.Fatal(err)
+	}
+	if exp, got := 3, len(tr.Stacks); exp != got {
+		t.Fatalf(`exp %v stacks; got %v`, exp, got)
+	}
+	if exp, got := 5, len(tr.Strings); exp != got {
+		t.Fatalf(`exp %v strings; got %v`, exp, got)
+	}
+	for i, f := range tr.Stacks[1] {
+		exp := main[i]
+		if f.PC() != exp.PC() || f.Func() != exp.Func() ||
+			f.File() != exp.File() || f.Line() != exp.Line() {
+			t.Fatalf(`exp frame %v; got %v`, exp, f)
+		}
+	}
+
+	t.Run(`Version1`, func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf, TargetVersion(event.Version1))
+		if id, err := enc.EmitStack(main); err != nil || id != 1 {
+			t.Fatalf(`exp id 1; got %v (err %v)`, id, err)
+		}
+
+		evt := new(event.Event)
+		if err := NewDecoder(&buf).Decode(evt); err != nil {
+			t.Fatal(err)
+		}
+		if exp := []uint64{1, 2, 0x10, 0x20}; !reflect.DeepEqual(exp, evt.Args) {
+			t.Fatalf(`exp args %v; got %v`, exp, evt.Args)
+		}
+	})
+}
+
+func TestEncoderBatch(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	emit := func(evt *event.Event) {
+		if err := enc.Emit(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.BeginBatch(1, 100); err != nil {
+		t.Fatal(err)
+	}
+	start := &event.Event{Type: event.EvGoStart, Args: []uint64{0, 7, 0}, Ts: 105}
+	emit(start)
+	emit(&event.Event{Type: event.EvGoSched, Args: []uint64{0, 0}, Ts: 115})
+	if err := enc.BeginBatch(-1, 90); err != nil {
+		t.Fatal(err)
+	}
+	emit(&event.Event{Type: event.EvGCStart, Args: []uint64{0, 1, 0}, Ts: 95})
+	if err := enc.EndBatch(); err != nil {
+		t.Fatal(err)
+	}
+	emit(&event.Event{Type: event.EvFrequency, Args: []uint64{1000}})
+	if start.Args[0] != 0 {
+		t.Fatal(`exp events given to Emit to be unmodified`)
+	}
+
+	exp := []struct{ p, ts int64 }{{1, 100}, {1, 105}, {1, 115}, {-1, 90}, {-1, 95}}
+	dec, evt := NewDecoder(&buf), new(event.Event)
+	for i := 0; i < len(exp); i++ {
+		evt.Reset()
+		if err := dec.Decode(evt); err != nil {
+			t.Fatal(err)
+		}
+		if evt.P != exp[i].p || evt.Ts != exp[i].ts {
+			t.Fatalf(`exp P %v Ts %v for %v; got P %v Ts %v`,
+				exp[i].p, exp[i].ts, evt, evt.P, evt.Ts)
+		}
+	}
+
+	t.Run(`Errors`, func(t *testing.T) {
+		enc := NewEncoder(ioutil.Discard)
+		if err := enc.EndBatch(); err == nil {
+			t.Fatal(`exp non-nil err for EndBatch without a batch`)
+		}
+		if err := enc.BeginBatch(0, -1); err == nil {
+			t.Fatal(`exp non-nil err for negative timestamp`)
+		}
+		if err := enc.BeginBatch(0, 100); err != nil {
+			t.Fatal(err)
+		}
+		err := enc.Emit(&event.Event{Type: event.EvGoEnd, Args: []uint64{0}, Ts: 50})
+		if err == nil {
+			t.Fatal(`exp non-nil err for event preceding its batch`)
+		}
+	})
+	t.Run(`Transcode`, func(t *testing.T) {
+		data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+		dec, evt := NewDecoder(bytes.NewReader(data)), new(event.Event)
+
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf, TargetVersion(event.Version4))
+		for dec.More() {
+			evt.Reset()
+			if err := dec.Decode(evt); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			if evt.Type == event.EvBatch {
+				err = enc.BeginBatch(int(evt.P), evt.Ts)
+			} else {
+				err = enc.Emit(evt)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(data, buf.Bytes()) {
+			t.Fatal(`exp batches to be re-encoded identically`)
+		}
+	})
+}
+
+type shortWriter struct {
+	n, calls int
+	buf      bytes.Buffer
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	w.calls++
+	if len(p) > w.n {
+		p = p[:w.n]
+	}
+	w.n -= len(p)
+	return w.buf.Write(p)
+}
+
+func TestEncoderEmitAll(t *testing.T) {
+	data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+	dec := NewDecoder(bytes.NewReader(data))
+	var evts []*event.Event
+	for dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+
+	w := &shortWriter{n: len(data)}
+	enc := NewEncoder(w, TargetVersion(event.Version4))
+	n, err := enc.EmitAll(evts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(evts) {
+		t.Fatalf(`exp %v events written; got %v`, len(evts), n)
+	}
+	if w.calls != 1 {
+		t.Fatalf(`exp a single write; got %v`, w.calls)
+	}
+	if !bytes.Equal(data, w.buf.Bytes()) {
+		t.Fatal(`exp events to be re-encoded identically`)
+	}
+
+	t.Run(`ShortWrite`, func(t *testing.T) {
+		w := &shortWriter{n: 16 + 3}
+		enc := NewEncoder(w)
+		n, err := enc.EmitAll([]*event.Event{
+			{Type: event.EvBatch, Args: []uint64{0, 1}},
+			{Type: event.EvBatch, Args: []uint64{0, 2}},
+		})
+		if err == nil {
+			t.Fatal(`exp non-nil err for short write`)
+		}
+		if n != 1 {
+			t.Fatalf(`exp 1 event written; got %v`, n)
+		}
+		if off := enc.w.Off(); off != 19 {
+			t.Fatalf(`exp offset 19; got %v`, off)
+		}
+		if _, err2 := enc.EmitAll(nil); err2 != err {
+			t.Fatalf(`exp err %v; got %v`, err, err2)
+		}
+	})
+	t.Run(`InvalidEvent`, func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		n, err := enc.EmitAll([]*event.Event{
+			{Type: event.EvBatch, Args: []uint64{0, 1}},
+			{Type: event.EvBatch},
+			{Type: event.EvBatch, Args: []uint64{0, 2}},
+		})
+		if err == nil {
+			t.Fatal(`exp non-nil err for invalid event`)
+		}
+		if n != 1 {
+			t.Fatalf(`exp 1 event written; got %v`, n)
+		}
+		if buf.Len() != 19 {
+			t.Fatalf(`exp only the preceding event to be written; got %v bytes`,
+				buf.Len())
+		}
+	})
+}
+
+func TestEncoderStrict(t *testing.T) {
+	evt := func(typ event.Type, args ...uint64) *event.Event {
+		return &event.Event{Type: typ, Args: args}
+	}
+	t.Run(`Valid`, func(t *testing.T) {
+		for _, v := range []event.Version{event.Version1, event.Version4, event.Latest} {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf, Strict(), TargetVersion(v))
+			stk, err := enc.EmitStack(event.Stack{event.NewFrame(1, `main.main`, `main.go`, 1)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The kind argument is emitted from Go 1.10.
+			stw := evt(event.EvGCSTWStart, 1)
+			if v >= event.Version5 {
+				stw.Args = append(stw.Args, 0)
+			}
+			for _, e := range []*event.Event{
+				evt(event.EvBatch, 0, 100),
+				evt(event.EvGoCreate, 1, 2, stk, stk),
+				evt(event.EvGoStart, 1, 2, 1),
+				stw,
+				evt(event.EvFrequency, 1000),
+			} {
+				if err := enc.Emit(e); err != nil {
+					t.Fatalf(`exp nil err for %v in %v; got %v`, e, v, err)
+				}
+			}
+			if err := NewDecoder(&buf, Strict()).VisitAll(new(countVisitor)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	tests := []struct {
+		name string
+		evts []*event.Event
+		exp  string
+	}{
+		{`ArgCount`, []*event.Event{
+			evt(event.EvGoStart, 1)},
+			`expected 3 arguments; got 1`},
+		{`FrameString`, []*event.Event{
+			evt(event.EvStack, 1, 1, 0x1, 3, 0, 10)},
+			`frame string id 3 was not declared`},
+		{`LabelString`, []*event.Event{
+			evt(event.EvGoStartLabel, 1, 2, 3, 4)},
+			`label string id 4 was not declared`},
+		{`DuplicateStack`, []*event.Event{
+			evt(event.EvStack, 1, 0), evt(event.EvStack, 1, 0)},
+			`stack id 1 was already declared`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf, Strict())
+
+			var err error
+			for _, e := range test.evts {
+				if err = enc.Emit(e); err != nil {
+					break
+				}
+			}
+			var eerr *EncodeError
+			if !errors.As(err, &eerr) || eerr.Off != buf.Len() {
+				t.Fatalf(`exp *EncodeError at 0x%x; got %v`, buf.Len(), err)
+			}
+			serr, ok := eerr.Err.(*SchemaError)
+			if !ok {
+				t.Fatalf(`exp *SchemaError; got %v`, eerr.Err)
+			}
+			if serr.Msg != test.exp {
+				t.Fatalf(`exp msg %q; got %q`, test.exp, serr.Msg)
+			}
+			if serr.Off != buf.Len() {
+				t.Fatalf(`exp offset 0x%x; got 0x%x`, buf.Len(), serr.Off)
+			}
+			if enc.Err() != err {
+				t.Fatal(`exp error to be permanent`)
+			}
+		})
+	}
+	t.Run(`UndeclaredStack`, func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf, Strict())
+		if err := enc.Emit(evt(event.EvBatch, 0, 100)); err != nil {
+			t.Fatal(err)
+		}
+		off, err := enc.EmitOffset(evt(event.EvGoSched, 1, 9))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range []*event.Event{
+			evt(event.EvGoSched, 1, 2),
+			evt(event.EvStack, 2, 0),
+		} {
+			if err := enc.Emit(e); err != nil {
+				t.Fatalf(`exp stacks to be declared after references; got %v`, err)
+			}
+		}
+
+		err = enc.Close()
+		var eerr *EncodeError
+		if !errors.As(err, &eerr) || int64(eerr.Off) != off || eerr.Type != event.EvGoSched {
+			t.Fatalf(`exp *EncodeError for GoSched at 0x%x; got %v`, off, err)
+		}
+		if serr, ok := eerr.Err.(*SchemaError); !ok || serr.Msg != `stack id 9 was never declared` {
+			t.Fatalf(`exp *SchemaError for undeclared stack 9; got %v`, eerr.Err)
+		}
+	})
+}
+
 func testEncodeFn(t *testing.T, fn encodeFn, evt *event.Event) {
 	sentinel := errors.New(`expected error`)
 	wrt := func(limit int, err error) writer {
@@ -152,3 +649,1044 @@ func TestOffsetWriter(t *testing.T) {
 		}
 	})
 }
+
+func TestTranscode(t *testing.T) {
+	data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+
+	var buf bytes.Buffer
+	if err := Transcode(&buf, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, buf.Bytes()) {
+		t.Fatal(`exp trace to be transcoded identically`)
+	}
+
+	t.Run(`Transform`, func(t *testing.T) {
+		var calls, kept int
+		var buf bytes.Buffer
+		err := Transcode(&buf, bytes.NewReader(data),
+			Transform(func(evt *event.Event) (bool, error) {
+				calls++
+				return evt.Type != event.EvGoSched, nil
+			}),
+			Transform(func(evt *event.Event) (bool, error) {
+				if evt.Type == event.EvGoSched {
+					t.Fatal(`exp dropped events to skip later transforms`)
+				}
+				kept++
+				return true, nil
+			}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if calls == 0 || kept == 0 {
+			t.Fatalf(`exp transforms to be called; got %v and %v`, calls, kept)
+		}
+
+		dec := NewDecoder(&buf)
+		if ver, err := dec.Version(); err != nil || ver != event.Version4 {
+			t.Fatalf(`exp Version4 output; got %v (err %v)`, ver, err)
+		}
+		var n int
+		err = dec.VisitAll(visitFunc(func(evt *event.Event) error {
+			if evt.Type == event.EvGoSched {
+				t.Fatal(`exp EvGoSched events to be dropped`)
+			}
+			n++
+			return nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != kept {
+			t.Fatalf(`exp %v events; got %v`, kept, n)
+		}
+	})
+	t.Run(`Carry`, func(t *testing.T) {
+		for _, tf := range traceList.ByName(`sync_atomic.trace`) {
+			src := tf.Bytes()
+			opts := []Option{Transform(func(evt *event.Event) (bool, error) {
+				return evt.Type != event.EvHeapAlloc, nil
+			})}
+			if tf.Version == event.Version1 {
+				opts = append(opts, Fidelity())
+			}
+			var buf bytes.Buffer
+			if err := Transcode(&buf, bytes.NewReader(src), opts...); err != nil {
+				t.Fatal(err)
+			}
+			assertRetained(t, src, buf.Bytes(), event.ByType(event.EvHeapAlloc))
+		}
+	})
+	t.Run(`Strict`, func(t *testing.T) {
+		for _, tf := range traceList {
+			opts := []Option{Strict()}
+			if tf.Version == event.Version1 {
+				opts = append(opts, Fidelity())
+			}
+			var buf bytes.Buffer
+			if err := Transcode(&buf, bytes.NewReader(tf.Bytes()), opts...); err != nil {
+				t.Fatalf(`exp nil err transcoding %v %v; got %v`, tf.Version, tf.Name, err)
+			}
+		}
+	})
+	t.Run(`TargetVersion`, func(t *testing.T) {
+		var buf bytes.Buffer
+		err := Transcode(&buf, bytes.NewReader(data), TargetVersion(event.Latest))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ver, err := NewDecoder(&buf).Version(); err != nil || ver != event.Latest {
+			t.Fatalf(`exp %v output; got %v (err %v)`, event.Latest, ver, err)
+		}
+	})
+	t.Run(`Errors`, func(t *testing.T) {
+		sentinel := errors.New(`sentinel`)
+		err := Transcode(ioutil.Discard, bytes.NewReader(data),
+			Transform(func(evt *event.Event) (bool, error) {
+				return false, sentinel
+			}))
+		if !errors.Is(err, sentinel) {
+			t.Fatalf(`exp transform err %v; got %v`, sentinel, err)
+		}
+
+		old := traceList.ByVersion(event.Version1)[0].Bytes()
+		if err := Transcode(ioutil.Discard, bytes.NewReader(old)); err == nil {
+			t.Fatal(`exp non-nil err for Version1 input`)
+		}
+		if err := Transcode(ioutil.Discard, bytes.NewReader(nil)); err == nil {
+			t.Fatal(`exp non-nil err for empty input`)
+		}
+	})
+}
+
+func TestEncoderStats(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if _, err := enc.InternString(`main`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.EmitStack(event.Stack{event.NewFrame(1, `main`, `main.go`, 2)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.BeginBatch(0, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Emit(&event.Event{Type: event.EvGoStart, Args: []uint64{0, 1, 0}, Ts: 200}); err != nil {
+		t.Fatal(err)
+	}
+
+	st := enc.Stats()
+	if st.Bytes != buf.Len() {
+		t.Fatalf(`exp %v bytes; got %v`, buf.Len(), st.Bytes)
+	}
+	if st.Events != 5 || st.Strings != 2 || st.Stacks != 1 {
+		t.Fatalf(`exp 5 events, 2 strings and 1 stack; got %v, %v and %v`,
+			st.Events, st.Strings, st.Stacks)
+	}
+	var events, size int
+	for _, ts := range st.Types {
+		events += ts.Events
+		size += ts.Bytes
+	}
+	if events != st.Events || size != st.Bytes-16 {
+		t.Fatalf(`exp types to total %v events and %v bytes; got %v and %v`,
+			st.Events, st.Bytes-16, events, size)
+	}
+	if exp := (TypeStats{Events: 1, Bytes: 3}); st.Types[event.EvBatch] != exp {
+		t.Fatalf(`exp batch stats %v; got %v`, exp, st.Types[event.EvBatch])
+	}
+
+	st.Types[event.EvBatch] = TypeStats{}
+	if enc.Stats().Types[event.EvBatch].Events != 1 {
+		t.Fatal(`exp Stats to return a copy`)
+	}
+	enc.Reset(ioutil.Discard)
+	if st := enc.Stats(); st.Events != 0 || st.Bytes != 0 || len(st.Types) != 0 {
+		t.Fatalf(`exp stats to clear after Reset; got %v`, st)
+	}
+}
+
+func TestEncoderTimestamps(t *testing.T) {
+	data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+	decodeAll := func(r io.Reader) (evts []event.Event) {
+		dec := NewDecoder(r)
+		err := dec.VisitAll(visitFunc(func(evt *event.Event) error {
+			cpy := *evt
+			cpy.Args = append([]uint64(nil), evt.Args...)
+			evts = append(evts, cpy)
+			return nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	var freq uint64
+	from := decodeAll(bytes.NewReader(data))
+	for _, evt := range from {
+		if evt.Type == event.EvFrequency {
+			freq = evt.Args[0]
+		}
+	}
+	if freq == 0 {
+		t.Fatal(`exp fixture to declare a frequency`)
+	}
+
+	var buf bytes.Buffer
+	err := Transcode(&buf, bytes.NewReader(data),
+		ShiftTimestamps(1000), ScaleTimestamps(freq, freq*2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := decodeAll(&buf)
+	if len(to) != len(from) {
+		t.Fatalf(`exp %v events; got %v`, len(from), len(to))
+	}
+	for i := range from {
+		if from[i].Type == event.EvFrequency {
+			if to[i].Args[0] != freq*2 {
+				t.Fatalf(`exp frequency %v; got %v`, freq*2, to[i].Args[0])
+			}
+			continue
+		}
+		if exp := from[i].Ts*2 + 1000; from[i].P == to[i].P &&
+			from[i].Ts != 0 && to[i].Ts != exp {
+			t.Fatalf(`exp Ts %v for %v; got %v`, exp, to[i], to[i].Ts)
+		}
+	}
+
+	t.Run(`Rounding`, func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf, ScaleTimestamps(3, 1))
+		for _, evt := range []*event.Event{
+			{Type: event.EvBatch, Args: []uint64{0, 2}},
+			{Type: event.EvGoEnd, Args: []uint64{2}},
+			{Type: event.EvGoEnd, Args: []uint64{2}},
+			{Type: event.EvGoEnd, Args: []uint64{2}},
+		} {
+			if err := enc.Emit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Absolute ticks 2, 4, 6 and 8 scale to 0, 1, 2 and 2.
+		exp := []int64{0, 1, 2, 2}
+		for i, evt := range decodeAll(&buf) {
+			if evt.Ts != exp[i] {
+				t.Fatalf(`exp Ts %v for event %v; got %v`, exp[i], i, evt.Ts)
+			}
+		}
+	})
+	t.Run(`Negative`, func(t *testing.T) {
+		enc := NewEncoder(ioutil.Discard, ShiftTimestamps(-10))
+		err := enc.Emit(&event.Event{Type: event.EvBatch, Args: []uint64{0, 5}})
+		if err == nil {
+			t.Fatal(`exp non-nil err for negative timestamp`)
+		}
+	})
+}
+
+func TestEncoderHook(t *testing.T) {
+	var buf bytes.Buffer
+	var calls int
+	enc := NewEncoder(&buf,
+		Hook(func(evt *event.Event) (*event.Event, error) {
+			calls++
+			if evt.Type == event.EvGoSched {
+				return nil, nil
+			}
+			return evt, nil
+		}),
+		Hook(func(evt *event.Event) (*event.Event, error) {
+			if evt.Type == event.EvGoSched {
+				t.Fatal(`exp dropped events to skip later hooks`)
+			}
+			if evt.Type != event.EvString {
+				return evt, nil
+			}
+			return &event.Event{
+				Type: evt.Type, Args: evt.Args, Data: []byte(`redacted`)}, nil
+		}))
+	str := &event.Event{Type: event.EvString, Args: []uint64{1}, Data: []byte(`secret`)}
+	for _, evt := range []*event.Event{
+		str,
+		{Type: event.EvGoSched, Args: []uint64{0, 0}},
+		{Type: event.EvFrequency, Args: []uint64{1000}},
+	} {
+		if err := enc.Emit(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf(`exp 3 calls to the first hook; got %v`, calls)
+	}
+	if string(str.Data) != `secret` {
+		t.Fatal(`exp events given to Emit to be unmodified`)
+	}
+
+	var types []event.Type
+	dec := NewDecoder(&buf)
+	err := dec.VisitAll(visitFunc(func(evt *event.Event) error {
+		types = append(types, evt.Type)
+		if evt.Type == event.EvString && string(evt.Data) != `redacted` {
+			t.Fatalf(`exp redacted string; got %q`, evt.Data)
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []event.Type{event.EvString, event.EvFrequency}; !reflect.DeepEqual(exp, types) {
+		t.Fatalf(`exp events %v; got %v`, exp, types)
+	}
+
+	t.Run(`Error`, func(t *testing.T) {
+		enc := NewEncoder(ioutil.Discard,
+			Hook(func(evt *event.Event) (*event.Event, error) {
+				return nil, errors.New(`sentinel`)
+			}))
+		err := enc.Emit(&event.Event{Type: event.EvFrequency, Args: []uint64{1}})
+		if err == nil || enc.Err() != err {
+			t.Fatalf(`exp permanent non-nil err from hook; got %v`, err)
+		}
+	})
+}
+
+func TestEncoderConcurrent(t *testing.T) {
+	const producers, batches = 8, 50
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, Concurrent())
+	done := make(chan error, producers)
+	for p := 0; p < producers; p++ {
+		go func(p uint64) {
+			for i := 0; i < batches; i++ {
+				_, err := enc.EmitAll([]*event.Event{
+					{Type: event.EvBatch, Args: []uint64{p, 0}},
+					{Type: event.EvGoStart, Args: []uint64{1, p, 0}},
+					{Type: event.EvGoEnd, Args: []uint64{1}},
+				})
+				if err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}(uint64(p))
+	}
+	for p := 0; p < producers; p++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := enc.Stats(); st.Events != producers*batches*3 {
+		t.Fatalf(`exp %v events; got %v`, producers*batches*3, st.Events)
+	}
+
+	var n int
+	dec := NewDecoder(&buf)
+	err := dec.VisitAll(visitFunc(func(evt *event.Event) error {
+		if evt.Type == event.EvGoStart && evt.P != int64(evt.Args[1]) {
+			t.Fatalf(`exp batches to be contiguous; got %v on P %v`, evt, evt.P)
+		}
+		n++
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != producers*batches*3 {
+		t.Fatalf(`exp %v events; got %v`, producers*batches*3, n)
+	}
+}
+
+func TestEncoderStage(t *testing.T) {
+	const producers, batches = 8, 50
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, Concurrent())
+	done := make(chan error, producers)
+	for p := 0; p < producers; p++ {
+		go func(p uint64) {
+			st, evt := enc.Stage(), new(event.Event)
+			for i := 0; i < batches; i++ {
+				*evt = event.Event{Type: event.EvBatch, Args: []uint64{p, 0}}
+				st.Emit(evt)
+				*evt = event.Event{Type: event.EvGoStart, Args: []uint64{1, p, 0}}
+				st.Emit(evt)
+				*evt = event.Event{Type: event.EvGoEnd, Args: []uint64{1}}
+				st.Emit(evt)
+				if st.Len() != 3 {
+					done <- fmt.Errorf(`exp 3 staged events; got %v`, st.Len())
+					return
+				}
+				if err := st.Flush(); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}(uint64(p))
+	}
+	for p := 0; p < producers; p++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var n int
+	dec := NewDecoder(&buf)
+	err := dec.VisitAll(visitFunc(func(evt *event.Event) error {
+		if evt.Type == event.EvGoStart && evt.P != int64(evt.Args[1]) {
+			t.Fatalf(`exp batches to be contiguous; got %v on P %v`, evt, evt.P)
+		}
+		n++
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != producers*batches*3 {
+		t.Fatalf(`exp %v events; got %v`, producers*batches*3, n)
+	}
+}
+
+func TestEncoderOffset(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, Hook(func(evt *event.Event) (*event.Event, error) {
+		if evt.Type == event.EvGoSched {
+			return nil, nil
+		}
+		return evt, nil
+	}))
+	if off := enc.Offset(); off != 0 {
+		t.Fatalf(`exp offset 0 before the header is written; got %v`, off)
+	}
+
+	var offs []int64
+	for _, evt := range []*event.Event{
+		{Type: event.EvBatch, Args: []uint64{0, 100}},
+		{Type: event.EvGoStart, Args: []uint64{10, 1, 0}},
+		{Type: event.EvGoEnd, Args: []uint64{20}},
+	} {
+		off, err := enc.EmitOffset(evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offs = append(offs, off)
+	}
+	if off := enc.Offset(); off != int64(buf.Len()) {
+		t.Fatalf(`exp offset %v; got %v`, buf.Len(), off)
+	}
+	off, err := enc.EmitOffset(&event.Event{Type: event.EvGoSched, Args: []uint64{5, 0}})
+	if err != nil || off != int64(buf.Len()) {
+		t.Fatalf(`exp offset %v for dropped event; got %v (err %v)`, buf.Len(), off, err)
+	}
+	next, err := enc.EmitOffset(&event.Event{Type: event.EvGoEnd, Args: []uint64{5}})
+	if err != nil || next != off {
+		t.Fatalf(`exp offset %v after dropped event; got %v (err %v)`, off, next, err)
+	}
+	offs = append(offs, next)
+
+	var i int
+	dec := NewDecoder(&buf)
+	err = dec.VisitAll(visitFunc(func(evt *event.Event) error {
+		if int64(evt.Off) != offs[i] {
+			t.Fatalf(`exp offset %v for %v; got %v`, evt.Off, evt, offs[i])
+		}
+		if i == len(offs)-1 && evt.Ts != 140 {
+			t.Fatalf(`exp dropped delta to be carried to ts 140; got %v`, evt.Ts)
+		}
+		i++
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != len(offs) {
+		t.Fatalf(`exp %v events; got %v`, len(offs), i)
+	}
+
+	enc = NewEncoder(ioutil.Discard, SortWindow(4))
+	off, err = enc.EmitOffset(&event.Event{Type: event.EvGoEnd, Args: []uint64{5}, Ts: 5})
+	if err != nil || off != -1 {
+		t.Fatalf(`exp offset -1 for event buffered by SortWindow; got %v (err %v)`, off, err)
+	}
+}
+
+func TestEncoderGzip(t *testing.T) {
+	data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+
+	var buf bytes.Buffer
+	err := Transcode(&buf, bytes.NewReader(data), Gzip(gzip.BestSpeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, got) {
+		t.Fatal(`exp decompressed output to match the input trace`)
+	}
+
+	t.Run(`Flush`, func(t *testing.T) {
+		var out bytes.Buffer
+		bw := bufio.NewWriter(&out)
+		enc := NewEncoder(bw, Gzip(gzip.DefaultCompression))
+		if err := enc.Emit(&event.Event{Type: event.EvFrequency, Args: []uint64{1000}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		zr, err := gzip.NewReader(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, evt := NewDecoder(zr), new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			t.Fatal(err)
+		}
+		if evt.Type != event.EvFrequency {
+			t.Fatalf(`exp EvFrequency after Flush; got %v`, evt)
+		}
+	})
+	t.Run(`Close`, func(t *testing.T) {
+		var out bytes.Buffer
+		enc := NewEncoder(&out, Gzip(gzip.DefaultCompression))
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf(`exp repeated Close to return nil; got %v`, err)
+		}
+		if err := enc.Emit(&event.Event{Type: event.EvFrequency, Args: []uint64{1}}); err == nil {
+			t.Fatal(`exp non-nil err for Emit after Close`)
+		}
+		enc.Reset(&out)
+		if err := enc.Emit(&event.Event{Type: event.EvFrequency, Args: []uint64{1}}); err != nil {
+			t.Fatalf(`exp nil err for Emit after Reset; got %v`, err)
+		}
+	})
+	t.Run(`Level`, func(t *testing.T) {
+		enc := NewEncoder(ioutil.Discard, Gzip(100))
+		if err := enc.Emit(&event.Event{Type: event.EvFrequency, Args: []uint64{1}}); err == nil {
+			t.Fatal(`exp non-nil err for invalid compression level`)
+		}
+	})
+}
+
+func TestEncoderResetVersion(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, TargetVersion(event.Version2))
+	if err := enc.Emit(&event.Event{Type: event.EvFrequency, Args: []uint64{1}}); err != nil {
+		t.Fatal(err)
+	}
+	if ver, err := NewDecoder(&buf).Version(); err != nil || ver != event.Version2 {
+		t.Fatalf(`exp %v; got %v (err %v)`, event.Version2, ver, err)
+	}
+
+	buf.Reset()
+	enc.ResetVersion(&buf, event.Version4)
+	if err := enc.Emit(&event.Event{Type: event.EvFrequency, Args: []uint64{1}}); err != nil {
+		t.Fatal(err)
+	}
+	if ver, err := NewDecoder(&buf).Version(); err != nil || ver != event.Version4 {
+		t.Fatalf(`exp %v; got %v (err %v)`, event.Version4, ver, err)
+	}
+}
+
+func TestEncoderResetKeepState(t *testing.T) {
+	var a, b bytes.Buffer
+	enc := NewEncoder(&a)
+	id, err := enc.InternString(`main`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.BeginBatch(0, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.ResetKeepState(&b); err != nil {
+		t.Fatal(err)
+	}
+	if id2, err := enc.InternString(`main`); err != nil || id2 != id {
+		t.Fatalf(`exp string id %v to be retained; got %v (err %v)`, id, id2, err)
+	}
+	err = enc.Emit(&event.Event{Type: event.EvGoEnd, Args: []uint64{0}, Ts: 150})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off := enc.Offset(); off != int64(a.Len()+b.Len()) {
+		t.Fatalf(`exp offset %v; got %v`, a.Len()+b.Len(), off)
+	}
+
+	var types []event.Type
+	var last event.Event
+	dec := NewDecoder(io.MultiReader(&a, &b))
+	err = dec.VisitAll(visitFunc(func(evt *event.Event) error {
+		types = append(types, evt.Type)
+		last = *evt
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []event.Type{event.EvString, event.EvBatch, event.EvGoEnd}
+	if !reflect.DeepEqual(exp, types) {
+		t.Fatalf(`exp events %v; got %v`, exp, types)
+	}
+	if last.Ts != 150 {
+		t.Fatalf(`exp batch to continue across writers; got Ts %v`, last.Ts)
+	}
+
+	t.Run(`Gzip`, func(t *testing.T) {
+		var a, b bytes.Buffer
+		enc := NewEncoder(&a, Gzip(gzip.DefaultCompression))
+		if err := enc.Emit(&event.Event{Type: event.EvFrequency, Args: []uint64{1}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.ResetKeepState(&b); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Emit(&event.Event{Type: event.EvFrequency, Args: []uint64{2}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		for _, buf := range []*bytes.Buffer{&a, &b} {
+			zr, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ioutil.ReadAll(zr); err != nil {
+				t.Fatalf(`exp a complete gzip member for each writer; got %v`, err)
+			}
+		}
+	})
+}
+
+func TestEncoderFrequency(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, Frequency(1e9))
+	for _, evt := range []*event.Event{
+		{Type: event.EvBatch, Args: []uint64{0, 100}},
+		{Type: event.EvFrequency, Args: []uint64{1000}},
+	} {
+		if err := enc.Emit(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := enc.Stats(); st.Types[event.EvFrequency].Events != 1 {
+		t.Fatalf(`exp 1 EvFrequency event; got %v`, st.Types[event.EvFrequency])
+	}
+
+	var evts []event.Event
+	dec := NewDecoder(&buf)
+	err := dec.VisitAll(visitFunc(func(evt *event.Event) error {
+		evts = append(evts, *evt)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 2 {
+		t.Fatalf(`exp 2 events; got %v`, len(evts))
+	}
+	if evts[0].Type != event.EvFrequency || evts[0].Args[0] != 1e9 {
+		t.Fatalf(`exp EvFrequency of 1e9 following the header; got %v`, evts[0])
+	}
+	if evts[0].Off != 16 {
+		t.Fatalf(`exp EvFrequency at offset 16; got %v`, evts[0].Off)
+	}
+	if dec.Stopped() {
+		t.Fatal(`exp leading EvFrequency to not be taken as the trace footer`)
+	}
+}
+
+func TestEncodeError(t *testing.T) {
+	t.Run(`ShortWrite`, func(t *testing.T) {
+		enc := NewEncoder(&shortWriter{n: 16 + 3 + 1})
+		batch := &event.Event{Type: event.EvBatch, Args: []uint64{0, 1}}
+		if err := enc.Emit(batch); err != nil {
+			t.Fatal(err)
+		}
+		err := enc.Emit(&event.Event{Type: event.EvGoEnd, Args: []uint64{300}})
+		if !errors.Is(err, io.ErrShortWrite) {
+			t.Fatalf(`exp io.ErrShortWrite; got %v`, err)
+		}
+		if errors.Is(err, ErrInvalidEvent) {
+			t.Fatal(`exp short write to not match ErrInvalidEvent`)
+		}
+		var eerr *EncodeError
+		if !errors.As(err, &eerr) {
+			t.Fatalf(`exp *EncodeError; got %T`, err)
+		}
+		if eerr.Off != 19 || eerr.Type != event.EvGoEnd {
+			t.Fatalf(`exp EvGoEnd at 0x13; got %v at 0x%x`, eerr.Type, eerr.Off)
+		}
+	})
+	t.Run(`EmitAll`, func(t *testing.T) {
+		enc := NewEncoder(&shortWriter{n: 16 + 3 + 1})
+		_, err := enc.EmitAll([]*event.Event{
+			{Type: event.EvBatch, Args: []uint64{0, 1}},
+			{Type: event.EvGoEnd, Args: []uint64{300}},
+		})
+		var eerr *EncodeError
+		if !errors.As(err, &eerr) || !errors.Is(err, io.ErrShortWrite) {
+			t.Fatalf(`exp *EncodeError for io.ErrShortWrite; got %v`, err)
+		}
+		if eerr.Off != 19 || eerr.Type != event.EvGoEnd {
+			t.Fatalf(`exp EvGoEnd at 0x13; got %v at 0x%x`, eerr.Type, eerr.Off)
+		}
+	})
+	t.Run(`InvalidEvent`, func(t *testing.T) {
+		enc := NewEncoder(ioutil.Discard)
+		err := enc.Emit(&event.Event{Type: event.EvBatch})
+		if !errors.Is(err, ErrInvalidEvent) {
+			t.Fatalf(`exp ErrInvalidEvent; got %v`, err)
+		}
+		var eerr *EncodeError
+		if !errors.As(err, &eerr) || eerr.Off != 16 || eerr.Type != event.EvBatch {
+			t.Fatalf(`exp *EncodeError for EvBatch at 0x10; got %v`, err)
+		}
+	})
+	t.Run(`Strict`, func(t *testing.T) {
+		enc := NewEncoder(ioutil.Discard, Strict())
+		err := enc.Emit(&event.Event{Type: event.EvBatch})
+		var serr *SchemaError
+		if !errors.Is(err, ErrInvalidEvent) || !errors.As(err, &serr) {
+			t.Fatalf(`exp *SchemaError matching ErrInvalidEvent; got %v`, err)
+		}
+		var eerr *EncodeError
+		if !errors.As(err, &eerr) || eerr.Type != event.EvBatch {
+			t.Fatalf(`exp *EncodeError wrapping the *SchemaError; got %v`, err)
+		}
+	})
+}
+
+func TestEncoderPool(t *testing.T) {
+	pool := NewEncoderPool(TargetVersion(event.Version4))
+	for i := 0; i < 3; i++ {
+		var buf bytes.Buffer
+		enc := pool.Get(&buf)
+		if err := enc.Emit(&event.Event{Type: event.EvFrequency, Args: []uint64{1}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		pool.Put(enc)
+
+		if buf.Len() != 16+2 {
+			t.Fatalf(`exp a single trace per Get; got %v bytes`, buf.Len())
+		}
+		dec := NewDecoder(&buf)
+		if ver, err := dec.Version(); err != nil || ver != event.Version4 {
+			t.Fatalf(`exp %v from pooled encoder; got %v (err %v)`,
+				event.Version4, ver, err)
+		}
+	}
+
+	// A version given to ResetVersion does not outlive the Encoder's return.
+	enc := pool.Get(ioutil.Discard)
+	enc.ResetVersion(ioutil.Discard, event.Version2)
+	pool.Put(enc)
+	for i := 0; i < 3; i++ {
+		var buf bytes.Buffer
+		enc := pool.Get(&buf)
+		if err := enc.Emit(&event.Event{Type: event.EvFrequency, Args: []uint64{1}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		pool.Put(enc)
+
+		dec := NewDecoder(&buf)
+		if ver, err := dec.Version(); err != nil || ver != event.Version4 {
+			t.Fatalf(`exp %v after ResetVersion; got %v (err %v)`,
+				event.Version4, ver, err)
+		}
+	}
+
+	var zero EncoderPool
+	enc = zero.Get(ioutil.Discard)
+	if err := enc.Emit(&event.Event{Type: event.EvFrequency, Args: []uint64{1}}); err != nil {
+		t.Fatal(err)
+	}
+	zero.Put(enc)
+}
+
+func TestEncoderSortWindow(t *testing.T) {
+	data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+
+	var buf bytes.Buffer
+	err := Transcode(&buf, bytes.NewReader(data), SortWindow(1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var from, to int
+	dec := NewDecoder(bytes.NewReader(data))
+	err = dec.VisitAll(visitFunc(func(evt *event.Event) error {
+		if evt.Type != event.EvBatch {
+			from++
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last int64
+	dec = NewDecoder(&buf)
+	err = dec.VisitAll(visitFunc(func(evt *event.Event) error {
+		if evt.Type == event.EvBatch {
+			return nil
+		}
+		if ordered(evt) {
+			if evt.Ts < last {
+				t.Fatalf(`exp events ordered by Ts; got %v at %v after %v`,
+					evt, evt.Ts, last)
+			}
+			last = evt.Ts
+		}
+		to++
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != to {
+		t.Fatalf(`exp %v events; got %v`, from, to)
+	}
+
+	t.Run(`Canonical`, func(t *testing.T) {
+		evts := []*event.Event{
+			{Type: event.EvGoStart, Args: []uint64{0, 1, 0}, Ts: 10, P: 0, G: 1},
+			{Type: event.EvGoStart, Args: []uint64{0, 2, 0}, Ts: 10, P: 1, G: 2},
+			{Type: event.EvGoEnd, Args: []uint64{0}, Ts: 20, P: 0, G: 1},
+			{Type: event.EvGoEnd, Args: []uint64{0}, Ts: 15, P: 1, G: 2},
+		}
+		encode := func(window int, order ...int) []byte {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf, SortWindow(window))
+			for _, i := range order {
+				if err := enc.Emit(evts[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+			return buf.Bytes()
+		}
+		exp := encode(4, 0, 1, 2, 3)
+		if got := encode(4, 3, 2, 1, 0); !bytes.Equal(exp, got) {
+			t.Fatal(`exp identical output regardless of the order events are given`)
+		}
+		if got := encode(2, 1, 0, 3, 2); !bytes.Equal(exp, got) {
+			t.Fatal(`exp identical output for events disordered within the window`)
+		}
+
+		var ps []int64
+		err := NewDecoder(bytes.NewReader(exp)).VisitAll(visitFunc(
+			func(evt *event.Event) error {
+				if evt.Type == event.EvBatch {
+					ps = append(ps, evt.P)
+				}
+				return nil
+			}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp := []int64{0, 1, 0}; !reflect.DeepEqual(exp, ps) {
+			t.Fatalf(`exp batches for P %v; got %v`, exp, ps)
+		}
+	})
+	t.Run(`Declarations`, func(t *testing.T) {
+		str := event.MustNew(event.EvString, 1)
+		str.Data = []byte(`key`)
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf, SortWindow(1))
+		for _, evt := range []*event.Event{
+			str,
+			{Type: event.EvGoStart, Args: []uint64{0, 1, 0}, Ts: 10, P: 0, G: 1},
+			{Type: event.EvGoEnd, Args: []uint64{0}, Ts: 20, P: 0, G: 1},
+		} {
+			if err := enc.Emit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Parsers reject events which precede the first batch.
+		var types []event.Type
+		err := NewDecoder(&buf).VisitAll(visitFunc(func(evt *event.Event) error {
+			types = append(types, evt.Type)
+			return nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := []event.Type{event.EvBatch, event.EvString, event.EvGoStart, event.EvGoEnd}
+		if !reflect.DeepEqual(exp, types) {
+			t.Fatalf(`exp events %v; got %v`, exp, types)
+		}
+	})
+}
+
+func TestAppendEvent(t *testing.T) {
+	for i, test := range testEventsLatest {
+		s := testDecodeSetup(t, event.Latest, test.from)
+		evt := new(event.Event)
+		if err := decodeEvent(s, evt); err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := encodeEvent(&offsetWriter{w: &buf}, evt); err != nil {
+			t.Fatal(err)
+		}
+		got, err := AppendEvent([]byte{0xa}, evt)
+		if err != nil {
+			t.Fatalf(`test #%v exp nil err for %v; got %v`, i, evt, err)
+		}
+		if got[0] != 0xa || !bytes.Equal(buf.Bytes(), got[1:]) {
+			t.Fatalf(`test #%v exp %#v for %v; got %#v`, i, buf.Bytes(), evt, got[1:])
+		}
+		back := new(event.Event)
+		if err := back.UnmarshalBinary(got[1:]); err != nil {
+			t.Fatalf(`test #%v exp nil err unmarshaling %v; got %v`, i, evt, err)
+		}
+		if back.Type != evt.Type || !reflect.DeepEqual(back.Args, evt.Args) ||
+			!bytes.Equal(back.Data, evt.Data) {
+			t.Fatalf(`test #%v exp %v from UnmarshalBinary; got %v`, i, evt, back)
+		}
+		if n := EncodedSize(evt); n != buf.Len() {
+			t.Fatalf(`test #%v exp EncodedSize %v for %v; got %v`, i, buf.Len(), evt, n)
+		}
+	}
+
+	t.Run(`Allocs`, func(t *testing.T) {
+		evts := []*event.Event{
+			{Type: event.EvGoStart, Args: []uint64{10, 1, 2}},
+			{Type: event.EvStack, Args: []uint64{1, 1, 0x400, 2, 3, 40}},
+			{Type: event.EvString, Args: []uint64{1}, Data: []byte(`main`)},
+		}
+		dst := make([]byte, 0, 1024)
+		allocs := testing.AllocsPerRun(100, func() {
+			var err error
+			for _, evt := range evts {
+				if dst, err = AppendEvent(dst, evt); err != nil {
+					t.Fatal(err)
+				}
+			}
+			dst = dst[:0]
+		})
+		if allocs > 0 {
+			t.Fatalf(`exp AppendEvent to not allocate; got %v allocs`, allocs)
+		}
+	})
+	t.Run(`Errors`, func(t *testing.T) {
+		dst := []byte{0xa}
+		for _, evt := range []*event.Event{
+			{Type: event.EvCount, Args: []uint64{1}},
+			{Type: event.EvGoEnd},
+		} {
+			got, err := AppendEvent(dst, evt)
+			if err == nil {
+				t.Fatalf(`exp non-nil err for %v`, evt)
+			}
+			if n := EncodedSize(evt); n != -1 {
+				t.Fatalf(`exp EncodedSize -1 for %v; got %v`, evt, n)
+			}
+			if !bytes.Equal(dst, got) {
+				t.Fatal(`exp dst to be returned unmodified`)
+			}
+		}
+	})
+}
+
+func TestEncoderFidelity(t *testing.T) {
+	for _, tf := range traceList.ByVersion(event.Version1) {
+		tf := tf
+		t.Run(tf.Name, func(t *testing.T) {
+			data := tf.Bytes()
+			var buf bytes.Buffer
+			if err := Transcode(&buf, bytes.NewReader(data), Fidelity()); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, buf.Bytes()) {
+				t.Fatal(`exp Version1 trace to round trip byte for byte`)
+			}
+		})
+	}
+
+	t.Run(`Strings`, func(t *testing.T) {
+		data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+		var buf bytes.Buffer
+		err := Transcode(&buf, bytes.NewReader(data),
+			Fidelity(), LazyStrings(nil), LargeStrings(1, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, buf.Bytes()) {
+			t.Fatal(`exp trace to round trip byte for byte`)
+		}
+	})
+	t.Run(`Errors`, func(t *testing.T) {
+		data := traceList.ByVersion(event.Version1)[0].Bytes()
+		err := Transcode(ioutil.Discard, bytes.NewReader(data),
+			Fidelity(), TargetVersion(event.Latest))
+		if err == nil {
+			t.Fatal(`exp non-nil err for Fidelity with another target version`)
+		}
+	})
+}
+
+// assertRetained checks the events decoded from out are those of src which
+// dropped does not match, with unchanged timestamps.
+func assertRetained(t *testing.T, src, out []byte, dropped func(evt *event.Event) bool) {
+	t.Helper()
+	var exp []event.Event
+	err := NewDecoder(bytes.NewReader(src)).VisitAll(visitFunc(func(evt *event.Event) error {
+		if !dropped(evt) {
+			exp = append(exp, event.Event{Type: evt.Type, Ts: evt.Ts, P: evt.P})
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	err = NewDecoder(bytes.NewReader(out)).VisitAll(visitFunc(func(evt *event.Event) error {
+		if n >= len(exp) {
+			return fmt.Errorf(`exp %v events; got more`, len(exp))
+		}
+		if e := exp[n]; e.Type != evt.Type || e.Ts != evt.Ts || e.P != evt.P {
+			return fmt.Errorf(`exp event #%v to be %v at %v on P %v; got %v at %v on P %v`,
+				n, e.Type, e.Ts, e.P, evt.Type, evt.Ts, evt.P)
+		}
+		n++
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(exp) {
+		t.Fatalf(`exp %v events; got %v`, len(exp), n)
+	}
+}
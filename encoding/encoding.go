@@ -4,8 +4,9 @@
 //
 // Overview
 //
-// This library will Decode all previous versions of the trace codec, while only
-// emitting Events in the latest version. Unlike the go tool it does not buffer
+// This library will Decode all previous versions of the trace codec, while
+// emitting Events in the latest version unless another is selected with the
+// TargetVersion option. Unlike the go tool it does not buffer
 // events during decoding to make them immediately available without allocation
 // with large performance gains. It is fast enough to easily allow decoding in
 // the same process that is performing the tracing, enabling you to defer writes
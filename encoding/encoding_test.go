@@ -27,7 +27,7 @@ func init() {
 func runEncodingTest(t *testing.T, tf *tracefile.Trace, b []byte, r io.Reader) {
 	t.Run(tf.Version.Go()+`/`+tf.Name, func(t *testing.T) {
 		w := new(bytes.Buffer)
-		dec, enc := NewDecoder(r), NewEncoder(w)
+		dec, enc := NewDecoder(r), NewEncoder(w, TargetVersion(tf.Version))
 		for dec.More() {
 			off := dec.state.off
 			evt := new(event.Event)
@@ -39,7 +39,7 @@ func runEncodingTest(t *testing.T, tf *tracefile.Trace, b []byte, r io.Reader) {
 
 			// Use the comprehensive decoder testing to prove the Encoder correct
 			// through invariant Dec(Enc(Dec(Input)))
-			if tf.Version != event.Latest {
+			if tf.Version == event.Version1 {
 				continue
 			}
 
@@ -116,6 +116,11 @@ func TestEncodingVersion(t *testing.T) {
 		{1, verFn(`1.5`), nil},
 		{2, verFn(`1.7`), nil},
 		{3, verFn(`1.8`), nil},
+		{4, verFn(`1.9`), nil},
+		{5, verFn(`1.11`), nil},
+		{6, verFn(`1.19`), nil},
+		{0, verFn(`1.10`), true},
+		{0, verFn(`1.111`), true},
 		{0, verFn(`1.8.0`), true},
 		{0, verFn(`1.4`), true},
 		{0, verFn(`1.4.0`), true},
@@ -158,7 +163,7 @@ func TestEncodingVersion(t *testing.T) {
 			if ver != test.exp {
 				t.Fatalf(`expected version %q; got %q`, test.exp, ver)
 			}
-			if exp, got := string(test.from[3:6]), ver.Go(); exp != got {
+			if exp, got := strings.Fields(string(test.from))[1], ver.Go(); exp != got {
 				t.Fatalf(`expected Go() value %q; got %q`, exp, got)
 			}
 
@@ -309,10 +314,11 @@ type testDecodeEvent struct {
 	from []byte
 }
 
-var testEventsLatest = testEventsV3
+var testEventsLatest = testEventsV6
 
 var testEvents = [...][]testDecodeEvent{
-	nil, testEventsV1, testEventsV2, testEventsV3, testEventsV4,
+	nil, testEventsV1, testEventsV2, testEventsV3, testEventsV4, testEventsV5,
+	testEventsV6,
 }
 
 var testEventsV1 = []testDecodeEvent{
@@ -435,6 +441,21 @@ var testEventsV4 = append(testEventsV3, []testDecodeEvent{
 	{event.EvGCMarkAssistDone, []uint64{0x1}, []byte{0x2c, 0x1}},
 }...)
 
+var testEventsV5 = append(testEventsV4, []testDecodeEvent{
+	{event.EvUserTaskCreate, []uint64{0x10, 0x1, 0x0, 0x2, 0x3},
+		[]byte{0xed, 0x5, 0x10, 0x1, 0x0, 0x2, 0x3}},
+	{event.EvUserTaskEnd, []uint64{0x10, 0x1, 0x3}, []byte{0xae, 0x10, 0x1, 0x3}},
+	{event.EvUserRegion, []uint64{0x10, 0x1, 0x0, 0x2, 0x3},
+		[]byte{0xef, 0x5, 0x10, 0x1, 0x0, 0x2, 0x3}},
+	{event.EvUserLog, []uint64{0x10, 0x1, 0x2, 0x3},
+		[]byte{0xf0, 0x4, 0x10, 0x1, 0x2, 0x3, 0x1, 'v'}},
+}...)
+
+var testEventsV6 = append(testEventsV5, []testDecodeEvent{
+	{event.EvCPUSample, []uint64{0x10, 0x2000, 0x1, 0x5, 0x3},
+		[]byte{0xf1, 0x6, 0x10, 0x80, 0x40, 0x1, 0x5, 0x3}},
+}...)
+
 type testEventString struct {
 	id   int
 	exp  string
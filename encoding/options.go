@@ -0,0 +1,285 @@
+package encoding
+
+import (
+	"io"
+	"sync"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// Option is used to configure a Decoder or Encoder. Options which do not apply
+// to the type they are given to are ignored.
+type Option func(*options)
+
+type options struct {
+	lazy       bool
+	spill      *spill
+	large      int
+	largeFn    func(evt *event.Event, r io.Reader) error
+	strict     bool
+	nanos      bool
+	target     event.Version
+	shift      int64
+	from, to   uint64
+	hooks      []HookFunc
+	concurrent bool
+	gzip       bool
+	level      int
+	freq       uint64
+	window     int
+	fidelity   bool
+	sample     uint64
+	stacks     int
+}
+
+func newOptions(opts []Option) *options {
+	o := new(options)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// LazyStrings configures a Decoder to record the location of EvString payloads
+// in the Span field of each event rather than copying them into Data, cutting
+// peak memory for traces with large string tables.
+//
+// When spill is nil the payload is skipped and the span is relative to the
+// beginning of the input stream, which the caller must retain. Otherwise the
+// payload is copied to spill and the span is relative to the first byte
+// written to it. In both cases the retained bytes may be given to the Source
+// field of an event.Trace to resolve the strings on demand.
+func LazyStrings(w io.Writer) Option {
+	return func(o *options) {
+		o.lazy, o.spill = true, nil
+		if w != nil {
+			o.spill = &spill{w: w}
+		}
+	}
+}
+
+// spill is the writer given to LazyStrings and the offset of the next payload
+// written to it, which are shared by a Decoder and its clones.
+type spill struct {
+	mu  sync.Mutex
+	w   io.Writer
+	off int
+}
+
+// copy writes the next size bytes of r to the spill, returning the span they
+// were written at.
+func (s *spill) copy(r io.Reader, size int) (event.Span, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	span := event.Span{Off: s.off, Len: size}
+	n, err := io.CopyN(s.w, r, int64(size))
+	s.off += int(n)
+	return span, err
+}
+
+// LargeStrings configures a Decoder to stream EvString payloads exceeding max
+// bytes to fn rather than materializing them in Data, so traces containing
+// very large values do not exhaust the memory of constrained consumers.
+//
+// The reader given to fn is limited to the payload, any bytes fn does not read
+// are discarded once it returns and an error returned from fn halts decoding.
+// The event given to fn has its Span set to the location of the payload within
+// the input stream and will be returned from Decode with empty Data. When fn
+// is nil the payload is instead capped, Data will contain only the first max
+// bytes of the string.
+//
+// The allocation limits of the Decoder do not apply to payloads given to fn.
+// LazyStrings takes precedence when both options are given.
+func LargeStrings(max int, fn func(evt *event.Event, r io.Reader) error) Option {
+	return func(o *options) {
+		o.large, o.largeFn = max, fn
+	}
+}
+
+// Strict configures a Decoder or Encoder to check every event against the
+// schema of its type, for validating traces produced by emitters other than
+// the runtime. The first violation halts with a *SchemaError describing the
+// event and its offset in the input or output stream, which the Encoder wraps
+// in an *EncodeError like its other errors.
+//
+// The number of arguments and the frames of each stack are checked, as are
+// references to strings and stacks which must be declared exactly once.
+// Strings must be declared before they are referenced. The runtime declares
+// stacks in a table following the trace footer, so references to stacks are
+// checked by the Decoder once a trace that stopped cleanly has been fully
+// decoded, and by the Encoder when it is closed.
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// Nanoseconds configures a Decoder to set the Ts field of each event in
+// nanoseconds rather than CPU ticks. The number of ticks per second is declared
+// in the trace footer, so the input stream must implement io.ReaderAt for the
+// Decoder to read it before the first event is returned.
+func Nanoseconds() Option {
+	return func(o *options) {
+		o.nanos = true
+	}
+}
+
+// TargetVersion configures an Encoder to emit the given version of the Go trace
+// format, producing fixture traces for older Go versions. Events given to Emit
+// must have the layout of the latest version, those introduced after v are
+// rejected. For Version1 targets the sequence delta preceding each timestamp
+// is added, sequence arguments introduced in later versions are removed and
+// stack frames are reduced to their PC.
+func TargetVersion(v event.Version) Option {
+	return func(o *options) {
+		o.target = v
+	}
+}
+
+// ShiftTimestamps configures an Encoder to add delta ticks to the absolute
+// timestamps of emitted events, for aligning traces from different machines or
+// stitching multiple capture windows into a single trace. The base timestamp of
+// each EvBatch and the real timestamp of syscall exits are shifted, the deltas
+// of the events within a batch are unchanged.
+func ShiftTimestamps(delta int64) Option {
+	return func(o *options) {
+		o.shift = delta
+	}
+}
+
+// ScaleTimestamps configures an Encoder to rescale the timestamps of emitted
+// events from a tick frequency of from ticks per second to a frequency of to,
+// the argument of EvFrequency events is replaced with to. Scaling is applied
+// before any shift given with ShiftTimestamps, so the delta is in the ticks of
+// the new frequency.
+func ScaleTimestamps(from, to uint64) Option {
+	return func(o *options) {
+		o.from, o.to = from, to
+	}
+}
+
+// HookFunc is called by an Encoder for each event given to Emit before it is
+// encoded. It returns the event to encode in place of evt, which may be evt
+// itself, or nil to drop the event. The timestamp delta of a dropped event is
+// carried to the next event of its batch as by a Carry. A non-nil error is
+// returned from Emit.
+type HookFunc func(evt *event.Event) (*event.Event, error)
+
+// Hook configures an Encoder to pass each event given to Emit through fn, for
+// redacting, renumbering or annotating events. Hooks run in the order they are
+// given and before any other processing by the Encoder, an event dropped by one
+// is not given to those that follow it. Hooks should not modify the events
+// given to them in place unless the caller of Emit permits it.
+func Hook(fn HookFunc) Option {
+	return func(o *options) {
+		o.hooks = append(o.hooks, fn)
+	}
+}
+
+// Concurrent configures an Encoder to be safe for use by multiple goroutines.
+// Each call holds the Encoder for its duration, so the events given to a single
+// call to EmitAll are written contiguously. Producers which build batches should
+// stage their events in their own Stage, beginning each batch with its EvBatch
+// event, to prevent the events of other producers being interleaved within it.
+func Concurrent() Option {
+	return func(o *options) {
+		o.concurrent = true
+	}
+}
+
+// Gzip configures an Encoder to compress its output with gzip at the given
+// compression level, such as gzip.DefaultCompression, producing .trace.gz files
+// directly. Close must be called once the final event is emitted to write the
+// gzip footer, and Flush may be used to make the events emitted so far readable
+// by a consumer of the compressed stream. Offsets reported by the Encoder are
+// within the uncompressed trace.
+func Gzip(level int) Option {
+	return func(o *options) {
+		o.gzip, o.level = true, level
+	}
+}
+
+// Frequency configures an Encoder to emit an EvFrequency event declaring the
+// given number of ticks per second immediately after the trace header, as
+// consumers which convert timestamps to wall time require it. EvFrequency
+// events given to Emit are dropped so the frequency is declared only once.
+func Frequency(ticks uint64) Option {
+	return func(o *options) {
+		o.freq = ticks
+	}
+}
+
+// SortWindow configures an Encoder to buffer up to n timestamped events and
+// write them ordered by the Ts field, then by P and G, producing canonical output
+// so the differences between re-encoded traces are meaningful. Events must have
+// the Ts, P and G fields populated as by the Decoder.
+//
+// The Encoder groups the sorted events into batches itself, a new batch begins
+// whenever the P of an event differs from the prior event. The EvBatch events
+// given to Emit are dropped and BeginBatch and EndBatch have no effect. Events
+// without a timestamp, such as EvString and EvStack, are written immediately,
+// or within the first batch when given before any timestamped event.
+// Buffered events are written by Flush and Close, or before an EvFrequency event
+// as it begins the trace footer. EmitOffset returns -1 for buffered events.
+func SortWindow(n int) Option {
+	return func(o *options) {
+		o.window = n
+	}
+}
+
+// Fidelity configures a Decoder and Encoder pair to round trip traces byte for
+// byte, for tools which must not perturb the traces they filter. The Decoder
+// materializes every string payload in Data, ignoring LazyStrings and
+// LargeStrings. An Encoder targeting Version1 writes events in the Version1
+// layout they were decoded in, preserving the sequence argument and stack
+// frames as given, rather than translating them from the latest layout.
+//
+// Options which rewrite timestamps or batches, such as ShiftTimestamps and
+// SortWindow, expect the latest layout and may not be combined with Fidelity
+// for Version1 targets.
+func Fidelity() Option {
+	return func(o *options) {
+		o.fidelity = true
+	}
+}
+
+// Sample configures a Decoder to return only every nth event of each type, for
+// approximate analysis of traces too large to examine in full. The EvBatch,
+// EvFrequency, EvString and EvStack events describing the structure of the
+// trace are always returned, and values of n below 2 disable sampling.
+//
+// Skipped events are still decoded, so the Ts, P and G fields of the events
+// returned remain exact. The timestamp delta of each skipped event is added to
+// the next event returned from the same batch, allowing the sampled events to
+// be given to an Encoder. Events which pair with others, such as the start and
+// end of a goroutine, are sampled independently, so analysis relying on them
+// is approximate. Events are decoded ahead of those returned, so More reports
+// false once every event remaining in the input stream would be skipped.
+func Sample(n int) Option {
+	return func(o *options) {
+		if n > 1 {
+			o.sample = uint64(n)
+		}
+	}
+}
+
+// SampleStacks configures a Decoder to return at most k events of each type
+// from each stack, chosen uniformly at random by reservoir sampling, for
+// approximate analysis of where the time of traces too large to examine in
+// full was spent. Events of types which do not record a stack are always
+// returned, along with the EvBatch, EvFrequency, EvString and EvStack events.
+// Values of k below 1 disable sampling, and Sample is ignored when it is set.
+//
+// The input stream is decoded in full by the first call to More or Decode,
+// retaining only the returned events, so memory is proportional to k and the
+// number of stacks rather than the length of the trace. Like Sample the Ts, P
+// and G fields remain exact and the deltas of skipped events are carried to
+// the next event returned from the same batch. Sampling is deterministic, the
+// same trace always returns the same events.
+func SampleStacks(k int) Option {
+	return func(o *options) {
+		if k > 0 {
+			o.stacks = k
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package encoding
+
+import (
+	"container/heap"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// orderState buffers timestamped events for an Encoder configured with
+// SortWindow, see the Less method for the order they are emitted in. The decl
+// field holds the events without a timestamp given before the first batch.
+type orderState struct {
+	window int
+	seq    uint64
+	evts   []*orderedEvent
+	free   []*orderedEvent
+	decl   []event.Event
+}
+
+type orderedEvent struct {
+	evt event.Event
+	seq uint64
+}
+
+func newOrderState(o *options) *orderState {
+	if o == nil || o.window <= 0 {
+		return nil
+	}
+	return &orderState{window: o.window}
+}
+
+// ordered reports if evt is buffered rather than written immediately. Events
+// without a timestamp, such as those declaring strings and stacks, are written
+// as they are given so they always precede the events referring to them, or
+// once the first batch begins if they are given before it.
+func ordered(evt *event.Event) bool {
+	if evt.Type == event.EvBatch {
+		return true
+	}
+	names := evt.Type.Args()
+	return len(names) > 0 && names[0] == event.ArgTimestamp
+}
+
+// push adds a copy of evt to the window.
+func (o *orderState) push(evt *event.Event) {
+	var oe *orderedEvent
+	if n := len(o.free); n > 0 {
+		oe, o.free = o.free[n-1], o.free[:n-1]
+	} else {
+		oe = new(orderedEvent)
+	}
+	args, data := append(oe.evt.Args[:0], evt.Args...), append(oe.evt.Data[:0], evt.Data...)
+	oe.evt = *evt
+	oe.evt.Args, oe.evt.Data = args, data
+	oe.seq = o.seq
+	o.seq++
+	heap.Push(o, oe)
+}
+
+// hold adds a copy of evt to the declarations written once the first batch
+// begins.
+func (o *orderState) hold(evt *event.Event) {
+	out := *evt
+	out.Args = append([]uint64(nil), evt.Args...)
+	out.Data = append([]byte(nil), evt.Data...)
+	o.decl = append(o.decl, out)
+}
+
+// pop removes the earliest event from the window, it remains valid until the
+// next call to push.
+func (o *orderState) pop() *event.Event {
+	oe := heap.Pop(o).(*orderedEvent)
+	o.free = append(o.free, oe)
+	return &oe.evt
+}
+
+func (o *orderState) Len() int { return len(o.evts) }
+
+// Less orders events by timestamp, then P, then goroutine and finally the order
+// they were given to Emit.
+func (o *orderState) Less(i, j int) bool {
+	a, b := o.evts[i], o.evts[j]
+	switch {
+	case a.evt.Ts != b.evt.Ts:
+		return a.evt.Ts < b.evt.Ts
+	case a.evt.P != b.evt.P:
+		return a.evt.P < b.evt.P
+	case a.evt.G != b.evt.G:
+		return a.evt.G < b.evt.G
+	}
+	return a.seq < b.seq
+}
+
+func (o *orderState) Swap(i, j int) { o.evts[i], o.evts[j] = o.evts[j], o.evts[i] }
+
+func (o *orderState) Push(x interface{}) { o.evts = append(o.evts, x.(*orderedEvent)) }
+
+func (o *orderState) Pop() interface{} {
+	n := len(o.evts) - 1
+	oe := o.evts[n]
+	o.evts[n], o.evts = nil, o.evts[:n]
+	return oe
+}
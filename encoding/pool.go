@@ -0,0 +1,71 @@
+package encoding
+
+import (
+	"io"
+	"sync"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// EncoderPool is a pool of Encoders sharing the same options, for servers that
+// encode many small traces and wish to reuse their buffers and tables. The zero
+// value is a pool of Encoders without options. It is safe for use by multiple
+// goroutines.
+type EncoderPool struct {
+	opts   []Option
+	target event.Version
+	pool   sync.Pool
+}
+
+// NewEncoderPool returns a pool of Encoders configured with opts.
+func NewEncoderPool(opts ...Option) *EncoderPool {
+	return &EncoderPool{opts: opts, target: newOptions(opts).target}
+}
+
+// Get returns an Encoder from the pool which has been Reset to write to w.
+func (p *EncoderPool) Get(w io.Writer) *Encoder {
+	if enc, ok := p.pool.Get().(*Encoder); ok {
+		enc.Reset(w)
+		return enc
+	}
+	return NewEncoder(w, p.opts...)
+}
+
+// Put returns enc to the pool, it must not be used after Put returns. Encoders
+// configured with Gzip should be closed before they are returned. A version
+// given to ResetVersion is replaced with the target version of the pool.
+func (p *EncoderPool) Put(enc *Encoder) {
+	enc.Reset(nil)
+	if enc.opts != nil {
+		enc.opts.target = p.target
+	}
+	p.pool.Put(enc)
+}
+
+// DecoderPool is a pool of Decoders sharing the same options, see EncoderPool.
+type DecoderPool struct {
+	opts []Option
+	pool sync.Pool
+}
+
+// NewDecoderPool returns a pool of Decoders configured with opts.
+func NewDecoderPool(opts ...Option) *DecoderPool {
+	return &DecoderPool{opts: opts}
+}
+
+// Get returns a Decoder from the pool which has been Reset to read from r.
+func (p *DecoderPool) Get(r io.Reader) *Decoder {
+	if dec, ok := p.pool.Get().(*Decoder); ok {
+		dec.Reset(r)
+		return dec
+	}
+	return NewDecoder(r, p.opts...)
+}
+
+// Put returns dec to the pool, it must not be used after Put returns. The state
+// of the trace being decoded is discarded so the pool does not retain its input.
+func (p *DecoderPool) Put(dec *Decoder) {
+	dec.err = nil
+	dec.state.Reset(nil)
+	p.pool.Put(dec)
+}
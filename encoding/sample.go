@@ -0,0 +1,200 @@
+package encoding
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// sampler holds the state of a Decoder configured with the Sample or
+// SampleStacks options. Events are decoded ahead of those returned, so More
+// reports true only while a sampled event remains.
+type sampler struct {
+
+	// n is the interval of Sample and seen the number of events of each type
+	// it has seen, with carry holding the deltas of those it skipped.
+	n     uint64
+	seen  [event.EvCount]uint64
+	carry *Carry
+
+	// k is the size of the reservoir of each stack of SampleStacks and stk the
+	// position of the stack argument of each type in the layout of the trace,
+	// or -1 if the type has none. The sampled events are held in out once the
+	// input stream has been decoded, in the order they were decoded.
+	k    int
+	stk  [event.EvCount]int
+	out  []event.Event
+	done bool
+
+	// next is the event decoded ahead of the caller when ok is true.
+	next event.Event
+	ok   bool
+}
+
+func newSampler(o *options, v event.Version) *sampler {
+	if o.sample == 0 && o.stacks == 0 {
+		return nil
+	}
+	s := &sampler{n: o.sample, k: o.stacks, carry: NewCarry(v)}
+	for i := range s.stk {
+		s.stk[i] = -1
+	}
+	for _, sch := range event.Schemas(v) {
+		for i, name := range sch.Args {
+			if name == event.ArgStackID && int(sch.Type) < len(s.stk) {
+				s.stk[sch.Type] = i
+			}
+		}
+	}
+	return s
+}
+
+// clone returns a deep copy of s, see the Clone method of Decoder.
+func (s *sampler) clone() *sampler {
+	c, carry := *s, *s.carry
+	c.carry, c.next = &carry, *s.next.Copy()
+	c.out = make([]event.Event, len(s.out))
+	for i := range s.out {
+		c.out[i] = *s.out[i].Copy()
+	}
+	return &c
+}
+
+// stride reports if evt is returned by a Decoder configured with Sample, moving
+// the deltas of the events it skips to the next event returned from the same
+// batch.
+func (s *sampler) stride(evt *event.Event) bool {
+	keep := true
+	switch evt.Type {
+	case event.EvBatch, event.EvFrequency, event.EvString, event.EvStack:
+	default:
+		if int(evt.Type) < len(s.seen) {
+			keep = s.seen[evt.Type]%s.n == 0
+			s.seen[evt.Type]++
+		}
+	}
+	if keep {
+		s.carry.Keep(evt)
+	} else {
+		s.carry.Drop(evt)
+	}
+	return keep
+}
+
+// ahead decodes the next event returned by a Decoder configured with Sample or
+// SampleStacks into its sampler, reporting if one remains.
+func (d *Decoder) ahead() bool {
+	s := d.state.sampler
+	if s.ok {
+		return true
+	}
+	if s.k > 0 {
+		if !s.done {
+			d.fill()
+		}
+		if len(s.out) == 0 {
+			return false
+		}
+		s.next, s.out[0] = s.out[0], event.Event{}
+		s.out, s.ok = s.out[1:], true
+		return true
+	}
+	for d.input() {
+		s.next.Reset()
+		if err := d.decode(&s.next); err != nil {
+			return false
+		}
+		if s.stride(&s.next) {
+			s.ok = true
+			return true
+		}
+	}
+	return false
+}
+
+// fill decodes the remainder of the input stream for SampleStacks, retaining a
+// reservoir of the events of each type and stack chosen uniformly at random
+// along with every event of the types without a stack.
+func (d *Decoder) fill() {
+	s := d.state.sampler
+	s.done = true
+
+	// Each held event records the deltas accumulated within its batch, so those
+	// of the events which are not retained may be carried once all are known.
+	type held struct {
+		evt     event.Event
+		n       int
+		seq, ts uint64
+	}
+	type key struct {
+		typ event.Type
+		stk uint64
+	}
+	var (
+		evts    []*held
+		spare   *held
+		seq, ts uint64
+		pools   = make(map[key][]*held)
+		seen    = make(map[key]int)
+		rng     = rand.New(rand.NewSource(1))
+	)
+	for n := 0; d.input(); n++ {
+		h := spare
+		if spare = nil; h == nil {
+			h = new(held)
+		}
+		h.evt.Reset()
+		if err := d.decode(&h.evt); err != nil {
+			break
+		}
+		evt := &h.evt
+		if evt.Type == event.EvBatch {
+			seq, ts = 0, 0
+		} else if s.carry.timed(evt) {
+			if s.carry.argoff > 0 {
+				seq += evt.Args[0]
+			}
+			ts += evt.Args[s.carry.argoff]
+		}
+		h.n, h.seq, h.ts = n, seq, ts
+
+		i := -1
+		if int(evt.Type) < len(s.stk) {
+			i = s.stk[evt.Type]
+		}
+		if i < 0 || i >= len(evt.Args) {
+			evts = append(evts, h)
+			continue
+		}
+		k := key{evt.Type, evt.Args[i]}
+		seen[k]++
+		if pool := pools[k]; len(pool) < s.k {
+			pools[k] = append(pool, h)
+		} else if j := rng.Intn(seen[k]); j < s.k {
+			spare, pool[j] = pool[j], h
+		} else {
+			spare = h
+		}
+	}
+	for _, pool := range pools {
+		evts = append(evts, pool...)
+	}
+	sort.Slice(evts, func(i, j int) bool { return evts[i].n < evts[j].n })
+
+	var last held
+	s.out = make([]event.Event, len(evts))
+	for i, h := range evts {
+		evt := &h.evt
+		if evt.Type == event.EvBatch {
+			last.seq, last.ts = 0, 0
+		} else if s.carry.timed(evt) {
+			if s.carry.argoff > 0 {
+				evt.Args[0] = h.seq - last.seq
+			}
+			evt.Args[s.carry.argoff] = h.ts - last.ts
+			last.seq, last.ts = h.seq, h.ts
+		}
+		s.out[i] = *evt
+	}
+}
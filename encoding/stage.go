@@ -0,0 +1,55 @@
+package encoding
+
+import "github.com/cstockton/go-trace/event"
+
+// Stage is a staging buffer for a single producer of an Encoder created with
+// the Concurrent option. Events given to Emit are copied into the buffer
+// without holding the Encoder, then written contiguously by Flush, so the
+// events of other producers are never interleaved within a staged batch.
+//
+// Each producing goroutine should have its own Stage, a Stage may not be used
+// by multiple goroutines.
+type Stage struct {
+	enc  *Encoder
+	evts []event.Event
+	ptrs []*event.Event
+	n    int
+}
+
+// Stage returns a new staging buffer which flushes to e.
+func (e *Encoder) Stage() *Stage {
+	return &Stage{enc: e}
+}
+
+// Len returns the number of events staged since the last Flush.
+func (s *Stage) Len() int { return s.n }
+
+// Emit copies evt into the staging buffer, evt may be reused once it returns.
+// The staged events are not written until Flush is called, a batch is staged by
+// giving its EvBatch event before the events which belong to it.
+func (s *Stage) Emit(evt *event.Event) {
+	if s.n == len(s.evts) {
+		s.evts = append(s.evts, event.Event{})
+	}
+	out := &s.evts[s.n]
+	args, data := append(out.Args[:0], evt.Args...), append(out.Data[:0], evt.Data...)
+	*out = *evt
+	out.Args, out.Data = args, data
+	s.n++
+}
+
+// Flush writes the staged events to the Encoder with a single call to EmitAll
+// and empties the buffer. The error, if any, is that returned by EmitAll, in
+// which case the events following the failed event are discarded.
+func (s *Stage) Flush() error {
+	if s.n == 0 {
+		return nil
+	}
+	s.ptrs = s.ptrs[:0]
+	for i := 0; i < s.n; i++ {
+		s.ptrs = append(s.ptrs, &s.evts[i])
+	}
+	s.n = 0
+	_, err := s.enc.EmitAll(s.ptrs)
+	return err
+}
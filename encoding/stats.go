@@ -0,0 +1,61 @@
+package encoding
+
+import (
+	"github.com/cstockton/go-trace/event"
+)
+
+// Stats describes the output of an Encoder.
+type Stats struct {
+
+	// Events is the total number of events emitted.
+	Events int
+
+	// Bytes is the total number of bytes written, including the trace header.
+	Bytes int
+
+	// Types holds the number of events and bytes emitted for each event type.
+	Types map[event.Type]TypeStats
+
+	// Strings and Stacks are the number of entries in the string and stack
+	// tables, the bytes used to declare them are found in Types.
+	Strings, Stacks int
+}
+
+// TypeStats describes the events of a single type emitted by an Encoder.
+type TypeStats struct {
+	Events, Bytes int
+}
+
+// Stats returns statistics for the output written since the Encoder was
+// created or last Reset. The returned value is a copy and is not updated by
+// future calls to Emit.
+func (e *Encoder) Stats() Stats {
+	e.lock()
+	defer e.unlock()
+	st := e.stats
+	st.Bytes = e.w.Off()
+	st.Types = make(map[event.Type]TypeStats, len(e.stats.Types))
+	for typ, ts := range e.stats.Types {
+		st.Types[typ] = ts
+	}
+	return st
+}
+
+// visit records an event of size bytes that has been emitted.
+func (st *Stats) visit(evt *event.Event, size int) {
+	if st.Types == nil {
+		st.Types = make(map[event.Type]TypeStats)
+	}
+	ts := st.Types[evt.Type]
+	ts.Events++
+	ts.Bytes += size
+	st.Types[evt.Type] = ts
+	st.Events++
+
+	switch evt.Type {
+	case event.EvString:
+		st.Strings++
+	case event.EvStack:
+		st.Stacks++
+	}
+}
@@ -0,0 +1,219 @@
+package encoding
+
+import (
+	"fmt"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// SchemaError is returned from a Decoder configured with Strict when an event
+// does not conform to the schema of its type or refers to undeclared state. An
+// Encoder returns it as the Err of an *EncodeError.
+type SchemaError struct {
+
+	// Off is the offset of the invalid event in the input stream.
+	Off int
+
+	// Type is the type of the invalid event.
+	Type event.Type
+
+	// Msg describes why the event is invalid.
+	Msg string
+}
+
+// Error implements the error interface.
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf(`invalid %v at 0x%x: %v`, e.Type.Name(), e.Off, e.Msg)
+}
+
+// Is reports if target is ErrInvalidEvent.
+func (e *SchemaError) Is(target error) bool {
+	return target == ErrInvalidEvent
+}
+
+// validator checks events against the schema of their type while tracking the
+// string and stack ids that have been declared, see Strict.
+type validator struct {
+	ver    event.Version
+	argoff int
+
+	// deferred is set when stacks may be declared after they are referenced,
+	// as is the case for traces written by the runtime. The error to report
+	// for the first event referring to each undeclared stack is kept in
+	// pending until the trace ends.
+	deferred bool
+	strings  map[uint64]bool
+	stacks   map[uint64]bool
+	pending  map[uint64]*SchemaError
+}
+
+func newValidator(ver event.Version, argoff int, deferred bool) *validator {
+	return &validator{
+		ver:      ver,
+		argoff:   argoff,
+		deferred: deferred,
+		strings:  make(map[uint64]bool),
+		stacks:   make(map[uint64]bool),
+		pending:  make(map[uint64]*SchemaError),
+	}
+}
+
+func (v *validator) clone() *validator {
+	c := newValidator(v.ver, v.argoff, v.deferred)
+	for id := range v.strings {
+		c.strings[id] = true
+	}
+	for id := range v.stacks {
+		c.stacks[id] = true
+	}
+	for id, err := range v.pending {
+		c.pending[id] = err
+	}
+	return c
+}
+
+// validate checks evt against the schema of its type.
+func (v *validator) validate(evt *event.Event) error {
+	invalid := func(format string, args ...interface{}) *SchemaError {
+		return &SchemaError{
+			Off: evt.Off, Type: evt.Type, Msg: fmt.Sprintf(format, args...)}
+	}
+	if !evt.Type.Valid() {
+		return invalid(`event type is not valid`)
+	}
+
+	switch evt.Type {
+	case event.EvString:
+		if len(evt.Args) != 1 {
+			return invalid(`expected 1 argument; got %v`, len(evt.Args))
+		}
+		id := evt.Args[0]
+		if id == 0 {
+			return invalid(`string id 0 is reserved`)
+		}
+		if v.strings[id] {
+			return invalid(`string id %v was already declared`, id)
+		}
+		v.strings[id] = true
+		return nil
+	case event.EvStack:
+		if err := v.validateStack(evt); err != `` {
+			return invalid(`%v`, err)
+		}
+		return nil
+	}
+	if len(evt.Args) < v.argoff {
+		return invalid(`expected a sequence argument`)
+	}
+
+	// Events prior to Version2 have a sequence preceding their arguments and
+	// may omit the sequence arguments that later versions declare.
+	names, args := evt.Type.Args(), evt.Args[v.argoff:]
+	if evt.Type == event.EvGCSTWStart && v.ver < event.Version5 {
+		// The kind argument is emitted from Go 1.10.
+		names = names[:1]
+	}
+	min, max := len(names), len(names)
+	if v.ver == event.Version1 {
+		for _, name := range names {
+			if name == event.ArgSequence || name == event.ArgSequenceGC {
+				min--
+			}
+		}
+	}
+	if got := len(args); got < min || got > max {
+		if min == max {
+			return invalid(`expected %v arguments; got %v`, max, got)
+		}
+		return invalid(`expected %v to %v arguments; got %v`, min, max, got)
+	}
+	if len(args) < len(names) {
+		// Argument positions are unknown when any are omitted.
+		return nil
+	}
+
+	for i, name := range names {
+		switch id := args[i]; name {
+		case event.ArgNewStackID:
+			// Version1 has the start PC of the new goroutine in place of a stack.
+			if v.ver == event.Version1 {
+				continue
+			}
+			fallthrough
+		case event.ArgStackID:
+			if id == 0 || v.stacks[id] || v.pending[id] != nil {
+				continue
+			}
+			err := invalid(`stack id %v was never declared`, id)
+			if !v.deferred {
+				err.Msg = fmt.Sprintf(`stack id %v was not declared`, id)
+				return err
+			}
+			v.pending[id] = err
+		case event.ArgLabelStringID, event.ArgNameStringID, event.ArgKeyStringID:
+			if id != 0 && !v.strings[id] {
+				return invalid(`%v %v was not declared`, refNames[name], id)
+			}
+		}
+	}
+	return nil
+}
+
+// validateStack checks the frames of a stack event, which carry string ids for
+// the func and file names from Version2 onward.
+func (v *validator) validateStack(evt *event.Event) string {
+	if len(evt.Args) < 2 {
+		return fmt.Sprintf(`expected at least 2 arguments; got %v`, len(evt.Args))
+	}
+
+	id, size := evt.Args[0], evt.Args[1]
+	if id == 0 {
+		return `stack id 0 is reserved`
+	}
+	if v.stacks[id] {
+		return fmt.Sprintf(`stack id %v was already declared`, id)
+	}
+
+	frameSize := 4
+	if v.ver == event.Version1 {
+		frameSize = 1
+	}
+	frames := evt.Args[2:]
+	if got := uint64(len(frames)); got != size*uint64(frameSize) {
+		return fmt.Sprintf(`stack size %v does not match %v frame arguments`, size, got)
+	}
+	for i := 0; frameSize == 4 && i < len(frames); i += frameSize {
+		for _, str := range frames[i+1 : i+3] {
+			// The runtime uses id 0 for frames it could not symbolize.
+			if str != 0 && !v.strings[str] {
+				return fmt.Sprintf(`frame string id %v was not declared`, str)
+			}
+		}
+	}
+
+	v.stacks[id] = true
+	delete(v.pending, id)
+	return ``
+}
+
+// unresolved returns an error for the first event referring to a stack that was
+// never declared.
+func (v *validator) unresolved() error {
+	var first *SchemaError
+	for _, err := range v.pending {
+		if first == nil || err.Off < first.Off {
+			first = err
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	return first
+}
+
+// refNames describe the string id arguments in error messages.
+var refNames = map[string]string{
+	event.ArgLabelStringID: `label string id`,
+	event.ArgNameStringID:  `name string id`,
+	event.ArgKeyStringID:   `key string id`,
+}
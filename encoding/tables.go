@@ -0,0 +1,148 @@
+package encoding
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// InternString returns the id of the EvString event declaring s, emitting one
+// with the next unused id the first time s is given. As with the runtime the
+// empty string has an id of 0 and is never emitted.
+//
+// Ids of EvString events given to Emit are never reused, allowing interned
+// strings to be mixed with a string table managed by the caller.
+func (e *Encoder) InternString(s string) (uint64, error) {
+	e.lock()
+	defer e.unlock()
+	return e.internString(s)
+}
+
+func (e *Encoder) internString(s string) (uint64, error) {
+	if e.encode == nil {
+		e.init()
+	}
+	if e.err != nil {
+		return 0, e.err
+	}
+	if e.ver < event.EvString.Since() {
+		return 0, fmt.Errorf(`version %v does not support strings`, e.ver)
+	}
+	if s == `` {
+		return 0, nil
+	}
+	if id, ok := e.tab.strings[s]; ok {
+		return id, nil
+	}
+
+	id := e.tab.stringID + 1
+	evt := &event.Event{Type: event.EvString, Args: []uint64{id}, Data: []byte(s)}
+	if _, err := e.emit(evt); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// EmitStack returns the id of the EvStack event declaring stk, emitting one
+// with the next unused id the first time an identical stack is given. The
+// function and file names of each frame are interned as if by InternString.
+// As with the runtime the empty stack has an id of 0 and is never emitted.
+//
+// Ids of EvStack events given to Emit are never reused, allowing stacks from
+// EmitStack to be mixed with a stack table managed by the caller.
+func (e *Encoder) EmitStack(stk event.Stack) (uint64, error) {
+	e.lock()
+	defer e.unlock()
+	if e.encode == nil {
+		e.init()
+	}
+	if e.err != nil {
+		return 0, e.err
+	}
+	if len(stk) == 0 {
+		return 0, nil
+	}
+
+	// Version1 frames contain only the PC, the remaining arguments are removed
+	// when the stack is emitted.
+	evt := &event.Event{Type: event.EvStack, Args: make([]uint64, 2, 2+len(stk)*4)}
+	evt.Args[1] = uint64(len(stk))
+	for _, f := range stk {
+		var fn, file uint64
+		if e.ver >= event.EvString.Since() {
+			var err error
+			if fn, err = e.internString(f.Func()); err != nil {
+				return 0, err
+			}
+			if file, err = e.internString(f.File()); err != nil {
+				return 0, err
+			}
+		}
+		evt.Args = append(evt.Args, f.PC(), fn, file, uint64(f.Line()))
+	}
+	if id, ok := e.tab.stacks[stackKey(evt)]; ok {
+		return id, nil
+	}
+
+	evt.Args[0] = e.tab.stackID + 1
+	if _, err := e.emit(evt); err != nil {
+		return 0, err
+	}
+	return evt.Args[0], nil
+}
+
+// tables tracks the string and stack ids emitted by an Encoder.
+type tables struct {
+	strings  map[string]uint64
+	stringID uint64
+	stacks   map[string]uint64
+	stackID  uint64
+}
+
+// visit records the ids declared by an event that has been emitted.
+func (t *tables) visit(evt *event.Event) {
+	if len(evt.Args) == 0 {
+		return
+	}
+
+	id := evt.Args[0]
+	switch evt.Type {
+	case event.EvString:
+		if t.strings == nil {
+			t.strings = make(map[string]uint64)
+		}
+		if _, ok := t.strings[string(evt.Data)]; !ok {
+			t.strings[string(evt.Data)] = id
+		}
+		if id > t.stringID {
+			t.stringID = id
+		}
+	case event.EvStack:
+		if t.stacks == nil {
+			t.stacks = make(map[string]uint64)
+		}
+		if key := stackKey(evt); len(key) > 0 {
+			if _, ok := t.stacks[key]; !ok {
+				t.stacks[key] = id
+			}
+		}
+		if id > t.stackID {
+			t.stackID = id
+		}
+	}
+}
+
+// stackKey returns the frame arguments of a stack event in a form suitable for
+// use as a map key.
+func stackKey(evt *event.Event) string {
+	if len(evt.Args) < 2 {
+		return ``
+	}
+
+	var buf bytes.Buffer
+	for _, arg := range evt.Args[1:] {
+		encodeUleb(&buf, arg)
+	}
+	return buf.String()
+}
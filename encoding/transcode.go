@@ -0,0 +1,64 @@
+package encoding
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// TransformFunc is called for each event before it is encoded, see Transform.
+// It may modify evt in place and returns false to drop the event.
+type TransformFunc func(evt *event.Event) (keep bool, err error)
+
+// Transform configures an Encoder to pass each event given to Emit through fn,
+// such as the events decoded by Transcode. It is a Hook for functions which
+// modify events in place, so the caller of Emit must permit it. Transforms and
+// hooks run in the order they are given.
+func Transform(fn TransformFunc) Option {
+	return Hook(func(evt *event.Event) (*event.Event, error) {
+		keep, err := fn(evt)
+		if err != nil || !keep {
+			return nil, err
+		}
+		return evt, nil
+	})
+}
+
+// Transcode decodes the trace read from src and encodes it to dst, passing each
+// event through any hooks given with Transform or Hook. The output has the same
+// version as the input unless another is selected with TargetVersion. The
+// remaining options are given to both the Decoder and Encoder.
+//
+// The Encoder is closed once the final event is written, dst is not. The first
+// error from decoding, a transform or encoding is returned. Traces in the
+// Version1 format may only be transcoded with the Fidelity option, as their
+// events do not share the layout expected by the Encoder otherwise.
+func Transcode(dst io.Writer, src io.Reader, opts ...Option) error {
+	dec := NewDecoder(src, opts...)
+	ver, err := dec.Version()
+	if err != nil {
+		return err
+	}
+	o := dec.state.opts
+	if ver == event.Version1 && !o.fidelity {
+		return fmt.Errorf(`transcoding %v requires the Fidelity option`, ver)
+	}
+	if o.fidelity && o.target != 0 && o.target != ver {
+		return fmt.Errorf(`transcoding %v to %v is not supported with Fidelity`,
+			ver, o.target)
+	}
+	if o.target == 0 {
+		opts = append(opts[:len(opts):len(opts)], TargetVersion(ver))
+	}
+	enc := NewEncoder(dst, opts...)
+	err = dec.VisitAll(visitFunc(enc.Emit))
+	if err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+type visitFunc func(evt *event.Event) error
+
+func (fn visitFunc) Visit(evt *event.Event) error { return fn(evt) }
@@ -0,0 +1,21 @@
+package encoding
+
+import (
+	"io"
+)
+
+// AppendUleb appends v to dst as an unsigned little endian base128 value, the
+// variable length encoding used for the arguments of every trace event.
+func AppendUleb(dst []byte, v uint64) []byte {
+	for ; v >= 0x80; v >>= 7 {
+		dst = append(dst, 0x80|byte(v))
+	}
+	return append(dst, byte(v))
+}
+
+// ReadUleb reads one unsigned little endian base128 value from r. As with the
+// runtime a value may span at most 10 bytes, an error is returned for longer
+// values.
+func ReadUleb(r io.ByteReader) (uint64, error) {
+	return decodeUleb(r)
+}
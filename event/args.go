@@ -0,0 +1,1495 @@
+// Code generated by argsgen; DO NOT EDIT.
+
+package event
+
+import "fmt"
+
+// Indexes of the arguments of each type of event within the Args field of
+// an Event, in the layout of Version2 and later. See Schemas for the layout
+// of Version1 and the arguments absent from other versions.
+const (
+	IdxBatchProcessorID            = 0
+	IdxBatchTimestamp              = 1
+	IdxFrequencyFrequency          = 0
+	IdxStackStackID                = 0
+	IdxStackStackSize              = 1
+	IdxGomaxprocsTimestamp         = 0
+	IdxGomaxprocsGomaxprocs        = 1
+	IdxGomaxprocsStackID           = 2
+	IdxProcStartTimestamp          = 0
+	IdxProcStartThreadID           = 1
+	IdxProcStopTimestamp           = 0
+	IdxGCStartTimestamp            = 0
+	IdxGCStartSequenceGC           = 1
+	IdxGCStartStackID              = 2
+	IdxGCDoneTimestamp             = 0
+	IdxGCSTWStartTimestamp         = 0
+	IdxGCSTWStartKind              = 1
+	IdxGCSTWDoneTimestamp          = 0
+	IdxGCSweepStartTimestamp       = 0
+	IdxGCSweepStartStackID         = 1
+	IdxGCSweepDoneTimestamp        = 0
+	IdxGoCreateTimestamp           = 0
+	IdxGoCreateNewGoroutineID      = 1
+	IdxGoCreateNewStackID          = 2
+	IdxGoCreateStackID             = 3
+	IdxGoStartTimestamp            = 0
+	IdxGoStartGoroutineID          = 1
+	IdxGoStartSequence             = 2
+	IdxGoEndTimestamp              = 0
+	IdxGoStopTimestamp             = 0
+	IdxGoStopStackID               = 1
+	IdxGoSchedTimestamp            = 0
+	IdxGoSchedStackID              = 1
+	IdxGoPreemptTimestamp          = 0
+	IdxGoPreemptStackID            = 1
+	IdxGoSleepTimestamp            = 0
+	IdxGoSleepStackID              = 1
+	IdxGoBlockTimestamp            = 0
+	IdxGoBlockStackID              = 1
+	IdxGoUnblockTimestamp          = 0
+	IdxGoUnblockGoroutineID        = 1
+	IdxGoUnblockSequence           = 2
+	IdxGoUnblockStackID            = 3
+	IdxGoBlockSendTimestamp        = 0
+	IdxGoBlockSendStackID          = 1
+	IdxGoBlockRecvTimestamp        = 0
+	IdxGoBlockRecvStackID          = 1
+	IdxGoBlockSelectTimestamp      = 0
+	IdxGoBlockSelectStackID        = 1
+	IdxGoBlockSyncTimestamp        = 0
+	IdxGoBlockSyncStackID          = 1
+	IdxGoBlockCondTimestamp        = 0
+	IdxGoBlockCondStackID          = 1
+	IdxGoBlockNetTimestamp         = 0
+	IdxGoBlockNetStackID           = 1
+	IdxGoSysCallTimestamp          = 0
+	IdxGoSysCallStackID            = 1
+	IdxGoSysExitTimestamp          = 0
+	IdxGoSysExitGoroutineID        = 1
+	IdxGoSysExitSequence           = 2
+	IdxGoSysExitRealTimestamp      = 3
+	IdxGoSysBlockTimestamp         = 0
+	IdxGoWaitingTimestamp          = 0
+	IdxGoWaitingGoroutineID        = 1
+	IdxGoInSyscallTimestamp        = 0
+	IdxGoInSyscallGoroutineID      = 1
+	IdxHeapAllocTimestamp          = 0
+	IdxHeapAllocHeapAlloc          = 1
+	IdxNextGCTimestamp             = 0
+	IdxNextGCNextGC                = 1
+	IdxTimerGoroutineGoroutineID   = 0
+	IdxFutileWakeupTimestamp       = 0
+	IdxStringStringID              = 0
+	IdxGoStartLocalTimestamp       = 0
+	IdxGoStartLocalGoroutineID     = 1
+	IdxGoUnblockLocalTimestamp     = 0
+	IdxGoUnblockLocalGoroutineID   = 1
+	IdxGoUnblockLocalStackID       = 2
+	IdxGoSysExitLocalTimestamp     = 0
+	IdxGoSysExitLocalGoroutineID   = 1
+	IdxGoSysExitLocalRealTimestamp = 2
+	IdxGoStartLabelTimestamp       = 0
+	IdxGoStartLabelGoroutineID     = 1
+	IdxGoStartLabelSequence        = 2
+	IdxGoStartLabelLabelStringID   = 3
+	IdxGoBlockGCTimestamp          = 0
+	IdxGoBlockGCStackID            = 1
+	IdxGCMarkAssistStartTimestamp  = 0
+	IdxGCMarkAssistStartStackID    = 1
+	IdxGCMarkAssistDoneTimestamp   = 0
+	IdxUserTaskCreateTimestamp     = 0
+	IdxUserTaskCreateTaskID        = 1
+	IdxUserTaskCreateParentTaskID  = 2
+	IdxUserTaskCreateNameStringID  = 3
+	IdxUserTaskCreateStackID       = 4
+	IdxUserTaskEndTimestamp        = 0
+	IdxUserTaskEndTaskID           = 1
+	IdxUserTaskEndStackID          = 2
+	IdxUserRegionTimestamp         = 0
+	IdxUserRegionTaskID            = 1
+	IdxUserRegionMode              = 2
+	IdxUserRegionNameStringID      = 3
+	IdxUserRegionStackID           = 4
+	IdxUserLogTimestamp            = 0
+	IdxUserLogTaskID               = 1
+	IdxUserLogKeyStringID          = 2
+	IdxUserLogStackID              = 3
+	IdxCPUSampleTimestamp          = 0
+	IdxCPUSampleRealTimestamp      = 1
+	IdxCPUSampleProcessorID        = 2
+	IdxCPUSampleGoroutineID        = 3
+	IdxCPUSampleStackID            = 4
+)
+
+// BatchArgs holds the arguments of an EvBatch event.
+type BatchArgs struct {
+	ProcessorID uint64
+	Timestamp   uint64
+}
+
+// Batch returns the arguments of an EvBatch event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) Batch(v Version) (args BatchArgs, ok bool) {
+	idx, ok := e.layout(v, EvBatch)
+	if !ok {
+		return args, false
+	}
+	args.ProcessorID = e.at(idx, IdxBatchProcessorID)
+	args.Timestamp = e.at(idx, IdxBatchTimestamp)
+	return args, true
+}
+
+// FrequencyArgs holds the arguments of an EvFrequency event.
+type FrequencyArgs struct {
+	Frequency uint64
+}
+
+// Frequency returns the arguments of an EvFrequency event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) Frequency(v Version) (args FrequencyArgs, ok bool) {
+	idx, ok := e.layout(v, EvFrequency)
+	if !ok {
+		return args, false
+	}
+	args.Frequency = e.at(idx, IdxFrequencyFrequency)
+	return args, true
+}
+
+// StackArgs holds the arguments of an EvStack event.
+type StackArgs struct {
+	StackID   uint64
+	StackSize uint64
+}
+
+// Stack returns the arguments of an EvStack event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) Stack(v Version) (args StackArgs, ok bool) {
+	idx, ok := e.layout(v, EvStack)
+	if !ok {
+		return args, false
+	}
+	args.StackID = e.at(idx, IdxStackStackID)
+	args.StackSize = e.at(idx, IdxStackStackSize)
+	return args, true
+}
+
+// GomaxprocsArgs holds the arguments of an EvGomaxprocs event.
+type GomaxprocsArgs struct {
+	Timestamp  uint64
+	Gomaxprocs uint64
+	StackID    uint64
+}
+
+// Gomaxprocs returns the arguments of an EvGomaxprocs event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) Gomaxprocs(v Version) (args GomaxprocsArgs, ok bool) {
+	idx, ok := e.layout(v, EvGomaxprocs)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGomaxprocsTimestamp)
+	args.Gomaxprocs = e.at(idx, IdxGomaxprocsGomaxprocs)
+	args.StackID = e.at(idx, IdxGomaxprocsStackID)
+	return args, true
+}
+
+// ProcStartArgs holds the arguments of an EvProcStart event.
+type ProcStartArgs struct {
+	Timestamp uint64
+	ThreadID  uint64
+}
+
+// ProcStart returns the arguments of an EvProcStart event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) ProcStart(v Version) (args ProcStartArgs, ok bool) {
+	idx, ok := e.layout(v, EvProcStart)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxProcStartTimestamp)
+	args.ThreadID = e.at(idx, IdxProcStartThreadID)
+	return args, true
+}
+
+// ProcStopArgs holds the arguments of an EvProcStop event.
+type ProcStopArgs struct {
+	Timestamp uint64
+}
+
+// ProcStop returns the arguments of an EvProcStop event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) ProcStop(v Version) (args ProcStopArgs, ok bool) {
+	idx, ok := e.layout(v, EvProcStop)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxProcStopTimestamp)
+	return args, true
+}
+
+// GCStartArgs holds the arguments of an EvGCStart event.
+type GCStartArgs struct {
+	Timestamp  uint64
+	SequenceGC uint64
+	StackID    uint64
+}
+
+// GCStart returns the arguments of an EvGCStart event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GCStart(v Version) (args GCStartArgs, ok bool) {
+	idx, ok := e.layout(v, EvGCStart)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGCStartTimestamp)
+	args.SequenceGC = e.at(idx, IdxGCStartSequenceGC)
+	args.StackID = e.at(idx, IdxGCStartStackID)
+	return args, true
+}
+
+// GCDoneArgs holds the arguments of an EvGCDone event.
+type GCDoneArgs struct {
+	Timestamp uint64
+}
+
+// GCDone returns the arguments of an EvGCDone event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GCDone(v Version) (args GCDoneArgs, ok bool) {
+	idx, ok := e.layout(v, EvGCDone)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGCDoneTimestamp)
+	return args, true
+}
+
+// GCSTWStartArgs holds the arguments of an EvGCSTWStart event.
+type GCSTWStartArgs struct {
+	Timestamp uint64
+	Kind      uint64
+}
+
+// GCSTWStart returns the arguments of an EvGCSTWStart event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GCSTWStart(v Version) (args GCSTWStartArgs, ok bool) {
+	idx, ok := e.layout(v, EvGCSTWStart)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGCSTWStartTimestamp)
+	args.Kind = e.at(idx, IdxGCSTWStartKind)
+	return args, true
+}
+
+// GCSTWDoneArgs holds the arguments of an EvGCSTWDone event.
+type GCSTWDoneArgs struct {
+	Timestamp uint64
+}
+
+// GCSTWDone returns the arguments of an EvGCSTWDone event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GCSTWDone(v Version) (args GCSTWDoneArgs, ok bool) {
+	idx, ok := e.layout(v, EvGCSTWDone)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGCSTWDoneTimestamp)
+	return args, true
+}
+
+// GCSweepStartArgs holds the arguments of an EvGCSweepStart event.
+type GCSweepStartArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GCSweepStart returns the arguments of an EvGCSweepStart event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GCSweepStart(v Version) (args GCSweepStartArgs, ok bool) {
+	idx, ok := e.layout(v, EvGCSweepStart)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGCSweepStartTimestamp)
+	args.StackID = e.at(idx, IdxGCSweepStartStackID)
+	return args, true
+}
+
+// GCSweepDoneArgs holds the arguments of an EvGCSweepDone event.
+type GCSweepDoneArgs struct {
+	Timestamp uint64
+}
+
+// GCSweepDone returns the arguments of an EvGCSweepDone event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GCSweepDone(v Version) (args GCSweepDoneArgs, ok bool) {
+	idx, ok := e.layout(v, EvGCSweepDone)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGCSweepDoneTimestamp)
+	return args, true
+}
+
+// GoCreateArgs holds the arguments of an EvGoCreate event.
+type GoCreateArgs struct {
+	Timestamp      uint64
+	NewGoroutineID uint64
+	NewStackID     uint64
+	StackID        uint64
+}
+
+// GoCreate returns the arguments of an EvGoCreate event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoCreate(v Version) (args GoCreateArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoCreate)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoCreateTimestamp)
+	args.NewGoroutineID = e.at(idx, IdxGoCreateNewGoroutineID)
+	args.NewStackID = e.at(idx, IdxGoCreateNewStackID)
+	args.StackID = e.at(idx, IdxGoCreateStackID)
+	return args, true
+}
+
+// GoStartArgs holds the arguments of an EvGoStart event.
+type GoStartArgs struct {
+	Timestamp   uint64
+	GoroutineID uint64
+	Sequence    uint64
+}
+
+// GoStart returns the arguments of an EvGoStart event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoStart(v Version) (args GoStartArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoStart)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoStartTimestamp)
+	args.GoroutineID = e.at(idx, IdxGoStartGoroutineID)
+	args.Sequence = e.at(idx, IdxGoStartSequence)
+	return args, true
+}
+
+// GoEndArgs holds the arguments of an EvGoEnd event.
+type GoEndArgs struct {
+	Timestamp uint64
+}
+
+// GoEnd returns the arguments of an EvGoEnd event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoEnd(v Version) (args GoEndArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoEnd)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoEndTimestamp)
+	return args, true
+}
+
+// GoStopArgs holds the arguments of an EvGoStop event.
+type GoStopArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GoStop returns the arguments of an EvGoStop event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoStop(v Version) (args GoStopArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoStop)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoStopTimestamp)
+	args.StackID = e.at(idx, IdxGoStopStackID)
+	return args, true
+}
+
+// GoSchedArgs holds the arguments of an EvGoSched event.
+type GoSchedArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GoSched returns the arguments of an EvGoSched event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoSched(v Version) (args GoSchedArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoSched)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoSchedTimestamp)
+	args.StackID = e.at(idx, IdxGoSchedStackID)
+	return args, true
+}
+
+// GoPreemptArgs holds the arguments of an EvGoPreempt event.
+type GoPreemptArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GoPreempt returns the arguments of an EvGoPreempt event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoPreempt(v Version) (args GoPreemptArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoPreempt)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoPreemptTimestamp)
+	args.StackID = e.at(idx, IdxGoPreemptStackID)
+	return args, true
+}
+
+// GoSleepArgs holds the arguments of an EvGoSleep event.
+type GoSleepArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GoSleep returns the arguments of an EvGoSleep event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoSleep(v Version) (args GoSleepArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoSleep)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoSleepTimestamp)
+	args.StackID = e.at(idx, IdxGoSleepStackID)
+	return args, true
+}
+
+// GoBlockArgs holds the arguments of an EvGoBlock event.
+type GoBlockArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GoBlock returns the arguments of an EvGoBlock event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoBlock(v Version) (args GoBlockArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoBlock)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoBlockTimestamp)
+	args.StackID = e.at(idx, IdxGoBlockStackID)
+	return args, true
+}
+
+// GoUnblockArgs holds the arguments of an EvGoUnblock event.
+type GoUnblockArgs struct {
+	Timestamp   uint64
+	GoroutineID uint64
+	Sequence    uint64
+	StackID     uint64
+}
+
+// GoUnblock returns the arguments of an EvGoUnblock event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoUnblock(v Version) (args GoUnblockArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoUnblock)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoUnblockTimestamp)
+	args.GoroutineID = e.at(idx, IdxGoUnblockGoroutineID)
+	args.Sequence = e.at(idx, IdxGoUnblockSequence)
+	args.StackID = e.at(idx, IdxGoUnblockStackID)
+	return args, true
+}
+
+// GoBlockSendArgs holds the arguments of an EvGoBlockSend event.
+type GoBlockSendArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GoBlockSend returns the arguments of an EvGoBlockSend event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoBlockSend(v Version) (args GoBlockSendArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoBlockSend)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoBlockSendTimestamp)
+	args.StackID = e.at(idx, IdxGoBlockSendStackID)
+	return args, true
+}
+
+// GoBlockRecvArgs holds the arguments of an EvGoBlockRecv event.
+type GoBlockRecvArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GoBlockRecv returns the arguments of an EvGoBlockRecv event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoBlockRecv(v Version) (args GoBlockRecvArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoBlockRecv)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoBlockRecvTimestamp)
+	args.StackID = e.at(idx, IdxGoBlockRecvStackID)
+	return args, true
+}
+
+// GoBlockSelectArgs holds the arguments of an EvGoBlockSelect event.
+type GoBlockSelectArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GoBlockSelect returns the arguments of an EvGoBlockSelect event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoBlockSelect(v Version) (args GoBlockSelectArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoBlockSelect)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoBlockSelectTimestamp)
+	args.StackID = e.at(idx, IdxGoBlockSelectStackID)
+	return args, true
+}
+
+// GoBlockSyncArgs holds the arguments of an EvGoBlockSync event.
+type GoBlockSyncArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GoBlockSync returns the arguments of an EvGoBlockSync event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoBlockSync(v Version) (args GoBlockSyncArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoBlockSync)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoBlockSyncTimestamp)
+	args.StackID = e.at(idx, IdxGoBlockSyncStackID)
+	return args, true
+}
+
+// GoBlockCondArgs holds the arguments of an EvGoBlockCond event.
+type GoBlockCondArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GoBlockCond returns the arguments of an EvGoBlockCond event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoBlockCond(v Version) (args GoBlockCondArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoBlockCond)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoBlockCondTimestamp)
+	args.StackID = e.at(idx, IdxGoBlockCondStackID)
+	return args, true
+}
+
+// GoBlockNetArgs holds the arguments of an EvGoBlockNet event.
+type GoBlockNetArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GoBlockNet returns the arguments of an EvGoBlockNet event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoBlockNet(v Version) (args GoBlockNetArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoBlockNet)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoBlockNetTimestamp)
+	args.StackID = e.at(idx, IdxGoBlockNetStackID)
+	return args, true
+}
+
+// GoSysCallArgs holds the arguments of an EvGoSysCall event.
+type GoSysCallArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GoSysCall returns the arguments of an EvGoSysCall event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoSysCall(v Version) (args GoSysCallArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoSysCall)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoSysCallTimestamp)
+	args.StackID = e.at(idx, IdxGoSysCallStackID)
+	return args, true
+}
+
+// GoSysExitArgs holds the arguments of an EvGoSysExit event.
+type GoSysExitArgs struct {
+	Timestamp     uint64
+	GoroutineID   uint64
+	Sequence      uint64
+	RealTimestamp uint64
+}
+
+// GoSysExit returns the arguments of an EvGoSysExit event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoSysExit(v Version) (args GoSysExitArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoSysExit)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoSysExitTimestamp)
+	args.GoroutineID = e.at(idx, IdxGoSysExitGoroutineID)
+	args.Sequence = e.at(idx, IdxGoSysExitSequence)
+	args.RealTimestamp = e.at(idx, IdxGoSysExitRealTimestamp)
+	return args, true
+}
+
+// GoSysBlockArgs holds the arguments of an EvGoSysBlock event.
+type GoSysBlockArgs struct {
+	Timestamp uint64
+}
+
+// GoSysBlock returns the arguments of an EvGoSysBlock event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoSysBlock(v Version) (args GoSysBlockArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoSysBlock)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoSysBlockTimestamp)
+	return args, true
+}
+
+// GoWaitingArgs holds the arguments of an EvGoWaiting event.
+type GoWaitingArgs struct {
+	Timestamp   uint64
+	GoroutineID uint64
+}
+
+// GoWaiting returns the arguments of an EvGoWaiting event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoWaiting(v Version) (args GoWaitingArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoWaiting)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoWaitingTimestamp)
+	args.GoroutineID = e.at(idx, IdxGoWaitingGoroutineID)
+	return args, true
+}
+
+// GoInSyscallArgs holds the arguments of an EvGoInSyscall event.
+type GoInSyscallArgs struct {
+	Timestamp   uint64
+	GoroutineID uint64
+}
+
+// GoInSyscall returns the arguments of an EvGoInSyscall event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoInSyscall(v Version) (args GoInSyscallArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoInSyscall)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoInSyscallTimestamp)
+	args.GoroutineID = e.at(idx, IdxGoInSyscallGoroutineID)
+	return args, true
+}
+
+// HeapAllocArgs holds the arguments of an EvHeapAlloc event.
+type HeapAllocArgs struct {
+	Timestamp uint64
+	HeapAlloc uint64
+}
+
+// HeapAlloc returns the arguments of an EvHeapAlloc event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) HeapAlloc(v Version) (args HeapAllocArgs, ok bool) {
+	idx, ok := e.layout(v, EvHeapAlloc)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxHeapAllocTimestamp)
+	args.HeapAlloc = e.at(idx, IdxHeapAllocHeapAlloc)
+	return args, true
+}
+
+// NextGCArgs holds the arguments of an EvNextGC event.
+type NextGCArgs struct {
+	Timestamp uint64
+	NextGC    uint64
+}
+
+// NextGC returns the arguments of an EvNextGC event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) NextGC(v Version) (args NextGCArgs, ok bool) {
+	idx, ok := e.layout(v, EvNextGC)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxNextGCTimestamp)
+	args.NextGC = e.at(idx, IdxNextGCNextGC)
+	return args, true
+}
+
+// TimerGoroutineArgs holds the arguments of an EvTimerGoroutine event.
+type TimerGoroutineArgs struct {
+	GoroutineID uint64
+}
+
+// TimerGoroutine returns the arguments of an EvTimerGoroutine event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) TimerGoroutine(v Version) (args TimerGoroutineArgs, ok bool) {
+	idx, ok := e.layout(v, EvTimerGoroutine)
+	if !ok {
+		return args, false
+	}
+	args.GoroutineID = e.at(idx, IdxTimerGoroutineGoroutineID)
+	return args, true
+}
+
+// FutileWakeupArgs holds the arguments of an EvFutileWakeup event.
+type FutileWakeupArgs struct {
+	Timestamp uint64
+}
+
+// FutileWakeup returns the arguments of an EvFutileWakeup event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) FutileWakeup(v Version) (args FutileWakeupArgs, ok bool) {
+	idx, ok := e.layout(v, EvFutileWakeup)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxFutileWakeupTimestamp)
+	return args, true
+}
+
+// StringArgs holds the arguments of an EvString event.
+type StringArgs struct {
+	StringID uint64
+}
+
+// StringEntry returns the arguments of an EvString event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) StringEntry(v Version) (args StringArgs, ok bool) {
+	idx, ok := e.layout(v, EvString)
+	if !ok {
+		return args, false
+	}
+	args.StringID = e.at(idx, IdxStringStringID)
+	return args, true
+}
+
+// GoStartLocalArgs holds the arguments of an EvGoStartLocal event.
+type GoStartLocalArgs struct {
+	Timestamp   uint64
+	GoroutineID uint64
+}
+
+// GoStartLocal returns the arguments of an EvGoStartLocal event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoStartLocal(v Version) (args GoStartLocalArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoStartLocal)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoStartLocalTimestamp)
+	args.GoroutineID = e.at(idx, IdxGoStartLocalGoroutineID)
+	return args, true
+}
+
+// GoUnblockLocalArgs holds the arguments of an EvGoUnblockLocal event.
+type GoUnblockLocalArgs struct {
+	Timestamp   uint64
+	GoroutineID uint64
+	StackID     uint64
+}
+
+// GoUnblockLocal returns the arguments of an EvGoUnblockLocal event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoUnblockLocal(v Version) (args GoUnblockLocalArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoUnblockLocal)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoUnblockLocalTimestamp)
+	args.GoroutineID = e.at(idx, IdxGoUnblockLocalGoroutineID)
+	args.StackID = e.at(idx, IdxGoUnblockLocalStackID)
+	return args, true
+}
+
+// GoSysExitLocalArgs holds the arguments of an EvGoSysExitLocal event.
+type GoSysExitLocalArgs struct {
+	Timestamp     uint64
+	GoroutineID   uint64
+	RealTimestamp uint64
+}
+
+// GoSysExitLocal returns the arguments of an EvGoSysExitLocal event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoSysExitLocal(v Version) (args GoSysExitLocalArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoSysExitLocal)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoSysExitLocalTimestamp)
+	args.GoroutineID = e.at(idx, IdxGoSysExitLocalGoroutineID)
+	args.RealTimestamp = e.at(idx, IdxGoSysExitLocalRealTimestamp)
+	return args, true
+}
+
+// GoStartLabelArgs holds the arguments of an EvGoStartLabel event.
+type GoStartLabelArgs struct {
+	Timestamp     uint64
+	GoroutineID   uint64
+	Sequence      uint64
+	LabelStringID uint64
+}
+
+// GoStartLabel returns the arguments of an EvGoStartLabel event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoStartLabel(v Version) (args GoStartLabelArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoStartLabel)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoStartLabelTimestamp)
+	args.GoroutineID = e.at(idx, IdxGoStartLabelGoroutineID)
+	args.Sequence = e.at(idx, IdxGoStartLabelSequence)
+	args.LabelStringID = e.at(idx, IdxGoStartLabelLabelStringID)
+	return args, true
+}
+
+// GoBlockGCArgs holds the arguments of an EvGoBlockGC event.
+type GoBlockGCArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GoBlockGC returns the arguments of an EvGoBlockGC event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GoBlockGC(v Version) (args GoBlockGCArgs, ok bool) {
+	idx, ok := e.layout(v, EvGoBlockGC)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGoBlockGCTimestamp)
+	args.StackID = e.at(idx, IdxGoBlockGCStackID)
+	return args, true
+}
+
+// GCMarkAssistStartArgs holds the arguments of an EvGCMarkAssistStart event.
+type GCMarkAssistStartArgs struct {
+	Timestamp uint64
+	StackID   uint64
+}
+
+// GCMarkAssistStart returns the arguments of an EvGCMarkAssistStart event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GCMarkAssistStart(v Version) (args GCMarkAssistStartArgs, ok bool) {
+	idx, ok := e.layout(v, EvGCMarkAssistStart)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGCMarkAssistStartTimestamp)
+	args.StackID = e.at(idx, IdxGCMarkAssistStartStackID)
+	return args, true
+}
+
+// GCMarkAssistDoneArgs holds the arguments of an EvGCMarkAssistDone event.
+type GCMarkAssistDoneArgs struct {
+	Timestamp uint64
+}
+
+// GCMarkAssistDone returns the arguments of an EvGCMarkAssistDone event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) GCMarkAssistDone(v Version) (args GCMarkAssistDoneArgs, ok bool) {
+	idx, ok := e.layout(v, EvGCMarkAssistDone)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxGCMarkAssistDoneTimestamp)
+	return args, true
+}
+
+// UserTaskCreateArgs holds the arguments of an EvUserTaskCreate event.
+type UserTaskCreateArgs struct {
+	Timestamp    uint64
+	TaskID       uint64
+	ParentTaskID uint64
+	NameStringID uint64
+	StackID      uint64
+}
+
+// UserTaskCreate returns the arguments of an EvUserTaskCreate event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) UserTaskCreate(v Version) (args UserTaskCreateArgs, ok bool) {
+	idx, ok := e.layout(v, EvUserTaskCreate)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxUserTaskCreateTimestamp)
+	args.TaskID = e.at(idx, IdxUserTaskCreateTaskID)
+	args.ParentTaskID = e.at(idx, IdxUserTaskCreateParentTaskID)
+	args.NameStringID = e.at(idx, IdxUserTaskCreateNameStringID)
+	args.StackID = e.at(idx, IdxUserTaskCreateStackID)
+	return args, true
+}
+
+// UserTaskEndArgs holds the arguments of an EvUserTaskEnd event.
+type UserTaskEndArgs struct {
+	Timestamp uint64
+	TaskID    uint64
+	StackID   uint64
+}
+
+// UserTaskEnd returns the arguments of an EvUserTaskEnd event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) UserTaskEnd(v Version) (args UserTaskEndArgs, ok bool) {
+	idx, ok := e.layout(v, EvUserTaskEnd)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxUserTaskEndTimestamp)
+	args.TaskID = e.at(idx, IdxUserTaskEndTaskID)
+	args.StackID = e.at(idx, IdxUserTaskEndStackID)
+	return args, true
+}
+
+// UserRegionArgs holds the arguments of an EvUserRegion event.
+type UserRegionArgs struct {
+	Timestamp    uint64
+	TaskID       uint64
+	Mode         uint64
+	NameStringID uint64
+	StackID      uint64
+}
+
+// UserRegion returns the arguments of an EvUserRegion event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) UserRegion(v Version) (args UserRegionArgs, ok bool) {
+	idx, ok := e.layout(v, EvUserRegion)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxUserRegionTimestamp)
+	args.TaskID = e.at(idx, IdxUserRegionTaskID)
+	args.Mode = e.at(idx, IdxUserRegionMode)
+	args.NameStringID = e.at(idx, IdxUserRegionNameStringID)
+	args.StackID = e.at(idx, IdxUserRegionStackID)
+	return args, true
+}
+
+// UserLogArgs holds the arguments of an EvUserLog event.
+type UserLogArgs struct {
+	Timestamp   uint64
+	TaskID      uint64
+	KeyStringID uint64
+	StackID     uint64
+}
+
+// UserLog returns the arguments of an EvUserLog event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) UserLog(v Version) (args UserLogArgs, ok bool) {
+	idx, ok := e.layout(v, EvUserLog)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxUserLogTimestamp)
+	args.TaskID = e.at(idx, IdxUserLogTaskID)
+	args.KeyStringID = e.at(idx, IdxUserLogKeyStringID)
+	args.StackID = e.at(idx, IdxUserLogStackID)
+	return args, true
+}
+
+// CPUSampleArgs holds the arguments of an EvCPUSample event.
+type CPUSampleArgs struct {
+	Timestamp     uint64
+	RealTimestamp uint64
+	ProcessorID   uint64
+	GoroutineID   uint64
+	StackID       uint64
+}
+
+// CPUSample returns the arguments of an EvCPUSample event in the layout of version v.
+// The ok result is false if e is another type of event or has too few
+// arguments, those absent from the layout of v are zero.
+func (e *Event) CPUSample(v Version) (args CPUSampleArgs, ok bool) {
+	idx, ok := e.layout(v, EvCPUSample)
+	if !ok {
+		return args, false
+	}
+	args.Timestamp = e.at(idx, IdxCPUSampleTimestamp)
+	args.RealTimestamp = e.at(idx, IdxCPUSampleRealTimestamp)
+	args.ProcessorID = e.at(idx, IdxCPUSampleProcessorID)
+	args.GoroutineID = e.at(idx, IdxCPUSampleGoroutineID)
+	args.StackID = e.at(idx, IdxCPUSampleStackID)
+	return args, true
+}
+
+// Handler has a method for every type of event, called by Dispatch with the
+// arguments of the event. Types implementing Handler fail to compile when a
+// new type of event is added, ensuring each is handled.
+type Handler interface {
+	HandleBatch(evt *Event, args BatchArgs) error
+	HandleFrequency(evt *Event, args FrequencyArgs) error
+	HandleStack(evt *Event, args StackArgs) error
+	HandleGomaxprocs(evt *Event, args GomaxprocsArgs) error
+	HandleProcStart(evt *Event, args ProcStartArgs) error
+	HandleProcStop(evt *Event, args ProcStopArgs) error
+	HandleGCStart(evt *Event, args GCStartArgs) error
+	HandleGCDone(evt *Event, args GCDoneArgs) error
+	HandleGCSTWStart(evt *Event, args GCSTWStartArgs) error
+	HandleGCSTWDone(evt *Event, args GCSTWDoneArgs) error
+	HandleGCSweepStart(evt *Event, args GCSweepStartArgs) error
+	HandleGCSweepDone(evt *Event, args GCSweepDoneArgs) error
+	HandleGoCreate(evt *Event, args GoCreateArgs) error
+	HandleGoStart(evt *Event, args GoStartArgs) error
+	HandleGoEnd(evt *Event, args GoEndArgs) error
+	HandleGoStop(evt *Event, args GoStopArgs) error
+	HandleGoSched(evt *Event, args GoSchedArgs) error
+	HandleGoPreempt(evt *Event, args GoPreemptArgs) error
+	HandleGoSleep(evt *Event, args GoSleepArgs) error
+	HandleGoBlock(evt *Event, args GoBlockArgs) error
+	HandleGoUnblock(evt *Event, args GoUnblockArgs) error
+	HandleGoBlockSend(evt *Event, args GoBlockSendArgs) error
+	HandleGoBlockRecv(evt *Event, args GoBlockRecvArgs) error
+	HandleGoBlockSelect(evt *Event, args GoBlockSelectArgs) error
+	HandleGoBlockSync(evt *Event, args GoBlockSyncArgs) error
+	HandleGoBlockCond(evt *Event, args GoBlockCondArgs) error
+	HandleGoBlockNet(evt *Event, args GoBlockNetArgs) error
+	HandleGoSysCall(evt *Event, args GoSysCallArgs) error
+	HandleGoSysExit(evt *Event, args GoSysExitArgs) error
+	HandleGoSysBlock(evt *Event, args GoSysBlockArgs) error
+	HandleGoWaiting(evt *Event, args GoWaitingArgs) error
+	HandleGoInSyscall(evt *Event, args GoInSyscallArgs) error
+	HandleHeapAlloc(evt *Event, args HeapAllocArgs) error
+	HandleNextGC(evt *Event, args NextGCArgs) error
+	HandleTimerGoroutine(evt *Event, args TimerGoroutineArgs) error
+	HandleFutileWakeup(evt *Event, args FutileWakeupArgs) error
+	HandleString(evt *Event, args StringArgs) error
+	HandleGoStartLocal(evt *Event, args GoStartLocalArgs) error
+	HandleGoUnblockLocal(evt *Event, args GoUnblockLocalArgs) error
+	HandleGoSysExitLocal(evt *Event, args GoSysExitLocalArgs) error
+	HandleGoStartLabel(evt *Event, args GoStartLabelArgs) error
+	HandleGoBlockGC(evt *Event, args GoBlockGCArgs) error
+	HandleGCMarkAssistStart(evt *Event, args GCMarkAssistStartArgs) error
+	HandleGCMarkAssistDone(evt *Event, args GCMarkAssistDoneArgs) error
+	HandleUserTaskCreate(evt *Event, args UserTaskCreateArgs) error
+	HandleUserTaskEnd(evt *Event, args UserTaskEndArgs) error
+	HandleUserRegion(evt *Event, args UserRegionArgs) error
+	HandleUserLog(evt *Event, args UserLogArgs) error
+	HandleCPUSample(evt *Event, args CPUSampleArgs) error
+}
+
+// NopHandler implements Handler with methods that do nothing, it may be
+// embedded to handle a subset of event types at the cost of being exhaustive.
+type NopHandler struct{}
+
+// HandleBatch implements Handler.
+func (NopHandler) HandleBatch(*Event, BatchArgs) error { return nil }
+
+// HandleFrequency implements Handler.
+func (NopHandler) HandleFrequency(*Event, FrequencyArgs) error { return nil }
+
+// HandleStack implements Handler.
+func (NopHandler) HandleStack(*Event, StackArgs) error { return nil }
+
+// HandleGomaxprocs implements Handler.
+func (NopHandler) HandleGomaxprocs(*Event, GomaxprocsArgs) error { return nil }
+
+// HandleProcStart implements Handler.
+func (NopHandler) HandleProcStart(*Event, ProcStartArgs) error { return nil }
+
+// HandleProcStop implements Handler.
+func (NopHandler) HandleProcStop(*Event, ProcStopArgs) error { return nil }
+
+// HandleGCStart implements Handler.
+func (NopHandler) HandleGCStart(*Event, GCStartArgs) error { return nil }
+
+// HandleGCDone implements Handler.
+func (NopHandler) HandleGCDone(*Event, GCDoneArgs) error { return nil }
+
+// HandleGCSTWStart implements Handler.
+func (NopHandler) HandleGCSTWStart(*Event, GCSTWStartArgs) error { return nil }
+
+// HandleGCSTWDone implements Handler.
+func (NopHandler) HandleGCSTWDone(*Event, GCSTWDoneArgs) error { return nil }
+
+// HandleGCSweepStart implements Handler.
+func (NopHandler) HandleGCSweepStart(*Event, GCSweepStartArgs) error { return nil }
+
+// HandleGCSweepDone implements Handler.
+func (NopHandler) HandleGCSweepDone(*Event, GCSweepDoneArgs) error { return nil }
+
+// HandleGoCreate implements Handler.
+func (NopHandler) HandleGoCreate(*Event, GoCreateArgs) error { return nil }
+
+// HandleGoStart implements Handler.
+func (NopHandler) HandleGoStart(*Event, GoStartArgs) error { return nil }
+
+// HandleGoEnd implements Handler.
+func (NopHandler) HandleGoEnd(*Event, GoEndArgs) error { return nil }
+
+// HandleGoStop implements Handler.
+func (NopHandler) HandleGoStop(*Event, GoStopArgs) error { return nil }
+
+// HandleGoSched implements Handler.
+func (NopHandler) HandleGoSched(*Event, GoSchedArgs) error { return nil }
+
+// HandleGoPreempt implements Handler.
+func (NopHandler) HandleGoPreempt(*Event, GoPreemptArgs) error { return nil }
+
+// HandleGoSleep implements Handler.
+func (NopHandler) HandleGoSleep(*Event, GoSleepArgs) error { return nil }
+
+// HandleGoBlock implements Handler.
+func (NopHandler) HandleGoBlock(*Event, GoBlockArgs) error { return nil }
+
+// HandleGoUnblock implements Handler.
+func (NopHandler) HandleGoUnblock(*Event, GoUnblockArgs) error { return nil }
+
+// HandleGoBlockSend implements Handler.
+func (NopHandler) HandleGoBlockSend(*Event, GoBlockSendArgs) error { return nil }
+
+// HandleGoBlockRecv implements Handler.
+func (NopHandler) HandleGoBlockRecv(*Event, GoBlockRecvArgs) error { return nil }
+
+// HandleGoBlockSelect implements Handler.
+func (NopHandler) HandleGoBlockSelect(*Event, GoBlockSelectArgs) error { return nil }
+
+// HandleGoBlockSync implements Handler.
+func (NopHandler) HandleGoBlockSync(*Event, GoBlockSyncArgs) error { return nil }
+
+// HandleGoBlockCond implements Handler.
+func (NopHandler) HandleGoBlockCond(*Event, GoBlockCondArgs) error { return nil }
+
+// HandleGoBlockNet implements Handler.
+func (NopHandler) HandleGoBlockNet(*Event, GoBlockNetArgs) error { return nil }
+
+// HandleGoSysCall implements Handler.
+func (NopHandler) HandleGoSysCall(*Event, GoSysCallArgs) error { return nil }
+
+// HandleGoSysExit implements Handler.
+func (NopHandler) HandleGoSysExit(*Event, GoSysExitArgs) error { return nil }
+
+// HandleGoSysBlock implements Handler.
+func (NopHandler) HandleGoSysBlock(*Event, GoSysBlockArgs) error { return nil }
+
+// HandleGoWaiting implements Handler.
+func (NopHandler) HandleGoWaiting(*Event, GoWaitingArgs) error { return nil }
+
+// HandleGoInSyscall implements Handler.
+func (NopHandler) HandleGoInSyscall(*Event, GoInSyscallArgs) error { return nil }
+
+// HandleHeapAlloc implements Handler.
+func (NopHandler) HandleHeapAlloc(*Event, HeapAllocArgs) error { return nil }
+
+// HandleNextGC implements Handler.
+func (NopHandler) HandleNextGC(*Event, NextGCArgs) error { return nil }
+
+// HandleTimerGoroutine implements Handler.
+func (NopHandler) HandleTimerGoroutine(*Event, TimerGoroutineArgs) error { return nil }
+
+// HandleFutileWakeup implements Handler.
+func (NopHandler) HandleFutileWakeup(*Event, FutileWakeupArgs) error { return nil }
+
+// HandleString implements Handler.
+func (NopHandler) HandleString(*Event, StringArgs) error { return nil }
+
+// HandleGoStartLocal implements Handler.
+func (NopHandler) HandleGoStartLocal(*Event, GoStartLocalArgs) error { return nil }
+
+// HandleGoUnblockLocal implements Handler.
+func (NopHandler) HandleGoUnblockLocal(*Event, GoUnblockLocalArgs) error { return nil }
+
+// HandleGoSysExitLocal implements Handler.
+func (NopHandler) HandleGoSysExitLocal(*Event, GoSysExitLocalArgs) error { return nil }
+
+// HandleGoStartLabel implements Handler.
+func (NopHandler) HandleGoStartLabel(*Event, GoStartLabelArgs) error { return nil }
+
+// HandleGoBlockGC implements Handler.
+func (NopHandler) HandleGoBlockGC(*Event, GoBlockGCArgs) error { return nil }
+
+// HandleGCMarkAssistStart implements Handler.
+func (NopHandler) HandleGCMarkAssistStart(*Event, GCMarkAssistStartArgs) error { return nil }
+
+// HandleGCMarkAssistDone implements Handler.
+func (NopHandler) HandleGCMarkAssistDone(*Event, GCMarkAssistDoneArgs) error { return nil }
+
+// HandleUserTaskCreate implements Handler.
+func (NopHandler) HandleUserTaskCreate(*Event, UserTaskCreateArgs) error { return nil }
+
+// HandleUserTaskEnd implements Handler.
+func (NopHandler) HandleUserTaskEnd(*Event, UserTaskEndArgs) error { return nil }
+
+// HandleUserRegion implements Handler.
+func (NopHandler) HandleUserRegion(*Event, UserRegionArgs) error { return nil }
+
+// HandleUserLog implements Handler.
+func (NopHandler) HandleUserLog(*Event, UserLogArgs) error { return nil }
+
+// HandleCPUSample implements Handler.
+func (NopHandler) HandleCPUSample(*Event, CPUSampleArgs) error { return nil }
+
+// Dispatch calls the method of h for the type of evt with its arguments, read
+// in the layout of version v. An error is returned if evt has an invalid type
+// or too few arguments.
+func Dispatch(h Handler, v Version, evt *Event) error {
+	switch evt.Type {
+	case EvBatch:
+		if args, ok := evt.Batch(v); ok {
+			return h.HandleBatch(evt, args)
+		}
+	case EvFrequency:
+		if args, ok := evt.Frequency(v); ok {
+			return h.HandleFrequency(evt, args)
+		}
+	case EvStack:
+		if args, ok := evt.Stack(v); ok {
+			return h.HandleStack(evt, args)
+		}
+	case EvGomaxprocs:
+		if args, ok := evt.Gomaxprocs(v); ok {
+			return h.HandleGomaxprocs(evt, args)
+		}
+	case EvProcStart:
+		if args, ok := evt.ProcStart(v); ok {
+			return h.HandleProcStart(evt, args)
+		}
+	case EvProcStop:
+		if args, ok := evt.ProcStop(v); ok {
+			return h.HandleProcStop(evt, args)
+		}
+	case EvGCStart:
+		if args, ok := evt.GCStart(v); ok {
+			return h.HandleGCStart(evt, args)
+		}
+	case EvGCDone:
+		if args, ok := evt.GCDone(v); ok {
+			return h.HandleGCDone(evt, args)
+		}
+	case EvGCSTWStart:
+		if args, ok := evt.GCSTWStart(v); ok {
+			return h.HandleGCSTWStart(evt, args)
+		}
+	case EvGCSTWDone:
+		if args, ok := evt.GCSTWDone(v); ok {
+			return h.HandleGCSTWDone(evt, args)
+		}
+	case EvGCSweepStart:
+		if args, ok := evt.GCSweepStart(v); ok {
+			return h.HandleGCSweepStart(evt, args)
+		}
+	case EvGCSweepDone:
+		if args, ok := evt.GCSweepDone(v); ok {
+			return h.HandleGCSweepDone(evt, args)
+		}
+	case EvGoCreate:
+		if args, ok := evt.GoCreate(v); ok {
+			return h.HandleGoCreate(evt, args)
+		}
+	case EvGoStart:
+		if args, ok := evt.GoStart(v); ok {
+			return h.HandleGoStart(evt, args)
+		}
+	case EvGoEnd:
+		if args, ok := evt.GoEnd(v); ok {
+			return h.HandleGoEnd(evt, args)
+		}
+	case EvGoStop:
+		if args, ok := evt.GoStop(v); ok {
+			return h.HandleGoStop(evt, args)
+		}
+	case EvGoSched:
+		if args, ok := evt.GoSched(v); ok {
+			return h.HandleGoSched(evt, args)
+		}
+	case EvGoPreempt:
+		if args, ok := evt.GoPreempt(v); ok {
+			return h.HandleGoPreempt(evt, args)
+		}
+	case EvGoSleep:
+		if args, ok := evt.GoSleep(v); ok {
+			return h.HandleGoSleep(evt, args)
+		}
+	case EvGoBlock:
+		if args, ok := evt.GoBlock(v); ok {
+			return h.HandleGoBlock(evt, args)
+		}
+	case EvGoUnblock:
+		if args, ok := evt.GoUnblock(v); ok {
+			return h.HandleGoUnblock(evt, args)
+		}
+	case EvGoBlockSend:
+		if args, ok := evt.GoBlockSend(v); ok {
+			return h.HandleGoBlockSend(evt, args)
+		}
+	case EvGoBlockRecv:
+		if args, ok := evt.GoBlockRecv(v); ok {
+			return h.HandleGoBlockRecv(evt, args)
+		}
+	case EvGoBlockSelect:
+		if args, ok := evt.GoBlockSelect(v); ok {
+			return h.HandleGoBlockSelect(evt, args)
+		}
+	case EvGoBlockSync:
+		if args, ok := evt.GoBlockSync(v); ok {
+			return h.HandleGoBlockSync(evt, args)
+		}
+	case EvGoBlockCond:
+		if args, ok := evt.GoBlockCond(v); ok {
+			return h.HandleGoBlockCond(evt, args)
+		}
+	case EvGoBlockNet:
+		if args, ok := evt.GoBlockNet(v); ok {
+			return h.HandleGoBlockNet(evt, args)
+		}
+	case EvGoSysCall:
+		if args, ok := evt.GoSysCall(v); ok {
+			return h.HandleGoSysCall(evt, args)
+		}
+	case EvGoSysExit:
+		if args, ok := evt.GoSysExit(v); ok {
+			return h.HandleGoSysExit(evt, args)
+		}
+	case EvGoSysBlock:
+		if args, ok := evt.GoSysBlock(v); ok {
+			return h.HandleGoSysBlock(evt, args)
+		}
+	case EvGoWaiting:
+		if args, ok := evt.GoWaiting(v); ok {
+			return h.HandleGoWaiting(evt, args)
+		}
+	case EvGoInSyscall:
+		if args, ok := evt.GoInSyscall(v); ok {
+			return h.HandleGoInSyscall(evt, args)
+		}
+	case EvHeapAlloc:
+		if args, ok := evt.HeapAlloc(v); ok {
+			return h.HandleHeapAlloc(evt, args)
+		}
+	case EvNextGC:
+		if args, ok := evt.NextGC(v); ok {
+			return h.HandleNextGC(evt, args)
+		}
+	case EvTimerGoroutine:
+		if args, ok := evt.TimerGoroutine(v); ok {
+			return h.HandleTimerGoroutine(evt, args)
+		}
+	case EvFutileWakeup:
+		if args, ok := evt.FutileWakeup(v); ok {
+			return h.HandleFutileWakeup(evt, args)
+		}
+	case EvString:
+		if args, ok := evt.StringEntry(v); ok {
+			return h.HandleString(evt, args)
+		}
+	case EvGoStartLocal:
+		if args, ok := evt.GoStartLocal(v); ok {
+			return h.HandleGoStartLocal(evt, args)
+		}
+	case EvGoUnblockLocal:
+		if args, ok := evt.GoUnblockLocal(v); ok {
+			return h.HandleGoUnblockLocal(evt, args)
+		}
+	case EvGoSysExitLocal:
+		if args, ok := evt.GoSysExitLocal(v); ok {
+			return h.HandleGoSysExitLocal(evt, args)
+		}
+	case EvGoStartLabel:
+		if args, ok := evt.GoStartLabel(v); ok {
+			return h.HandleGoStartLabel(evt, args)
+		}
+	case EvGoBlockGC:
+		if args, ok := evt.GoBlockGC(v); ok {
+			return h.HandleGoBlockGC(evt, args)
+		}
+	case EvGCMarkAssistStart:
+		if args, ok := evt.GCMarkAssistStart(v); ok {
+			return h.HandleGCMarkAssistStart(evt, args)
+		}
+	case EvGCMarkAssistDone:
+		if args, ok := evt.GCMarkAssistDone(v); ok {
+			return h.HandleGCMarkAssistDone(evt, args)
+		}
+	case EvUserTaskCreate:
+		if args, ok := evt.UserTaskCreate(v); ok {
+			return h.HandleUserTaskCreate(evt, args)
+		}
+	case EvUserTaskEnd:
+		if args, ok := evt.UserTaskEnd(v); ok {
+			return h.HandleUserTaskEnd(evt, args)
+		}
+	case EvUserRegion:
+		if args, ok := evt.UserRegion(v); ok {
+			return h.HandleUserRegion(evt, args)
+		}
+	case EvUserLog:
+		if args, ok := evt.UserLog(v); ok {
+			return h.HandleUserLog(evt, args)
+		}
+	case EvCPUSample:
+		if args, ok := evt.CPUSample(v); ok {
+			return h.HandleCPUSample(evt, args)
+		}
+	default:
+		return fmt.Errorf(`event type %v was not valid`, evt.Type)
+	}
+	return fmt.Errorf(`event type %v has too few arguments`, evt.Type)
+}
@@ -0,0 +1,179 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler by returning the encoding of
+// this event in the latest version of the Go trace format, as a single record
+// without the trace header. The fields derived while decoding a trace, such as
+// P, G, Ts and Off, are not included.
+func (e *Event) MarshalBinary() ([]byte, error) {
+	return e.AppendBinary(nil)
+}
+
+// AppendBinary is like MarshalBinary but appends the encoding to b, which is
+// returned unmodified when an error occurs.
+func (e *Event) AppendBinary(b []byte) ([]byte, error) {
+	if !e.Type.Valid() {
+		return b, errors.New(`invalid trace event type`)
+	}
+	if len(e.Args) == 0 {
+		return b, errors.New(`expected at least 1 argument for event`)
+	}
+
+	typ := byte(e.Type)
+	switch {
+	case e.Type == EvString:
+		// Strings do not provide an arg count.
+		b = appendUleb(append(b, typ), e.Args[0])
+		return appendData(b, e.Data), nil
+	case e.Type == EvUserLog:
+		return appendData(appendSized(b, e), e.Data), nil
+	case len(e.Args) < 4:
+		b = append(b, typ|byte(len(e.Args)-1)<<traceArgCountShift)
+		for _, arg := range e.Args {
+			b = appendUleb(b, arg)
+		}
+		return b, nil
+	default:
+		return appendSized(b, e), nil
+	}
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler for a single record as
+// produced by MarshalBinary. The Args and Data of e are reused when they have
+// enough capacity.
+func (e *Event) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		return io.ErrUnexpectedEOF
+	}
+
+	typ, n := Type(b[0]<<2>>2), int(b[0]>>traceArgCountShift)
+	if !typ.Valid() {
+		return errors.New(`invalid trace event type`)
+	}
+	e.Reset()
+	e.Type = typ
+
+	r := &byteReader{b: b[1:]}
+	var err error
+	switch {
+	case typ == EvString:
+		err = r.args(e, 1)
+		if err == nil {
+			e.Data, err = r.data(e.Data)
+		}
+	case n < 3:
+		err = r.args(e, n+1)
+	default:
+		err = r.sized(e)
+		if err == nil && typ == EvUserLog {
+			e.Data, err = r.data(e.Data)
+		}
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil && len(r.b) > 0 {
+		err = fmt.Errorf(`%v trailing bytes after event %v`, len(r.b), typ)
+	}
+	return err
+}
+
+func appendUleb(b []byte, v uint64) []byte {
+	for ; v >= 0x80; v >>= 7 {
+		b = append(b, 0x80|byte(v))
+	}
+	return append(b, byte(v))
+}
+
+// appendSized appends e with args prefixed by their byte length.
+func appendSized(b []byte, e *Event) []byte {
+	var size uint64
+	for _, arg := range e.Args {
+		for size++; arg >= 0x80; arg >>= 7 {
+			size++
+		}
+	}
+	b = appendUleb(append(b, byte(e.Type)|3<<traceArgCountShift), size)
+	for _, arg := range e.Args {
+		b = appendUleb(b, arg)
+	}
+	return b
+}
+
+// appendData appends the length of data followed by its bytes.
+func appendData(b, data []byte) []byte {
+	return append(appendUleb(b, uint64(len(data))), data...)
+}
+
+// byteReader reads the arguments of a single record.
+type byteReader struct {
+	b []byte
+}
+
+func (r *byteReader) uleb() (uint64, error) {
+	var v, y uint64
+	for i := 0; i < 10; i, y = i+1, y+7 {
+		if len(r.b) == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		byt := r.b[0]
+		r.b = r.b[1:]
+		v |= uint64(byt&0x7f) << y
+		if byt&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, errors.New(`uleb128 value overflowed`)
+}
+
+func (r *byteReader) args(e *Event, n int) error {
+	for i := 0; i < n; i++ {
+		v, err := r.uleb()
+		if err != nil {
+			return err
+		}
+		e.Args = append(e.Args, v)
+	}
+	return nil
+}
+
+func (r *byteReader) sized(e *Event) error {
+	size, err := r.uleb()
+	if err != nil {
+		return err
+	}
+	if size > uint64(len(r.b)) {
+		return io.ErrUnexpectedEOF
+	}
+
+	end := len(r.b) - int(size)
+	for len(r.b) > end {
+		v, err := r.uleb()
+		if err != nil {
+			return err
+		}
+		e.Args = append(e.Args, v)
+	}
+	if len(r.b) != end {
+		return errors.New(`event arguments exceeded their declared size`)
+	}
+	return nil
+}
+
+func (r *byteReader) data(buf []byte) ([]byte, error) {
+	size, err := r.uleb()
+	if err != nil {
+		return nil, err
+	}
+	if size > uint64(len(r.b)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	buf = append(buf[:0], r.b[:size]...)
+	r.b = r.b[size:]
+	return buf, nil
+}
@@ -0,0 +1,144 @@
+package event
+
+import "sort"
+
+// BlockProfile aggregates the time goroutines spent blocked by the stack and
+// type of the event they blocked with, see the BlockProfile method of Trace.
+type BlockProfile struct {
+
+	// Records lists each stack and type goroutines blocked with, ordered by the
+	// most time blocked.
+	Records []BlockRecord
+
+	// Count and Time are the totals of all records.
+	Count int
+	Time  int64
+}
+
+// BlockRecord is the number of times goroutines blocked from a stack with an
+// event of Type, along with the cumulative time they remained blocked in the
+// unit of the Ts field of the events it was derived from.
+type BlockRecord struct {
+	StackID uint64
+	Type    Type
+	Count   int
+	Time    int64
+}
+
+// BlockProfile aggregates the GoBlock events referred to by evts, which must be
+// ordered as they are by an Orderer and retain the arguments in the layout of
+// the Version of this Trace. A goroutine remains blocked until it is unblocked,
+// or if no unblock event exists until it next starts running. Goroutines which
+// remain blocked at the end of the trace are not counted.
+func (tr *Trace) BlockProfile(evts []*Event) *BlockProfile {
+	return aggregate(func(add func(BlockRecord)) {
+		tr.waits(evts, func(stk uint64, typ Type, d int64) {
+			add(BlockRecord{StackID: stk, Type: typ, Count: 1, Time: d})
+		})
+	})
+}
+
+// SyscallProfile aggregates the time goroutines spent blocked in system calls
+// within evts, which must be ordered as they are by an Orderer and retain the
+// arguments in the layout of the Version of this Trace. Each record is of an
+// EvGoSysCall event and its stack, timed as described by the Syscalls method.
+// System calls which did not block are not counted.
+func (tr *Trace) SyscallProfile(evts []*Event) *BlockProfile {
+	sum := tr.Syscalls(evts)
+	return aggregate(func(add func(BlockRecord)) {
+		for stk, st := range sum.Stacks {
+			if st.Blocked > 0 {
+				add(BlockRecord{StackID: stk, Type: EvGoSysCall,
+					Count: st.Blocked, Time: st.Time})
+			}
+		}
+	})
+}
+
+// SchedProfile aggregates the scheduler latency of goroutines within evts,
+// which must be ordered as they are by an Orderer and retain the arguments in
+// the layout of the Version of this Trace. Each record is of the EvGoUnblock
+// or EvGoCreate event which made a goroutine runnable and the stack it was
+// emitted with, timed until the goroutine next started running as described by
+// the SchedLatencies method. Goroutines which had not started by the end of
+// the trace are not counted.
+func (tr *Trace) SchedProfile(evts []*Event) *BlockProfile {
+	lats := tr.SchedLatencies(evts, -1)
+	return aggregate(func(add func(BlockRecord)) {
+		for _, sl := range lats {
+			add(BlockRecord{StackID: sl.StackID, Type: sl.Type,
+				Count: 1, Time: sl.Latency})
+		}
+	})
+}
+
+// aggregate returns the profile of the records walk gives to add, grouped by
+// stack and type.
+func aggregate(walk func(add func(BlockRecord))) *BlockProfile {
+	type key struct {
+		stk uint64
+		typ Type
+	}
+	recs := make(map[key]*BlockRecord)
+	walk(func(r BlockRecord) {
+		k := key{r.StackID, r.Type}
+		rec := recs[k]
+		if rec == nil {
+			rec = &BlockRecord{StackID: r.StackID, Type: r.Type}
+			recs[k] = rec
+		}
+		rec.Count += r.Count
+		rec.Time += r.Time
+	})
+
+	p := &BlockProfile{Records: make([]BlockRecord, 0, len(recs))}
+	for _, rec := range recs {
+		p.Records = append(p.Records, *rec)
+		p.Count += rec.Count
+		p.Time += rec.Time
+	}
+	sort.Slice(p.Records, func(i, j int) bool {
+		a, b := p.Records[i], p.Records[j]
+		switch {
+		case a.Time != b.Time:
+			return a.Time > b.Time
+		case a.StackID != b.StackID:
+			return a.StackID < b.StackID
+		}
+		return a.Type < b.Type
+	})
+	return p
+}
+
+// waits calls fn with the stack, type and duration of each time a goroutine
+// blocked within evts, as described by BlockProfile.
+func (tr *Trace) waits(evts []*Event, fn func(stk uint64, typ Type, d int64)) {
+	var argoff int
+	if tr.Version.Valid() {
+		argoff = versions[tr.Version].argOffset
+	}
+
+	type block struct {
+		stk uint64
+		typ Type
+		ts  int64
+	}
+	blocked := make(map[uint64]block)
+	for _, evt := range evts {
+		switch evt.Type {
+		case EvGoBlock, EvGoBlockSend, EvGoBlockRecv, EvGoBlockSelect,
+			EvGoBlockSync, EvGoBlockCond, EvGoBlockNet, EvGoBlockGC:
+			if evt.G != 0 {
+				blocked[uint64(evt.G)] = block{
+					evt.arg(ArgStackID, argoff), evt.Type, evt.Ts}
+			}
+		case EvGoUnblock, EvGoUnblockLocal, EvGoStart, EvGoStartLocal,
+			EvGoStartLabel:
+			g := evt.arg(ArgGoroutineID, argoff)
+			if b, ok := blocked[g]; ok {
+				delete(blocked, g)
+				fn(b.stk, b.typ, evt.Ts-b.ts)
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package event
+
+import "sort"
+
+// Contention is a cluster of waits on channels or sync primitives from the
+// same stack which each exceeded a threshold, see the Contentions method of
+// Trace. Times are in the unit of the Ts field of the events they were derived
+// from.
+type Contention struct {
+
+	// StackID and Type are the stack and type of the event the goroutines
+	// blocked with. StackID may be zero when stacks were not recorded.
+	StackID uint64
+	Type    Type
+
+	// Count is the number of waits in this cluster, Time their cumulative
+	// duration and Max the longest of them.
+	Count     int
+	Time, Max int64
+
+	// Goroutines are the ids of the goroutines which waited in ascending
+	// order, and Forever the number of them which remained blocked at the end
+	// of the trace.
+	Goroutines []uint64
+	Forever    int
+}
+
+// Deadlocked reports if every goroutine of this cluster remained blocked at
+// the end of the trace, as is the case when a set of goroutines deadlock.
+func (c Contention) Deadlocked() bool {
+	return c.Forever > 0 && c.Forever == len(c.Goroutines)
+}
+
+// Contentions returns the waits within evts on channels, select statements and
+// sync primitives lasting longer than threshold, clustered by the stack and
+// type of the event the goroutines blocked with. A wait lasts until the
+// goroutine is unblocked or next starts running, those which remain blocked
+// at the end of the trace are timed until its last event. Goroutines stopped
+// by an EvGoStop event, such as by an empty select or a nil channel, remain
+// blocked forever. Waits which began before the trace, declared by EvGoWaiting
+// events, are not reported as their duration is unknown.
+//
+// Clusters are ordered by the most goroutines blocked forever, surfacing
+// probable deadlocks, followed by the most time waited for contention. Like
+// BlockProfile, evts must be ordered as they are by an Orderer and retain the
+// arguments in the layout of the Version of this Trace.
+func (tr *Trace) Contentions(evts []*Event, threshold int64) []Contention {
+	var argoff int
+	if tr.Version.Valid() {
+		argoff = versions[tr.Version].argOffset
+	}
+
+	type key struct {
+		stk uint64
+		typ Type
+	}
+	type block struct {
+		key
+		ts int64
+	}
+	var (
+		lastTs   int64
+		blocked  = make(map[uint64]block)
+		clusters = make(map[key]*Contention)
+		seen     = make(map[key]map[uint64]bool)
+	)
+	add := func(g uint64, b block, forever bool) {
+		d := lastTs - b.ts
+		if d <= threshold {
+			return
+		}
+		c := clusters[b.key]
+		if c == nil {
+			c = &Contention{StackID: b.stk, Type: b.typ}
+			clusters[b.key], seen[b.key] = c, make(map[uint64]bool)
+		}
+		c.Count++
+		c.Time += d
+		if d > c.Max {
+			c.Max = d
+		}
+		if !seen[b.key][g] {
+			seen[b.key][g] = true
+			c.Goroutines = append(c.Goroutines, g)
+		}
+		if forever {
+			c.Forever++
+		}
+	}
+
+	for _, evt := range evts {
+		lastTs = evt.Ts
+		switch evt.Type {
+		case EvGoBlock, EvGoBlockSend, EvGoBlockRecv, EvGoBlockSelect,
+			EvGoBlockSync, EvGoBlockCond, EvGoStop:
+			if evt.G != 0 {
+				blocked[uint64(evt.G)] = block{
+					key{evt.arg(ArgStackID, argoff), evt.Type}, evt.Ts}
+			}
+		case EvGoUnblock, EvGoUnblockLocal, EvGoStart, EvGoStartLocal,
+			EvGoStartLabel:
+			g := evt.arg(ArgGoroutineID, argoff)
+			if b, ok := blocked[g]; ok {
+				delete(blocked, g)
+				add(g, b, false)
+			}
+		}
+	}
+	for g, b := range blocked {
+		add(g, b, true)
+	}
+
+	out := make([]Contention, 0, len(clusters))
+	for _, c := range clusters {
+		sort.Slice(c.Goroutines, func(i, j int) bool {
+			return c.Goroutines[i] < c.Goroutines[j]
+		})
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		switch {
+		case a.Forever != b.Forever:
+			return a.Forever > b.Forever
+		case a.Time != b.Time:
+			return a.Time > b.Time
+		case a.StackID != b.StackID:
+			return a.StackID < b.StackID
+		}
+		return a.Type < b.Type
+	})
+	return out
+}
@@ -0,0 +1,96 @@
+package event
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Dump returns a multi-line description of this event for debugging, such as
+// when a trace fails to decode. It lists the offset, timestamp, P and G of the
+// event followed by each argument by name and kind, any data and a hexdump of
+// the encoding given by MarshalBinary.
+//
+// When tr is not nil the arguments are named in the layout of its version and
+// the strings and stacks they refer to are resolved from it, otherwise they
+// are named in the layout of the latest version.
+func (e *Event) Dump(tr *Trace) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%v at 0x%x (ts %v, p %v, g %v)\n",
+		e.Type.Name(), e.Off, e.Ts, e.P, e.G)
+
+	off := 0
+	if tr != nil && tr.Version.Valid() {
+		off = versions[tr.Version].argOffset
+	}
+	names, kinds := e.Type.Args(), e.Type.ArgKinds()
+	for i, arg := range e.Args {
+		idx := i - off
+		if idx < 0 || idx >= len(names) {
+			fmt.Fprintf(&buf, "  %-16v = %v\n", fmt.Sprintf(`[%d]`, i), arg)
+			continue
+		}
+		kind := kinds[idx]
+		fmt.Fprintf(&buf, "  %-16v = %v", names[idx], arg)
+		if unit := kind.Unit(); unit != `` {
+			fmt.Fprintf(&buf, " %v", unit)
+		}
+		fmt.Fprintf(&buf, " (%v)\n", kind)
+		if tr != nil {
+			dumpRef(&buf, tr, e, kind, arg)
+		}
+	}
+
+	switch {
+	case len(e.Data) > 0:
+		fmt.Fprintf(&buf, "  data             = %q\n", e.Data)
+	case e.Span.Len > 0:
+		fmt.Fprintf(&buf, "  span             = [0x%x, 0x%x)\n",
+			e.Span.Off, e.Span.Off+e.Span.Len)
+		if tr != nil {
+			if s, err := tr.readSpan(e.Span); err == nil {
+				fmt.Fprintf(&buf, "    %q\n", s)
+			}
+		}
+	}
+
+	b, err := e.MarshalBinary()
+	if err != nil {
+		fmt.Fprintf(&buf, "  encoding error: %v\n", err)
+		return buf.String()
+	}
+	for _, line := range strings.SplitAfter(hex.Dump(b), "\n") {
+		if line != `` {
+			buf.WriteString(`  ` + line)
+		}
+	}
+	return buf.String()
+}
+
+// dumpRef writes the string or stack an argument of the given kind refers to.
+func dumpRef(buf *bytes.Buffer, tr *Trace, e *Event, kind Kind, arg uint64) {
+	switch kind {
+	case KindString:
+		if e.Type == EvString {
+			return
+		}
+		if s, err := tr.getString(arg); err == nil {
+			fmt.Fprintf(buf, "    %q\n", s)
+		}
+	case KindStack:
+		if e.Type == EvStack || arg == 0 {
+			return
+		}
+		stk, err := tr.getStack(arg)
+		if err != nil {
+			return
+		}
+		long := stk.Format(StackFormat{Style: StackLong})
+		for _, line := range strings.SplitAfter(long, "\n") {
+			if line != `` {
+				buf.WriteString(`    ` + line)
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package event
 import (
 	"bytes"
 	"fmt"
+	"strings"
 )
 
 const (
@@ -64,12 +65,39 @@ const (
 	EvGoBlockGC         Type = 42 // goroutine blocks on GC assist [timestamp, stack]
 	EvGCMarkAssistStart Type = 43 // GC mark assist start [timestamp, stack]
 	EvGCMarkAssistDone  Type = 44 // GC mark assist done [timestamp]
-	EvCount             Type = 45
+	EvUserTaskCreate    Type = 45 // trace.NewContext [timestamp, internal task id, internal parent task id, stack, name string]
+	EvUserTaskEnd       Type = 46 // end of a task [timestamp, internal task id, stack]
+	EvUserRegion        Type = 47 // trace.WithRegion [timestamp, internal task id, mode(0:start, 1:end), stack, name string]
+	EvUserLog           Type = 48 // trace.Log [timestamp, internal task id, key string id, stack, value string]
+	EvCPUSample         Type = 49 // CPU profiling sample [timestamp, real timestamp, real P id (-1 when absent), goroutine id, stack]
+	EvCount             Type = 50
 )
 
 // Type represents the type of trace event.
 type Type byte
 
+// TypeFromString returns the valid Type with the given name and a boolean
+// true, or EvNone and false if no such type exists. Names are matched without
+// regard to case and may carry an optional "Ev" prefix, so "GoBlockRecv",
+// "goblockrecv" and "EvGoBlockRecv" all return EvGoBlockRecv.
+func TypeFromString(name string) (Type, bool) {
+	if len(name) > 2 && strings.EqualFold(name[:2], `ev`) {
+		if typ, ok := typeFromString(name[2:]); ok {
+			return typ, true
+		}
+	}
+	return typeFromString(name)
+}
+
+func typeFromString(name string) (Type, bool) {
+	for typ := EvNone + 1; typ < EvCount; typ++ {
+		if strings.EqualFold(typ.Name(), name) {
+			return typ, true
+		}
+	}
+	return EvNone, false
+}
+
 // Valid returns true if the event Type is valid, false otherwise.
 func (t Type) Valid() bool {
 	return EvNone < t && t < EvCount
@@ -85,6 +113,14 @@ func (t Type) Since() Version {
 	return schemas[t%EvCount].Since
 }
 
+// RemovedIn returns the first version which no longer emits this type of event,
+// or zero if it is emitted by every version since it was introduced. The
+// runtime has only added types of events across the versions supported by this
+// package, so it is always zero.
+func (t Type) RemovedIn() Version {
+	return 0
+}
+
 // Args returns an ordered list of arguments this type of event will contain.
 func (t Type) Args() []string {
 	return schemas[t%EvCount].Args
@@ -124,13 +160,20 @@ type Event struct {
 	Args []uint64
 
 	// Data may be nil or a slice containing Event data for arguments that are not
-	// uleb128 encoded. Currently only the string event fits this criteria.
+	// uleb128 encoded. Currently only the string and user log events fit this
+	// criteria, the latter holding the logged value.
 	//
 	// @TODO Remove all together in favor of storing in *Trace?
 	Data []byte
 
+	// Span locates the payload of an EvString event within a retained source
+	// when it was decoded lazily, in which case Data is left empty. See the
+	// Source field of Trace for resolving lazily decoded strings.
+	Span Span
+
 	// Id's of the P and G associated with this event. With G being a goroutine
-	// and P a resource that is required to execute Go code.
+	// and P a resource that is required to execute Go code. The P is set from
+	// the enclosing EvBatch while decoding.
 	P, G int64
 
 	// Ts is the timestamp of the event.
@@ -149,6 +192,45 @@ type Event struct {
 	// Seq uint64
 }
 
+// New returns a new Event of Type t holding a copy of args, which must match
+// the arguments listed by the Args method of t in the layout of Version2 and
+// later. A Stack event must be followed by the four values of each frame it
+// declares in its size argument. Data for string and user log events is not
+// checked and may be assigned to the returned Event.
+func New(t Type, args ...uint64) (*Event, error) {
+	if !t.Valid() {
+		return nil, fmt.Errorf(`event type %v was not valid`, t)
+	}
+
+	exp := len(schemas[t].Args)
+	if t == EvStack && len(args) >= exp {
+		size := args[1]
+		if maxStackSize < size {
+			return nil, fmt.Errorf(
+				"stack size %v exceeds limit(%v)", size, maxStackSize)
+		}
+		exp += int(size) * versions[Latest].frameSize
+	}
+	if got := len(args); got != exp {
+		return nil, fmt.Errorf(
+			`Event %v was given %d of %d expected arguments`, t, got, exp)
+	}
+
+	evt := &Event{Type: t, Args: make([]uint64, len(args))}
+	copy(evt.Args, args)
+	return evt, nil
+}
+
+// MustNew is like New but panics if the event could not be created, it
+// simplifies the construction of synthetic events in tests.
+func MustNew(t Type, args ...uint64) *Event {
+	evt, err := New(t, args...)
+	if err != nil {
+		panic(err)
+	}
+	return evt
+}
+
 // Get returns a argument by name, or the zero value if it doesn't exist.
 func (e *Event) Get(name string) uint64 {
 	if idx, has := e.Type.Arg(name); has && idx <= len(e.Args) {
@@ -171,6 +253,73 @@ func (e *Event) Lookup(name string) (arg uint64, found bool) {
 	return
 }
 
+// ArgsMap returns the arguments of this event keyed by the names returned from
+// the Args method of its type. Arguments beyond those named, such as the
+// frames of a stack, are not included. Like MarshalJSON the names follow the
+// latest version of the trace format.
+func (e *Event) ArgsMap() map[string]uint64 {
+	args := e.NamedArgs()
+	m := make(map[string]uint64, len(args))
+	for _, arg := range args {
+		m[arg.Name] = arg.Value
+	}
+	return m
+}
+
+// NamedArg is an argument of an event paired with its name, see NamedArgs.
+type NamedArg struct {
+	Name  string
+	Value uint64
+}
+
+// NamedArgs is like ArgsMap but returns the arguments in the order they are
+// declared by the Args method of the event type.
+func (e *Event) NamedArgs() []NamedArg {
+	names := e.Type.Args()
+	if len(e.Args) < len(names) {
+		names = names[:len(e.Args)]
+	}
+	out := make([]NamedArg, len(names))
+	for i, name := range names {
+		out[i] = NamedArg{Name: name, Value: e.Args[i]}
+	}
+	return out
+}
+
+// arg returns the named argument offset by off, the argument offset of the
+// version the event was decoded from, or zero if it does not exist.
+func (e *Event) arg(name string, off int) uint64 {
+	idx, ok := e.Type.Arg(name)
+	if !ok || idx+off >= len(e.Args) {
+		return 0
+	}
+	return e.Args[idx+off]
+}
+
+// Set places v at the index of the named argument, growing Args with zero
+// values when it is shorter than the schema of this event type. An error is
+// returned if arg does not exist in this event type.
+func (e *Event) Set(name string, v uint64) error {
+	idx, ok := e.Type.Arg(name)
+	if !ok {
+		return fmt.Errorf(`event type %v has no argument %q`, e.Type, name)
+	}
+	for len(e.Args) <= idx {
+		e.Args = append(e.Args, 0)
+	}
+	e.Args[idx] = v
+	return nil
+}
+
+// With is like Set but returns this event to allow chaining, it panics if arg
+// does not exist in this event type.
+func (e *Event) With(name string, v uint64) *Event {
+	if err := e.Set(name, v); err != nil {
+		panic(err)
+	}
+	return e
+}
+
 // Copy will return a deep copy of this event.
 func (e *Event) Copy() *Event {
 	evt := new(Event)
@@ -199,6 +348,12 @@ func (e Event) String() string {
 	return fmt.Sprintf(`encoding.%v`, schemas[e.Type%EvCount].Name)
 }
 
+// Span is a range of bytes within a source retained by the caller, such as the
+// input stream of a trace.
+type Span struct {
+	Off, Len int
+}
+
 // Stack is a slice of Frame.
 type Stack []Frame
 
@@ -222,6 +377,21 @@ type Frame struct {
 	tr           *Trace
 	pc, fn, file uint64
 	line         int
+
+	// names is set for frames created with NewFrame which do not belong to a
+	// Trace, it is held by pointer to keep the frames of a Trace small.
+	names *frameNames
+}
+
+// frameNames holds the function and file of a Frame created with NewFrame.
+type frameNames struct {
+	fn, file string
+}
+
+// NewFrame returns a new Frame for the given program counter, function, file
+// and line, such as for building a Stack to be encoded.
+func NewFrame(pc uint64, fn, file string, line int) Frame {
+	return Frame{pc: pc, names: &frameNames{fn: fn, file: file}, line: line}
 }
 
 // PC is the program counter of this frame.
@@ -231,11 +401,23 @@ func (f Frame) PC() uint64 {
 
 // Func is the enclosing function of this frame.
 func (f Frame) Func() string {
+	if f.tr == nil {
+		if f.names == nil {
+			return ``
+		}
+		return f.names.fn
+	}
 	return f.tr.getStringDefault(f.fn)
 }
 
 // File of this frame.
 func (f Frame) File() string {
+	if f.tr == nil {
+		if f.names == nil {
+			return ``
+		}
+		return f.names.file
+	}
 	return f.tr.getStringDefault(f.file)
 }
 
@@ -1 +1,2438 @@
 package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewFrame(t *testing.T) {
+	f := NewFrame(0x10, `main.main`, `main.go`, 10)
+	if f.PC() != 0x10 || f.Func() != `main.main` || f.File() != `main.go` || f.Line() != 10 {
+		t.Fatalf(`exp frame fields to match NewFrame args; got %v`, f)
+	}
+}
+
+func TestTypeFromString(t *testing.T) {
+	for typ := EvNone + 1; typ < EvCount; typ++ {
+		for _, name := range []string{
+			typ.Name(),
+			strings.ToLower(typ.Name()),
+			strings.ToUpper(typ.Name()),
+			`Ev` + typ.Name(),
+			`ev` + strings.ToLower(typ.Name()),
+		} {
+			got, ok := TypeFromString(name)
+			if !ok || got != typ {
+				t.Fatalf(`exp %v from %q; got %v (%v)`, typ, name, got, ok)
+			}
+		}
+	}
+	for _, name := range []string{``, `Ev`, `None`, `EvNone`, `Count`, `GoBlockRecvX`} {
+		if got, ok := TypeFromString(name); ok || got != EvNone {
+			t.Fatalf(`exp no type from %q; got %v (%v)`, name, got, ok)
+		}
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	tests := []*Event{
+		{Type: EvGoCreate, Args: []uint64{10, 2, 3, 4}, Ts: 100, P: 1, G: 1, Off: 16},
+		{Type: EvStack, Args: []uint64{1, 1, 0x400, 2, 3, 40}},
+		{Type: EvString, Args: []uint64{1}, Data: []byte(`main`)},
+		{Type: EvString, Args: []uint64{2}, Data: []byte{0xff, 0xfe}},
+		{Type: EvString, Args: []uint64{3}, Span: Span{Off: 20, Len: 4}},
+		{Type: EvGoEnd, Args: []uint64{10}, P: -1},
+	}
+	for _, exp := range tests {
+		b, err := json.Marshal(exp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := new(Event)
+		if err := json.Unmarshal(b, got); err != nil {
+			t.Fatalf(`exp nil err unmarshaling %s; got %v`, b, err)
+		}
+		if !reflect.DeepEqual(exp, got) {
+			t.Fatalf(`exp %#v from %s; got %#v`, exp, b, got)
+		}
+	}
+
+	b, err := json.Marshal(tests[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m[`type`] != `GoCreate` {
+		t.Fatalf(`exp type name GoCreate; got %v`, m[`type`])
+	}
+	args, _ := m[`args`].(map[string]interface{})
+	if args[ArgNewGoroutineID] != float64(2) {
+		t.Fatalf(`exp args keyed by name; got %s`, b)
+	}
+
+	t.Run(`Errors`, func(t *testing.T) {
+		for _, s := range []string{
+			`{"type":"Unknown"}`,
+			`{"type":"GoCreate","args":{"Timestamp":1,"StackID":2}}`,
+			`{"type":"GoEnd","args":1}`,
+		} {
+			if err := json.Unmarshal([]byte(s), new(Event)); err == nil {
+				t.Fatalf(`exp non-nil err for %s`, s)
+			}
+		}
+	})
+}
+
+func TestTraceMarshalEvent(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, evt := range []*Event{
+		{Type: EvString, Args: []uint64{1}, Data: []byte(`main.main`)},
+		{Type: EvString, Args: []uint64{2}, Data: []byte(`main.go`)},
+		{Type: EvString, Args: []uint64{3}, Data: []byte(`region`)},
+		{Type: EvStack, Args: []uint64{1, 1, 0x400, 1, 2, 40}},
+	} {
+		if err := tr.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evt := &Event{Type: EvUserRegion, Args: []uint64{10, 1, 0, 3, 1}}
+	b, err := tr.MarshalEvent(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{
+		`"NameStringID":"region"`, `"func":"main.main"`, `"file":"main.go"`, `"line":40`,
+	} {
+		if !strings.Contains(string(b), exp) {
+			t.Fatalf(`exp %s in %s`, exp, b)
+		}
+	}
+
+	got := new(Event)
+	if err := json.Unmarshal(b, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(evt.Args, got.Args) {
+		t.Fatalf(`exp args %v; got %v`, evt.Args, got.Args)
+	}
+}
+
+func TestEventBinary(t *testing.T) {
+	tests := []struct {
+		evt *Event
+		exp []byte
+	}{
+		{&Event{Type: EvGoEnd, Args: []uint64{10}}, []byte{0x0f, 0x0a}},
+		{&Event{Type: EvBatch, Args: []uint64{1, 300}}, []byte{0x41, 0x1, 0xac, 0x2}},
+		{&Event{Type: EvGoCreate, Args: []uint64{1, 2, 3, 4}},
+			[]byte{0xcd, 0x4, 0x1, 0x2, 0x3, 0x4}},
+		{&Event{Type: EvString, Args: []uint64{1}, Data: []byte(`main`)},
+			[]byte{0x25, 0x1, 0x4, 'm', 'a', 'i', 'n'}},
+		{&Event{Type: EvUserLog, Args: []uint64{1, 2, 3, 4}, Data: []byte(`v`)},
+			[]byte{0xf0, 0x4, 0x1, 0x2, 0x3, 0x4, 0x1, 'v'}},
+	}
+	for _, test := range tests {
+		b, err := test.evt.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(test.exp, b) {
+			t.Fatalf(`exp %#v for %v; got %#v`, test.exp, test.evt, b)
+		}
+
+		got := &Event{Ts: 10, Data: make([]byte, 0, 8)}
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if got.Type != test.evt.Type || got.Ts != 0 ||
+			!reflect.DeepEqual(got.Args, test.evt.Args) ||
+			string(got.Data) != string(test.evt.Data) {
+			t.Fatalf(`exp %#v; got %#v`, test.evt, got)
+		}
+	}
+
+	t.Run(`Errors`, func(t *testing.T) {
+		if _, err := (&Event{Type: EvCount, Args: []uint64{1}}).MarshalBinary(); err == nil {
+			t.Fatal(`exp non-nil err for invalid type`)
+		}
+		if _, err := (&Event{Type: EvGoEnd}).MarshalBinary(); err == nil {
+			t.Fatal(`exp non-nil err for event without args`)
+		}
+		for _, b := range [][]byte{
+			nil,
+			{byte(EvCount)},
+			{0x0f},
+			{0x0f, 0x0a, 0x0b},
+			{0xcd, 0x9, 0x1},
+			{0xcd, 0x1, 0x80, 0x1},
+			{0x25, 0x1, 0x4, 'm'},
+		} {
+			if err := new(Event).UnmarshalBinary(b); err == nil {
+				t.Fatalf(`exp non-nil err for %#v`, b)
+			}
+		}
+	})
+}
+
+func TestEventArgs(t *testing.T) {
+	evt := &Event{Type: EvGoCreate, Args: []uint64{10, 2, 3, 4}}
+	args, ok := evt.GoCreate(Latest)
+	if !ok {
+		t.Fatal(`exp ok for EvGoCreate`)
+	}
+	exp := GoCreateArgs{Timestamp: 10, NewGoroutineID: 2, NewStackID: 3, StackID: 4}
+	if args != exp {
+		t.Fatalf(`exp %+v; got %+v`, exp, args)
+	}
+	if _, ok := evt.GoStart(Latest); ok {
+		t.Fatal(`exp !ok for another event type`)
+	}
+	if _, ok := (&Event{Type: EvGoCreate, Args: []uint64{10}}).GoCreate(Latest); ok {
+		t.Fatal(`exp !ok for too few arguments`)
+	}
+	if s, ok := (&Event{Type: EvString, Args: []uint64{7}}).StringEntry(Latest); !ok || s.StringID != 7 {
+		t.Fatalf(`exp StringID 7; got %+v`, s)
+	}
+
+	// Version1 events lead with a sequence delta and have no GC sequence.
+	v1 := &Event{Type: EvGoCreate, Args: []uint64{1, 10, 2, 3, 4}}
+	if args, ok = v1.GoCreate(Version1); !ok || args != exp {
+		t.Fatalf(`exp %+v for Version1; got %+v`, exp, args)
+	}
+	gc, ok := (&Event{Type: EvGCStart, Args: []uint64{1, 10, 5}}).GCStart(Version1)
+	if exp := (GCStartArgs{Timestamp: 10, StackID: 5}); !ok || gc != exp {
+		t.Fatalf(`exp %+v for Version1; got %+v`, exp, gc)
+	}
+	if EvGCMarkAssistStart.String() != `event.EvGCMarkAssistStart` {
+		t.Fatalf(`exp original name; got %v`, EvGCMarkAssistStart)
+	}
+}
+
+func TestTraceTime(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evt := &Event{Type: EvGoStart, Args: []uint64{0, 1, 0}, Ts: 3000}
+	if got := tr.Time(evt); !got.IsZero() {
+		t.Fatalf(`exp zero time without a frequency; got %v`, got)
+	}
+
+	for _, e := range []*Event{
+		{Type: EvBatch, Args: []uint64{0, 1000}, Ts: 1000},
+		{Type: EvBatch, Args: []uint64{1, 500}, Ts: 500},
+		{Type: EvFrequency, Args: []uint64{1000}},
+	} {
+		if err := tr.Visit(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tr.Frequency != 1000 || tr.Anchor.Ticks != 1000 {
+		t.Fatalf(`exp frequency 1000 and anchor 1000; got %v and %v`,
+			tr.Frequency, tr.Anchor.Ticks)
+	}
+	if got := tr.Time(evt).Sub(time.Time{}); got != 2*time.Second {
+		t.Fatalf(`exp 2s from the first batch; got %v`, got)
+	}
+
+	wall := time.Date(2018, 8, 24, 0, 0, 0, 0, time.UTC)
+	tr.Anchor = Anchor{Time: wall, Ticks: 2500}
+	if got, exp := tr.Time(evt), wall.Add(500*time.Millisecond); !got.Equal(exp) {
+		t.Fatalf(`exp %v; got %v`, exp, got)
+	}
+
+	t.Run(`Overflow`, func(t *testing.T) {
+		tr := &Trace{Frequency: 3e9}
+		evt := &Event{Ts: 1e13}
+		exp := 3333*time.Second + time.Second/3
+		if got := tr.Time(evt).Sub(time.Time{}); got != exp {
+			t.Fatalf(`exp %v; got %v`, exp, got)
+		}
+	})
+}
+
+func TestTypeArgKinds(t *testing.T) {
+	for typ := EvNone; typ < EvCount; typ++ {
+		args, kinds := typ.Args(), typ.ArgKinds()
+		if len(args) != len(kinds) {
+			t.Fatalf(`%v: exp %d kinds; got %d`, typ, len(args), len(kinds))
+		}
+		for i, kind := range kinds {
+			if kind == KindNone || !kind.Valid() {
+				t.Fatalf(`%v: exp a kind for arg %v; got %v`, typ, args[i], kind)
+			}
+		}
+	}
+
+	kind, ok := EvHeapAlloc.ArgKind(ArgHeapAlloc)
+	if !ok || kind != KindBytes || kind.Unit() != `bytes` {
+		t.Fatalf(`exp HeapAlloc in bytes; got %v (%v) in %q`, kind, ok, kind.Unit())
+	}
+	if kind, ok := EvGoCreate.ArgKind(ArgNewStackID); !ok || kind != KindStack {
+		t.Fatalf(`exp NewStackID to be a stack; got %v (%v)`, kind, ok)
+	}
+	if kind, ok := EvGoCreate.ArgKind(ArgHeapAlloc); ok || kind != KindNone {
+		t.Fatalf(`exp no kind for missing arg; got %v (%v)`, kind, ok)
+	}
+	if got := Kind(255).String(); got != `Kind(255)` {
+		t.Fatalf(`exp Kind(255); got %v`, got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	evt, err := New(EvGoCreate, 10, 2, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evt.Type != EvGoCreate || !reflect.DeepEqual(evt.Args, []uint64{10, 2, 3, 4}) {
+		t.Fatalf(`exp GoCreate with its args; got %v %v`, evt.Type, evt.Args)
+	}
+	if cap(evt.Args) != 4 {
+		t.Fatalf(`exp args to be sized 4; got %v`, cap(evt.Args))
+	}
+
+	stk := MustNew(EvStack, 1, 1, 0x4000, 1, 2, 3)
+	if len(stk.Args) != 6 {
+		t.Fatalf(`exp 6 stack args; got %v`, len(stk.Args))
+	}
+
+	for _, c := range []struct {
+		t    Type
+		args []uint64
+	}{
+		{EvNone, nil},
+		{EvCount, nil},
+		{EvGoCreate, []uint64{10, 2, 3}},
+		{EvGoCreate, []uint64{10, 2, 3, 4, 5}},
+		{EvStack, []uint64{1}},
+		{EvStack, []uint64{1, 1, 0x4000}},
+		{EvStack, []uint64{1, maxStackSize + 1}},
+	} {
+		if _, err := New(c.t, c.args...); err == nil {
+			t.Fatalf(`exp error from New(%v, %v)`, c.t, c.args)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal(`exp MustNew to panic`)
+		}
+	}()
+	MustNew(EvGoStart)
+}
+
+func TestEventSet(t *testing.T) {
+	evt := &Event{Type: EvGoCreate}
+	if err := evt.Set(ArgStackID, 4); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []uint64{0, 0, 0, 4}; !reflect.DeepEqual(evt.Args, exp) {
+		t.Fatalf(`exp args %v; got %v`, exp, evt.Args)
+	}
+
+	evt.With(ArgTimestamp, 10).With(ArgNewGoroutineID, 2).With(ArgNewStackID, 3)
+	if exp := []uint64{10, 2, 3, 4}; !reflect.DeepEqual(evt.Args, exp) {
+		t.Fatalf(`exp args %v; got %v`, exp, evt.Args)
+	}
+	if got := evt.Get(ArgNewGoroutineID); got != 2 {
+		t.Fatalf(`exp NewGoroutineID 2; got %v`, got)
+	}
+
+	if err := evt.Set(ArgHeapAlloc, 1); err == nil {
+		t.Fatal(`exp error for unknown argument`)
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal(`exp With to panic`)
+		}
+	}()
+	evt.With(ArgHeapAlloc, 1)
+}
+
+func TestEventArgsMap(t *testing.T) {
+	evt := MustNew(EvGoCreate, 10, 2, 3, 4)
+	exp := map[string]uint64{
+		ArgTimestamp: 10, ArgNewGoroutineID: 2, ArgNewStackID: 3, ArgStackID: 4}
+	if got := evt.ArgsMap(); !reflect.DeepEqual(got, exp) {
+		t.Fatalf(`exp args %v; got %v`, exp, got)
+	}
+	named := evt.NamedArgs()
+	if len(named) != 4 || named[0] != (NamedArg{ArgTimestamp, 10}) ||
+		named[3] != (NamedArg{ArgStackID, 4}) {
+		t.Fatalf(`exp args in schema order; got %v`, named)
+	}
+
+	// Frames of a stack are not named and short events name what they have.
+	evt = MustNew(EvStack, 7, 1, 0x40, 1, 2, 12)
+	if got := evt.ArgsMap(); len(got) != 2 || got[ArgStackSize] != 1 {
+		t.Fatalf(`exp only named stack args; got %v`, got)
+	}
+	evt = &Event{Type: EvGoCreate, Args: []uint64{10}}
+	if got := evt.NamedArgs(); len(got) != 1 {
+		t.Fatalf(`exp 1 named arg; got %v`, got)
+	}
+}
+
+func TestPool(t *testing.T) {
+	p := NewPool(4, 8)
+
+	evt := p.Get()
+	evt.Type, evt.Ts, evt.Args = EvGoStart, 10, append(evt.Args, 1, 2, 3)
+	evt.Data = append(evt.Data, `abc`...)
+	p.Put(evt)
+	p.Put(nil)
+
+	// sync.Pool may drop values, so only check what was returned when reused.
+	if got := p.Get(); got == evt {
+		if got.Type != EvNone || got.Ts != 0 || len(got.Args) != 0 || len(got.Data) != 0 {
+			t.Fatalf(`exp reset event; got %#v`, got)
+		}
+		if cap(got.Args) < 3 || cap(got.Data) < 3 {
+			t.Fatal(`exp small Args and Data to be retained`)
+		}
+	}
+
+	big := &Event{Args: make([]uint64, 5), Data: make([]byte, 9)}
+	p.Put(big)
+	if big.Args != nil || big.Data != nil {
+		t.Fatal(`exp large Args and Data to be released`)
+	}
+
+	var zero Pool
+	evt = zero.Get()
+	evt.Args = make([]uint64, defaultPoolArgs)
+	zero.Put(evt)
+	if evt.Args == nil {
+		t.Fatal(`exp default capacity to be retained`)
+	}
+}
+
+func TestOrderer(t *testing.T) {
+	at := func(evt *Event, p, ts int64) *Event {
+		evt.P, evt.Ts = p, ts
+		return evt
+	}
+
+	t.Run(`Sequence`, func(t *testing.T) {
+		o := NewOrderer(Latest)
+		for _, evt := range []*Event{
+			at(MustNew(EvBatch, 1, 5), 1, 5),
+			at(MustNew(EvGoStart, 5, 5, 3), 1, 5),
+			at(MustNew(EvProcStop, 9), 1, 9),
+			at(MustNew(EvBatch, 0, 1), 0, 1),
+			at(MustNew(EvGoCreate, 1, 5, 0, 0), 0, 1),
+			at(MustNew(EvGoWaiting, 1, 5), 0, 1),
+			at(MustNew(EvGoUnblock, 6, 5, 2, 0), 0, 6),
+			at(MustNew(EvString, 1), 0, 0),
+		} {
+			o.Push(evt)
+		}
+		if o.Len() != 5 {
+			t.Fatalf(`exp 5 pending events; got %v`, o.Len())
+		}
+
+		// The GoStart on P 1 has the earliest timestamp, but depends on the
+		// GoUnblock of P 0.
+		exp := []Type{EvGoCreate, EvGoWaiting, EvGoUnblock, EvGoStart, EvProcStop}
+		for _, typ := range exp {
+			evt, err := o.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if evt.Type != typ {
+				t.Fatalf(`exp %v; got %v`, typ, evt.Type)
+			}
+		}
+		if _, err := o.Next(); err != io.EOF {
+			t.Fatalf(`exp io.EOF; got %v`, err)
+		}
+	})
+	t.Run(`Inconsistent`, func(t *testing.T) {
+		o := NewOrderer(Latest)
+		o.Push(at(MustNew(EvGoStart, 5, 5, 3), 1, 5))
+		if _, err := o.Next(); err == nil {
+			t.Fatal(`exp error for unsatisfiable ordering`)
+		}
+	})
+	t.Run(`Version1`, func(t *testing.T) {
+		o := NewOrderer(Version1)
+		for _, evt := range []*Event{
+			at(&Event{Type: EvBatch, Args: []uint64{0, 10, 100}}, 0, 100),
+			at(&Event{Type: EvProcStart, Args: []uint64{1, 1, 1}}, 0, 101),
+			at(&Event{Type: EvProcStop, Args: []uint64{2, 1}}, 0, 102),
+			at(&Event{Type: EvBatch, Args: []uint64{1, 11, 50}}, 1, 50),
+			at(&Event{Type: EvGoEnd, Args: []uint64{1, 1}}, 1, 51),
+		} {
+			o.Push(evt)
+		}
+		exp := []Type{EvProcStart, EvGoEnd, EvProcStop}
+		for _, typ := range exp {
+			evt, err := o.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if evt.Type != typ {
+				t.Fatalf(`exp %v; got %v`, typ, evt.Type)
+			}
+		}
+		if _, err := o.Next(); err != io.EOF {
+			t.Fatalf(`exp io.EOF; got %v`, err)
+		}
+	})
+}
+
+func TestTraceGoroutines(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Stacks[2] = Stack{NewFrame(0x10, `main.worker`, `main.go`, 1)}
+
+	on := func(evt *Event, g, ts int64) *Event {
+		evt.G, evt.Ts = g, ts
+		return evt
+	}
+	evts := []*Event{
+		on(MustNew(EvGoCreate, 0, 7, 2, 1), 0, 10),
+		on(MustNew(EvGoCreate, 0, 8, 0, 0), 0, 12),
+		on(MustNew(EvGoStart, 0, 7, 1), 7, 15),
+		on(MustNew(EvGoBlockRecv, 0, 0), 7, 25),
+		on(MustNew(EvGoUnblock, 0, 7, 2, 0), 0, 40),
+		on(MustNew(EvGoStart, 0, 7, 3), 7, 45),
+		on(MustNew(EvGoSysBlock, 0), 7, 50),
+		on(MustNew(EvGoSysExit, 0, 7, 4, 55), 7, 60),
+		on(MustNew(EvGoStart, 0, 7, 5), 7, 62),
+		on(MustNew(EvGoEnd, 0), 7, 70),
+		on(MustNew(EvProcStop, 0), 0, 80),
+	}
+	gs := tr.Goroutines(evts)
+	if len(gs) != 2 {
+		t.Fatalf(`exp 2 goroutines; got %v`, len(gs))
+	}
+
+	g := gs[7]
+	exp := GDesc{
+		ID: 7, Name: `main.worker`, PC: 0x10, CreateStackID: 1, StartStackID: 2,
+		Initial: GRunnable, CreateTime: 10, StartTime: 15, EndTime: 70, EndReason: EvGoEnd,
+		TotalTime: 60, ExecTime: 23, SchedWaitTime: 17, BlockTime: 15,
+		SyscallTime: 5,
+	}
+	got := *g
+	got.Transitions, got.status, got.since, got.wait = nil, 0, 0, 0
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("exp:\n  %+v\ngot:\n  %+v", exp, got)
+	}
+	if n := len(g.Transitions); n != 9 {
+		t.Fatalf(`exp 9 transitions; got %v`, n)
+	}
+	if tn := g.Transitions[2]; tn.Type != EvGoBlockRecv || tn.From != GRunning || tn.To != GWaiting {
+		t.Fatalf(`exp GoBlockRecv from Running to Waiting; got %+v`, tn)
+	}
+
+	alive := gs[8]
+	if alive.EndReason != EvNone || alive.EndTime != 0 || alive.TotalTime != 68 ||
+		alive.SchedWaitTime != 68 {
+		t.Fatalf(`exp goroutine 8 runnable until the end of the trace; got %+v`, alive)
+	}
+	if s := alive.Transitions[0].To.String(); s != `Runnable` {
+		t.Fatalf(`exp Runnable; got %v`, s)
+	}
+}
+
+func TestTraceGC(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	on := func(evt *Event, p, g, ts int64) *Event {
+		evt.P, evt.G, evt.Ts = p, g, ts
+		return evt
+	}
+	evts := []*Event{
+		on(MustNew(EvHeapAlloc, 0, 100), 0, 0, 1),
+		on(MustNew(EvNextGC, 0, 200), 0, 0, 2),
+		on(MustNew(EvGCStart, 0, 1, 0), 0, 0, 10),
+		on(MustNew(EvGCSTWStart, 0, 0), 0, 0, 10),
+		on(MustNew(EvGCSTWDone, 0), 0, 0, 13),
+		on(MustNew(EvGCMarkAssistStart, 0, 0), 1, 5, 14),
+		on(MustNew(EvGCSTWStart, 0, 0), 0, 0, 20),
+		on(MustNew(EvGCMarkAssistDone, 0), 1, 5, 21),
+		on(MustNew(EvGCSTWDone, 0), 0, 0, 25),
+		on(MustNew(EvGCDone, 0), 0, 0, 26),
+		on(MustNew(EvHeapAlloc, 0, 80), 0, 0, 27),
+		on(MustNew(EvNextGC, 0, 160), 0, 0, 27),
+		on(MustNew(EvGCSweepStart, 0, 0), 2, 6, 30),
+		on(MustNew(EvGCSweepDone, 0), 2, 6, 34),
+		on(MustNew(EvGCStart, 0, 2, 0), 0, 0, 50),
+	}
+	sum := tr.GC(evts)
+
+	if len(sum.Cycles) != 2 || len(sum.Heap) != 4 {
+		t.Fatalf(`exp 2 cycles and 4 heap samples; got %v and %v`,
+			len(sum.Cycles), len(sum.Heap))
+	}
+	if sum.PauseTime != 8 || sum.MaxPause != 5 || sum.AssistTime != 7 ||
+		sum.SweepTime != 4 {
+		t.Fatalf(`exp totals 8, 5, 7 and 4; got %+v`, sum)
+	}
+
+	c := sum.Cycles[0]
+	if c.Seq != 1 || c.Start != 10 || c.End != 26 || c.Duration() != 16 {
+		t.Fatalf(`exp first cycle from 10 to 26; got %+v`, c)
+	}
+
+	// Version1 cycles have no sequence, the argument following the timestamp
+	// is the stack.
+	tr1, err := NewTrace(Version1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := tr1.GC([]*Event{{Type: EvGCStart, Ts: 10, Args: []uint64{1, 10, 3}}})
+	if len(v1.Cycles) != 1 || v1.Cycles[0].Seq != 0 {
+		t.Fatalf(`exp Version1 cycle without a sequence; got %+v`, v1.Cycles)
+	}
+	if len(c.Pauses) != 2 || c.PauseTime != 8 || c.AssistTime != 7 || c.SweepTime != 4 {
+		t.Fatalf(`exp first cycle pauses, assists and sweeps; got %+v`, c)
+	}
+	if c.HeapAlloc != 100 || c.HeapGoal != 200 || c.HeapLive != 80 || c.NextGoal != 160 {
+		t.Fatalf(`exp first cycle heap 100, 200, 80, 160; got %+v`, c)
+	}
+
+	c = sum.Cycles[1]
+	if c.Seq != 2 || c.End != 0 || c.Duration() != 0 || c.HeapAlloc != 80 || c.HeapGoal != 160 {
+		t.Fatalf(`exp incomplete second cycle; got %+v`, c)
+	}
+	if exp := (HeapSample{Ts: 27, Alloc: 80, Goal: 200}); sum.Heap[2] != exp {
+		t.Fatalf(`exp heap sample %+v; got %+v`, exp, sum.Heap[2])
+	}
+}
+
+func TestTraceSyscalls(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	on := func(evt *Event, g, ts int64) *Event {
+		evt.G, evt.Ts = g, ts
+		return evt
+	}
+	evts := []*Event{
+		on(MustNew(EvGoInSyscall, 0, 9), 0, 1),
+		on(MustNew(EvGoSysCall, 0, 3), 5, 10),
+		on(MustNew(EvGoSysCall, 0, 3), 5, 12),
+		on(MustNew(EvGoSysBlock, 0), 5, 14),
+		on(MustNew(EvGoSysCall, 0, 4), 6, 15),
+		on(MustNew(EvGoSysBlock, 0), 6, 16),
+		on(MustNew(EvGoSysExit, 0, 5, 1, 28), 0, 30),
+		on(MustNew(EvGoSysExit, 0, 6, 1, 0), 0, 40),
+		on(MustNew(EvGoSysCall, 0, 4), 7, 41),
+		on(MustNew(EvGoEnd, 0), 7, 42),
+		on(MustNew(EvProcStop, 0), 0, 50),
+	}
+	sum := tr.Syscalls(evts)
+
+	exp := SyscallStat{Calls: 5, Blocked: 3, Time: 16 + 25 + 49, MaxTime: 49}
+	if sum.SyscallStat != exp {
+		t.Fatalf(`exp %+v; got %+v`, exp, sum.SyscallStat)
+	}
+	if sum.MaxBlocked != 3 {
+		t.Fatalf(`exp 3 blocked threads at most; got %v`, sum.MaxBlocked)
+	}
+	if g := sum.Goroutines[5]; g == nil || *g != (SyscallStat{2, 1, 16, 16}) {
+		t.Fatalf(`exp goroutine 5 to block once until its real exit; got %+v`, g)
+	}
+
+	top := sum.Top(2)
+	if len(top) != 2 || top[0].StackID != 0 || top[1].StackID != 4 {
+		t.Fatalf(`exp stacks 0 and 4 first; got %+v`, top)
+	}
+	if top[1].SyscallStat != (SyscallStat{2, 1, 25, 25}) {
+		t.Fatalf(`exp stack 4 to block once for 25; got %+v`, top[1])
+	}
+	if all := sum.Top(-1); len(all) != 3 || all[2].StackID != 3 {
+		t.Fatalf(`exp stack 3 last of all sites; got %+v`, all)
+	}
+}
+
+func TestTraceBlockProfile(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	on := func(evt *Event, g, ts int64) *Event {
+		evt.G, evt.Ts = g, ts
+		return evt
+	}
+	evts := []*Event{
+		on(MustNew(EvGoBlockRecv, 0, 3), 5, 10),
+		on(MustNew(EvGoBlockRecv, 0, 3), 6, 12),
+		on(MustNew(EvGoBlockSync, 0, 4), 7, 13),
+		on(MustNew(EvGoUnblock, 0, 5, 2, 0), 1, 20),
+		on(MustNew(EvGoStart, 0, 6, 2), 6, 62),
+		on(MustNew(EvGoBlockNet, 0, 9), 8, 70),
+	}
+	p := tr.BlockProfile(evts)
+
+	exp := []BlockRecord{
+		{StackID: 3, Type: EvGoBlockRecv, Count: 2, Time: 60},
+	}
+	if !reflect.DeepEqual(p.Records, exp) || p.Count != 2 || p.Time != 60 {
+		t.Fatalf(`exp records %+v; got %+v`, exp, p)
+	}
+}
+
+func TestTraceSyscallProfile(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	on := func(evt *Event, g, ts int64) *Event {
+		evt.G, evt.Ts = g, ts
+		return evt
+	}
+
+	// Goroutine 5 blocks in two syscalls, 6 makes one that does not block and
+	// 7 is still in a syscall at the end of the trace, timed until its end.
+	evts := []*Event{
+		on(MustNew(EvGoSysCall, 0, 3), 5, 10),
+		on(MustNew(EvGoSysBlock, 0), 5, 11),
+		on(MustNew(EvGoSysCall, 0, 4), 6, 12),
+		on(MustNew(EvGoSysExit, 0, 5, 1, 0), 0, 40),
+		on(MustNew(EvGoSysCall, 0, 3), 5, 50),
+		on(MustNew(EvGoSysBlock, 0), 5, 51),
+		on(MustNew(EvGoSysCall, 0, 4), 7, 55),
+		on(MustNew(EvGoSysBlock, 0), 7, 56),
+		on(MustNew(EvGoSysExitLocal, 0, 5, 0), 0, 60),
+		on(MustNew(EvGoSysExit, 0, 6, 1, 0), 0, 70),
+	}
+	p := tr.SyscallProfile(evts)
+
+	exp := []BlockRecord{
+		{StackID: 3, Type: EvGoSysCall, Count: 2, Time: 40},
+		{StackID: 4, Type: EvGoSysCall, Count: 1, Time: 15},
+	}
+	if !reflect.DeepEqual(p.Records, exp) || p.Count != 3 || p.Time != 55 {
+		t.Fatalf(`exp records %+v; got %+v`, exp, p)
+	}
+}
+
+func TestTraceSchedProfile(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(evt *Event, ts int64) *Event {
+		evt.Ts = ts
+		return evt
+	}
+
+	// Goroutine 5 is created from stack 3 and later unblocked from stack 4,
+	// goroutine 6 is unblocked but never runs.
+	evts := []*Event{
+		at(MustNew(EvGoCreate, 0, 5, 9, 3), 10),
+		at(MustNew(EvGoStart, 0, 5, 0), 25),
+		at(MustNew(EvGoUnblock, 0, 5, 1, 4), 30),
+		at(MustNew(EvGoUnblock, 0, 6, 1, 4), 31),
+		at(MustNew(EvGoStartLocal, 0, 5), 32),
+		at(MustNew(EvGoUnblockLocal, 0, 5, 4), 40),
+		at(MustNew(EvGoStartLabel, 0, 5, 2, 1), 50),
+	}
+	p := tr.SchedProfile(evts)
+
+	exp := []BlockRecord{
+		{StackID: 3, Type: EvGoCreate, Count: 1, Time: 15},
+		{StackID: 4, Type: EvGoUnblockLocal, Count: 1, Time: 10},
+		{StackID: 4, Type: EvGoUnblock, Count: 1, Time: 2},
+	}
+	if !reflect.DeepEqual(p.Records, exp) || p.Count != 3 || p.Time != 27 {
+		t.Fatalf(`exp records %+v; got %+v`, exp, p)
+	}
+}
+
+func TestTraceNetWaits(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Goroutine 5 waits on stack 3 for 1 through 100 ticks, goroutine 6 on
+	// stack 4 for 500 ticks and goroutine 7 blocks on a channel.
+	var evts []*Event
+	var ts int64
+	for i := int64(1); i <= 100; i++ {
+		blk := MustNew(EvGoBlockNet, 0, 3)
+		blk.G, blk.Ts = 5, ts
+		unblk := MustNew(EvGoUnblock, 0, 5, 0, 0)
+		unblk.Ts = ts + i
+		evts, ts = append(evts, blk, unblk), ts+i
+	}
+	blk := MustNew(EvGoBlockNet, 0, 4)
+	blk.G, blk.Ts = 6, ts
+	start := MustNew(EvGoStart, 0, 6, 0)
+	start.G, start.Ts = 6, ts+500
+	recv := MustNew(EvGoBlockRecv, 0, 9)
+	recv.G, recv.Ts = 7, ts
+	unblk := MustNew(EvGoUnblock, 0, 7, 0, 0)
+	unblk.Ts = ts + 1000
+	evts = append(evts, blk, recv, start, unblk)
+
+	dists := tr.NetWaits(evts)
+	if len(dists) != 2 {
+		t.Fatalf(`exp 2 stacks; got %v`, len(dists))
+	}
+	d := dists[0]
+	if d.StackID != 3 || d.Count != 100 || d.Time != 5050 || d.Min != 1 ||
+		d.Max != 100 || d.P50 != 50 || d.P95 != 95 || d.P99 != 99 {
+		t.Fatalf(`exp stack 3 waits 1 through 100; got %+v`, d)
+	}
+	if d.Quantile(0) != 1 || d.Quantile(1) != 100 || d.Quantile(.001) != 1 {
+		t.Fatalf(`exp quantile bounds 1 and 100`)
+	}
+	if d = dists[1]; d.StackID != 4 || d.Count != 1 || d.P50 != 500 || d.P99 != 500 {
+		t.Fatalf(`exp stack 4 wait of 500; got %+v`, d)
+	}
+	if (&WaitDist{}).Quantile(.5) != 0 {
+		t.Fatal(`exp zero quantile without waits`)
+	}
+}
+
+func TestTraceTasks(t *testing.T) {
+	tr, err := NewTrace(Version5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, s := range map[uint64]string{
+		1: `request`, 2: `db`, 3: `query`, 4: `scan`, 5: `user`, 6: `bg`} {
+		tr.Strings[id] = s
+	}
+	on := func(evt *Event, g, ts int64) *Event {
+		evt.G, evt.Ts = g, ts
+		return evt
+	}
+	logEvt := on(MustNew(EvUserLog, 0, 2, 5, 7), 5, 25)
+	logEvt.Data = []byte(`alice`)
+	evts := []*Event{
+		on(MustNew(EvUserTaskCreate, 0, 1, 0, 1, 8), 5, 10),
+		on(MustNew(EvUserTaskCreate, 0, 2, 1, 2, 8), 5, 12),
+		on(MustNew(EvUserRegion, 0, 2, 0, 3, 9), 5, 20),
+		on(MustNew(EvUserRegion, 0, 2, 0, 4, 9), 5, 21),
+		on(MustNew(EvUserRegion, 0, 2, 1, 4, 9), 5, 24),
+		logEvt,
+		on(MustNew(EvUserRegion, 0, 2, 1, 3, 9), 5, 30),
+		on(MustNew(EvUserTaskEnd, 0, 2, 8), 5, 31),
+		on(MustNew(EvUserRegion, 0, 0, 1, 6, 9), 6, 33),
+		on(MustNew(EvUserTaskEnd, 0, 1, 8), 5, 40),
+		on(MustNew(EvUserTaskEnd, 0, 7, 8), 6, 41),
+	}
+	tt, err := tr.Tasks(evts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tt.Tasks) != 3 || len(tt.Roots) != 2 {
+		t.Fatalf(`exp 3 tasks and 2 roots; got %v and %v`, len(tt.Tasks), len(tt.Roots))
+	}
+	if early := tt.Roots[0]; early.ID != 7 || early.Start != 0 || early.End != 41 {
+		t.Fatalf(`exp task 7 created before the trace first; got %+v`, early)
+	}
+	req := tt.Roots[1]
+	if req.Name != `request` || req.Duration() != 30 || len(req.Children) != 1 {
+		t.Fatalf(`exp request task of 30 with 1 child; got %+v`, req)
+	}
+
+	db := req.Children[0]
+	if db.Name != `db` || db.Parent != req || db.Duration() != 19 {
+		t.Fatalf(`exp db task of 19 within request; got %+v`, db)
+	}
+	if len(db.Logs) != 1 || db.Logs[0] != (TaskLog{25, 5, `user`, `alice`, 7}) {
+		t.Fatalf(`exp user log in db task; got %+v`, db.Logs)
+	}
+	if len(db.Regions) != 1 {
+		t.Fatalf(`exp 1 region in db task; got %v`, len(db.Regions))
+	}
+	q := db.Regions[0]
+	if q.Name != `query` || q.Duration() != 10 || len(q.Children) != 1 {
+		t.Fatalf(`exp query region of 10 with 1 child; got %+v`, q)
+	}
+	if scan := q.Children[0]; scan.Name != `scan` || scan.Duration() != 3 {
+		t.Fatalf(`exp scan region of 3; got %+v`, scan)
+	}
+
+	if len(tt.Regions) != 1 || tt.Regions[0].Name != `bg` || tt.Regions[0].End != 33 ||
+		tt.Regions[0].Duration() != 0 {
+		t.Fatalf(`exp bg region begun before the trace; got %+v`, tt.Regions)
+	}
+
+	tr.Version = Version4
+	if tt, err := tr.Tasks(evts); err != nil || len(tt.Tasks) != 0 {
+		t.Fatalf(`exp empty tree for Version4; got %v, %v`, tt, err)
+	}
+}
+
+func TestTraceSnapshot(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wall := time.Date(2018, 8, 24, 0, 0, 0, 0, time.UTC)
+	for _, evt := range []*Event{
+		{Type: EvBatch, Args: []uint64{0, 100}, Ts: 100},
+		{Type: EvString, Args: []uint64{1}, Data: []byte(`main.main`)},
+		{Type: EvString, Args: []uint64{2}, Data: []byte(`main.go`)},
+		{Type: EvString, Args: []uint64{3}, Span: Span{Off: 4, Len: 3}},
+		{Type: EvStack, Args: []uint64{7, 1, 0x40, 1, 2, 12}},
+		{Type: EvFrequency, Args: []uint64{1e9}},
+	} {
+		if err := tr.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tr.Stacks[8] = Stack{NewFrame(0x50, `ext.fn`, `ext.go`, 3)}
+	tr.Anchor.Time = wall
+
+	b, err := tr.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Trace
+	got.Source = strings.NewReader(`xxxxabc`)
+	if err := got.RestoreSnapshot(b); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != Latest || got.Count != tr.Count || got.Frequency != 1e9 ||
+		got.Anchor != tr.Anchor {
+		t.Fatalf(`exp restored trace state; got %+v`, got)
+	}
+	if s, err := got.GetString(3); err != nil || s != `abc` {
+		t.Fatalf(`exp lazy string abc from Source; got %q (%v)`, s, err)
+	}
+
+	for id, exp := range map[uint64][3]string{
+		7: {`main.main`, `main.go`, `12`}, 8: {`ext.fn`, `ext.go`, `3`}} {
+		stk := got.Stacks[id]
+		if len(stk) != 1 {
+			t.Fatalf(`exp stack %v with 1 frame; got %v`, id, stk)
+		}
+		f := stk[0]
+		if f.Func() != exp[0] || f.File() != exp[1] || fmt.Sprint(f.Line()) != exp[2] {
+			t.Fatalf(`exp frame %v; got %v`, exp, f)
+		}
+	}
+
+	// Visiting continues from the restored state.
+	evt := &Event{Type: EvGoStart, Args: []uint64{0, 1, 0}, Ts: 100 + 2e9}
+	if err := got.Visit(evt); err != nil {
+		t.Fatal(err)
+	}
+	if exp := wall.Add(2 * time.Second); !got.Time(evt).Equal(exp) {
+		t.Fatalf(`exp %v; got %v`, exp, got.Time(evt))
+	}
+
+	for _, b := range [][]byte{
+		[]byte(`{`), []byte(`{"format":2,"version":5}`), []byte(`{"format":1,"version":0}`),
+	} {
+		if err := got.RestoreSnapshot(b); err == nil {
+			t.Fatalf(`exp error restoring %s`, b)
+		}
+	}
+}
+
+func TestTraceLimits(t *testing.T) {
+	str := func(id uint64, s string) *Event {
+		return &Event{Type: EvString, Args: []uint64{id}, Data: []byte(s)}
+	}
+	stk := func(id uint64) *Event {
+		return &Event{Type: EvStack, Args: []uint64{id, 1, 0x40, 1, 2, 3}}
+	}
+
+	t.Run(`Unbounded`, func(t *testing.T) {
+		tr, err := NewTrace(Latest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, evt := range []*Event{str(1, `abc`), stk(1)} {
+			if err := tr.Visit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if exp := stringSize(`abc`) + entryBytes + frameBytes; tr.MemoryUsage() != exp {
+			t.Fatalf(`exp %v bytes; got %v`, exp, tr.MemoryUsage())
+		}
+	})
+	t.Run(`Error`, func(t *testing.T) {
+		tr, err := NewTrace(Latest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr.Limits = TableLimits{MaxStrings: 1, MaxStacks: 1}
+		if err := tr.Visit(str(1, `a`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := tr.Visit(str(2, `b`)); err != ErrTableFull {
+			t.Fatalf(`exp ErrTableFull; got %v`, err)
+		}
+		if err := tr.Visit(stk(1)); err != nil {
+			t.Fatal(err)
+		}
+		if err := tr.Visit(stk(2)); err != ErrTableFull {
+			t.Fatalf(`exp ErrTableFull; got %v`, err)
+		}
+
+		tr.Reset()
+		if tr.Limits.MaxStrings != 1 || tr.MemoryUsage() != 0 {
+			t.Fatal(`exp Reset to retain limits and clear usage`)
+		}
+	})
+	t.Run(`Evict`, func(t *testing.T) {
+		tr, err := NewTrace(Latest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr.Limits = TableLimits{MaxStrings: 2, Evict: true}
+		for _, evt := range []*Event{str(1, `a`), str(2, `b`)} {
+			if err := tr.Visit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := tr.GetString(1); err != nil {
+			t.Fatal(err)
+		}
+		if err := tr.Visit(str(3, `c`)); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := tr.Strings[2]; ok || len(tr.Strings) != 2 {
+			t.Fatalf(`exp least recently used string 2 evicted; got %v`, tr.Strings)
+		}
+		if exp := stringSize(`a`) + stringSize(`c`); tr.MemoryUsage() != exp {
+			t.Fatalf(`exp %v bytes; got %v`, exp, tr.MemoryUsage())
+		}
+
+		// Byte limits evict across both tables, oldest first.
+		tr.Reset()
+		tr.Version = Latest
+		tr.Limits = TableLimits{MaxBytes: stringSize(`a`) + stackSize(make(Stack, 1)), Evict: true}
+		for _, evt := range []*Event{str(1, `a`), stk(1), str(2, `b`)} {
+			if err := tr.Visit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, ok := tr.Strings[1]; ok || len(tr.Stacks) != 1 || tr.Strings[2] != `b` {
+			t.Fatalf(`exp string 1 evicted; got %v %v`, tr.Strings, tr.Stacks)
+		}
+	})
+}
+
+func TestSafeTrace(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := NewSafeTrace(tr)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i uint64) {
+			defer wg.Done()
+			for j := uint64(1); j <= 100; j++ {
+				id := i*100 + j
+				evt := &Event{Type: EvString, Args: []uint64{id}, Data: []byte(`s`)}
+				if err := st.Visit(evt); err != nil {
+					t.Error(err)
+					return
+				}
+				if s, err := st.GetString(id); err != nil || s != `s` {
+					t.Errorf(`exp string s for %v; got %q (%v)`, id, s, err)
+					return
+				}
+			}
+		}(uint64(i))
+	}
+	wg.Wait()
+
+	err = st.Do(func(tr *Trace) error {
+		if len(tr.Strings) != 800 || tr.Count != 800 {
+			t.Fatalf(`exp 800 strings; got %v`, len(tr.Strings))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.MemoryUsage() != 800*stringSize(`s`) {
+		t.Fatalf(`exp memory of 800 strings; got %v`, st.MemoryUsage())
+	}
+	if _, err := st.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Stack(&Event{Type: EvGoSched, Args: []uint64{0, 1}}); err == nil {
+		t.Fatal(`exp error for missing stack`)
+	}
+	if !st.Time(&Event{}).IsZero() {
+		t.Fatal(`exp zero time without a frequency`)
+	}
+}
+
+func TestTraceStacks(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Limits = TableLimits{MaxStacks: 4, Evict: true}
+	for id, s := range map[uint64]string{
+		1: `net/http.(*conn).serve`, 2: `main.handler`, 3: `runtime.goexit`,
+		4: `main.main`, 5: `x.go`} {
+		tr.Strings[id] = s
+	}
+	for _, args := range [][]uint64{
+		{1, 2, 0x10, 2, 5, 1, 0x20, 1, 5, 2},
+		{2, 1, 0x30, 4, 5, 3},
+		{3, 2, 0x10, 2, 5, 1, 0x20, 1, 5, 2},
+		{4, 1, 0x40, 3, 5, 4},
+	} {
+		if err := tr.Visit(&Event{Type: EvStack, Args: args}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := tr.CanonicalStackID(3); got != 1 {
+		t.Fatalf(`exp stack 3 to be identical to 1; got %v`, got)
+	}
+	for _, id := range []uint64{1, 2, 4, 99} {
+		if got := tr.CanonicalStackID(id); got != id {
+			t.Fatalf(`exp stack %v to be canonical; got %v`, id, got)
+		}
+	}
+	if &tr.Stacks[1][0] != &tr.Stacks[3][0] {
+		t.Fatal(`exp identical stacks to share frames`)
+	}
+	if exp := 2*entryBytes + 3*frameBytes + entryBytes; tr.MemoryUsage() != exp+entryBytes+frameBytes {
+		t.Fatalf(`exp shared stack to be accounted once; got %v`, tr.MemoryUsage())
+	}
+
+	for pattern, exp := range map[string][]uint64{
+		`^net/http\.`: {1, 3},
+		`^main\.`:     {1, 2, 3},
+		`goexit$`:     {4},
+		`^nothing$`:   nil,
+	} {
+		got, err := tr.StacksByFunc(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf(`exp %v for %q; got %v`, exp, pattern, got)
+		}
+	}
+	if _, err := tr.StacksByFunc(`(`); err == nil {
+		t.Fatal(`exp error for invalid pattern`)
+	}
+
+	if err := tr.Visit(&Event{Type: EvStack, Args: []uint64{5, 1, 0x50, 4, 5, 9}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tr.Stacks[1]; ok {
+		t.Fatal(`exp stack 1 to be evicted`)
+	}
+	if got := tr.CanonicalStackID(3); got != 3 {
+		t.Fatalf(`exp stack 3 canonical once 1 is evicted; got %v`, got)
+	}
+}
+
+func TestTraceSymbolizer(t *testing.T) {
+	pc := uint64(reflect.ValueOf(TestTraceSymbolizer).Pointer())
+	const name = `github.com/cstockton/go-trace/event.TestTraceSymbolizer`
+
+	syms := map[string]Symbolizer{`Runtime`: RuntimeSymbolizer{}}
+	if f, err := os.Open(os.Args[0]); err == nil {
+		defer f.Close()
+		if s, err := NewELFSymbolizer(f); err == nil {
+			syms[`ELF`] = s
+		}
+	}
+	for label, sym := range syms {
+		t.Run(label, func(t *testing.T) {
+			tr, err := NewTrace(Version1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr.Symbolizer = sym
+			evt := &Event{Type: EvStack, Args: []uint64{1, 2, pc, 1}}
+			if err := tr.Visit(evt); err != nil {
+				t.Fatal(err)
+			}
+
+			stk := tr.Stacks[1]
+			if got := stk[0].Func(); !strings.HasSuffix(got, `TestTraceSymbolizer`) {
+				t.Fatalf(`exp func %v; got %v`, name, got)
+			}
+			if got := stk[0].File(); got != `` && !strings.HasSuffix(got, `event_test.go`) {
+				t.Fatalf(`exp file event_test.go; got %v`, got)
+			}
+			if stk[1].PC() != 1 || stk[1].Line() != 0 {
+				t.Fatalf(`exp unresolved frame to hold only its pc; got %v`, stk[1])
+			}
+
+			tr.Reset()
+			if tr.Symbolizer != sym {
+				t.Fatal(`exp Symbolizer to be retained across Reset`)
+			}
+		})
+	}
+
+	tr, err := NewTrace(Version1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Visit(&Event{Type: EvStack, Args: []uint64{1, 1, pc}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := tr.Stacks[1][0].PC(); got != pc {
+		t.Fatalf(`exp pc %v without a Symbolizer; got %v`, pc, got)
+	}
+	if _, err := NewELFSymbolizer(strings.NewReader(`not elf`)); err == nil {
+		t.Fatal(`exp error for a non ELF binary`)
+	}
+}
+
+func TestMultiVisitor(t *testing.T) {
+	sentinel := errors.New(`sentinel`)
+	evts := []*Event{
+		{Type: EvFrequency, Args: []uint64{100}},
+		{Type: EvString, Args: []uint64{1}, Data: []byte(`main`)},
+		{Type: EvGoStart, Args: []uint64{1, 2}},
+	}
+
+	var seen []Type
+	record := VisitorFunc(func(evt *Event) error {
+		seen = append(seen, evt.Type)
+		return nil
+	})
+	fail := VisitorFunc(func(evt *Event) error {
+		if evt.Type == EvString {
+			return sentinel
+		}
+		return nil
+	})
+
+	t.Run(`FailFast`, func(t *testing.T) {
+		seen = nil
+		tr, err := NewTrace(Latest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mv := MultiVisitor(tr, fail, record)
+		var got error
+		for _, evt := range evts {
+			if got = mv.Visit(evt); got != nil {
+				break
+			}
+		}
+		if got != sentinel {
+			t.Fatalf(`exp %v; got %v`, sentinel, got)
+		}
+		if exp := []Type{EvFrequency}; !reflect.DeepEqual(seen, exp) {
+			t.Fatalf(`exp visitors after a failure to be skipped; got %v`, seen)
+		}
+		if tr.Frequency != 100 || tr.Strings[1] != `main` {
+			t.Fatalf(`exp visitors before a failure to visit; got %v`, tr)
+		}
+	})
+
+	t.Run(`Collect`, func(t *testing.T) {
+		seen = nil
+		skip := VisitorFunc(func(evt *Event) error {
+			if evt.Type == EvFrequency {
+				return nil
+			}
+			return record(evt)
+		})
+		c := Collect(fail, skip, fail)
+		for _, evt := range evts {
+			if err := c.Visit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if exp := []Type{EvString, EvGoStart}; !reflect.DeepEqual(seen, exp) {
+			t.Fatalf(`exp %v; got %v`, exp, seen)
+		}
+
+		errs := c.Errors()
+		if len(errs) != 2 || errs[0].Visitor != 0 || errs[1].Visitor != 2 {
+			t.Fatalf(`exp an error from visitors 0 and 2; got %v`, errs)
+		}
+		if errs[0].Type != EvString || !errors.Is(errs[0], sentinel) {
+			t.Fatalf(`exp error to wrap sentinel for EvString; got %v`, errs[0])
+		}
+		if err := c.Err(); err == nil || !strings.Contains(err.Error(), `1 more`) {
+			t.Fatalf(`exp Err to summarize errors; got %v`, err)
+		}
+		if err := Collect().Err(); err != nil {
+			t.Fatalf(`exp nil Err without errors; got %v`, err)
+		}
+	})
+}
+
+func TestFilterVisitor(t *testing.T) {
+	evts := []*Event{
+		{Type: EvGoStart, G: 1, Ts: 10},
+		{Type: EvGoBlock, G: 1, Ts: 20},
+		{Type: EvGoStart, G: 2, Ts: 30},
+		{Type: EvGoSched, G: 3, Ts: 40},
+		{Type: EvNone, G: 2, Ts: 50},
+	}
+	tests := []struct {
+		pred func(evt *Event) bool
+		exp  []int64
+	}{
+		{ByType(EvGoStart, EvGoSched), []int64{10, 30, 40}},
+		{ByType(EvNone, EvCount), nil},
+		{ByType(), nil},
+		{ByTime(20, 40), []int64{20, 30}},
+		{ByTime(30, 0), []int64{30, 40, 50}},
+		{ByGoroutine(2, 3), []int64{30, 40, 50}},
+		{ByGoroutine(), nil},
+	}
+	for idx, test := range tests {
+		var got []int64
+		v := FilterVisitor(test.pred, VisitorFunc(func(evt *Event) error {
+			got = append(got, evt.Ts)
+			return nil
+		}))
+		for _, evt := range evts {
+			if err := v.Visit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Fatalf(`test #%v: exp %v; got %v`, idx, test.exp, got)
+		}
+	}
+}
+
+func TestStatsVisitor(t *testing.T) {
+	if _, err := NewStatsVisitor(Version(0)); err == nil {
+		t.Fatal(`exp error for invalid version`)
+	}
+	sv, err := NewStatsVisitor(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evts := []*Event{
+		{Type: EvFrequency, Args: []uint64{100}},
+		{Type: EvString, Args: []uint64{1}, Data: []byte(`hello`)},
+		{Type: EvString, Args: []uint64{2}, Span: Span{Off: 10, Len: 5}},
+		{Type: EvHeapAlloc, Args: []uint64{1, 1024}, Ts: 100},
+		{Type: EvGoStart, Args: []uint64{1, 2}, Ts: 200},
+		{Type: EvHeapAlloc, Args: []uint64{2, 3000}, Ts: 300},
+		{Type: EvNone, Ts: 400},
+	}
+	var bytes int
+	for _, evt := range evts {
+		if err := sv.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+		if b, err := evt.MarshalBinary(); err == nil {
+			bytes += len(b)
+		}
+	}
+	bytes += 5
+
+	r := sv.Report()
+	if r.Events != 6 || r.Bytes != bytes {
+		t.Fatalf(`exp 6 events of %v bytes; got %v of %v`, bytes, r.Events, r.Bytes)
+	}
+	if r.Duration != 200 || r.Frequency != 100 || r.EventsPerSecond != 3 {
+		t.Fatalf(`exp 3 events per second over 200 ticks; got %v over %v`,
+			r.EventsPerSecond, r.Duration)
+	}
+	if r.Interarrival.Count != 2 || r.Interarrival.Buckets[7] != 2 ||
+		r.Interarrival.Mean() != 100 {
+		t.Fatalf(`exp two inter-arrivals of 100 ticks; got %v`, r.Interarrival)
+	}
+	str, err := evts[1].MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Types[`String`]; got.Events != 2 || got.Bytes != 2*len(str) ||
+		got.Interarrival.Count != 0 {
+		t.Fatalf(`exp lazy strings to be sized by their span; got %v`, got)
+	}
+
+	heap := r.Types[`HeapAlloc`]
+	if heap.Events != 2 || heap.Interarrival.Min != 200 {
+		t.Fatalf(`exp 2 heap events 200 ticks apart; got %v`, heap)
+	}
+	exp := Histogram{Count: 2, Sum: 4024, Min: 1024, Max: 3000,
+		Buckets: []int{11: 1, 12: 1}}
+	if got := heap.Args[ArgHeapAlloc]; !reflect.DeepEqual(got, exp) {
+		t.Fatalf(`exp %v; got %v`, exp, got)
+	}
+	if _, ok := r.Types[`GoStart`].Args[ArgGoroutineID]; ok {
+		t.Fatal(`exp identifiers to be excluded from argument histograms`)
+	}
+
+	sv.Visit(&Event{Type: EvHeapAlloc, Args: []uint64{3, 1}, Ts: 500})
+	if r.Events != 6 || r.Types[`HeapAlloc`].Args[ArgHeapAlloc].Count != 2 {
+		t.Fatal(`exp report to be unaffected by later visits`)
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got StatsReport
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, r) {
+		t.Fatalf(`exp report to round trip through JSON; got %s`, b)
+	}
+}
+
+func TestValidator(t *testing.T) {
+	if _, err := NewValidator(Version(0)); err == nil {
+		t.Fatal(`exp error for invalid version`)
+	}
+	ev := func(typ Type, p int64, args ...uint64) *Event {
+		return &Event{Type: typ, P: p, Args: append([]uint64{0}, args...)}
+	}
+
+	// g 1 runs on P 0 while g 2 is waiting and g 3 is in a syscall.
+	setup := []*Event{
+		ev(EvProcStart, 0, 1),
+		ev(EvGoCreate, 0, 1, 0, 0),
+		ev(EvGoCreate, 0, 2, 0, 0),
+		ev(EvGoCreate, 0, 3, 0, 0),
+		ev(EvGoWaiting, 0, 2),
+		ev(EvGoInSyscall, 0, 3),
+		ev(EvGoStart, 0, 1, 0),
+	}
+
+	t.Run(`Valid`, func(t *testing.T) {
+		v, err := NewValidator(Latest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		evts := append(setup[:len(setup):len(setup)],
+			ev(EvGoSysCall, 0, 0),
+			ev(EvGoSysBlock, 0),
+			ev(EvGoUnblock, -1, 2, 0, 0),
+			ev(EvGoSysExit, 0, 1, 0, 0),
+			ev(EvGoSysExit, 0, 3, 0, 0),
+			ev(EvGoStart, 0, 2, 0),
+			ev(EvGCStart, 0, 0, 0),
+			ev(EvGoBlock, 0, 0),
+			ev(EvGCDone, -1),
+			ev(EvGoStartLocal, 0, 1),
+			ev(EvGoSched, 0, 0),
+			ev(EvGoStart, 0, 1, 0),
+			ev(EvGoEnd, 0),
+			ev(EvGoCreate, 0, 1, 0, 0),
+			ev(EvProcStop, 0),
+		)
+		for i, evt := range evts {
+			if err := v.Visit(evt); err != nil {
+				t.Fatalf(`exp nil err for event #%v; got %v`, i, err)
+			}
+		}
+	})
+
+	tests := []struct {
+		evt *Event
+		msg string
+	}{
+		{ev(EvProcStart, 0, 1), `P 0 is already running`},
+		{ev(EvProcStop, 1), `P 1 is not running`},
+		{ev(EvProcStop, 0), `P 0 stopped while running g 1`},
+		{ev(EvGCDone, -1), `GC is not running`},
+		{ev(EvGCSTWDone, 0), `the world is not stopped`},
+		{ev(EvGoCreate, 0, 2, 0, 0), `g 2 already exists`},
+		{ev(EvGoStart, 1, 9, 0), `g 9 does not exist`},
+		{ev(EvGoStart, 1, 2, 0), `g 2 is Waiting, not Runnable`},
+		{ev(EvGoWaiting, 1, 1), `g 1 is Running, not Runnable`},
+		{ev(EvGoSched, 1, 0), `no goroutine is running on P 1`},
+		{ev(EvGoUnblock, 0, 1, 0, 0), `g 1 is Running, not Waiting`},
+		{ev(EvGoSysExit, 0, 2, 0, 0), `g 2 is not in a syscall`},
+	}
+	for idx, test := range tests {
+		v, err := NewValidator(Latest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, evt := range setup {
+			if err := v.Visit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		test.evt.Off = 0x100 + idx
+		vi, ok := v.Visit(test.evt).(*Violation)
+		if !ok {
+			t.Fatalf(`test #%v: exp *Violation %q`, idx, test.msg)
+		}
+		if vi.Msg != test.msg || vi.Off != test.evt.Off || vi.Type != test.evt.Type {
+			t.Fatalf(`test #%v: exp %q at 0x%x; got %v`, idx, test.msg, test.evt.Off, vi)
+		}
+		if !strings.Contains(vi.Error(), fmt.Sprintf(`0x%x`, vi.Off)) {
+			t.Fatalf(`test #%v: exp offset in error; got %v`, idx, vi)
+		}
+	}
+
+	// Version1 events have a leading sequence argument.
+	v, err := NewValidator(Version1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Visit(ev(EvGoStart, 0, 0, 7)); err == nil ||
+		!strings.Contains(err.Error(), `g 7 does not exist`) {
+		t.Fatalf(`exp Version1 goroutine id to be read; got %v`, err)
+	}
+
+	// Each P stops the world prior to Version5, afterwards it is global.
+	stw := []*Event{
+		ev(EvGCSTWStart, 0), ev(EvGCSTWStart, 1), ev(EvGCSTWDone, 1), ev(EvGCSTWDone, 0)}
+	for _, ver := range []Version{Version4, Version5} {
+		v, err := NewValidator(ver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var violations int
+		for _, evt := range stw {
+			if v.Visit(evt) != nil {
+				violations++
+			}
+		}
+		if exp := map[Version]int{Version4: 0, Version5: 2}[ver]; violations != exp {
+			t.Fatalf(`exp %v violations for %v; got %v`, exp, ver, violations)
+		}
+	}
+}
+
+func TestTraceDuration(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start, end := tr.TimeBounds(); start != 0 || end != 0 || tr.Duration() != 0 {
+		t.Fatalf(`exp zero bounds before visiting; got [%v, %v]`, start, end)
+	}
+	if got := tr.TicksToDuration(100); got != 0 {
+		t.Fatalf(`exp zero duration without a frequency; got %v`, got)
+	}
+
+	evts := []*Event{
+		{Type: EvBatch, Args: []uint64{0, 500}, Ts: 500},
+		{Type: EvGoSched, Args: []uint64{0, 0}, Ts: 700},
+		{Type: EvString, Args: []uint64{1}, Data: []byte(`main`)},
+		{Type: EvBatch, Args: []uint64{1, 400}, Ts: 400},
+		{Type: EvGoSched, Args: []uint64{0, 0}, Ts: 2500},
+		{Type: EvFrequency, Args: []uint64{1000}},
+	}
+	for _, evt := range evts {
+		if err := tr.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if start, end := tr.TimeBounds(); start != 400 || end != 2500 {
+		t.Fatalf(`exp bounds [400, 2500]; got [%v, %v]`, start, end)
+	}
+	if got, exp := tr.Duration(), 2100*time.Millisecond; got != exp {
+		t.Fatalf(`exp duration %v; got %v`, exp, got)
+	}
+	if got, exp := tr.TicksToDuration(1), time.Millisecond; got != exp {
+		t.Fatalf(`exp %v per tick; got %v`, exp, got)
+	}
+
+	// Large deltas must not overflow.
+	tr.Frequency = 3e9
+	if got, exp := tr.TicksToDuration(1e13), 3333*time.Second+time.Second/3; got != exp {
+		t.Fatalf(`exp %v; got %v`, exp, got)
+	}
+
+	b, err := tr.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := new(Trace)
+	if err := restored.RestoreSnapshot(b); err != nil {
+		t.Fatal(err)
+	}
+	if start, end := restored.TimeBounds(); start != 400 || end != 2500 {
+		t.Fatalf(`exp bounds to be restored; got [%v, %v]`, start, end)
+	}
+	if tr.Reset(); tr.Duration() != 0 {
+		t.Fatal(`exp Reset to clear bounds`)
+	}
+}
+
+func TestTraceInitialGoroutines(t *testing.T) {
+	for _, v := range []Version{Version1, Latest} {
+		tr, err := NewTrace(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ev := func(typ Type, g int64, args ...uint64) *Event {
+			if v == Version1 {
+				args = append([]uint64{0}, args...)
+			}
+			return &Event{Type: typ, G: g, Ts: 10, Args: args}
+		}
+		evts := []*Event{
+			ev(EvGoCreate, 0, 0, 3, 0x30, 1),
+			ev(EvGoCreate, 0, 0, 1, 0x10, 1),
+			ev(EvGoCreate, 0, 0, 2, 0x20, 1),
+			ev(EvGoWaiting, 0, 0, 2),
+			ev(EvGoInSyscall, 0, 0, 3),
+			{Type: EvTimerGoroutine, Ts: 10, Args: []uint64{4}},
+			ev(EvGoCreate, 1, 0, 5, 0x50, 2),
+		}
+		if v == Version1 {
+			// The goroutine is followed by an unused argument.
+			evts[5].Args = append(evts[5].Args, 0)
+		}
+		for _, evt := range evts {
+			if err := tr.Visit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		exp := []GDesc{
+			{ID: 1, CreateTime: 10, Initial: GRunnable, CreateStackID: 1,
+				StartStackID: 0x10},
+			{ID: 2, CreateTime: 10, Initial: GWaiting, CreateStackID: 1,
+				StartStackID: 0x20},
+			{ID: 3, CreateTime: 10, Initial: GWaiting, InitialSyscall: true,
+				CreateStackID: 1, StartStackID: 0x30},
+			{ID: 4, CreateTime: 10, Initial: GRunnable, Timer: true},
+		}
+		if v == Version1 {
+			for i := range exp {
+				exp[i].PC, exp[i].StartStackID = exp[i].StartStackID, 0
+			}
+		}
+		got := tr.InitialGoroutines()
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf(`%v: exp %+v; got %+v`, v, exp, got)
+		}
+
+		b, err := tr.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored := new(Trace)
+		if err := restored.RestoreSnapshot(b); err != nil {
+			t.Fatal(err)
+		}
+		if got := restored.InitialGoroutines(); !reflect.DeepEqual(got, exp) {
+			t.Fatalf(`%v: exp restored %+v; got %+v`, v, exp, got)
+		}
+		if tr.Reset(); len(tr.InitialGoroutines()) != 0 {
+			t.Fatal(`exp Reset to clear initial goroutines`)
+		}
+	}
+}
+
+func TestTraceGCSTWKind(t *testing.T) {
+	evts := func(kinds ...uint64) []*Event {
+		out := []*Event{{Type: EvGCStart, Ts: 10, Args: []uint64{0, 1, 0}}}
+		for i, kind := range kinds {
+			ts := int64(20 + i*10)
+			out = append(out,
+				&Event{Type: EvGCSTWStart, Ts: ts, Args: []uint64{0, kind}},
+				&Event{Type: EvGCSTWDone, Ts: ts + 5, Args: []uint64{0}})
+		}
+		return append(out, &Event{Type: EvGCDone, Ts: 100, Args: []uint64{0}})
+	}
+
+	tr, err := NewTrace(Version6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pauses := tr.GC(evts(1, 0, 7)).Cycles[0].Pauses
+	for i, exp := range []STWKind{STWSweepTermination, STWMarkTermination, STWUnknown} {
+		if got := pauses[i].Kind; got != exp {
+			t.Fatalf(`exp pause #%v kind %v; got %v`, i, exp, got)
+		}
+	}
+
+	// The kind argument is not emitted prior to Go 1.10.
+	tr, err = NewTrace(Version4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tr.GC(evts(1)).Cycles[0].Pauses[0].Kind; got != STWUnknown {
+		t.Fatalf(`exp unknown kind for %v; got %v`, tr.Version, got)
+	}
+	if args, ok := MustNew(EvGCSTWStart, 10, 1).GCSTWStart(Version5); !ok ||
+		args.Kind != 1 {
+		t.Fatalf(`exp kind 1 in the layout of Version5; got %+v`, args)
+	}
+	stw := &Event{Type: EvGCSTWStart, Args: []uint64{10}}
+	if args, ok := stw.GCSTWStart(Version4); !ok || args.Timestamp != 10 || args.Kind != 0 {
+		t.Fatalf(`exp no kind in the layout of Version4; got %+v`, args)
+	}
+	if _, ok := stw.GCSTWStart(Version5); ok {
+		t.Fatal(`exp too few arguments in the layout of Version5`)
+	}
+	if got := STWKind(9).String(); got != `STWKind(9)` {
+		t.Fatalf(`exp STWKind(9); got %v`, got)
+	}
+}
+
+func TestTraceGCAnomalies(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	on := func(evt *Event, p, g, ts int64) *Event {
+		evt.P, evt.G, evt.Ts = p, g, ts
+		return evt
+	}
+	evts := []*Event{
+		on(MustNew(EvHeapAlloc, 0, 100), 0, 0, 1),
+		on(MustNew(EvGCStart, 0, 1, 3), 0, 0, 10),
+		on(MustNew(EvGCSTWStart, 0, 0), 0, 0, 10),
+		on(MustNew(EvGCSTWDone, 0), 0, 0, 12),
+		on(MustNew(EvGCMarkAssistStart, 0, 4), 1, 5, 12),
+		on(MustNew(EvGCMarkAssistStart, 0, 5), 2, 6, 12),
+		on(MustNew(EvGCMarkAssistDone, 0), 1, 5, 14),
+		on(MustNew(EvGCMarkAssistDone, 0), 2, 6, 20),
+		on(MustNew(EvGCSTWStart, 0, 0), 0, 0, 20),
+		on(MustNew(EvGCSTWDone, 0), 0, 0, 26),
+		on(MustNew(EvGCDone, 0), 0, 0, 30),
+		on(MustNew(EvHeapAlloc, 0, 50), 0, 0, 31),
+		on(MustNew(EvHeapAlloc, 0, 200), 0, 0, 40),
+		on(MustNew(EvGCStart, 0, 2, 0), 0, 0, 50),
+		on(MustNew(EvGCDone, 0), 0, 0, 60),
+	}
+
+	if got := tr.GCAnomalies(evts, GCThresholds{}); len(got) != 0 {
+		t.Fatalf(`exp no anomalies without thresholds; got %v`, got)
+	}
+	got := tr.GCAnomalies(evts, GCThresholds{
+		MaxPause: 4, MaxAssistRatio: 0.25, MaxHeapGrowth: 3})
+	exp := []GCAnomaly{
+		{Kind: GCAssistRatio, Ts: 10, Seq: 1, Value: 0.5, Limit: 0.25,
+			StackIDs: []uint64{5, 4}},
+		{Kind: GCLongPause, Ts: 20, Seq: 1, Value: 6, Limit: 4,
+			StackIDs: []uint64{3}},
+		{Kind: GCHeapGrowth, Ts: 50, Seq: 2, Value: 4, Limit: 3},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("exp:\n  %+v\ngot:\n  %+v", exp, got)
+	}
+	if s := got[1].String(); s != `LongPause in GC 1 at 20: 6 exceeds 4` {
+		t.Fatalf(`exp LongPause description; got %v`, s)
+	}
+	if s := GCAnomalyKind(9).String(); s != `GCAnomalyKind(9)` {
+		t.Fatalf(`exp GCAnomalyKind(9); got %v`, s)
+	}
+
+	// Version1 events lead with a sequence delta and have no GC sequence.
+	tr, err = NewTrace(Version1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evts = []*Event{
+		on(&Event{Type: EvGCStart, Args: []uint64{1, 0, 3}}, 0, 0, 10),
+		on(&Event{Type: EvGCSTWStart, Args: []uint64{1, 0}}, 0, 0, 20),
+		on(&Event{Type: EvGCSTWDone, Args: []uint64{1, 0}}, 0, 0, 26),
+		on(&Event{Type: EvGCDone, Args: []uint64{1, 0}}, 0, 0, 30),
+	}
+	got = tr.GCAnomalies(evts, GCThresholds{MaxPause: 4})
+	if len(got) != 1 || !reflect.DeepEqual(got[0].StackIDs, []uint64{3}) {
+		t.Fatalf(`exp a LongPause from stack 3; got %+v`, got)
+	}
+}
+
+func TestTraceSchedLatencies(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	on := func(evt *Event, p, g, ts int64) *Event {
+		evt.P, evt.G, evt.Ts = p, g, ts
+		return evt
+	}
+	evts := []*Event{
+		on(MustNew(EvGomaxprocs, 0, 2, 0), 0, 0, 1),
+		on(MustNew(EvGoStart, 0, 1, 1), 0, 1, 2),
+		on(MustNew(EvGoStart, 0, 2, 1), 1, 2, 2),
+		on(MustNew(EvGoCreate, 0, 3, 7, 8), 0, 1, 5),
+		on(MustNew(EvGoUnblock, 0, 4, 2, 9), 1, 2, 6),
+		on(MustNew(EvGoSysCall, 0, 10), 1, 2, 7),
+		on(MustNew(EvGoSysBlock, 0), 1, 2, 30),
+		on(MustNew(EvGoStart, 0, 4, 3), 1, 4, 31),
+		on(MustNew(EvGoSched, 0, 0), 0, 1, 40),
+		on(MustNew(EvGoStart, 0, 3, 1), 0, 3, 41),
+		on(MustNew(EvGoUnblock, 0, 5, 2, 0), 0, 3, 42),
+		on(MustNew(EvGoStart, 0, 5, 3), 1, 5, 43),
+	}
+
+	got := tr.SchedLatencies(evts, 10)
+	exp := []SchedLatency{
+		{G: 3, Type: EvGoCreate, StackID: 8, Ts: 5, Latency: 36, WakeP: 0,
+			StartP: 0, Gomaxprocs: 2, Running: 2, Syscalls: 1},
+		{G: 4, Type: EvGoUnblock, StackID: 9, Ts: 6, Latency: 25, WakeP: 1,
+			StartP: 1, Gomaxprocs: 2, Running: 2, Syscalls: 1},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("exp:\n  %+v\ngot:\n  %+v", exp, got)
+	}
+	if n := len(tr.SchedLatencies(evts, 0)); n != 3 {
+		t.Fatalf(`exp 3 latencies without a threshold; got %v`, n)
+	}
+}
+
+func TestTraceLeaks(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	on := func(evt *Event, g, ts int64) *Event {
+		evt.G, evt.Ts = g, ts
+		return evt
+	}
+	evts := []*Event{
+		on(MustNew(EvGoCreate, 0, 6, 0, 9), 0, 0),
+		on(MustNew(EvGoCreate, 0, 2, 0, 1), 1, 1),
+		on(MustNew(EvGoCreate, 0, 3, 0, 1), 1, 2),
+		on(MustNew(EvGoCreate, 0, 4, 0, 5), 1, 3),
+		on(MustNew(EvGoCreate, 0, 5, 0, 5), 1, 4),
+		on(MustNew(EvGoCreate, 0, 7, 0, 1), 1, 4),
+		on(MustNew(EvGoStart, 0, 6, 1), 6, 5),
+		on(MustNew(EvGoBlockSelect, 0, 0), 6, 6),
+		on(MustNew(EvGoStart, 0, 7, 1), 7, 7),
+		on(MustNew(EvGoStop, 0, 0), 7, 8),
+		on(MustNew(EvGoStart, 0, 3, 1), 3, 10),
+		on(MustNew(EvGoBlockRecv, 0, 0), 3, 11),
+		on(MustNew(EvGoStart, 0, 2, 1), 2, 12),
+		on(MustNew(EvGoBlockSend, 0, 0), 2, 13),
+		on(MustNew(EvGoStart, 0, 4, 1), 4, 14),
+		on(MustNew(EvGoEnd, 0), 4, 15),
+		on(MustNew(EvGoStart, 0, 5, 1), 5, 16),
+		on(MustNew(EvGoSysBlock, 0), 5, 17),
+		on(MustNew(EvProcStop, 0), 0, 31),
+	}
+
+	for _, evt := range evts {
+		tr.Visit(evt)
+	}
+
+	// Goroutine 6 existed before the trace, 4 ended, 5 is in a syscall and 7
+	// stopped forever.
+	got := tr.Leaks(evts, 0)
+	exp := []Leak{{
+		CreateStackID: 1, Count: 3, Goroutines: []uint64{2, 3, 7},
+		Reasons: map[Type]int{
+			EvGoBlockSend: 1, EvGoBlockRecv: 1, EvGoStop: 1}, Oldest: 23}}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("exp:\n  %+v\ngot:\n  %+v", exp, got)
+	}
+	if got := tr.Leaks(evts, 20); len(got) != 1 ||
+		!reflect.DeepEqual(got[0].Goroutines, []uint64{3, 7}) {
+		t.Fatalf(`exp goroutines 3 and 7 blocked for 20; got %+v`, got)
+	}
+	if got := tr.Leaks(evts, 24); len(got) != 0 {
+		t.Fatalf(`exp no leaks blocked for 24; got %+v`, got)
+	}
+}
+
+func TestTraceContentions(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	on := func(evt *Event, g, ts int64) *Event {
+		evt.G, evt.Ts = g, ts
+		return evt
+	}
+	evts := []*Event{
+		on(MustNew(EvGoWaiting, 0, 9), 0, 1),
+		on(MustNew(EvGoBlockSync, 0, 4), 2, 2),
+		on(MustNew(EvGoBlockSync, 0, 4), 3, 3),
+		on(MustNew(EvGoBlockRecv, 0, 5), 6, 4),
+		on(MustNew(EvGoBlockRecv, 0, 6), 7, 5),
+		on(MustNew(EvGoBlockNet, 0, 7), 8, 6),
+		on(MustNew(EvGoUnblockLocal, 0, 7, 0), 1, 7),
+		on(MustNew(EvGoStop, 0, 8), 10, 8),
+		on(MustNew(EvGoUnblock, 0, 2, 2, 0), 1, 20),
+		on(MustNew(EvGoStart, 0, 3, 2), 3, 30),
+		on(MustNew(EvGoBlockSync, 0, 4), 3, 31),
+		on(MustNew(EvGoUnblock, 0, 3, 4, 0), 1, 33),
+		on(MustNew(EvProcStop, 0), 0, 50),
+	}
+
+	// Goroutine 7 and the second wait of 3 are under the threshold, 8 waited
+	// on the network and 9 was blocked before the trace began.
+	got := tr.Contentions(evts, 10)
+	exp := []Contention{
+		{StackID: 5, Type: EvGoBlockRecv, Count: 1, Time: 46, Max: 46,
+			Goroutines: []uint64{6}, Forever: 1},
+		{StackID: 8, Type: EvGoStop, Count: 1, Time: 42, Max: 42,
+			Goroutines: []uint64{10}, Forever: 1},
+		{StackID: 4, Type: EvGoBlockSync, Count: 2, Time: 45, Max: 27,
+			Goroutines: []uint64{2, 3}},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("exp:\n  %+v\ngot:\n  %+v", exp, got)
+	}
+	if !got[0].Deadlocked() || !got[1].Deadlocked() || got[2].Deadlocked() {
+		t.Fatalf(`exp the blocked recv and stop to be deadlocked; got %+v`, got)
+	}
+	if got := tr.Contentions(evts, 0); len(got) != 4 || got[2].Count != 3 {
+		t.Fatalf(`exp every sync and recv wait without a threshold; got %+v`, got)
+	}
+}
+
+func TestEventDump(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Source = strings.NewReader(`xxxxabc`)
+	for _, evt := range []*Event{
+		{Type: EvString, Args: []uint64{1}, Data: []byte(`main.main`)},
+		{Type: EvString, Args: []uint64{2}, Data: []byte(`main.go`)},
+		{Type: EvString, Args: []uint64{3}, Data: []byte(`region`)},
+		{Type: EvStack, Args: []uint64{7, 1, 0x40, 1, 2, 12}},
+	} {
+		if err := tr.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evt := &Event{Type: EvUserRegion, Off: 0x20, Ts: 5, P: 1, G: 2,
+		Args: []uint64{5, 0, 0, 3, 7}}
+	got := evt.Dump(tr)
+	for _, exp := range []string{
+		"UserRegion at 0x20 (ts 5, p 1, g 2)\n",
+		"  Timestamp        = 5 ticks (Timestamp)\n",
+		"  NameStringID     = 3 (String)\n    \"region\"\n",
+		"  StackID          = 7 (Stack)\n    main.main(...)\n    \tmain.go:12 pc=0x40\n",
+		"  00000000  ",
+	} {
+		if !strings.Contains(got, exp) {
+			t.Fatalf("exp dump to contain %q; got:\n%v", exp, got)
+		}
+	}
+
+	// Without a Trace references are not resolved.
+	if got := evt.Dump(nil); strings.Contains(got, `main.main`) {
+		t.Fatalf("exp unresolved stack without a Trace; got:\n%v", got)
+	}
+
+	// Lazily decoded strings are read from the Source of the Trace.
+	evt = &Event{Type: EvString, Args: []uint64{4}, Span: Span{Off: 4, Len: 3}}
+	if got := evt.Dump(tr); !strings.Contains(got, "span             = [0x4, 0x7)\n    \"abc\"\n") {
+		t.Fatalf("exp resolved span; got:\n%v", got)
+	}
+
+	// Malformed events still describe what is known.
+	evt = &Event{Type: EvGoCreate}
+	if got := evt.Dump(tr); !strings.Contains(got, `encoding error`) {
+		t.Fatalf("exp encoding error; got:\n%v", got)
+	}
+}
+
+func TestStackFormat(t *testing.T) {
+	stk := Stack{
+		NewFrame(0x10, `runtime.gopark`, `proc.go`, 300),
+		NewFrame(0x20, `main.worker`, `main.go`, 12),
+		NewFrame(0x30, `main.main`, `main.go`, 7),
+		NewFrame(0x40, `runtime.goexit`, `asm.s`, 1),
+	}
+
+	tests := []struct {
+		opts StackFormat
+		exp  string
+	}{
+		{StackFormat{},
+			`runtime.gopark:300 < main.worker:12 < main.main:7 < runtime.goexit:1`},
+		{StackFormat{TrimRuntime: true}, `main.worker:12 < main.main:7`},
+		{StackFormat{Depth: 1, TrimRuntime: true}, `main.worker:12`},
+		{StackFormat{Style: StackLong, Depth: 2},
+			"runtime.gopark(...)\n\tproc.go:300 pc=0x10\n" +
+				"main.worker(...)\n\tmain.go:12 pc=0x20\n"},
+		{StackFormat{Style: StackJSON, Depth: 1},
+			`[{"pc":16,"func":"runtime.gopark","file":"proc.go","line":300}]`},
+	}
+	for _, test := range tests {
+		if got := stk.Format(test.opts); got != test.exp {
+			t.Fatalf("exp %+v to format as:\n%v\ngot:\n%v", test.opts, test.exp, got)
+		}
+	}
+
+	if got := (Stack{}).Format(StackFormat{Style: StackJSON}); got != `[]` {
+		t.Fatalf(`exp [] for empty stack; got %v`, got)
+	}
+	if got := stk.Top(10); len(got) != len(stk) {
+		t.Fatalf(`exp Top beyond length to return all frames; got %v`, len(got))
+	}
+	if got := stk.Top(-1); len(got) != 0 {
+		t.Fatalf(`exp Top of negative n to be empty; got %v`, len(got))
+	}
+	if stk.TrimRuntime(); stk[0].Func() != `runtime.gopark` {
+		t.Fatal(`exp TrimRuntime to not modify the stack`)
+	}
+}
+
+func TestTraceGoroutineQuery(t *testing.T) {
+	for _, v := range []Version{Version1, Latest} {
+		tr, err := NewTrace(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ev := func(typ Type, g, ts int64, args ...uint64) *Event {
+			if v == Version1 {
+				args = append([]uint64{0}, args...)
+			}
+			return &Event{Type: typ, G: g, Ts: ts, Args: args}
+		}
+		evts := []*Event{
+			ev(EvGoCreate, 0, 10, 0, 1, 0x10, 0),
+			ev(EvGoCreate, 1, 20, 0, 5, 0x50, 8),
+			ev(EvGoEnd, 5, 40, 0),
+		}
+		if v != Version1 {
+			evts = append(evts,
+				&Event{Type: EvString, Args: []uint64{3}, Data: []byte(`GC (idle)`)},
+				ev(EvGoStartLabel, 1, 30, 0, 1, 0, 3),
+				&Event{Type: EvString, Args: []uint64{1}, Data: []byte(`main.worker`)},
+				&Event{Type: EvStack, Args: []uint64{0x50, 1, 0x51, 1, 0, 4}})
+		}
+		for _, evt := range evts {
+			if err := tr.Visit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if exp, got := []GoroutineID{1, 5}, tr.GoroutineIDs(); !reflect.DeepEqual(got, exp) {
+			t.Fatalf(`%v: exp ids %v; got %v`, v, exp, got)
+		}
+		if _, ok := tr.Goroutine(2); ok {
+			t.Fatalf(`%v: exp unknown goroutine to not be found`, v)
+		}
+		g, ok := tr.Goroutine(5)
+		if !ok {
+			t.Fatalf(`%v: exp goroutine 5 to be found`, v)
+		}
+		if g.ID != 5 || g.Parent != 1 || g.CreateStackID != 8 ||
+			g.CreateTime != 20 || g.EndTime != 40 {
+			t.Fatalf(`%v: exp goroutine 5 created by 1; got %+v`, v, g)
+		}
+		if v == Version1 {
+			if g.PC != 0x50 || g.StartStackID != 0 {
+				t.Fatalf(`%v: exp PC in place of a start stack; got %+v`, v, g)
+			}
+			continue
+		}
+		if g.StartStackID != 0x50 || g.Name != `main.worker` || g.PC != 0x51 {
+			t.Fatalf(`%v: exp start stack resolved; got %+v`, v, g)
+		}
+		if g, _ := tr.Goroutine(1); g.Label != `GC (idle)` || g.Parent != 0 || g.EndTime != 0 {
+			t.Fatalf(`%v: exp labeled initial goroutine; got %+v`, v, g)
+		}
+
+		b, err := tr.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored := new(Trace)
+		if err := restored.RestoreSnapshot(b); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := restored.Goroutine(5); got.Name != g.Name || got.EndTime != g.EndTime {
+			t.Fatalf(`%v: exp restored %+v; got %+v`, v, g, got)
+		}
+		if tr.Reset(); len(tr.GoroutineIDs()) != 0 {
+			t.Fatal(`exp Reset to clear goroutines`)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	mk := func() List {
+		return List{
+			{Type: EvGoStart, Ts: 30, Off: 3, G: 2, Args: []uint64{30, 2, 0}},
+			{Type: EvGoCreate, Ts: 10, Off: 1, G: 1, Args: []uint64{10, 2, 0, 0}},
+			{Type: EvGoStart, Ts: 10, Off: 2, G: 1, Args: []uint64{10, 1, 0}},
+			{Type: EvGoEnd, Ts: 40, Off: 4, G: 2, Args: []uint64{40}},
+		}
+	}
+	offs := func(l List) (out []int) {
+		for _, evt := range l {
+			out = append(out, evt.Off)
+		}
+		return
+	}
+
+	l := mk()
+	l.SortByTime()
+	if exp, got := []int{1, 2, 3, 4}, offs(l); !reflect.DeepEqual(got, exp) {
+		t.Fatalf(`exp sorted offsets %v; got %v`, exp, got)
+	}
+	if exp, got := []int{3, 4}, offs(l.Filter(ByGoroutine(2))); !reflect.DeepEqual(got, exp) {
+		t.Fatalf(`exp filtered offsets %v; got %v`, exp, got)
+	}
+	if exp, got := []int{1, 2, 3}, offs(l.Between(10, 40)); !reflect.DeepEqual(got, exp) {
+		t.Fatalf(`exp offsets between %v; got %v`, exp, got)
+	}
+
+	groups := l.GroupBy()
+	if len(groups) != 3 || len(groups[EvGoStart]) != 2 || groups[EvGoStart][0].Off != 2 {
+		t.Fatalf(`exp 3 groups in order; got %v`, groups)
+	}
+	if groups = l.GroupBy(EvGoEnd); len(groups) != 1 || len(groups[EvGoEnd]) != 1 {
+		t.Fatalf(`exp only GoEnd group; got %v`, groups)
+	}
+
+	size := List(l[:2]).MemoryUsage()
+	if exp := 2*eventBytes + 7*8; size != exp {
+		t.Fatalf(`exp memory usage %v; got %v`, exp, size)
+	}
+	if got := l.Truncate(size); len(got) != 2 || l[2] != nil || l[3] != nil {
+		t.Fatalf(`exp truncation to 2 events clearing the rest; got %v`, got)
+	}
+	if got := mk().Truncate(1 << 20); len(got) != 4 {
+		t.Fatalf(`exp all events within limit; got %v`, len(got))
+	}
+}
+
+func TestTraceResolve(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wall := time.Date(2018, 8, 24, 0, 0, 0, 0, time.UTC)
+	tr.Anchor = Anchor{Time: wall, Ticks: 100}
+	tr.Source = strings.NewReader(`xxxxvalue`)
+	for _, evt := range []*Event{
+		{Type: EvFrequency, Args: []uint64{1e9}},
+		{Type: EvString, Args: []uint64{1}, Data: []byte(`main.main`)},
+		{Type: EvString, Args: []uint64{2}, Data: []byte(`main.go`)},
+		{Type: EvString, Args: []uint64{3}, Data: []byte(`key`)},
+		{Type: EvStack, Args: []uint64{7, 1, 0x40, 1, 2, 12}},
+		{Type: EvStack, Args: []uint64{8, 1, 0x80, 1, 2, 24}},
+	} {
+		if err := tr.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evt := &Event{Type: EvUserLog, Ts: 150, Args: []uint64{150, 1, 3, 7},
+		Span: Span{Off: 4, Len: 5}}
+	re := tr.Resolve(evt)
+	if re.Event != evt || !re.Time.Equal(wall.Add(50)) {
+		t.Fatalf(`exp event at %v; got %+v`, wall.Add(50), re)
+	}
+	if re.Strings[ArgKeyStringID] != `key` || re.Value != `value` {
+		t.Fatalf(`exp key and value resolved; got %+v`, re)
+	}
+	if len(re.Stack) != 1 || re.Stack[0].Func() != `main.main` || re.NewStack != nil {
+		t.Fatalf(`exp stack resolved; got %+v`, re)
+	}
+
+	re = tr.Resolve(&Event{Type: EvGoCreate, Args: []uint64{0, 2, 8, 7}})
+	if !re.Time.IsZero() || re.Strings != nil {
+		t.Fatalf(`exp no time or strings; got %+v`, re)
+	}
+	if len(re.NewStack) != 1 || re.NewStack[0].Line() != 24 || len(re.Stack) != 1 {
+		t.Fatalf(`exp new stack resolved; got %+v`, re)
+	}
+
+	// Version1 recorded a program counter in place of the new stack.
+	tr.Version = Version1
+	re = tr.Resolve(&Event{Type: EvGoCreate, Args: []uint64{0, 0, 2, 8, 7}})
+	if re.NewStack != nil || len(re.Stack) != 1 {
+		t.Fatalf(`exp only stack resolved for Version1; got %+v`, re)
+	}
+}
+
+func TestTraceWarnings(t *testing.T) {
+	evts := []*Event{
+		{Type: EvString, Off: 1, Args: []uint64{1}, Data: []byte(`main.main`)},
+		{Type: EvString, Off: 2, Args: []uint64{1}, Data: []byte(`other`)},
+		{Type: EvGoCreate, Off: 3, Args: []uint64{0, 2, 7, 9}},
+		{Type: EvGoStart, Off: 4, Args: []uint64{0, 2, 0}},
+		{Type: EvStack, Off: 5, Args: []uint64{7, 1, 0x40, 1, 1, 12}},
+		{Type: EvStack, Off: 6, Args: []uint64{7, 1, 0x40, 1, 1, 12}},
+	}
+
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed bool
+	for _, evt := range evts {
+		if err := tr.Visit(evt); err != nil {
+			failed = true
+		}
+	}
+	if !failed || len(tr.Warnings()) != 0 {
+		t.Fatal(`exp duplicates to fail without warnings by default`)
+	}
+
+	tr.Reset()
+	tr.Version, tr.Lenient = Latest, true
+	for _, evt := range evts {
+		if err := tr.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exp := []Warning{
+		{Off: 2, Type: EvString, Msg: `trace string already exists for id 1`},
+		{Off: 6, Type: EvStack, Msg: `trace stack already exists for id 7`},
+		{Off: 3, Type: EvGoCreate, Msg: `StackID 9 was never declared`},
+	}
+	if got := tr.Warnings(); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("exp warnings:\n%v\ngot:\n%v", exp, got)
+	}
+	if got := tr.Strings[1]; got != `main.main` {
+		t.Fatalf(`exp first string retained; got %v`, got)
+	}
+	if exp, got := `String at 0x2: trace string already exists for id 1`, exp[0].String(); got != exp {
+		t.Fatalf(`exp %v; got %v`, exp, got)
+	}
+
+	// Malformed events remain fatal.
+	if err := tr.Visit(&Event{Type: EvString, Args: []uint64{0}}); err == nil {
+		t.Fatal(`exp error for string id 0`)
+	}
+	if tr.Reset(); !tr.Lenient || len(tr.Warnings()) != 0 {
+		t.Fatal(`exp Reset to retain Lenient and clear warnings`)
+	}
+}
+
+type testHandler struct {
+	NopHandler
+	creates []GoCreateArgs
+}
+
+func (h *testHandler) HandleGoCreate(evt *Event, args GoCreateArgs) error {
+	h.creates = append(h.creates, args)
+	return nil
+}
+
+func TestDispatch(t *testing.T) {
+	h := new(testHandler)
+	for _, evt := range []*Event{
+		MustNew(EvGoCreate, 10, 2, 3, 4),
+		MustNew(EvGoStart, 20, 2, 1),
+	} {
+		if err := Dispatch(h, Latest, evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Dispatch(h, Version1, &Event{Type: EvGoCreate, Args: []uint64{1, 10, 2, 3, 4}}); err != nil {
+		t.Fatal(err)
+	}
+	exp := []GoCreateArgs{
+		{Timestamp: 10, NewGoroutineID: 2, NewStackID: 3, StackID: 4},
+		{Timestamp: 10, NewGoroutineID: 2, NewStackID: 3, StackID: 4}}
+	if !reflect.DeepEqual(h.creates, exp) {
+		t.Fatalf(`exp %v; got %v`, exp, h.creates)
+	}
+	if IdxGoCreateStackID != 3 || IdxUserLogKeyStringID != 2 {
+		t.Fatal(`exp argument indexes to follow the schema`)
+	}
+
+	if err := Dispatch(h, Latest, &Event{Type: EvGoCreate, Args: []uint64{1}}); err == nil {
+		t.Fatal(`exp error for too few arguments`)
+	}
+	if err := Dispatch(h, Version1, MustNew(EvGoCreate, 10, 2, 3, 4)); err == nil {
+		t.Fatal(`exp error for too few Version1 arguments`)
+	}
+	if err := Dispatch(h, Latest, &Event{Type: EvCount}); err == nil {
+		t.Fatal(`exp error for invalid type`)
+	}
+}
+
+func TestTraceHeapSeries(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heap := func(typ Type, ts int64, v uint64) *Event {
+		evt := MustNew(typ, 0, v)
+		evt.Ts = ts
+		return evt
+	}
+	evts := []*Event{
+		heap(EvHeapAlloc, 0, 100),
+		heap(EvNextGC, 10, 200),
+		MustNew(EvGCStart, 0, 1, 0),
+		heap(EvHeapAlloc, 20, 180),
+		heap(EvHeapAlloc, 30, 90),
+		heap(EvHeapAlloc, 60, 120),
+		heap(EvNextGC, 70, 240),
+		heap(EvHeapAlloc, 99, 130),
+	}
+
+	got := tr.HeapSeries(evts, 0)
+	if exp := tr.GC(evts).Heap; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("exp series to match GC heap samples:\n%v\ngot:\n%v", exp, got)
+	}
+	if exp := (HeapSample{Ts: 70, Alloc: 120, Goal: 240}); got[5] != exp {
+		t.Fatalf(`exp %v; got %v`, exp, got[5])
+	}
+
+	exp := []HeapSample{
+		{Ts: 20, Alloc: 180, Goal: 200},
+		{Ts: 99, Alloc: 130, Goal: 240},
+	}
+	if got := tr.HeapSeries(evts, 2); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("exp downsampled peaks:\n%v\ngot:\n%v", exp, got)
+	}
+	if got := tr.HeapSeries(evts, 100); len(got) != 7 {
+		t.Fatalf(`exp all 7 samples when points exceeds them; got %v`, len(got))
+	}
+	if got := tr.HeapSeries(nil, 2); len(got) != 0 {
+		t.Fatalf(`exp no samples; got %v`, got)
+	}
+}
+
+func TestTaskTreeStats(t *testing.T) {
+	req := &Task{ID: 1, Name: `request`, Start: 10, End: 40}
+	req.Regions = []*Region{
+		{Name: `query`, Start: 10, End: 20, Children: []*Region{
+			{Name: `scan`, Start: 12, End: 15}}},
+		{Name: `query`, Start: 15, End: 45},
+	}
+	tt := &TaskTree{
+		Tasks: map[uint64]*Task{
+			1: req,
+			2: {ID: 2, Name: `request`, Start: 50, End: 60},
+			3: {ID: 3, Name: `request`, End: 5},
+		},
+		Regions: []*Region{
+			{Name: `query`, Start: 20, End: 21},
+			{Name: `query`, Start: 44},
+		},
+	}
+
+	got := tt.RegionStats()
+	if len(got) != 2 || got[0].Name != `query` || got[1].Name != `scan` {
+		t.Fatalf(`exp query and scan stats; got %+v`, got)
+	}
+	exp := DurationStats{Name: `query`, Count: 4, Complete: 3,
+		Total: 41, Min: 1, Max: 30, P50: 10, P90: 30, P99: 30, MaxConcurrent: 2}
+	if got[0] != exp {
+		t.Fatalf("exp:\n%+v\ngot:\n%+v", exp, got[0])
+	}
+	if mean := got[0].Mean(); mean < 13.6 || mean > 13.7 {
+		t.Fatalf(`exp mean of 13.67; got %v`, mean)
+	}
+
+	// The task begun before the trace ended before any other started.
+	tasks := tt.TaskStats()
+	exp = DurationStats{Name: `request`, Count: 3, Complete: 2,
+		Total: 40, Min: 10, Max: 30, P50: 10, P90: 30, P99: 30, MaxConcurrent: 1}
+	if len(tasks) != 1 || tasks[0] != exp {
+		t.Fatalf("exp:\n%+v\ngot:\n%+v", exp, tasks)
+	}
+	if s := (DurationStats{}); s.Mean() != 0 {
+		t.Fatal(`exp zero mean without complete instances`)
+	}
+}
+
+func TestTraceIntegrity(t *testing.T) {
+	tr, err := NewTrace(Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	on := func(evt *Event, off int, p, ts int64) *Event {
+		evt.Off, evt.P, evt.Ts = off, p, ts
+		return evt
+	}
+	for _, evt := range []*Event{
+		on(MustNew(EvBatch, 0, 100), 1, 0, 100),
+		on(MustNew(EvGomaxprocs, 0, 2, 0), 2, 0, 100),
+		on(MustNew(EvGCStart, 0, 1, 0), 3, 0, 110),
+		on(MustNew(EvBatch, 1, 105), 4, 1, 105),
+		on(MustNew(EvGCStart, 0, 4, 0), 5, 1, 120),
+		on(MustNew(EvBatch, 0, 90), 6, 0, 90),
+		on(MustNew(EvBatch, 3, 130), 7, 3, 130),
+		on(MustNew(EvBatch, 0, 0), 8, -1, 0),
+		on(MustNew(EvCPUSample, 50, 0, 0, 1, 0), 9, 0, 50),
+	} {
+		if err := tr.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := tr.Integrity()
+	if r.OK() {
+		t.Fatal(`exp integrity issues`)
+	}
+	exp := []IntegrityIssue{
+		{Kind: IntegrityTimeRegression, Off: 6, Ts: 90, P: 0,
+			Msg: `timestamp 90 precedes 110 of P 0`},
+		{Kind: IntegritySequenceGap, Off: 5, Ts: 120, P: 1,
+			Msg: `GC sequence 4 does not follow 1`},
+		{Kind: IntegrityUnknownP, Off: 7, Ts: 130, P: 3,
+			Msg: `P 3 exceeds GOMAXPROCS of 2`},
+	}
+	if !reflect.DeepEqual(r.Issues, exp) {
+		t.Fatalf("exp issues:\n%+v\ngot:\n%+v", exp, r.Issues)
+	}
+	for _, is := range exp {
+		if r.Counts[is.Kind] != 1 {
+			t.Fatalf(`exp 1 issue of kind %v; got %v`, is.Kind, r.Counts)
+		}
+	}
+	if got := IntegrityKind(9).String(); got != `IntegrityKind(9)` {
+		t.Fatalf(`exp IntegrityKind(9); got %v`, got)
+	}
+
+	// Issues beyond the retained limit are only counted.
+	for i := 0; i < maxIntegrityIssues*2; i++ {
+		tr.Visit(on(MustNew(EvGCStart, 0, uint64(10+i*2), 0), 10+i, 2, 200))
+	}
+	r = tr.Integrity()
+	if len(r.Issues) != maxIntegrityIssues || r.Counts[IntegritySequenceGap] != 1+maxIntegrityIssues*2 {
+		t.Fatalf(`exp %v retained issues; got %v with counts %v`,
+			maxIntegrityIssues, len(r.Issues), r.Counts)
+	}
+	if tr.Reset(); !tr.Integrity().OK() {
+		t.Fatal(`exp Reset to clear integrity issues`)
+	}
+
+	// Cycles are compared by sequence rather than the order they were visited
+	// in, while Version1 declared no sequence to compare.
+	for _, v := range []Version{Version1, Latest} {
+		tr, err := NewTrace(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, seq := range []uint64{2, 1, 3} {
+			evt := &Event{Type: EvGCStart, Ts: 10, Args: []uint64{0, seq, 7}}
+			if v == Version1 {
+				evt.Args = []uint64{0, 0, 7}
+			}
+			if err := tr.Visit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if r := tr.Integrity(); !r.OK() {
+			t.Fatalf(`%v: exp no integrity issues; got %+v`, v, r)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package event
+
+// FilterVisitor returns a Visitor which visits only the events pred returns
+// true for with next.
+func FilterVisitor(pred func(evt *Event) bool, next Visitor) Visitor {
+	return VisitorFunc(func(evt *Event) error {
+		if !pred(evt) {
+			return nil
+		}
+		return next.Visit(evt)
+	})
+}
+
+// ByType returns a predicate for FilterVisitor which is true for events of any
+// of the given types.
+func ByType(types ...Type) func(evt *Event) bool {
+	var set [EvCount]bool
+	for _, t := range types {
+		if t.Valid() {
+			set[t] = true
+		}
+	}
+	return func(evt *Event) bool {
+		return evt.Type.Valid() && set[evt.Type]
+	}
+}
+
+// ByTime returns a predicate for FilterVisitor which is true for events with a
+// Ts field within [start, end). Both bounds are in the unit of the Ts field of
+// the events, with an end of zero leaving the range unbounded.
+func ByTime(start, end int64) func(evt *Event) bool {
+	return func(evt *Event) bool {
+		return evt.Ts >= start && (end == 0 || evt.Ts < end)
+	}
+}
+
+// ByGoroutine returns a predicate for FilterVisitor which is true for events
+// with a G field of any of the given goroutines.
+func ByGoroutine(gs ...uint64) func(evt *Event) bool {
+	set := make(map[uint64]bool, len(gs))
+	for _, g := range gs {
+		set[g] = true
+	}
+	return func(evt *Event) bool {
+		return set[uint64(evt.G)]
+	}
+}
@@ -0,0 +1,221 @@
+package event
+
+import "fmt"
+
+// GCSummary describes the garbage collections within a trace, see the GC method
+// of Trace. All times are in the unit of the Ts field of the events they were
+// derived from.
+type GCSummary struct {
+
+	// Cycles lists each garbage collection in the order they began.
+	Cycles []GCCycle
+
+	// Heap is the series of heap sizes and goals, with a sample for each
+	// EvHeapAlloc or EvNextGC event. See HeapSeries for downsampling it.
+	Heap []HeapSample
+
+	// PauseTime, AssistTime and SweepTime are the totals across the trace,
+	// including time spent outside of any cycle. MaxPause is the longest
+	// single stop the world pause.
+	PauseTime, MaxPause, AssistTime, SweepTime int64
+}
+
+// GCCycle is a single garbage collection, beginning with an EvGCStart event and
+// ending with EvGCDone.
+type GCCycle struct {
+
+	// Seq is the sequence of this cycle as declared by the runtime, it is zero
+	// for Version1 which did not declare one.
+	Seq uint64
+
+	// Start and End are the timestamps of the EvGCStart and EvGCDone events,
+	// End is zero when the trace ends before the cycle completes.
+	Start, End int64
+
+	// Pauses lists each stop the world pause during this cycle and PauseTime
+	// their total.
+	Pauses    []GCPause
+	PauseTime int64
+
+	// AssistTime is the time goroutines spent performing mark assists which
+	// began during this cycle. SweepTime is the time spent sweeping after this
+	// cycle, until the next began.
+	AssistTime, SweepTime int64
+
+	// HeapAlloc and HeapGoal are the heap size and the goal which triggered
+	// this cycle. HeapLive is the first heap size reported once the cycle
+	// ended and NextGoal the goal of the following cycle.
+	HeapAlloc, HeapGoal, HeapLive, NextGoal uint64
+}
+
+// Duration returns the time from the start to the end of the cycle, or zero if
+// the cycle did not complete.
+func (c *GCCycle) Duration() int64 {
+	if c.End == 0 {
+		return 0
+	}
+	return c.End - c.Start
+}
+
+// GCPause is a stop the world pause from EvGCSTWStart until EvGCSTWDone.
+type GCPause struct {
+	Start, End int64
+	Kind       STWKind
+}
+
+// STWKind is the phase of the garbage collector which stopped the world, as
+// declared by the kind argument of EvGCSTWStart events emitted from Go 1.10.
+type STWKind byte
+
+// Kinds of stop the world pauses, traces prior to Version5 do not declare the
+// kind of their pauses.
+const (
+	STWUnknown STWKind = iota
+	STWMarkTermination
+	STWSweepTermination
+)
+
+var stwKindNames = [...]string{
+	STWUnknown:          `Unknown`,
+	STWMarkTermination:  `MarkTermination`,
+	STWSweepTermination: `SweepTermination`,
+}
+
+// String implements fmt.Stringer by returning the name of this kind.
+func (k STWKind) String() string {
+	if int(k) < len(stwKindNames) {
+		return stwKindNames[k]
+	}
+	return fmt.Sprintf(`STWKind(%d)`, int(k))
+}
+
+// stwKind returns the STWKind of the kind argument of an EvGCSTWStart event,
+// which the runtime declares as 0 for mark and 1 for sweep termination.
+func stwKind(v Version, evt *Event) STWKind {
+	args, ok := evt.GCSTWStart(v)
+	if !ok || v < Version5 {
+		return STWUnknown
+	}
+	switch args.Kind {
+	case 0:
+		return STWMarkTermination
+	case 1:
+		return STWSweepTermination
+	}
+	return STWUnknown
+}
+
+// Duration returns the length of the pause.
+func (p GCPause) Duration() int64 {
+	return p.End - p.Start
+}
+
+// HeapSample is the heap size and goal at the time of an EvHeapAlloc or
+// EvNextGC event, carrying forward the most recent value of the other.
+type HeapSample struct {
+	Ts          int64
+	Alloc, Goal uint64
+}
+
+// GC summarizes the garbage collections referred to by evts, which must be
+// ordered as they are by an Orderer and retain the arguments in the layout of
+// the Version of this Trace.
+func (tr *Trace) GC(evts []*Event) *GCSummary {
+	var argoff int
+	if tr.Version.Valid() {
+		argoff = versions[tr.Version].argOffset
+	}
+
+	// Cycles are referred to by index as they move while appending, assists
+	// are attributed to the cycle they began in.
+	var (
+		sum          = new(GCSummary)
+		idx          = -1 // most recent cycle, which may have ended
+		live         bool // the cycle has ended and awaits a heap size
+		stw          int64
+		kind         STWKind
+		alloc, goal  uint64
+		assists      = make(map[int64]int64)
+		assistCycles = make(map[int64]int)
+		sweeps       = make(map[int64]int64)
+	)
+	cycle := func(i int) *GCCycle {
+		if i < 0 {
+			return nil
+		}
+		return &sum.Cycles[i]
+	}
+	for _, evt := range evts {
+		cur := cycle(idx)
+		switch evt.Type {
+		case EvGCStart:
+			args, _ := evt.GCStart(tr.Version)
+			sum.Cycles = append(sum.Cycles, GCCycle{
+				Seq:   args.SequenceGC,
+				Start: evt.Ts, HeapAlloc: alloc, HeapGoal: goal})
+			idx, live = len(sum.Cycles)-1, false
+		case EvGCDone:
+			if cur != nil && cur.End == 0 {
+				cur.End, live = evt.Ts, true
+			}
+		case EvGCSTWStart:
+			stw, kind = evt.Ts, stwKind(tr.Version, evt)
+		case EvGCSTWDone:
+			if stw == 0 {
+				break
+			}
+			p := GCPause{Start: stw, End: evt.Ts, Kind: kind}
+			stw = 0
+			d := p.Duration()
+			sum.PauseTime += d
+			if d > sum.MaxPause {
+				sum.MaxPause = d
+			}
+			if cur != nil && cur.End == 0 {
+				cur.Pauses = append(cur.Pauses, p)
+				cur.PauseTime += d
+			}
+		case EvGCMarkAssistStart:
+			assists[evt.G] = evt.Ts
+			assistCycles[evt.G] = idx
+		case EvGCMarkAssistDone:
+			start, ok := assists[evt.G]
+			if !ok {
+				break
+			}
+			d := evt.Ts - start
+			sum.AssistTime += d
+			if c := cycle(assistCycles[evt.G]); c != nil {
+				c.AssistTime += d
+			}
+			delete(assists, evt.G)
+			delete(assistCycles, evt.G)
+		case EvGCSweepStart:
+			sweeps[evt.P] = evt.Ts
+		case EvGCSweepDone:
+			start, ok := sweeps[evt.P]
+			if !ok {
+				break
+			}
+			d := evt.Ts - start
+			sum.SweepTime += d
+			if cur != nil {
+				cur.SweepTime += d
+			}
+			delete(sweeps, evt.P)
+		case EvHeapAlloc:
+			alloc = evt.arg(ArgHeapAlloc, argoff)
+			sum.Heap = append(sum.Heap, HeapSample{Ts: evt.Ts, Alloc: alloc, Goal: goal})
+			if live {
+				cur.HeapLive, live = alloc, false
+			}
+		case EvNextGC:
+			goal = evt.arg(ArgNextGC, argoff)
+			sum.Heap = append(sum.Heap, HeapSample{Ts: evt.Ts, Alloc: alloc, Goal: goal})
+			if cur != nil && cur.End != 0 {
+				cur.NextGoal = goal
+			}
+		}
+	}
+	return sum
+}
@@ -0,0 +1,162 @@
+package event
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GCThresholds are the limits beyond which the garbage collections of a trace
+// are reported as anomalies by the GCAnomalies method of Trace. A zero field
+// disables its check. Times are in the unit of the Ts field of events.
+type GCThresholds struct {
+
+	// MaxPause is the longest stop the world pause allowed.
+	MaxPause int64
+
+	// MaxAssistRatio is the largest fraction of the duration of a cycle which
+	// goroutines may spend performing mark assists, which may exceed 1 as
+	// many goroutines may assist at once.
+	MaxAssistRatio float64
+
+	// MaxHeapGrowth is the largest factor the heap may grow by from the live
+	// heap after a cycle until the next cycle begins.
+	MaxHeapGrowth float64
+}
+
+// GCAnomalyKind is the threshold of GCThresholds a GCAnomaly exceeded.
+type GCAnomalyKind byte
+
+// Kinds of anomalies reported by GCAnomalies.
+const (
+	GCLongPause GCAnomalyKind = iota
+	GCAssistRatio
+	GCHeapGrowth
+)
+
+var gcAnomalyKindNames = [...]string{
+	GCLongPause:   `LongPause`,
+	GCAssistRatio: `AssistRatio`,
+	GCHeapGrowth:  `HeapGrowth`,
+}
+
+// String implements fmt.Stringer by returning the name of this kind.
+func (k GCAnomalyKind) String() string {
+	if int(k) < len(gcAnomalyKindNames) {
+		return gcAnomalyKindNames[k]
+	}
+	return fmt.Sprintf(`GCAnomalyKind(%d)`, int(k))
+}
+
+// GCAnomaly is a garbage collection which exceeded a threshold.
+type GCAnomaly struct {
+	Kind GCAnomalyKind
+
+	// Ts is the start of the offending pause, or of the cycle for the other
+	// kinds, and Seq the sequence of the cycle it occurred within.
+	Ts  int64
+	Seq uint64
+
+	// Value is the measured pause, ratio or growth and Limit the threshold
+	// it exceeded.
+	Value, Limit float64
+
+	// StackIDs are the stacks responsible for the anomaly: the stack which
+	// started the cycle for pauses and heap growth, and the stacks of the
+	// mark assists performed during the cycle ordered by the most time spent
+	// for assist ratios. Stacks which were not recorded are omitted.
+	StackIDs []uint64
+}
+
+// String implements fmt.Stringer.
+func (a GCAnomaly) String() string {
+	return fmt.Sprintf(`%v in GC %v at %v: %.4g exceeds %.4g`,
+		a.Kind, a.Seq, a.Ts, a.Value, a.Limit)
+}
+
+// GCAnomalies returns the garbage collections within evts exceeding the given
+// thresholds ordered by their timestamp, for triaging traces automatically.
+// Like GC, evts must be ordered as they are by an Orderer and retain the
+// arguments in the layout of the Version of this Trace.
+func (tr *Trace) GCAnomalies(evts []*Event, th GCThresholds) []GCAnomaly {
+	sum := tr.GC(evts)
+
+	// Gather the stacks which started each cycle and the time spent assisting
+	// from each stack, in the same manner cycles are counted by GC.
+	var (
+		starts  = make([]uint64, 0, len(sum.Cycles))
+		assists = make([]map[uint64]int64, 0, len(sum.Cycles))
+		open    = make(map[int64]*Event)
+	)
+	for _, evt := range evts {
+		switch evt.Type {
+		case EvGCStart:
+			starts = append(starts, evt.argIn(tr.Version, ArgStackID))
+			assists = append(assists, make(map[uint64]int64))
+		case EvGCMarkAssistStart:
+			if len(assists) > 0 {
+				open[evt.G] = evt
+			}
+		case EvGCMarkAssistDone:
+			start, ok := open[evt.G]
+			if !ok {
+				break
+			}
+			delete(open, evt.G)
+			if stk := start.argIn(tr.Version, ArgStackID); stk != 0 {
+				assists[len(assists)-1][stk] += evt.Ts - start.Ts
+			}
+		}
+	}
+	stacks := func(ids ...uint64) []uint64 {
+		var out []uint64
+		for _, id := range ids {
+			if id != 0 {
+				out = append(out, id)
+			}
+		}
+		return out
+	}
+
+	var out []GCAnomaly
+	for i, c := range sum.Cycles {
+		if th.MaxPause > 0 {
+			for _, p := range c.Pauses {
+				if d := p.Duration(); d > th.MaxPause {
+					out = append(out, GCAnomaly{
+						Kind: GCLongPause, Ts: p.Start, Seq: c.Seq,
+						Value: float64(d), Limit: float64(th.MaxPause),
+						StackIDs: stacks(starts[i])})
+				}
+			}
+		}
+		if d := c.Duration(); th.MaxAssistRatio > 0 && d > 0 {
+			if r := float64(c.AssistTime) / float64(d); r > th.MaxAssistRatio {
+				ids := make([]uint64, 0, len(assists[i]))
+				for id := range assists[i] {
+					ids = append(ids, id)
+				}
+				sort.Slice(ids, func(a, b int) bool {
+					ta, tb := assists[i][ids[a]], assists[i][ids[b]]
+					return ta > tb || (ta == tb && ids[a] < ids[b])
+				})
+				out = append(out, GCAnomaly{
+					Kind: GCAssistRatio, Ts: c.Start, Seq: c.Seq,
+					Value: r, Limit: th.MaxAssistRatio, StackIDs: ids})
+			}
+		}
+		if th.MaxHeapGrowth > 0 && i > 0 {
+			live := sum.Cycles[i-1].HeapLive
+			if live == 0 {
+				continue
+			}
+			if g := float64(c.HeapAlloc) / float64(live); g > th.MaxHeapGrowth {
+				out = append(out, GCAnomaly{
+					Kind: GCHeapGrowth, Ts: c.Start, Seq: c.Seq,
+					Value: g, Limit: th.MaxHeapGrowth,
+					StackIDs: stacks(starts[i])})
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Ts < out[j].Ts })
+	return out
+}
@@ -0,0 +1,151 @@
+package event
+
+import "sort"
+
+// GoroutineID is the id of a goroutine, as held by the G field of an Event or
+// its goroutine arguments.
+type GoroutineID uint64
+
+// gInfo is the state of a goroutine retained by a Trace, with strings and
+// stacks resolved when queried as they may be visited after the goroutine.
+type gInfo struct {
+	parent                  uint64
+	createStack, newStack   uint64
+	label                   uint64
+	created, started, ended int64
+	end                     Type
+	initial                 GStatus
+	syscall, timer          bool
+}
+
+// GoroutineIDs returns the id of every goroutine created or declared by the
+// events visited by this Trace in ascending order.
+func (tr *Trace) GoroutineIDs() []GoroutineID {
+	out := make([]GoroutineID, 0, len(tr.gs))
+	for id := range tr.gs {
+		out = append(out, GoroutineID(id))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i] < out[j]
+	})
+	return out
+}
+
+// Goroutine returns the descriptor of the goroutine with the given id and a
+// boolean true, or the zero value and false if it has not been visited. Unlike
+// the Goroutines method it does not require the events to be retained, so the
+// time spent in each status and the Transitions are not set. Events must be
+// decoded by a Decoder before they are visited to determine the parent and end
+// of each goroutine.
+func (tr *Trace) Goroutine(id GoroutineID) (GDesc, bool) {
+	g, ok := tr.gs[uint64(id)]
+	if !ok {
+		return GDesc{}, false
+	}
+	desc := GDesc{
+		ID:             uint64(id),
+		Parent:         g.parent,
+		CreateStackID:  g.createStack,
+		CreateTime:     g.created,
+		StartTime:      g.started,
+		EndTime:        g.ended,
+		EndReason:      g.end,
+		Initial:        g.initial,
+		InitialSyscall: g.syscall,
+		Timer:          g.timer,
+	}
+	if tr.Version == Version1 {
+		desc.PC = g.newStack
+	} else {
+		desc.StartStackID = g.newStack
+	}
+	if stk := tr.Stacks[desc.StartStackID]; len(stk) > 0 {
+		desc.Name, desc.PC = stk[0].Func(), stk[0].PC()
+	}
+	if g.label != 0 {
+		desc.Label = tr.getStringDefault(g.label)
+	}
+	return desc, true
+}
+
+// InitialGoroutines returns the descriptor of each goroutine which existed when
+// tracing started in order of their id, such as to seed lifecycle and blocking
+// analysis of a trace captured during the life of a program. The runtime
+// declares them with EvGoCreate events emitted before any goroutine runs,
+// followed by EvGoWaiting or EvGoInSyscall events for those which are blocked.
+// These are identified by having a G of zero, so events must be decoded by a
+// Decoder before they are visited.
+func (tr *Trace) InitialGoroutines() []GDesc {
+	var out []GDesc
+	for _, id := range tr.GoroutineIDs() {
+		if g := tr.gs[uint64(id)]; g.initial != GDead {
+			desc, _ := tr.Goroutine(id)
+			out = append(out, desc)
+		}
+	}
+	return out
+}
+
+// visitGoroutine records the creation, labels and end of goroutines, along
+// with the status of those declared by the runtime when tracing started.
+func (tr *Trace) visitGoroutine(evt *Event) {
+	if tr.gs == nil {
+		tr.gs = make(map[uint64]gInfo)
+	}
+
+	// Goroutines first seen without an EvGoCreate event are considered created
+	// by the event referring to them.
+	get := func(id uint64) gInfo {
+		g, ok := tr.gs[id]
+		if !ok {
+			g.created = evt.Ts
+		}
+		return g
+	}
+
+	switch evt.Type {
+	case EvGoCreate:
+		args, _ := evt.GoCreate(tr.Version)
+		g := gInfo{
+			parent:      uint64(evt.G),
+			createStack: args.StackID,
+			newStack:    args.NewStackID,
+			created:     evt.Ts,
+		}
+		if evt.G == 0 {
+			g.initial = GRunnable
+		}
+		tr.gs[args.NewGoroutineID] = g
+	case EvGoWaiting, EvGoInSyscall:
+		id := evt.argIn(tr.Version, ArgGoroutineID)
+		g := get(id)
+		g.initial, g.syscall = GWaiting, evt.Type == EvGoInSyscall
+		tr.gs[id] = g
+	case EvTimerGoroutine:
+		id := evt.argIn(tr.Version, ArgGoroutineID)
+		g := get(id)
+		if g.initial == GDead && g.parent == 0 {
+			g.initial = GRunnable
+		}
+		g.timer = true
+		tr.gs[id] = g
+	case EvGoStart, EvGoStartLocal, EvGoStartLabel:
+		id := evt.argIn(tr.Version, ArgGoroutineID)
+		g := get(id)
+		if g.started != 0 && evt.Type != EvGoStartLabel {
+			break
+		}
+		if g.started == 0 {
+			g.started = evt.Ts
+		}
+		if evt.Type == EvGoStartLabel {
+			g.label = evt.argIn(tr.Version, ArgLabelStringID)
+		}
+		tr.gs[id] = g
+	case EvGoEnd, EvGoStop:
+		if g, ok := tr.gs[uint64(evt.G)]; ok && evt.G != 0 {
+			g.ended, g.end = evt.Ts, evt.Type
+			tr.gs[uint64(evt.G)] = g
+		}
+	}
+}
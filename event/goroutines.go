@@ -0,0 +1,292 @@
+package event
+
+import "fmt"
+
+// GStatus is the scheduling status of a goroutine.
+type GStatus byte
+
+// Statuses a goroutine may be in, a goroutine blocked in a syscall is waiting.
+const (
+	GDead GStatus = iota
+	GRunnable
+	GRunning
+	GWaiting
+)
+
+var gStatusNames = [...]string{
+	GDead:     `Dead`,
+	GRunnable: `Runnable`,
+	GRunning:  `Running`,
+	GWaiting:  `Waiting`,
+}
+
+// String implements fmt.Stringer by returning the name of this status.
+func (s GStatus) String() string {
+	if int(s) < len(gStatusNames) {
+		return gStatusNames[s]
+	}
+	return fmt.Sprintf(`GStatus(%d)`, int(s))
+}
+
+// GTransition is a change in the status of a goroutine caused by an event.
+type GTransition struct {
+	Ts       int64
+	Type     Type
+	From, To GStatus
+}
+
+// GDesc describes the lifetime of a single goroutine, see the Goroutines
+// method of Trace. All times are in the unit of the Ts field of the events
+// they were derived from.
+type GDesc struct {
+	ID uint64
+
+	// Parent is the goroutine which created this goroutine, or zero when it
+	// existed before tracing started.
+	Parent uint64
+
+	// Name is the function the goroutine was started with and PC its program
+	// counter, resolved from the top frame of the stack of StartStackID. The
+	// runtime of Version1 recorded the PC of the function in place of a stack,
+	// leaving only PC set.
+	Name string
+	PC   uint64
+
+	// CreateStackID is the stack of the EvGoCreate event which created this
+	// goroutine and StartStackID the stack the goroutine began running with.
+	// Either may be zero when stacks were not recorded.
+	CreateStackID, StartStackID uint64
+
+	// Label is the label of the most recent EvGoStartLabel event of the
+	// goroutine, such as "GC (dedicated)" for GC mark workers.
+	Label string
+
+	// Initial is the status of a goroutine which existed when tracing started,
+	// GWaiting for those blocked or in a syscall and GRunnable otherwise, which
+	// includes the goroutine that started tracing. It is GDead for goroutines
+	// created while tracing. InitialSyscall is true when it was blocked in a
+	// syscall and Timer when it is the runtime timer goroutine.
+	Initial        GStatus
+	InitialSyscall bool
+	Timer          bool
+
+	// CreateTime, StartTime and EndTime are the timestamps this goroutine was
+	// created or declared, first ran and ended. EndTime is zero when the
+	// goroutine was still alive at the end of the trace.
+	CreateTime, StartTime, EndTime int64
+
+	// EndReason is EvGoEnd or EvGoStop when the goroutine ended within the
+	// trace, or EvNone otherwise.
+	EndReason Type
+
+	// TotalTime is the time from creation until the goroutine ended, or until
+	// the last event of the trace.
+	TotalTime int64
+
+	// ExecTime is the time spent running, SchedWaitTime runnable and waiting
+	// for a P, BlockTime blocked on synchronization, IOTime blocked on the
+	// network and SyscallTime blocked in syscalls.
+	ExecTime, SchedWaitTime, BlockTime, IOTime, SyscallTime int64
+
+	// GCTime is the time this goroutine spent alive during a garbage
+	// collection, AssistTime performing mark assists or blocked for them and
+	// SweepTime sweeping.
+	GCTime, AssistTime, SweepTime int64
+
+	// Transitions lists every change in status in the order they occurred.
+	Transitions []GTransition
+
+	status GStatus
+	since  int64
+	wait   Type // event that caused the goroutine to wait
+	assist int64
+	sweep  int64
+}
+
+// move transitions g to status to at ts, accounting the time spent in the prior
+// status.
+func (g *GDesc) move(ts int64, typ Type, to GStatus) {
+	if g == nil {
+		return
+	}
+	g.account(ts)
+	g.Transitions = append(g.Transitions, GTransition{
+		Ts: ts, Type: typ, From: g.status, To: to})
+	g.status, g.since = to, ts
+	if to == GWaiting {
+		g.wait = typ
+	}
+}
+
+// account adds the time from the prior transition until ts to the total of the
+// current status.
+func (g *GDesc) account(ts int64) {
+	d := ts - g.since
+	if d <= 0 {
+		return
+	}
+	switch g.status {
+	case GRunning:
+		g.ExecTime += d
+	case GRunnable:
+		g.SchedWaitTime += d
+	case GWaiting:
+		switch g.wait {
+		case EvGoBlockSend, EvGoBlockRecv, EvGoBlockSelect, EvGoBlockSync,
+			EvGoBlockCond:
+			g.BlockTime += d
+		case EvGoBlockNet:
+			g.IOTime += d
+		case EvGoSysBlock, EvGoInSyscall:
+			g.SyscallTime += d
+		case EvGoBlockGC:
+			g.AssistTime += d
+		}
+	}
+}
+
+// sysExitTime returns the time the syscall ended by an EvGoSysExit or
+// EvGoSysExitLocal event. The event is emitted once the goroutine is next
+// scheduled, the runtime records when the syscall returned in its RealTimestamp
+// argument which is zero when it was not recorded.
+func (tr *Trace) sysExitTime(evt *Event) int64 {
+	var real uint64
+	if args, ok := evt.GoSysExit(tr.Version); ok {
+		real = args.RealTimestamp
+	} else if args, ok := evt.GoSysExitLocal(tr.Version); ok {
+		real = args.RealTimestamp
+	}
+	if real == 0 || int64(real) > evt.Ts {
+		return evt.Ts
+	}
+	return int64(real)
+}
+
+// Goroutines returns a descriptor for each goroutine referred to by evts, which
+// must be ordered as they are by an Orderer and retain the arguments in the
+// layout of the Version of this Trace. Every event should have been visited by
+// this Trace beforehand so the stacks of each goroutine may be resolved.
+func (tr *Trace) Goroutines(evts []*Event) map[uint64]*GDesc {
+	var argoff int
+	if tr.Version.Valid() {
+		argoff = versions[tr.Version].argOffset
+	}
+
+	// Events without a running goroutine have a G of zero, which is never the
+	// id of a goroutine. Goroutines first seen without an EvGoCreate event are
+	// considered created by the event referring to them.
+	var lastTs, gcStart int64
+	gs := make(map[uint64]*GDesc)
+	get := func(id uint64) *GDesc {
+		g := gs[id]
+		if g == nil && id != 0 {
+			g = &GDesc{ID: id, CreateTime: lastTs, since: lastTs}
+			gs[id] = g
+		}
+		return g
+	}
+
+	for _, evt := range evts {
+		lastTs = evt.Ts
+		switch evt.Type {
+		case EvGoCreate:
+			g := get(evt.arg(ArgNewGoroutineID, argoff))
+			if g == nil {
+				continue
+			}
+			g.CreateTime, g.since, g.Parent = evt.Ts, evt.Ts, uint64(evt.G)
+			if evt.G == 0 {
+				g.Initial = GRunnable
+			}
+			g.CreateStackID = evt.arg(ArgStackID, argoff)
+			if tr.Version == Version1 {
+				g.PC = evt.arg(ArgNewStackID, argoff)
+			} else {
+				g.StartStackID = evt.arg(ArgNewStackID, argoff)
+			}
+			g.move(evt.Ts, evt.Type, GRunnable)
+		case EvGoWaiting, EvGoInSyscall:
+			g := get(evt.arg(ArgGoroutineID, argoff))
+			if g == nil {
+				continue
+			}
+			g.Initial, g.InitialSyscall = GWaiting, evt.Type == EvGoInSyscall
+			g.move(evt.Ts, evt.Type, GWaiting)
+		case EvTimerGoroutine:
+			if g := gs[evt.argIn(tr.Version, ArgGoroutineID)]; g != nil {
+				g.Timer = true
+			}
+		case EvGoStart, EvGoStartLocal, EvGoStartLabel:
+			g := get(evt.arg(ArgGoroutineID, argoff))
+			if g == nil {
+				continue
+			}
+			if g.StartTime == 0 {
+				g.StartTime = evt.Ts
+			}
+			if id := evt.argIn(tr.Version, ArgLabelStringID); id != 0 {
+				g.Label = tr.getStringDefault(id)
+			}
+			g.move(evt.Ts, evt.Type, GRunning)
+		case EvGoEnd, EvGoStop:
+			if g := get(uint64(evt.G)); g != nil {
+				g.move(evt.Ts, evt.Type, GDead)
+				g.EndTime, g.EndReason = evt.Ts, evt.Type
+			}
+		case EvGoSched, EvGoPreempt:
+			get(uint64(evt.G)).move(evt.Ts, evt.Type, GRunnable)
+		case EvGoBlock, EvGoBlockSend, EvGoBlockRecv, EvGoBlockSelect,
+			EvGoBlockSync, EvGoBlockCond, EvGoBlockNet, EvGoSleep,
+			EvGoSysBlock, EvGoBlockGC:
+			get(uint64(evt.G)).move(evt.Ts, evt.Type, GWaiting)
+		case EvGoUnblock, EvGoUnblockLocal:
+			get(evt.arg(ArgGoroutineID, argoff)).move(evt.Ts, evt.Type, GRunnable)
+		case EvGoSysExit, EvGoSysExitLocal:
+			get(evt.arg(ArgGoroutineID, argoff)).move(tr.sysExitTime(evt), evt.Type, GRunnable)
+		case EvGCMarkAssistStart:
+			if g := gs[uint64(evt.G)]; g != nil {
+				g.assist = evt.Ts
+			}
+		case EvGCMarkAssistDone:
+			if g := gs[uint64(evt.G)]; g != nil && g.assist != 0 {
+				g.AssistTime += evt.Ts - g.assist
+				g.assist = 0
+			}
+		case EvGCSweepStart:
+			if g := gs[uint64(evt.G)]; g != nil {
+				g.sweep = evt.Ts
+			}
+		case EvGCSweepDone:
+			if g := gs[uint64(evt.G)]; g != nil && g.sweep != 0 {
+				g.SweepTime += evt.Ts - g.sweep
+				g.sweep = 0
+			}
+		case EvGCStart:
+			gcStart = evt.Ts
+		case EvGCDone:
+			for _, g := range gs {
+				if g.EndTime != 0 {
+					continue
+				}
+				if gcStart < g.CreateTime {
+					g.GCTime += evt.Ts - g.CreateTime
+				} else {
+					g.GCTime += evt.Ts - gcStart
+				}
+			}
+		}
+	}
+
+	for _, g := range gs {
+		end := g.EndTime
+		if end == 0 {
+			g.account(lastTs)
+			end = lastTs
+		}
+		g.TotalTime = end - g.CreateTime
+		if stk := tr.Stacks[g.StartStackID]; len(stk) > 0 {
+			g.Name, g.PC = stk[0].Func(), stk[0].PC()
+		}
+	}
+	return gs
+}
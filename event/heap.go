@@ -0,0 +1,43 @@
+package event
+
+// HeapSeries returns the Heap samples of the GC method for evts, which must be
+// ordered as they are by an Orderer and retain the arguments in the layout of
+// the Version of this Trace.
+//
+// When points is greater than zero and fewer than the number of samples, the
+// series is downsampled to at most points samples by dividing the time of the
+// series into equal intervals and keeping the sample with the largest heap
+// size in each, so peaks remain visible to plots and threshold alerts.
+func (tr *Trace) HeapSeries(evts []*Event, points int) []HeapSample {
+	out := tr.GC(evts).Heap
+	if points <= 0 || len(out) <= points {
+		return out
+	}
+	return downsampleHeap(out, points)
+}
+
+// downsampleHeap returns the sample with the largest Alloc within each of n
+// equal intervals of the time spanned by samples, omitting empty intervals.
+func downsampleHeap(samples []HeapSample, n int) []HeapSample {
+	first, last := samples[0].Ts, samples[len(samples)-1].Ts
+	span := last - first + 1
+	out := make([]HeapSample, 0, n)
+	bucket := -1
+	for _, s := range samples {
+		b := int(float64(s.Ts-first) / float64(span) * float64(n))
+		switch {
+		case b < 0:
+			b = 0
+		case b >= n:
+			b = n - 1
+		}
+		if b != bucket {
+			out, bucket = append(out, s), b
+			continue
+		}
+		if s.Alloc > out[len(out)-1].Alloc {
+			out[len(out)-1] = s
+		}
+	}
+	return out
+}
@@ -0,0 +1,206 @@
+package event
+
+import (
+	"fmt"
+	"sort"
+)
+
+// IntegrityKind is a symptom of a trace which lost events, such as when the
+// buffers of the runtime overflowed, or which was stitched together from
+// multiple captures.
+type IntegrityKind byte
+
+// Kinds of integrity issues, see IntegrityReport.
+const (
+
+	// IntegrityTimeRegression is an event with a timestamp earlier than the
+	// prior event of the same P.
+	IntegrityTimeRegression IntegrityKind = iota + 1
+
+	// IntegritySequenceGap is an EvGCStart event whose sequence does not
+	// follow the prior garbage collection, so cycles are missing. Version1
+	// did not declare the sequence of garbage collections.
+	IntegritySequenceGap
+
+	// IntegrityUnknownP is an EvBatch for a P beyond any GOMAXPROCS declared
+	// by EvGomaxprocs events.
+	IntegrityUnknownP
+)
+
+var integrityKindNames = [...]string{
+	IntegrityTimeRegression: `TimeRegression`,
+	IntegritySequenceGap:    `SequenceGap`,
+	IntegrityUnknownP:       `UnknownP`,
+}
+
+// String implements fmt.Stringer by returning the name of this kind.
+func (k IntegrityKind) String() string {
+	if k > 0 && int(k) < len(integrityKindNames) {
+		return integrityKindNames[k]
+	}
+	return fmt.Sprintf(`IntegrityKind(%d)`, int(k))
+}
+
+// maxIntegrityIssues bounds the issues retained by a Trace, issues beyond it
+// are only counted.
+const maxIntegrityIssues = 64
+
+// IntegrityIssue is a single event exhibiting an IntegrityKind.
+type IntegrityIssue struct {
+	Kind IntegrityKind
+	Off  int
+	Ts   int64
+	P    int64
+	Msg  string
+}
+
+// IntegrityReport describes the symptoms of lost events found while visiting,
+// see the Integrity method of Trace.
+type IntegrityReport struct {
+
+	// Issues lists the first issues found in the order they were found,
+	// followed by those found when the report was made.
+	Issues []IntegrityIssue
+
+	// Counts holds the number of issues of each kind, including those beyond
+	// the ones retained in Issues.
+	Counts map[IntegrityKind]int
+}
+
+// OK reports whether no issues were found.
+func (r *IntegrityReport) OK() bool {
+	return len(r.Counts) == 0
+}
+
+// integrity is the state of a Trace used to detect integrity issues.
+type integrity struct {
+	last     map[int64]int64 // timestamp of the last event of each P
+	p        int64           // P of the prior event, whose timestamp is ts
+	ts       int64
+	seen     bool
+	procs    map[int64]IntegrityIssue
+	maxprocs uint64
+	gcs      []gcSeq
+	issues   []IntegrityIssue
+	counts   map[IntegrityKind]int
+}
+
+// gcSeq is the sequence of an EvGCStart event and the issue it would cause.
+type gcSeq struct {
+	seq uint64
+	is  IntegrityIssue
+}
+
+func (in *integrity) add(is IntegrityIssue) {
+	if in.counts == nil {
+		in.counts = make(map[IntegrityKind]int)
+	}
+	in.counts[is.Kind]++
+	if len(in.issues) < maxIntegrityIssues {
+		in.issues = append(in.issues, is)
+	}
+}
+
+// Integrity returns a report of the symptoms of lost events or stitched
+// captures found while visiting events, meaning analysis of the trace may be
+// incomplete or wrong. Events must be decoded by a Decoder before they are
+// visited, in the order they were decoded or as ordered by an Orderer. The
+// garbage collections of each P are decoded out of order, so their sequences
+// are compared once the report is made.
+func (tr *Trace) Integrity() *IntegrityReport {
+	in := &tr.integrity
+	r := &IntegrityReport{
+		Issues: append([]IntegrityIssue(nil), in.issues...),
+		Counts: make(map[IntegrityKind]int, len(in.counts)),
+	}
+	for k, n := range in.counts {
+		r.Counts[k] = n
+	}
+	report := func(is IntegrityIssue) {
+		r.Counts[is.Kind]++
+		if len(r.Issues) < maxIntegrityIssues {
+			r.Issues = append(r.Issues, is)
+		}
+	}
+
+	gcs := append([]gcSeq(nil), in.gcs...)
+	sort.SliceStable(gcs, func(i, j int) bool { return gcs[i].seq < gcs[j].seq })
+	for i := 1; i < len(gcs); i++ {
+		if prev := gcs[i-1].seq; gcs[i].seq != prev+1 {
+			is := gcs[i].is
+			is.Msg = fmt.Sprintf(`GC sequence %v does not follow %v`, gcs[i].seq, prev)
+			report(is)
+		}
+	}
+	if in.maxprocs == 0 {
+		return r
+	}
+
+	var unknown []IntegrityIssue
+	for p, is := range in.procs {
+		if uint64(p) >= in.maxprocs {
+			unknown = append(unknown, is)
+		}
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Off < unknown[j].Off })
+	for _, is := range unknown {
+		is.Msg = fmt.Sprintf(`P %v exceeds GOMAXPROCS of %v`, is.P, in.maxprocs)
+		report(is)
+	}
+	return r
+}
+
+// visitIntegrity records the integrity issues of evt.
+func (tr *Trace) visitIntegrity(evt *Event) {
+	in := &tr.integrity
+	switch evt.Type {
+	case EvBatch:
+		if evt.P < 0 {
+			break
+		}
+		if in.procs == nil {
+			in.procs = make(map[int64]IntegrityIssue)
+		}
+		if _, ok := in.procs[evt.P]; !ok {
+			in.procs[evt.P] = IntegrityIssue{
+				Kind: IntegrityUnknownP, Off: evt.Off, Ts: evt.Ts, P: evt.P}
+		}
+	case EvGomaxprocs:
+		if n := evt.argIn(tr.Version, ArgGomaxprocs); n > in.maxprocs {
+			in.maxprocs = n
+		}
+	case EvGCStart:
+		if hasArg(tr.Version, EvGCStart, ArgSequenceGC) {
+			in.gcs = append(in.gcs, gcSeq{
+				seq: evt.argIn(tr.Version, ArgSequenceGC),
+				is: IntegrityIssue{
+					Kind: IntegritySequenceGap, Off: evt.Off, Ts: evt.Ts, P: evt.P}})
+		}
+	}
+
+	// Samples carry the time they were taken rather than following the clock
+	// of the batch holding them. The timestamp of the P of the prior event is
+	// held until the events of another P are visited.
+	if evt.Ts == 0 || evt.Type == EvCPUSample {
+		return
+	}
+	if !in.seen || evt.P != in.p {
+		if in.last == nil {
+			in.last = make(map[int64]int64)
+		}
+		if in.seen {
+			in.last[in.p] = in.ts
+		}
+		ts, ok := in.last[evt.P]
+		if !ok {
+			ts = evt.Ts
+		}
+		in.p, in.ts, in.seen = evt.P, ts, true
+	}
+	if evt.Ts < in.ts {
+		in.add(IntegrityIssue{
+			Kind: IntegrityTimeRegression, Off: evt.Off, Ts: evt.Ts, P: evt.P,
+			Msg: fmt.Sprintf(`timestamp %v precedes %v of P %v`, evt.Ts, in.ts, evt.P)})
+	}
+	in.ts = evt.Ts
+}
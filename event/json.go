@@ -0,0 +1,149 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// jsonEvent is the JSON representation of an Event, see MarshalJSON.
+type jsonEvent struct {
+	Type       string            `json:"type"`
+	Off        int               `json:"off"`
+	Ts         int64             `json:"ts"`
+	P          int64             `json:"p"`
+	G          int64             `json:"g"`
+	Args       map[string]uint64 `json:"args,omitempty"`
+	Extra      []uint64          `json:"extra,omitempty"`
+	Data       *string           `json:"data,omitempty"`
+	DataBase64 []byte            `json:"dataBase64,omitempty"`
+	Span       *jsonSpan         `json:"span,omitempty"`
+	Strings    map[string]string `json:"strings,omitempty"`
+	Stack      []jsonFrame       `json:"stack,omitempty"`
+}
+
+type jsonSpan struct {
+	Off int `json:"off"`
+	Len int `json:"len"`
+}
+
+type jsonFrame struct {
+	PC   uint64 `json:"pc"`
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// MarshalJSON implements json.Marshaler. Arguments are keyed by the names
+// returned from the Args method of the event type, with arguments beyond them
+// such as the frames of a stack given in order as "extra". Data is given as a
+// string when it is valid UTF-8, otherwise base64 encoded as "dataBase64".
+//
+// The names follow the latest version of the trace format, Version1 events
+// have an additional leading argument so their names will not align. See the
+// MarshalEvent method of Trace for including the strings and stack an event
+// refers to.
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newJSONEvent(&e))
+}
+
+// UnmarshalJSON implements json.Unmarshaler for the representation produced
+// by MarshalJSON, any resolved strings or stack are ignored.
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var je jsonEvent
+	if err := json.Unmarshal(b, &je); err != nil {
+		return err
+	}
+
+	typ, ok := TypeFromString(je.Type)
+	if !ok {
+		return fmt.Errorf(`unknown event type %q`, je.Type)
+	}
+
+	names := typ.Args()
+	args := make([]uint64, 0, len(je.Args)+len(je.Extra))
+	for _, name := range names {
+		v, ok := je.Args[name]
+		if !ok {
+			break
+		}
+		args = append(args, v)
+	}
+	if len(args) == len(names) {
+		args = append(args, je.Extra...)
+	}
+	if len(args) != len(je.Args)+len(je.Extra) {
+		return fmt.Errorf(`arguments of event %v are not contiguous`, typ)
+	}
+
+	*e = Event{Type: typ, Args: args, Off: je.Off, Ts: je.Ts, P: je.P, G: je.G}
+	if je.Data != nil {
+		e.Data = []byte(*je.Data)
+	} else if je.DataBase64 != nil {
+		e.Data = je.DataBase64
+	}
+	if je.Span != nil {
+		e.Span = Span{Off: je.Span.Off, Len: je.Span.Len}
+	}
+	return nil
+}
+
+// MarshalEvent returns the JSON representation of evt as by its MarshalJSON
+// method, adding the strings and stack it refers to. Strings are keyed by the
+// name of the argument holding their id and the stack is given as a list of
+// frames, those which can not be found in the Trace are omitted.
+func (tr *Trace) MarshalEvent(evt *Event) ([]byte, error) {
+	je := newJSONEvent(evt)
+	for i, name := range evt.Type.Args() {
+		if i >= len(evt.Args) {
+			break
+		}
+		switch name {
+		case ArgLabelStringID, ArgNameStringID, ArgKeyStringID:
+			if s, err := tr.getString(evt.Args[i]); err == nil {
+				if je.Strings == nil {
+					je.Strings = make(map[string]string)
+				}
+				je.Strings[name] = s
+			}
+		case ArgStackID:
+			if evt.Type == EvStack {
+				continue
+			}
+			stk, _ := tr.getStack(evt.Args[i])
+			for _, f := range stk {
+				je.Stack = append(je.Stack, jsonFrame{
+					PC: f.PC(), Func: f.Func(), File: f.File(), Line: f.Line()})
+			}
+		}
+	}
+	return json.Marshal(je)
+}
+
+func newJSONEvent(e *Event) *jsonEvent {
+	je := &jsonEvent{
+		Type: e.Type.Name(), Off: e.Off, Ts: e.Ts, P: e.P, G: e.G}
+	names := e.Type.Args()
+	for i, arg := range e.Args {
+		if i >= len(names) {
+			je.Extra = e.Args[i:]
+			break
+		}
+		if je.Args == nil {
+			je.Args = make(map[string]uint64, len(names))
+		}
+		je.Args[names[i]] = arg
+	}
+	if len(e.Data) > 0 {
+		if utf8.Valid(e.Data) {
+			s := string(e.Data)
+			je.Data = &s
+		} else {
+			je.DataBase64 = e.Data
+		}
+	}
+	if e.Span.Len > 0 {
+		je.Span = &jsonSpan{Off: e.Span.Off, Len: e.Span.Len}
+	}
+	return je
+}
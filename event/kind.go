@@ -0,0 +1,131 @@
+package event
+
+import "fmt"
+
+// Kind describes the meaning of an event argument so generic tools may format
+// or resolve values without switching on each event Type.
+type Kind byte
+
+// Kinds of arguments that may exist within an event, see the ArgKinds method
+// of Type.
+const (
+	KindNone      Kind = iota // argument has no known meaning
+	KindTimestamp             // timestamp in ticks, see ArgFrequency
+	KindRealTime              // wall clock timestamp in nanoseconds
+	KindFrequency             // ticks per second
+	KindSequence              // sequence number ordering related events
+	KindGoroutine             // goroutine id
+	KindProcessor             // processor (P) id
+	KindThread                // operating system thread (M) id
+	KindStack                 // stack id, resolved from Stack events
+	KindString                // string id, resolved from String events
+	KindTask                  // user task id
+	KindBytes                 // count of bytes
+	KindCount                 // count of a quantity without a unit
+	KindEnum                  // discrete value specific to the event type
+)
+
+var kindNames = [...]string{
+	KindNone:      `None`,
+	KindTimestamp: `Timestamp`,
+	KindRealTime:  `RealTime`,
+	KindFrequency: `Frequency`,
+	KindSequence:  `Sequence`,
+	KindGoroutine: `Goroutine`,
+	KindProcessor: `Processor`,
+	KindThread:    `Thread`,
+	KindStack:     `Stack`,
+	KindString:    `String`,
+	KindTask:      `Task`,
+	KindBytes:     `Bytes`,
+	KindCount:     `Count`,
+	KindEnum:      `Enum`,
+}
+
+var kindUnits = [...]string{
+	KindTimestamp: `ticks`,
+	KindRealTime:  `ns`,
+	KindFrequency: `ticks/s`,
+	KindBytes:     `bytes`,
+}
+
+// Valid returns true if the Kind is known, false otherwise.
+func (k Kind) Valid() bool {
+	return int(k) < len(kindNames)
+}
+
+// Unit returns the unit of measure for arguments of this kind such as "bytes"
+// or "ns", or an empty string when values are identifiers or unitless.
+func (k Kind) Unit() string {
+	if int(k) < len(kindUnits) {
+		return kindUnits[k]
+	}
+	return ``
+}
+
+// String implements fmt.Stringer by returning the name of this Kind.
+func (k Kind) String() string {
+	if k.Valid() {
+		return kindNames[k]
+	}
+	return fmt.Sprintf(`Kind(%d)`, int(k))
+}
+
+// argKinds maps each argument name to its kind, every name used in schemas
+// must be present.
+var argKinds = map[string]Kind{
+	ArgTimestamp:      KindTimestamp,
+	ArgRealTimestamp:  KindRealTime,
+	ArgFrequency:      KindFrequency,
+	ArgSequence:       KindSequence,
+	ArgSequenceGC:     KindSequence,
+	ArgStackID:        KindStack,
+	ArgStackSize:      KindCount,
+	ArgNewStackID:     KindStack,
+	ArgStringID:       KindString,
+	ArgLabelStringID:  KindString,
+	ArgThreadID:       KindThread,
+	ArgProcessorID:    KindProcessor,
+	ArgGoroutineID:    KindGoroutine,
+	ArgNewGoroutineID: KindGoroutine,
+	ArgGomaxprocs:     KindCount,
+	ArgHeapAlloc:      KindBytes,
+	ArgNextGC:         KindBytes,
+	ArgKind:           KindEnum,
+	ArgTaskID:         KindTask,
+	ArgParentTaskID:   KindTask,
+	ArgNameStringID:   KindString,
+	ArgKeyStringID:    KindString,
+	ArgMode:           KindEnum,
+	ArgSequenceDelta:  KindSequence,
+	ArgUnused:         KindNone,
+}
+
+// schemaKinds holds the kind of each argument in schemas, in the same order.
+var schemaKinds [schemasCount][]Kind
+
+func init() {
+	for typ, s := range schemas {
+		kinds := make([]Kind, len(s.Args))
+		for i, name := range s.Args {
+			kinds[i] = argKinds[name]
+		}
+		schemaKinds[typ] = kinds
+	}
+}
+
+// ArgKinds returns the kind of each argument listed by Args, in the same
+// order.
+func (t Type) ArgKinds() []Kind {
+	return schemaKinds[t%EvCount]
+}
+
+// ArgKind returns the kind of the named argument and a boolean true, or
+// KindNone and false if arg does not exist in this event type.
+func (t Type) ArgKind(name string) (Kind, bool) {
+	idx, ok := t.Arg(name)
+	if !ok {
+		return KindNone, false
+	}
+	return schemaKinds[t%EvCount][idx], true
+}
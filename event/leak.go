@@ -0,0 +1,99 @@
+package event
+
+import "sort"
+
+// Leak is a group of goroutines created by the same stack which never ended
+// and remained blocked at the end of a trace, see the Leaks method of Trace.
+type Leak struct {
+
+	// CreateStackID is the stack of the EvGoCreate events which created the
+	// goroutines, which may be zero when stacks were not recorded.
+	CreateStackID uint64
+
+	// Count is the number of goroutines in this group and Goroutines their
+	// ids in ascending order.
+	Count      int
+	Goroutines []uint64
+
+	// Reasons counts the goroutines by the event they last blocked with, such
+	// as EvGoBlockRecv.
+	Reasons map[Type]int
+
+	// Oldest is the longest time any of the goroutines remained blocked until
+	// the last event of the trace.
+	Oldest int64
+}
+
+// Leaks returns the goroutines created within evts which never ended and were
+// blocked for at least min at the end of the trace, grouped by the stack that
+// created them and ordered by the most goroutines. Goroutines blocked in
+// syscalls or which existed before the trace began are not reported, while
+// those stopped by an EvGoStop event, such as by an empty select or a nil
+// channel, are reported as blocked since it. Like Goroutines, evts must be
+// ordered as they are by an Orderer and retain the arguments in the layout of
+// the Version of this Trace.
+//
+// A goroutine blocked at the end of a trace is not necessarily leaked, though
+// many goroutines from one stack blocked for most of a long trace often are.
+func (tr *Trace) Leaks(evts []*Event, min int64) []Leak {
+	if len(evts) == 0 {
+		return nil
+	}
+	last := evts[len(evts)-1].Ts
+	initial := make(map[uint64]bool)
+	for _, g := range tr.InitialGoroutines() {
+		initial[g.ID] = true
+	}
+
+	// Goroutines which block forever end with an EvGoStop event, they never
+	// run again and are the most certain of leaks.
+	groups := make(map[uint64]*Leak)
+	for _, g := range tr.Goroutines(evts) {
+		if initial[g.ID] || g.Initial != GDead || len(g.Transitions) == 0 ||
+			g.Transitions[0].Type != EvGoCreate {
+			continue
+		}
+		reason, since := g.wait, g.since
+		switch {
+		case g.EndReason == EvGoStop:
+			reason, since = EvGoStop, g.EndTime
+		case g.EndReason != EvNone || g.status != GWaiting:
+			continue
+		}
+		switch reason {
+		case EvGoSysBlock, EvGoInSyscall:
+			continue
+		}
+		blocked := last - since
+		if blocked < min {
+			continue
+		}
+
+		l := groups[g.CreateStackID]
+		if l == nil {
+			l = &Leak{CreateStackID: g.CreateStackID, Reasons: make(map[Type]int)}
+			groups[g.CreateStackID] = l
+		}
+		l.Count++
+		l.Goroutines = append(l.Goroutines, g.ID)
+		l.Reasons[reason]++
+		if blocked > l.Oldest {
+			l.Oldest = blocked
+		}
+	}
+
+	out := make([]Leak, 0, len(groups))
+	for _, l := range groups {
+		sort.Slice(l.Goroutines, func(i, j int) bool {
+			return l.Goroutines[i] < l.Goroutines[j]
+		})
+		out = append(out, *l)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].CreateStackID < out[j].CreateStackID
+	})
+	return out
+}
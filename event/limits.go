@@ -0,0 +1,165 @@
+package event
+
+import (
+	"container/list"
+	"errors"
+)
+
+// ErrTableFull is returned when visiting an event would exceed the Limits of a
+// Trace which does not evict.
+var ErrTableFull = errors.New(`trace table limit exceeded`)
+
+// TableLimits bounds the strings and stacks accumulated by a Trace, for
+// services that ingest many traces concurrently. Zero values are unbounded.
+type TableLimits struct {
+
+	// MaxStrings and MaxStacks bound the number of entries in each table and
+	// MaxBytes the approximate memory of both, as reported by MemoryUsage.
+	MaxStrings, MaxStacks, MaxBytes int
+
+	// Evict selects evicting the least recently added or resolved entries once
+	// a limit is reached, otherwise visiting an event that would exceed a
+	// limit returns ErrTableFull. Events referring to evicted entries resolve
+	// them as missing.
+	Evict bool
+}
+
+// Approximate sizes of table entries used for accounting, a string costs its
+// length plus the overhead of an entry.
+const (
+	entryBytes = 48
+	frameBytes = 72
+)
+
+type tableKind int
+
+const (
+	stringTable tableKind = iota
+	stackTable
+)
+
+type tableEntry struct {
+	kind tableKind
+	id   uint64
+	size int
+	used uint64
+}
+
+// tables tracks the use of each entry in the string and stack tables of a Trace
+// so the least recently used may be evicted.
+type tables struct {
+	lru   [2]*list.List
+	els   [2]map[uint64]*list.Element
+	bytes int
+	clock uint64
+}
+
+// MemoryUsage returns the approximate number of bytes held by the strings and
+// stacks of this Trace that were added by visiting events.
+func (tr *Trace) MemoryUsage() int {
+	return tr.tables.bytes
+}
+
+func stringSize(s string) int { return entryBytes + len(s) }
+
+func stackSize(stk Stack) int { return entryBytes + len(stk)*frameBytes }
+
+// admit accounts for a new entry, evicting others or returning ErrTableFull if
+// it would exceed the Limits of this Trace.
+func (tr *Trace) admit(kind tableKind, id uint64, size int) error {
+	t, l := &tr.tables, tr.Limits
+	if l == (TableLimits{}) {
+		// Entries of unbounded tables are never evicted, so only their size
+		// is tracked.
+		t.bytes += size
+		return nil
+	}
+	if t.lru[kind] == nil {
+		t.lru[kind] = list.New()
+		t.els[kind] = make(map[uint64]*list.Element)
+	}
+
+	max := l.MaxStrings
+	if kind == stackTable {
+		max = l.MaxStacks
+	}
+	for {
+		byCount := max > 0 && t.lru[kind].Len() >= max
+		byBytes := l.MaxBytes > 0 && t.bytes+size > l.MaxBytes
+		if !byCount && !byBytes {
+			break
+		}
+		if !l.Evict {
+			return ErrTableFull
+		}
+
+		victim := kind
+		if !byCount {
+			victim = t.oldest()
+		}
+		if !tr.evict(victim) {
+			break
+		}
+	}
+
+	t.clock++
+	te := &tableEntry{kind: kind, id: id, size: size, used: t.clock}
+	t.els[kind][id] = t.lru[kind].PushFront(te)
+	t.bytes += size
+	return nil
+}
+
+// touch marks an entry as used.
+func (tr *Trace) touch(kind tableKind, id uint64) {
+	t := &tr.tables
+	if el, ok := t.els[kind][id]; ok {
+		t.clock++
+		el.Value.(*tableEntry).used = t.clock
+		t.lru[kind].MoveToFront(el)
+	}
+}
+
+// back returns the least recently used entry of a table, if any.
+func (t *tables) back(kind tableKind) *list.Element {
+	if t.lru[kind] == nil {
+		return nil
+	}
+	return t.lru[kind].Back()
+}
+
+// oldest returns the table holding the least recently used entry.
+func (t *tables) oldest() tableKind {
+	strs, stks := t.back(stringTable), t.back(stackTable)
+	switch {
+	case strs == nil:
+		return stackTable
+	case stks == nil:
+		return stringTable
+	case stks.Value.(*tableEntry).used < strs.Value.(*tableEntry).used:
+		return stackTable
+	}
+	return stringTable
+}
+
+// evict removes the least recently used entry of a table, returning false if
+// the table is empty.
+func (tr *Trace) evict(kind tableKind) bool {
+	t := &tr.tables
+	el := t.back(kind)
+	if el == nil {
+		return false
+	}
+	te := t.lru[kind].Remove(el).(*tableEntry)
+	delete(t.els[kind], te.id)
+	t.bytes -= te.size
+
+	switch kind {
+	case stringTable:
+		delete(tr.Strings, te.id)
+		delete(tr.spans, te.id)
+	case stackTable:
+		tr.unindexStack(te.id, tr.Stacks[te.id])
+		delete(tr.Stacks, te.id)
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package event
+
+import "sort"
+
+// List is a slice of events with helpers for the operations commonly performed
+// on events accumulated from a trace.
+type List []*Event
+
+// Approximate size of an Event and the pointer to it held by a List, excluding
+// the backing arrays of its Args and Data, used for accounting.
+const eventBytes = 112
+
+// SortByTime sorts the events of this list by their Ts field, breaking ties by
+// their Off field so events decoded in order retain it.
+func (l List) SortByTime() {
+	sort.SliceStable(l, func(i, j int) bool {
+		if l[i].Ts != l[j].Ts {
+			return l[i].Ts < l[j].Ts
+		}
+		return l[i].Off < l[j].Off
+	})
+}
+
+// Filter returns a new List of the events pred returns true for, such as the
+// predicates returned by ByType, ByTime and ByGoroutine.
+func (l List) Filter(pred func(evt *Event) bool) List {
+	var out List
+	for _, evt := range l {
+		if pred(evt) {
+			out = append(out, evt)
+		}
+	}
+	return out
+}
+
+// GroupBy returns the events of this list grouped by their Type, retaining
+// their order. When types are given only events of those types are grouped.
+func (l List) GroupBy(types ...Type) map[Type]List {
+	pred := func(*Event) bool { return true }
+	if len(types) > 0 {
+		pred = ByType(types...)
+	}
+	out := make(map[Type]List)
+	for _, evt := range l {
+		if pred(evt) {
+			out[evt.Type] = append(out[evt.Type], evt)
+		}
+	}
+	return out
+}
+
+// Between returns a new List of the events with a Ts field within [start, end)
+// as by ByTime, with an end of zero leaving the range unbounded.
+func (l List) Between(start, end int64) List {
+	return l.Filter(ByTime(start, end))
+}
+
+// MemoryUsage returns the approximate number of bytes retained by the events
+// of this list, including their arguments and data.
+func (l List) MemoryUsage() int {
+	n := 0
+	for _, evt := range l {
+		n += eventBytes + cap(evt.Args)*8 + cap(evt.Data)
+	}
+	return n
+}
+
+// Truncate returns the longest prefix of this list with a MemoryUsage of at
+// most maxBytes. The events beyond it are cleared from the backing array of l
+// so they may be garbage collected, or returned to a Pool by the caller
+// beforehand.
+func (l List) Truncate(maxBytes int) List {
+	n := 0
+	for i, evt := range l {
+		n += eventBytes + cap(evt.Args)*8 + cap(evt.Data)
+		if n > maxBytes {
+			for j := i; j < len(l); j++ {
+				l[j] = nil
+			}
+			return l[:i]
+		}
+	}
+	return l
+}
@@ -0,0 +1,65 @@
+package event
+
+import "sort"
+
+// WaitDist is the distribution of the times goroutines waited after blocking
+// from a single stack, in the unit of the Ts field of the events it was derived
+// from.
+type WaitDist struct {
+	StackID uint64
+	Count   int
+
+	// Time is the cumulative wait, Min and Max the shortest and longest waits
+	// and P50, P95 and P99 the respective percentiles.
+	Time, Min, Max, P50, P95, P99 int64
+
+	// Waits holds every wait in ascending order.
+	Waits []int64
+}
+
+// Quantile returns the wait at quantile q in [0, 1] using the nearest rank
+// method, or zero if there were no waits.
+func (d *WaitDist) Quantile(q float64) int64 {
+	return rank(d.Waits, q)
+}
+
+// NetWaits returns the distribution of network waits for each stack that
+// goroutines blocked on the network from, ordered by the most cumulative time
+// waited. Waits span from an EvGoBlockNet event until the goroutine is made
+// runnable by the network poller, pairing events as BlockProfile does.
+func (tr *Trace) NetWaits(evts []*Event) []WaitDist {
+	dists := make(map[uint64]*WaitDist)
+	tr.waits(evts, func(stk uint64, typ Type, d int64) {
+		if typ != EvGoBlockNet {
+			return
+		}
+		dist := dists[stk]
+		if dist == nil {
+			dist = &WaitDist{StackID: stk}
+			dists[stk] = dist
+		}
+		dist.Waits = append(dist.Waits, d)
+	})
+
+	out := make([]WaitDist, 0, len(dists))
+	for _, dist := range dists {
+		sort.Slice(dist.Waits, func(i, j int) bool {
+			return dist.Waits[i] < dist.Waits[j]
+		})
+		for _, w := range dist.Waits {
+			dist.Time += w
+		}
+		dist.Count = len(dist.Waits)
+		dist.Min, dist.Max = dist.Waits[0], dist.Waits[dist.Count-1]
+		dist.P50, dist.P95, dist.P99 =
+			dist.Quantile(.50), dist.Quantile(.95), dist.Quantile(.99)
+		out = append(out, *dist)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Time != out[j].Time {
+			return out[i].Time > out[j].Time
+		}
+		return out[i].StackID < out[j].StackID
+	})
+	return out
+}
@@ -0,0 +1,281 @@
+package event
+
+import (
+	"container/heap"
+	"errors"
+	"io"
+)
+
+// Orderer merges the per-P batches of events in a trace into a single stream
+// ordered consistently across all P's, much like the parser internal to the
+// Go runtime does.
+//
+// Timestamps of separate P's are not guaranteed to be comparable, so events
+// of Version2 and later are ordered using the sequence numbers carried by
+// goroutine state transitions, falling back to timestamps only between events
+// that have no dependency on one another. Events of Version1 are ordered by
+// the global sequence each event declares relative to its batch.
+//
+// Events must have their P and Ts fields set as they are by the Decoder, and
+// retain the arguments in the layout of the Version given to NewOrderer. All
+// events should be pushed before they are consumed with Next, an event that
+// depends on one not yet pushed will otherwise cause an error.
+type Orderer struct {
+	ver    Version
+	argoff int
+	n      uint64
+
+	// procs holds the pending events of each P in the order they were pushed,
+	// ids lists the P's in the order they were first seen.
+	procs map[int64]*orderProc
+	ids   []int64
+	gs    map[uint64]gState
+
+	// seqs holds the last sequence of each P and heap the pending events of
+	// Version1 traces.
+	seqs map[int64]uint64
+	heap orderHeap
+}
+
+// NewOrderer returns an Orderer for events of the given Version.
+func NewOrderer(v Version) *Orderer {
+	o := &Orderer{ver: v}
+	if v.Valid() {
+		o.argoff = versions[v].argOffset
+	}
+	return o
+}
+
+// Len returns the number of pending events.
+func (o *Orderer) Len() int {
+	if o.ver == Version1 {
+		return len(o.heap)
+	}
+	var n int
+	for _, p := range o.procs {
+		n += len(p.evts) - p.head
+	}
+	return n
+}
+
+// Push adds a copy of evt to the Orderer, returning false if evt is not
+// ordered. Only events that carry a timestamp are ordered, others such as
+// stacks and strings are ignored and should be handled by the caller as they
+// are decoded.
+func (o *Orderer) Push(evt *Event) bool {
+	if o.ver == Version1 && o.seqs == nil {
+		o.seqs = make(map[int64]uint64)
+	}
+	if evt.Type == EvBatch {
+		if o.ver == Version1 && len(evt.Args) > 1 {
+			o.seqs[evt.P] = evt.Args[1]
+		}
+		return false
+	}
+	if names := evt.Type.Args(); len(names) == 0 || names[0] != ArgTimestamp {
+		return false
+	}
+
+	oe := &orderEvent{Event: evt.Copy(), n: o.n}
+	o.n++
+	if o.ver == Version1 {
+		seq := o.seqs[evt.P]
+		if len(evt.Args) > 0 {
+			seq += evt.Args[0]
+		}
+		o.seqs[evt.P], oe.seq = seq, seq
+		heap.Push(&o.heap, oe)
+		return true
+	}
+
+	if o.procs == nil {
+		o.procs = make(map[int64]*orderProc)
+	}
+	p := o.procs[evt.P]
+	if p == nil {
+		p = new(orderProc)
+		o.procs[evt.P] = p
+		o.ids = append(o.ids, evt.P)
+	}
+	p.evts = append(p.evts, oe)
+	return true
+}
+
+// Next returns the next event in order, or io.EOF once no events are pending.
+// The returned event is owned by the caller.
+func (o *Orderer) Next() (*Event, error) {
+	if o.ver == Version1 {
+		if len(o.heap) == 0 {
+			return nil, io.EOF
+		}
+		return heap.Pop(&o.heap).(*orderEvent).Event, nil
+	}
+	if o.gs == nil {
+		o.gs = make(map[uint64]gState)
+	}
+
+	// Select the earliest event at the front of each P which may transition
+	// the goroutine it refers to from its current state.
+	var (
+		sel     *orderProc
+		g       uint64
+		next    gState
+		pending bool
+	)
+	for _, id := range o.ids {
+		p := o.procs[id]
+		if p.head == len(p.evts) {
+			continue
+		}
+		pending = true
+
+		oe := p.evts[p.head]
+		eg, ei, en := o.transition(oe.Event)
+		if !ready(eg, o.gs[eg], ei) {
+			continue
+		}
+		if sel != nil && !oe.less(sel.evts[sel.head]) {
+			continue
+		}
+		sel, g, next = p, eg, en
+	}
+	if !pending {
+		return nil, io.EOF
+	}
+	if sel == nil {
+		return nil, errors.New(`no consistent ordering of events possible`)
+	}
+
+	if g != unordered {
+		cur := o.gs[g]
+		switch next.seq {
+		case noseq:
+			next.seq = cur.seq
+		case seqinc:
+			next.seq = cur.seq + 1
+		}
+		o.gs[g] = next
+	}
+
+	oe := sel.evts[sel.head]
+	sel.evts[sel.head] = nil
+	if sel.head++; sel.head == len(sel.evts) {
+		sel.evts, sel.head = sel.evts[:0], 0
+	}
+	return oe.Event, nil
+}
+
+// arg returns the named argument of evt in the layout of the version.
+func (o *Orderer) arg(evt *Event, name string) uint64 {
+	return evt.arg(name, o.argoff)
+}
+
+// transition returns the goroutine evt refers to along with the state it must
+// be in before evt and the state it is in afterwards. Events which do not
+// change the state of a goroutine return unordered.
+func (o *Orderer) transition(evt *Event) (g uint64, init, next gState) {
+	switch evt.Type {
+	case EvGoCreate:
+		g = o.arg(evt, ArgNewGoroutineID)
+		init, next = gState{0, GDead}, gState{1, GRunnable}
+	case EvGoWaiting, EvGoInSyscall:
+		g = o.arg(evt, ArgGoroutineID)
+		init, next = gState{1, GRunnable}, gState{2, GWaiting}
+	case EvGoStart, EvGoStartLabel:
+		seq := o.arg(evt, ArgSequence)
+		g = o.arg(evt, ArgGoroutineID)
+		init, next = gState{seq, GRunnable}, gState{seq + 1, GRunning}
+	case EvGoStartLocal:
+		g = o.arg(evt, ArgGoroutineID)
+		init, next = gState{noseq, GRunnable}, gState{seqinc, GRunning}
+	case EvGoBlock, EvGoBlockSend, EvGoBlockRecv, EvGoBlockSelect,
+		EvGoBlockSync, EvGoBlockCond, EvGoBlockNet, EvGoSleep, EvGoSysBlock,
+		EvGoBlockGC:
+		g = uint64(evt.G)
+		init, next = gState{noseq, GRunning}, gState{noseq, GWaiting}
+	case EvGoSched, EvGoPreempt:
+		g = uint64(evt.G)
+		init, next = gState{noseq, GRunning}, gState{noseq, GRunnable}
+	case EvGoUnblock, EvGoSysExit:
+		seq := o.arg(evt, ArgSequence)
+		g = o.arg(evt, ArgGoroutineID)
+		init, next = gState{seq, GWaiting}, gState{seq + 1, GRunnable}
+	case EvGoUnblockLocal, EvGoSysExitLocal:
+		g = o.arg(evt, ArgGoroutineID)
+		init, next = gState{noseq, GWaiting}, gState{seqinc, GRunnable}
+	case EvGCStart:
+		seq := o.arg(evt, ArgSequenceGC)
+		g, init, next = garbage, gState{seq, GDead}, gState{seq + 1, GDead}
+	default:
+		g = unordered
+	}
+	return
+}
+
+// Reserved goroutine ids and sequences used while ordering, matching the
+// runtime parser.
+const (
+	unordered = ^uint64(0)     // event does not change goroutine state
+	garbage   = ^uint64(0) - 1 // pseudo goroutine ordering GC cycles
+	noseq     = ^uint64(0)     // any sequence is accepted
+	seqinc    = ^uint64(0) - 1 // sequence is incremented
+)
+
+type gState struct {
+	seq    uint64
+	status GStatus
+}
+
+// ready reports if a goroutine in state cur may make the transition beginning
+// in state init.
+func ready(g uint64, cur, init gState) bool {
+	return g == unordered ||
+		(init.seq == noseq || init.seq == cur.seq) && init.status == cur.status
+}
+
+type orderProc struct {
+	evts []*orderEvent
+	head int
+}
+
+type orderEvent struct {
+	*Event
+	seq, n uint64
+}
+
+// less orders events by timestamp, then P and finally the order they were
+// pushed.
+func (oe *orderEvent) less(other *orderEvent) bool {
+	switch {
+	case oe.Ts != other.Ts:
+		return oe.Ts < other.Ts
+	case oe.P != other.P:
+		return oe.P < other.P
+	}
+	return oe.n < other.n
+}
+
+// orderHeap orders the events of Version1 traces by sequence, then the order
+// they were pushed.
+type orderHeap []*orderEvent
+
+func (h orderHeap) Len() int { return len(h) }
+
+func (h orderHeap) Less(i, j int) bool {
+	if h[i].seq != h[j].seq {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].n < h[j].n
+}
+
+func (h orderHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *orderHeap) Push(x interface{}) { *h = append(*h, x.(*orderEvent)) }
+
+func (h *orderHeap) Pop() interface{} {
+	old := *h
+	n := len(old) - 1
+	oe := old[n]
+	old[n], *h = nil, old[:n]
+	return oe
+}
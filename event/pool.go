@@ -0,0 +1,58 @@
+package event
+
+import "sync"
+
+// Default capacities retained by the zero value of Pool.
+const (
+	defaultPoolArgs = 256
+	defaultPoolData = 4096
+)
+
+// Pool is a pool of Events for streaming pipelines which wish to reuse the Args
+// and Data of events that have been handled. Events returned to the pool are
+// Reset, with Args or Data larger than the retained capacity released so a
+// single large Stack or String event does not pin memory. The zero value
+// retains up to 256 Args and 4096 bytes of Data. It is safe for use by
+// multiple goroutines.
+type Pool struct {
+	maxArgs, maxData int
+	pool             sync.Pool
+}
+
+// NewPool returns a Pool retaining at most maxArgs Args and maxData bytes of
+// Data per event, values <= 0 select the defaults of the zero value.
+func NewPool(maxArgs, maxData int) *Pool {
+	return &Pool{maxArgs: maxArgs, maxData: maxData}
+}
+
+// Get returns a reset Event from the pool, or a new Event if it is empty.
+func (p *Pool) Get() *Event {
+	if evt, ok := p.pool.Get().(*Event); ok {
+		return evt
+	}
+	return new(Event)
+}
+
+// Put resets evt and returns it to the pool, it must not be used after Put
+// returns.
+func (p *Pool) Put(evt *Event) {
+	if evt == nil {
+		return
+	}
+
+	maxArgs, maxData := p.maxArgs, p.maxData
+	if maxArgs <= 0 {
+		maxArgs = defaultPoolArgs
+	}
+	if maxData <= 0 {
+		maxData = defaultPoolData
+	}
+	if cap(evt.Args) > maxArgs {
+		evt.Args = nil
+	}
+	if cap(evt.Data) > maxData {
+		evt.Data = nil
+	}
+	evt.Reset()
+	p.pool.Put(evt)
+}
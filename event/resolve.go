@@ -0,0 +1,73 @@
+package event
+
+import "time"
+
+// ResolvedEvent bundles an event with the strings and stacks it refers to and
+// its wall clock time, so it may be rendered without access to a Trace. See
+// the Resolve method of Trace.
+type ResolvedEvent struct {
+	Event *Event
+
+	// Time is the wall clock time of the event as returned by the Time method
+	// of Trace, the zero time when the frequency of the trace is unknown or the
+	// event has no timestamp.
+	Time time.Time
+
+	// Stack is the stack of the event and NewStack the stack an EvGoCreate
+	// event started the new goroutine with, each nil when not recorded or
+	// not found in the Trace.
+	Stack, NewStack Stack
+
+	// Strings holds the strings the event refers to keyed by the name of the
+	// argument holding their id, such as the label of EvGoStartLabel or the
+	// key of EvUserLog. Strings which can not be found are omitted.
+	Strings map[string]string
+
+	// Value is the payload of EvString and EvUserLog events, read from the
+	// Source of the Trace when it was decoded lazily.
+	Value string
+}
+
+// Resolve returns evt along with the strings and stacks it refers to and its
+// wall clock time. The arguments of evt are read in the layout of the Version
+// of this Trace, which should have visited every event beforehand.
+func (tr *Trace) Resolve(evt *Event) ResolvedEvent {
+	re := ResolvedEvent{Event: evt}
+	if evt.Ts != 0 {
+		re.Time = tr.Time(evt)
+	}
+
+	var argoff int
+	if tr.Version.Valid() {
+		argoff = versions[tr.Version].argOffset
+	}
+	for i, kind := range evt.Type.ArgKinds() {
+		if i+argoff >= len(evt.Args) {
+			break
+		}
+		name, arg := evt.Type.Args()[i], evt.Args[i+argoff]
+		switch {
+		case evt.Type == EvString || evt.Type == EvStack:
+		case kind == KindString:
+			if s, err := tr.getString(arg); err == nil {
+				if re.Strings == nil {
+					re.Strings = make(map[string]string)
+				}
+				re.Strings[name] = s
+			}
+		case name == ArgStackID && arg != 0:
+			re.Stack, _ = tr.getStack(arg)
+		case name == ArgNewStackID && arg != 0 && tr.Version != Version1:
+			re.NewStack, _ = tr.getStack(arg)
+		}
+	}
+
+	switch {
+	case evt.Type != EvString && evt.Type != EvUserLog:
+	case len(evt.Data) > 0:
+		re.Value = string(evt.Data)
+	case evt.Span.Len > 0:
+		re.Value, _ = tr.readSpan(evt.Span)
+	}
+	return re
+}
@@ -0,0 +1,78 @@
+package event
+
+import (
+	"sync"
+	"time"
+)
+
+// SafeTrace wraps a Trace so it may be used by multiple goroutines, such as
+// decoders visiting events of separate batches into shared state. Each method
+// holds a lock for the duration of the call to the wrapped Trace.
+//
+// Frames of the stacks returned from SafeTrace resolve their names from the
+// wrapped Trace without holding the lock, use Do to resolve them while other
+// goroutines may be visiting events.
+type SafeTrace struct {
+	mu sync.Mutex
+	tr *Trace
+}
+
+// NewSafeTrace returns a SafeTrace wrapping tr, which must not be used
+// directly while the SafeTrace is in use.
+func NewSafeTrace(tr *Trace) *SafeTrace {
+	return &SafeTrace{tr: tr}
+}
+
+// Do calls fn with the wrapped Trace while holding the lock, returning the
+// error from fn.
+func (st *SafeTrace) Do(fn func(tr *Trace) error) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return fn(st.tr)
+}
+
+// Visit the given event with the wrapped Trace.
+func (st *SafeTrace) Visit(evt *Event) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.tr.Visit(evt)
+}
+
+// Stack returns the Stack trace associated with the given event, see the Stack
+// method of Trace.
+func (st *SafeTrace) Stack(evt *Event) (Stack, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.tr.Stack(evt)
+}
+
+// GetString returns the string for the given string id, see the GetString
+// method of Trace.
+func (st *SafeTrace) GetString(id uint64) (string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.tr.GetString(id)
+}
+
+// Time returns the wall clock time of evt, see the Time method of Trace.
+func (st *SafeTrace) Time(evt *Event) time.Time {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.tr.Time(evt)
+}
+
+// Snapshot returns the state accumulated by the wrapped Trace, see the
+// Snapshot method of Trace.
+func (st *SafeTrace) Snapshot() ([]byte, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.tr.Snapshot()
+}
+
+// MemoryUsage returns the approximate number of bytes held by the tables of
+// the wrapped Trace.
+func (st *SafeTrace) MemoryUsage() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.tr.MemoryUsage()
+}
@@ -0,0 +1,96 @@
+package event
+
+import "sort"
+
+// SchedLatency is a time a goroutine waited to run for longer than a threshold,
+// see the SchedLatencies method of Trace. Times are in the unit of the Ts field
+// of the events they were derived from.
+type SchedLatency struct {
+	G uint64
+
+	// Type is the EvGoCreate, EvGoUnblock or EvGoUnblockLocal event which made
+	// the goroutine runnable and StackID the stack it was emitted with, which
+	// may be zero when stacks were not recorded.
+	Type    Type
+	StackID uint64
+
+	// Ts is the time the goroutine became runnable and Latency the time until
+	// it started running.
+	Ts, Latency int64
+
+	// WakeP is the P the goroutine was made runnable on and StartP the P it
+	// started running on.
+	WakeP, StartP int64
+
+	// Gomaxprocs is the value of GOMAXPROCS and Running the number of Ps
+	// running goroutines once the goroutine became runnable, Running at or
+	// above Gomaxprocs indicates the Ps were exhausted. Gomaxprocs is zero
+	// when the trace did not declare it.
+	Gomaxprocs uint64
+	Running    int
+
+	// Syscalls is the number of system calls which blocked while the
+	// goroutine waited, each of which held its P until it was retaken.
+	Syscalls int
+}
+
+// SchedLatencies returns each time a goroutine within evts waited longer than
+// threshold from becoming runnable until it started running, in the order they
+// became runnable. It surfaces starvation from long system calls and from the
+// exhaustion of GOMAXPROCS with the state of the Ps during each wait. Like
+// Goroutines, evts must be ordered as they are by an Orderer and retain the
+// arguments in the layout of the Version of this Trace.
+func (tr *Trace) SchedLatencies(evts []*Event, threshold int64) []SchedLatency {
+	var argoff int
+	if tr.Version.Valid() {
+		argoff = versions[tr.Version].argOffset
+	}
+
+	var (
+		out      []SchedLatency
+		procs    uint64
+		syscalls int
+		running  = make(map[int64]bool)
+		runnable = make(map[uint64]*SchedLatency)
+	)
+	wake := func(g uint64, evt *Event) {
+		runnable[g] = &SchedLatency{
+			G: g, Type: evt.Type, StackID: evt.arg(ArgStackID, argoff),
+			Ts: evt.Ts, WakeP: evt.P, Gomaxprocs: procs,
+			Running: len(running), Syscalls: syscalls}
+	}
+	for _, evt := range evts {
+		switch evt.Type {
+		case EvGomaxprocs:
+			procs = evt.arg(ArgGomaxprocs, argoff)
+		case EvGoCreate:
+			wake(evt.arg(ArgNewGoroutineID, argoff), evt)
+		case EvGoUnblock, EvGoUnblockLocal:
+			wake(evt.arg(ArgGoroutineID, argoff), evt)
+		case EvGoStart, EvGoStartLocal, EvGoStartLabel:
+			running[evt.P] = true
+			g := evt.arg(ArgGoroutineID, argoff)
+			sl, ok := runnable[g]
+			if !ok {
+				break
+			}
+			delete(runnable, g)
+			if sl.Latency = evt.Ts - sl.Ts; sl.Latency > threshold {
+				sl.StartP, sl.Syscalls = evt.P, syscalls-sl.Syscalls
+				out = append(out, *sl)
+			}
+		case EvGoSysBlock:
+			syscalls++
+			delete(running, evt.P)
+		case EvGoEnd, EvGoStop, EvGoSched, EvGoPreempt, EvGoSleep, EvGoBlock,
+			EvGoBlockSend, EvGoBlockRecv, EvGoBlockSelect, EvGoBlockSync,
+			EvGoBlockCond, EvGoBlockNet, EvGoBlockGC, EvProcStop:
+			delete(running, evt.P)
+		}
+	}
+
+	// Goroutines are appended as they start, so restore the order they became
+	// runnable in.
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Ts < out[j].Ts })
+	return out
+}
@@ -0,0 +1,164 @@
+package event
+
+// Arguments which exist only in the layout of Version1, see Schemas.
+const (
+	ArgSequenceDelta = `SequenceDelta`
+	ArgUnused        = `Unused`
+)
+
+// Schema describes the arguments of a type of event in the layout of a single
+// Version, so tools may locate arguments by name rather than hard-coding their
+// positions for each version.
+type Schema struct {
+	Type Type
+	Name string
+
+	// Since is the version the type was introduced in and RemovedIn the
+	// version it was removed in, or zero if it has not been removed.
+	Since, RemovedIn Version
+
+	// Args are the names of the arguments in the order they are encoded and
+	// Kinds the kind of each of them, see the ArgKinds method of Type.
+	Args  []string
+	Kinds []Kind
+
+	// FrameSize is the number of arguments of each frame following Args for
+	// EvStack events, or zero for every other type.
+	FrameSize int
+}
+
+// Schemas returns the schema of each type of event in version v in the order
+// of their Type, or nil if v is invalid. EvNone is not included. The returned
+// value may be mutated by the caller.
+//
+// The layout of Version2 and later matches the Args method of Type, except for
+// EvGCSTWStart events which omit ArgKind prior to Version5. Events of Version1
+// are instead preceded by an ArgSequenceDelta argument, with the
+// sequence of the batch following the processor of EvBatch events. Only the
+// EvGoSysExit events of Version1 carry an ArgSequence argument and none carry
+// ArgSequenceGC. EvFrequency and EvTimerGoroutine events are followed by an
+// ArgUnused argument and each frame of a stack holds only the PC.
+func Schemas(v Version) []Schema {
+	if !v.Valid() {
+		return nil
+	}
+	out := make([]Schema, 0, len(versions[v].types))
+	for _, typ := range versions[v].types {
+		if typ == EvNone {
+			continue
+		}
+		s := Schema{
+			Type: typ, Name: typ.Name(), Since: typ.Since(),
+			RemovedIn: typ.RemovedIn(), Args: schemaArgs(v, typ)}
+		s.Kinds = make([]Kind, len(s.Args))
+		for i, name := range s.Args {
+			s.Kinds[i] = argKinds[name]
+		}
+		if typ == EvStack {
+			s.FrameSize = versions[v].frameSize
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// schemaArgs returns the names of the arguments of typ in the layout of v.
+func schemaArgs(v Version, typ Type) []string {
+	args := typ.Args()
+	if typ == EvGCSTWStart && v < Version5 {
+		args = args[:1]
+	}
+	if v != Version1 {
+		return append([]string(nil), args...)
+	}
+	switch typ {
+	case EvBatch:
+		return []string{ArgProcessorID, ArgSequence, ArgTimestamp}
+	case EvFrequency, EvTimerGoroutine:
+		return append(append([]string(nil), args...), ArgUnused)
+	case EvStack:
+		return append([]string(nil), args...)
+	}
+	out := []string{ArgSequenceDelta}
+	for _, name := range args {
+		// Only the sequence of EvGoSysExit was recorded by Go 1.5.
+		if typ == EvGoSysExit || (name != ArgSequence && name != ArgSequenceGC) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// layouts holds the layout of the arguments of each type of event in each
+// version, see the typed accessors of Event.
+var layouts [versionsCount][EvCount]layout
+
+// layout locates the arguments named by the Args method of a Type within the
+// Args of its events in a single version. Each position in idx is the index of
+// the argument, or -1 if it is absent from the version, and n is the number of
+// arguments encoded.
+type layout struct {
+	idx []int
+	n   int
+}
+
+func init() {
+	for v := Version1; v < versionsCount; v++ {
+		for typ := EvNone + 1; typ < EvCount; typ++ {
+			args := schemaArgs(v, typ)
+			l := layout{idx: make([]int, len(typ.Args())), n: len(args)}
+			for i, name := range typ.Args() {
+				l.idx[i] = -1
+				for j := range args {
+					if args[j] == name {
+						l.idx[i] = j
+						break
+					}
+				}
+			}
+			layouts[v][typ] = l
+		}
+	}
+}
+
+// layout returns the index of each argument of typ within e.Args in the layout
+// of v, ok is false if e is another type of event or has too few arguments.
+func (e *Event) layout(v Version, typ Type) (idx []int, ok bool) {
+	if e.Type != typ || !v.Valid() || !typ.Valid() {
+		return nil, false
+	}
+	l := &layouts[v][typ]
+	return l.idx, len(e.Args) >= l.n
+}
+
+// at returns the argument at the i'th position of idx, or zero if it is absent.
+func (e *Event) at(idx []int, i int) uint64 {
+	if idx[i] < 0 {
+		return 0
+	}
+	return e.Args[idx[i]]
+}
+
+// argIn returns the named argument of e in the layout of v, or zero if it is
+// absent from the version or e has too few arguments.
+func (e *Event) argIn(v Version, name string) uint64 {
+	i, ok := e.Type.Arg(name)
+	if !ok || !v.Valid() {
+		return 0
+	}
+	idx := layouts[v][e.Type].idx
+	if i >= len(idx) || idx[i] < 0 || idx[i] >= len(e.Args) {
+		return 0
+	}
+	return e.Args[idx[i]]
+}
+
+// hasArg reports if the named argument of typ is present in the layout of v.
+func hasArg(v Version, typ Type, name string) bool {
+	i, ok := typ.Arg(name)
+	if !ok || !v.Valid() {
+		return false
+	}
+	idx := layouts[v][typ].idx
+	return i < len(idx) && idx[i] >= 0
+}
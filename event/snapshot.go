@@ -0,0 +1,178 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// snapshotFormat is incremented when the representation of a snapshot changes
+// in a way older versions of this package could not restore.
+const snapshotFormat = 1
+
+// jsonSnapshot is the representation of a Trace returned by Snapshot.
+type jsonSnapshot struct {
+	Format    int                        `json:"format"`
+	Version   Version                    `json:"version"`
+	Count     int                        `json:"count"`
+	Frequency uint64                     `json:"frequency,omitempty"`
+	Anchor    *jsonAnchor                `json:"anchor,omitempty"`
+	Bounds    *[2]int64                  `json:"bounds,omitempty"`
+	Gs        map[uint64]jsonG           `json:"goroutines,omitempty"`
+	Strings   map[uint64]string          `json:"strings,omitempty"`
+	Spans     map[uint64]jsonSpan        `json:"spans,omitempty"`
+	Stacks    map[uint64][]snapshotFrame `json:"stacks,omitempty"`
+}
+
+type jsonAnchor struct {
+	Time  time.Time `json:"time"`
+	Ticks int64     `json:"ticks"`
+}
+
+type jsonG struct {
+	Parent      uint64  `json:"parent,omitempty"`
+	CreateStack uint64  `json:"createStack,omitempty"`
+	NewStack    uint64  `json:"newStack,omitempty"`
+	Label       uint64  `json:"label,omitempty"`
+	Created     int64   `json:"created"`
+	Started     int64   `json:"started,omitempty"`
+	Ended       int64   `json:"ended,omitempty"`
+	End         Type    `json:"end,omitempty"`
+	Initial     GStatus `json:"initial,omitempty"`
+	Syscall     bool    `json:"syscall,omitempty"`
+	Timer       bool    `json:"timer,omitempty"`
+}
+
+// snapshotFrame holds the string ids of a frame decoded from a trace, or the
+// names of a frame created with NewFrame.
+type snapshotFrame struct {
+	Decoded  bool   `json:"decoded,omitempty"`
+	PC       uint64 `json:"pc"`
+	Fn       uint64 `json:"fn,omitempty"`
+	File     uint64 `json:"file,omitempty"`
+	Line     int    `json:"line"`
+	FuncName string `json:"funcName,omitempty"`
+	FileName string `json:"fileName,omitempty"`
+}
+
+// Snapshot returns the state accumulated by this Trace from the events it has
+// visited, including the strings, stacks and frequency of the trace. It may be
+// given to RestoreSnapshot to resume visiting events where this Trace left off,
+// such as after a restart or for a segment of the trace that no longer holds
+// the events declaring them. Strings decoded lazily remain spans of Source,
+// which must be assigned again after restoring.
+func (tr *Trace) Snapshot() ([]byte, error) {
+	snap := jsonSnapshot{
+		Format:    snapshotFormat,
+		Version:   tr.Version,
+		Count:     tr.Count,
+		Frequency: tr.Frequency,
+		Strings:   tr.Strings,
+	}
+	if tr.anchored || !tr.Anchor.Time.IsZero() {
+		snap.Anchor = &jsonAnchor{Time: tr.Anchor.Time, Ticks: tr.Anchor.Ticks}
+	}
+	if tr.timed {
+		snap.Bounds = &[2]int64{tr.start, tr.end}
+	}
+	if len(tr.gs) > 0 {
+		snap.Gs = make(map[uint64]jsonG, len(tr.gs))
+		for id, g := range tr.gs {
+			snap.Gs[id] = jsonG{
+				Parent: g.parent, CreateStack: g.createStack, NewStack: g.newStack,
+				Label: g.label, Created: g.created, Started: g.started,
+				Ended: g.ended, End: g.end, Initial: g.initial, Syscall: g.syscall,
+				Timer: g.timer}
+		}
+	}
+	if len(tr.spans) > 0 {
+		snap.Spans = make(map[uint64]jsonSpan, len(tr.spans))
+		for id, sp := range tr.spans {
+			snap.Spans[id] = jsonSpan{Off: sp.Off, Len: sp.Len}
+		}
+	}
+	if len(tr.Stacks) > 0 {
+		snap.Stacks = make(map[uint64][]snapshotFrame, len(tr.Stacks))
+		for id, stk := range tr.Stacks {
+			frames := make([]snapshotFrame, len(stk))
+			for i, f := range stk {
+				frames[i] = snapshotFrame{
+					Decoded: f.tr != nil, PC: f.pc, Fn: f.fn, File: f.file,
+					Line: f.line}
+				if f.names != nil {
+					frames[i].FuncName, frames[i].FileName = f.names.fn, f.names.file
+				}
+			}
+			snap.Stacks[id] = frames
+		}
+	}
+	return json.Marshal(snap)
+}
+
+// RestoreSnapshot resets this Trace to the state held by a snapshot returned
+// from Snapshot. The Source, Limits, Symbolizer and Lenient fields of the Trace
+// are retained.
+func (tr *Trace) RestoreSnapshot(b []byte) error {
+	var snap jsonSnapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return err
+	}
+	if snap.Format != snapshotFormat {
+		return fmt.Errorf(`snapshot format %v is not supported`, snap.Format)
+	}
+	if !snap.Version.Valid() {
+		return errors.New(`snapshot has an invalid version`)
+	}
+
+	src := tr.Source
+	tr.Reset()
+	tr.Version, tr.Count, tr.Frequency, tr.Source =
+		snap.Version, snap.Count, snap.Frequency, src
+	if err := tr.init(); err != nil {
+		return err
+	}
+	if snap.Anchor != nil {
+		tr.Anchor = Anchor{Time: snap.Anchor.Time, Ticks: snap.Anchor.Ticks}
+		tr.anchored = true
+	}
+	if snap.Bounds != nil {
+		tr.start, tr.end, tr.timed = snap.Bounds[0], snap.Bounds[1], true
+	}
+	for id, jg := range snap.Gs {
+		if tr.gs == nil {
+			tr.gs = make(map[uint64]gInfo, len(snap.Gs))
+		}
+		tr.gs[id] = gInfo{
+			parent: jg.Parent, createStack: jg.CreateStack, newStack: jg.NewStack,
+			label: jg.Label, created: jg.Created, started: jg.Started,
+			ended: jg.Ended, end: jg.End, initial: jg.Initial, syscall: jg.Syscall,
+			timer: jg.Timer}
+	}
+	for id, s := range snap.Strings {
+		if err := tr.addString(id, s); err != nil {
+			return err
+		}
+	}
+	for id, sp := range snap.Spans {
+		if err := tr.addSpan(id, Span{Off: sp.Off, Len: sp.Len}); err != nil {
+			return err
+		}
+	}
+	for id, frames := range snap.Stacks {
+		stk := make(Stack, len(frames))
+		for i, f := range frames {
+			stk[i] = Frame{pc: f.PC, fn: f.Fn, file: f.File, line: f.Line}
+			if f.FuncName != `` || f.FileName != `` {
+				stk[i].names = &frameNames{fn: f.FuncName, file: f.FileName}
+			}
+			if f.Decoded {
+				stk[i].tr = tr
+			}
+		}
+		if err := tr.addStack(id, stk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package event
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// StackStyle selects the layout produced by the Format method of Stack.
+type StackStyle int
+
+// Styles of formatting a Stack, see StackFormat.
+const (
+
+	// StackShort formats a stack on a single line as each function and line
+	// from the innermost frame outwards, such as:
+	//
+	//   main.worker:12 < main.main:7
+	StackShort StackStyle = iota
+
+	// StackLong formats a stack over multiple lines like the stack of a
+	// goroutine dump, with the function of each frame followed by an indented
+	// line holding its file, line and program counter.
+	StackLong
+
+	// StackJSON formats a stack as a JSON array of frame objects with the pc,
+	// func, file and line of each, as used by the MarshalEvent method of Trace.
+	StackJSON
+)
+
+// StackFormat configures the Format method of Stack.
+type StackFormat struct {
+
+	// Style is the layout of the formatted stack.
+	Style StackStyle
+
+	// Depth limits the number of innermost frames formatted, or formats all
+	// frames when zero, see the Top method of Stack.
+	Depth int
+
+	// TrimRuntime omits the frames of the runtime package, see the TrimRuntime
+	// method of Stack.
+	TrimRuntime bool
+}
+
+// Format returns the frames of this stack in the layout described by opts. An
+// empty stack is formatted as an empty string, or "[]" for StackJSON.
+func (s Stack) Format(opts StackFormat) string {
+	if opts.TrimRuntime {
+		s = s.TrimRuntime()
+	}
+	if opts.Depth > 0 {
+		s = s.Top(opts.Depth)
+	}
+
+	var buf bytes.Buffer
+	switch opts.Style {
+	case StackLong:
+		for _, f := range s {
+			fmt.Fprintf(&buf, "%v(...)\n\t%v:%v pc=0x%x\n",
+				f.Func(), f.File(), f.Line(), f.PC())
+		}
+	case StackJSON:
+		frames := make([]jsonFrame, 0, len(s))
+		for _, f := range s {
+			frames = append(frames, jsonFrame{
+				PC: f.PC(), Func: f.Func(), File: f.File(), Line: f.Line()})
+		}
+		b, _ := json.Marshal(frames)
+		buf.Write(b)
+	default:
+		for i, f := range s {
+			if i > 0 {
+				buf.WriteString(` < `)
+			}
+			fmt.Fprintf(&buf, `%v:%v`, f.Func(), f.Line())
+		}
+	}
+	return buf.String()
+}
+
+// Top returns the innermost n frames of this stack, or the entire stack when
+// it has n or fewer frames. The returned Stack shares frames with s.
+func (s Stack) Top(n int) Stack {
+	if n < 0 {
+		n = 0
+	}
+	if n >= len(s) {
+		return s
+	}
+	return s[:n:n]
+}
+
+// TrimRuntime returns this stack without the frames of functions within the
+// runtime package, such as runtime.gopark at the top of blocked goroutines and
+// runtime.goexit at their base. Frames of its sub packages such as runtime/trace
+// are retained. The frames of s are not modified.
+func (s Stack) TrimRuntime() Stack {
+	out := make(Stack, 0, len(s))
+	for _, f := range s {
+		if !strings.HasPrefix(f.Func(), `runtime.`) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
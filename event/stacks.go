@@ -0,0 +1,201 @@
+package event
+
+import (
+	"regexp"
+	"sort"
+)
+
+// stackIndex groups the ids of stacks by a hash of their frames, so identical
+// stacks declared under different ids share a single Stack. The first id of
+// each hash is held in byHash and any later ids, which are rare as the runtime
+// declares each stack once, in more.
+type stackIndex struct {
+	byHash map[uint64]uint64
+	more   map[uint64][]uint64
+}
+
+// FNV-1a parameters, see hash/fnv.
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+func hashUint(h, v uint64) uint64 {
+	for i := 0; i < 8; i++ {
+		h = (h ^ (v & 0xff)) * fnvPrime
+		v >>= 8
+	}
+	return h
+}
+
+func hashString(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h = (h ^ uint64(s[i])) * fnvPrime
+	}
+	return hashUint(h, uint64(len(s)))
+}
+
+func hashStack(stk Stack) uint64 {
+	h := uint64(fnvOffset)
+	for _, f := range stk {
+		h = hashUint(h, f.pc)
+		h = hashUint(h, f.fn)
+		h = hashUint(h, f.file)
+		h = hashUint(h, uint64(f.line))
+		if f.names != nil {
+			h = hashString(h, f.names.fn)
+			h = hashString(h, f.names.file)
+		}
+	}
+	return h
+}
+
+func equalFrame(a, b Frame) bool {
+	if a.tr != b.tr || a.pc != b.pc || a.fn != b.fn || a.file != b.file ||
+		a.line != b.line {
+		return false
+	}
+	if a.names == nil || b.names == nil {
+		return a.names == b.names
+	}
+	return *a.names == *b.names
+}
+
+func equalStack(a, b Stack) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equalFrame(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ids calls fn with each id indexed under hash h in the order they were
+// indexed until it returns false.
+func (idx *stackIndex) ids(h uint64, fn func(id uint64) bool) {
+	first, ok := idx.byHash[h]
+	if !ok || !fn(first) {
+		return
+	}
+	for _, id := range idx.more[h] {
+		if !fn(id) {
+			return
+		}
+	}
+}
+
+// dedupStack returns a previously indexed Stack identical to stk along with a
+// boolean true, or stk and false if there is none. In both cases id is indexed.
+func (tr *Trace) dedupStack(id uint64, stk Stack) (Stack, bool) {
+	idx := &tr.stackIndex
+	if idx.byHash == nil {
+		idx.byHash = make(map[uint64]uint64)
+	}
+
+	h := hashStack(stk)
+	shared, found := stk, false
+	idx.ids(h, func(other uint64) bool {
+		if prev, ok := tr.Stacks[other]; ok && equalStack(prev, stk) {
+			shared, found = prev, true
+		}
+		return !found
+	})
+	if _, ok := idx.byHash[h]; !ok {
+		idx.byHash[h] = id
+	} else {
+		if idx.more == nil {
+			idx.more = make(map[uint64][]uint64)
+		}
+		idx.more[h] = append(idx.more[h], id)
+	}
+	return shared, found
+}
+
+// unindexStack removes id, which was indexed with the frames of stk, from the
+// stack index.
+func (tr *Trace) unindexStack(id uint64, stk Stack) {
+	idx := &tr.stackIndex
+	h := hashStack(stk)
+	first, ok := idx.byHash[h]
+	if !ok {
+		return
+	}
+
+	ids := idx.more[h]
+	if first == id {
+		if len(ids) == 0 {
+			delete(idx.byHash, h)
+			return
+		}
+		idx.byHash[h], ids = ids[0], ids[1:]
+	} else {
+		for i, other := range ids {
+			if other == id {
+				ids = append(ids[:i], ids[i+1:]...)
+				break
+			}
+		}
+	}
+	if len(ids) == 0 {
+		delete(idx.more, h)
+	} else {
+		idx.more[h] = ids
+	}
+}
+
+// CanonicalStackID returns the first id declared for a stack with frames
+// identical to the stack of the given id. Identical stacks declared under
+// different ids share the same Stack, the id itself is returned for stacks
+// that are unique or unknown.
+func (tr *Trace) CanonicalStackID(id uint64) uint64 {
+	stk, ok := tr.Stacks[id]
+	if !ok {
+		return id
+	}
+	canon := id
+	tr.stackIndex.ids(hashStack(stk), func(other uint64) bool {
+		if other == id {
+			return false
+		}
+		if prev, ok := tr.Stacks[other]; ok && equalStack(prev, stk) {
+			canon = other
+			return false
+		}
+		return true
+	})
+	return canon
+}
+
+// StacksByFunc returns the ids of every stack with a frame whose function
+// matches the regular expression pattern, in ascending order. For example the
+// pattern `^net/http\.` finds the stacks passing through package net/http.
+func (tr *Trace) StacksByFunc(pattern string) ([]uint64, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// Identical stacks are only matched once.
+	memo := make(map[uint64]bool)
+	var ids []uint64
+	for id, stk := range tr.Stacks {
+		canon := tr.CanonicalStackID(id)
+		match, ok := memo[canon]
+		if !ok {
+			for _, f := range stk {
+				if match = re.MatchString(f.Func()); match {
+					break
+				}
+			}
+			memo[canon] = match
+		}
+		if match {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
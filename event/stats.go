@@ -0,0 +1,243 @@
+package event
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// StatsVisitor is a Visitor which accumulates statistics about the events of a
+// trace, such as the number and size of each type of event and how frequently
+// they occur. See the Report method for retrieving them.
+type StatsVisitor struct {
+
+	// Frequency is the number of units per second of the Ts field of visited
+	// events, used for EventsPerSecond. When zero it is taken from the first
+	// EvFrequency event visited, which declares CPU ticks per second, so it
+	// must be set to 1e9 for events decoded with nanosecond timestamps.
+	Frequency uint64
+
+	argoff int
+	events int
+	bytes  int
+	buf    []byte
+
+	timed             bool
+	first, last, prev int64
+	arrivals          Histogram
+	types             [EvCount]*typeStats
+}
+
+type typeStats struct {
+	events, bytes int
+	prev          int64
+	arrivals      Histogram
+	args          map[string]*Histogram
+}
+
+// NewStatsVisitor returns a StatsVisitor for events with arguments in the
+// layout of the given version, or an error if the version is unknown.
+func NewStatsVisitor(v Version) (*StatsVisitor, error) {
+	if !v.Valid() {
+		return nil, fmt.Errorf(`Version %v is unknown`, v)
+	}
+	return &StatsVisitor{argoff: versions[v].argOffset}, nil
+}
+
+// Visit implements Visitor. Events are expected in the order they are decoded
+// or as ordered by an Orderer, inter-arrival times of events visited out of
+// order are counted as zero. Events of an invalid type are ignored.
+func (sv *StatsVisitor) Visit(evt *Event) error {
+	if !evt.Type.Valid() {
+		return nil
+	}
+	ts := sv.types[evt.Type]
+	if ts == nil {
+		ts = &typeStats{args: make(map[string]*Histogram)}
+		sv.types[evt.Type] = ts
+	}
+
+	size := sv.size(evt)
+	sv.events, sv.bytes = sv.events+1, sv.bytes+size
+	ts.events, ts.bytes = ts.events+1, ts.bytes+size
+
+	if evt.Type == EvFrequency && sv.Frequency == 0 && len(evt.Args) > 0 {
+		sv.Frequency = evt.Args[0]
+	}
+
+	// Only arguments holding quantities are meaningful to distribute.
+	for i, kind := range evt.Type.ArgKinds() {
+		switch kind {
+		case KindBytes, KindCount, KindEnum:
+		default:
+			continue
+		}
+		name := evt.Type.Args()[i]
+		if i+sv.argoff >= len(evt.Args) {
+			continue
+		}
+		h := ts.args[name]
+		if h == nil {
+			h = new(Histogram)
+			ts.args[name] = h
+		}
+		h.Add(evt.arg(name, sv.argoff))
+	}
+
+	// Events without a timestamp, such as strings and stacks, are not timed.
+	if evt.Ts == 0 {
+		return nil
+	}
+	if !sv.timed {
+		sv.first, sv.last, sv.timed = evt.Ts, evt.Ts, true
+	} else {
+		sv.arrivals.Add(delta(sv.prev, evt.Ts))
+	}
+	if ts.prev != 0 {
+		ts.arrivals.Add(delta(ts.prev, evt.Ts))
+	}
+	if evt.Ts < sv.first {
+		sv.first = evt.Ts
+	}
+	if evt.Ts > sv.last {
+		sv.last = evt.Ts
+	}
+	sv.prev = evt.Ts
+	ts.prev = evt.Ts
+	return nil
+}
+
+// size returns the number of bytes evt occupies when encoded by MarshalBinary,
+// including the payload of lazily decoded strings.
+func (sv *StatsVisitor) size(evt *Event) int {
+	b, err := evt.AppendBinary(sv.buf[:0])
+	if err != nil {
+		return 0
+	}
+	sv.buf = b
+	size := len(b)
+	if len(evt.Data) == 0 && evt.Span.Len > 0 {
+		size += len(appendUleb(b[:0], uint64(evt.Span.Len))) - 1 + evt.Span.Len
+	}
+	return size
+}
+
+func delta(from, to int64) uint64 {
+	if to < from {
+		return 0
+	}
+	return uint64(to - from)
+}
+
+// Report returns the statistics accumulated so far. The returned value is a
+// copy and is not updated by future calls to Visit.
+func (sv *StatsVisitor) Report() *StatsReport {
+	r := &StatsReport{
+		Events:       sv.events,
+		Bytes:        sv.bytes,
+		Frequency:    sv.Frequency,
+		Interarrival: sv.arrivals.clone(),
+		Types:        make(map[string]TypeReport),
+	}
+	if sv.timed {
+		r.Duration = sv.last - sv.first
+	}
+	if r.Duration > 0 && r.Frequency > 0 {
+		r.EventsPerSecond = float64(r.Events) /
+			(float64(r.Duration) / float64(r.Frequency))
+	}
+	for typ, ts := range sv.types {
+		if ts == nil {
+			continue
+		}
+		tr := TypeReport{Events: ts.events, Bytes: ts.bytes,
+			Interarrival: ts.arrivals.clone()}
+		if len(ts.args) > 0 {
+			tr.Args = make(map[string]Histogram, len(ts.args))
+			for name, h := range ts.args {
+				tr.Args[name] = h.clone()
+			}
+		}
+		r.Types[Type(typ).Name()] = tr
+	}
+	return r
+}
+
+// StatsReport holds the statistics accumulated by a StatsVisitor, it may be
+// marshaled as JSON.
+type StatsReport struct {
+
+	// Events and Bytes are the totals of all events, with Bytes being their
+	// size when encoded in the latest version of the trace format.
+	Events int `json:"events"`
+	Bytes  int `json:"bytes"`
+
+	// Duration is the time from the earliest to the latest event in the unit
+	// of the Ts field of the events, Frequency the number of those units per
+	// second and EventsPerSecond is zero when either is unknown.
+	Duration        int64   `json:"duration"`
+	Frequency       uint64  `json:"frequency,omitempty"`
+	EventsPerSecond float64 `json:"eventsPerSecond,omitempty"`
+
+	// Interarrival is the distribution of time between consecutive events.
+	Interarrival Histogram `json:"interarrival"`
+
+	// Types holds the statistics of each type of event by its name.
+	Types map[string]TypeReport `json:"types"`
+}
+
+// TypeReport holds the statistics for events of a single type.
+type TypeReport struct {
+	Events int `json:"events"`
+	Bytes  int `json:"bytes"`
+
+	// Interarrival is the distribution of time between consecutive events of
+	// this type.
+	Interarrival Histogram `json:"interarrival"`
+
+	// Args holds the distribution of each argument holding a quantity, those
+	// of KindBytes, KindCount and KindEnum, by name.
+	Args map[string]Histogram `json:"args,omitempty"`
+}
+
+// Histogram is a distribution of values in power of two buckets.
+type Histogram struct {
+	Count int    `json:"count"`
+	Sum   uint64 `json:"sum"`
+	Min   uint64 `json:"min"`
+	Max   uint64 `json:"max"`
+
+	// Buckets counts values by their bit length, with Buckets[0] counting
+	// zeros and Buckets[i] values within [1<<(i-1), 1<<i).
+	Buckets []int `json:"buckets"`
+}
+
+// Add records v within the histogram.
+func (h *Histogram) Add(v uint64) {
+	if h.Count == 0 || v < h.Min {
+		h.Min = v
+	}
+	if v > h.Max {
+		h.Max = v
+	}
+	h.Count++
+	h.Sum += v
+
+	i := bits.Len64(v)
+	if i >= len(h.Buckets) {
+		h.Buckets = append(h.Buckets, make([]int, i+1-len(h.Buckets))...)
+	}
+	h.Buckets[i]++
+}
+
+// Mean returns the average of all values, or zero if there are none.
+func (h *Histogram) Mean() float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	return float64(h.Sum) / float64(h.Count)
+}
+
+func (h Histogram) clone() Histogram {
+	h.Buckets = append([]int(nil), h.Buckets...)
+	return h
+}
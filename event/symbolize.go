@@ -0,0 +1,177 @@
+package event
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"debug/gosym"
+	"errors"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Symbolizer resolves the function, file and line of a program counter. It
+// is used by a Trace to name the frames of Version1 stacks, which carry only
+// program counters.
+type Symbolizer interface {
+
+	// Symbolize returns the function, file and line of pc, with ok false when
+	// pc could not be resolved.
+	Symbolize(pc uint64) (fn, file string, line int, ok bool)
+}
+
+// RuntimeSymbolizer resolves program counters with runtime.FuncForPC, so it is
+// only useful for traces recorded by the running binary.
+type RuntimeSymbolizer struct{}
+
+// Symbolize implements Symbolizer.
+func (RuntimeSymbolizer) Symbolize(pc uint64) (fn, file string, line int, ok bool) {
+	f := runtime.FuncForPC(uintptr(pc))
+	if f == nil {
+		return ``, ``, 0, false
+	}
+	file, line = f.FileLine(uintptr(pc))
+	return f.Name(), file, line, true
+}
+
+// ELFSymbolizer resolves program counters from the symbol table and DWARF line
+// tables of an ELF binary, such as the binary a trace was recorded from. Go
+// binaries stripped of both are resolved from their .gopclntab section. It is
+// safe for use by multiple goroutines.
+type ELFSymbolizer struct {
+	funcs []elfFunc
+	data  *dwarf.Data
+	tab   *gosym.Table
+
+	mu    sync.Mutex
+	cache map[uint64]symbol
+}
+
+type elfFunc struct {
+	lo, hi uint64
+	name   string
+}
+
+type symbol struct {
+	fn, file string
+	line     int
+	ok       bool
+}
+
+// NewELFSymbolizer returns an ELFSymbolizer for the ELF binary read from r. An
+// error is returned if r is not an ELF binary or has neither a symbol table
+// nor a .gopclntab section. Binaries with a symbol table but without DWARF
+// data resolve function names only.
+func NewELFSymbolizer(r io.ReaderAt) (*ELFSymbolizer, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ELFSymbolizer{cache: make(map[uint64]symbol)}
+	syms, err := f.Symbols()
+	if err != nil {
+		return newGoSymbolizer(s, f)
+	}
+	for _, sym := range syms {
+		if elf.ST_TYPE(sym.Info) != elf.STT_FUNC || sym.Value == 0 {
+			continue
+		}
+		s.funcs = append(s.funcs, elfFunc{
+			lo: sym.Value, hi: sym.Value + sym.Size, name: sym.Name})
+	}
+	if len(s.funcs) == 0 {
+		return newGoSymbolizer(s, f)
+	}
+	sort.Slice(s.funcs, func(i, j int) bool {
+		return s.funcs[i].lo < s.funcs[j].lo
+	})
+
+	// DWARF is optional, without it only function names are resolved.
+	if data, err := f.DWARF(); err == nil {
+		s.data = data
+	}
+	return s, nil
+}
+
+// newGoSymbolizer initializes s from the .gopclntab section of f.
+func newGoSymbolizer(s *ELFSymbolizer, f *elf.File) (*ELFSymbolizer, error) {
+	sec, text := f.Section(`.gopclntab`), f.Section(`.text`)
+	if sec == nil || text == nil {
+		return nil, errors.New(`elf binary has no symbol table or .gopclntab section`)
+	}
+	data, err := sec.Data()
+	if err != nil {
+		return nil, err
+	}
+	tab, err := gosym.NewTable(nil, gosym.NewLineTable(data, text.Addr))
+	if err != nil {
+		return nil, err
+	}
+	s.tab = tab
+	return s, nil
+}
+
+// Symbolize implements Symbolizer.
+func (s *ELFSymbolizer) Symbolize(pc uint64) (fn, file string, line int, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sym, found := s.cache[pc]
+	if !found {
+		sym = s.lookup(pc)
+		s.cache[pc] = sym
+	}
+	return sym.fn, sym.file, sym.line, sym.ok
+}
+
+func (s *ELFSymbolizer) lookup(pc uint64) (sym symbol) {
+	if s.tab != nil {
+		file, line, fn := s.tab.PCToLine(pc)
+		if fn != nil {
+			sym = symbol{fn: fn.Name, file: file, line: line, ok: true}
+		}
+		return
+	}
+
+	i := sort.Search(len(s.funcs), func(i int) bool {
+		return s.funcs[i].lo > pc
+	}) - 1
+	if i < 0 || pc >= s.funcs[i].hi {
+		return
+	}
+	sym.fn, sym.ok = s.funcs[i].name, true
+	if s.data == nil {
+		return
+	}
+
+	r := s.data.Reader()
+	cu, err := r.SeekPC(pc)
+	if err != nil {
+		return
+	}
+	lr, err := s.data.LineReader(cu)
+	if err != nil || lr == nil {
+		return
+	}
+	var le dwarf.LineEntry
+	if err := lr.SeekPC(pc, &le); err != nil {
+		return
+	}
+	if le.File != nil {
+		sym.file = le.File.Name
+	}
+	sym.line = le.Line
+	return
+}
+
+// symbolize returns a Frame for pc named by the Symbolizer of this Trace, or a
+// Frame holding only pc if there is none or it could not be resolved.
+func (tr *Trace) symbolize(pc uint64) Frame {
+	if tr.Symbolizer != nil {
+		if fn, file, line, ok := tr.Symbolizer.Symbolize(pc); ok {
+			return NewFrame(pc, fn, file, line)
+		}
+	}
+	return Frame{tr: tr, pc: pc}
+}
@@ -0,0 +1,160 @@
+package event
+
+import "sort"
+
+// SyscallSummary describes the syscalls made within a trace, see the Syscalls
+// method of Trace. All times are in the unit of the Ts field of the events they
+// were derived from.
+//
+// The runtime only records the end of a syscall that blocked its thread long
+// enough for the P to be handed off, so the Time of a syscall is measured from
+// its EvGoSysCall event until the EvGoSysExit of the goroutine, or the real
+// time of the exit it records, and syscalls that did not block contribute only
+// to Calls.
+type SyscallSummary struct {
+	SyscallStat
+
+	// MaxBlocked is the largest number of threads blocked in syscalls at once.
+	MaxBlocked int
+
+	// Goroutines holds the syscalls of each goroutine by id and Stacks those of
+	// each call site by the stack id of the EvGoSysCall event.
+	Goroutines map[uint64]*SyscallStat
+	Stacks     map[uint64]*SyscallStat
+}
+
+// SyscallStat is the number of syscalls made, the number which blocked and the
+// total and longest times of those that blocked.
+type SyscallStat struct {
+	Calls, Blocked int
+	Time, MaxTime  int64
+}
+
+func (s *SyscallStat) add(blocked bool, d int64) {
+	s.Calls++
+	if !blocked {
+		return
+	}
+	s.Blocked++
+	s.Time += d
+	if d > s.MaxTime {
+		s.MaxTime = d
+	}
+}
+
+// SyscallSite is the syscalls made from a single call site.
+type SyscallSite struct {
+	StackID uint64
+	SyscallStat
+}
+
+// Top returns up to n call sites ordered by the most time blocked, then the
+// most syscalls which blocked. A negative n returns every call site.
+func (s *SyscallSummary) Top(n int) []SyscallSite {
+	sites := make([]SyscallSite, 0, len(s.Stacks))
+	for id, st := range s.Stacks {
+		sites = append(sites, SyscallSite{StackID: id, SyscallStat: *st})
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		a, b := sites[i], sites[j]
+		switch {
+		case a.Time != b.Time:
+			return a.Time > b.Time
+		case a.Blocked != b.Blocked:
+			return a.Blocked > b.Blocked
+		case a.Calls != b.Calls:
+			return a.Calls > b.Calls
+		}
+		return a.StackID < b.StackID
+	})
+	if n >= 0 && n < len(sites) {
+		sites = sites[:n]
+	}
+	return sites
+}
+
+// Syscalls summarizes the syscalls referred to by evts, which must be ordered
+// as they are by an Orderer and retain the arguments in the layout of the
+// Version of this Trace.
+func (tr *Trace) Syscalls(evts []*Event) *SyscallSummary {
+	var argoff int
+	if tr.Version.Valid() {
+		argoff = versions[tr.Version].argOffset
+	}
+
+	type pending struct {
+		stk     uint64
+		ts      int64
+		blocked bool
+	}
+	var (
+		sum = &SyscallSummary{
+			Goroutines: make(map[uint64]*SyscallStat),
+			Stacks:     make(map[uint64]*SyscallStat),
+		}
+		calls   = make(map[uint64]*pending)
+		blocked int
+	)
+	stat := func(m map[uint64]*SyscallStat, id uint64) *SyscallStat {
+		st := m[id]
+		if st == nil {
+			st = new(SyscallStat)
+			m[id] = st
+		}
+		return st
+	}
+
+	// A syscall is recorded once it is known if it blocked, which is when the
+	// goroutine makes another syscall, blocks or when the trace ends.
+	done := func(g uint64, ts int64) {
+		c := calls[g]
+		if c == nil {
+			return
+		}
+		delete(calls, g)
+		if c.blocked {
+			blocked--
+		}
+		d := ts - c.ts
+		sum.add(c.blocked, d)
+		stat(sum.Goroutines, g).add(c.blocked, d)
+		stat(sum.Stacks, c.stk).add(c.blocked, d)
+	}
+
+	var lastTs int64
+	for _, evt := range evts {
+		lastTs = evt.Ts
+		switch evt.Type {
+		case EvGoSysCall:
+			g := uint64(evt.G)
+			done(g, evt.Ts)
+			calls[g] = &pending{stk: evt.arg(ArgStackID, argoff), ts: evt.Ts}
+		case EvGoInSyscall:
+			g := evt.arg(ArgGoroutineID, argoff)
+			calls[g] = &pending{ts: evt.Ts, blocked: true}
+			blocked++
+		case EvGoSysBlock:
+			if c := calls[uint64(evt.G)]; c != nil && !c.blocked {
+				c.blocked = true
+				blocked++
+			}
+		case EvGoSysExit, EvGoSysExitLocal:
+			done(evt.arg(ArgGoroutineID, argoff), tr.sysExitTime(evt))
+		case EvGoEnd, EvGoStop, EvGoSched, EvGoPreempt, EvGoSleep, EvGoBlock,
+			EvGoBlockSend, EvGoBlockRecv, EvGoBlockSelect, EvGoBlockSync,
+			EvGoBlockCond, EvGoBlockNet, EvGoBlockGC:
+			// The prior syscall of a goroutine which continued running did
+			// not block, its duration is unknown.
+			if c := calls[uint64(evt.G)]; c != nil && !c.blocked {
+				done(uint64(evt.G), evt.Ts)
+			}
+		}
+		if blocked > sum.MaxBlocked {
+			sum.MaxBlocked = blocked
+		}
+	}
+	for g := range calls {
+		done(g, lastTs)
+	}
+	return sum
+}
@@ -0,0 +1,216 @@
+package event
+
+import "sort"
+
+// TaskTree is the hierarchy of user tasks within a Version5 or later trace,
+// along with the regions and logs which do not belong to any task. See the
+// Tasks method of Trace.
+type TaskTree struct {
+
+	// Tasks holds every task by id and Roots the tasks without a parent,
+	// ordered by their start.
+	Tasks map[uint64]*Task
+	Roots []*Task
+
+	// Regions and Logs are those recorded outside of any task.
+	Regions []*Region
+	Logs    []TaskLog
+}
+
+// Task is a user task created with runtime/trace.NewTask. All times are in the
+// unit of the Ts field of the events they were derived from.
+type Task struct {
+	ID   uint64
+	Name string
+
+	// Start and End are the timestamps the task was created and ended. Start is
+	// zero for tasks created before the trace began and End for tasks which did
+	// not end within the trace.
+	Start, End int64
+
+	// CreateStackID and EndStackID are the stacks the task was created and
+	// ended from.
+	CreateStackID, EndStackID uint64
+
+	// Parent is nil for root tasks, Children are ordered by their start.
+	Parent   *Task
+	Children []*Task
+
+	// Regions holds the outermost regions of each goroutine within this task,
+	// with nested regions held by their Children.
+	Regions []*Region
+	Logs    []TaskLog
+}
+
+// Duration returns the time from the start to the end of the task, or zero if
+// either is unknown.
+func (t *Task) Duration() int64 {
+	if t.Start == 0 || t.End == 0 {
+		return 0
+	}
+	return t.End - t.Start
+}
+
+// Region is a span of time within a single goroutine, recorded with
+// runtime/trace.WithRegion or StartRegion.
+type Region struct {
+	TaskID uint64
+	G      uint64
+	Name   string
+
+	// Start and End are zero when the region began before or ended after the
+	// trace.
+	Start, End int64
+
+	// StartStackID and EndStackID are the stacks the region started and ended
+	// from.
+	StartStackID, EndStackID uint64
+
+	// Children are the regions nested within this region.
+	Children []*Region
+}
+
+// Duration returns the time from the start to the end of the region, or zero
+// if either is unknown.
+func (r *Region) Duration() int64 {
+	if r.Start == 0 || r.End == 0 {
+		return 0
+	}
+	return r.End - r.Start
+}
+
+// TaskLog is a message recorded with runtime/trace.Log.
+type TaskLog struct {
+	Ts         int64
+	G          uint64
+	Key, Value string
+	StackID    uint64
+}
+
+// Tasks builds the hierarchy of user tasks, regions and logs referred to by
+// evts, which must be ordered as they are by an Orderer. Every event should
+// have been visited by this Trace beforehand so names may be resolved. Traces
+// prior to Version5 have no user tasks and return an empty tree.
+func (tr *Trace) Tasks(evts []*Event) (*TaskTree, error) {
+	tt := &TaskTree{Tasks: make(map[uint64]*Task)}
+	if tr.Version < Version5 {
+		return tt, nil
+	}
+
+	// Tasks created before the trace began are added when first referred to.
+	task := func(id uint64) *Task {
+		if id == 0 {
+			return nil
+		}
+		t := tt.Tasks[id]
+		if t == nil {
+			t = &Task{ID: id}
+			tt.Tasks[id] = t
+		}
+		return t
+	}
+	addRegion := func(r *Region) {
+		if t := task(r.TaskID); t != nil {
+			t.Regions = append(t.Regions, r)
+		} else {
+			tt.Regions = append(tt.Regions, r)
+		}
+	}
+
+	// open holds the regions of each goroutine which have not yet ended, with
+	// the innermost last.
+	open := make(map[uint64][]*Region)
+	for _, evt := range evts {
+		switch evt.Type {
+		case EvUserTaskCreate:
+			name, err := tr.getString(evt.Get(ArgNameStringID))
+			if err != nil {
+				return nil, err
+			}
+			t := task(evt.Get(ArgTaskID))
+			if t == nil {
+				continue
+			}
+			t.Name, t.Start = name, evt.Ts
+			t.CreateStackID = evt.Get(ArgStackID)
+			if p := task(evt.Get(ArgParentTaskID)); p != nil {
+				t.Parent = p
+			}
+		case EvUserTaskEnd:
+			if t := task(evt.Get(ArgTaskID)); t != nil {
+				t.End, t.EndStackID = evt.Ts, evt.Get(ArgStackID)
+			}
+		case EvUserRegion:
+			name, err := tr.getString(evt.Get(ArgNameStringID))
+			if err != nil {
+				return nil, err
+			}
+			g, stk := uint64(evt.G), evt.Get(ArgStackID)
+			rs := open[g]
+
+			if evt.Get(ArgMode) == 0 {
+				r := &Region{TaskID: evt.Get(ArgTaskID), G: g, Name: name,
+					Start: evt.Ts, StartStackID: stk}
+				if n := len(rs); n > 0 {
+					rs[n-1].Children = append(rs[n-1].Children, r)
+				} else {
+					addRegion(r)
+				}
+				open[g] = append(rs, r)
+				continue
+			}
+
+			// End the innermost open region with the same name, or one which
+			// began before the trace when there is none.
+			i := len(rs) - 1
+			for ; i >= 0 && rs[i].Name != name; i-- {
+			}
+			if i < 0 {
+				addRegion(&Region{TaskID: evt.Get(ArgTaskID), G: g, Name: name,
+					End: evt.Ts, EndStackID: stk})
+				continue
+			}
+			rs[i].End, rs[i].EndStackID = evt.Ts, stk
+			open[g] = rs[:i]
+		case EvUserLog:
+			key, err := tr.getString(evt.Get(ArgKeyStringID))
+			if err != nil {
+				return nil, err
+			}
+			val := string(evt.Data)
+			if len(evt.Data) == 0 && evt.Span.Len > 0 {
+				if val, err = tr.readSpan(evt.Span); err != nil {
+					return nil, err
+				}
+			}
+			log := TaskLog{Ts: evt.Ts, G: uint64(evt.G), Key: key, Value: val,
+				StackID: evt.Get(ArgStackID)}
+			if t := task(evt.Get(ArgTaskID)); t != nil {
+				t.Logs = append(t.Logs, log)
+			} else {
+				tt.Logs = append(tt.Logs, log)
+			}
+		}
+	}
+
+	for _, t := range tt.Tasks {
+		if t.Parent != nil {
+			t.Parent.Children = append(t.Parent.Children, t)
+		} else {
+			tt.Roots = append(tt.Roots, t)
+		}
+	}
+	byStart := func(ts []*Task) {
+		sort.Slice(ts, func(i, j int) bool {
+			if ts[i].Start != ts[j].Start {
+				return ts[i].Start < ts[j].Start
+			}
+			return ts[i].ID < ts[j].ID
+		})
+	}
+	byStart(tt.Roots)
+	for _, t := range tt.Tasks {
+		byStart(t.Children)
+	}
+	return tt, nil
+}
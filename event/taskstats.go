@@ -0,0 +1,150 @@
+package event
+
+import (
+	"math"
+	"sort"
+)
+
+// DurationStats summarizes the durations of the tasks or regions sharing a
+// name, see the RegionStats and TaskStats methods of TaskTree. All times are
+// in the unit of the Ts field of the events they were derived from.
+type DurationStats struct {
+	Name string
+
+	// Count is the number of instances with this name and Complete those both
+	// starting and ending within the trace, which are the only instances with
+	// a known duration.
+	Count, Complete int
+
+	// Total, Min and Max are of the durations of complete instances, with P50,
+	// P90 and P99 their percentiles by the nearest rank method.
+	Total, Min, Max int64
+	P50, P90, P99   int64
+
+	// MaxConcurrent is the largest number of instances that were in progress
+	// at the same time, including those which began before or ended after
+	// the trace.
+	MaxConcurrent int
+}
+
+// Mean returns the average duration of complete instances, or zero if there
+// are none.
+func (s *DurationStats) Mean() float64 {
+	if s.Complete == 0 {
+		return 0
+	}
+	return float64(s.Total) / float64(s.Complete)
+}
+
+// RegionStats returns the duration statistics of the regions within this tree
+// grouped by name and ordered by name, including nested regions and those
+// outside of any task.
+func (tt *TaskTree) RegionStats() []DurationStats {
+	spans := make(map[string][]span)
+	var walk func(rs []*Region)
+	walk = func(rs []*Region) {
+		for _, r := range rs {
+			spans[r.Name] = append(spans[r.Name], span{r.Start, r.End})
+			walk(r.Children)
+		}
+	}
+	walk(tt.Regions)
+	for _, t := range tt.Tasks {
+		walk(t.Regions)
+	}
+	return durationStats(spans)
+}
+
+// TaskStats returns the duration statistics of the tasks within this tree
+// grouped by name and ordered by name.
+func (tt *TaskTree) TaskStats() []DurationStats {
+	spans := make(map[string][]span)
+	for _, t := range tt.Tasks {
+		spans[t.Name] = append(spans[t.Name], span{t.Start, t.End})
+	}
+	return durationStats(spans)
+}
+
+// span is the start and end of a task or region, either of which may be zero
+// when unknown.
+type span struct {
+	start, end int64
+}
+
+func durationStats(byName map[string][]span) []DurationStats {
+	out := make([]DurationStats, 0, len(byName))
+	for name, spans := range byName {
+		s := DurationStats{Name: name, Count: len(spans)}
+		var ds []int64
+		for _, sp := range spans {
+			if sp.start != 0 && sp.end != 0 {
+				ds = append(ds, sp.end-sp.start)
+			}
+		}
+		sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+		s.Complete = len(ds)
+		if n := len(ds); n > 0 {
+			for _, d := range ds {
+				s.Total += d
+			}
+			s.Min, s.Max = ds[0], ds[n-1]
+			s.P50, s.P90, s.P99 = rank(ds, .50), rank(ds, .90), rank(ds, .99)
+		}
+		s.MaxConcurrent = maxConcurrent(spans)
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// rank returns the value at quantile q in [0, 1] of the sorted values ds by
+// nearest rank, or zero if ds is empty. The rounding error of q*len(ds) is
+// tolerated so a quantile such as .07 of 100 values is the 7th value.
+func rank(ds []int64, q float64) int64 {
+	n := len(ds)
+	if n == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(n)-1e-9)) - 1
+	switch {
+	case i < 0:
+		i = 0
+	case i >= n:
+		i = n - 1
+	}
+	return ds[i]
+}
+
+// maxConcurrent returns the largest number of overlapping spans, with unknown
+// starts and ends extending to the bounds of the trace. Spans ending at the
+// time another starts do not overlap.
+func maxConcurrent(spans []span) int {
+	type edge struct {
+		ts    int64
+		delta int
+	}
+	edges := make([]edge, 0, len(spans)*2)
+	for _, sp := range spans {
+		start, end := sp.start, sp.end
+		if start == 0 {
+			start = math.MinInt64
+		}
+		if end == 0 {
+			end = math.MaxInt64
+		}
+		edges = append(edges, edge{start, 1}, edge{end, -1})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].ts != edges[j].ts {
+			return edges[i].ts < edges[j].ts
+		}
+		return edges[i].delta < edges[j].delta
+	})
+	var cur, max int
+	for _, e := range edges {
+		if cur += e.delta; cur > max {
+			max = cur
+		}
+	}
+	return max
+}
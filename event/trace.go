@@ -3,14 +3,57 @@ package event
 import (
 	"errors"
 	"fmt"
+	"io"
+	"time"
 )
 
 // Trace maintains the shared satate across events.
 type Trace struct {
-	Version      Version
-	Strings      map[uint64]string
-	Stacks       map[uint64]Stack
-	Count        int
+	Version Version
+	Strings map[uint64]string
+	Stacks  map[uint64]Stack
+	Count   int
+
+	// Source is used to resolve strings from events that were decoded lazily,
+	// having a Span in place of Data. The offsets of each span are relative to
+	// the beginning of Source.
+	Source io.ReaderAt
+
+	// Frequency is the number of ticks per second declared by the EvFrequency
+	// event of the trace, it is used by Time and TicksToDuration.
+	Frequency uint64
+
+	// Anchor relates the tick count of the trace to the wall clock, it is used
+	// by Time. When the Time of the anchor is zero the Ticks are set to the base
+	// timestamp of the first EvBatch visited.
+	Anchor Anchor
+
+	// Limits bounds the strings and stacks accumulated from visited events, it
+	// is retained across calls to Reset. It must be set before the first event
+	// is visited, entries visited while unbounded are never evicted.
+	Limits TableLimits
+
+	// Symbolizer names the frames of Version1 stacks as they are visited, it
+	// is retained across calls to Reset. Frames which could not be resolved
+	// hold only a program counter.
+	Symbolizer Symbolizer
+
+	// Lenient records non-fatal inconsistencies, such as strings or stacks
+	// declared more than once, as warnings returned by Warnings rather than
+	// returning them from Visit. It is retained across calls to Reset.
+	Lenient bool
+
+	anchored   bool
+	timed      bool
+	start, end int64
+	gs         map[uint64]gInfo
+	warnings   []Warning
+	stackRefs  map[uint64]Warning
+	integrity  integrity
+	tables     tables
+	stackIndex stackIndex
+
+	spans        map[uint64]Span
 	stackVisitFn func(evt *Event) error
 }
 
@@ -21,6 +64,7 @@ func NewTrace(v Version) (*Trace, error) {
 		Version: v,
 		Stacks:  make(map[uint64]Stack),
 		Strings: make(map[uint64]string),
+		spans:   make(map[uint64]Span),
 	}
 	if err := tr.init(); err != nil {
 		return nil, err
@@ -30,9 +74,10 @@ func NewTrace(v Version) (*Trace, error) {
 
 // Reset will reset this event for reuse.
 func (tr *Trace) Reset() {
-	*tr = Trace{}
+	*tr = Trace{Limits: tr.Limits, Symbolizer: tr.Symbolizer, Lenient: tr.Lenient}
 	tr.Stacks = make(map[uint64]Stack)
 	tr.Strings = make(map[uint64]string)
+	tr.spans = make(map[uint64]Span)
 }
 
 func (tr *Trace) init() error {
@@ -58,6 +103,72 @@ func (tr *Trace) Stack(evt *Event) (Stack, error) {
 	return tr.getStack(evt.Get(ArgStackID))
 }
 
+// GetString returns the string for the given string id, reading it from Source
+// if the EvString event declaring it was decoded lazily.
+func (tr *Trace) GetString(id uint64) (string, error) {
+	return tr.getString(id)
+}
+
+// Anchor pairs a wall clock time with the tick count of the trace at which it
+// was taken, such as recording time.Now() when tracing starts.
+type Anchor struct {
+	Time  time.Time
+	Ticks int64
+}
+
+// Time returns the wall clock time of evt from its Ts field, which must be in
+// CPU ticks as populated by the Decoder without the Nanoseconds option. The
+// Frequency must be known, otherwise the zero time is returned. When the Trace
+// has no Anchor time the result is relative to the zero time, so only the
+// differences between times are meaningful.
+func (tr *Trace) Time(evt *Event) time.Time {
+	if tr.Frequency == 0 {
+		return time.Time{}
+	}
+
+	// Split the conversion to avoid overflow for large tick counts.
+	ticks, freq := evt.Ts-tr.Anchor.Ticks, int64(tr.Frequency)
+	d := ticks/freq*int64(time.Second) + ticks%freq*int64(time.Second)/freq
+	return tr.Anchor.Time.Add(time.Duration(d))
+}
+
+// TicksToDuration converts a delta in CPU ticks, such as the difference between
+// the Ts fields of two events, to a time.Duration using the Frequency of the
+// trace. Zero is returned when the Frequency is unknown.
+func (tr *Trace) TicksToDuration(delta uint64) time.Duration {
+	freq := tr.Frequency
+	if freq == 0 {
+		return 0
+	}
+	sec := uint64(time.Second)
+	return time.Duration(delta/freq*sec + delta%freq*sec/freq)
+}
+
+// TimeBounds returns the earliest and latest Ts fields of the events visited
+// by this Trace, or zeros if no visited event had a timestamp.
+func (tr *Trace) TimeBounds() (start, end int64) {
+	return tr.start, tr.end
+}
+
+// Duration returns the time spanned by the events visited by this Trace, from
+// the earliest to the latest timestamp. Like Time it requires the Ts fields of
+// events to be in CPU ticks and the Frequency to be known, otherwise zero is
+// returned.
+func (tr *Trace) Duration() time.Duration {
+	return tr.TicksToDuration(uint64(tr.end - tr.start))
+}
+
+// bound extends the time bounds of this trace to include ts.
+func (tr *Trace) bound(ts int64) {
+	if !tr.timed || ts < tr.start {
+		tr.start = ts
+	}
+	if !tr.timed || ts > tr.end {
+		tr.end = ts
+	}
+	tr.timed = true
+}
+
 // Visit the given event with this Trace.
 func (tr *Trace) Visit(evt *Event) (err error) {
 	if tr.Count == 0 {
@@ -74,22 +185,36 @@ func (tr *Trace) Visit(evt *Event) (err error) {
 		return fmt.Errorf(`event type %v was not valid`, evt.Type)
 	}
 
-	// Fetch schema for validation
-	sm := schemas[evt.Type]
-
-	// Validate the arg len is at least as long as the schema
-	if exp, got := len(sm.Args), len(evt.Args); exp > got {
+	// Validate the arg len is at least as long as the layout of the version
+	exp := len(schemas[evt.Type].Args)
+	if tr.Version.Valid() {
+		exp = layouts[tr.Version][evt.Type].n
+	}
+	if got := len(evt.Args); exp > got {
 		return fmt.Errorf(
 			`event type %v only had %d of %d arguments`, evt.Type, got, exp)
 	}
 
+	if evt.Ts != 0 {
+		tr.bound(evt.Ts)
+	}
+	tr.refStacks(evt)
+	tr.visitIntegrity(evt)
 	switch evt.Type {
+	case EvBatch:
+		if !tr.anchored && tr.Anchor.Time.IsZero() {
+			tr.Anchor.Ticks = evt.Ts
+		}
+		tr.anchored = true
 	case EvFrequency:
-		// err = tr.visitFrequency(evt)
+		err = tr.visitFrequency(evt)
 	case EvString:
-		err = tr.visitString(evt)
+		err = tr.warn(evt, tr.visitString(evt))
 	case EvStack:
-		err = tr.visitStack(evt)
+		err = tr.warn(evt, tr.visitStack(evt))
+	case EvGoCreate, EvGoWaiting, EvGoInSyscall, EvTimerGoroutine, EvGoStart,
+		EvGoStartLocal, EvGoStartLabel, EvGoEnd, EvGoStop:
+		tr.visitGoroutine(evt)
 	}
 	return
 }
@@ -126,9 +251,10 @@ func (tr *Trace) visitString(evt *Event) error {
 		return errors.New(`invalid string id 0`)
 	}
 
-	// @TODO Decide how to store EvString and the mapping. Nil ref data, or
-	// maybe skip allocating data to *Event all together.
-	// evt.Data = nil
+	// Lazily decoded strings are resolved from Source when requested.
+	if evt.Span.Len > 0 {
+		return tr.addSpan(id, evt.Span)
+	}
 	str := string(evt.Data)
 	return tr.addString(id, str)
 }
@@ -162,17 +288,16 @@ func (tr *Trace) visitFrequency(evt *Event) error {
 	if evt.Type != EvFrequency {
 		return fmt.Errorf("event type %v may not be used as a frequency", evt)
 	}
-	if err := tr.validateArgCount(evt, 1, 1); err != nil {
+	// Version1 frequencies are followed by an unused argument.
+	if err := tr.validateArgCount(evt, 1, 2); err != nil {
 		return err
 	}
 
-	freq := float64(evt.Args[0])
-	if freq <= 0 {
-		return fmt.Errorf(`frequency %v should be >= to 0`, freq)
+	freq := evt.Args[0]
+	if freq == 0 {
+		return fmt.Errorf(`frequency %v should be > 0`, freq)
 	}
-
-	// tr.freq = 1e9 / freq
-	// @TODO
+	tr.Frequency = freq
 	return nil
 }
 
@@ -187,7 +312,7 @@ func (tr *Trace) visitStackSize1(evt *Event) (err error) {
 
 	stack := make(Stack, size)
 	for i := 0; i < size; i++ {
-		stack[i] = Frame{tr: tr, pc: evt.Args[2+i*frameSize]}
+		stack[i] = tr.symbolize(evt.Args[2+i*frameSize])
 	}
 	return tr.addStack(id, stack)
 }
@@ -220,14 +345,13 @@ func (tr *Trace) getStack(id uint64) (stk Stack, err error) {
 	if !ok {
 		err = fmt.Errorf(`trace stack ID %v could not be found`, id)
 	}
+	tr.touch(stackTable, id)
 	return
 }
 
 func (tr *Trace) getStringDefault(id uint64) string {
-	if tr != nil {
-		if str, ok := tr.Strings[id]; ok {
-			return str
-		}
+	if str, err := tr.getString(id); err == nil {
+		return str
 	}
 	return fmt.Sprintf(`ID(%v missing)`, id)
 }
@@ -237,23 +361,77 @@ func (tr *Trace) getString(id uint64) (string, error) {
 		return ``, fmt.Errorf(`trace: cannot find string ID %v in nil Trace`, id)
 	}
 	if s, ok := tr.Strings[id]; ok {
+		tr.touch(stringTable, id)
 		return s, nil
 	}
+	if sp, ok := tr.spans[id]; ok {
+		tr.touch(stringTable, id)
+		return tr.readSpan(sp)
+	}
 	return ``, fmt.Errorf(`trace: cannot find string ID %v in Trace`, id)
 }
 
+func (tr *Trace) readSpan(sp Span) (string, error) {
+	if tr.Source == nil {
+		return ``, errors.New(`trace: cannot resolve lazy string without a Source`)
+	}
+	if maxMakeSize < sp.Len {
+		return ``, fmt.Errorf(
+			"trace: size %v exceeds allocation limit(%v)", sp.Len, maxMakeSize)
+	}
+
+	b := make([]byte, sp.Len)
+	if _, err := tr.Source.ReadAt(b, int64(sp.Off)); err != nil {
+		return ``, err
+	}
+	return string(b), nil
+}
+
 func (tr *Trace) addStack(id uint64, stk Stack) error {
 	if _, ok := tr.Stacks[id]; ok {
-		return errors.New(`trace stack already exists`)
+		return errDupStack
+	}
+	size := stackSize(stk)
+	if shared, ok := tr.dedupStack(id, stk); ok {
+		stk, size = shared, entryBytes
+	}
+	if err := tr.admit(stackTable, id, size); err != nil {
+		tr.unindexStack(id, stk)
+		return err
 	}
 	tr.Stacks[id] = stk
 	return nil
 }
 
 func (tr *Trace) addString(id uint64, str string) error {
-	if _, ok := tr.Strings[id]; ok {
-		return errors.New(`trace string already exists`)
+	if tr.hasString(id) {
+		return errDupString
+	}
+	if err := tr.admit(stringTable, id, stringSize(str)); err != nil {
+		return err
 	}
 	tr.Strings[id] = str
 	return nil
 }
+
+func (tr *Trace) addSpan(id uint64, sp Span) error {
+	if tr.hasString(id) {
+		return errDupString
+	}
+	if err := tr.admit(stringTable, id, entryBytes); err != nil {
+		return err
+	}
+	if tr.spans == nil {
+		tr.spans = make(map[uint64]Span)
+	}
+	tr.spans[id] = sp
+	return nil
+}
+
+func (tr *Trace) hasString(id uint64) bool {
+	if _, ok := tr.Strings[id]; ok {
+		return true
+	}
+	_, ok := tr.spans[id]
+	return ok
+}
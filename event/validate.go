@@ -0,0 +1,247 @@
+package event
+
+import "fmt"
+
+// Violation describes an event which is inconsistent with the state of the
+// goroutines, Ps or garbage collector established by prior events, see
+// Validator.
+type Violation struct {
+
+	// Off is the offset of the event in the input stream and Ts its
+	// timestamp.
+	Off int
+	Ts  int64
+
+	// Type is the type of the inconsistent event.
+	Type Type
+
+	// Msg describes why the event is inconsistent.
+	Msg string
+}
+
+// Error implements the error interface.
+func (v *Violation) Error() string {
+	return fmt.Sprintf(`inconsistent %v at 0x%x: %v`, v.Type.Name(), v.Off, v.Msg)
+}
+
+// Validator is a Visitor which tracks the state machines of goroutines, Ps and
+// the garbage collector to detect events which are well formed but logically
+// impossible, such as starting a goroutine that was never created or starting
+// a P twice. These are typical of corrupted or hand-built traces that would
+// otherwise decode without error.
+//
+// Events must be visited in the order they occurred, as they are by an
+// Orderer, the order events are decoded in is not consistent across Ps. Visit returns a *Violation for each inconsistent event and applies
+// it regardless, so visiting may continue to find further violations, such as
+// with Collect.
+type Validator struct {
+	ver    Version
+	argoff int
+	gs     map[uint64]*gValid
+	ps     map[int64]*pValid
+	gc     bool
+	stw    bool
+}
+
+type gValid struct {
+	status  GStatus
+	syscall bool
+}
+
+type pValid struct {
+	running bool
+	stw     bool
+	g       uint64
+}
+
+// NewValidator returns a Validator for events with arguments in the layout of
+// the given version, or an error if the version is unknown.
+func NewValidator(v Version) (*Validator, error) {
+	if !v.Valid() {
+		return nil, fmt.Errorf(`Version %v is unknown`, v)
+	}
+	return &Validator{
+		ver:    v,
+		argoff: versions[v].argOffset,
+		gs:     make(map[uint64]*gValid),
+		ps:     make(map[int64]*pValid),
+	}, nil
+}
+
+// Visit implements Visitor.
+func (v *Validator) Visit(evt *Event) error {
+	msg := v.visit(evt)
+	if msg == `` {
+		return nil
+	}
+	return &Violation{Off: evt.Off, Ts: evt.Ts, Type: evt.Type, Msg: msg}
+}
+
+func (v *Validator) visit(evt *Event) string {
+	p := v.proc(evt.P)
+	switch evt.Type {
+	case EvProcStart:
+		if p == nil {
+			return ``
+		}
+		defer func() { p.running = true }()
+		if p.running {
+			return fmt.Sprintf(`P %v is already running`, evt.P)
+		}
+	case EvProcStop:
+		if p == nil {
+			return ``
+		}
+		defer func() { p.running, p.g = false, 0 }()
+		if !p.running {
+			return fmt.Sprintf(`P %v is not running`, evt.P)
+		}
+		if p.g != 0 {
+			return fmt.Sprintf(`P %v stopped while running g %v`, evt.P, p.g)
+		}
+
+	case EvGCStart:
+		defer func() { v.gc = true }()
+		if v.gc {
+			return `GC is already running`
+		}
+	case EvGCDone:
+		defer func() { v.gc = false }()
+		if !v.gc {
+			return `GC is not running`
+		}
+	case EvGCSTWStart:
+		stw := v.world(p)
+		defer func() { *stw = true }()
+		if *stw {
+			return `the world is already stopped`
+		}
+	case EvGCSTWDone:
+		stw := v.world(p)
+		defer func() { *stw = false }()
+		if !*stw {
+			return `the world is not stopped`
+		}
+
+	case EvGoCreate:
+		id := evt.arg(ArgNewGoroutineID, v.argoff)
+		defer func() { v.gs[id] = &gValid{status: GRunnable} }()
+		if g := v.gs[id]; g != nil {
+			return fmt.Sprintf(`g %v already exists`, id)
+		}
+	case EvGoWaiting, EvGoInSyscall:
+		id := evt.arg(ArgGoroutineID, v.argoff)
+		g, msg := v.expect(id, GRunnable)
+		if g != nil {
+			g.status, g.syscall = GWaiting, evt.Type == EvGoInSyscall
+		}
+		return msg
+	case EvGoStart, EvGoStartLocal, EvGoStartLabel:
+		id := evt.arg(ArgGoroutineID, v.argoff)
+		g, msg := v.expect(id, GRunnable)
+		if g != nil {
+			g.status = GRunning
+		}
+		if p != nil {
+			if p.g != 0 && msg == `` {
+				msg = fmt.Sprintf(`P %v is already running g %v`, evt.P, p.g)
+			}
+			p.g = id
+		}
+		return msg
+
+	case EvGoSysCall:
+		_, _, msg := v.current(evt, p)
+		return msg
+	case EvGoEnd, EvGoStop, EvGoSched, EvGoPreempt, EvGoBlock, EvGoBlockSend,
+		EvGoBlockRecv, EvGoBlockSelect, EvGoBlockSync, EvGoBlockCond,
+		EvGoBlockNet, EvGoBlockGC, EvGoSleep, EvGoSysBlock:
+		id, g, msg := v.current(evt, p)
+		if p != nil {
+			p.g = 0
+		}
+		switch evt.Type {
+		case EvGoEnd, EvGoStop:
+			delete(v.gs, id)
+		case EvGoSched, EvGoPreempt:
+			if g != nil {
+				g.status = GRunnable
+			}
+		default:
+			if g != nil {
+				g.status, g.syscall = GWaiting, evt.Type == EvGoSysBlock
+			}
+		}
+		return msg
+
+	case EvGoUnblock, EvGoUnblockLocal:
+		id := evt.arg(ArgGoroutineID, v.argoff)
+		g, msg := v.expect(id, GWaiting)
+		if g != nil {
+			g.status, g.syscall = GRunnable, false
+		}
+		return msg
+	case EvGoSysExit, EvGoSysExitLocal:
+		id := evt.arg(ArgGoroutineID, v.argoff)
+		g, msg := v.expect(id, GWaiting)
+		if g != nil {
+			if msg == `` && !g.syscall {
+				msg = fmt.Sprintf(`g %v is not in a syscall`, id)
+			}
+			g.status, g.syscall = GRunnable, false
+		}
+		return msg
+	}
+	return ``
+}
+
+// proc returns the state of the P with the given id, or nil for events which
+// are not associated with a P.
+func (v *Validator) proc(id int64) *pValid {
+	if id < 0 {
+		return nil
+	}
+	p := v.ps[id]
+	if p == nil {
+		p = new(pValid)
+		v.ps[id] = p
+	}
+	return p
+}
+
+// world returns the stop the world state for the events of P p, which is
+// shared by all Ps from Version5. Each P stopped and started the world within
+// its own batches prior to Go 1.10, so the pauses of Ps overlap.
+func (v *Validator) world(p *pValid) *bool {
+	if v.ver < Version5 && p != nil {
+		return &p.stw
+	}
+	return &v.stw
+}
+
+// expect returns the state of goroutine id and a message if it does not exist
+// or is not in the given status.
+func (v *Validator) expect(id uint64, status GStatus) (*gValid, string) {
+	g := v.gs[id]
+	switch {
+	case g == nil:
+		return nil, fmt.Sprintf(`g %v does not exist`, id)
+	case g.status != status:
+		return g, fmt.Sprintf(`g %v is %v, not %v`, id, g.status, status)
+	}
+	return g, ``
+}
+
+// current returns the goroutine running on the P of evt, or the G of evt when
+// it is not associated with a P, along with a message if it is not running.
+func (v *Validator) current(evt *Event, p *pValid) (uint64, *gValid, string) {
+	id := uint64(evt.G)
+	if p != nil {
+		id = p.g
+	}
+	if id == 0 {
+		return 0, nil, fmt.Sprintf(`no goroutine is running on P %v`, evt.P)
+	}
+	g, msg := v.expect(id, GRunning)
+	return id, g, msg
+}
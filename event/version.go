@@ -1,6 +1,10 @@
 package event
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 const (
 
@@ -13,11 +17,17 @@ const (
 	// Version3 was released in Go version 1.8 - 2017/02/16
 	Version3 Version = 3
 
-	// Version4 is in tip, currently marked in the header as 1.9.
+	// Version4 was released in Go version 1.9 - 2017/08/24
 	Version4 Version = 4
 
+	// Version5 was released in Go version 1.11 - 2018/08/24
+	Version5 Version = 5
+
+	// Version6 was released in Go version 1.19 - 2022/08/02
+	Version6 Version = 6
+
 	// Latest always points to the newest released version for convenience.
-	Latest = Version4
+	Latest = Version6
 )
 
 // Arguments that may exist within an event, 1 or more of these are returned
@@ -41,6 +51,11 @@ const (
 	ArgHeapAlloc      = `HeapAlloc`
 	ArgNextGC         = `NextGC`
 	ArgKind           = `Kind`
+	ArgTaskID         = `TaskID`
+	ArgParentTaskID   = `ParentTaskID`
+	ArgNameStringID   = `NameStringID`
+	ArgKeyStringID    = `KeyStringID`
+	ArgMode           = `Mode`
 )
 
 // Version of Go declared in the header of the trace. Each version is
@@ -51,7 +66,7 @@ type Version byte
 // Valid returns true if this version object is from a valid trace header, false
 // otherwise.
 func (v Version) Valid() bool {
-	return Version1 <= v && v <= Version4
+	return Version1 <= v && v <= Latest
 }
 
 // Go returns the version of Go this version was released with.
@@ -62,6 +77,67 @@ func (v Version) Go() string {
 	return versions[v].gover
 }
 
+// Supports returns true if events of type t may be emitted in traces of this
+// version, false otherwise.
+func (v Version) Supports(t Type) bool {
+	return v.Valid() && t.Valid() && t != EvNone && v >= t.Since()
+}
+
+// goNewTracer is the minor version of the first Go release recording traces
+// in the format of the rewritten execution tracer, which is not supported.
+const goNewTracer = 22
+
+// goHeaders are the minor versions of the Go releases which introduced a trace
+// header not described by any Version, such as "go 1.10 trace", in ascending
+// order. Traces with these headers are rejected when decoded.
+var goHeaders = [...]int{10, 21}
+
+// VersionForGo returns the version of the trace format recorded by the given
+// release of Go and a boolean true, or zero and false if the release predates
+// tracing or records a format this package does not support. The release may
+// be given with or without a "go" prefix and with a patch or pre-release
+// suffix, such as "1.11", "go1.11.2" or "go1.19rc1". Releases between those
+// introducing a version record the prior version, unless a release between
+// them introduced a header that is not supported, such as Go 1.10 and 1.21.
+func VersionForGo(release string) (Version, bool) {
+	minor, ok := goMinor(release)
+	if !ok || minor >= goNewTracer {
+		return 0, false
+	}
+	var header int
+	for _, h := range goHeaders {
+		if h <= minor {
+			header = h
+		}
+	}
+	for v := Latest; v.Valid(); v-- {
+		if introduced, _ := goMinor(versions[v].gover); introduced <= minor {
+			if introduced < header {
+				return 0, false
+			}
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// goMinor returns the minor version of a Go 1 release such as "go1.11.2".
+func goMinor(release string) (int, bool) {
+	s := strings.TrimPrefix(release, `go`)
+	if !strings.HasPrefix(s, `1.`) {
+		return 0, false
+	}
+	s = s[2:]
+	i := 0
+	for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+	}
+	if i == 0 || (i < len(s) && s[i] != '.' && s[i] != 'r' && s[i] != 'b') {
+		return 0, false
+	}
+	minor, err := strconv.Atoi(s[:i])
+	return minor, err == nil
+}
+
 // Types returns this versions declared event types. The arguments declared by
 // each Type will always have the latest versions signature. The returned value
 // must not be mutated and may be nil if the Version is invalid.
@@ -72,12 +148,6 @@ func (v Version) Types() []Type {
 	return versions[v].types
 }
 
-// // Schemas returns the schema for each event in this version. The returned value
-// // must not be mutated.
-// func (v Version) Schemas() []*Schema {
-// 	return versions[v%Latest].schemas
-// }
-
 // String implements fmt.Stringer.
 func (v Version) String() string {
 	if !v.Valid() {
@@ -93,7 +163,7 @@ func (v Version) String() string {
 
 func init() {
 	for typ, s := range schemas {
-		for i := s.Since; i <= Version4; i++ {
+		for i := s.Since; i <= Latest; i++ {
 			versions[i].schemas = append(versions[i].schemas, s)
 			versions[i].types = append(versions[i].types, Type(typ))
 		}
@@ -117,6 +187,8 @@ var versions = [...]version{
 	Version2: {gover: `1.7`, frameSize: 4},
 	Version3: {gover: `1.8`, frameSize: 4},
 	Version4: {gover: `1.9`, frameSize: 4},
+	Version5: {gover: `1.11`, frameSize: 4},
+	Version6: {gover: `1.19`, frameSize: 4},
 }
 
 type schema struct {
@@ -128,6 +200,13 @@ type schema struct {
 
 const schemasCount = len(schemas)
 
+// The argument indexes, typed accessors of Event and the Handler interface in
+// args.go are generated from schemas, the accessors read arguments in the
+// layout of the version given to them, see Schemas. Run go generate after
+// changing the arguments of a schema or adding a type of event.
+//
+//go:generate go run ../internal/cmd/argsgen -o args.go
+
 var schemas = [...]schema{
 	{"None", 0, []string{}},
 	{"Batch", Version1, []string{ArgProcessorID, ArgTimestamp}},
@@ -140,6 +219,8 @@ var schemas = [...]schema{
 	{"GCStart", Version1, []string{
 		ArgTimestamp, ArgSequenceGC, ArgStackID}},
 	{"GCDone", Version1, []string{ArgTimestamp}},
+	// The kind argument is emitted from Go 1.10, the layouts of prior versions
+	// omit it, see STWKind.
 	{"GCSTWStart", Version1, []string{ArgTimestamp, ArgKind}},
 	{"GCSTWDone", Version1, []string{ArgTimestamp}},
 	{"GCSweepStart", Version1, []string{ArgTimestamp, ArgStackID}},
@@ -183,4 +264,17 @@ var schemas = [...]schema{
 	{"GoBlockGC", Version3, []string{ArgTimestamp, ArgStackID}},
 	{"EvGCMarkAssistStart", Version4, []string{ArgTimestamp, ArgStackID}},
 	{"EvGCMarkAssistDone", Version4, []string{ArgTimestamp}},
+	// The stack id of user events is emitted after the string ids, contrary to
+	// the order listed in the runtime.
+	{"UserTaskCreate", Version5, []string{
+		ArgTimestamp, ArgTaskID, ArgParentTaskID, ArgNameStringID, ArgStackID}},
+	{"UserTaskEnd", Version5, []string{ArgTimestamp, ArgTaskID, ArgStackID}},
+	{"UserRegion", Version5, []string{
+		ArgTimestamp, ArgTaskID, ArgMode, ArgNameStringID, ArgStackID}},
+	{"UserLog", Version5, []string{
+		ArgTimestamp, ArgTaskID, ArgKeyStringID, ArgStackID}},
+	// The timestamp of a sample is the time it was taken, the batch holding
+	// samples is written when the profiler is read and belongs to no P.
+	{"CPUSample", Version6, []string{ArgTimestamp, ArgRealTimestamp,
+		ArgProcessorID, ArgGoroutineID, ArgStackID}},
 }
@@ -1,10 +1,13 @@
 package event
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestVersionDrift(t *testing.T) {
-	if Latest != Version4 {
-		// When adding Version4 this will help remind me to update tests that
+	if Latest != Version6 {
+		// When adding Version6 this will help remind me to update tests that
 		// literal versions are used.
 		t.Fatal(`Make sure to update tests where Versions are used.`)
 	}
@@ -19,6 +22,8 @@ func TestVersionValid(t *testing.T) {
 		{Version2, true},
 		{Version3, true},
 		{Version4, true},
+		{Version5, true},
+		{Version6, true},
 		{Latest, true},
 		{Latest + 1, false},
 		{Latest + 2, false},
@@ -35,7 +40,7 @@ func TestVersionValid(t *testing.T) {
 }
 
 func TestVersionComparable(t *testing.T) {
-	order := []Version{0, Version1, Version2, Version3, Version(4), Version(5)}
+	order := []Version{0, Version1, Version2, Version3, Version(4), Version(5), Version(6)}
 	for i, ver := range order {
 		if i > 0 {
 			if older := order[i-1]; older > ver {
@@ -62,7 +67,9 @@ func TestVersionGo(t *testing.T) {
 		{Version2, `1.7`},
 		{Version3, `1.8`},
 		{Version4, `1.9`},
-		{Latest, `1.9`},
+		{Version5, `1.11`},
+		{Version6, `1.19`},
+		{Latest, `1.19`},
 		{Latest + 1, `None`},
 		{Latest + 2, `None`},
 		{Latest + 3, `None`},
@@ -85,7 +92,9 @@ func TestVersionTypes(t *testing.T) {
 		{Version1, 37},
 		{Version2, 41},
 		{Version3, 43},
-		{Version4, int(EvCount)},
+		{Version4, 45},
+		{Version5, 49},
+		{Version6, int(EvCount)},
 		{Latest, int(EvCount)},
 		{Latest + 1, 0},
 		{Latest + 2, 0},
@@ -112,7 +121,9 @@ func TestVersionString(t *testing.T) {
 		{Version2, `Version(#2 [Go 1.7])`},
 		{Version3, `Version(#3 [Go 1.8])`},
 		{Version4, `Version(#4 [Go 1.9])`},
-		{Latest, `Version(#4 [Go 1.9])`},
+		{Version5, `Version(#5 [Go 1.11])`},
+		{Version6, `Version(#6 [Go 1.19])`},
+		{Latest, `Version(#6 [Go 1.19])`},
 		{Latest + 1, `Version(none)`},
 		{Latest + 3, `Version(none)`},
 		{Latest + 2, `Version(none)`},
@@ -126,3 +137,151 @@ func TestVersionString(t *testing.T) {
 		}
 	}
 }
+
+func TestVersionSupports(t *testing.T) {
+	tests := []struct {
+		v   Version
+		typ Type
+		exp bool
+	}{
+		{Version1, EvBatch, true},
+		{Version1, EvString, false},
+		{Version2, EvString, true},
+		{Version4, EvUserLog, false},
+		{Version5, EvUserLog, true},
+		{Version5, EvCPUSample, false},
+		{Version6, EvCPUSample, true},
+		{Version6, EvNone, false},
+		{Version6, EvCount, false},
+		{Version(0), EvBatch, false},
+		{Latest + 1, EvBatch, false},
+	}
+	for _, test := range tests {
+		if got := test.v.Supports(test.typ); got != test.exp {
+			t.Fatalf(`exp %v.Supports(%v) to be %v; got %v`,
+				test.v, test.typ, test.exp, got)
+		}
+	}
+
+	// Supports agrees with the types declared by each version.
+	for v := Version1; v <= Latest; v++ {
+		for _, typ := range v.Types() {
+			if typ != EvNone && !v.Supports(typ) {
+				t.Fatalf(`exp %v to support declared type %v`, v, typ)
+			}
+			if typ.RemovedIn() != 0 {
+				t.Fatalf(`exp %v to not be removed; got %v`, typ, typ.RemovedIn())
+			}
+		}
+	}
+}
+
+func TestVersionForGo(t *testing.T) {
+	tests := []struct {
+		release string
+		exp     Version
+		ok      bool
+	}{
+		{`1.5`, Version1, true},
+		{`go1.6`, Version1, true},
+		{`1.8`, Version3, true},
+		{`go1.9.7`, Version4, true},
+		{`go1.10.8`, 0, false},
+		{`1.11`, Version5, true},
+		{`go1.18beta1`, Version5, true},
+		{`go1.19rc1`, Version6, true},
+		{`go1.20.14`, Version6, true},
+		{`1.21.0`, 0, false},
+		{`go1.22`, 0, false},
+		{`1.4`, 0, false},
+		{`2.0`, 0, false},
+		{`1.`, 0, false},
+		{`1.x`, 0, false},
+		{`1.11x`, 0, false},
+		{``, 0, false},
+	}
+	for _, test := range tests {
+		got, ok := VersionForGo(test.release)
+		if got != test.exp || ok != test.ok {
+			t.Fatalf(`exp VersionForGo(%q) to return %v, %v; got %v, %v`,
+				test.release, test.exp, test.ok, got, ok)
+		}
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	for v := Version1; v <= Latest; v++ {
+		schemas := Schemas(v)
+		if exp := len(v.Types()) - 1; len(schemas) != exp {
+			t.Fatalf(`exp %v schemas for %v; got %v`, exp, v, len(schemas))
+		}
+		for _, s := range schemas {
+			if s.Type == EvNone || s.Name != s.Type.Name() || s.Since != s.Type.Since() {
+				t.Fatalf(`exp schema of %v to describe it; got %+v`, s.Type, s)
+			}
+			if len(s.Kinds) != len(s.Args) {
+				t.Fatalf(`exp a kind for each arg of %v; got %+v`, s.Type, s)
+			}
+			exp := s.Type.Args()
+			if s.Type == EvGCSTWStart && v < Version5 {
+				exp = exp[:1]
+			}
+			if v > Version1 && !reflect.DeepEqual(s.Args, exp) {
+				t.Fatalf(`exp args of %v in %v to be %v; got %v`,
+					s.Type, v, exp, s.Args)
+			}
+		}
+	}
+
+	find := func(v Version, typ Type) Schema {
+		for _, s := range Schemas(v) {
+			if s.Type == typ {
+				return s
+			}
+		}
+		t.Fatalf(`exp schema for %v in %v`, typ, v)
+		return Schema{}
+	}
+	tests := []struct {
+		typ  Type
+		exp  []string
+		kind []Kind
+	}{
+		{EvBatch, []string{ArgProcessorID, ArgSequence, ArgTimestamp},
+			[]Kind{KindProcessor, KindSequence, KindTimestamp}},
+		{EvFrequency, []string{ArgFrequency, ArgUnused},
+			[]Kind{KindFrequency, KindNone}},
+		{EvGoStart, []string{ArgSequenceDelta, ArgTimestamp, ArgGoroutineID},
+			[]Kind{KindSequence, KindTimestamp, KindGoroutine}},
+		{EvGCStart, []string{ArgSequenceDelta, ArgTimestamp, ArgStackID},
+			[]Kind{KindSequence, KindTimestamp, KindStack}},
+		{EvGoSysExit, []string{ArgSequenceDelta, ArgTimestamp, ArgGoroutineID,
+			ArgSequence, ArgRealTimestamp}, []Kind{KindSequence, KindTimestamp,
+			KindGoroutine, KindSequence, KindRealTime}},
+	}
+	for _, test := range tests {
+		s := find(Version1, test.typ)
+		if !reflect.DeepEqual(s.Args, test.exp) || !reflect.DeepEqual(s.Kinds, test.kind) {
+			t.Fatalf(`exp %v Version1 args %v %v; got %v %v`,
+				test.typ, test.exp, test.kind, s.Args, s.Kinds)
+		}
+	}
+	if s := find(Version1, EvStack); s.FrameSize != 1 {
+		t.Fatalf(`exp Version1 frames of 1 arg; got %v`, s.FrameSize)
+	}
+	if s := find(Latest, EvStack); s.FrameSize != 4 {
+		t.Fatalf(`exp frames of 4 args; got %v`, s.FrameSize)
+	}
+	if s := find(Latest, EvGoCreate); s.FrameSize != 0 {
+		t.Fatalf(`exp no frames for GoCreate; got %v`, s.FrameSize)
+	}
+
+	schemas := Schemas(Latest)
+	schemas[0].Args[0] = `mutated`
+	if Schemas(Latest)[0].Args[0] == `mutated` || EvBatch.Args()[0] == `mutated` {
+		t.Fatal(`exp schemas to be copies`)
+	}
+	if Schemas(0) != nil || Schemas(Latest+1) != nil {
+		t.Fatal(`exp nil schemas for invalid versions`)
+	}
+}
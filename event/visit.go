@@ -1,5 +1,7 @@
 package event
 
+import "fmt"
+
 // Visitor is the interface that wraps the basic Visit method.
 //
 // Implementations of Visit indicate they may visit one or more events within
@@ -13,3 +15,106 @@ type errVisitor struct{ err error }
 func (v errVisitor) Visit(evt *Event) (err error) {
 	return v.err
 }
+
+// VisitorFunc is an adapter to allow the use of ordinary functions as a
+// Visitor.
+type VisitorFunc func(evt *Event) error
+
+// Visit calls fn(evt).
+func (fn VisitorFunc) Visit(evt *Event) error {
+	return fn(evt)
+}
+
+// MultiVisitor returns a Visitor which visits each event with every given
+// Visitor in order, such as to build a Trace while collecting statistics from
+// a single pass over a stream. The first error returned by a Visitor stops the
+// event from reaching those after it and is returned by Visit. See Collect for
+// visiting every Visitor regardless of errors.
+func MultiVisitor(v ...Visitor) Visitor {
+	return multiVisitor(append([]Visitor(nil), v...))
+}
+
+type multiVisitor []Visitor
+
+func (mv multiVisitor) Visit(evt *Event) error {
+	for _, v := range mv {
+		if err := v.Visit(evt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Collector is a Visitor which visits each event with every Visitor it was
+// created with, recording their errors rather than returning them so a stream
+// may be visited in full. See Collect.
+type Collector struct {
+	visitors []Visitor
+	errs     VisitErrors
+}
+
+// Collect returns a Collector which visits each event with every given Visitor
+// in order.
+func Collect(v ...Visitor) *Collector {
+	return &Collector{visitors: append([]Visitor(nil), v...)}
+}
+
+// Visit visits evt with every Visitor, recording any errors returned. It
+// always returns nil.
+func (c *Collector) Visit(evt *Event) error {
+	for i, v := range c.visitors {
+		if err := v.Visit(evt); err != nil {
+			c.errs = append(c.errs, &VisitError{Visitor: i, Type: evt.Type, Err: err})
+		}
+	}
+	return nil
+}
+
+// Errors returns the errors recorded so far in the order they occurred.
+func (c *Collector) Errors() VisitErrors {
+	return c.errs
+}
+
+// Err returns the errors recorded so far as a VisitErrors, or nil if there are
+// none.
+func (c *Collector) Err() error {
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return c.errs
+}
+
+// VisitError is an error returned by a Visitor of a Collector.
+type VisitError struct {
+
+	// Visitor is the position of the Visitor given to Collect and Type the
+	// type of event it failed to visit.
+	Visitor int
+	Type    Type
+	Err     error
+}
+
+// Error implements the error interface.
+func (e *VisitError) Error() string {
+	return fmt.Sprintf(`visitor %v failed to visit %v: %v`,
+		e.Visitor, e.Type, e.Err)
+}
+
+// Unwrap returns the error returned by the Visitor.
+func (e *VisitError) Unwrap() error {
+	return e.Err
+}
+
+// VisitErrors holds the errors recorded by a Collector.
+type VisitErrors []*VisitError
+
+// Error implements the error interface.
+func (e VisitErrors) Error() string {
+	switch len(e) {
+	case 0:
+		return `no visit errors`
+	case 1:
+		return e[0].Error()
+	}
+	return fmt.Sprintf(`%v (and %v more errors)`, e[0], len(e)-1)
+}
@@ -0,0 +1,89 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	errDupString = errors.New(`trace string already exists`)
+	errDupStack  = errors.New(`trace stack already exists`)
+)
+
+// Warning describes a non-fatal inconsistency found while visiting events with
+// a Lenient Trace, see the Warnings method of Trace.
+type Warning struct {
+
+	// Off is the offset of the event in the input stream and Type its type.
+	Off  int
+	Type Type
+
+	// Msg describes the inconsistency.
+	Msg string
+}
+
+// String implements fmt.Stringer.
+func (w Warning) String() string {
+	return fmt.Sprintf(`%v at 0x%x: %v`, w.Type.Name(), w.Off, w.Msg)
+}
+
+// Warnings returns the inconsistencies found while visiting events with a
+// Lenient Trace in the order they were visited, followed by the events which
+// referred to stacks that were never declared in the order of their offset.
+// Stacks are declared at the end of a trace, so the latter are determined
+// when Warnings is called and include stacks evicted due to Limits.
+func (tr *Trace) Warnings() []Warning {
+	out := append([]Warning(nil), tr.warnings...)
+	var missing []Warning
+	for id, w := range tr.stackRefs {
+		if _, ok := tr.Stacks[id]; !ok {
+			missing = append(missing, w)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].Off < missing[j].Off
+	})
+	return append(out, missing...)
+}
+
+// warn records err as a warning for evt and returns nil when it is non-fatal
+// and this Trace is Lenient, otherwise it returns err.
+func (tr *Trace) warn(evt *Event, err error) error {
+	if !tr.Lenient || (err != errDupString && err != errDupStack) {
+		return err
+	}
+	msg := fmt.Sprintf(`%v for id %v`, err, evt.Args[0])
+	tr.warnings = append(tr.warnings, Warning{Off: evt.Off, Type: evt.Type, Msg: msg})
+	return nil
+}
+
+// refStacks records the first event referring to each stack id when this Trace
+// is Lenient, so references to undeclared stacks may be reported.
+func (tr *Trace) refStacks(evt *Event) {
+	if !tr.Lenient || evt.Type == EvStack {
+		return
+	}
+	argoff := versions[tr.Version].argOffset
+	for i, kind := range evt.Type.ArgKinds() {
+		if kind != KindStack || i+argoff >= len(evt.Args) {
+			continue
+		}
+		name := evt.Type.Args()[i]
+		if name == ArgNewStackID && tr.Version == Version1 {
+			continue
+		}
+		id := evt.Args[i+argoff]
+		if id == 0 {
+			continue
+		}
+		if _, ok := tr.stackRefs[id]; ok {
+			continue
+		}
+		if tr.stackRefs == nil {
+			tr.stackRefs = make(map[uint64]Warning)
+		}
+		tr.stackRefs[id] = Warning{Off: evt.Off, Type: evt.Type,
+			Msg: fmt.Sprintf(`%v %v was never declared`, name, id)}
+	}
+}
@@ -0,0 +1,361 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// Limits on the expressions accepted by Parse, so user supplied input may not
+// exhaust the stack or memory of the caller.
+const (
+	maxExprLen   = 4096
+	maxExprDepth = 32
+)
+
+// Parse compiles the filter expression s into a Filter built from the
+// combinators of this package. It is intended for filters supplied by users
+// of command line tools and services, so any input will either compile or
+// return an error.
+//
+// An expression compares the fields of an event, joined by && and || and
+// negated by !, with parentheses to group them:
+//
+//	type in (GoBlockRecv, GoBlockSend) && g == 42 && ts > 1500000
+//	!(p == -1 || type == Batch)
+//
+// The fields are type, g, p and ts. Each may be compared with a value by ==
+// or != or tested against a parenthesized list of values by in, while all
+// but type may be ordered by <, <=, > and >=. Types are named as accepted by
+// event.TypeFromString. Timestamps are integers compared with the Ts field of
+// events, which a Decoder sets in CPU ticks since an arbitrary epoch unless it
+// was configured with encoding.Nanoseconds, so durations such as 250ms are not
+// accepted.
+func Parse(s string) (Filter, error) {
+	if len(s) > maxExprLen {
+		return nil, fmt.Errorf(`expression length %v exceeds limit of %v`,
+			len(s), maxExprLen)
+	}
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	f, err := p.or(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.unexpected(tok)
+	}
+	return f, nil
+}
+
+// MustParse is like Parse but panics if the expression does not compile.
+func MustParse(s string) Filter {
+	f, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+type tokKind byte
+
+const (
+	tokEOF tokKind = iota
+	tokWord
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokKind
+	text string
+	off  int
+}
+
+// ops are the operators of the expression language, with longer operators
+// listed first so they are matched before their prefixes.
+var ops = []string{`&&`, `||`, `==`, `!=`, `<=`, `>=`, `<`, `>`, `!`}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '(':
+			toks = append(toks, token{tokLParen, `(`, i})
+			i++
+			continue
+		case c == ')':
+			toks = append(toks, token{tokRParen, `)`, i})
+			i++
+			continue
+		case c == ',':
+			toks = append(toks, token{tokComma, `,`, i})
+			i++
+			continue
+		case isWordByte(c) || (c == '-' && i+1 < len(s) && isWordByte(s[i+1])):
+			j := i + 1
+			for j < len(s) && isWordByte(s[j]) {
+				j++
+			}
+			toks = append(toks, token{tokWord, s[i:j], i})
+			i = j
+			continue
+		}
+
+		var op string
+		for _, o := range ops {
+			if strings.HasPrefix(s[i:], o) {
+				op = o
+				break
+			}
+		}
+		if op == `` {
+			return nil, fmt.Errorf(`unexpected %q at offset %v`, c, i)
+		}
+		toks = append(toks, token{tokOp, op, i})
+		i += len(op)
+	}
+	return append(toks, token{tokEOF, ``, len(s)}), nil
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '.' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	tok := p.toks[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) unexpected(tok token) error {
+	if tok.kind == tokEOF {
+		return fmt.Errorf(`unexpected end of expression at offset %v`, tok.off)
+	}
+	return fmt.Errorf(`unexpected %q at offset %v`, tok.text, tok.off)
+}
+
+func (p *parser) expect(kind tokKind) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, p.unexpected(tok)
+	}
+	return tok, nil
+}
+
+func (p *parser) or(depth int) (Filter, error) {
+	f, err := p.and(depth)
+	if err != nil {
+		return nil, err
+	}
+	fs := []Filter{f}
+	for tok := p.peek(); tok.kind == tokOp && tok.text == `||`; tok = p.peek() {
+		p.next()
+		if f, err = p.and(depth); err != nil {
+			return nil, err
+		}
+		fs = append(fs, f)
+	}
+	if len(fs) == 1 {
+		return fs[0], nil
+	}
+	return Or(fs...), nil
+}
+
+func (p *parser) and(depth int) (Filter, error) {
+	f, err := p.unary(depth)
+	if err != nil {
+		return nil, err
+	}
+	fs := []Filter{f}
+	for tok := p.peek(); tok.kind == tokOp && tok.text == `&&`; tok = p.peek() {
+		p.next()
+		if f, err = p.unary(depth); err != nil {
+			return nil, err
+		}
+		fs = append(fs, f)
+	}
+	if len(fs) == 1 {
+		return fs[0], nil
+	}
+	return And(fs...), nil
+}
+
+func (p *parser) unary(depth int) (Filter, error) {
+	if depth >= maxExprDepth {
+		return nil, fmt.Errorf(`expression nesting exceeds limit of %v at offset %v`,
+			maxExprDepth, p.peek().off)
+	}
+	switch tok := p.peek(); {
+	case tok.kind == tokOp && tok.text == `!`:
+		p.next()
+		f, err := p.unary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Not(f), nil
+	case tok.kind == tokLParen:
+		p.next()
+		f, err := p.or(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	return p.compare()
+}
+
+// field is an event field which may be compared by an expression, with parse
+// returning the value of a literal and value the field of an event.
+type field struct {
+	parse   func(s string) (int64, error)
+	value   func(evt *event.Event) int64
+	ordered bool
+}
+
+var fields = map[string]field{
+	`type`: {
+		parse: parseType,
+		value: func(evt *event.Event) int64 { return int64(evt.Type) },
+	},
+	`g`: {
+		parse:   parseInt,
+		value:   func(evt *event.Event) int64 { return evt.G },
+		ordered: true,
+	},
+	`p`: {
+		parse:   parseInt,
+		value:   func(evt *event.Event) int64 { return evt.P },
+		ordered: true,
+	},
+	`ts`: {
+		parse:   parseInt,
+		value:   func(evt *event.Event) int64 { return evt.Ts },
+		ordered: true,
+	},
+}
+
+func parseType(s string) (int64, error) {
+	typ, ok := event.TypeFromString(s)
+	if !ok {
+		return 0, fmt.Errorf(`event type %q is unknown`, s)
+	}
+	return int64(typ), nil
+}
+
+func parseInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf(`value %q is not an integer`, s)
+	}
+	return n, nil
+}
+
+func (p *parser) compare() (Filter, error) {
+	name, err := p.expect(tokWord)
+	if err != nil {
+		return nil, err
+	}
+	fd, ok := fields[strings.ToLower(name.text)]
+	if !ok {
+		return nil, fmt.Errorf(`field %q at offset %v is unknown`, name.text, name.off)
+	}
+
+	op := p.next()
+	if op.kind == tokWord && strings.EqualFold(op.text, `in`) {
+		return p.in(fd)
+	}
+	if op.kind != tokOp {
+		return nil, p.unexpected(op)
+	}
+	switch op.text {
+	case `==`, `!=`:
+	case `<`, `<=`, `>`, `>=`:
+		if !fd.ordered {
+			return nil, fmt.Errorf(`field %q may not be ordered by %v at offset %v`,
+				name.text, op.text, op.off)
+		}
+	default:
+		return nil, p.unexpected(op)
+	}
+
+	v, err := p.value(fd)
+	if err != nil {
+		return nil, err
+	}
+	get := fd.value
+	switch op.text {
+	case `==`:
+		return Func(func(evt *event.Event) bool { return get(evt) == v }), nil
+	case `!=`:
+		return Func(func(evt *event.Event) bool { return get(evt) != v }), nil
+	case `<`:
+		return Func(func(evt *event.Event) bool { return get(evt) < v }), nil
+	case `<=`:
+		return Func(func(evt *event.Event) bool { return get(evt) <= v }), nil
+	case `>`:
+		return Func(func(evt *event.Event) bool { return get(evt) > v }), nil
+	default:
+		return Func(func(evt *event.Event) bool { return get(evt) >= v }), nil
+	}
+}
+
+func (p *parser) in(fd field) (Filter, error) {
+	if _, err := p.expect(tokLParen); err != nil {
+		return nil, err
+	}
+	set := make(map[int64]bool)
+	for {
+		v, err := p.value(fd)
+		if err != nil {
+			return nil, err
+		}
+		set[v] = true
+
+		tok := p.next()
+		if tok.kind == tokRParen {
+			break
+		}
+		if tok.kind != tokComma {
+			return nil, p.unexpected(tok)
+		}
+	}
+	get := fd.value
+	return Func(func(evt *event.Event) bool { return set[get(evt)] }), nil
+}
+
+func (p *parser) value(fd field) (int64, error) {
+	tok, err := p.expect(tokWord)
+	if err != nil {
+		return 0, err
+	}
+	v, err := fd.parse(tok.text)
+	if err != nil {
+		return 0, fmt.Errorf(`%v at offset %v`, err, tok.off)
+	}
+	return v, nil
+}
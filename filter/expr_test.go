@@ -0,0 +1,91 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+func TestParse(t *testing.T) {
+	recv := &event.Event{Type: event.EvGoBlockRecv, P: 1, G: 42, Ts: 2e9}
+	send := &event.Event{Type: event.EvGoBlockSend, P: -1, G: 7, Ts: 1e9}
+	batch := &event.Event{Type: event.EvBatch, P: 3, Ts: 1500}
+
+	tests := []struct {
+		expr string
+		exp  [3]bool
+	}{
+		{`type in (GoBlockRecv, GoBlockSend) && g == 42 && ts > 1500000000`, [3]bool{true, false, false}},
+		{`type in (GoBlockRecv, EvGoBlockSend)`, [3]bool{true, true, false}},
+		{`type == batch`, [3]bool{false, false, true}},
+		{`type != Batch`, [3]bool{true, true, false}},
+		{`g != 42`, [3]bool{false, true, true}},
+		{`G in (7, 0)`, [3]bool{false, true, true}},
+		{`p == -1`, [3]bool{false, true, false}},
+		{`p >= 1 && p < 3`, [3]bool{true, false, false}},
+		{`ts <= 1500`, [3]bool{false, false, true}},
+		{`ts >= 1000000000`, [3]bool{true, true, false}},
+		{`g == 7 || p == 3`, [3]bool{false, true, true}},
+		{`g == 7 || p == 3 && ts > 1000000000`, [3]bool{false, true, false}},
+		{`(g == 7 || p == 3) && ts < 1000000000`, [3]bool{false, false, true}},
+		{`!(p == -1 || type == Batch)`, [3]bool{true, false, false}},
+		{`!!g == 42`, [3]bool{true, false, false}},
+	}
+	for _, test := range tests {
+		f, err := Parse(test.expr)
+		if err != nil {
+			t.Fatalf(`exp nil err for %q; got %v`, test.expr, err)
+		}
+		for i, evt := range []*event.Event{recv, send, batch} {
+			if got := f.Match(evt); got != test.exp[i] {
+				t.Fatalf(`exp %q to match event #%v %v; got %v`,
+					test.expr, i, test.exp[i], got)
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr, exp string
+	}{
+		{``, `unexpected end of expression at offset 0`},
+		{`g == `, `unexpected end of expression at offset 5`},
+		{`g = 1`, `unexpected '=' at offset 2`},
+		{`g == 1 &&`, `unexpected end of expression at offset 9`},
+		{`g == 1 g == 2`, `unexpected "g" at offset 7`},
+		{`(g == 1`, `unexpected end of expression at offset 7`},
+		{`g == 1)`, `unexpected ")" at offset 6`},
+		{`m == 1`, `field "m" at offset 0 is unknown`},
+		{`g == x`, `value "x" is not an integer at offset 5`},
+		{`ts > 1.5`, `value "1.5" is not an integer at offset 5`},
+		{`ts > 1.5s`, `value "1.5s" is not an integer at offset 5`},
+		{`type == Nope`, `event type "Nope" is unknown at offset 8`},
+		{`type < Batch`, `field "type" may not be ordered by < at offset 5`},
+		{`type in Batch`, `unexpected "Batch" at offset 8`},
+		{`type in (Batch,)`, `unexpected ")" at offset 15`},
+		{`g in (1 2)`, `unexpected "2" at offset 8`},
+		{`g !`, `unexpected "!" at offset 2`},
+		{strings.Repeat(`!`, 40) + `g == 1`, `expression nesting exceeds limit of 32 at offset 32`},
+		{strings.Repeat(`(`, 40) + `g == 1` + strings.Repeat(`)`, 40),
+			`expression nesting exceeds limit of 32 at offset 32`},
+		{strings.Repeat(` `, maxExprLen+1), `expression length 4097 exceeds limit of 4096`},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.expr)
+		if err == nil {
+			t.Fatalf(`exp non-nil err for %q`, test.expr)
+		}
+		if got := err.Error(); got != test.exp {
+			t.Fatalf(`exp err %q for %q; got %q`, test.exp, test.expr, got)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal(`exp MustParse to panic`)
+		}
+	}()
+	MustParse(`g ==`)
+}
@@ -0,0 +1,149 @@
+// Package filter implements composable predicates over trace events, along
+// with Stream for writing the events of a trace that match them.
+package filter
+
+import (
+	"fmt"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// Filter matches events, see Stream.
+type Filter interface {
+	Match(evt *event.Event) bool
+}
+
+// Func is an adapter to allow the use of ordinary functions as a Filter, such
+// as the predicates of the event package.
+type Func func(evt *event.Event) bool
+
+// Match implements Filter by calling fn.
+func (fn Func) Match(evt *event.Event) bool { return fn(evt) }
+
+// ByType returns a Filter matching events of any of the given types.
+func ByType(types ...event.Type) Filter {
+	return Func(event.ByType(types...))
+}
+
+// ByTimeRange returns a Filter matching events with a Ts field within
+// [start, end), with an end of zero leaving the range unbounded.
+func ByTimeRange(start, end int64) Filter {
+	return Func(event.ByTime(start, end))
+}
+
+// ByGoroutine returns a Filter matching events with a G field of any of the
+// given goroutines.
+func ByGoroutine(gs ...uint64) Filter {
+	return Func(event.ByGoroutine(gs...))
+}
+
+// ByP returns a Filter matching events with a P field of any of the given Ps.
+func ByP(ps ...int64) Filter {
+	set := make(map[int64]bool, len(ps))
+	for _, p := range ps {
+		set[p] = true
+	}
+	return Func(func(evt *event.Event) bool {
+		return set[evt.P]
+	})
+}
+
+// ByStackFunc returns a Filter matching events with a stack holding a frame
+// whose function matches the regular expression pattern, as by the
+// StacksByFunc method of Trace. Stacks are resolved from tr, which must have
+// visited them beforehand as the runtime declares stacks at the end of a
+// trace. Events are read in the layout of the Version of tr.
+func ByStackFunc(tr *event.Trace, pattern string) (Filter, error) {
+	matched, err := tr.StacksByFunc(pattern)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[uint64]bool, len(matched))
+	for _, id := range matched {
+		ids[id] = true
+	}
+	off := 0
+	if tr.Version == event.Version1 {
+		off = 1
+	}
+	return Func(func(evt *event.Event) bool {
+		i, ok := evt.Type.Arg(event.ArgStackID)
+		if !ok || evt.Type == event.EvStack || i+off >= len(evt.Args) {
+			return false
+		}
+		return ids[evt.Args[i+off]]
+	}), nil
+}
+
+// And returns a Filter matching events matched by every filter in fs, or all
+// events when fs is empty.
+func And(fs ...Filter) Filter {
+	return Func(func(evt *event.Event) bool {
+		for _, f := range fs {
+			if !f.Match(evt) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Or returns a Filter matching events matched by any filter in fs, or no
+// events when fs is empty.
+func Or(fs ...Filter) Filter {
+	return Func(func(evt *event.Event) bool {
+		for _, f := range fs {
+			if f.Match(evt) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Not returns a Filter matching the events f does not.
+func Not(f Filter) Filter {
+	return Func(func(evt *event.Event) bool {
+		return !f.Match(evt)
+	})
+}
+
+// Stream decodes the events of dec and emits those matched by f to enc, which
+// should target the version of the trace read by dec. The EvBatch, EvFrequency,
+// EvString and EvStack events are always emitted so the output may be decoded,
+// and the timestamp of each event dropped is carried to the next event of its
+// batch so the timestamps of emitted events are unchanged.
+//
+// The Encoder is flushed once the final event is written but not closed. The
+// first error from decoding or encoding is returned. Traces in the Version1
+// format are not supported, as their events also carry sequence deltas.
+func Stream(dec *encoding.Decoder, enc *encoding.Encoder, f Filter) error {
+	ver, err := dec.Version()
+	if err != nil {
+		return err
+	}
+	if ver == event.Version1 {
+		return fmt.Errorf(`filtering %v is not supported`, ver)
+	}
+
+	// Timestamps within a batch are deltas from the prior event of the batch.
+	var carry encoding.Carry
+	err = dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+		switch evt.Type {
+		case event.EvBatch, event.EvFrequency, event.EvString, event.EvStack:
+			carry.Keep(evt)
+			return enc.Emit(evt)
+		}
+		if !f.Match(evt) {
+			carry.Drop(evt)
+			return nil
+		}
+		carry.Keep(evt)
+		return enc.Emit(evt)
+	}))
+	if err != nil {
+		return err
+	}
+	return enc.Flush()
+}
@@ -0,0 +1,143 @@
+package filter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/internal/tracefile"
+)
+
+var traceList tracefile.TraceList
+
+func init() {
+	var err error
+	traceList, err = tracefile.Load(`../internal/tracefile`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	evt := &event.Event{Type: event.EvGoStart, P: 2, G: 5, Ts: 100}
+	tests := []struct {
+		f   Filter
+		exp bool
+	}{
+		{ByType(event.EvGoStart), true},
+		{ByType(event.EvGoEnd), false},
+		{ByTimeRange(100, 0), true},
+		{ByTimeRange(0, 100), false},
+		{ByGoroutine(1, 5), true},
+		{ByP(1), false},
+		{ByP(2), true},
+		{And(), true},
+		{And(ByP(2), ByGoroutine(5)), true},
+		{And(ByP(2), ByGoroutine(4)), false},
+		{Or(), false},
+		{Or(ByP(1), ByGoroutine(5)), true},
+		{Not(ByP(2)), false},
+		{Not(Or(ByP(1), ByType(event.EvGoEnd))), true},
+	}
+	for i, test := range tests {
+		if got := test.f.Match(evt); got != test.exp {
+			t.Fatalf(`exp test #%d to match %v; got %v`, i, test.exp, got)
+		}
+	}
+}
+
+func TestByStackFunc(t *testing.T) {
+	tr, err := event.NewTrace(event.Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Stacks[7] = event.Stack{event.NewFrame(0x10, `main.worker`, `main.go`, 1)}
+	tr.Stacks[8] = event.Stack{event.NewFrame(0x20, `runtime.main`, `proc.go`, 1)}
+
+	f, err := ByStackFunc(tr, `^main\.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		evt *event.Event
+		exp bool
+	}{
+		{event.MustNew(event.EvGoBlock, 0, 7), true},
+		{event.MustNew(event.EvGoBlock, 0, 8), false},
+		{event.MustNew(event.EvGoCreate, 0, 1, 8, 7), true},
+		{event.MustNew(event.EvGoEnd, 0), false},
+		{event.MustNew(event.EvStack, 7, 0), false},
+	} {
+		if got := f.Match(test.evt); got != test.exp {
+			t.Fatalf(`exp %v to match %v; got %v`, test.evt.Type, test.exp, got)
+		}
+	}
+	if _, err := ByStackFunc(tr, `(`); err == nil {
+		t.Fatal(`exp error for invalid pattern`)
+	}
+}
+
+func TestStream(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		if tf.Version == event.Version1 {
+			continue
+		}
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			f := Or(ByType(event.EvGoCreate, event.EvGoEnd), ByP(1))
+			type stamp struct {
+				typ event.Type
+				ts  int64
+			}
+			var exp []stamp
+			dec := encoding.NewDecoder(bytes.NewReader(tf.Bytes()))
+			err := dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+				if evt.Type != event.EvBatch && f.Match(evt) {
+					exp = append(exp, stamp{evt.Type, evt.Ts})
+				}
+				return nil
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			dec = encoding.NewDecoder(bytes.NewReader(tf.Bytes()))
+			enc := encoding.NewEncoder(&buf, encoding.TargetVersion(tf.Version))
+			if err := Stream(dec, enc, f); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() == 0 || buf.Len() >= len(tf.Bytes()) {
+				t.Fatalf(`exp output smaller than %v bytes; got %v`, len(tf.Bytes()), buf.Len())
+			}
+
+			var got []stamp
+			dec = encoding.NewDecoder(&buf)
+			err = dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+				switch evt.Type {
+				case event.EvBatch, event.EvFrequency, event.EvString, event.EvStack:
+				default:
+					got = append(got, stamp{evt.Type, evt.Ts})
+				}
+				return nil
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) == 0 || len(got) != len(exp) {
+				t.Fatalf(`exp %v events; got %v`, len(exp), len(got))
+			}
+			for i := range exp {
+				if got[i] != exp[i] {
+					t.Fatalf(`exp event #%v to be %+v; got %+v`, i, exp[i], got[i])
+				}
+			}
+		})
+	}
+
+	old := traceList.ByVersion(event.Version1)[0].Bytes()
+	dec := encoding.NewDecoder(bytes.NewReader(old))
+	if err := Stream(dec, encoding.NewEncoder(&bytes.Buffer{}), ByP(0)); err == nil {
+		t.Fatal(`exp error for Version1 input`)
+	}
+}
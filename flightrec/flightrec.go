@@ -0,0 +1,266 @@
+// Package flightrec implements a flight recorder, which consumes a live trace
+// stream into a bounded ring of its most recent batches so a trace of the last
+// moments of a program may be written on demand, such as when a request was
+// found to be slow or an error occurred.
+//
+// A Recorder is fed the output of the runtime tracer, for example:
+//
+//	r, w := io.Pipe()
+//	if err := trace.Start(w); err != nil {
+//		return err
+//	}
+//	rec := flightrec.New(10*time.Second, 1024)
+//	go rec.Consume(r)
+//	...
+//	err := rec.Dump(f, 5*time.Second)
+package flightrec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/rewrite"
+)
+
+// Recorder retains the most recent batches of a trace stream. The strings and
+// stacks declared by the stream are retained for its whole duration, as the
+// events of recent batches may refer to those declared long before them.
+//
+// The runtime declares the frequency of its clock in the trace footer, which
+// is not written until tracing stops. Until then the frequency is estimated
+// from the wall clock time batches were received at.
+//
+// The runtime also declares every stack in a table following the footer, so
+// the stacks of a live stream are not known until tracing stops. The events of
+// a trace dumped before then refer to stacks it does not declare.
+type Recorder struct {
+	window time.Duration
+	size   int
+	now    func() time.Time
+
+	mu      sync.Mutex
+	ver     event.Version
+	batches []*batch
+	strs    map[uint64]*event.Event
+	stks    map[uint64]*event.Event
+	freq    uint64
+
+	// first is the earliest start of any batch and the wall clock time the
+	// first batch was received at, last the latest end of any batch and the
+	// time the most recent batch was received at, for estimating the
+	// frequency.
+	first, last event.Anchor
+}
+
+// batch is a batch of events retained by a Recorder, beginning with its
+// EvBatch event. End is the timestamp of the last event of the batch.
+type batch struct {
+	evts []*event.Event
+	end  int64
+}
+
+// New returns a Recorder retaining the batches which ended within window of
+// the most recent batch, up to size batches. A window of zero retains batches
+// regardless of their age and a size of zero regardless of their number.
+func New(window time.Duration, size int) *Recorder {
+	return &Recorder{
+		window: window,
+		size:   size,
+		now:    time.Now,
+		strs:   make(map[uint64]*event.Event),
+		stks:   make(map[uint64]*event.Event),
+	}
+}
+
+// Consume decodes the trace stream r into the Recorder until it ends, which
+// must be Version2 or later. It returns nil when the stream ended cleanly
+// with or without the trace footer, otherwise the error which broke it. The
+// retained batches remain available to Dump once Consume has returned.
+func (rec *Recorder) Consume(r io.Reader) error {
+	dec := encoding.NewDecoder(r)
+	ver, err := dec.Version()
+	if err != nil {
+		return err
+	}
+	if ver == event.Version1 {
+		return fmt.Errorf(`recording %v is not supported`, ver)
+	}
+	rec.mu.Lock()
+	rec.ver = ver
+	rec.mu.Unlock()
+
+	var cur *batch
+	for dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			break
+		}
+
+		switch evt.Type {
+		case event.EvBatch:
+			rec.add(cur)
+			cur = &batch{evts: []*event.Event{evt}, end: evt.Ts}
+			continue
+		case event.EvFrequency, event.EvString, event.EvStack:
+			rec.declare(evt)
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		cur.evts = append(cur.evts, evt)
+		if evt.Ts > cur.end {
+			cur.end = evt.Ts
+		}
+	}
+	rec.add(cur)
+	return dec.Err()
+}
+
+// declare records an EvFrequency, EvString or EvStack event.
+func (rec *Recorder) declare(evt *event.Event) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	switch evt.Type {
+	case event.EvFrequency:
+		if len(evt.Args) > 0 {
+			rec.freq = evt.Args[0]
+		}
+	case event.EvString:
+		rec.strs[evt.Args[0]] = evt
+	case event.EvStack:
+		rec.stks[evt.Args[0]] = evt
+	}
+}
+
+// add appends b to the ring, evicting the batches which fell outside of it.
+func (rec *Recorder) add(b *batch) {
+	if b == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	now := rec.now()
+	if start := b.evts[0].Ts; rec.first.Time.IsZero() {
+		rec.first = event.Anchor{Time: now, Ticks: start}
+	} else if start < rec.first.Ticks {
+		rec.first.Ticks = start
+	}
+	if b.end > rec.last.Ticks {
+		rec.last.Ticks = b.end
+	}
+	rec.last.Time = now
+	rec.batches = append(rec.batches, b)
+
+	if rec.size > 0 && len(rec.batches) > rec.size {
+		n := len(rec.batches) - rec.size
+		rec.batches = append(rec.batches[:0], rec.batches[n:]...)
+	}
+	if freq := rec.frequency(); rec.window > 0 && freq > 0 {
+		rec.batches = rec.since(rec.window, freq)
+	}
+}
+
+// since returns the retained batches which ended within d of the most recent
+// batch. Batches are received once they are full or their P stops, so they
+// are not ordered by the time they ended.
+func (rec *Recorder) since(d time.Duration, freq uint64) []*batch {
+	var (
+		min  = rec.last.Ticks - ticks(d, freq)
+		keep []*batch
+	)
+	for _, b := range rec.batches {
+		if b.end >= min {
+			keep = append(keep, b)
+		}
+	}
+	return keep
+}
+
+// frequency returns the frequency declared by the stream, or an estimate of it
+// when it has not yet been declared. Zero is returned when neither is known.
+func (rec *Recorder) frequency() uint64 {
+	if rec.freq > 0 {
+		return rec.freq
+	}
+	wall := rec.last.Time.Sub(rec.first.Time)
+	if wall <= 0 || rec.last.Ticks <= rec.first.Ticks {
+		return 0
+	}
+	return uint64(float64(rec.last.Ticks-rec.first.Ticks) / wall.Seconds())
+}
+
+// ticks returns the duration d in ticks of the given frequency.
+func ticks(d time.Duration, freq uint64) int64 {
+	return int64(d.Seconds() * float64(freq))
+}
+
+// Dump writes a trace of the retained batches which ended within d of the most
+// recent batch to w, or of every retained batch when d is zero. The trace is a
+// standalone trace of the version of the stream, holding only the strings and
+// stacks referred to by its events along with the frequency of the stream or
+// its estimate.
+//
+// The events of the trace may refer to goroutines and Ps whose state was
+// established by events which are no longer retained. A trace dumped before
+// the footer of the stream was received declares no stacks, as they follow
+// the footer, so the stacks of its events can not be resolved.
+func (rec *Recorder) Dump(w io.Writer, d time.Duration) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.ver == 0 {
+		return errors.New(`no trace stream has been recorded`)
+	}
+	freq := rec.frequency()
+	if freq == 0 {
+		return errors.New(`frequency of the trace stream is not yet known`)
+	}
+	batches := rec.batches
+	if d > 0 {
+		batches = rec.since(d, freq)
+	}
+
+	enc := encoding.NewEncoder(w, encoding.TargetVersion(rec.ver))
+	refs, err := rewrite.Drop()
+	if err != nil {
+		return err
+	}
+	emit := func(evt *event.Event) error {
+		return refs.Transform(evt.Copy(), enc.Emit)
+	}
+	for _, tbl := range []map[uint64]*event.Event{rec.strs, rec.stks} {
+		ids := make([]uint64, 0, len(tbl))
+		for id := range tbl {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			if err := emit(tbl[id]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, b := range batches {
+		for _, evt := range b.evts {
+			if err := emit(evt); err != nil {
+				return err
+			}
+		}
+	}
+	if err := emit(event.MustNew(event.EvFrequency, freq)); err != nil {
+		return err
+	}
+	if err := refs.Flush(enc.Emit); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,262 @@
+package flightrec
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/internal/tracefile"
+)
+
+var traceList tracefile.TraceList
+
+func init() {
+	var err error
+	traceList, err = tracefile.Load(`../internal/tracefile`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// dumped is the contents of a trace written by Dump.
+type dumped struct {
+	tr      *event.Trace
+	batches int
+	evts    int
+}
+
+// read decodes the trace b, checking it declares only the strings and stacks
+// its events refer to.
+func read(t *testing.T, b []byte) *dumped {
+	dec := encoding.NewDecoder(bytes.NewReader(b))
+	ver, err := dec.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := event.NewTrace(ver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &dumped{tr: tr}
+	decl, refs := make(map[uint64]bool), make(map[uint64]bool)
+	for dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			t.Fatal(err)
+		}
+		if err := tr.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+		switch evt.Type {
+		case event.EvBatch:
+			d.batches++
+			continue
+		case event.EvFrequency:
+			continue
+		case event.EvString, event.EvStack:
+			decl[evt.Args[0]] = true
+			if evt.Type == event.EvStack {
+				for pos := 2; pos+3 < len(evt.Args); pos += 4 {
+					refs[evt.Args[pos+1]], refs[evt.Args[pos+2]] = true, true
+				}
+			}
+			continue
+		}
+		for i, name := range evt.Type.Args() {
+			switch name {
+			case event.ArgStackID, event.ArgNewStackID,
+				event.ArgLabelStringID, event.ArgNameStringID, event.ArgKeyStringID:
+				if i < len(evt.Args) {
+					refs[evt.Args[i]] = true
+				}
+			}
+		}
+		d.evts++
+	}
+	if err := dec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	for id := range decl {
+		if !refs[id] {
+			t.Fatalf(`exp declaration %v to be referenced`, id)
+		}
+	}
+	return d
+}
+
+func TestRecorder(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		if tf.Version == event.Version1 {
+			continue
+		}
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			orig := read(t, tf.Bytes())
+
+			rec := New(0, 0)
+			if err := rec.Consume(bytes.NewReader(tf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := rec.Dump(&buf, 0); err != nil {
+				t.Fatal(err)
+			}
+			all := read(t, buf.Bytes())
+			if all.batches != orig.batches || all.evts != orig.evts {
+				t.Fatalf(`exp %v batches of %v events; got %v of %v`,
+					orig.batches, orig.evts, all.batches, all.evts)
+			}
+			if all.tr.Frequency != orig.tr.Frequency {
+				t.Fatalf(`exp frequency %v; got %v`, orig.tr.Frequency, all.tr.Frequency)
+			}
+
+			// The ring retains only the most recent batches.
+			rec = New(0, 2)
+			if err := rec.Consume(bytes.NewReader(tf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			buf.Reset()
+			if err := rec.Dump(&buf, 0); err != nil {
+				t.Fatal(err)
+			}
+			if got := read(t, buf.Bytes()); got.batches != 2 || got.evts >= orig.evts {
+				t.Fatalf(`exp 2 batches of fewer than %v events; got %v of %v`,
+					orig.evts, got.batches, got.evts)
+			}
+
+			// Dumping the last moment of the trace retains only the most
+			// recent batch along with those ending at the same time.
+			rec = New(time.Hour, 0)
+			if err := rec.Consume(bytes.NewReader(tf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			buf.Reset()
+			if err := rec.Dump(&buf, time.Nanosecond); err != nil {
+				t.Fatal(err)
+			}
+			if got := read(t, buf.Bytes()); got.batches == 0 || got.batches >= orig.batches {
+				t.Fatalf(`exp fewer than %v batches; got %v`, orig.batches, got.batches)
+			}
+		})
+	}
+}
+
+func TestRecorderEstimate(t *testing.T) {
+	tf := traceList.ByName(`log.trace`)
+	data := tf[len(tf)-1].Bytes()
+
+	// Re-encode the trace without its footer, as a stream still being traced.
+	dec := encoding.NewDecoder(bytes.NewReader(data))
+	ver, err := dec.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := encoding.NewEncoder(&buf, encoding.TargetVersion(ver))
+	for dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			t.Fatal(err)
+		}
+		if evt.Type == event.EvFrequency {
+			continue
+		}
+		if err := enc.Emit(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each batch is received a millisecond after the last.
+	rec, clock := New(0, 0), time.Unix(0, 0)
+	rec.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	if err := rec.Dump(&bytes.Buffer{}, 0); err == nil {
+		t.Fatal(`exp non-nil err dumping before recording`)
+	}
+	if err := rec.Consume(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if rec.freq != 0 || rec.frequency() == 0 {
+		t.Fatalf(`exp an estimated frequency; got %v`, rec.frequency())
+	}
+
+	var out bytes.Buffer
+	if err := rec.Dump(&out, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, out.Bytes()); got.tr.Frequency != rec.frequency() {
+		t.Fatalf(`exp frequency %v; got %v`, rec.frequency(), got.tr.Frequency)
+	}
+}
+
+func TestRecorderLive(t *testing.T) {
+	tf := traceList.ByName(`log.trace`)
+	data := tf[len(tf)-1].Bytes()
+
+	// The stream received while still being traced ends before its footer.
+	dec, footer := encoding.NewDecoder(bytes.NewReader(data)), 0
+	for footer == 0 && dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			t.Fatal(err)
+		}
+		if evt.Type == event.EvFrequency {
+			footer = evt.Off
+		}
+	}
+	if footer == 0 {
+		t.Fatal(`exp trace to have a footer`)
+	}
+
+	rec, clock := New(0, 0), time.Unix(0, 0)
+	rec.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	if err := rec.Consume(bytes.NewReader(data[:footer])); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := rec.Dump(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	got := read(t, buf.Bytes())
+	if got.evts == 0 || len(got.tr.Stacks) != 0 {
+		t.Fatalf(`exp events without stacks before the footer; got %v events, %v stacks`,
+			got.evts, len(got.tr.Stacks))
+	}
+
+	rec = New(0, 0)
+	if err := rec.Consume(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := rec.Dump(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, buf.Bytes()); len(got.tr.Stacks) == 0 {
+		t.Fatal(`exp stacks once the footer was received`)
+	}
+}
+
+func TestRecorderErrors(t *testing.T) {
+	v1 := traceList.ByName(`log.trace`)[0]
+	if v1.Version != event.Version1 {
+		t.Fatalf(`exp first fixture to be Version1; got %v`, v1.Version)
+	}
+	if err := New(0, 0).Consume(bytes.NewReader(v1.Bytes())); err == nil {
+		t.Fatal(`exp non-nil err consuming Version1`)
+	}
+
+	data := traceList.ByName(`log.trace`)[1].Bytes()
+	if err := New(0, 0).Consume(bytes.NewReader(data[:len(data)-3])); err == nil {
+		t.Fatal(`exp non-nil err consuming a broken stream`)
+	}
+}
@@ -0,0 +1,98 @@
+package index
+
+import (
+	"fmt"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// Builder builds an Index from the events of a trace as they are decoded or
+// encoded, so indexing needs no pass of its own.
+type Builder struct {
+	argoff int
+	idx    Index
+	cur    *Entry
+	ts     int64
+}
+
+// NewBuilder returns a Builder for a trace of the given version, or an error
+// if the version is unknown.
+func NewBuilder(v event.Version) (*Builder, error) {
+	if !v.Valid() {
+		return nil, fmt.Errorf(`Version %v is unknown`, v)
+	}
+	var argoff int
+	if v == event.Version1 {
+		argoff = 1
+	}
+	return &Builder{argoff: argoff, idx: Index{Version: v}}, nil
+}
+
+// Visit implements event.Visitor by adding evt at the offset of its Off field,
+// for building an index while decoding.
+func (b *Builder) Visit(evt *event.Event) error {
+	return b.Add(evt, int64(evt.Off))
+}
+
+// Add adds evt which begins at the given offset of the trace, such as the
+// offset returned by the EmitOffset method of an Encoder. Events must be
+// added in the order they appear in the trace, those with a negative offset
+// were not written and are ignored.
+func (b *Builder) Add(evt *event.Event, off int64) error {
+	if off < 0 {
+		return nil
+	}
+	switch evt.Type {
+	case event.EvBatch:
+		n := 1 + b.argoff
+		if n >= len(evt.Args) {
+			return fmt.Errorf(`expected %v arguments for event %v`, n+1, evt.Type)
+		}
+		if k := len(b.idx.Entries); k > 0 && off <= b.idx.Entries[k-1].Off {
+			return fmt.Errorf(`batch at offset %v does not follow offset %v`,
+				off, b.idx.Entries[k-1].Off)
+		}
+		b.ts = int64(evt.Args[n])
+		b.idx.Entries = append(b.idx.Entries, Entry{
+			Off:   off,
+			P:     int64(evt.Args[0]),
+			Start: b.ts,
+			End:   b.ts,
+		})
+		b.cur = &b.idx.Entries[len(b.idx.Entries)-1]
+		return nil
+	case event.EvFrequency:
+		// The runtime writes the frequency in the footer of a trace and only
+		// the stack table and string events follow it, an Encoder may write
+		// it before the first batch.
+		if len(evt.Args) > 0 {
+			b.idx.Frequency = evt.Args[0]
+		}
+		b.cur = nil
+		return nil
+	}
+	if b.cur == nil {
+		return nil
+	}
+
+	b.cur.Events++
+	if names := evt.Type.Args(); len(names) > 0 && names[0] == event.ArgTimestamp &&
+		b.argoff < len(evt.Args) {
+		b.ts += int64(evt.Args[b.argoff])
+		if b.ts > b.cur.End {
+			b.cur.End = b.ts
+		}
+	}
+	return nil
+}
+
+// Index returns the index of the events added so far.
+func (b *Builder) Index() *Index {
+	idx := &Index{
+		Version:   b.idx.Version,
+		Frequency: b.idx.Frequency,
+		Entries:   append([]Entry(nil), b.idx.Entries...),
+	}
+	idx.init()
+	return idx
+}
@@ -0,0 +1,141 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/internal/tracefile"
+)
+
+var traceList tracefile.TraceList
+
+func init() {
+	var err error
+	traceList, err = tracefile.Load(`../internal/tracefile`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// build returns the index of data built while decoding it.
+func build(t *testing.T, ver event.Version, data []byte) *Index {
+	b, err := NewBuilder(ver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := encoding.NewDecoder(bytes.NewReader(data)).VisitAll(b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Index()
+}
+
+func TestBuilder(t *testing.T) {
+	if _, err := NewBuilder(event.Version(0)); err == nil {
+		t.Fatal(`exp error for unknown version`)
+	}
+
+	for _, tf := range traceList.ByName(`log.trace`) {
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			idx := build(t, tf.Version, tf.Bytes())
+			if idx.Version != tf.Version || idx.Frequency == 0 {
+				t.Fatalf(`exp version %v and a frequency; got %+v`, tf.Version, idx)
+			}
+
+			// Every batch is indexed with the times of the events within it.
+			var (
+				batches, events int
+				cur             *Entry
+			)
+			dec := encoding.NewDecoder(bytes.NewReader(tf.Bytes()))
+			err := dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+				switch evt.Type {
+				case event.EvBatch:
+					if batches >= len(idx.Entries) {
+						t.Fatalf(`exp %v batches; got more`, len(idx.Entries))
+					}
+					cur = &idx.Entries[batches]
+					batches++
+					if cur.Off != int64(evt.Off) || cur.P != evt.P || cur.Start != evt.Ts {
+						t.Fatalf(`exp batch %+v to match %v`, *cur, evt)
+					}
+				case event.EvFrequency:
+					cur = nil
+				default:
+					if cur == nil {
+						return nil
+					}
+					events++
+					names := evt.Type.Args()
+					timed := len(names) > 0 && names[0] == event.ArgTimestamp
+					if timed && evt.Type != event.EvCPUSample &&
+						(evt.Ts < cur.Start || evt.Ts > cur.End) {
+						t.Fatalf(`exp %v at %v within batch %+v`, evt.Type, evt.Ts, *cur)
+					}
+				}
+				return nil
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var total int
+			for _, e := range idx.Entries {
+				total += e.Events
+			}
+			if batches != len(idx.Entries) || total != events {
+				t.Fatalf(`exp %v batches of %v events; got %v of %v`,
+					batches, events, len(idx.Entries), total)
+			}
+
+			// An index built while encoding matches one built while decoding
+			// the encoded trace.
+			if tf.Version == event.Version1 {
+				return
+			}
+			var buf bytes.Buffer
+			b, err := NewBuilder(tf.Version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			enc := encoding.NewEncoder(&buf, encoding.TargetVersion(tf.Version))
+			dec = encoding.NewDecoder(bytes.NewReader(tf.Bytes()))
+			err = dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+				off, err := enc.EmitOffset(evt)
+				if err != nil {
+					return err
+				}
+				return b.Add(evt, off)
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if exp, got := build(t, tf.Version, buf.Bytes()), b.Index(); !reflect.DeepEqual(exp, got) {
+				t.Fatalf(`exp encoded index %+v; got %+v`, exp, got)
+			}
+		})
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	b, err := NewBuilder(event.Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(event.MustNew(event.EvGoEnd, 1), -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(&event.Event{Type: event.EvBatch, Args: []uint64{1}}, 16); err == nil {
+		t.Fatal(`exp error for batch missing its timestamp`)
+	}
+	if err := b.Add(event.MustNew(event.EvBatch, 1, 100), 32); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(event.MustNew(event.EvBatch, 2, 100), 32); err == nil {
+		t.Fatal(`exp error for batch out of order`)
+	}
+}
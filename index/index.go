@@ -0,0 +1,223 @@
+// Package index implements a compact sidecar index of a trace, mapping the
+// timestamps and batch numbers of a trace to the byte offsets of its batches
+// so a Decoder may begin at a time of interest without decoding the events
+// which precede it.
+package index
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// Entry locates a single batch of events within a trace, the number of a batch
+// is the position of its Entry within an Index.
+type Entry struct {
+
+	// Off is the offset of the EvBatch event beginning the batch and P the id
+	// of the P it was emitted from.
+	Off int64
+	P   int64
+
+	// Start is the base timestamp of the batch and End the timestamp of its
+	// last event, both in CPU ticks.
+	Start, End int64
+
+	// Events is the number of events in the batch, excluding the EvBatch.
+	Events int
+}
+
+// Index maps the timestamps and batch numbers of a trace to the offsets of its
+// batches, see Builder.
+type Index struct {
+	Version event.Version
+
+	// Frequency is the number of ticks per second declared by the trace, for
+	// converting times in nanoseconds to ticks. It is zero if the index was
+	// built before the footer of the trace was reached.
+	Frequency uint64
+
+	// Entries are the batches of the trace in the order they appear.
+	Entries []Entry
+
+	// maxEnd holds the largest End of the entries up to each position, which
+	// is non-decreasing so may be searched.
+	maxEnd []int64
+}
+
+func (x *Index) init() {
+	x.maxEnd = make([]int64, len(x.Entries))
+	var max int64
+	for i, e := range x.Entries {
+		if i == 0 || e.End > max {
+			max = e.End
+		}
+		x.maxEnd[i] = max
+	}
+}
+
+// Find returns the number of the first batch which may hold events at or after
+// the timestamp ts given in ticks and a boolean true, or false if every batch
+// ended before ts. Batches are written when the buffer of a P fills, so batches
+// following the one returned may still hold events before ts.
+func (x *Index) Find(ts int64) (int, bool) {
+	if len(x.maxEnd) != len(x.Entries) {
+		x.init()
+	}
+	n := sort.Search(len(x.maxEnd), func(i int) bool {
+		return x.maxEnd[i] >= ts
+	})
+	return n, n < len(x.Entries)
+}
+
+// Range returns the batches holding events within [start, end) in ticks, in
+// the order they appear, with an end of zero leaving the range unbounded.
+func (x *Index) Range(start, end int64) []Entry {
+	n, ok := x.Find(start)
+	if !ok {
+		return nil
+	}
+	var out []Entry
+	for _, e := range x.Entries[n:] {
+		if e.End >= start && (end == 0 || e.Start < end) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Ticks returns the tick count of the time ns in nanoseconds, as given by the
+// Ts field of events decoded with the Nanoseconds option. It returns ns when
+// the Frequency is unknown.
+func (x *Index) Ticks(ns int64) int64 {
+	if x.Frequency == 0 {
+		return ns
+	}
+	return int64(float64(ns) * float64(x.Frequency) / 1e9)
+}
+
+// Open returns a Decoder reading the trace within ra from the first batch which
+// may hold events at or after ts in ticks, as found by Find. The options are
+// given to the Decoder, see encoding.NewDecoderAt. Events before ts may still
+// be decoded and should be skipped by the caller.
+func (x *Index) Open(ra io.ReaderAt, ts int64, opts ...encoding.Option) (*encoding.Decoder, error) {
+	n, ok := x.Find(ts)
+	if !ok {
+		return nil, fmt.Errorf(`no batch holds events at or after %v`, ts)
+	}
+	return encoding.NewDecoderAt(ra, x.Entries[n].Off, opts...)
+}
+
+// magic begins every index file, followed by the version of the index format.
+var magic = [...]byte{'g', 'o', 't', 'r', 'a', 'c', 'e', 'i', 'd', 'x'}
+
+const formatVersion = 1
+
+// WriteTo implements io.WriterTo by writing this index to w in a compact form
+// which may be read with Read. Offsets and timestamps are written as deltas
+// from the prior entry.
+func (x *Index) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var (
+		n   int64
+		buf [binary.MaxVarintLen64]byte
+	)
+	write := func(b []byte) {
+		m, _ := bw.Write(b)
+		n += int64(m)
+	}
+	uvarint := func(v uint64) { write(buf[:binary.PutUvarint(buf[:], v)]) }
+	varint := func(v int64) { write(buf[:binary.PutVarint(buf[:], v)]) }
+
+	write(magic[:])
+	write([]byte{formatVersion, byte(x.Version)})
+	uvarint(x.Frequency)
+	uvarint(uint64(len(x.Entries)))
+	var prev Entry
+	for _, e := range x.Entries {
+		uvarint(uint64(e.Off - prev.Off))
+		varint(e.P)
+		varint(e.Start - prev.Start)
+		uvarint(uint64(e.End - e.Start))
+		uvarint(uint64(e.Events))
+		prev = e
+	}
+	return n, bw.Flush()
+}
+
+// Read reads an index written by WriteTo from r.
+func Read(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	var head [len(magic) + 2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		return nil, readErr(err)
+	}
+	if string(head[:len(magic)]) != string(magic[:]) {
+		return nil, errors.New(`index header was malformed`)
+	}
+	if v := head[len(magic)]; v != formatVersion {
+		return nil, fmt.Errorf(`index format %v is not supported`, v)
+	}
+	x := &Index{Version: event.Version(head[len(magic)+1])}
+	if !x.Version.Valid() {
+		return nil, fmt.Errorf(`Version %v is unknown`, x.Version)
+	}
+
+	var err error
+	uvarint := func() uint64 {
+		var v uint64
+		if err == nil {
+			v, err = binary.ReadUvarint(br)
+		}
+		return v
+	}
+	varint := func() int64 {
+		var v int64
+		if err == nil {
+			v, err = binary.ReadVarint(br)
+		}
+		return v
+	}
+
+	x.Frequency = uvarint()
+	count := uvarint()
+	if err != nil {
+		return nil, readErr(err)
+	}
+
+	// Every entry occupies at least 5 bytes, so the count is not trusted to
+	// size the allocation beyond what a small index could hold.
+	const maxPrealloc = 1 << 16
+	if count < maxPrealloc {
+		x.Entries = make([]Entry, 0, count)
+	}
+	var prev Entry
+	for i := uint64(0); i < count; i++ {
+		var e Entry
+		e.Off = prev.Off + int64(uvarint())
+		e.P = varint()
+		e.Start = prev.Start + varint()
+		e.End = e.Start + int64(uvarint())
+		e.Events = int(uvarint())
+		if err != nil {
+			return nil, readErr(err)
+		}
+		x.Entries = append(x.Entries, e)
+		prev = e
+	}
+	x.init()
+	return x, nil
+}
+
+func readErr(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
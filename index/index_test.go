@@ -0,0 +1,199 @@
+package index
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+func TestIndexFind(t *testing.T) {
+	idx := &Index{Version: event.Latest, Entries: []Entry{
+		{Off: 16, Start: 10, End: 50},
+		{Off: 40, Start: 5, End: 20},
+		{Off: 80, Start: 60, End: 90},
+		{Off: 99, Start: 55, End: 70},
+	}}
+	for _, test := range []struct {
+		ts  int64
+		exp int
+		ok  bool
+	}{
+		{0, 0, true}, {50, 0, true}, {51, 2, true}, {90, 2, true}, {91, 4, false},
+	} {
+		if n, ok := idx.Find(test.ts); n != test.exp || ok != test.ok {
+			t.Fatalf(`exp Find(%v) to be %v, %v; got %v, %v`,
+				test.ts, test.exp, test.ok, n, ok)
+		}
+	}
+
+	offs := func(es []Entry) (out []int64) {
+		for _, e := range es {
+			out = append(out, e.Off)
+		}
+		return
+	}
+	for _, test := range []struct {
+		start, end int64
+		exp        []int64
+	}{
+		{0, 0, []int64{16, 40, 80, 99}},
+		{21, 56, []int64{16, 99}},
+		{51, 60, []int64{99}},
+		{91, 0, nil},
+	} {
+		if got := offs(idx.Range(test.start, test.end)); !reflect.DeepEqual(got, test.exp) {
+			t.Fatalf(`exp Range(%v, %v) to be %v; got %v`, test.start, test.end, test.exp, got)
+		}
+	}
+
+	if got := idx.Ticks(2e9); got != 2e9 {
+		t.Fatalf(`exp ticks unchanged without a frequency; got %v`, got)
+	}
+	idx.Frequency = 500
+	if got := idx.Ticks(2e9); got != 1000 {
+		t.Fatalf(`exp 1000 ticks; got %v`, got)
+	}
+}
+
+func TestIndexReadWrite(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		idx := build(t, tf.Version, tf.Bytes())
+		var buf bytes.Buffer
+		n, err := idx.WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(buf.Len()) || n > int64(len(idx.Entries)*16+32) {
+			t.Fatalf(`exp compact index of %v bytes; got %v`, buf.Len(), n)
+		}
+		got, err := Read(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(idx, got) {
+			t.Fatalf(`exp %+v; got %+v`, idx, got)
+		}
+
+		b := buf.Bytes()
+		for _, test := range []struct {
+			data []byte
+			exp  error
+		}{
+			{b[:5], io.ErrUnexpectedEOF},
+			{b[:len(b)-1], io.ErrUnexpectedEOF},
+		} {
+			if _, err := Read(bytes.NewReader(test.data)); err != test.exp {
+				t.Fatalf(`exp %v; got %v`, test.exp, err)
+			}
+		}
+		bad := append([]byte(nil), b...)
+		bad[0] = 'x'
+		if _, err := Read(bytes.NewReader(bad)); err == nil {
+			t.Fatal(`exp error for malformed header`)
+		}
+		bad[0], bad[len(magic)] = b[0], formatVersion+1
+		if _, err := Read(bytes.NewReader(bad)); err == nil {
+			t.Fatal(`exp error for unsupported format`)
+		}
+		bad[len(magic)], bad[len(magic)+1] = formatVersion, 0
+		if _, err := Read(bytes.NewReader(bad)); err == nil {
+			t.Fatal(`exp error for unknown version`)
+		}
+	}
+}
+
+// synthetic returns a trace of four batches alternating between two Ps, each
+// beginning 100 ticks after the last and holding events 1 and 6 ticks later.
+func synthetic(t *testing.T) []byte {
+	var buf bytes.Buffer
+	enc := encoding.NewEncoder(&buf, encoding.TargetVersion(event.Latest))
+	for i := uint64(0); i < 4; i++ {
+		for _, evt := range []*event.Event{
+			event.MustNew(event.EvBatch, i%2, 100*(i+1)),
+			event.MustNew(event.EvProcStart, 1, i+1),
+			event.MustNew(event.EvProcStop, 5),
+		} {
+			if err := enc.Emit(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := enc.Emit(event.MustNew(event.EvFrequency, 1e9)); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestIndexOpen(t *testing.T) {
+	type test struct {
+		name string
+		ver  event.Version
+		data []byte
+		ts   int64
+		skip int
+	}
+	tests := []test{{`synthetic`, event.Latest, synthetic(t), 250, 2}}
+	for _, tf := range traceList.ByName(`log.trace`) {
+		tests = append(tests, test{tf.Version.Go(), tf.Version, tf.Bytes(), 0, 0})
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := test.data
+			idx := build(t, test.ver, data)
+			last := idx.maxEnd[len(idx.maxEnd)-1]
+			ts := test.ts
+			if ts == 0 {
+				ts = (idx.Entries[0].Start + last) / 2
+			}
+
+			// Every event at or after ts is decoded after seeking.
+			exp := make(map[int]bool)
+			dec := encoding.NewDecoder(bytes.NewReader(data))
+			err := dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+				if evt.Ts >= ts && evt.Type != event.EvBatch && evt.Type != event.EvCPUSample {
+					exp[evt.Off] = true
+				}
+				return nil
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(exp) == 0 {
+				t.Fatal(`exp events after the seek time`)
+			}
+
+			dec, err = idx.Open(bytes.NewReader(data), ts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, _ := idx.Find(ts)
+			err = dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+				if evt.Off < int(idx.Entries[n].Off) {
+					t.Fatalf(`exp events from batch %v; got %v`, n, evt)
+				}
+				delete(exp, evt.Off)
+				return nil
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(exp) != 0 {
+				t.Fatalf(`exp all events after %v to be decoded; %v missing`, ts, len(exp))
+			}
+			if n != test.skip {
+				t.Fatalf(`exp seeking to skip %v batches; skipped %v`, test.skip, n)
+			}
+
+			if _, err := idx.Open(bytes.NewReader(data), last+1); err == nil {
+				t.Fatal(`exp error seeking beyond the trace`)
+			}
+		})
+	}
+}
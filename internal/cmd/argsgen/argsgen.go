@@ -0,0 +1,165 @@
+// Command argsgen generates the argument index constants, typed argument
+// accessors and the exhaustive Handler interface of the event package from
+// the schema of each event type.
+//
+// Usage:
+//
+//	go run ../internal/cmd/argsgen -o args.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+var flagOut = flag.String(`o`, ``, `output file, defaults to stdout`)
+
+// renames holds the accessor names of event types whose name collides with an
+// existing method of event.Event.
+var renames = map[string]string{
+	`String`: `StringEntry`,
+}
+
+func main() {
+	flag.Parse()
+	src, err := generate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *flagOut == `` {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := ioutil.WriteFile(*flagOut, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ident returns the name of typ used in generated identifiers, the names of a
+// few types retain the Ev prefix of their constant.
+func ident(typ event.Type) string {
+	return strings.TrimPrefix(typ.Name(), `Ev`)
+}
+
+func types() []event.Type {
+	var out []event.Type
+	for typ := event.EvNone + 1; typ < event.EvCount; typ++ {
+		out = append(out, typ)
+	}
+	return out
+}
+
+func generate() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by argsgen; DO NOT EDIT.\n\n")
+	buf.WriteString("package event\n\nimport \"fmt\"\n")
+	genIndexes(&buf)
+	for _, typ := range types() {
+		genAccessor(&buf, typ)
+	}
+	genHandler(&buf)
+	return format.Source(buf.Bytes())
+}
+
+// genIndexes writes the index of each argument within the Args of each type of
+// event.
+func genIndexes(buf *bytes.Buffer) {
+	buf.WriteString("\n// Indexes of the arguments of each type of event within the Args field of\n")
+	buf.WriteString("// an Event, in the layout of Version2 and later. See Schemas for the layout\n")
+	buf.WriteString("// of Version1 and the arguments absent from other versions.\n")
+	buf.WriteString("const (\n")
+	for _, typ := range types() {
+		for i, arg := range typ.Args() {
+			fmt.Fprintf(buf, "\tIdx%v%v = %v\n", ident(typ), arg, i)
+		}
+	}
+	buf.WriteString(")\n")
+}
+
+// genAccessor writes the Args struct and accessor method of typ.
+func genAccessor(buf *bytes.Buffer, typ event.Type) {
+	name, args := ident(typ), typ.Args()
+	method := name
+	if s, ok := renames[name]; ok {
+		method = s
+	}
+
+	fmt.Fprintf(buf, "\n// %vArgs holds the arguments of an Ev%v event.\n", name, name)
+	fmt.Fprintf(buf, "type %vArgs struct {\n", name)
+	for _, arg := range args {
+		fmt.Fprintf(buf, "\t%v uint64\n", arg)
+	}
+	buf.WriteString("}\n")
+
+	fmt.Fprintf(buf, "\n// %v returns the arguments of an Ev%v event in the layout of version v.\n", method, name)
+	buf.WriteString("// The ok result is false if e is another type of event or has too few\n")
+	buf.WriteString("// arguments, those absent from the layout of v are zero.\n")
+	fmt.Fprintf(buf, "func (e *Event) %v(v Version) (args %vArgs, ok bool) {\n", method, name)
+	if len(args) == 0 {
+		fmt.Fprintf(buf, "\t_, ok = e.layout(v, Ev%v)\n", name)
+		buf.WriteString("\treturn args, ok\n}\n")
+		return
+	}
+	fmt.Fprintf(buf, "\tidx, ok := e.layout(v, Ev%v)\n", name)
+	buf.WriteString("\tif !ok {\n\t\treturn args, false\n\t}\n")
+	for _, arg := range args {
+		fmt.Fprintf(buf, "\targs.%v = e.at(idx, Idx%v%v)\n", arg, name, arg)
+	}
+	buf.WriteString("\treturn args, true\n}\n")
+}
+
+// genHandler writes the Handler interface, NopHandler and Dispatch.
+func genHandler(buf *bytes.Buffer) {
+	buf.WriteString(`
+// Handler has a method for every type of event, called by Dispatch with the
+// arguments of the event. Types implementing Handler fail to compile when a
+// new type of event is added, ensuring each is handled.
+type Handler interface {
+`)
+	for _, typ := range types() {
+		fmt.Fprintf(buf, "\tHandle%v(evt *Event, args %vArgs) error\n",
+			ident(typ), ident(typ))
+	}
+	buf.WriteString("}\n")
+
+	buf.WriteString(`
+// NopHandler implements Handler with methods that do nothing, it may be
+// embedded to handle a subset of event types at the cost of being exhaustive.
+type NopHandler struct{}
+`)
+	for _, typ := range types() {
+		fmt.Fprintf(buf, "\n// Handle%v implements Handler.\n", ident(typ))
+		fmt.Fprintf(buf, "func (NopHandler) Handle%v(*Event, %vArgs) error { return nil }\n",
+			ident(typ), ident(typ))
+	}
+
+	buf.WriteString(`
+// Dispatch calls the method of h for the type of evt with its arguments, read
+// in the layout of version v. An error is returned if evt has an invalid type
+// or too few arguments.
+func Dispatch(h Handler, v Version, evt *Event) error {
+	switch evt.Type {
+`)
+	for _, typ := range types() {
+		method := ident(typ)
+		if s, ok := renames[method]; ok {
+			method = s
+		}
+		fmt.Fprintf(buf, "\tcase Ev%v:\n", ident(typ))
+		fmt.Fprintf(buf, "\t\tif args, ok := evt.%v(v); ok {\n", method)
+		fmt.Fprintf(buf, "\t\t\treturn h.Handle%v(evt, args)\n\t\t}\n", ident(typ))
+	}
+	buf.WriteString("\tdefault:\n")
+	buf.WriteString("\t\treturn fmt.Errorf(`event type %v was not valid`, evt.Type)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn fmt.Errorf(`event type %v has too few arguments`, evt.Type)\n")
+	buf.WriteString("}\n")
+}
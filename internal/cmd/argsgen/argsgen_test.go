@@ -0,0 +1,21 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	exp, err := ioutil.ReadFile(`../../../event/args.go`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(exp, got) {
+		t.Fatal(`event/args.go is out of date with the schemas, run go generate`)
+	}
+}
@@ -0,0 +1,418 @@
+// Package merge implements the merging of multiple traces into one, such as
+// the traces captured from each process of a distributed program, so they
+// may be viewed and analyzed on a single timeline.
+package merge
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// OriginKey is the key of the user log events which tag each goroutine of a
+// merged trace with the Name of the Source it came from.
+const OriginKey = `origin`
+
+// Frequency is the tick frequency of merged traces, their timestamps are in
+// nanoseconds.
+const Frequency = 1e9
+
+// Source is a trace to be merged.
+type Source struct {
+
+	// Name identifies the trace, each goroutine of the merged trace is tagged
+	// with the name of its Source by a user log event keyed by OriginKey
+	// when it first starts. Goroutines are not tagged when Name is empty.
+	Name string
+
+	// Offset is added to the timestamps of the trace once its first event is
+	// aligned with the start of the merged trace, for correcting the skew
+	// between traces which were not started at once.
+	Offset time.Duration
+
+	// R reads the trace, which must be Version2 or later.
+	R io.Reader
+}
+
+// Merge reads the trace of each Source and writes a single trace interleaving
+// their events by time to w, in the latest version of the trace format.
+//
+// The timestamps of each trace are converted to nanoseconds, rebased so each
+// trace begins at the start of the merged trace and then shifted by its
+// Offset. The Ps of each trace are renumbered in the order they are first
+// seen, and the goroutine, string, stack, task and thread ids of each trace
+// are shifted past those of the traces before it so they do not collide.
+// Parsers allow a single collection and stop the world pause at once, so those
+// which overlap are joined into one and collections are renumbered.
+//
+// Each trace is decoded and ordered in full before the merged trace is
+// written, so memory use is proportional to the total number of events.
+func Merge(w io.Writer, srcs ...Source) error {
+	if len(srcs) == 0 {
+		return errors.New(`no sources were given to merge`)
+	}
+
+	var (
+		ts   []*trace
+		next ids
+		ps   int64
+		min  time.Duration
+	)
+	for i, src := range srcs {
+		tr, err := load(src)
+		if err != nil {
+			return fmt.Errorf(`source %v: %v`, sourceName(src, i), err)
+		}
+		tr.off, tr.ps = next, make(map[int64]int64)
+		next = next.add(tr.max)
+		if src.Offset < min {
+			min = src.Offset
+		}
+		ts = append(ts, tr)
+	}
+
+	// Renumber the Ps of each trace in the order they are first seen, shifted
+	// past those of the traces before it.
+	for _, tr := range ts {
+		for _, evt := range tr.evts {
+			if _, ok := tr.ps[evt.P]; !ok && evt.P >= 0 {
+				tr.ps[evt.P] = ps
+				ps++
+			}
+		}
+	}
+
+	// The key of origin logs is declared after the strings of every trace.
+	key := next.strs + 1
+	enc := encoding.NewEncoder(w,
+		encoding.TargetVersion(event.Latest), encoding.SortWindow(1))
+	if err := enc.Emit(newString(key, OriginKey)); err != nil {
+		return err
+	}
+	for _, tr := range ts {
+		for _, evt := range tr.decl {
+			tr.remap(evt)
+			if err := enc.Emit(evt); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Interleave the events of each trace by their rebased timestamps, which
+	// preserves the order of the events within each trace.
+	shift := int64(-min)
+	for _, tr := range ts {
+		tr.shift = shift + int64(tr.src.Offset)
+	}
+	tagged := make(map[uint64]bool)
+	var sp spans
+	for {
+		var sel *trace
+		for _, tr := range ts {
+			if tr.head < len(tr.evts) &&
+				(sel == nil || tr.time(tr.evts[tr.head].Ts) < sel.time(sel.evts[sel.head].Ts)) {
+				sel = tr
+			}
+		}
+		if sel == nil {
+			break
+		}
+		evt := sel.evts[sel.head]
+		sel.evts[sel.head] = nil
+		sel.head++
+
+		sel.remap(evt)
+		if !sp.keep(evt) {
+			continue
+		}
+		if err := enc.Emit(evt); err != nil {
+			return err
+		}
+
+		// Tag each goroutine with its origin once it is running.
+		switch evt.Type {
+		case event.EvGoStart, event.EvGoStartLocal, event.EvGoStartLabel:
+			g := evt.Get(event.ArgGoroutineID)
+			if sel.src.Name == `` || tagged[g] {
+				break
+			}
+			tagged[g] = true
+			log := event.MustNew(event.EvUserLog, 0, 0, key, 0)
+			log.Data = []byte(sel.src.Name)
+			log.Ts, log.P, log.G = evt.Ts, evt.P, int64(g)
+			if err := enc.Emit(log); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := enc.Emit(event.MustNew(event.EvFrequency, Frequency)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// spans tracks the collections and stop the world pauses in progress within a
+// merged trace, where only one of each may be in progress at once. Those of
+// each trace which overlap another, along with the pauses on each P of traces
+// before Version5, are joined into one by writing only the outermost events
+// which begin and end them.
+type spans struct {
+	gc, stw int
+
+	// seq is the sequence of the next collection, those of each trace are
+	// renumbered as the parser orders collections by their sequence.
+	seq uint64
+}
+
+// keep reports if evt should be written to the merged trace.
+func (s *spans) keep(evt *event.Event) bool {
+	switch evt.Type {
+	case event.EvGCStart:
+		if s.gc++; s.gc > 1 {
+			return false
+		}
+		if i, ok := evt.Type.Arg(event.ArgSequenceGC); ok && i < len(evt.Args) {
+			evt.Args[i] = s.seq
+		}
+		s.seq++
+	case event.EvGCDone:
+		return end(&s.gc)
+	case event.EvGCSTWStart:
+		s.stw++
+		return s.stw == 1
+	case event.EvGCSTWDone:
+		return end(&s.stw)
+	}
+	return true
+}
+
+// end reports if the end of a span is outermost, decrementing the number in
+// progress n.
+func end(n *int) bool {
+	if *n == 0 {
+		return false
+	}
+	*n--
+	return *n == 0
+}
+
+func sourceName(src Source, i int) string {
+	if src.Name != `` {
+		return fmt.Sprintf(`%q`, src.Name)
+	}
+	return fmt.Sprint(i)
+}
+
+func newString(id uint64, s string) *event.Event {
+	evt := event.MustNew(event.EvString, id)
+	evt.Data = []byte(s)
+	return evt
+}
+
+// ids holds the largest id of each kind within a trace, or the offset the ids
+// of a trace are shifted by. An id of zero means none, other than for threads
+// whose ids begin at zero.
+type ids struct {
+	gs, strs, stks, tasks, threads uint64
+}
+
+func (a ids) add(b ids) ids {
+	return ids{a.gs + b.gs, a.strs + b.strs, a.stks + b.stks,
+		a.tasks + b.tasks, a.threads + b.threads}
+}
+
+// trace holds the decoded events of a Source.
+type trace struct {
+	src   Source
+	freq  uint64
+	start int64
+	shift int64
+
+	// decl holds the events without a timestamp, such as the string and stack
+	// declarations, and evts the ordered events of the trace with head the
+	// next event to merge.
+	decl []*event.Event
+	evts []*event.Event
+	head int
+
+	// max holds the largest ids of the trace and off the offsets they are
+	// shifted by, ps maps the Ps of the trace to those of the merged trace.
+	max, off ids
+	ps       map[int64]int64
+}
+
+// load decodes and orders the events of src.
+func load(src Source) (*trace, error) {
+	if src.R == nil {
+		return nil, errors.New(`source has no reader`)
+	}
+	dec := encoding.NewDecoder(src.R)
+	ver, err := dec.Version()
+	if err != nil {
+		return nil, err
+	}
+	if ver == event.Version1 {
+		return nil, fmt.Errorf(`Version %v may not be merged`, ver)
+	}
+	vt, err := event.NewTrace(ver)
+	if err != nil {
+		return nil, err
+	}
+
+	tr, o := &trace{src: src}, event.NewOrderer(ver)
+	for dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			return nil, err
+		}
+		if err := vt.Visit(evt); err != nil {
+			return nil, err
+		}
+		tr.visit(evt)
+		switch evt.Type {
+		case event.EvBatch, event.EvFrequency:
+		default:
+			if !o.Push(evt) {
+				tr.decl = append(tr.decl, evt)
+			}
+		}
+	}
+	if err := dec.Err(); err != nil {
+		return nil, err
+	}
+	if tr.freq = vt.Frequency; tr.freq == 0 {
+		return nil, errors.New(`trace contains no frequency event`)
+	}
+	tr.start, _ = vt.TimeBounds()
+
+	for {
+		evt, err := o.Next()
+		if err == io.EOF {
+			return tr, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		tr.evts = append(tr.evts, evt)
+	}
+}
+
+// visit records the largest ids referred to by evt.
+func (tr *trace) visit(evt *event.Event) {
+	max := func(p *uint64, v uint64) {
+		if v > *p {
+			*p = v
+		}
+	}
+	switch evt.Type {
+	case event.EvString:
+		max(&tr.max.strs, evt.Args[0])
+		return
+	case event.EvStack:
+		max(&tr.max.stks, evt.Args[0])
+		return
+	}
+	for i, name := range evt.Type.Args() {
+		if i >= len(evt.Args) {
+			break
+		}
+		switch v := evt.Args[i]; name {
+		case event.ArgGoroutineID, event.ArgNewGoroutineID:
+			max(&tr.max.gs, v)
+		case event.ArgStackID, event.ArgNewStackID:
+			max(&tr.max.stks, v)
+		case event.ArgLabelStringID, event.ArgNameStringID, event.ArgKeyStringID:
+			max(&tr.max.strs, v)
+		case event.ArgTaskID, event.ArgParentTaskID:
+			max(&tr.max.tasks, v)
+		case event.ArgThreadID:
+			// Thread ids begin at zero, so the count of ids is recorded.
+			max(&tr.max.threads, v+1)
+		}
+	}
+	if evt.G > 0 {
+		max(&tr.max.gs, uint64(evt.G))
+	}
+}
+
+// time returns the timestamp ts in the ticks of the trace rebased in the
+// nanoseconds of the merged trace.
+func (tr *trace) time(ts int64) int64 {
+	return int64(float64(ts-tr.start)*1e9/float64(tr.freq)) + tr.shift
+}
+
+// grown holds the number of arguments of the latest layout of the types which
+// gained arguments after Version1, the kind of a stop the world added in
+// Version5 and the bytes swept and reclaimed added in Version4, which are not
+// named by the Args of EvGCSweepDone.
+var grown = map[event.Type]int{
+	event.EvGCSTWStart:  2,
+	event.EvGCSweepDone: 3,
+}
+
+// remap rewrites the timestamps, P and ids of evt into those of the merged
+// trace. Timestamp arguments are rewritten by the Encoder from the Ts field.
+func (tr *trace) remap(evt *event.Event) {
+	shift := func(v, off uint64) uint64 {
+		if v == 0 {
+			return 0
+		}
+		return v + off
+	}
+	switch evt.Type {
+	case event.EvString:
+		evt.Args[0] = shift(evt.Args[0], tr.off.strs)
+		return
+	case event.EvStack:
+		evt.Args[0] = shift(evt.Args[0], tr.off.stks)
+		for pos := 2; pos+3 < len(evt.Args); pos += 4 {
+			evt.Args[pos+1] = shift(evt.Args[pos+1], tr.off.strs)
+			evt.Args[pos+2] = shift(evt.Args[pos+2], tr.off.strs)
+		}
+		return
+	}
+
+	// Arguments added by later versions are zero in the latest layout.
+	if n := grown[evt.Type]; len(evt.Args) < n {
+		evt.Args = append(evt.Args, make([]uint64, n-len(evt.Args))...)
+	}
+
+	evt.Ts = tr.time(evt.Ts)
+	if p, ok := tr.ps[evt.P]; ok {
+		evt.P = p
+	}
+	if evt.G > 0 {
+		evt.G += int64(tr.off.gs)
+	}
+	for i, name := range evt.Type.Args() {
+		if i >= len(evt.Args) {
+			break
+		}
+		a := &evt.Args[i]
+		switch name {
+		case event.ArgGoroutineID, event.ArgNewGoroutineID:
+			*a = shift(*a, tr.off.gs)
+		case event.ArgStackID, event.ArgNewStackID:
+			*a = shift(*a, tr.off.stks)
+		case event.ArgLabelStringID, event.ArgNameStringID, event.ArgKeyStringID:
+			*a = shift(*a, tr.off.strs)
+		case event.ArgTaskID, event.ArgParentTaskID:
+			*a = shift(*a, tr.off.tasks)
+		case event.ArgThreadID:
+			*a += tr.off.threads
+		case event.ArgRealTimestamp:
+			if *a != 0 {
+				*a = uint64(tr.time(int64(*a)))
+			}
+		case event.ArgProcessorID:
+			if p, ok := tr.ps[int64(*a)]; ok {
+				*a = uint64(p)
+			}
+		}
+	}
+}
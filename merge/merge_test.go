@@ -0,0 +1,228 @@
+package merge
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/internal/tracefile"
+)
+
+var traceList tracefile.TraceList
+
+func init() {
+	var err error
+	traceList, err = tracefile.Load(`../internal/tracefile`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// starts returns the goroutines started within the trace b in the order they
+// first start, along with the origin logs of the ordered trace.
+func starts(t *testing.T, b []byte) ([]uint64, []event.TaskLog) {
+	dec := encoding.NewDecoder(bytes.NewReader(b))
+	ver, err := dec.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := event.NewTrace(ver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := event.NewValidator(ver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := event.NewOrderer(ver)
+	for dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			t.Fatal(err)
+		}
+		if err := tr.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+		o.Push(evt)
+	}
+
+	var (
+		gs   []uint64
+		seen = make(map[uint64]bool)
+		evts []*event.Event
+	)
+	for {
+		evt, err := o.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+		switch evt.Type {
+		case event.EvGoStart, event.EvGoStartLocal, event.EvGoStartLabel:
+			if g := uint64(evt.G); !seen[g] {
+				seen[g] = true
+				gs = append(gs, g)
+			}
+		}
+		evts = append(evts, evt)
+	}
+
+	var logs []event.TaskLog
+	if ver >= event.Version5 {
+		tt, err := tr.Tasks(evts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, log := range tt.Logs {
+			if log.Key == OriginKey {
+				logs = append(logs, log)
+			}
+		}
+	}
+	return gs, logs
+}
+
+func TestMerge(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		if tf.Version == event.Version1 {
+			continue
+		}
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Merge(&buf,
+				Source{Name: `a`, R: bytes.NewReader(tf.Bytes())},
+				Source{Name: `b`, R: bytes.NewReader(tf.Bytes()), Offset: time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			exp, _ := starts(t, tf.Bytes())
+			gs, logs := starts(t, buf.Bytes())
+			if len(gs) != 2*len(exp) {
+				t.Fatalf(`exp %v goroutines to start; got %v`, 2*len(exp), len(gs))
+			}
+			if len(logs) != len(gs) {
+				t.Fatalf(`exp %v origin logs; got %v`, len(gs), len(logs))
+			}
+
+			// Goroutines of the second trace are shifted past the first.
+			var max uint64
+			for _, g := range exp {
+				if g > max {
+					max = g
+				}
+			}
+			origin := make(map[uint64]string)
+			for _, log := range logs {
+				origin[log.G] = log.Value
+			}
+			for _, g := range gs {
+				if exp := map[bool]string{true: `a`, false: `b`}[g <= max]; origin[g] != exp {
+					t.Fatalf(`exp g %v to have origin %v; got %q`, g, exp, origin[g])
+				}
+			}
+		})
+	}
+}
+
+// TestMergeParse parses merged traces with the parser of the Go toolchain when
+// it is available, which rejects events outside of a batch and overlapping
+// collections or pauses.
+func TestMergeParse(t *testing.T) {
+	if testing.Short() {
+		t.Skip(`skipping parse by the Go toolchain in short mode`)
+	}
+	path, err := exec.LookPath(`go`)
+	if err != nil {
+		t.Skip(`skipping parse by the Go toolchain: go was not found`)
+	}
+	for _, tf := range traceList.ByName(`sync_atomic.trace`) {
+		if tf.Version == event.Version1 {
+			continue
+		}
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Merge(&buf,
+				Source{Name: `a`, R: bytes.NewReader(tf.Bytes())},
+				Source{Name: `b`, R: bytes.NewReader(tf.Bytes()), Offset: time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := filepath.Join(t.TempDir(), `merged.trace`)
+			if err := ioutil.WriteFile(name, buf.Bytes(), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := exec.Command(path, `tool`, `trace`, `-d=parsed`, name).CombinedOutput()
+			if strings.Contains(string(res), `unsupported trace version`) {
+				t.Skipf(`skipping parse by the Go toolchain: %s`, bytes.TrimSpace(res))
+			}
+			if err != nil {
+				t.Fatalf(`go tool trace: %v: %s`, err, res)
+			}
+		})
+	}
+}
+
+func TestMergeOffset(t *testing.T) {
+	tf := traceList.ByName(`log.trace`)
+	data := tf[len(tf)-1].Bytes()
+
+	var buf bytes.Buffer
+	err := Merge(&buf,
+		Source{Name: `a`, R: bytes.NewReader(data), Offset: time.Second},
+		Source{Name: `b`, R: bytes.NewReader(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every event of the first trace follows every event of the second, as
+	// the trace is far shorter than a second.
+	_, logs := starts(t, buf.Bytes())
+	var last int64
+	for _, log := range logs {
+		if log.Value == `b` && log.Ts > last {
+			last = log.Ts
+		}
+	}
+	for _, log := range logs {
+		if log.Value == `a` && log.Ts < int64(time.Second) {
+			t.Fatalf(`exp logs of a after 1s; got %v`, log.Ts)
+		}
+	}
+	if last == 0 || last >= int64(time.Second) {
+		t.Fatalf(`exp logs of b before 1s; got %v`, last)
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	v1 := traceList.ByName(`log.trace`)[0]
+	if v1.Version != event.Version1 {
+		t.Fatalf(`exp first fixture to be Version1; got %v`, v1.Version)
+	}
+	tests := [][]Source{
+		nil,
+		{{Name: `nil`}},
+		{{R: bytes.NewReader(v1.Bytes())}},
+		{{R: bytes.NewReader(v1.Bytes()[:8])}},
+	}
+	for _, srcs := range tests {
+		var buf bytes.Buffer
+		if err := Merge(&buf, srcs...); err == nil {
+			t.Fatalf(`exp non-nil err merging %v`, srcs)
+		}
+	}
+}
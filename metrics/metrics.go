@@ -0,0 +1,357 @@
+// Package metrics implements an exporter of metrics derived from a live trace
+// stream in the Prometheus text exposition format, so the signals of a trace
+// may feed existing dashboards and alerts.
+//
+// An Exporter is fed the output of the runtime tracer and served over HTTP,
+// for example:
+//
+//	r, w := io.Pipe()
+//	if err := trace.Start(w); err != nil {
+//		return err
+//	}
+//	exp := metrics.NewExporter()
+//	go exp.Consume(r)
+//	http.Handle(`/metrics/trace`, exp)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// Buckets are the upper bounds in seconds of the buckets of the histograms of
+// an Exporter.
+var Buckets = []float64{
+	1e-5, 5e-5, 1e-4, 5e-4, 1e-3, 5e-3, 1e-2, 5e-2, 0.1, 0.5, 1, 5}
+
+// blockReasons maps the events a goroutine may block with to the value of the
+// reason label of the block events counter.
+var blockReasons = map[event.Type]string{
+	event.EvGoBlock:       `other`,
+	event.EvGoBlockSend:   `send`,
+	event.EvGoBlockRecv:   `recv`,
+	event.EvGoBlockSelect: `select`,
+	event.EvGoBlockSync:   `sync`,
+	event.EvGoBlockCond:   `cond`,
+	event.EvGoBlockNet:    `net`,
+	event.EvGoBlockGC:     `gc`,
+	event.EvGoSleep:       `sleep`,
+	event.EvGoSysBlock:    `syscall`,
+}
+
+// Exporter consumes a trace stream and exports the following metrics:
+//
+//	go_trace_events_total                  counter of events decoded
+//	go_trace_goroutines_created_total      counter of goroutines created
+//	go_trace_block_events_total{reason}    counter of goroutines blocking
+//	go_trace_gc_pause_seconds              histogram of stop the world pauses
+//	go_trace_sched_latency_seconds         histogram of the time goroutines
+//	                                       waited to run once runnable
+//
+// The rate of goroutines created per second is given by the rate function of
+// Prometheus over the counter.
+//
+// Batches of a live stream are received out of order, so the events which
+// begin and end the durations of the histograms are paired by the goroutine
+// and sequence they refer to rather than by the order they are received. The
+// runtime declares the frequency of its clock in the trace footer, until then
+// it is estimated from the wall clock time batches were received at.
+type Exporter struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	events  uint64
+	created uint64
+	blocks  map[string]uint64
+	pauses  histogram
+	latency histogram
+
+	// freq is the frequency declared by the stream and first and last the
+	// tick and wall clock times of the batches it was estimated from.
+	freq        uint64
+	first, last event.Anchor
+	deferred    []observation
+
+	// stw holds the start of the pause on each P, wakes the most recent time
+	// each goroutine became runnable and starts the most recent start of each
+	// goroutine received before the time it became runnable.
+	stw    map[int64]int64
+	wakes  map[uint64]pending
+	starts map[uint64]pending
+}
+
+// pending is a time a goroutine became runnable or started running, with the
+// sequence of the start. The local events emitted on the P a goroutine last
+// ran on do not carry a sequence and have a seq of zero, as do the starts
+// paired with them.
+type pending struct {
+	seq uint64
+	ts  int64
+}
+
+// paired reports if the wake w and the start s refer to the same time a
+// goroutine waited to run.
+func paired(w, s pending) bool {
+	return w.seq == 0 || s.seq == 0 || w.seq == s.seq
+}
+
+// observation is a duration in ticks observed before the frequency was known.
+type observation struct {
+	h     *histogram
+	ticks int64
+}
+
+// NewExporter returns a new Exporter.
+func NewExporter() *Exporter {
+	return &Exporter{
+		now:     time.Now,
+		blocks:  make(map[string]uint64),
+		pauses:  newHistogram(),
+		latency: newHistogram(),
+		stw:     make(map[int64]int64),
+		wakes:   make(map[uint64]pending),
+		starts:  make(map[uint64]pending),
+	}
+}
+
+// Consume decodes the trace stream r into the metrics of the Exporter until it
+// ends, which must be Version2 or later. It returns nil when the stream ended
+// cleanly with or without the trace footer, otherwise the error which broke
+// it.
+func (e *Exporter) Consume(r io.Reader) error {
+	dec := encoding.NewDecoder(r)
+	ver, err := dec.Version()
+	if err != nil {
+		return err
+	}
+	if ver == event.Version1 {
+		return fmt.Errorf(`exporting %v is not supported`, ver)
+	}
+
+	// Arguments are read through the typed accessors in the layout of ver.
+	for dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			break
+		}
+		e.mu.Lock()
+		e.visit(ver, evt)
+		e.mu.Unlock()
+	}
+	return dec.Err()
+}
+
+// visit updates the metrics with evt, decoded from a trace of version ver.
+func (e *Exporter) visit(ver event.Version, evt *event.Event) {
+	e.events++
+	if reason, ok := blockReasons[evt.Type]; ok {
+		e.blocks[reason]++
+	}
+
+	switch evt.Type {
+	case event.EvBatch:
+		e.estimate(evt.Ts)
+	case event.EvFrequency:
+		if args, ok := evt.Frequency(ver); ok && args.Frequency > 0 {
+			e.freq = args.Frequency
+			e.flush()
+		}
+	case event.EvGCSTWStart:
+		e.stw[evt.P] = evt.Ts
+	case event.EvGCSTWDone:
+		if start, ok := e.stw[evt.P]; ok {
+			delete(e.stw, evt.P)
+			e.observe(&e.pauses, evt.Ts-start)
+		}
+	case event.EvGoCreate:
+		if args, ok := evt.GoCreate(ver); ok {
+			e.created++
+			e.wake(args.NewGoroutineID, pending{1, evt.Ts})
+		}
+	case event.EvGoUnblock:
+		if args, ok := evt.GoUnblock(ver); ok {
+			e.wake(args.GoroutineID, pending{args.Sequence + 1, evt.Ts})
+		}
+	case event.EvGoUnblockLocal:
+		if args, ok := evt.GoUnblockLocal(ver); ok {
+			e.wake(args.GoroutineID, pending{0, evt.Ts})
+		}
+	case event.EvGoStart:
+		if args, ok := evt.GoStart(ver); ok {
+			e.start(args.GoroutineID, pending{args.Sequence, evt.Ts})
+		}
+	case event.EvGoStartLabel:
+		if args, ok := evt.GoStartLabel(ver); ok {
+			e.start(args.GoroutineID, pending{args.Sequence, evt.Ts})
+		}
+	case event.EvGoStartLocal:
+		if args, ok := evt.GoStartLocal(ver); ok {
+			e.start(args.GoroutineID, pending{0, evt.Ts})
+		}
+	case event.EvGoEnd:
+		delete(e.starts, uint64(evt.G))
+		delete(e.wakes, uint64(evt.G))
+	}
+}
+
+// wake records goroutine g became runnable, observing its latency if it was
+// received after the start it is paired with.
+func (e *Exporter) wake(g uint64, w pending) {
+	if s, ok := e.starts[g]; ok && w.seq != 0 && paired(w, s) {
+		delete(e.starts, g)
+		e.observe(&e.latency, s.ts-w.ts)
+		return
+	}
+	e.wakes[g] = w
+}
+
+// start records goroutine g started running, observing its latency if the
+// time it became runnable was received.
+func (e *Exporter) start(g uint64, s pending) {
+	if w, ok := e.wakes[g]; ok && paired(w, s) {
+		delete(e.wakes, g)
+		e.observe(&e.latency, s.ts-w.ts)
+		return
+	}
+	// Only starts which carry a sequence may precede their wake, as local
+	// events are emitted on the same P in order.
+	if s.seq != 0 {
+		e.starts[g] = s
+	}
+}
+
+// estimate records a batch beginning at ts was received, for estimating the
+// frequency until it is declared.
+func (e *Exporter) estimate(ts int64) {
+	now := e.now()
+	if e.first.Time.IsZero() {
+		e.first = event.Anchor{Time: now, Ticks: ts}
+		return
+	}
+	if ts < e.first.Ticks {
+		e.first.Ticks = ts
+	}
+	if ts > e.last.Ticks {
+		e.last.Ticks = ts
+	}
+	e.last.Time = now
+	e.flush()
+}
+
+// frequency returns the frequency declared by the stream, or an estimate of it
+// when it has not yet been declared. Zero is returned when neither is known.
+func (e *Exporter) frequency() uint64 {
+	if e.freq > 0 {
+		return e.freq
+	}
+	wall := e.last.Time.Sub(e.first.Time)
+	if wall <= 0 || e.last.Ticks <= e.first.Ticks {
+		return 0
+	}
+	return uint64(float64(e.last.Ticks-e.first.Ticks) / wall.Seconds())
+}
+
+// observe adds a duration in ticks to h, deferring it until the frequency is
+// known.
+func (e *Exporter) observe(h *histogram, ticks int64) {
+	if ticks < 0 {
+		return
+	}
+	freq := e.frequency()
+	if freq == 0 {
+		e.deferred = append(e.deferred, observation{h, ticks})
+		return
+	}
+	h.observe(float64(ticks) / float64(freq))
+}
+
+// flush adds the deferred observations once the frequency is known.
+func (e *Exporter) flush() {
+	freq := e.frequency()
+	if freq == 0 {
+		return
+	}
+	for _, o := range e.deferred {
+		o.h.observe(float64(o.ticks) / float64(freq))
+	}
+	e.deferred = nil
+}
+
+// histogram is a Prometheus histogram with the bounds of Buckets.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram() histogram {
+	return histogram{counts: make([]uint64, len(Buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	i := sort.SearchFloat64s(Buckets, v)
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+// ServeHTTP implements http.Handler by writing the metrics of the Exporter.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(`Content-Type`, `text/plain; version=0.0.4; charset=utf-8`)
+	e.Write(w)
+}
+
+// Write writes the metrics of the Exporter to w in the Prometheus text
+// exposition format.
+func (e *Exporter) Write(w io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	counter := func(name, help string) {
+		fmt.Fprintf(bw, "# HELP %v %v\n# TYPE %v counter\n", name, help, name)
+	}
+	counter(`go_trace_events_total`, `Events decoded from the trace stream.`)
+	fmt.Fprintf(bw, "go_trace_events_total %v\n", e.events)
+	counter(`go_trace_goroutines_created_total`, `Goroutines created.`)
+	fmt.Fprintf(bw, "go_trace_goroutines_created_total %v\n", e.created)
+	counter(`go_trace_block_events_total`, `Goroutines blocking, by reason.`)
+	reasons := make([]string, 0, len(e.blocks))
+	for reason := range e.blocks {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(bw, "go_trace_block_events_total{reason=%q} %v\n",
+			reason, e.blocks[reason])
+	}
+	writeHistogram(bw, `go_trace_gc_pause_seconds`,
+		`Stop the world pauses of the garbage collector.`, &e.pauses)
+	writeHistogram(bw, `go_trace_sched_latency_seconds`,
+		`Time goroutines waited to run once runnable.`, &e.latency)
+	return bw.Flush()
+}
+
+func writeHistogram(w io.Writer, name, help string, h *histogram) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v histogram\n", name, help, name)
+	var n uint64
+	for i, le := range Buckets {
+		n += h.counts[i]
+		fmt.Fprintf(w, "%v_bucket{le=%q} %v\n",
+			name, strconv.FormatFloat(le, 'g', -1, 64), n)
+	}
+	fmt.Fprintf(w, "%v_bucket{le=\"+Inf\"} %v\n", name, h.count)
+	fmt.Fprintf(w, "%v_sum %v\n", name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%v_count %v\n", name, h.count)
+}
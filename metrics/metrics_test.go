@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/internal/tracefile"
+)
+
+var traceList tracefile.TraceList
+
+func init() {
+	var err error
+	traceList, err = tracefile.Load(`../internal/tracefile`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ordered decodes and orders the events of the trace b.
+func ordered(t *testing.T, b []byte) (*event.Trace, []*event.Event) {
+	dec := encoding.NewDecoder(bytes.NewReader(b))
+	ver, err := dec.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := event.NewTrace(ver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := event.NewOrderer(ver)
+	for dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			t.Fatal(err)
+		}
+		if err := tr.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+		o.Push(evt)
+	}
+	var evts []*event.Event
+	for {
+		evt, err := o.Next()
+		if err == io.EOF {
+			return tr, evts
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+}
+
+// scrape returns the samples written by e keyed by their name and labels.
+func scrape(t *testing.T, e *Exporter) map[string]float64 {
+	rr := httptest.NewRecorder()
+	e.ServeHTTP(rr, httptest.NewRequest(`GET`, `/metrics`, nil))
+	if ct := rr.Header().Get(`Content-Type`); !strings.HasPrefix(ct, `text/plain`) {
+		t.Fatalf(`exp text content type; got %q`, ct)
+	}
+
+	out := make(map[string]float64)
+	sc := bufio.NewScanner(rr.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, `#`) {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if i < 0 || err != nil {
+			t.Fatalf(`exp sample; got %q`, line)
+		}
+		out[line[:i]] = v
+	}
+	return out
+}
+
+func TestExporter(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		if tf.Version == event.Version1 {
+			continue
+		}
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			// The frequency is declared in the footer, so it is given up front
+			// rather than estimated from the time the batches are received.
+			tr, evts := ordered(t, tf.Bytes())
+			e := NewExporter()
+			e.freq = tr.Frequency
+			if err := e.Consume(bytes.NewReader(tf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			got := scrape(t, e)
+
+			var created, recv float64
+			for _, evt := range evts {
+				switch evt.Type {
+				case event.EvGoCreate:
+					created++
+				case event.EvGoBlockRecv:
+					recv++
+				}
+			}
+			if got[`go_trace_goroutines_created_total`] != created {
+				t.Fatalf(`exp %v goroutines created; got %v`,
+					created, got[`go_trace_goroutines_created_total`])
+			}
+			if got[`go_trace_block_events_total{reason="recv"}`] != recv {
+				t.Fatalf(`exp %v recv blocks; got %v`,
+					recv, got[`go_trace_block_events_total{reason="recv"}`])
+			}
+
+			// Latencies paired out of order match those of the ordered trace.
+			lats := tr.SchedLatencies(evts, -1)
+			if n := got[`go_trace_sched_latency_seconds_count`]; n != float64(len(lats)) {
+				t.Fatalf(`exp %v sched latencies; got %v`, len(lats), n)
+			}
+			if n := got[`go_trace_sched_latency_seconds_bucket{le="+Inf"}`]; n != float64(len(lats)) {
+				t.Fatalf(`exp +Inf bucket of %v; got %v`, len(lats), n)
+			}
+			var sum int64
+			for _, l := range lats {
+				sum += l.Latency
+			}
+			exp := tr.TicksToDuration(uint64(sum)).Seconds()
+			if d := got[`go_trace_sched_latency_seconds_sum`] - exp; d > 1e-6 || d < -1e-6 {
+				t.Fatalf(`exp latency sum %v; got %v`, exp, got[`go_trace_sched_latency_seconds_sum`])
+			}
+
+			gc := tr.GC(evts)
+			exp = tr.TicksToDuration(uint64(gc.PauseTime)).Seconds()
+			if d := got[`go_trace_gc_pause_seconds_sum`] - exp; d > 1e-6 || d < -1e-6 {
+				t.Fatalf(`exp pause sum %v; got %v`, exp, got[`go_trace_gc_pause_seconds_sum`])
+			}
+		})
+	}
+}
+
+func TestExporterEstimate(t *testing.T) {
+	e, clock := NewExporter(), time.Unix(0, 0)
+	e.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	// Pauses observed before the frequency is known are deferred until it may
+	// be estimated, here from batches a second and 1000 ticks apart.
+	for _, evt := range []*event.Event{
+		{Type: event.EvBatch, Ts: 1000, Args: []uint64{0, 1000}},
+		{Type: event.EvGCSTWStart, Ts: 1000, Args: []uint64{0, 0}},
+		{Type: event.EvGCSTWDone, Ts: 1001, Args: []uint64{1}},
+		{Type: event.EvBatch, Ts: 2000, Args: []uint64{0, 2000}},
+	} {
+		e.visit(event.Latest, evt)
+	}
+	got := scrape(t, e)
+	if got[`go_trace_gc_pause_seconds_count`] != 1 ||
+		got[`go_trace_gc_pause_seconds_bucket{le="0.001"}`] != 1 ||
+		got[`go_trace_gc_pause_seconds_bucket{le="0.0005"}`] != 0 {
+		t.Fatalf(`exp a single 1ms pause; got %v`, got)
+	}
+}
+
+func TestExporterErrors(t *testing.T) {
+	v1 := traceList.ByName(`log.trace`)[0]
+	if v1.Version != event.Version1 {
+		t.Fatalf(`exp first fixture to be Version1; got %v`, v1.Version)
+	}
+	if err := NewExporter().Consume(bytes.NewReader(v1.Bytes())); err == nil {
+		t.Fatal(`exp non-nil err consuming Version1`)
+	}
+	if err := NewExporter().Consume(bytes.NewReader(nil)); err == nil {
+		t.Fatal(`exp non-nil err consuming an empty stream`)
+	}
+}
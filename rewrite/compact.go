@@ -0,0 +1,181 @@
+package rewrite
+
+import (
+	"fmt"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// Compactor is a Transformer renumbering the string and stack IDs of a trace,
+// and optionally its goroutine IDs, into a dense range beginning at 1 in the
+// order they are first referenced. Placed after stages which drop events it
+// shrinks the output and makes the IDs of filtered traces stable, so diffs
+// between them are meaningful.
+//
+// The EvString and EvStack events declaring an ID are held until the ID is
+// referenced by a surviving event and emitted immediately before it, those
+// which are never referenced are dropped. An ID of zero means no string or
+// stack and is never renumbered. Events must be in the layout of Version2 and
+// later, the EvBatch events of Version1 traces return an error.
+type Compactor struct {
+
+	// Goroutines will also renumber the goroutine IDs of event arguments and
+	// the G field of events when true.
+	Goroutines bool
+
+	// keep retains the original string and stack IDs while still dropping
+	// the declarations which are never referenced, see Dropper.
+	keep bool
+
+	strs, stks, gs map[uint64]uint64
+	held           map[declKey]*event.Event
+}
+
+// declKey identifies a held EvString or EvStack declaration.
+type declKey struct {
+	typ event.Type
+	id  uint64
+}
+
+// Compact returns a Compactor renumbering string and stack IDs.
+func Compact() *Compactor {
+	return newCompactor(false)
+}
+
+func newCompactor(keep bool) *Compactor {
+	return &Compactor{
+		keep: keep,
+		strs: make(map[uint64]uint64),
+		stks: make(map[uint64]uint64),
+		gs:   make(map[uint64]uint64),
+		held: make(map[declKey]*event.Event),
+	}
+}
+
+// String implements fmt.Stringer for reporting stage errors.
+func (c *Compactor) String() string {
+	return `compact`
+}
+
+// Transform implements Transformer by renumbering the IDs of evt.
+func (c *Compactor) Transform(evt *event.Event, emit Emitter) error {
+	switch evt.Type {
+	case event.EvBatch:
+		if len(evt.Args) != 2 {
+			return fmt.Errorf(`expected 2 arguments for event %v`, evt.Type)
+		}
+	case event.EvString, event.EvStack:
+		if len(evt.Args) == 0 {
+			return fmt.Errorf(`expected at least 1 argument for event %v`, evt.Type)
+		}
+		ids := c.stks
+		if evt.Type == event.EvString {
+			ids = c.strs
+		}
+		id := evt.Args[0]
+		if _, ok := ids[id]; !ok {
+			c.held[declKey{evt.Type, id}] = evt
+			return nil
+		}
+		return c.release(evt, emit)
+	}
+
+	for i, name := range evt.Type.Args() {
+		if i >= len(evt.Args) {
+			break
+		}
+		var err error
+		switch name {
+		case event.ArgStackID, event.ArgNewStackID:
+			evt.Args[i], err = c.ref(event.EvStack, evt.Args[i], emit)
+		case event.ArgLabelStringID, event.ArgNameStringID, event.ArgKeyStringID:
+			evt.Args[i], err = c.ref(event.EvString, evt.Args[i], emit)
+		case event.ArgGoroutineID, event.ArgNewGoroutineID:
+			if c.Goroutines {
+				evt.Args[i] = c.g(evt.Args[i])
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if c.Goroutines && evt.G > 0 {
+		evt.G = int64(c.g(uint64(evt.G)))
+	}
+	return emit(evt)
+}
+
+// Flush implements Flusher by dropping the declarations which were never
+// referenced.
+func (c *Compactor) Flush(emit Emitter) error {
+	c.held = make(map[declKey]*event.Event)
+	return nil
+}
+
+// ref returns the new ID of the string or stack id, assigning the next ID if it
+// has not been referenced before and emitting its declaration if it was held.
+func (c *Compactor) ref(typ event.Type, id uint64, emit Emitter) (uint64, error) {
+	if id == 0 {
+		return 0, nil
+	}
+	ids := c.stks
+	if typ == event.EvString {
+		ids = c.strs
+	}
+	if to, ok := ids[id]; ok {
+		return to, nil
+	}
+	to := uint64(len(ids) + 1)
+	if c.keep {
+		to = id
+	}
+	ids[id] = to
+
+	key := declKey{typ, id}
+	if evt, ok := c.held[key]; ok {
+		delete(c.held, key)
+		if err := c.release(evt, emit); err != nil {
+			return 0, err
+		}
+	}
+	return to, nil
+}
+
+// release renumbers the referenced declaration evt and emits it, along with
+// the declarations of the function and file names of a stack.
+func (c *Compactor) release(evt *event.Event, emit Emitter) error {
+	if evt.Type == event.EvString {
+		evt.Args[0] = c.strs[evt.Args[0]]
+		return emit(evt)
+	}
+
+	const frameSize = 4
+	if len(evt.Args) < 2 || len(evt.Args)-2 != int(evt.Args[1])*frameSize {
+		return fmt.Errorf(`stack %v has malformed frames`, evt.Args[0])
+	}
+	evt.Args[0] = c.stks[evt.Args[0]]
+	for pos := 2; pos < len(evt.Args); pos += frameSize {
+		for _, i := range []int{pos + 1, pos + 2} {
+			to, err := c.ref(event.EvString, evt.Args[i], emit)
+			if err != nil {
+				return err
+			}
+			evt.Args[i] = to
+		}
+	}
+	return emit(evt)
+}
+
+// g returns the new ID of goroutine id, assigning the next ID if it has not
+// been seen before.
+func (c *Compactor) g(id uint64) uint64 {
+	if id == 0 {
+		return 0
+	}
+	if to, ok := c.gs[id]; ok {
+		return to
+	}
+	to := uint64(len(c.gs) + 1)
+	c.gs[id] = to
+	return to
+}
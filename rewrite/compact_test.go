@@ -0,0 +1,167 @@
+package rewrite
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// resolved is an event with its stack and string references resolved, for
+// comparing events across renumbering.
+type resolved struct {
+	typ        event.Type
+	stk, label string
+	g          uint64
+}
+
+func resolveAll(t *testing.T, b []byte) (*event.Trace, []resolved) {
+	var evts []*event.Event
+	dec := encoding.NewDecoder(bytes.NewReader(b))
+	ver, err := dec.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+		evts = append(evts, evt.Copy())
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := event.NewTrace(ver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Lenient = true
+	for _, evt := range evts {
+		if err := tr.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ws := tr.Warnings(); len(ws) != 0 {
+		t.Fatalf(`exp no warnings; got %v`, ws)
+	}
+
+	var out []resolved
+	for _, evt := range evts {
+		switch evt.Type {
+		case event.EvBatch, event.EvFrequency, event.EvString, event.EvStack:
+			continue
+		}
+		r := resolved{typ: evt.Type}
+		if i, ok := evt.Type.Arg(event.ArgNewGoroutineID); ok {
+			r.g = evt.Args[i]
+		}
+		if i, ok := evt.Type.Arg(event.ArgStackID); ok && evt.Args[i] != 0 {
+			r.stk = tr.Stacks[evt.Args[i]].String()
+		}
+		if i, ok := evt.Type.Arg(event.ArgLabelStringID); ok {
+			r.label = tr.Strings[evt.Args[i]]
+		}
+		out = append(out, r)
+	}
+	return tr, out
+}
+
+func dense(t *testing.T, name string, n int, has func(id uint64) bool) {
+	for id := 1; id <= n; id++ {
+		if !has(uint64(id)) {
+			t.Fatalf(`exp %v IDs to be dense from 1 to %v; missing %v`, name, n, id)
+		}
+	}
+}
+
+func TestCompactor(t *testing.T) {
+	keep := event.ByType(event.EvBatch, event.EvFrequency, event.EvString,
+		event.EvStack, event.EvGoCreate, event.EvGoStartLabel, event.EvGoBlockRecv,
+		event.EvGoBlockSend, event.EvGoSysCall)
+	filter := NewFunc(func(evt *event.Event) (bool, error) { return keep(evt), nil })
+
+	for _, tf := range traceList.ByName(`log.trace`) {
+		if tf.Version == event.Version1 {
+			continue
+		}
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			filtered, err := runPipeline(t, tf, New(filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := Compact()
+			compacted, err := runPipeline(t, tf, New(filter, c))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(compacted) >= len(filtered) {
+				t.Fatalf(`exp compacted output smaller than %v bytes; got %v`,
+					len(filtered), len(compacted))
+			}
+
+			_, exp := resolveAll(t, filtered)
+			tr, got := resolveAll(t, compacted)
+			if len(exp) != len(got) {
+				t.Fatalf(`exp %v events; got %v`, len(exp), len(got))
+			}
+			for i := range exp {
+				if exp[i] != got[i] {
+					t.Fatalf(`exp event #%v to be %+v; got %+v`, i, exp[i], got[i])
+				}
+			}
+			if len(tr.Stacks) == 0 || len(tr.Stacks) != len(c.stks) {
+				t.Fatalf(`exp %v stacks; got %v`, len(c.stks), len(tr.Stacks))
+			}
+			dense(t, `stack`, len(tr.Stacks), func(id uint64) bool {
+				_, ok := tr.Stacks[id]
+				return ok
+			})
+			dense(t, `string`, len(tr.Strings), func(id uint64) bool {
+				_, ok := tr.Strings[id]
+				return ok
+			})
+
+			c = Compact()
+			c.Goroutines = true
+			compacted, err = runPipeline(t, tf, New(filter, c))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, got = resolveAll(t, compacted)
+			gs, seen := make(map[uint64]uint64), make(map[uint64]bool)
+			for i := range exp {
+				if got[i].g > uint64(len(c.gs)) {
+					t.Fatalf(`exp goroutine IDs within %v; got %v`, len(c.gs), got[i].g)
+				}
+				if to, ok := gs[exp[i].g]; ok && to != got[i].g {
+					t.Fatalf(`exp goroutine %v to be renumbered to %v; got %v`,
+						exp[i].g, to, got[i].g)
+				} else if !ok && seen[got[i].g] {
+					t.Fatalf(`exp goroutine %v to be renumbered once`, got[i].g)
+				}
+				gs[exp[i].g], seen[got[i].g] = got[i].g, true
+				got[i].g = exp[i].g
+				if exp[i] != got[i] {
+					t.Fatalf(`exp event #%v to be %+v; got %+v`, i, exp[i], got[i])
+				}
+			}
+			if len(gs) < 2 {
+				t.Fatalf(`exp several goroutines; got %v`, len(gs))
+			}
+		})
+	}
+
+	batch := event.MustNew(event.EvBatch, 1, 2)
+	batch.Args = append(batch.Args, 3)
+	nop := func(*event.Event) error { return nil }
+	if err := Compact().Transform(batch, nop); err == nil {
+		t.Fatal(`exp error for Version1 batch`)
+	}
+	c := Compact()
+	stk := &event.Event{Type: event.EvStack, Args: []uint64{1, 2}}
+	if err := c.Transform(stk, nop); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Transform(event.MustNew(event.EvGoSched, 1, 1), nop); err == nil {
+		t.Fatal(`exp error for malformed stack`)
+	}
+}
@@ -0,0 +1,111 @@
+package rewrite
+
+import (
+	"fmt"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// substitutes maps the goroutine state events which may be dropped by a
+// Dropper with Synthesize set to a generic event with the same effect on the
+// state of the goroutine and a prefix of its arguments.
+var substitutes = map[event.Type]event.Type{
+	event.EvGoSched:       event.EvGoPreempt,
+	event.EvGoPreempt:     event.EvGoSched,
+	event.EvGoSleep:       event.EvGoBlock,
+	event.EvGoBlockSend:   event.EvGoBlock,
+	event.EvGoBlockRecv:   event.EvGoBlock,
+	event.EvGoBlockSelect: event.EvGoBlock,
+	event.EvGoBlockSync:   event.EvGoBlock,
+	event.EvGoBlockCond:   event.EvGoBlock,
+	event.EvGoBlockNet:    event.EvGoBlock,
+	event.EvGoBlockGC:     event.EvGoBlock,
+	event.EvGoStartLabel:  event.EvGoStart,
+}
+
+// stateTypes are the events which change the state of a goroutine, as tracked
+// by an event.Validator.
+var stateTypes = event.ByType(
+	event.EvGoCreate, event.EvGoStart, event.EvGoEnd, event.EvGoStop,
+	event.EvGoSched, event.EvGoPreempt, event.EvGoSleep, event.EvGoBlock,
+	event.EvGoUnblock, event.EvGoBlockSend, event.EvGoBlockRecv,
+	event.EvGoBlockSelect, event.EvGoBlockSync, event.EvGoBlockCond,
+	event.EvGoBlockNet, event.EvGoSysBlock, event.EvGoSysExit,
+	event.EvGoWaiting, event.EvGoInSyscall, event.EvGoStartLocal,
+	event.EvGoUnblockLocal, event.EvGoSysExitLocal, event.EvGoStartLabel,
+	event.EvGoBlockGC)
+
+// Dropper is a Transformer removing events of selected types while keeping the
+// trace self-consistent. The timestamp of each dropped event is carried to the
+// next event of its batch so the timestamps of retained events are unchanged,
+// and the EvString and EvStack events which are no longer referenced by any
+// retained event are dropped with them, as by a Compactor which does not
+// renumber IDs.
+//
+// Events must be in the layout of Version2 and later, the EvBatch events of
+// Version1 traces return an error. The EvBatch, EvFrequency, EvString and
+// EvStack types may not be dropped.
+type Dropper struct {
+
+	// Synthesize will preserve the goroutine state transitions of dropped
+	// events so the output still passes an event.Validator when true. Events
+	// with a generic equivalent, such as EvGoBlockRecv and EvGoBlock, are
+	// replaced by it and the remaining goroutine state events are retained.
+	Synthesize bool
+
+	drop  [event.EvCount]bool
+	carry encoding.Carry
+	refs  *Compactor
+}
+
+// Drop returns a Dropper removing the events of the given types.
+func Drop(types ...event.Type) (*Dropper, error) {
+	d := &Dropper{refs: newCompactor(true)}
+	for _, typ := range types {
+		switch {
+		case !typ.Valid():
+			return nil, fmt.Errorf(`event type %v was not valid`, typ)
+		case typ == event.EvBatch, typ == event.EvFrequency,
+			typ == event.EvString, typ == event.EvStack:
+			return nil, fmt.Errorf(`event type %v may not be dropped`, typ)
+		}
+		d.drop[typ] = true
+	}
+	return d, nil
+}
+
+// String implements fmt.Stringer for reporting stage errors.
+func (d *Dropper) String() string {
+	return `drop`
+}
+
+// Transform implements Transformer by dropping, replacing or retaining evt.
+func (d *Dropper) Transform(evt *event.Event, emit Emitter) error {
+	if evt.Type == event.EvBatch {
+		d.carry.Keep(evt)
+		return d.refs.Transform(evt, emit)
+	}
+
+	if evt.Type.Valid() && d.drop[evt.Type] {
+		sub, ok := substitutes[evt.Type]
+		switch {
+		case !d.Synthesize || !stateTypes(evt):
+			d.carry.Drop(evt)
+			return nil
+		case ok:
+			if n := len(sub.Args()); len(evt.Args) > n {
+				evt.Args = evt.Args[:n]
+			}
+			evt.Type = sub
+		}
+	}
+	d.carry.Keep(evt)
+	return d.refs.Transform(evt, emit)
+}
+
+// Flush implements Flusher by dropping the declarations which were never
+// referenced.
+func (d *Dropper) Flush(emit Emitter) error {
+	return d.refs.Flush(emit)
+}
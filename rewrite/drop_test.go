@@ -0,0 +1,161 @@
+package rewrite
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// validate orders the events of the trace b and returns the first violation
+// found by an event.Validator.
+func validate(t *testing.T, ver event.Version, b []byte) error {
+	dec, evt := encoding.NewDecoder(bytes.NewReader(b)), new(event.Event)
+	o := event.NewOrderer(ver)
+	for dec.More() {
+		evt.Reset()
+		if err := dec.Decode(evt); err != nil {
+			t.Fatal(err)
+		}
+		o.Push(evt)
+	}
+	v, err := event.NewValidator(ver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		evt, err := o.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := v.Visit(evt); err != nil {
+			return err
+		}
+	}
+}
+
+func TestDropper(t *testing.T) {
+	if _, err := Drop(event.EvStack); err == nil {
+		t.Fatal(`exp error dropping stacks`)
+	}
+	if _, err := Drop(event.EvCount); err == nil {
+		t.Fatal(`exp error dropping invalid type`)
+	}
+
+	for _, tf := range traceList.ByName(`log.trace`) {
+		if tf.Version == event.Version1 {
+			continue
+		}
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			if err := validate(t, tf.Version, tf.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+			orig := count(t, tf.Bytes())
+			types := []event.Type{event.EvGoBlockRecv, event.EvGoSched,
+				event.EvGoStartLabel, event.EvGoCreate, event.EvGoUnblock}
+
+			t.Run(`Synthesize`, func(t *testing.T) {
+				d, err := Drop(types...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				d.Synthesize = true
+				got, err := runPipeline(t, tf, New(d))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := validate(t, tf.Version, got); err != nil {
+					t.Fatal(err)
+				}
+
+				counts := count(t, got)
+				for typ, exp := range map[event.Type]int{
+					event.EvGoBlockRecv:  0,
+					event.EvGoSched:      0,
+					event.EvGoStartLabel: 0,
+					event.EvGoCreate:     orig[event.EvGoCreate],
+					event.EvGoUnblock:    orig[event.EvGoUnblock],
+					event.EvGoBlock:      orig[event.EvGoBlock] + orig[event.EvGoBlockRecv],
+					event.EvGoPreempt:    orig[event.EvGoPreempt] + orig[event.EvGoSched],
+					event.EvGoStart:      orig[event.EvGoStart] + orig[event.EvGoStartLabel],
+				} {
+					if counts[typ] != exp {
+						t.Fatalf(`exp %v %v events; got %v`, exp, typ, counts[typ])
+					}
+				}
+			})
+			t.Run(`Drop`, func(t *testing.T) {
+				d, err := Drop(types...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := runPipeline(t, tf, New(d))
+				if err != nil {
+					t.Fatal(err)
+				}
+				counts := count(t, got)
+				for _, typ := range types {
+					if counts[typ] != 0 {
+						t.Fatalf(`exp no %v events; got %v`, typ, counts[typ])
+					}
+				}
+				if counts[event.EvStack] >= orig[event.EvStack] {
+					t.Fatalf(`exp orphaned stacks to be dropped; got %v of %v`,
+						counts[event.EvStack], orig[event.EvStack])
+				}
+
+				// Retained events keep their timestamps and references.
+				keep := event.ByType(types...)
+				var exp []resolved
+				tr, all := resolveAll(t, tf.Bytes())
+				for i, r := range all {
+					if !keep(&event.Event{Type: r.typ}) {
+						exp = append(exp, all[i])
+					}
+				}
+				_, after := resolveAll(t, got)
+				if len(after) != len(exp) {
+					t.Fatalf(`exp %v events; got %v`, len(exp), len(after))
+				}
+				for i := range exp {
+					if exp[i] != after[i] {
+						t.Fatalf(`exp event #%v to be %+v; got %+v`, i, exp[i], after[i])
+					}
+				}
+				if len(tr.Stacks) == 0 {
+					t.Fatal(`exp stacks in the original trace`)
+				}
+
+				var ts []int64
+				each := func(b []byte, fn func(evt *event.Event)) {
+					dec := encoding.NewDecoder(bytes.NewReader(b))
+					err := dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+						if evt.Ts != 0 && !keep(evt) {
+							fn(evt)
+						}
+						return nil
+					}))
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+				each(tf.Bytes(), func(evt *event.Event) { ts = append(ts, evt.Ts) })
+				var i int
+				each(got, func(evt *event.Event) {
+					if i >= len(ts) || ts[i] != evt.Ts {
+						t.Fatalf(`exp timestamp of event #%v to be unchanged`, i)
+					}
+					i++
+				})
+				if i != len(ts) {
+					t.Fatalf(`exp %v timed events; got %v`, len(ts), i)
+				}
+			})
+		})
+	}
+}
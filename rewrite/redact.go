@@ -0,0 +1,95 @@
+package rewrite
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/cstockton/go-trace/event"
+)
+
+// Replacement is a rule of a Redactor, see Literal and Regexp.
+type Replacement struct {
+	old  []byte
+	re   *regexp.Regexp
+	repl []byte
+}
+
+// Literal returns a Replacement of every occurrence of old with repl.
+func Literal(old, repl string) Replacement {
+	return Replacement{old: []byte(old), repl: []byte(repl)}
+}
+
+// Regexp returns a Replacement of every match of the regular expression
+// pattern with repl, which may refer to submatches as by the Expand method of
+// regexp.Regexp.
+func Regexp(pattern, repl string) (Replacement, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Replacement{}, err
+	}
+	return Replacement{re: re, repl: []byte(repl)}, nil
+}
+
+// MustRegexp is like Regexp but panics if the pattern does not compile.
+func MustRegexp(pattern, repl string) Replacement {
+	r, err := Regexp(pattern, repl)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (r Replacement) replace(b []byte) []byte {
+	if r.re != nil {
+		return r.re.ReplaceAll(b, r.repl)
+	}
+	if len(r.old) == 0 {
+		return b
+	}
+	return bytes.Replace(b, r.old, r.repl, -1)
+}
+
+// Redactor is a Transformer applying replacements to the payloads of EvString
+// and EvUserLog events, for removing paths, hostnames or other sensitive text
+// from a trace before it is shared. Only the payloads are changed, so string
+// IDs and every reference to them remain intact.
+type Redactor struct {
+	rules []Replacement
+
+	// Logs will also redact the values of EvUserLog events when true.
+	Logs bool
+}
+
+// Redact returns a Redactor applying each replacement in order to the payload
+// of every EvString and EvUserLog event.
+func Redact(rules ...Replacement) *Redactor {
+	return &Redactor{rules: rules, Logs: true}
+}
+
+// String implements fmt.Stringer for reporting stage errors.
+func (r *Redactor) String() string {
+	return `redact`
+}
+
+// Transform implements Transformer by redacting the payload of evt. Strings
+// decoded with the encoding.LazyStrings option have no payload to redact and
+// return an error rather than leaking the original text.
+func (r *Redactor) Transform(evt *event.Event, emit Emitter) error {
+	switch {
+	case evt.Type == event.EvString:
+	case evt.Type == event.EvUserLog && r.Logs:
+	default:
+		return emit(evt)
+	}
+	if len(evt.Data) == 0 && evt.Span.Len > 0 {
+		return fmt.Errorf(`payload of %v was not decoded`, evt.Type)
+	}
+
+	data := evt.Data
+	for _, rule := range r.rules {
+		data = rule.replace(data)
+	}
+	evt.Data = data
+	return emit(evt)
+}
@@ -0,0 +1,114 @@
+package rewrite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+func TestRedactor(t *testing.T) {
+	var got []*event.Event
+	emit := func(evt *event.Event) error {
+		got = append(got, evt)
+		return nil
+	}
+
+	r := Redact(
+		Literal(`/home/alice`, `~`),
+		MustRegexp(`token=(\w+)`, `token=<redacted>`),
+		Literal(``, `ignored`),
+	)
+	str := event.MustNew(event.EvString, 3)
+	str.Data = []byte(`/home/alice/src/main.go token=abc123`)
+	log := event.MustNew(event.EvUserLog, 1, 2, 4, 5)
+	log.Data = []byte(`user /home/alice`)
+	other := event.MustNew(event.EvGoStart, 1, 2, 3)
+
+	for _, evt := range []*event.Event{str, log, other} {
+		if err := r.Transform(evt, emit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(got) != 3 {
+		t.Fatalf(`exp 3 events emitted; got %v`, len(got))
+	}
+	if exp := `~/src/main.go token=<redacted>`; string(str.Data) != exp {
+		t.Fatalf(`exp string %q; got %q`, exp, str.Data)
+	}
+	if str.Args[0] != 3 {
+		t.Fatalf(`exp string id to be unchanged; got %v`, str.Args[0])
+	}
+	if exp := `user ~`; string(log.Data) != exp {
+		t.Fatalf(`exp log %q; got %q`, exp, log.Data)
+	}
+
+	r.Logs = false
+	log.Data = []byte(`/home/alice`)
+	if err := r.Transform(log, emit); err != nil {
+		t.Fatal(err)
+	}
+	if string(log.Data) != `/home/alice` {
+		t.Fatalf(`exp log to be unchanged; got %q`, log.Data)
+	}
+
+	lazy := event.MustNew(event.EvString, 4)
+	lazy.Span = event.Span{Off: 10, Len: 5}
+	if err := r.Transform(lazy, emit); err == nil {
+		t.Fatal(`exp error for lazily decoded string`)
+	}
+	if _, err := Regexp(`(`, ``); err == nil {
+		t.Fatal(`exp error for invalid pattern`)
+	}
+	if r.String() != `redact` {
+		t.Fatalf(`exp name redact; got %v`, r)
+	}
+}
+
+func TestRedactorPipeline(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		if tf.Version == event.Version1 {
+			continue
+		}
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			strs := func(b []byte) map[uint64]string {
+				out := make(map[uint64]string)
+				dec := encoding.NewDecoder(bytes.NewReader(b))
+				err := dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+					if evt.Type == event.EvString {
+						out[evt.Args[0]] = string(evt.Data)
+					}
+					return nil
+				}))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return out
+			}
+
+			got, err := runPipeline(t, tf, New(Redact(Literal(`main`, `XXXX`))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			before, after := strs(tf.Bytes()), strs(got)
+			if len(before) != len(after) {
+				t.Fatalf(`exp %v strings; got %v`, len(before), len(after))
+			}
+			var changed int
+			for id, s := range before {
+				exp := strings.Replace(s, `main`, `XXXX`, -1)
+				if after[id] != exp {
+					t.Fatalf(`exp string %v to be %q; got %q`, id, exp, after[id])
+				}
+				if exp != s {
+					changed++
+				}
+			}
+			if changed == 0 {
+				t.Fatal(`exp at least one string to be redacted`)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package rewrite
+
+import (
+	"errors"
+	"io"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// Retimer is a Transformer shifting and scaling the timestamps of events, for
+// aligning traces captured on different hosts before they are merged or for
+// zero basing the timestamps of a trace before it is shared. It rewrites the
+// events of a Pipeline as the ShiftTimestamps and ScaleTimestamps options of
+// the encoding package rewrite those given to an Encoder.
+//
+// The timestamps are rewritten by a Clock of the encoding package, which
+// rebases the batches and recomputes the deltas of their events. The Ts field
+// of events is not changed. Events must be in the layout of Version2 and later,
+// the EvBatch events of Version1 traces return an error.
+type Retimer struct {
+	clock *encoding.Clock
+}
+
+// Retime returns a Retimer adding shift ticks to the absolute timestamps of
+// events after rescaling them from a frequency of from ticks per second to a
+// frequency of to, see NewClock of the encoding package.
+func Retime(shift int64, from, to uint64) *Retimer {
+	return &Retimer{clock: encoding.NewClock(shift, from, to)}
+}
+
+// String implements fmt.Stringer for reporting stage errors.
+func (r *Retimer) String() string {
+	return `retime`
+}
+
+// Transform implements Transformer by rebasing the timestamps of evt.
+func (r *Retimer) Transform(evt *event.Event, emit Emitter) error {
+	if err := r.clock.Rebase(evt); err != nil {
+		return err
+	}
+	return emit(evt)
+}
+
+// BaseTimestamp returns the smallest base timestamp of the EvBatch events in
+// the trace read from r, which negated is the shift given to Retime to zero
+// base the timestamps of the trace.
+func BaseTimestamp(r io.Reader) (uint64, error) {
+	var (
+		base uint64
+		seen bool
+	)
+	dec := encoding.NewDecoder(r)
+	err := dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+		if evt.Type != event.EvBatch || len(evt.Args) != 2 {
+			return nil
+		}
+		if ts := evt.Args[1]; !seen || ts < base {
+			base, seen = ts, true
+		}
+		return nil
+	}))
+	if err != nil {
+		return 0, err
+	}
+	if !seen {
+		return 0, errors.New(`trace contained no batches`)
+	}
+	return base, nil
+}
@@ -0,0 +1,69 @@
+package rewrite
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+func TestRetimer(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		if tf.Version == event.Version1 {
+			continue
+		}
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			for _, test := range []struct {
+				shift    int64
+				from, to uint64
+			}{
+				{1000, 0, 0},
+				{0, 1e9, 5e8},
+				{-10, 3, 7},
+			} {
+				var exp bytes.Buffer
+				err := encoding.Transcode(&exp, bytes.NewReader(tf.Bytes()),
+					encoding.ShiftTimestamps(test.shift),
+					encoding.ScaleTimestamps(test.from, test.to))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := runPipeline(t, tf, New(Retime(test.shift, test.from, test.to)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(exp.Bytes(), got) {
+					t.Fatalf(`exp Retime%+v to match the encoder options`, test)
+				}
+			}
+
+			base, err := BaseTimestamp(bytes.NewReader(tf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if base == 0 {
+				t.Fatal(`exp non-zero base timestamp`)
+			}
+			got, err := runPipeline(t, tf, New(Retime(-int64(base), 0, 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if zero, err := BaseTimestamp(bytes.NewReader(got)); err != nil || zero != 0 {
+				t.Fatalf(`exp zero based trace; got base %v (err %v)`, zero, err)
+			}
+
+			_, err = runPipeline(t, tf, New(Retime(-int64(base)-1, 0, 0)))
+			if err == nil {
+				t.Fatal(`exp error for negative timestamps`)
+			}
+		})
+	}
+
+	batch := event.MustNew(event.EvBatch, 1, 2)
+	batch.Args = append(batch.Args, 3)
+	err := Retime(1, 0, 0).Transform(batch, func(*event.Event) error { return nil })
+	if err == nil {
+		t.Fatal(`exp error for Version1 batch`)
+	}
+}
@@ -0,0 +1,262 @@
+// Package rewrite implements streaming pipelines which decode a trace, pass
+// each event through a chain of Transformers and encode the result, for tools
+// which strip, anonymize, trim or remap traces.
+package rewrite
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// Emitter passes an event to the next stage of a Pipeline. The event is owned
+// by the Pipeline once given to an Emitter and must not be used afterwards.
+type Emitter func(evt *event.Event) error
+
+// Transformer is a stage of a Pipeline.
+type Transformer interface {
+
+	// Transform is called with each event reaching this stage in the order
+	// they were decoded. It may modify evt and give it, or any other events,
+	// to emit to pass them to the next stage. Events which are not emitted are
+	// dropped. Transform is never called concurrently for a single stage.
+	Transform(evt *event.Event, emit Emitter) error
+}
+
+// Flusher may be implemented by a Transformer which holds events across calls
+// to Transform. Flush is called once every event has been given to Transform
+// so any held events may be emitted.
+type Flusher interface {
+	Flush(emit Emitter) error
+}
+
+// Func is a Transformer calling an ordinary function with each event, see
+// NewFunc. The timestamp delta of each event it drops is carried to the next
+// event retained from the same batch so the timestamps of retained events are
+// unchanged, events must be in the layout of Version2 and later.
+type Func struct {
+	fn    func(evt *event.Event) (keep bool, err error)
+	carry encoding.Carry
+}
+
+// NewFunc returns a Func calling fn, which may modify evt in place and returns
+// false to drop the event, matching the encoding.TransformFunc given to
+// Transcode. A Func may only be used by a single Pipeline at a time.
+func NewFunc(fn func(evt *event.Event) (keep bool, err error)) *Func {
+	return &Func{fn: fn}
+}
+
+// Transform implements Transformer by calling fn.
+func (f *Func) Transform(evt *event.Event, emit Emitter) error {
+	keep, err := f.fn(evt)
+	if err != nil {
+		return err
+	}
+	if !keep {
+		f.carry.Drop(evt)
+		return nil
+	}
+	f.carry.Keep(evt)
+	return emit(evt)
+}
+
+// DefaultBuffer is the number of events queued between stages of a Pipeline
+// with a Buffer of zero.
+const DefaultBuffer = 64
+
+// Pipeline chains a Decoder through a series of Transformers to an Encoder.
+// Each stage runs in its own goroutine and is connected to the next by a
+// queue holding at most Buffer events, so a slow stage blocks those before it
+// rather than growing memory without bound.
+type Pipeline struct {
+
+	// Stages are the Transformers each event is passed through in order.
+	Stages []Transformer
+
+	// Buffer is the number of events which may be queued between stages, when
+	// zero DefaultBuffer is used.
+	Buffer int
+}
+
+// New returns a Pipeline passing events through the given stages.
+func New(stages ...Transformer) *Pipeline {
+	return &Pipeline{Stages: stages}
+}
+
+// StageError is returned by Run when a stage of a Pipeline fails.
+type StageError struct {
+
+	// Stage is the index of the failing Transformer within Stages, or -1 when
+	// decoding failed and len(Stages) when encoding failed.
+	Stage int
+
+	// Name is the name of the stage, either "decode", "encode" or the String
+	// method of a Transformer implementing fmt.Stringer, otherwise its type.
+	Name string
+
+	// Type and Off describe the event being handled by the stage when it
+	// failed, Type is EvNone when no event was being handled.
+	Type event.Type
+	Off  int
+
+	// Err is the error returned from the stage.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *StageError) Error() string {
+	if e.Type == event.EvNone {
+		return fmt.Sprintf(`%v stage failed: %v`, e.Name, e.Err)
+	}
+	return fmt.Sprintf(`%v stage failed on %v at 0x%x: %v`,
+		e.Name, e.Type, e.Off, e.Err)
+}
+
+// Unwrap returns the error returned from the stage.
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// Run decodes every event of dec, passes them through each stage and emits
+// those reaching the end of the pipeline to enc, which should target the
+// version of the trace read by dec. The Encoder is flushed once the final
+// event is written but not closed.
+//
+// The first error from any stage is returned as a *StageError and stops the
+// remaining stages. Run returns once every stage has stopped, so dec and enc
+// may be reused by the caller.
+func (p *Pipeline) Run(dec *encoding.Decoder, enc *encoding.Encoder) error {
+	n := p.Buffer
+	if n <= 0 {
+		n = DefaultBuffer
+	}
+
+	r := &run{done: make(chan struct{})}
+	pool := event.NewPool(0, 0)
+
+	var wg sync.WaitGroup
+	decoded := make(chan *event.Event, n)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(decoded)
+		for dec.More() {
+			evt := pool.Get()
+			if err := dec.Decode(evt); err != nil {
+				break
+			}
+			if !r.send(decoded, evt) {
+				return
+			}
+		}
+		if err := dec.Err(); err != nil {
+			r.fail(&StageError{Stage: -1, Name: `decode`, Err: err})
+		}
+	}()
+
+	var in <-chan *event.Event = decoded
+	for i, t := range p.Stages {
+		out := make(chan *event.Event, n)
+		wg.Add(1)
+		go func(i int, t Transformer, in <-chan *event.Event, out chan<- *event.Event) {
+			defer wg.Done()
+			defer close(out)
+			r.stage(i, t, in, out)
+		}(i, t, in, out)
+		in = out
+	}
+
+	for evt := range in {
+		if r.stopped() {
+			continue
+		}
+		if err := enc.Emit(evt); err != nil {
+			r.fail(&StageError{Stage: len(p.Stages), Name: `encode`,
+				Type: evt.Type, Off: evt.Off, Err: err})
+			continue
+		}
+		pool.Put(evt)
+	}
+	wg.Wait()
+
+	if r.err != nil {
+		return r.err
+	}
+	if err := enc.Flush(); err != nil {
+		return &StageError{Stage: len(p.Stages), Name: `encode`, Err: err}
+	}
+	return nil
+}
+
+// run is the state shared by the stages of a single call to Run.
+type run struct {
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+// fail records err if it is the first error of the run and stops all stages.
+func (r *run) fail(err error) {
+	r.once.Do(func() {
+		r.err = err
+		close(r.done)
+	})
+}
+
+func (r *run) stopped() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// send queues evt for the next stage, returning false if the run was stopped
+// while waiting for room in the queue.
+func (r *run) send(out chan<- *event.Event, evt *event.Event) bool {
+	select {
+	case out <- evt:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+func (r *run) stage(i int, t Transformer, in <-chan *event.Event, out chan<- *event.Event) {
+	name := stageName(t)
+	emit := func(evt *event.Event) error {
+		if !r.send(out, evt) {
+			return errStopped
+		}
+		return nil
+	}
+	for evt := range in {
+		if r.stopped() {
+			continue
+		}
+		typ, off := evt.Type, evt.Off
+		if err := t.Transform(evt, emit); err != nil && err != errStopped {
+			r.fail(&StageError{Stage: i, Name: name, Type: typ, Off: off, Err: err})
+		}
+	}
+	if f, ok := t.(Flusher); ok && !r.stopped() {
+		if err := f.Flush(emit); err != nil && err != errStopped {
+			r.fail(&StageError{Stage: i, Name: name, Err: err})
+		}
+	}
+}
+
+// errStopped is returned from an Emitter once the run has been stopped by a
+// failure in another stage, it is not reported.
+var errStopped = errors.New(`pipeline was stopped`)
+
+func stageName(t Transformer) string {
+	if s, ok := t.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf(`%T`, t)
+}
@@ -0,0 +1,212 @@
+package rewrite
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/internal/tracefile"
+)
+
+var traceList tracefile.TraceList
+
+func init() {
+	var err error
+	traceList, err = tracefile.Load(`../internal/tracefile`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// holdStrings holds EvString events until Flush to exercise Flusher.
+type holdStrings struct {
+	held []*event.Event
+}
+
+func (h *holdStrings) String() string { return `holdStrings` }
+
+func (h *holdStrings) Transform(evt *event.Event, emit Emitter) error {
+	if evt.Type == event.EvString {
+		h.held = append(h.held, evt)
+		return nil
+	}
+	return emit(evt)
+}
+
+func (h *holdStrings) Flush(emit Emitter) error {
+	for _, evt := range h.held {
+		if err := emit(evt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runPipeline(t *testing.T, tf *tracefile.Trace, p *Pipeline) ([]byte, error) {
+	var buf bytes.Buffer
+	dec := encoding.NewDecoder(bytes.NewReader(tf.Bytes()))
+	enc := encoding.NewEncoder(&buf, encoding.TargetVersion(tf.Version))
+	err := p.Run(dec, enc)
+	return buf.Bytes(), err
+}
+
+func count(t *testing.T, b []byte) map[event.Type]int {
+	counts := make(map[event.Type]int)
+	dec := encoding.NewDecoder(bytes.NewReader(b))
+	err := dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+		counts[evt.Type]++
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return counts
+}
+
+func TestPipeline(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		if tf.Version == event.Version1 {
+			continue
+		}
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			t.Run(`Identity`, func(t *testing.T) {
+				var exp bytes.Buffer
+				err := encoding.Transcode(&exp, bytes.NewReader(tf.Bytes()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := runPipeline(t, tf, &Pipeline{Buffer: 1})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(exp.Bytes(), got) {
+					t.Fatalf(`exp %v bytes matching Transcode; got %v`, exp.Len(), len(got))
+				}
+			})
+			t.Run(`Stages`, func(t *testing.T) {
+				orig := count(t, tf.Bytes())
+				var seen int
+				p := New(
+					NewFunc(func(evt *event.Event) (bool, error) {
+						seen++
+						return evt.Type != event.EvHeapAlloc, nil
+					}),
+					&holdStrings{},
+				)
+				got, err := runPipeline(t, tf, p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				counts := count(t, got)
+				if counts[event.EvHeapAlloc] != 0 {
+					t.Fatalf(`exp no HeapAlloc events; got %v`, counts[event.EvHeapAlloc])
+				}
+				for typ, n := range orig {
+					if typ != event.EvHeapAlloc && counts[typ] != n {
+						t.Fatalf(`exp %v %v events; got %v`, n, typ, counts[typ])
+					}
+				}
+				var total int
+				for _, n := range orig {
+					total += n
+				}
+				if seen != total {
+					t.Fatalf(`exp first stage to see %v events; got %v`, total, seen)
+				}
+
+				// Deltas of dropped events are carried to the retained events.
+				timestamps := func(b []byte) (ts []int64) {
+					dec := encoding.NewDecoder(bytes.NewReader(b))
+					err := dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+						if evt.Ts != 0 && evt.Type != event.EvHeapAlloc {
+							ts = append(ts, evt.Ts)
+						}
+						return nil
+					}))
+					if err != nil {
+						t.Fatal(err)
+					}
+					return ts
+				}
+				if exp, got := timestamps(tf.Bytes()), timestamps(got); !reflect.DeepEqual(exp, got) {
+					t.Fatal(`exp timestamps of retained events to be unchanged`)
+				}
+			})
+		})
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) { return 0, errors.New(`write failed`) }
+
+func TestPipelineErrors(t *testing.T) {
+	list := traceList.ByName(`log.trace`)
+	tf := list[len(list)-1]
+	errStage := errors.New(`stage failed`)
+
+	t.Run(`Transform`, func(t *testing.T) {
+		var n int
+		p := New(
+			NewFunc(func(evt *event.Event) (bool, error) { return true, nil }),
+			NewFunc(func(evt *event.Event) (bool, error) {
+				if n++; n == 10 {
+					return false, errStage
+				}
+				return true, nil
+			}),
+			&holdStrings{},
+		)
+		p.Buffer = 1
+		_, err := runPipeline(t, tf, p)
+		var se *StageError
+		if !errors.As(err, &se) || !errors.Is(err, errStage) {
+			t.Fatalf(`exp StageError wrapping %v; got %v`, errStage, err)
+		}
+		if se.Stage != 1 || se.Name != `*rewrite.Func` || se.Type == event.EvNone {
+			t.Fatalf(`exp stage 1 to fail on an event; got %+v`, se)
+		}
+	})
+	t.Run(`Flush`, func(t *testing.T) {
+		p := New(flushErr{errStage})
+		_, err := runPipeline(t, tf, p)
+		var se *StageError
+		if !errors.As(err, &se) || se.Stage != 0 || se.Type != event.EvNone {
+			t.Fatalf(`exp stage 0 flush error; got %v`, err)
+		}
+		if exp := `flushErr stage failed: stage failed`; err.Error() != exp {
+			t.Fatalf(`exp %q; got %q`, exp, err)
+		}
+	})
+	t.Run(`Decode`, func(t *testing.T) {
+		b := tf.Bytes()
+		dec := encoding.NewDecoder(bytes.NewReader(b[:len(b)/2]))
+		enc := encoding.NewEncoder(io.Discard, encoding.TargetVersion(tf.Version))
+		err := New(&holdStrings{}).Run(dec, enc)
+		var se *StageError
+		if !errors.As(err, &se) || se.Stage != -1 || se.Name != `decode` {
+			t.Fatalf(`exp decode StageError; got %v`, err)
+		}
+	})
+	t.Run(`Encode`, func(t *testing.T) {
+		dec := encoding.NewDecoder(bytes.NewReader(tf.Bytes()))
+		enc := encoding.NewEncoder(failWriter{}, encoding.TargetVersion(tf.Version))
+		err := New(&holdStrings{}).Run(dec, enc)
+		var se *StageError
+		if !errors.As(err, &se) || se.Stage != 1 || se.Name != `encode` {
+			t.Fatalf(`exp encode StageError; got %v`, err)
+		}
+	})
+}
+
+type flushErr struct{ err error }
+
+func (f flushErr) String() string { return `flushErr` }
+
+func (f flushErr) Transform(evt *event.Event, emit Emitter) error { return emit(evt) }
+
+func (f flushErr) Flush(emit Emitter) error { return f.err }
@@ -0,0 +1,211 @@
+// Package split implements the splitting of a trace into multiple standalone
+// traces, such as a trace for each window of time or each P, which may be
+// shared or loaded by tools that can not handle the trace as a whole.
+package split
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/rewrite"
+)
+
+// Func returns the key of the part evt belongs to and true, or false when evt
+// belongs to no part and is dropped. The Ts field of events given to a Func is
+// in nanoseconds.
+type Func func(evt *event.Event) (key int64, ok bool)
+
+// Opener returns the writer the part with the given key is written to. It is
+// called once for each part as its first event is split, and the writer is
+// not closed once the part is complete.
+type Opener func(key int64) (io.Writer, error)
+
+// ByWindow returns a Func splitting events into consecutive windows of time of
+// length d, keyed by their number from zero. The first window begins at the
+// first event given to the Func, so a Func may only be used for a single call
+// to Split.
+func ByWindow(d time.Duration) Func {
+	var (
+		start int64
+		seen  bool
+	)
+	return func(evt *event.Event) (int64, bool) {
+		if !seen {
+			start, seen = evt.Ts, true
+		}
+		if d <= 0 || evt.Ts < start {
+			return 0, true
+		}
+		return (evt.Ts - start) / int64(d), true
+	}
+}
+
+// ByP returns a Func splitting events by their P, keyed by its id.
+func ByP() Func {
+	return func(evt *event.Event) (int64, bool) {
+		return evt.P, true
+	}
+}
+
+// ByGoroutine returns a Func splitting the events of goroutines into the given
+// sets, keyed by the index of the first set holding the G of an event. Events
+// of goroutines within no set are dropped.
+func ByGoroutine(sets ...[]uint64) Func {
+	idx := make(map[uint64]int64)
+	for i := len(sets) - 1; i >= 0; i-- {
+		for _, g := range sets[i] {
+			idx[g] = int64(i)
+		}
+	}
+	return func(evt *event.Event) (int64, bool) {
+		key, ok := idx[uint64(evt.G)]
+		return key, ok
+	}
+}
+
+// part is a trace being split from the input.
+type part struct {
+	enc  *encoding.Encoder
+	refs *rewrite.Dropper
+
+	// batch is the number of the input batch the last event of the part was
+	// split from and last the offset of that event from the batch base.
+	batch int
+	last  uint64
+}
+
+func (pt *part) emit(evt *event.Event) error {
+	return pt.refs.Transform(evt, pt.enc.Emit)
+}
+
+// Split reads a trace from ra and writes the events of each part returned by
+// fn to the writer given by open for it. Each part is a standalone trace of
+// the same version as the input, holding a header, the frequency and only the
+// strings and stacks its events refer to. Events keep their timestamps and
+// batches are split along with them, so the events of an input batch written
+// to a part share a batch within it.
+//
+// The input must be Version2 or later. Parts are only consistent in their
+// layout, the events of a part may refer to goroutines and Ps whose state was
+// established by events written to another.
+func Split(ra io.ReaderAt, fn Func, open Opener) error {
+	dec := encoding.NewDecoder(
+		io.NewSectionReader(ra, 0, math.MaxInt64), encoding.Nanoseconds())
+	ver, err := dec.Version()
+	if err != nil {
+		return err
+	}
+	if ver == event.Version1 {
+		return fmt.Errorf(`splitting %v is not supported`, ver)
+	}
+
+	var (
+		parts = make(map[int64]*part)
+		keys  []int64
+		decl  []*event.Event
+		freq  *event.Event
+		batch *event.Event
+		n     int
+		acc   uint64
+	)
+	broadcast := func(evt *event.Event) error {
+		for _, key := range keys {
+			if err := parts[key].emit(evt.Copy()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	get := func(key int64) (*part, error) {
+		if pt, ok := parts[key]; ok {
+			return pt, nil
+		}
+		w, err := open(key)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := rewrite.Drop()
+		if err != nil {
+			return nil, err
+		}
+		pt := &part{
+			enc:   encoding.NewEncoder(w, encoding.TargetVersion(ver)),
+			refs:  refs,
+			batch: -1,
+		}
+		for _, evt := range decl {
+			if err := pt.emit(evt.Copy()); err != nil {
+				return nil, err
+			}
+		}
+		parts[key] = pt
+		keys = append(keys, key)
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		return pt, nil
+	}
+
+	err = dec.VisitAll(event.VisitorFunc(func(evt *event.Event) error {
+		switch evt.Type {
+		case event.EvBatch:
+			batch, acc = evt.Copy(), 0
+			n++
+			return nil
+		case event.EvFrequency:
+			freq = evt.Copy()
+			return broadcast(evt)
+		case event.EvString, event.EvStack:
+			decl = append(decl, evt.Copy())
+			return broadcast(evt)
+		}
+
+		// Timestamps within a batch are deltas from the prior event of the
+		// batch, those of a part are from the prior event written to it.
+		timed := len(evt.Args) > 0 && len(evt.Type.Args()) > 0 &&
+			evt.Type.Args()[0] == event.ArgTimestamp
+		if timed {
+			acc += evt.Args[0]
+		}
+		key, ok := fn(evt)
+		if !ok {
+			return nil
+		}
+		pt, err := get(key)
+		if err != nil {
+			return err
+		}
+		if pt.batch != n && batch != nil {
+			if err := pt.emit(batch.Copy()); err != nil {
+				return err
+			}
+			pt.batch, pt.last = n, 0
+		}
+		out := evt.Copy()
+		if timed {
+			out.Args[0], pt.last = acc-pt.last, acc
+		}
+		return pt.emit(out)
+	}))
+	if err != nil {
+		return err
+	}
+
+	if freq == nil {
+		return errors.New(`trace contains no frequency event`)
+	}
+	for _, key := range keys {
+		pt := parts[key]
+		if err := pt.refs.Flush(pt.enc.Emit); err != nil {
+			return err
+		}
+		if err := pt.enc.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package split
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/internal/tracefile"
+)
+
+var traceList tracefile.TraceList
+
+func init() {
+	var err error
+	traceList, err = tracefile.Load(`../internal/tracefile`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// decoded is a trace decoded with timestamps in nanoseconds.
+type decoded struct {
+	tr   *event.Trace
+	evts []*event.Event
+
+	// strs and stks hold the declared ids, refs the referenced ids.
+	strs, stks, refs map[uint64]bool
+}
+
+func decode(t *testing.T, b []byte) *decoded {
+	dec := encoding.NewDecoder(bytes.NewReader(b), encoding.Nanoseconds())
+	ver, err := dec.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := event.NewTrace(ver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &decoded{tr: tr, strs: make(map[uint64]bool),
+		stks: make(map[uint64]bool), refs: make(map[uint64]bool)}
+	for dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			t.Fatal(err)
+		}
+		if err := tr.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+		switch evt.Type {
+		case event.EvBatch, event.EvFrequency:
+			continue
+		case event.EvString:
+			d.strs[evt.Args[0]] = true
+			continue
+		case event.EvStack:
+			d.stks[evt.Args[0]] = true
+			for pos := 2; pos+3 < len(evt.Args); pos += 4 {
+				d.refs[evt.Args[pos+1]], d.refs[evt.Args[pos+2]] = true, true
+			}
+			continue
+		}
+		for i, name := range evt.Type.Args() {
+			if i >= len(evt.Args) {
+				break
+			}
+			switch name {
+			case event.ArgStackID, event.ArgNewStackID,
+				event.ArgLabelStringID, event.ArgNameStringID, event.ArgKeyStringID:
+				d.refs[evt.Args[i]] = true
+			}
+		}
+		d.evts = append(d.evts, evt)
+	}
+	return d
+}
+
+// key identifies an event by its type, time and P. The G of an event is not
+// included, as it is the goroutine last started on the P which may have been
+// started within another part.
+func key(evt *event.Event) string {
+	return fmt.Sprintf(`%v@%v/%v`, evt.Type, evt.Ts, evt.P)
+}
+
+// split splits b with fn and checks each part is a standalone trace holding
+// only the declarations it refers to.
+func split(t *testing.T, b []byte, fn Func) map[int64]*decoded {
+	bufs := make(map[int64]*bytes.Buffer)
+	err := Split(bytes.NewReader(b), fn, func(key int64) (io.Writer, error) {
+		if _, ok := bufs[key]; ok {
+			t.Fatalf(`part %v was opened twice`, key)
+		}
+		bufs[key] = new(bytes.Buffer)
+		return bufs[key], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := make(map[int64]*decoded)
+	for k, buf := range bufs {
+		d := decode(t, buf.Bytes())
+		if d.tr.Frequency == 0 {
+			t.Fatalf(`part %v has no frequency`, k)
+		}
+		for id := range d.strs {
+			if !d.refs[id] {
+				t.Fatalf(`part %v declares unreferenced string %v`, k, id)
+			}
+		}
+		for id := range d.stks {
+			if !d.refs[id] {
+				t.Fatalf(`part %v declares unreferenced stack %v`, k, id)
+			}
+		}
+		parts[k] = d
+	}
+	return parts
+}
+
+func TestSplit(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		if tf.Version == event.Version1 {
+			continue
+		}
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			orig := decode(t, tf.Bytes())
+			var exp []string
+			for _, evt := range orig.evts {
+				exp = append(exp, key(evt))
+			}
+			sort.Strings(exp)
+
+			start, end := orig.tr.TimeBounds()
+			d := time.Duration(end-start)/3 + 1
+			tests := []struct {
+				name  string
+				fn    Func
+				check func(k int64, evt *event.Event) bool
+			}{
+				{`ByP`, ByP(), func(k int64, evt *event.Event) bool {
+					return evt.P == k
+				}},
+				{`ByWindow`, ByWindow(d), func(k int64, evt *event.Event) bool {
+					lo := orig.evts[0].Ts + k*int64(d)
+					return evt.Ts < lo+int64(d) && (k == 0 || evt.Ts >= lo)
+				}},
+			}
+			for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					parts := split(t, tf.Bytes(), test.fn)
+					if len(parts) < 2 {
+						t.Fatalf(`exp at least 2 parts; got %v`, len(parts))
+					}
+					var got []string
+					for k, part := range parts {
+						for _, evt := range part.evts {
+							if !test.check(k, evt) {
+								t.Fatalf(`exp %v in part %v`, evt, k)
+							}
+							got = append(got, key(evt))
+						}
+					}
+					sort.Strings(got)
+					if fmt.Sprint(got) != fmt.Sprint(exp) {
+						t.Fatalf(`exp parts to hold the %v events of the trace; got %v`,
+							len(exp), len(got))
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestSplitByGoroutine(t *testing.T) {
+	tf := traceList.ByName(`log.trace`)
+	data := tf[len(tf)-1].Bytes()
+	parts := split(t, data, ByGoroutine([]uint64{1}, []uint64{1, 2, 3}))
+	if len(parts) != 2 {
+		t.Fatalf(`exp 2 parts; got %v`, len(parts))
+	}
+	for k, part := range parts {
+		for _, evt := range part.evts {
+			if (k == 0 && evt.G != 1) || (k == 1 && (evt.G < 2 || evt.G > 3)) {
+				t.Fatalf(`exp %v to not be in part %v`, evt, k)
+			}
+		}
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	v1 := traceList.ByName(`log.trace`)[0]
+	if v1.Version != event.Version1 {
+		t.Fatalf(`exp first fixture to be Version1; got %v`, v1.Version)
+	}
+	open := func(int64) (io.Writer, error) { return io.Discard, nil }
+	if err := Split(bytes.NewReader(v1.Bytes()), ByP(), open); err == nil {
+		t.Fatal(`exp non-nil err splitting Version1`)
+	}
+	if err := Split(bytes.NewReader(nil), ByP(), open); err == nil {
+		t.Fatal(`exp non-nil err splitting empty trace`)
+	}
+
+	data := traceList.ByName(`log.trace`)[1].Bytes()
+	exp := fmt.Errorf(`opening`)
+	fail := func(int64) (io.Writer, error) { return nil, exp }
+	if err := Split(bytes.NewReader(data), ByP(), fail); err != exp {
+		t.Fatalf(`exp err %v; got %v`, exp, err)
+	}
+}
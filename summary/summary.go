@@ -0,0 +1,249 @@
+// Package summary implements a single structured report of the contents of a
+// trace, with renderers for JSON and plain text, so command line tools and
+// services describe traces alike.
+package summary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// Report summarizes a trace, see Read.
+type Report struct {
+
+	// Version is the version of the trace format.
+	Version event.Version `json:"version"`
+
+	// Duration is the time from the first to the last event of the trace.
+	Duration time.Duration `json:"duration"`
+
+	// Events is the total number of events and Types the number of events of
+	// each type by its name.
+	Events int            `json:"events"`
+	Types  map[string]int `json:"types"`
+
+	// Goroutines counts the goroutines within the trace.
+	Goroutines Goroutines `json:"goroutines"`
+
+	// GC summarizes the garbage collections within the trace.
+	GC GC `json:"gc"`
+
+	// Blocking lists the stacks goroutines spent the most time blocked from,
+	// ordered by the most time blocked.
+	Blocking []Stack `json:"blocking"`
+
+	// Utilization describes how busy the Ps of the trace were.
+	Utilization Utilization `json:"utilization"`
+}
+
+// Goroutines counts the goroutines within a trace.
+type Goroutines struct {
+
+	// Total is the number of goroutines referred to by the trace, Created
+	// those created and Ended those which ended within it.
+	Total   int `json:"total"`
+	Created int `json:"created"`
+	Ended   int `json:"ended"`
+}
+
+// GC summarizes the garbage collections within a trace.
+type GC struct {
+
+	// Cycles is the number of garbage collections which began within the
+	// trace.
+	Cycles int `json:"cycles"`
+
+	// PauseTime is the total and MaxPause the longest stop the world pause,
+	// AssistTime and SweepTime the total time spent performing mark assists
+	// and sweeping.
+	PauseTime  time.Duration `json:"pause_time"`
+	MaxPause   time.Duration `json:"max_pause"`
+	AssistTime time.Duration `json:"assist_time"`
+	SweepTime  time.Duration `json:"sweep_time"`
+}
+
+// Stack is the number of times and total time goroutines blocked from a stack
+// with an event of Type.
+type Stack struct {
+	Type   string        `json:"type"`
+	Count  int           `json:"count"`
+	Time   time.Duration `json:"time"`
+	Frames []Frame       `json:"frames"`
+}
+
+// Frame is a single frame of a Stack.
+type Frame struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// Utilization describes how busy the Ps of a trace were.
+type Utilization struct {
+
+	// Procs is the number of Ps which ran goroutines during the trace and
+	// Busy the total time they spent running them.
+	Procs int           `json:"procs"`
+	Busy  time.Duration `json:"busy"`
+
+	// Fraction is Busy divided by the Duration of the trace for each of its
+	// Procs, from 0 to 1.
+	Fraction float64 `json:"fraction"`
+}
+
+// Read reads a trace from r and returns a Report of its contents, with the top
+// stacks goroutines blocked from.
+func Read(r io.Reader, top int) (*Report, error) {
+	dec := encoding.NewDecoder(r)
+	ver, err := dec.Version()
+	if err != nil {
+		return nil, err
+	}
+	tr, err := event.NewTrace(ver)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := &Report{Version: ver, Types: make(map[string]int)}
+	o, evt := event.NewOrderer(ver), new(event.Event)
+	for dec.More() {
+		evt.Reset()
+		if err := dec.Decode(evt); err != nil {
+			return nil, err
+		}
+		if err := tr.Visit(evt); err != nil {
+			return nil, err
+		}
+		rep.Events++
+		rep.Types[evt.Type.Name()]++
+		o.Push(evt)
+	}
+	if err := dec.Err(); err != nil {
+		return nil, err
+	}
+
+	evts := make([]*event.Event, 0, o.Len())
+	for {
+		evt, err := o.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		evts = append(evts, evt)
+	}
+
+	dur := func(ticks int64) time.Duration {
+		if ticks < 0 {
+			return 0
+		}
+		return tr.TicksToDuration(uint64(ticks))
+	}
+	rep.Duration = tr.Duration()
+
+	var busy int64
+	for _, g := range tr.Goroutines(evts) {
+		rep.Goroutines.Total++
+		if g.CreateTime != 0 {
+			rep.Goroutines.Created++
+		}
+		if g.EndTime != 0 {
+			rep.Goroutines.Ended++
+		}
+		busy += g.ExecTime
+	}
+
+	gc := tr.GC(evts)
+	rep.GC = GC{
+		Cycles:     len(gc.Cycles),
+		PauseTime:  dur(gc.PauseTime),
+		MaxPause:   dur(gc.MaxPause),
+		AssistTime: dur(gc.AssistTime),
+		SweepTime:  dur(gc.SweepTime),
+	}
+
+	for _, rec := range tr.BlockProfile(evts).Records {
+		if top >= 0 && len(rep.Blocking) >= top {
+			break
+		}
+		stk := Stack{Type: rec.Type.Name(), Count: rec.Count, Time: dur(rec.Time)}
+		for _, f := range tr.Stacks[rec.StackID] {
+			stk.Frames = append(stk.Frames, Frame{f.Func(), f.File(), f.Line()})
+		}
+		rep.Blocking = append(rep.Blocking, stk)
+	}
+
+	ps := make(map[int64]bool)
+	for _, evt := range evts {
+		switch evt.Type {
+		case event.EvGoStart, event.EvGoStartLocal, event.EvGoStartLabel:
+			ps[evt.P] = true
+		}
+	}
+	rep.Utilization.Procs = len(ps)
+	rep.Utilization.Busy = dur(busy)
+	if rep.Duration > 0 && len(ps) > 0 {
+		rep.Utilization.Fraction = float64(rep.Utilization.Busy) /
+			float64(rep.Duration) / float64(len(ps))
+	}
+	return rep, nil
+}
+
+// WriteJSON writes the report to w as a single indented JSON object, with
+// durations in nanoseconds.
+func (rep *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent(``, `  `)
+	return enc.Encode(rep)
+}
+
+// WriteText writes the report to w as plain text for reading in a terminal.
+func (rep *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Version:\t%v\n", rep.Version)
+	fmt.Fprintf(tw, "Duration:\t%v\n", rep.Duration)
+	fmt.Fprintf(tw, "Events:\t%v\n", rep.Events)
+	fmt.Fprintf(tw, "Goroutines:\t%v (%v created, %v ended)\n",
+		rep.Goroutines.Total, rep.Goroutines.Created, rep.Goroutines.Ended)
+	fmt.Fprintf(tw, "GC cycles:\t%v\n", rep.GC.Cycles)
+	fmt.Fprintf(tw, "GC pauses:\t%v (max %v)\n", rep.GC.PauseTime, rep.GC.MaxPause)
+	fmt.Fprintf(tw, "GC assists:\t%v\n", rep.GC.AssistTime)
+	fmt.Fprintf(tw, "GC sweeping:\t%v\n", rep.GC.SweepTime)
+	fmt.Fprintf(tw, "Utilization:\t%.1f%% of %v Ps (%v busy)\n",
+		rep.Utilization.Fraction*100, rep.Utilization.Procs, rep.Utilization.Busy)
+
+	names := make([]string, 0, len(rep.Types))
+	for name := range rep.Types {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := rep.Types[names[i]], rep.Types[names[j]]
+		return a > b || (a == b && names[i] < names[j])
+	})
+	fmt.Fprintf(tw, "\nEvent\tCount\n")
+	for _, name := range names {
+		fmt.Fprintf(tw, "%v\t%v\n", name, rep.Types[name])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(rep.Blocking) > 0 {
+		fmt.Fprintf(w, "\nTop blocking stacks:\n")
+	}
+	for i, stk := range rep.Blocking {
+		fmt.Fprintf(w, "%v. %v: %v blocked %v times\n", i+1, stk.Type, stk.Time, stk.Count)
+		for _, f := range stk.Frames {
+			fmt.Fprintf(w, "\t%v\n\t\t%v:%v\n", f.Func, f.File, f.Line)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package summary
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/internal/tracefile"
+)
+
+var traceList tracefile.TraceList
+
+func init() {
+	var err error
+	traceList, err = tracefile.Load(`../internal/tracefile`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		if tf.Version == event.Version1 {
+			continue
+		}
+		t.Run(tf.Version.Go(), func(t *testing.T) {
+			rep, err := Read(bytes.NewReader(tf.Bytes()), 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rep.Version != tf.Version {
+				t.Fatalf(`exp version %v; got %v`, tf.Version, rep.Version)
+			}
+			if rep.Duration <= 0 {
+				t.Fatalf(`exp positive duration; got %v`, rep.Duration)
+			}
+
+			var n int
+			for _, c := range rep.Types {
+				n += c
+			}
+			if n != rep.Events || rep.Types[event.EvFrequency.Name()] != 1 {
+				t.Fatalf(`exp type counts to total %v events; got %v`, rep.Events, n)
+			}
+			if g := rep.Goroutines; g.Total == 0 || g.Created > g.Total || g.Ended > g.Total {
+				t.Fatalf(`exp consistent goroutine counts; got %+v`, g)
+			}
+			if u := rep.Utilization; u.Procs == 0 || u.Fraction <= 0 || u.Fraction > 1 {
+				t.Fatalf(`exp utilization within (0, 1]; got %+v`, u)
+			}
+			if len(rep.Blocking) == 0 || len(rep.Blocking) > 3 {
+				t.Fatalf(`exp 1 to 3 blocking stacks; got %v`, len(rep.Blocking))
+			}
+			for i, stk := range rep.Blocking {
+				if i > 0 && stk.Time > rep.Blocking[i-1].Time {
+					t.Fatalf(`exp blocking stacks ordered by time; got %v`, rep.Blocking)
+				}
+			}
+		})
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	data := traceList.ByName(`log.trace`)[1].Bytes()
+	for _, b := range [][]byte{nil, data[:8], data[:len(data)/2]} {
+		if _, err := Read(bytes.NewReader(b), 1); err == nil {
+			t.Fatalf(`exp non-nil err reading %v bytes`, len(b))
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	tf := traceList.ByName(`log.trace`)
+	rep, err := Read(bytes.NewReader(tf[len(tf)-1].Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := rep.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := new(Report)
+	if err := json.Unmarshal(buf.Bytes(), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Duration != rep.Duration || got.Events != rep.Events ||
+		got.GC != rep.GC || len(got.Blocking) != len(rep.Blocking) {
+		t.Fatalf(`exp %+v; got %+v`, rep, got)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	tf := traceList.ByName(`log.trace`)
+	rep, err := Read(bytes.NewReader(tf[len(tf)-1].Bytes()), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := rep.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, exp := range []string{
+		`Duration:`, `Utilization:`, `Top blocking stacks:`,
+		rep.Duration.String(), rep.Blocking[0].Frames[0].Func,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Fatalf(`exp output to contain %q; got:\n%v`, exp, out)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Package tracehttp implements an http.Handler serving execution traces of the
+// current program, as a diagnostics endpoint in the manner of the trace
+// endpoint of net/http/pprof which may also snapshot a flight recorder and
+// filter the trace it serves.
+//
+// The handler accepts the following query parameters:
+//
+//	duration  how long to trace for, such as 5s, or how far back to snapshot
+//	types     comma separated event types to keep, such as GoCreate,GoEnd
+//	g         comma separated goroutine ids to keep events of
+//
+// For example:
+//
+//	http.Handle(`/debug/trace`, &tracehttp.Handler{Recorder: rec})
+//	curl -o app.trace 'localhost:8080/debug/trace?duration=2s&g=1,7'
+package tracehttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cstockton/go-trace"
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/filter"
+	"github.com/cstockton/go-trace/flightrec"
+)
+
+// DefaultDuration is the duration of captures when none is requested.
+const DefaultDuration = time.Second
+
+// Handler serves execution traces of the current program. Traces are served in
+// the format of the runtime, holding only the events matched by the filters of
+// the request along with the batches, frequency, strings and stacks needed to
+// decode them.
+type Handler struct {
+
+	// Recorder is snapshotted for each request when set, serving the batches
+	// it retained within the requested duration or every retained batch when
+	// no duration is requested. When nil the current program is traced for
+	// the requested duration, or DefaultDuration, and requests fail with 501
+	// Not Implemented when the runtime records an unsupported format.
+	Recorder *flightrec.Recorder
+
+	// MaxDuration limits the duration of captures when greater than zero.
+	MaxDuration time.Duration
+}
+
+// release is the Go release of the runtime, captures are refused when the
+// format its tracer produces is not supported by the encoding package.
+var release = runtime.Version()
+
+// capture traces the current program to w for d or until ctx is done. It may
+// be replaced by tests along with release, as the tracer of the runtime running
+// them may produce a format not supported by the encoding package.
+var capture = func(ctx context.Context, w io.Writer, d time.Duration) error {
+	if err := trace.Start(w); err != nil {
+		return err
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+	trace.Stop()
+	return nil
+}
+
+// request holds the parsed query parameters of a request.
+type request struct {
+	d time.Duration
+	f filter.Filter
+}
+
+func parseRequest(r *http.Request) (*request, error) {
+	q, req := r.URL.Query(), &request{}
+	var fs []filter.Filter
+	if s := q.Get(`duration`); s != `` {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf(`invalid duration %q`, s)
+		}
+		req.d = d
+	}
+	if s := q.Get(`types`); s != `` {
+		var types []event.Type
+		for _, name := range strings.Split(s, `,`) {
+			typ, ok := event.TypeFromString(strings.TrimSpace(name))
+			if !ok {
+				return nil, fmt.Errorf(`invalid event type %q`, name)
+			}
+			types = append(types, typ)
+		}
+		fs = append(fs, filter.ByType(types...))
+	}
+	if s := q.Get(`g`); s != `` {
+		var gs []uint64
+		for _, id := range strings.Split(s, `,`) {
+			g, err := strconv.ParseUint(strings.TrimSpace(id), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf(`invalid goroutine id %q`, id)
+			}
+			gs = append(gs, g)
+		}
+		fs = append(fs, filter.ByGoroutine(gs...))
+	}
+	req.f = filter.And(fs...)
+	return req, nil
+}
+
+// ServeHTTP implements http.Handler by serving a filtered trace.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(`X-Content-Type-Options`, `nosniff`)
+	req, err := parseRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var src func(ctx context.Context, w io.Writer) error
+	if h.Recorder != nil {
+		src = func(_ context.Context, w io.Writer) error {
+			return h.Recorder.Dump(w, req.d)
+		}
+	} else {
+		d := req.d
+		if d == 0 {
+			d = DefaultDuration
+		}
+		if h.MaxDuration > 0 && d > h.MaxDuration {
+			http.Error(w, fmt.Sprintf(`duration %v exceeds the maximum of %v`,
+				d, h.MaxDuration), http.StatusBadRequest)
+			return
+		}
+		if _, ok := event.VersionForGo(release); !ok {
+			http.Error(w, fmt.Sprintf(`tracing %v is not supported`, release),
+				http.StatusNotImplemented)
+			return
+		}
+		src = func(ctx context.Context, w io.Writer) error {
+			return capture(ctx, w, d)
+		}
+	}
+
+	// The trace is filtered as it is produced by writing it to a pipe, which
+	// is closed once the response is served to end the capture early.
+	ctx, cancel := context.WithCancel(r.Context())
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(src(ctx, pw))
+	}()
+	defer func() {
+		pr.Close()
+		cancel()
+		<-done
+	}()
+
+	// Errors of the source surface once the header is read, before any of the
+	// response has been written.
+	dec := encoding.NewDecoder(pr)
+	ver, err := dec.Version()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`could not trace: %v`, err),
+			http.StatusServiceUnavailable)
+		return
+	}
+	if ver == event.Version1 {
+		http.Error(w, fmt.Sprintf(`filtering %v is not supported`, ver),
+			http.StatusInternalServerError)
+		return
+	}
+
+	// The response is streamed as it is filtered, so errors past this point
+	// may only truncate it.
+	w.Header().Set(`Content-Type`, `application/octet-stream`)
+	w.Header().Set(`Content-Disposition`, `attachment; filename="trace"`)
+	enc := encoding.NewEncoder(w, encoding.TargetVersion(ver))
+	if err := filter.Stream(dec, enc, req.f); err != nil {
+		return
+	}
+	enc.Close()
+}
@@ -0,0 +1,201 @@
+package tracehttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/flightrec"
+	"github.com/cstockton/go-trace/internal/tracefile"
+)
+
+var traceList tracefile.TraceList
+
+func init() {
+	var err error
+	traceList, err = tracefile.Load(`../internal/tracefile`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// get serves a request for target with h, returning the response recorder.
+func get(h http.Handler, target string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(`GET`, target, nil))
+	return rr
+}
+
+// count decodes the trace b and returns the number of events of each type,
+// failing if it is not a valid trace.
+func count(t *testing.T, b []byte) map[event.Type]int {
+	dec := encoding.NewDecoder(bytes.NewReader(b))
+	ver, err := dec.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := event.NewTrace(ver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := make(map[event.Type]int)
+	for dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			t.Fatal(err)
+		}
+		if err := tr.Visit(evt); err != nil {
+			t.Fatal(err)
+		}
+		types[evt.Type]++
+	}
+	if tr.Frequency == 0 {
+		t.Fatal(`exp trace to declare its frequency`)
+	}
+	return types
+}
+
+func TestHandlerCapture(t *testing.T) {
+	tf := traceList.ByName(`log.trace`)
+	data := tf[len(tf)-1].Bytes()
+
+	var got time.Duration
+	defer func(fn func(context.Context, io.Writer, time.Duration) error, s string) {
+		capture, release = fn, s
+	}(capture, release)
+	release = tf[len(tf)-1].Version.Go()
+	capture = func(ctx context.Context, w io.Writer, d time.Duration) error {
+		got = d
+		_, err := w.Write(data)
+		return err
+	}
+
+	h := &Handler{MaxDuration: time.Minute}
+	rr := get(h, `/debug/trace`)
+	if rr.Code != http.StatusOK || got != DefaultDuration {
+		t.Fatalf(`exp 200 after capturing for %v; got %v after %v`,
+			DefaultDuration, rr.Code, got)
+	}
+	if ct := rr.Header().Get(`Content-Type`); ct != `application/octet-stream` {
+		t.Fatalf(`exp octet stream; got %q`, ct)
+	}
+	orig := count(t, data)
+	if all := count(t, rr.Body.Bytes()); all[event.EvGoStart] != orig[event.EvGoStart] {
+		t.Fatalf(`exp %v GoStart events; got %v`, orig[event.EvGoStart], all[event.EvGoStart])
+	}
+
+	rr = get(h, `/debug/trace?duration=3s&types=GoCreate,EvGoEnd`)
+	if rr.Code != http.StatusOK || got != 3*time.Second {
+		t.Fatalf(`exp 200 after capturing for 3s; got %v after %v`, rr.Code, got)
+	}
+	for typ, n := range count(t, rr.Body.Bytes()) {
+		switch typ {
+		case event.EvGoCreate, event.EvGoEnd:
+			if n != orig[typ] {
+				t.Fatalf(`exp %v %v events; got %v`, orig[typ], typ, n)
+			}
+		case event.EvBatch, event.EvFrequency, event.EvString, event.EvStack:
+		default:
+			t.Fatalf(`exp no %v events; got %v`, typ, n)
+		}
+	}
+
+	capture = func(context.Context, io.Writer, time.Duration) error {
+		return errors.New(`tracing is already enabled`)
+	}
+	if rr := get(h, `/debug/trace`); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf(`exp 503 when tracing is enabled; got %v`, rr.Code)
+	}
+}
+
+func TestHandlerRuntime(t *testing.T) {
+	orig, rel := capture, release
+	defer func() { capture, release = orig, rel }()
+	for _, s := range []string{`go1.10.8`, `go1.21.0`, `go1.22.0`} {
+		release = s
+		capture = func(context.Context, io.Writer, time.Duration) error {
+			t.Fatalf(`exp no capture on unsupported runtime %v`, s)
+			return nil
+		}
+		if rr := get(&Handler{}, `/debug/trace`); rr.Code != http.StatusNotImplemented {
+			t.Fatalf(`exp 501 for unsupported runtime %v; got %v`, s, rr.Code)
+		}
+	}
+	capture, release = orig, rel
+
+	h := &Handler{}
+	rr := get(h, `/debug/trace?duration=10ms`)
+	if _, ok := event.VersionForGo(runtime.Version()); !ok {
+		if rr.Code != http.StatusNotImplemented {
+			t.Fatalf(`exp 501 for unsupported runtime %v; got %v: %v`,
+				runtime.Version(), rr.Code, rr.Body)
+		}
+		return
+	}
+	if rr.Code != http.StatusOK {
+		t.Fatalf(`exp 200; got %v: %v`, rr.Code, rr.Body)
+	}
+	if n := count(t, rr.Body.Bytes())[event.EvBatch]; n == 0 {
+		t.Fatal(`exp batches in the trace of the runtime`)
+	}
+}
+
+func TestHandlerRecorder(t *testing.T) {
+	tf := traceList.ByName(`log.trace`)
+	data := tf[len(tf)-1].Bytes()
+
+	h := &Handler{Recorder: flightrec.New(0, 0)}
+	if rr := get(h, `/debug/trace`); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf(`exp 503 before recording; got %v`, rr.Code)
+	}
+	if err := h.Recorder.Consume(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := get(h, `/debug/trace?g=1`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf(`exp 200; got %v: %v`, rr.Code, rr.Body)
+	}
+	dec := encoding.NewDecoder(bytes.NewReader(rr.Body.Bytes()))
+	var n int
+	for dec.More() {
+		evt := new(event.Event)
+		if err := dec.Decode(evt); err != nil {
+			t.Fatal(err)
+		}
+		switch evt.Type {
+		case event.EvBatch, event.EvFrequency, event.EvString, event.EvStack:
+			continue
+		}
+		if evt.G != 1 {
+			t.Fatalf(`exp only events of goroutine 1; got %v`, evt)
+		}
+		n++
+	}
+	if n == 0 {
+		t.Fatal(`exp events of goroutine 1`)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	h := &Handler{MaxDuration: time.Second}
+	for _, target := range []string{
+		`/?duration=x`,
+		`/?duration=-1s`,
+		`/?duration=2s`,
+		`/?types=GoCreate,Unknown`,
+		`/?g=one`,
+	} {
+		if rr := get(h, target); rr.Code != http.StatusBadRequest {
+			t.Fatalf(`exp 400 for %v; got %v`, target, rr.Code)
+		}
+	}
+}
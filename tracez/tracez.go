@@ -0,0 +1,149 @@
+// Package tracez provides a small facade over the trace, encoding, event and
+// convert packages for the workflows most users need. The signatures in this
+// package are stable and insulated from changes to the packages it wraps, which
+// expose a more complete but lower level pipeline model.
+package tracez
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/cstockton/go-trace"
+	"github.com/cstockton/go-trace/convert"
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+)
+
+// Capture writes the execution trace of the current program to w until ctx is
+// done. Only a single capture may be active at once.
+func Capture(ctx context.Context, w io.Writer) error {
+	if err := trace.Start(w); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	trace.Stop()
+	return nil
+}
+
+// Summary describes the contents of a trace.
+type Summary struct {
+
+	// Version is the version of the trace format.
+	Version event.Version
+
+	// Events is the total number of events.
+	Events int
+
+	// Types is the number of events for each event type.
+	Types map[event.Type]int
+
+	// Strings and Stacks are the sizes of the string and stack tables.
+	Strings, Stacks int
+
+	// Goroutines is the number of goroutines created during the trace.
+	Goroutines int
+
+	// Stopped is true if the producer of the trace stopped tracing cleanly.
+	Stopped bool
+}
+
+// Summarize reads a trace from r and returns a summary of its contents.
+func Summarize(r io.Reader) (*Summary, error) {
+	dec := encoding.NewDecoder(r)
+	ver, err := dec.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := &Summary{Version: ver, Types: make(map[event.Type]int)}
+	err = each(dec, func(evt *event.Event) error {
+		sum.Events++
+		sum.Types[evt.Type]++
+		switch evt.Type {
+		case event.EvString:
+			sum.Strings++
+		case event.EvStack:
+			sum.Stacks++
+		case event.EvGoCreate:
+			sum.Goroutines++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sum.Stopped = dec.Stopped()
+	return sum, nil
+}
+
+// Filter reads a trace from src and writes the events keep returns true for to
+// dst. Callers should keep the EvString and EvStack events referenced by any
+// retained events, along with EvBatch and EvFrequency events if the output is
+// to be consumed by timing aware tools.
+func Filter(dst io.Writer, src io.Reader, keep func(evt *event.Event) bool) error {
+	return encoding.Transcode(dst, src, encoding.Transform(
+		func(evt *event.Event) (bool, error) {
+			return keep(evt), nil
+		}))
+}
+
+// Format is an output format supported by Convert.
+type Format string
+
+// Formats supported by Convert.
+const (
+	Mermaid      Format = `mermaid`
+	MermaidGantt Format = `mermaid-gantt`
+)
+
+// Convert reads a trace from src and writes it to dst in the given format.
+func Convert(dst io.Writer, src io.Reader, to Format) error {
+	switch to {
+	case Mermaid:
+		return convert.Mermaid(dst, src, convert.Sequence, convert.Window{})
+	case MermaidGantt:
+		return convert.Mermaid(dst, src, convert.Gantt, convert.Window{})
+	}
+	return fmt.Errorf(`tracez: unknown format %q`, to)
+}
+
+// Verify reads a trace from r and returns an error if it is malformed, refers
+// to undeclared state, ended before the producer stopped tracing cleanly or is
+// followed by trailing bytes.
+func Verify(r io.Reader) error {
+	dec := encoding.NewDecoder(r)
+	ver, err := dec.Version()
+	if err != nil {
+		return err
+	}
+	tr, err := event.NewTrace(ver)
+	if err != nil {
+		return err
+	}
+	err = each(dec, func(evt *event.Event) error {
+		if err := tr.Visit(evt); err != nil {
+			return fmt.Errorf(`%v at 0x%x`, err, evt.Off)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !dec.Stopped() {
+		return trace.ErrTruncated
+	}
+	if n := dec.TrailingBytes(); n > 0 {
+		return fmt.Errorf(`tracez: %d trailing bytes after the trace footer`, n)
+	}
+	return nil
+}
+
+type visitFunc func(evt *event.Event) error
+
+func (fn visitFunc) Visit(evt *event.Event) error { return fn(evt) }
+
+// each calls fn for every event decoded from dec.
+func each(dec *encoding.Decoder, fn visitFunc) error {
+	return dec.VisitAll(fn)
+}
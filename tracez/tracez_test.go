@@ -0,0 +1,139 @@
+package tracez
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cstockton/go-trace"
+	"github.com/cstockton/go-trace/encoding"
+	"github.com/cstockton/go-trace/event"
+	"github.com/cstockton/go-trace/internal/tracefile"
+)
+
+var traceList tracefile.TraceList
+
+func init() {
+	var err error
+	traceList, err = tracefile.Load(`../internal/tracefile`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	var buf bytes.Buffer
+	if err := Capture(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte(`go 1.`)) {
+		t.Fatal(`exp captured trace to begin with a trace header`)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	for _, tf := range traceList.ByName(`log.trace`) {
+		sum, err := Summarize(bytes.NewReader(tf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum.Version != tf.Version {
+			t.Fatalf(`exp version %v; got %v`, tf.Version, sum.Version)
+		}
+		if sum.Events == 0 || sum.Stacks == 0 || sum.Goroutines == 0 {
+			t.Fatalf(`exp non-zero summary; got %+v`, sum)
+		}
+		if exp, got := sum.Stacks, sum.Types[event.EvStack]; exp != got {
+			t.Fatalf(`exp %v stack events; got %v`, exp, got)
+		}
+		if !sum.Stopped {
+			t.Fatal(`exp trace to be stopped`)
+		}
+	}
+	if _, err := Summarize(bytes.NewReader(nil)); err == nil {
+		t.Fatal(`exp non-nil err for empty input`)
+	}
+
+	data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+	sum, err := Summarize(bytes.NewReader(append(data, `garbage`...)))
+	if err != nil {
+		t.Fatalf(`exp nil err with trailing bytes; got %v`, err)
+	}
+	if !sum.Stopped {
+		t.Fatal(`exp trace with trailing bytes to be stopped`)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+
+	var buf bytes.Buffer
+	err := Filter(&buf, bytes.NewReader(data), func(evt *event.Event) bool {
+		return evt.Type == event.EvGoCreate
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := Summarize(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, got := sum.Goroutines, sum.Events; exp != got || got == 0 {
+		t.Fatalf(`exp only %v GoCreate events; got %v`, exp, got)
+	}
+
+	old := traceList.ByVersion(event.Version1).ByName(`log.trace`)[0].Bytes()
+	keep := func(*event.Event) bool { return true }
+	if err := Filter(&buf, bytes.NewReader(old), keep); err == nil {
+		t.Fatal(`exp non-nil err for older versions`)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+	for _, to := range []Format{Mermaid, MermaidGantt} {
+		var buf bytes.Buffer
+		if err := Convert(&buf, bytes.NewReader(data), to); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() == 0 {
+			t.Fatalf(`exp non-empty output for %v`, to)
+		}
+	}
+	if err := Convert(new(bytes.Buffer), bytes.NewReader(data), `pdf`); err == nil {
+		t.Fatal(`exp non-nil err for unknown format`)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for _, tf := range traceList {
+		if err := Verify(bytes.NewReader(tf.Bytes())); err != nil {
+			t.Fatalf(`exp nil err for %v; got %v`, tf.Path, err)
+		}
+	}
+
+	data := traceList.ByVersion(event.Version4).ByName(`log.trace`)[0].Bytes()
+	if err := Verify(bytes.NewReader(data[:16+8])); err != trace.ErrTruncated {
+		t.Fatalf(`exp ErrTruncated; got %v`, err)
+	}
+	err := Verify(bytes.NewReader(append(data, `garbage`...)))
+	if err == nil || !strings.Contains(err.Error(), `trailing bytes`) {
+		t.Fatalf(`exp trailing bytes err; got %v`, err)
+	}
+
+	// duplicate string ids are rejected by the trace state
+	var buf bytes.Buffer
+	enc := encoding.NewEncoder(&buf)
+	for i := 0; i < 2; i++ {
+		enc.Emit(&event.Event{Type: event.EvString, Args: []uint64{1}, Data: []byte(`s`)})
+	}
+	err = Verify(&buf)
+	if err == nil || !strings.Contains(err.Error(), `already exists`) {
+		t.Fatalf(`exp duplicate string err; got %v`, err)
+	}
+}